@@ -0,0 +1,152 @@
+// mockai 本地开发用的模拟 AI 后端，实现 POST /chat 和 POST /chat/stream
+// 支持回显或固定回复，以及延迟和错误注入，便于在没有真实 AI 服务时联调整个管道
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"time"
+
+	"go-wework-svc/internal/ai"
+)
+
+// 回复模式
+const (
+	modeEcho   = "echo"
+	modeCanned = "canned"
+)
+
+type options struct {
+	mode        string
+	reply       string
+	latency     time.Duration
+	jitter      time.Duration
+	errorRate   float64
+	errorStatus int
+	chunkSize   int
+	chunkDelay  time.Duration
+}
+
+func main() {
+	addr := flag.String("addr", ":8081", "listen address")
+	var opts options
+	flag.StringVar(&opts.mode, "mode", modeEcho, "reply mode: echo | canned")
+	flag.StringVar(&opts.reply, "reply", "这是一条模拟回复。", "reply text in canned mode")
+	flag.DurationVar(&opts.latency, "latency", 0, "delay before replying")
+	flag.DurationVar(&opts.jitter, "jitter", 0, "random extra delay added to -latency")
+	flag.Float64Var(&opts.errorRate, "error-rate", 0, "fraction of requests (0-1) that fail")
+	flag.IntVar(&opts.errorStatus, "error-status", http.StatusInternalServerError, "HTTP status of injected failures")
+	flag.IntVar(&opts.chunkSize, "chunk-size", 4, "characters per delta in /chat/stream")
+	flag.DurationVar(&opts.chunkDelay, "chunk-delay", 50*time.Millisecond, "delay between deltas in /chat/stream")
+	flag.Parse()
+
+	if opts.mode != modeEcho && opts.mode != modeCanned {
+		fmt.Fprintf(os.Stderr, "invalid -mode %q, must be echo or canned\n", opts.mode)
+		os.Exit(2)
+	}
+	if opts.chunkSize <= 0 {
+		opts.chunkSize = 1
+	}
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	s := &server{opts: opts, logger: logger}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /chat", s.handleChat)
+	mux.HandleFunc("POST /chat/stream", s.handleStream)
+	mux.HandleFunc("GET /health", func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	logger.Info("mock AI backend listening", "addr", *addr, "mode", opts.mode, "error_rate", opts.errorRate)
+	if err := http.ListenAndServe(*addr, mux); err != nil {
+		logger.Error("server exited", "error", err)
+		os.Exit(1)
+	}
+}
+
+type server struct {
+	opts   options
+	logger *slog.Logger
+}
+
+// handleChat POST /chat
+func (s *server) handleChat(w http.ResponseWriter, r *http.Request) {
+	req, ok := s.prepare(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ai.ChatResponse{Reply: s.replyFor(req)})
+}
+
+// handleStream POST /chat/stream，以 SSE 分段输出回复
+func (s *server) handleStream(w http.ResponseWriter, r *http.Request) {
+	req, ok := s.prepare(w, r)
+	if !ok {
+		return
+	}
+	flusher, _ := w.(http.Flusher)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	reply := []rune(s.replyFor(req))
+	for i := 0; i < len(reply); i += s.opts.chunkSize {
+		end := min(i+s.opts.chunkSize, len(reply))
+		data, _ := json.Marshal(map[string]string{"delta": string(reply[i:end])})
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		if flusher != nil {
+			flusher.Flush()
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(s.opts.chunkDelay):
+		}
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+}
+
+// prepare 解析请求并执行延迟和错误注入，返回 false 表示已写出错误响应
+func (s *server) prepare(w http.ResponseWriter, r *http.Request) (ai.ChatRequest, bool) {
+	var req ai.ChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return req, false
+	}
+
+	delay := s.opts.latency
+	if s.opts.jitter > 0 {
+		delay += rand.N(s.opts.jitter)
+	}
+	select {
+	case <-r.Context().Done():
+		return req, false
+	case <-time.After(delay):
+	}
+
+	if s.opts.errorRate > 0 && rand.Float64() < s.opts.errorRate {
+		s.logger.Info("injecting failure", "path", r.URL.Path, "user_id", req.UserID, "status", s.opts.errorStatus)
+		http.Error(w, "injected failure", s.opts.errorStatus)
+		return req, false
+	}
+	s.logger.Info("chat request", "path", r.URL.Path, "user_id", req.UserID, "history", len(req.History), "delay", delay)
+	return req, true
+}
+
+// replyFor 按模式生成回复
+func (s *server) replyFor(req ai.ChatRequest) string {
+	if s.opts.mode == modeCanned {
+		return s.opts.reply
+	}
+	if req.Attachment != nil {
+		return fmt.Sprintf("收到 %s 消息：%s", req.Attachment.Type, req.Content)
+	}
+	return "echo: " + req.Content
+}
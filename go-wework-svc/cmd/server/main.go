@@ -2,31 +2,126 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"log/slog"
 	"os"
+	"strings"
 
 	"go-wework-svc/internal/bootstrap"
+	"go-wework-svc/internal/secret"
 	"go-wework-svc/internal/shared"
 )
 
+const defaultConfigPath = "configs/config.yaml"
+
+const usage = `Usage: server <command> [flags]
+
+Commands:
+  serve            启动服务（默认）
+  validate-config  加载并校验配置文件
+  gen-aeskey       生成新应用的 Token 和 EncodingAESKey
+  simulate         加密明文消息并模拟企业微信回调
+
+Run "server <command> -h" for command flags.
+`
+
 func main() {
-	configPath := flag.String("config", "configs/config.yaml", "path to config file")
-	flag.Parse()
+	args := os.Args[1:]
+	// 兼容旧的启动方式：无子命令或直接传 flag 时等同于 serve
+	cmd := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
+	}
+
+	var code int
+	switch cmd {
+	case "serve":
+		code = serve(args)
+	case "validate-config":
+		code = validateConfig(args)
+	case "gen-aeskey":
+		code = genAESKey(args)
+	case "simulate":
+		code = simulate(args)
+	case "help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", cmd, usage)
+		code = 2
+	}
+	os.Exit(code)
+}
+
+// serve 加载配置并运行服务直到收到退出信号
+func serve(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	configPath := fs.String("config", defaultConfigPath, "path to config file")
+	fs.Parse(args)
 
-	cfg, err := shared.LoadConfig(*configPath)
+	cfg, err := shared.LoadConfig(*configPath, secret.ResolveConfig)
 	if err != nil {
 		slog.Error("failed to load config", "error", err)
-		os.Exit(1)
+		return 1
 	}
 
-	app, err := bootstrap.NewApp(cfg)
+	app, err := bootstrap.NewApp(cfg, bootstrap.WithConfigReload(func() (*shared.Config, error) {
+		return shared.LoadConfig(*configPath, secret.ResolveConfig)
+	}))
 	if err != nil {
 		slog.Error("failed to init app", "error", err)
-		os.Exit(1)
+		return 1
 	}
 
 	if err := app.Run(); err != nil {
 		slog.Error("app exited with error", "error", err)
-		os.Exit(1)
+		return 1
+	}
+	return 0
+}
+
+// validateConfig 加载配置并输出校验结果，配置无效时返回非 0
+func validateConfig(args []string) int {
+	fs := flag.NewFlagSet("validate-config", flag.ExitOnError)
+	configPath := fs.String("config", defaultConfigPath, "path to config file")
+	fs.Parse(args)
+
+	cfg, err := shared.LoadConfig(*configPath, secret.ResolveConfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: invalid\n  %v\n", *configPath, err)
+		return 1
+	}
+
+	fmt.Printf("%s: ok\n", *configPath)
+	fmt.Printf("  server.addr:  %s\n", cfg.Server.Addr)
+	for _, l := range cfg.Server.Listeners {
+		if l.Admin {
+			fmt.Printf("  listener:     %s (admin)\n", l.Addr)
+		} else {
+			fmt.Printf("  listener:     %s\n", l.Addr)
+		}
+	}
+	fmt.Printf("  wework.corp:  %s (agent %d, reply_mode %s)\n", cfg.WeWork.CorpID, cfg.WeWork.AgentID, replyMode(cfg.WeWork.ReplyMode))
+	for _, a := range cfg.WeWork.Agents {
+		fmt.Printf("  agent:        %s -> /callback/%s\n", a.Name, a.Name)
+	}
+	for _, t := range cfg.Tenants {
+		fmt.Printf("  tenant:       %s -> /callback/%s\n", t.Name, t.Name)
+	}
+	fmt.Printf("  ai.base_url:  %s\n", cfg.AI.BaseURL)
+	fmt.Printf("  store:        %s\n", storeDriver(cfg.Store.Driver))
+	return 0
+}
+
+func replyMode(m string) string {
+	if m == "" {
+		return shared.ReplyModeAsync
+	}
+	return m
+}
+
+func storeDriver(d string) string {
+	if d == "" {
+		return shared.StoreDriverMemory
 	}
+	return d
 }
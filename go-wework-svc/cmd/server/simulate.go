@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"go-wework-svc/internal/secret"
+	"go-wework-svc/internal/shared"
+	"go-wework-svc/internal/wework"
+)
+
+const simulateTimeout = 30 * time.Second
+
+// simulate 使用配置中的 token / aeskey / corp_id 加密明文消息并模拟企业微信回调
+func simulate(args []string) int {
+	fs := flag.NewFlagSet("simulate", flag.ExitOnError)
+	configPath := fs.String("config", defaultConfigPath, "path to config file")
+	target := fs.String("url", "http://localhost:8080/callback", "callback URL to POST to")
+	agent := fs.String("agent", "", "agent or tenant name whose credentials to use, empty for the default app")
+	file := fs.String("file", "", `plaintext message XML file, "-" for stdin`)
+	text := fs.String("text", "", "build a text message with this content instead of -file")
+	from := fs.String("from", "simulator", "FromUserName used with -text")
+	fs.Parse(args)
+
+	cfg, err := shared.LoadConfig(*configPath, secret.ResolveConfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "load config: %v\n", err)
+		return 1
+	}
+	wcfg, ok := credentials(cfg, *agent)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown agent or tenant %q\n", *agent)
+		return 1
+	}
+	// 使用 encoding_aes_key 加密；被动回复可能使用轮换密钥加密，解密时一并尝试
+	crypto, err := wework.NewCrypto(wcfg.Token, wcfg.EncodingAESKey, wcfg.CorpID,
+		wework.WithRotationKeys(slog.New(slog.DiscardHandler), wcfg.EncodingAESKeys...))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "init crypto: %v\n", err)
+		return 1
+	}
+
+	plain, err := plaintextMessage(*file, *text, *from, wcfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	encrypted, err := crypto.Encrypt(plain)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "encrypt message: %v\n", err)
+		return 1
+	}
+	body, err := xml.Marshal(wework.EncryptedBody{
+		ToUserName: wcfg.CorpID,
+		AgentID:    strconv.FormatInt(wcfg.AgentID, 10),
+		Encrypt:    encrypted,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "marshal encrypted body: %v\n", err)
+		return 1
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := rand.Text()
+	u, err := url.Parse(*target)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "parse url: %v\n", err)
+		return 1
+	}
+	q := u.Query()
+	q.Set("msg_signature", crypto.Sign(timestamp, nonce, encrypted))
+	q.Set("timestamp", timestamp)
+	q.Set("nonce", nonce)
+	u.RawQuery = q.Encode()
+
+	client := &http.Client{Timeout: simulateTimeout}
+	resp, err := client.Post(u.String(), "application/xml", bytes.NewReader(body))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "post callback: %v\n", err)
+		return 1
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+
+	fmt.Printf("%s %s\n", resp.Status, respBody)
+	// 被动回复为加密 XML，解密后输出明文
+	var reply wework.EncryptedReply
+	if xml.Unmarshal(respBody, &reply) == nil && reply.Encrypt != "" {
+		if plain, err := crypto.Decrypt(context.Background(), reply.Encrypt); err == nil {
+			fmt.Printf("decrypted reply: %s\n", plain)
+		} else {
+			fmt.Fprintf(os.Stderr, "decrypt reply: %v\n", err)
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 1
+	}
+	return 0
+}
+
+// credentials 返回默认应用、命名应用或租户的回调配置
+func credentials(cfg *shared.Config, name string) (shared.WeWorkConfig, bool) {
+	if name == "" {
+		return cfg.WeWork, true
+	}
+	for _, a := range cfg.WeWork.Agents {
+		if a.Name == name {
+			return cfg.WeWork.ForAgent(a), true
+		}
+	}
+	for _, t := range cfg.Tenants {
+		if t.Name == name {
+			return t.WeWork, true
+		}
+	}
+	return shared.WeWorkConfig{}, false
+}
+
+// plaintextMessage 读取明文消息 XML，指定 text 时构造一条文本消息
+func plaintextMessage(file, text, from string, cfg shared.WeWorkConfig) ([]byte, error) {
+	switch {
+	case text != "":
+		msg := wework.Message{
+			ToUserName:   cfg.CorpID,
+			FromUserName: from,
+			CreateTime:   time.Now().Unix(),
+			MsgType:      wework.MsgTypeText,
+			Content:      text,
+			MsgID:        strconv.FormatInt(time.Now().UnixNano(), 10),
+			AgentID:      cfg.AgentID,
+		}
+		out, err := xml.Marshal(msg)
+		if err != nil {
+			return nil, fmt.Errorf("marshal message: %w", err)
+		}
+		return out, nil
+	case file == "-":
+		return io.ReadAll(os.Stdin)
+	case file != "":
+		return os.ReadFile(file)
+	}
+	return nil, fmt.Errorf("one of -file or -text is required")
+}
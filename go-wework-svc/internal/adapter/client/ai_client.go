@@ -8,77 +8,185 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
+	"slices"
+	"sync/atomic"
 	"time"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
 	"go-wework-svc/internal/ai"
 	"go-wework-svc/internal/shared"
 )
 
 // AIClient AI 助手 HTTP 客户端
 type AIClient struct {
-	baseURL    string
-	httpClient *http.Client
-	logger     *slog.Logger
-	retry      int
+	// settings 连接设置，可通过 Update 在运行时替换，每次请求开始时读取一次
+	settings atomic.Pointer[clientSettings]
+	logger   *slog.Logger
+}
+
+// clientSettings AIClient 的连接设置
+type clientSettings struct {
+	baseURL      string
+	httpClient   *http.Client
+	streamClient *http.Client
+	retry        int
+	baseDelay    time.Duration
+	maxDelay     time.Duration
 }
 
+// defaultStreamTimeout 流式请求默认整体超时
+const defaultStreamTimeout = 2 * time.Minute
+
 // NewAIClient 创建 AI HTTP 客户端
 func NewAIClient(cfg shared.AIConfig, logger *slog.Logger) *AIClient {
-	return &AIClient{
+	c := &AIClient{logger: logger}
+	c.Update(cfg)
+	return c
+}
+
+// Update 替换后端地址、超时和重试策略，进行中的请求沿用旧设置
+func (c *AIClient) Update(cfg shared.AIConfig) {
+	c.settings.Store(newClientSettings(cfg))
+}
+
+// newClientSettings 按配置创建连接设置，未配置的超时和退避使用默认值
+func newClientSettings(cfg shared.AIConfig) *clientSettings {
+	streamTimeout := cfg.StreamTimeout
+	if streamTimeout <= 0 {
+		streamTimeout = defaultStreamTimeout
+	}
+	baseDelay := cfg.RetryBaseDelay
+	if baseDelay <= 0 {
+		baseDelay = defaultRetryBaseDelay
+	}
+	maxDelay := cfg.RetryMaxDelay
+	if maxDelay <= 0 {
+		maxDelay = defaultRetryMaxDelay
+	}
+	return &clientSettings{
 		baseURL: cfg.BaseURL,
 		httpClient: &http.Client{
 			Timeout: cfg.Timeout,
 		},
-		logger: logger,
-		retry:  cfg.Retry,
+		streamClient: &http.Client{
+			Timeout: streamTimeout,
+		},
+		retry:     cfg.Retry,
+		baseDelay: baseDelay,
+		maxDelay:  maxDelay,
 	}
 }
 
+var tracer = otel.Tracer("go-wework-svc/internal/adapter/client")
+
+// Ping 请求 GET {base_url}/health 探测后端是否可达，5xx 视为不可用
+// 后端未实现该路径时返回的 404 等状态仍表示服务在线
+func (c *AIClient) Ping(ctx context.Context) error {
+	s := c.settings.Load()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.baseURL+"/health", nil)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("execute request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
 // SendMessage 实现 ai.Service 接口，将消息发送给 AI 助手
 func (c *AIClient) SendMessage(ctx context.Context, req ai.ChatRequest) (*ai.ChatResponse, error) {
+	s := c.settings.Load()
+	ctx, span := tracer.Start(ctx, "ai.SendMessage",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("ai.base_url", s.baseURL)),
+	)
+	defer span.End()
+
+	for round := 0; ; round++ {
+		resp, attempts, err := c.sendWithRetry(ctx, s, req)
+		span.SetAttributes(attribute.Int("ai.attempts", attempts))
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "ai request failed")
+			return nil, err
+		}
+		if len(resp.ToolCalls) == 0 || len(req.Tools) == 0 || round == ai.MaxToolRounds {
+			span.SetAttributes(attribute.Int("ai.tool_rounds", round))
+			resp.ToolCalls = nil
+			return resp, nil
+		}
+		// 每轮重新序列化请求，后端据 ToolResults 继续生成回复
+		req.ToolResults = slices.Clone(req.ToolResults)
+		for _, call := range resp.ToolCalls {
+			req.ToolResults = append(req.ToolResults, runTool(ctx, c.logger, req, call))
+		}
+	}
+}
+
+// runTool 执行后端发起的工具调用并记录日志和追踪
+func runTool(ctx context.Context, logger *slog.Logger, req ai.ChatRequest, call ai.ToolCall) ai.ToolResult {
+	ctx, span := tracer.Start(ctx, "ai.Tool", trace.WithAttributes(attribute.String("ai.tool", call.Name)))
+	defer span.End()
+
+	res := ai.RunTool(ctx, req.Tools, req.UserID, call)
+	if res.IsError {
+		span.SetStatus(codes.Error, res.Content)
+		logger.WarnContext(ctx, "AI tool call failed", "tool", call.Name, "user_id", req.UserID, "error", res.Content)
+		return res
+	}
+	logger.InfoContext(ctx, "AI tool called", "tool", call.Name, "user_id", req.UserID)
+	return res
+}
+
+// sendWithRetry 按退避策略重试可重试的错误，返回实际尝试次数
+func (c *AIClient) sendWithRetry(ctx context.Context, s *clientSettings, req ai.ChatRequest) (*ai.ChatResponse, int, error) {
 	body, err := json.Marshal(req)
 	if err != nil {
-		return nil, fmt.Errorf("marshal chat request: %w", err)
+		return nil, 0, fmt.Errorf("marshal chat request: %w", err)
 	}
 
 	var lastErr error
-	attempts := c.retry + 1 // first attempt + retries
-
-	for i := range attempts {
-		resp, err := c.doRequest(ctx, body)
+	attempts := 0
+	for i := range s.retry + 1 { // first attempt + retries
+		attempts++
+		resp, err := c.doRequest(ctx, s, body)
 		if err == nil {
-			return resp, nil
+			return resp, attempts, nil
 		}
 		lastErr = err
-
-		// Don't sleep after the last attempt
-		if i < c.retry {
-			delay := time.Duration(500<<uint(i)) * time.Millisecond // 500ms, 1s, 2s, ...
-			select {
-			case <-ctx.Done():
-				return nil, ctx.Err()
-			case <-time.After(delay):
-			}
+		if i == s.retry || !waitRetry(ctx, c.logger, s, err, i) {
+			break
 		}
 	}
 
-	c.logger.Error("all retries failed for AI request",
+	c.logger.ErrorContext(ctx, "AI request failed",
 		"user_id", req.UserID,
 		"attempts", attempts,
 		"error", lastErr,
 	)
-	return nil, fmt.Errorf("send message after %d attempts: %w", attempts, lastErr)
+	return nil, attempts, fmt.Errorf("send message after %d attempts: %w", attempts, lastErr)
 }
 
 // doRequest 执行单次 HTTP POST 请求
-func (c *AIClient) doRequest(ctx context.Context, body []byte) (*ai.ChatResponse, error) {
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/chat", bytes.NewReader(body))
+func (c *AIClient) doRequest(ctx context.Context, s *clientSettings, body []byte) (*ai.ChatResponse, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, s.baseURL+"/chat", bytes.NewReader(body))
 	if err != nil {
 		return nil, fmt.Errorf("create request: %w", err)
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(httpReq.Header))
 
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := s.httpClient.Do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("execute request: %w", err)
 	}
@@ -86,7 +194,7 @@ func (c *AIClient) doRequest(ctx context.Context, body []byte) (*ai.ChatResponse
 
 	if resp.StatusCode != http.StatusOK {
 		respBody, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(respBody))
+		return nil, newStatusError(resp, respBody)
 	}
 
 	var chatResp ai.ChatResponse
@@ -0,0 +1,132 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"go-wework-svc/internal/ai"
+)
+
+// maxStreamLine 单行流式数据的最大长度
+const maxStreamLine = 1 << 20
+
+// streamChunk 流式响应的单个数据块
+type streamChunk struct {
+	Delta string `json:"delta"`
+}
+
+// SendMessageStream 实现 ai.StreamingService 接口，请求 POST /chat/stream
+// 支持 SSE（data: {...}，以 data: [DONE] 结束）和逐行 JSON 的分块响应
+// 尚未收到任何增量时可重试的失败会按重试策略重试，已输出部分内容后失败则直接返回错误
+// 请求带工具时退化为一次性回复，以便执行后端返回的工具调用
+func (c *AIClient) SendMessageStream(ctx context.Context, req ai.ChatRequest, onDelta func(string)) (*ai.ChatResponse, error) {
+	if len(req.Tools) > 0 {
+		resp, err := c.SendMessage(ctx, req)
+		if err == nil && resp.Reply != "" {
+			onDelta(resp.Reply)
+		}
+		return resp, err
+	}
+	s := c.settings.Load()
+	ctx, span := tracer.Start(ctx, "ai.SendMessageStream",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("ai.base_url", s.baseURL)),
+	)
+	defer span.End()
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("marshal chat request: %w", err)
+	}
+
+	var lastErr error
+	for i := range s.retry + 1 {
+		var reply strings.Builder
+		received := false
+		err := c.doStream(ctx, s, body, func(delta string) {
+			received = true
+			reply.WriteString(delta)
+			onDelta(delta)
+		})
+		if err == nil {
+			span.SetAttributes(attribute.Int("ai.attempts", i+1))
+			return &ai.ChatResponse{Reply: reply.String()}, nil
+		}
+		lastErr = err
+		if received || i == s.retry || !waitRetry(ctx, c.logger, s, err, i) {
+			break
+		}
+	}
+
+	span.RecordError(lastErr)
+	span.SetStatus(codes.Error, "ai stream failed")
+	c.logger.ErrorContext(ctx, "AI stream request failed", "user_id", req.UserID, "error", lastErr)
+	return nil, fmt.Errorf("stream message: %w", lastErr)
+}
+
+// doStream 执行单次流式请求
+func (c *AIClient) doStream(ctx context.Context, s *clientSettings, body []byte, onDelta func(string)) error {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, s.baseURL+"/chat/stream", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "text/event-stream")
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(httpReq.Header))
+
+	// 流式响应耗时长，使用单独的整体超时
+	resp, err := s.streamClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return newStatusError(resp, respBody)
+	}
+
+	sse := strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream")
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxStreamLine)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if sse {
+			data, ok := strings.CutPrefix(line, "data:")
+			if !ok {
+				continue // 事件名、注释和空行
+			}
+			line = strings.TrimSpace(data)
+			if line == "[DONE]" {
+				return nil
+			}
+		}
+		if line == "" {
+			continue
+		}
+
+		var chunk streamChunk
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			return fmt.Errorf("decode stream chunk: %w", err)
+		}
+		if chunk.Delta != "" {
+			onDelta(chunk.Delta)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read stream: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-wework-svc/internal/wework"
+	"go-wework-svc/internal/wework/token"
+)
+
+// ApprovalClient 基于 oa/getapprovaldetail API 的 wework.ApprovalClient 实现
+type ApprovalClient struct {
+	api *weworkAPI
+}
+
+// NewApprovalClient 创建审批客户端，tokens 需为审批应用的 secret 或已获授权的自建应用 secret
+func NewApprovalClient(baseURL string, tokens token.TokenProvider) *ApprovalClient {
+	return &ApprovalClient{
+		api: &weworkAPI{
+			baseURL:    baseURL,
+			tokens:     tokens,
+			httpClient: &http.Client{Timeout: weworkAPITimeout},
+		},
+	}
+}
+
+// approvalDetailResponse oa/getapprovaldetail 响应体，仅解析所需字段
+type approvalDetailResponse struct {
+	Info struct {
+		SpNo     string `json:"sp_no"`
+		SpName   string `json:"sp_name"`
+		SpStatus int    `json:"sp_status"`
+		Applyer  struct {
+			UserID string `json:"userid"`
+		} `json:"applyer"`
+		ApplyData struct {
+			Contents []approvalControl `json:"contents"`
+		} `json:"apply_data"`
+	} `json:"info"`
+}
+
+// approvalControl 申请内容中的控件
+type approvalControl struct {
+	Control string `json:"control"`
+	Title   []struct {
+		Text string `json:"text"`
+	} `json:"title"`
+	Value struct {
+		Text      string `json:"text"`
+		NewNumber string `json:"new_number"`
+		NewMoney  string `json:"new_money"`
+		Date      struct {
+			Type      string `json:"type"` // day | hour
+			Timestamp string `json:"s_timestamp"`
+		} `json:"date"`
+		Selector struct {
+			Options []struct {
+				Value []struct {
+					Text string `json:"text"`
+				} `json:"value"`
+			} `json:"options"`
+		} `json:"selector"`
+		Members []struct {
+			Name string `json:"name"`
+		} `json:"members"`
+		Departments []struct {
+			Name string `json:"name"`
+		} `json:"departments"`
+		Files []struct {
+			FileID string `json:"file_id"`
+		} `json:"files"`
+	} `json:"value"`
+}
+
+// Detail 实现 wework.ApprovalClient 接口
+func (c *ApprovalClient) Detail(ctx context.Context, spNo string) (*wework.Approval, error) {
+	var resp approvalDetailResponse
+	if err := c.api.postJSON(ctx, "/cgi-bin/oa/getapprovaldetail", map[string]string{"sp_no": spNo}, &resp); err != nil {
+		return nil, fmt.Errorf("get approval detail: %w", err)
+	}
+	info := resp.Info
+	a := &wework.Approval{
+		SpNo:      info.SpNo,
+		SpName:    info.SpName,
+		Status:    info.SpStatus,
+		Applicant: info.Applyer.UserID,
+	}
+	for _, ctl := range info.ApplyData.Contents {
+		var title string
+		if len(ctl.Title) > 0 {
+			title = ctl.Title[0].Text
+		}
+		a.Fields = append(a.Fields, wework.ApprovalField{Title: title, Value: ctl.text()})
+	}
+	return a, nil
+}
+
+// text 将控件的值转换为文本，不支持的控件返回空
+func (ctl approvalControl) text() string {
+	v := ctl.Value
+	switch ctl.Control {
+	case "Text", "Textarea":
+		return v.Text
+	case "Number":
+		return v.NewNumber
+	case "Money":
+		return v.NewMoney
+	case "Date":
+		sec, err := strconv.ParseInt(v.Date.Timestamp, 10, 64)
+		if err != nil {
+			return ""
+		}
+		layout := time.DateOnly
+		if v.Date.Type == "hour" {
+			layout = "2006-01-02 15:04"
+		}
+		return time.Unix(sec, 0).Format(layout)
+	case "Selector":
+		var texts []string
+		for _, o := range v.Selector.Options {
+			for _, t := range o.Value {
+				texts = append(texts, t.Text)
+			}
+		}
+		return strings.Join(texts, "、")
+	case "Contact":
+		var names []string
+		for _, m := range v.Members {
+			names = append(names, m.Name)
+		}
+		for _, d := range v.Departments {
+			names = append(names, d.Name)
+		}
+		return strings.Join(names, "、")
+	case "File":
+		if len(v.Files) > 0 {
+			return fmt.Sprintf("%d 个附件", len(v.Files))
+		}
+	}
+	return ""
+}
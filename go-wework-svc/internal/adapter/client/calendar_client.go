@@ -0,0 +1,107 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go-wework-svc/internal/wework"
+	"go-wework-svc/internal/wework/token"
+)
+
+// schedulePageSize oa/schedule/get_by_calendar 单页条数上限
+const schedulePageSize = 1000
+
+// CalendarClient 基于 oa/schedule API 的 wework.CalendarClient 实现
+type CalendarClient struct {
+	api *weworkAPI
+}
+
+// NewCalendarClient 创建日程客户端，应用需在管理后台开启日程的 API 权限
+func NewCalendarClient(baseURL string, tokens token.TokenProvider) *CalendarClient {
+	return &CalendarClient{
+		api: &weworkAPI{
+			baseURL:    baseURL,
+			tokens:     tokens,
+			httpClient: &http.Client{Timeout: weworkAPITimeout},
+		},
+	}
+}
+
+// scheduleAttendee 日程参与者
+type scheduleAttendee struct {
+	UserID string `json:"userid"`
+}
+
+// scheduleBody oa/schedule 接口中的日程
+type scheduleBody struct {
+	ScheduleID  string             `json:"schedule_id,omitempty"`
+	Organizer   string             `json:"organizer"`
+	Attendees   []scheduleAttendee `json:"attendees,omitempty"`
+	Summary     string             `json:"summary"`
+	Description string             `json:"description,omitempty"`
+	Location    string             `json:"location,omitempty"`
+	StartTime   int64              `json:"start_time"`
+	EndTime     int64              `json:"end_time"`
+	CalID       string             `json:"cal_id,omitempty"`
+	Status      int                `json:"status,omitempty"` // 1 已取消
+}
+
+// AddSchedule 实现 wework.CalendarClient 接口
+func (c *CalendarClient) AddSchedule(ctx context.Context, calID string, sch wework.Schedule) (string, error) {
+	body := scheduleBody{
+		Organizer:   sch.Organizer,
+		Summary:     sch.Summary,
+		Description: sch.Description,
+		Location:    sch.Location,
+		StartTime:   sch.Start.Unix(),
+		EndTime:     sch.End.Unix(),
+		CalID:       calID,
+	}
+	for _, u := range sch.Attendees {
+		body.Attendees = append(body.Attendees, scheduleAttendee{UserID: u})
+	}
+	var resp struct {
+		ScheduleID string `json:"schedule_id"`
+	}
+	if err := c.api.postJSON(ctx, "/cgi-bin/oa/schedule/add", map[string]any{"schedule": body}, &resp); err != nil {
+		return "", fmt.Errorf("add schedule: %w", err)
+	}
+	return resp.ScheduleID, nil
+}
+
+// Schedules 实现 wework.CalendarClient 接口，逐页读取日历中的全部日程
+func (c *CalendarClient) Schedules(ctx context.Context, calID string) ([]wework.Schedule, error) {
+	var out []wework.Schedule
+	for offset := 0; ; offset += schedulePageSize {
+		var resp struct {
+			ScheduleList []scheduleBody `json:"schedule_list"`
+		}
+		in := map[string]any{"cal_id": calID, "offset": offset, "limit": schedulePageSize}
+		if err := c.api.postJSON(ctx, "/cgi-bin/oa/schedule/get_by_calendar", in, &resp); err != nil {
+			return nil, fmt.Errorf("list schedules: %w", err)
+		}
+		for _, b := range resp.ScheduleList {
+			if b.Status == 1 {
+				continue
+			}
+			sch := wework.Schedule{
+				ID:          b.ScheduleID,
+				Organizer:   b.Organizer,
+				Summary:     b.Summary,
+				Description: b.Description,
+				Location:    b.Location,
+				Start:       time.Unix(b.StartTime, 0),
+				End:         time.Unix(b.EndTime, 0),
+			}
+			for _, a := range b.Attendees {
+				sch.Attendees = append(sch.Attendees, a.UserID)
+			}
+			out = append(out, sch)
+		}
+		if len(resp.ScheduleList) < schedulePageSize {
+			return out, nil
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go-wework-svc/internal/wework/token"
+)
+
+// CallbackIPClient 基于 getcallbackip API 的 handler.CallbackIPSource 实现
+type CallbackIPClient struct {
+	api *weworkAPI
+}
+
+// NewCallbackIPClient 创建回调 IP 段客户端，任一应用的 access_token 均可调用
+func NewCallbackIPClient(baseURL string, tokens token.TokenProvider) *CallbackIPClient {
+	return &CallbackIPClient{
+		api: &weworkAPI{
+			baseURL:    baseURL,
+			tokens:     tokens,
+			httpClient: &http.Client{Timeout: weworkAPITimeout},
+		},
+	}
+}
+
+// callbackIPResponse getcallbackip 响应，条目为 IP 或 CIDR
+type callbackIPResponse struct {
+	IPList []string `json:"ip_list"`
+}
+
+// CallbackIPs 实现 handler.CallbackIPSource 接口
+func (c *CallbackIPClient) CallbackIPs(ctx context.Context) ([]string, error) {
+	var resp callbackIPResponse
+	if err := c.api.getJSON(ctx, "/cgi-bin/getcallbackip", nil, &resp); err != nil {
+		return nil, fmt.Errorf("get callback ip: %w", err)
+	}
+	return resp.IPList, nil
+}
@@ -0,0 +1,341 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"go-wework-svc/internal/ai"
+	"go-wework-svc/internal/shared"
+	"go-wework-svc/internal/store"
+)
+
+// defaultDifyConversationTTL Dify 会话默认空闲时长，超过后开启新会话
+const defaultDifyConversationTTL = 24 * time.Hour
+
+// DifyClient Dify 对话型应用客户端（POST /chat-messages）
+// 会话上下文保存在 Dify，ChatRequest.ConversationID 与 Dify conversation_id 的对应关系保存在 store 中
+type DifyClient struct {
+	settings      atomic.Pointer[difySettings]
+	conversations store.Store
+	logger        *slog.Logger
+}
+
+// difySettings DifyClient 的连接与应用设置
+type difySettings struct {
+	*clientSettings
+	dify shared.DifyConfig
+	ttl  time.Duration
+}
+
+// NewDifyClient 创建 Dify 客户端，kv 用于保存会话对应关系，多副本部署时应为共享存储
+func NewDifyClient(cfg shared.AIConfig, kv store.Store, logger *slog.Logger) *DifyClient {
+	c := &DifyClient{conversations: kv, logger: logger}
+	c.Update(cfg)
+	return c
+}
+
+// Update 替换后端地址、超时、重试策略和应用参数，进行中的请求沿用旧设置
+func (c *DifyClient) Update(cfg shared.AIConfig) {
+	ttl := cfg.Dify.ConversationTTL
+	if ttl <= 0 {
+		ttl = defaultDifyConversationTTL
+	}
+	c.settings.Store(&difySettings{clientSettings: newClientSettings(cfg), dify: cfg.Dify, ttl: ttl})
+}
+
+// difyFile 远程文件附件
+type difyFile struct {
+	Type           string `json:"type"` // image
+	TransferMethod string `json:"transfer_method"`
+	URL            string `json:"url"`
+}
+
+// difyChatRequest POST /chat-messages 请求体
+type difyChatRequest struct {
+	Inputs         map[string]string `json:"inputs"`
+	Query          string            `json:"query"`
+	ResponseMode   string            `json:"response_mode"` // blocking | streaming
+	ConversationID string            `json:"conversation_id,omitempty"`
+	User           string            `json:"user"`
+	Files          []difyFile        `json:"files,omitempty"`
+}
+
+// difyEvent 阻塞模式的响应体，流式模式下每个 SSE 事件结构相同
+type difyEvent struct {
+	Event          string `json:"event"` // message | agent_message | message_end | error ...
+	Answer         string `json:"answer"`
+	ConversationID string `json:"conversation_id"`
+	Message        string `json:"message"` // error 事件的错误信息
+}
+
+// SendMessage 实现 ai.Service 接口
+func (c *DifyClient) SendMessage(ctx context.Context, req ai.ChatRequest) (*ai.ChatResponse, error) {
+	s := c.settings.Load()
+	ctx, span := tracer.Start(ctx, "ai.SendMessage",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("ai.base_url", s.baseURL)),
+	)
+	defer span.End()
+
+	convID := c.conversation(ctx, req)
+	reply, newConvID, attempts, err := c.send(ctx, s, req, convID)
+	if err != nil && convID != "" && conversationGone(err) {
+		// Dify 侧会话已删除，开启新会话
+		c.logger.InfoContext(ctx, "dify conversation not found, starting a new one", "conversation_id", convID)
+		reply, newConvID, attempts, err = c.send(ctx, s, req, "")
+	}
+	span.SetAttributes(attribute.Int("ai.attempts", attempts))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "ai request failed")
+		c.logger.ErrorContext(ctx, "AI request failed", "user_id", req.UserID, "attempts", attempts, "error", err)
+		return nil, fmt.Errorf("send message after %d attempts: %w", attempts, err)
+	}
+	c.remember(ctx, s, req, newConvID)
+	return &ai.ChatResponse{Reply: reply}, nil
+}
+
+// send 按重试策略发送阻塞请求，返回回复、Dify 会话 ID 和尝试次数
+func (c *DifyClient) send(ctx context.Context, s *difySettings, req ai.ChatRequest, convID string) (string, string, int, error) {
+	body, err := json.Marshal(s.chatRequest(req, convID, false))
+	if err != nil {
+		return "", "", 0, fmt.Errorf("marshal chat request: %w", err)
+	}
+
+	var lastErr error
+	attempts := 0
+	for i := range s.retry + 1 {
+		attempts++
+		out, err := c.chat(ctx, s, body)
+		if err == nil {
+			return out.Answer, out.ConversationID, attempts, nil
+		}
+		lastErr = err
+		if i == s.retry || !waitRetry(ctx, c.logger, s.clientSettings, err, i) {
+			break
+		}
+	}
+	return "", "", attempts, lastErr
+}
+
+// chat 执行单次阻塞请求
+func (c *DifyClient) chat(ctx context.Context, s *difySettings, body []byte) (*difyEvent, error) {
+	resp, err := s.do(ctx, s.httpClient, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var out difyEvent
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	return &out, nil
+}
+
+// SendMessageStream 实现 ai.StreamingService 接口，以 streaming 模式请求并解析 SSE 增量
+// 尚未收到任何增量时可重试的失败会按重试策略重试
+func (c *DifyClient) SendMessageStream(ctx context.Context, req ai.ChatRequest, onDelta func(string)) (*ai.ChatResponse, error) {
+	s := c.settings.Load()
+	ctx, span := tracer.Start(ctx, "ai.SendMessageStream",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("ai.base_url", s.baseURL)),
+	)
+	defer span.End()
+
+	convID := c.conversation(ctx, req)
+	var lastErr error
+	for i := 0; i <= s.retry; i++ {
+		body, err := json.Marshal(s.chatRequest(req, convID, true))
+		if err != nil {
+			return nil, fmt.Errorf("marshal chat request: %w", err)
+		}
+		var reply strings.Builder
+		received := false
+		newConvID, err := c.stream(ctx, s, body, func(delta string) {
+			received = true
+			reply.WriteString(delta)
+			onDelta(delta)
+		})
+		if err == nil {
+			span.SetAttributes(attribute.Int("ai.attempts", i+1))
+			c.remember(ctx, s, req, newConvID)
+			return &ai.ChatResponse{Reply: reply.String()}, nil
+		}
+		lastErr = err
+		if convID != "" && conversationGone(err) {
+			c.logger.InfoContext(ctx, "dify conversation not found, starting a new one", "conversation_id", convID)
+			convID = ""
+			i--
+			continue
+		}
+		if received || i == s.retry || !waitRetry(ctx, c.logger, s.clientSettings, err, i) {
+			break
+		}
+	}
+
+	span.RecordError(lastErr)
+	span.SetStatus(codes.Error, "ai stream failed")
+	c.logger.ErrorContext(ctx, "AI stream request failed", "user_id", req.UserID, "error", lastErr)
+	return nil, fmt.Errorf("stream message: %w", lastErr)
+}
+
+// stream 执行单次流式请求，返回 Dify 会话 ID；未收到 message_end 即断开视为失败
+func (c *DifyClient) stream(ctx context.Context, s *difySettings, body []byte, onDelta func(string)) (string, error) {
+	resp, err := s.do(ctx, s.streamClient, body)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var convID string
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxStreamLine)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "data:")
+		if !ok {
+			continue
+		}
+		var ev difyEvent
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &ev); err != nil {
+			return "", fmt.Errorf("decode stream event: %w", err)
+		}
+		if ev.ConversationID != "" {
+			convID = ev.ConversationID
+		}
+		switch ev.Event {
+		case "message", "agent_message":
+			if ev.Answer != "" {
+				onDelta(ev.Answer)
+			}
+		case "message_end":
+			return convID, nil
+		case "error":
+			return "", errors.New(ev.Message)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("read stream: %w", err)
+	}
+	return "", io.ErrUnexpectedEOF
+}
+
+// Ping 请求 GET {base_url}/parameters 探测后端是否可达，5xx 视为不可用
+func (c *DifyClient) Ping(ctx context.Context) error {
+	s := c.settings.Load()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.endpoint("/parameters"), nil)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+s.dify.APIKey)
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("execute request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// conversation 读取会话对应的 Dify conversation_id，不存在或读取失败时开启新会话
+func (c *DifyClient) conversation(ctx context.Context, req ai.ChatRequest) string {
+	if req.ConversationID == "" {
+		return ""
+	}
+	id, _, err := c.conversations.Get(ctx, difyConversationKey(req.ConversationID))
+	if err != nil {
+		c.logger.WarnContext(ctx, "failed to load dify conversation", "conversation", req.ConversationID, "error", err)
+	}
+	return id
+}
+
+// remember 保存会话对应关系并重置空闲时长
+func (c *DifyClient) remember(ctx context.Context, s *difySettings, req ai.ChatRequest, convID string) {
+	if req.ConversationID == "" || convID == "" {
+		return
+	}
+	if err := c.conversations.Set(ctx, difyConversationKey(req.ConversationID), convID, s.ttl); err != nil {
+		c.logger.WarnContext(ctx, "failed to save dify conversation", "conversation", req.ConversationID, "error", err)
+	}
+}
+
+func difyConversationKey(id string) string {
+	return "dify:conv:" + id
+}
+
+// conversationGone Dify 会话已被删除或过期
+func conversationGone(err error) bool {
+	var se *statusError
+	return errors.As(err, &se) && se.code == http.StatusNotFound
+}
+
+// do 发送 POST /chat-messages，非 200 响应返回 statusError
+func (s *difySettings) do(ctx context.Context, client *http.Client, body []byte) (*http.Response, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint("/chat-messages"), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+s.dify.APIKey)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(httpReq.Header))
+
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("execute request: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, newStatusError(resp, respBody)
+	}
+	return resp, nil
+}
+
+func (s *difySettings) endpoint(path string) string {
+	return strings.TrimSuffix(s.baseURL, "/") + path
+}
+
+// chatRequest 将 ChatRequest 转换为 /chat-messages 请求，历史由 Dify 按 conversation_id 维护
+// 带地址的图片作为远程文件上传，其余附件以文字描述附在正文后
+func (s *difySettings) chatRequest(req ai.ChatRequest, convID string, stream bool) difyChatRequest {
+	inputs := requestVars(s.dify.Inputs, req)
+	if inputs == nil {
+		inputs = map[string]string{}
+	}
+	mode := "blocking"
+	if stream {
+		mode = "streaming"
+	}
+	out := difyChatRequest{
+		Inputs:         inputs,
+		Query:          plainContent(req),
+		ResponseMode:   mode,
+		ConversationID: convID,
+		User:           req.UserID,
+	}
+	if a := req.Attachment; a != nil && a.Type == "image" && a.URL != "" {
+		out.Files = []difyFile{{Type: "image", TransferMethod: "remote_url", URL: a.URL}}
+		if req.Content != "" {
+			out.Query = req.Content
+		}
+	}
+	return out
+}
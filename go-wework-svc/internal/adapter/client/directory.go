@@ -0,0 +1,188 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"go-wework-svc/internal/ai"
+	"go-wework-svc/internal/store"
+	"go-wework-svc/internal/wework"
+	"go-wework-svc/internal/wework/token"
+)
+
+// departmentCacheTTL 成员信息和部门名称的缓存时长，调整部门后最迟在该时长后生效
+const departmentCacheTTL = time.Hour
+
+// Directory 基于 user/get、linkedcorp/user/get、department/get、tag/get API 的 wework.Directory、wework.Contacts、wework.TagDirectory 和 i18n.LanguageSource 实现，查询结果缓存在 kv 中
+type Directory struct {
+	api    *weworkAPI
+	corpID string
+	kv     store.Store
+}
+
+// NewDirectory 创建通讯录查询客户端，应用需有成员的通讯录可见范围
+func NewDirectory(baseURL, corpID string, tokens token.TokenProvider, kv store.Store) *Directory {
+	return &Directory{
+		api: &weworkAPI{
+			baseURL:    baseURL,
+			tokens:     tokens,
+			httpClient: &http.Client{Timeout: weworkAPITimeout},
+		},
+		corpID: corpID,
+		kv:     kv,
+	}
+}
+
+// userResponse user/get 响应体，仅解析所需字段；2022 年后创建的应用不再返回姓名、职务等字段
+type userResponse struct {
+	Name           string  `json:"name"`
+	Department     []int64 `json:"department"`
+	MainDepartment int64   `json:"main_department"`
+	Position       string  `json:"position"`
+	ExtAttr        struct {
+		Attrs []extAttr `json:"attrs"`
+	} `json:"extattr"`
+}
+
+// extAttr 成员的自定义字段，仅解析文本类型的值
+type extAttr struct {
+	Name string `json:"name"`
+	Text struct {
+		Value string `json:"value"`
+	} `json:"text"`
+}
+
+// departmentResponse department/get 响应体
+type departmentResponse struct {
+	Department struct {
+		Name string `json:"name"`
+	} `json:"department"`
+}
+
+// linkedUserResponse linkedcorp/user/get 响应体，部门为 LinkedID/DepartmentID 字符串
+type linkedUserResponse struct {
+	UserInfo struct {
+		Name     string `json:"name"`
+		Position string `json:"position"`
+	} `json:"user_info"`
+}
+
+// Departments 实现 wework.Directory 接口，互联企业成员不属于本企业部门，返回空列表
+func (d *Directory) Departments(ctx context.Context, userID string) ([]int64, error) {
+	if wework.IsLinkedUser(userID) {
+		return nil, nil
+	}
+	u, err := d.user(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return u.Department, nil
+}
+
+// Profile 实现 wework.Contacts 接口，部门名称查询失败时只返回部门 ID
+func (d *Directory) Profile(ctx context.Context, userID string) (*ai.UserProfile, error) {
+	u, err := d.user(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	p := &ai.UserProfile{Name: u.Name, DepartmentIDs: u.Department, Position: u.Position}
+	main := u.MainDepartment
+	if main == 0 && len(u.Department) > 0 {
+		main = u.Department[0]
+	}
+	if main != 0 {
+		p.Department, _ = d.departmentName(ctx, main)
+	}
+	return p, nil
+}
+
+// Attribute 实现 i18n.LanguageSource 接口，返回成员文本类型自定义字段的值，未设置时返回空字符串
+func (d *Directory) Attribute(ctx context.Context, userID, name string) (string, error) {
+	u, err := d.user(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	for _, a := range u.ExtAttr.Attrs {
+		if a.Name == name {
+			return a.Text.Value, nil
+		}
+	}
+	return "", nil
+}
+
+// user 查询成员信息，优先读取缓存
+func (d *Directory) user(ctx context.Context, userID string) (*userResponse, error) {
+	key := "user:" + d.corpID + ":" + userID
+	var u userResponse
+	if v, ok, err := d.kv.Get(ctx, key); err == nil && ok && json.Unmarshal([]byte(v), &u) == nil {
+		return &u, nil
+	}
+	if wework.IsLinkedUser(userID) {
+		var lu linkedUserResponse
+		if err := d.api.postJSON(ctx, "/cgi-bin/linkedcorp/user/get", map[string]string{"userid": userID}, &lu); err != nil {
+			return nil, fmt.Errorf("get linked corp user: %w", err)
+		}
+		u = userResponse{Name: lu.UserInfo.Name, Position: lu.UserInfo.Position}
+	} else if err := d.api.getJSON(ctx, "/cgi-bin/user/get", url.Values{"userid": {userID}}, &u); err != nil {
+		return nil, fmt.Errorf("get user: %w", err)
+	}
+	// 缓存失败不影响本次结果
+	if data, err := json.Marshal(u); err == nil {
+		_ = d.kv.Set(ctx, key, string(data), departmentCacheTTL)
+	}
+	return &u, nil
+}
+
+// departmentName 查询部门名称，优先读取缓存
+func (d *Directory) departmentName(ctx context.Context, id int64) (string, error) {
+	key := "dept-name:" + d.corpID + ":" + strconv.FormatInt(id, 10)
+	if v, ok, err := d.kv.Get(ctx, key); err == nil && ok {
+		return v, nil
+	}
+	var resp departmentResponse
+	if err := d.api.getJSON(ctx, "/cgi-bin/department/get", url.Values{"id": {strconv.FormatInt(id, 10)}}, &resp); err != nil {
+		return "", fmt.Errorf("get department: %w", err)
+	}
+	_ = d.kv.Set(ctx, key, resp.Department.Name, departmentCacheTTL)
+	return resp.Department.Name, nil
+}
+
+// tagResponse tag/get 响应体
+type tagResponse struct {
+	UserList []struct {
+		UserID string `json:"userid"`
+	} `json:"userlist"`
+	PartyList []int64 `json:"partylist"`
+}
+
+// tagMembers 缓存的标签成员
+type tagMembers struct {
+	Users       []string `json:"users"`
+	Departments []int64  `json:"departments"`
+}
+
+// TagMembers 实现 wework.TagDirectory 接口，应用需有标签的可见范围
+func (d *Directory) TagMembers(ctx context.Context, tagID int64) ([]string, []int64, error) {
+	key := "tag:" + d.corpID + ":" + strconv.FormatInt(tagID, 10)
+	var m tagMembers
+	if v, ok, err := d.kv.Get(ctx, key); err == nil && ok && json.Unmarshal([]byte(v), &m) == nil {
+		return m.Users, m.Departments, nil
+	}
+	var resp tagResponse
+	if err := d.api.getJSON(ctx, "/cgi-bin/tag/get", url.Values{"tagid": {strconv.FormatInt(tagID, 10)}}, &resp); err != nil {
+		return nil, nil, fmt.Errorf("get tag: %w", err)
+	}
+	m.Departments = resp.PartyList
+	for _, u := range resp.UserList {
+		m.Users = append(m.Users, u.UserID)
+	}
+	if data, err := json.Marshal(m); err == nil {
+		_ = d.kv.Set(ctx, key, string(data), departmentCacheTTL)
+	}
+	return m.Users, m.Departments, nil
+}
@@ -0,0 +1,71 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"go-wework-svc/internal/shared"
+)
+
+// defaultEmbeddingTimeout 向量接口的默认超时
+const defaultEmbeddingTimeout = 5 * time.Second
+
+// EmbeddingClient OpenAI 兼容 /embeddings 接口的 ai.Embedder 实现
+type EmbeddingClient struct {
+	cfg        shared.EmbeddingConfig
+	httpClient *http.Client
+}
+
+// NewEmbeddingClient 创建向量接口客户端
+func NewEmbeddingClient(cfg shared.EmbeddingConfig) *EmbeddingClient {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultEmbeddingTimeout
+	}
+	return &EmbeddingClient{cfg: cfg, httpClient: &http.Client{Timeout: timeout}}
+}
+
+// Embed 实现 ai.Embedder 接口
+func (c *EmbeddingClient) Embed(ctx context.Context, text string) ([]float32, error) {
+	body, err := json.Marshal(map[string]string{"model": c.cfg.Model, "input": text})
+	if err != nil {
+		return nil, fmt.Errorf("marshal embedding request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(c.cfg.BaseURL, "/")+"/embeddings", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.cfg.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.cfg.APIKey)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("execute request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, newStatusError(resp, respBody)
+	}
+
+	var out struct {
+		Data []struct {
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	if len(out.Data) == 0 || len(out.Data[0].Embedding) == 0 {
+		return nil, errors.New("empty embedding in response")
+	}
+	return out.Data[0].Embedding, nil
+}
@@ -0,0 +1,59 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"go-wework-svc/internal/wework"
+	"go-wework-svc/internal/wework/token"
+)
+
+// ExternalContactClient 基于客户联系 API 的 wework.ExternalContactClient 实现
+type ExternalContactClient struct {
+	api *weworkAPI
+}
+
+// NewExternalContactClient 创建客户联系客户端，tokens 对应的应用需为客户联系的可调用应用
+func NewExternalContactClient(baseURL string, tokens token.TokenProvider) *ExternalContactClient {
+	return &ExternalContactClient{
+		api: &weworkAPI{
+			baseURL:    baseURL,
+			tokens:     tokens,
+			httpClient: &http.Client{Timeout: weworkAPITimeout},
+		},
+	}
+}
+
+// welcomeRequest externalcontact/send_welcome_msg 请求体
+type welcomeRequest struct {
+	WelcomeCode string      `json:"welcome_code"`
+	Text        textContent `json:"text"`
+}
+
+// SendWelcome 实现 wework.ExternalContactClient 接口
+func (c *ExternalContactClient) SendWelcome(ctx context.Context, welcomeCode, content string) error {
+	req := welcomeRequest{WelcomeCode: welcomeCode, Text: textContent{Content: content}}
+	if err := c.api.postJSON(ctx, "/cgi-bin/externalcontact/send_welcome_msg", req, nil); err != nil {
+		return fmt.Errorf("send welcome message: %w", err)
+	}
+	return nil
+}
+
+// externalContactResponse externalcontact/get 响应体，仅解析所需字段
+type externalContactResponse struct {
+	ExternalContact struct {
+		Name     string `json:"name"`
+		CorpName string `json:"corp_name"`
+	} `json:"external_contact"`
+}
+
+// Contact 实现 wework.ExternalContactClient 接口
+func (c *ExternalContactClient) Contact(ctx context.Context, externalUserID string) (*wework.ExternalContact, error) {
+	var resp externalContactResponse
+	if err := c.api.getJSON(ctx, "/cgi-bin/externalcontact/get", url.Values{"external_userid": {externalUserID}}, &resp); err != nil {
+		return nil, fmt.Errorf("get external contact: %w", err)
+	}
+	return &wework.ExternalContact{Name: resp.ExternalContact.Name, CorpName: resp.ExternalContact.CorpName}, nil
+}
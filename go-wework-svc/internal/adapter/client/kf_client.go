@@ -0,0 +1,83 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go-wework-svc/internal/kf"
+	"go-wework-svc/internal/wework"
+	"go-wework-svc/internal/wework/token"
+)
+
+// KFClient 基于 kf/sync_msg 与 kf/send_msg API 的 kf.Client 实现
+type KFClient struct {
+	api *weworkAPI
+}
+
+// NewKFClient 创建微信客服客户端，tokens 需使用具有微信客服权限的 secret
+func NewKFClient(baseURL string, tokens token.TokenProvider) *KFClient {
+	return &KFClient{
+		api: &weworkAPI{
+			baseURL:    baseURL,
+			tokens:     tokens,
+			httpClient: &http.Client{Timeout: weworkAPITimeout},
+		},
+	}
+}
+
+// syncMsgRequest kf/sync_msg 请求体
+type syncMsgRequest struct {
+	Cursor   string `json:"cursor,omitempty"`
+	Token    string `json:"token,omitempty"`
+	Limit    int    `json:"limit,omitempty"`
+	OpenKfID string `json:"open_kfid"`
+}
+
+// syncMsgResponse kf/sync_msg 响应体
+type syncMsgResponse struct {
+	NextCursor string       `json:"next_cursor"`
+	HasMore    int          `json:"has_more"`
+	MsgList    []kf.Message `json:"msg_list"`
+}
+
+// SyncMsg 实现 kf.Client 接口
+func (c *KFClient) SyncMsg(ctx context.Context, req kf.SyncRequest) (*kf.SyncResult, error) {
+	var resp syncMsgResponse
+	err := c.api.postJSON(ctx, "/cgi-bin/kf/sync_msg", syncMsgRequest{
+		Cursor:   req.Cursor,
+		Token:    req.Token,
+		Limit:    req.Limit,
+		OpenKfID: req.OpenKfID,
+	}, &resp)
+	if err != nil {
+		return nil, fmt.Errorf("sync msg: %w", err)
+	}
+	return &kf.SyncResult{
+		NextCursor: resp.NextCursor,
+		HasMore:    resp.HasMore == 1,
+		Messages:   resp.MsgList,
+	}, nil
+}
+
+// kfSendRequest kf/send_msg 请求体
+type kfSendRequest struct {
+	ToUser   string       `json:"touser"`
+	OpenKfID string       `json:"open_kfid"`
+	MsgType  string       `json:"msgtype"`
+	Text     *textContent `json:"text,omitempty"`
+}
+
+// SendText 实现 kf.Client 接口
+func (c *KFClient) SendText(ctx context.Context, openKfID, externalUserID, content string) error {
+	err := c.api.postJSON(ctx, "/cgi-bin/kf/send_msg", kfSendRequest{
+		ToUser:   externalUserID,
+		OpenKfID: openKfID,
+		MsgType:  wework.MsgTypeText,
+		Text:     &textContent{Content: content},
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("send kf message: %w", err)
+	}
+	return nil
+}
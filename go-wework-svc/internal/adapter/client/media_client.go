@@ -0,0 +1,129 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"go-wework-svc/internal/wework"
+	"go-wework-svc/internal/wework/token"
+)
+
+const (
+	// mediaDownloadTimeout 单次下载超时，视频和文件可能较大
+	mediaDownloadTimeout = 60 * time.Second
+	// DefaultMediaMaxBytes 默认下载上限，与企业微信文件素材上限一致
+	DefaultMediaMaxBytes = 20 << 20
+)
+
+// MediaClient 基于 media/get API 的 wework.MediaDownloader 实现
+type MediaClient struct {
+	baseURL    string
+	tokens     token.TokenProvider
+	httpClient *http.Client
+	maxBytes   int64
+	tempDir    string
+}
+
+// NewMediaClient 创建素材下载客户端，maxBytes <= 0 时使用默认上限，tempDir 为空时使用系统临时目录
+func NewMediaClient(baseURL string, tokens token.TokenProvider, maxBytes int64, tempDir string) *MediaClient {
+	if maxBytes <= 0 {
+		maxBytes = DefaultMediaMaxBytes
+	}
+	return &MediaClient{
+		baseURL:    baseURL,
+		tokens:     tokens,
+		httpClient: &http.Client{Timeout: mediaDownloadTimeout},
+		maxBytes:   maxBytes,
+		tempDir:    tempDir,
+	}
+}
+
+// Download 实现 wework.MediaDownloader 接口
+func (c *MediaClient) Download(ctx context.Context, mediaID string) (*wework.Media, error) {
+	var media *wework.Media
+	err := token.Do(ctx, c.tokens, func(tok string) error {
+		var err error
+		media, err = c.download(ctx, tok, mediaID)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("download media: %w", err)
+	}
+	return media, nil
+}
+
+// download 执行单次下载，出错时响应体为 JSON 格式的 errcode
+func (c *MediaClient) download(ctx context.Context, tok, mediaID string) (*wework.Media, error) {
+	q := url.Values{"access_token": {tok}, "media_id": {mediaID}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/cgi-bin/media/get?"+q.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if strings.HasPrefix(contentType, "application/json") || strings.HasPrefix(contentType, "text/plain") {
+		var result apiResult
+		if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&result); err != nil {
+			return nil, fmt.Errorf("decode response: %w", err)
+		}
+		return nil, &wework.APIError{Code: result.ErrCode, Msg: result.ErrMsg}
+	}
+	if resp.ContentLength > c.maxBytes {
+		return nil, fmt.Errorf("%w: %d bytes exceeds %d", wework.ErrMediaTooLarge, resp.ContentLength, c.maxBytes)
+	}
+
+	f, err := os.CreateTemp(c.tempDir, "wework-media-*")
+	if err != nil {
+		return nil, fmt.Errorf("create temp file: %w", err)
+	}
+	media := &wework.Media{
+		MediaID:     mediaID,
+		FileName:    fileName(resp.Header.Get("Content-Disposition")),
+		ContentType: contentType,
+		Path:        f.Name(),
+	}
+	n, err := io.Copy(f, io.LimitReader(resp.Body, c.maxBytes+1))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	switch {
+	case err != nil:
+		err = fmt.Errorf("write temp file: %w", err)
+	case n > c.maxBytes:
+		err = fmt.Errorf("%w: exceeds %d bytes", wework.ErrMediaTooLarge, c.maxBytes)
+	}
+	if err != nil {
+		return nil, errors.Join(err, media.Remove())
+	}
+	media.Size = n
+	return media, nil
+}
+
+// fileName 解析 Content-Disposition 中的文件名，如 attachment; filename="a.pdf"
+func fileName(disposition string) string {
+	if disposition == "" {
+		return ""
+	}
+	_, params, err := mime.ParseMediaType(disposition)
+	if err != nil {
+		return ""
+	}
+	return params["filename"]
+}
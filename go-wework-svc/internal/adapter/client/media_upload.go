@@ -0,0 +1,188 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"go-wework-svc/internal/store"
+	"go-wework-svc/internal/wework"
+	"go-wework-svc/internal/wework/token"
+)
+
+const (
+	// mediaUploadTimeout 单次上传超时
+	mediaUploadTimeout = 60 * time.Second
+	// mediaIDCacheTTL 临时素材 media_id 3 天内有效，提前半天过期
+	mediaIDCacheTTL = 60 * time.Hour
+)
+
+// mediaUploadLimits 企业微信临时素材的大小上限
+var mediaUploadLimits = map[string]int64{
+	wework.MsgTypeImage: 10 << 20,
+	wework.MsgTypeVoice: 2 << 20,
+	wework.MsgTypeFile:  20 << 20,
+}
+
+// MediaUploader 基于 media/upload API 的 wework.MediaUploader 实现，相同内容的 media_id 缓存在 kv 中
+type MediaUploader struct {
+	api          *weworkAPI
+	httpClient   *http.Client // 下载回复中引用的素材
+	corpID       string
+	kv           store.Store
+	allowedHosts []string
+}
+
+// NewMediaUploader 创建临时素材上传客户端，UploadURL 只下载 allowedHosts 中域名的地址
+func NewMediaUploader(baseURL, corpID string, tokens token.TokenProvider, kv store.Store, allowedHosts []string) *MediaUploader {
+	u := &MediaUploader{
+		api: &weworkAPI{
+			baseURL:    baseURL,
+			tokens:     tokens,
+			httpClient: &http.Client{Timeout: mediaUploadTimeout},
+		},
+		corpID:       corpID,
+		kv:           kv,
+		allowedHosts: allowedHosts,
+	}
+	// 重定向同样只允许跳转到白名单域名
+	u.httpClient = &http.Client{
+		Timeout: mediaDownloadTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if !slices.Contains(u.allowedHosts, req.URL.Hostname()) {
+				return fmt.Errorf("media host %q is not allowed", req.URL.Hostname())
+			}
+			if len(via) >= 5 {
+				return errors.New("too many redirects")
+			}
+			return nil
+		},
+	}
+	return u
+}
+
+// uploadResponse media/upload 响应体
+type uploadResponse struct {
+	Type    string `json:"type"`
+	MediaID string `json:"media_id"`
+}
+
+// Upload 实现 wework.MediaUploader 接口
+func (u *MediaUploader) Upload(ctx context.Context, mediaType, fileName string, data []byte) (string, error) {
+	limit, ok := mediaUploadLimits[mediaType]
+	if !ok {
+		return "", fmt.Errorf("unsupported media type %q", mediaType)
+	}
+	if int64(len(data)) > limit {
+		return "", fmt.Errorf("%w: %d bytes exceeds %d", wework.ErrMediaTooLarge, len(data), limit)
+	}
+
+	sum := sha256.Sum256(data)
+	key := "media-upload:" + u.corpID + ":" + mediaType + ":" + hex.EncodeToString(sum[:])
+	if id, ok, err := u.kv.Get(ctx, key); err == nil && ok {
+		return id, nil
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", mime.FormatMediaType("form-data", map[string]string{"name": "media", "filename": fileName}))
+	h.Set("Content-Type", http.DetectContentType(data))
+	part, err := mw.CreatePart(h)
+	if err != nil {
+		return "", fmt.Errorf("create multipart: %w", err)
+	}
+	if _, err := part.Write(data); err != nil {
+		return "", fmt.Errorf("write multipart: %w", err)
+	}
+	if err := mw.Close(); err != nil {
+		return "", fmt.Errorf("close multipart: %w", err)
+	}
+
+	var resp uploadResponse
+	err = u.api.postBody(ctx, "/cgi-bin/media/upload", url.Values{"type": {mediaType}}, mw.FormDataContentType(), body.Bytes(), &resp)
+	if err != nil {
+		return "", fmt.Errorf("upload media: %w", err)
+	}
+	// 缓存失败不影响本次结果
+	_ = u.kv.Set(ctx, key, resp.MediaID, mediaIDCacheTTL)
+	return resp.MediaID, nil
+}
+
+// UploadURL 实现 wework.MediaUploader 接口，支持 http(s) 地址和 base64 data URL
+func (u *MediaUploader) UploadURL(ctx context.Context, mediaType, rawURL string) (string, error) {
+	data, name, err := u.fetch(ctx, mediaType, rawURL)
+	if err != nil {
+		return "", err
+	}
+	return u.Upload(ctx, mediaType, name, data)
+}
+
+// fetch 读取素材内容，返回内容和文件名
+func (u *MediaUploader) fetch(ctx context.Context, mediaType, rawURL string) ([]byte, string, error) {
+	limit := mediaUploadLimits[mediaType]
+	if rest, ok := strings.CutPrefix(rawURL, "data:"); ok {
+		meta, payload, ok := strings.Cut(rest, ",")
+		if !ok || !strings.HasSuffix(meta, ";base64") {
+			return nil, "", errors.New("unsupported data url")
+		}
+		data, err := base64.StdEncoding.DecodeString(payload)
+		if err != nil {
+			return nil, "", fmt.Errorf("decode data url: %w", err)
+		}
+		name := mediaType
+		if exts, _ := mime.ExtensionsByType(strings.TrimSuffix(meta, ";base64")); len(exts) > 0 {
+			name += exts[0]
+		}
+		return data, name, nil
+	}
+
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return nil, "", fmt.Errorf("invalid media url %q", rawURL)
+	}
+	if !slices.Contains(u.allowedHosts, parsed.Hostname()) {
+		return nil, "", fmt.Errorf("media host %q is not allowed", parsed.Hostname())
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("create request: %w", err)
+	}
+	resp, err := u.httpClient.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("fetch media: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("fetch media: unexpected status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("read media: %w", err)
+	}
+	if int64(len(data)) > limit {
+		return nil, "", fmt.Errorf("%w: exceeds %d bytes", wework.ErrMediaTooLarge, limit)
+	}
+	name := fileName(resp.Header.Get("Content-Disposition"))
+	if name == "" {
+		name = path.Base(parsed.Path)
+	}
+	if name == "." || name == "/" {
+		name = mediaType
+	}
+	return data, name, nil
+}
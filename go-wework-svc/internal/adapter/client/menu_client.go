@@ -0,0 +1,44 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"go-wework-svc/internal/wework"
+	"go-wework-svc/internal/wework/token"
+)
+
+// MenuClient 基于 menu/create API 的 wework.MenuManager 实现
+type MenuClient struct {
+	api     *weworkAPI
+	agentID int64
+}
+
+// NewMenuClient 创建应用菜单客户端
+func NewMenuClient(baseURL string, agentID int64, tokens token.TokenProvider) *MenuClient {
+	return &MenuClient{
+		api: &weworkAPI{
+			baseURL:    baseURL,
+			tokens:     tokens,
+			httpClient: &http.Client{Timeout: weworkAPITimeout},
+		},
+		agentID: agentID,
+	}
+}
+
+// SetMenu 实现 wework.MenuManager 接口
+func (c *MenuClient) SetMenu(ctx context.Context, menu wework.Menu) error {
+	body, err := json.Marshal(menu)
+	if err != nil {
+		return fmt.Errorf("marshal menu: %w", err)
+	}
+	query := url.Values{"agentid": {strconv.FormatInt(c.agentID, 10)}}
+	if err := c.api.postBody(ctx, "/cgi-bin/menu/create", query, "application/json", body, nil); err != nil {
+		return fmt.Errorf("create menu: %w", err)
+	}
+	return nil
+}
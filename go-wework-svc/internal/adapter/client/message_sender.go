@@ -0,0 +1,209 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"go-wework-svc/internal/wework"
+	"go-wework-svc/internal/wework/token"
+)
+
+const weworkAPITimeout = 5 * time.Second
+
+// MessageSender 基于 message/send API 的 wework.Sender 实现，接收人包含互联企业成员时改用 linkedcorp/message/send
+type MessageSender struct {
+	api     *weworkAPI
+	agentID int64
+	logger  *slog.Logger
+}
+
+// NewMessageSender 创建应用消息发送客户端
+func NewMessageSender(baseURL string, agentID int64, tokens token.TokenProvider, logger *slog.Logger) *MessageSender {
+	return &MessageSender{
+		api: &weworkAPI{
+			baseURL:    baseURL,
+			tokens:     tokens,
+			httpClient: &http.Client{Timeout: weworkAPITimeout},
+		},
+		agentID: agentID,
+		logger:  logger,
+	}
+}
+
+// sendRequest message/send 请求体
+type sendRequest struct {
+	ToUser  string `json:"touser,omitempty"`
+	ToParty string `json:"toparty,omitempty"`
+	ToTag   string `json:"totag,omitempty"`
+	sendBody
+}
+
+// linkedSendRequest linkedcorp/message/send 请求体，接收人为列表，互联企业成员格式为 CorpID/UserID
+type linkedSendRequest struct {
+	ToUser  []string `json:"touser,omitempty"`
+	ToParty []string `json:"toparty,omitempty"`
+	ToTag   []string `json:"totag,omitempty"`
+	sendBody
+}
+
+// sendBody 两种发送接口共用的消息内容
+type sendBody struct {
+	MsgType  string       `json:"msgtype"`
+	AgentID  int64        `json:"agentid"`
+	Text     *textContent `json:"text,omitempty"`
+	Markdown *textContent `json:"markdown,omitempty"`
+	TextCard *textCard    `json:"textcard,omitempty"`
+
+	TemplateCard *wework.TemplateCard `json:"template_card,omitempty"`
+
+	Image *mediaContent `json:"image,omitempty"`
+	Voice *mediaContent `json:"voice,omitempty"`
+	File  *mediaContent `json:"file,omitempty"`
+}
+
+type mediaContent struct {
+	MediaID string `json:"media_id"`
+}
+
+type textContent struct {
+	Content string `json:"content"`
+}
+
+type textCard struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	URL         string `json:"url"`
+	BtnTxt      string `json:"btntxt,omitempty"`
+}
+
+// sendResponse message/send 响应体
+type sendResponse struct {
+	InvalidUser  string `json:"invaliduser"`
+	InvalidParty string `json:"invalidparty"`
+	InvalidTag   string `json:"invalidtag"`
+	MsgID        string `json:"msgid"`
+}
+
+// linkedSendResponse linkedcorp/message/send 响应体
+type linkedSendResponse struct {
+	InvalidUser  []string `json:"invaliduser"`
+	InvalidParty []string `json:"invalidparty"`
+	InvalidTag   []string `json:"invalidtag"`
+}
+
+// Send 实现 wework.Sender 接口
+func (s *MessageSender) Send(ctx context.Context, msg wework.OutgoingMessage) error {
+	req := sendBody{MsgType: msg.MsgType, AgentID: s.agentID}
+	switch msg.MsgType {
+	case wework.MsgTypeText:
+		req.Text = &textContent{Content: msg.Content}
+	case wework.MsgTypeMarkdown:
+		req.Markdown = &textContent{Content: msg.Content}
+	case wework.MsgTypeTextCard:
+		req.TextCard = &textCard{
+			Title:       msg.Title,
+			Description: msg.Description,
+			URL:         msg.URL,
+			BtnTxt:      msg.BtnTxt,
+		}
+	case wework.MsgTypeTemplateCard:
+		if msg.Card == nil {
+			return errors.New("template_card message without card")
+		}
+		req.TemplateCard = msg.Card
+	case wework.MsgTypeImage:
+		req.Image = &mediaContent{MediaID: msg.MediaID}
+	case wework.MsgTypeVoice:
+		req.Voice = &mediaContent{MediaID: msg.MediaID}
+	case wework.MsgTypeFile:
+		req.File = &mediaContent{MediaID: msg.MediaID}
+	default:
+		return fmt.Errorf("unsupported msg type %q", msg.MsgType)
+	}
+	if msg.Linked() {
+		return s.sendLinked(ctx, msg, req)
+	}
+
+	var resp sendResponse
+	if err := s.api.postJSON(ctx, "/cgi-bin/message/send", sendRequest{
+		ToUser:   msg.ToUser,
+		ToParty:  msg.ToParty,
+		ToTag:    msg.ToTag,
+		sendBody: req,
+	}, &resp); err != nil {
+		return fmt.Errorf("send message: %w", err)
+	}
+	if resp.InvalidUser != "" || resp.InvalidParty != "" || resp.InvalidTag != "" {
+		s.logger.WarnContext(ctx, "message partially delivered",
+			"sent_msg_id", resp.MsgID,
+			"invalid_user", resp.InvalidUser,
+			"invalid_party", resp.InvalidParty,
+			"invalid_tag", resp.InvalidTag,
+		)
+	}
+	return nil
+}
+
+// sendLinked 通过 linkedcorp/message/send 发送，本企业成员可与互联企业成员一起发送，不支持模板卡片
+func (s *MessageSender) sendLinked(ctx context.Context, msg wework.OutgoingMessage, body sendBody) error {
+	if msg.MsgType == wework.MsgTypeTemplateCard {
+		return errors.New("template_card is not supported for linked corp recipients")
+	}
+	req := linkedSendRequest{
+		ToUser:   splitRecipients(msg.ToUser),
+		ToParty:  splitRecipients(msg.ToParty),
+		ToTag:    splitRecipients(msg.ToTag),
+		sendBody: body,
+	}
+	var resp linkedSendResponse
+	if err := s.api.postJSON(ctx, "/cgi-bin/linkedcorp/message/send", req, &resp); err != nil {
+		return fmt.Errorf("send linked corp message: %w", err)
+	}
+	if len(resp.InvalidUser) > 0 || len(resp.InvalidParty) > 0 || len(resp.InvalidTag) > 0 {
+		s.logger.WarnContext(ctx, "linked corp message partially delivered",
+			"invalid_user", resp.InvalidUser,
+			"invalid_party", resp.InvalidParty,
+			"invalid_tag", resp.InvalidTag,
+		)
+	}
+	return nil
+}
+
+// splitRecipients 将 | 分隔的接收人转换为列表
+func splitRecipients(v string) []string {
+	if v == "" {
+		return nil
+	}
+	return strings.Split(v, "|")
+}
+
+// updateCardRequest message/update_template_card 请求体，只更新按钮状态
+type updateCardRequest struct {
+	UserIDs      []string     `json:"userids"`
+	AgentID      int64        `json:"agentid"`
+	ResponseCode string       `json:"response_code"`
+	Button       updateButton `json:"button"`
+}
+
+type updateButton struct {
+	ReplaceName string `json:"replace_name"`
+}
+
+// UpdateTemplateCardButton 实现 wework.CardUpdater 接口
+func (s *MessageSender) UpdateTemplateCardButton(ctx context.Context, userID, responseCode, replaceName string) error {
+	req := updateCardRequest{
+		UserIDs:      []string{userID},
+		AgentID:      s.agentID,
+		ResponseCode: responseCode,
+		Button:       updateButton{ReplaceName: replaceName},
+	}
+	if err := s.api.postJSON(ctx, "/cgi-bin/message/update_template_card", req, nil); err != nil {
+		return fmt.Errorf("update template card: %w", err)
+	}
+	return nil
+}
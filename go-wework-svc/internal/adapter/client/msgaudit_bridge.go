@@ -0,0 +1,95 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"go-wework-svc/internal/archive"
+)
+
+const msgAuditBridgeTimeout = 30 * time.Second
+
+// MsgAuditBridge 基于 HTTP sidecar 的 archive.ChatSource 实现
+// 会话存档官方 SDK 只提供 C 库，由 sidecar 持有会话存档 secret 并封装为两个接口：
+//
+//	POST {base}/chatdata {"seq": N, "limit": L} → GetChatData 的原始 JSON（errcode、chatdata）
+//	POST {base}/decrypt  {"encrypt_key": "...", "encrypt_msg": "..."} → DecryptData 的明文 JSON
+//
+// RSA 私钥只保存在本服务中，sidecar 收到的是已解密的 random key
+type MsgAuditBridge struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// NewMsgAuditBridge 创建会话存档 sidecar 客户端，token 非空时以 Authorization: Bearer 发送
+func NewMsgAuditBridge(baseURL, token string) *MsgAuditBridge {
+	return &MsgAuditBridge{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		token:      token,
+		httpClient: &http.Client{Timeout: msgAuditBridgeTimeout},
+	}
+}
+
+// chatDataResponse GetChatData 响应
+type chatDataResponse struct {
+	ErrCode  int                `json:"errcode"`
+	ErrMsg   string             `json:"errmsg"`
+	ChatData []archive.ChatData `json:"chatdata"`
+}
+
+// ChatData 实现 archive.ChatSource 接口
+func (b *MsgAuditBridge) ChatData(ctx context.Context, seq uint64, limit int) ([]archive.ChatData, error) {
+	data, err := b.post(ctx, "/chatdata", map[string]any{"seq": seq, "limit": limit})
+	if err != nil {
+		return nil, err
+	}
+	var resp chatDataResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	if resp.ErrCode != 0 {
+		return nil, fmt.Errorf("get chat data error %d: %s", resp.ErrCode, resp.ErrMsg)
+	}
+	return resp.ChatData, nil
+}
+
+// Decrypt 实现 archive.ChatSource 接口
+func (b *MsgAuditBridge) Decrypt(ctx context.Context, randomKey, encryptChatMsg string) ([]byte, error) {
+	return b.post(ctx, "/decrypt", map[string]string{"encrypt_key": randomKey, "encrypt_msg": encryptChatMsg})
+}
+
+func (b *MsgAuditBridge) post(ctx context.Context, path string, in any) ([]byte, error) {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if b.token != "" {
+		req.Header.Set("Authorization", "Bearer "+b.token)
+	}
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(data))
+	}
+	return data, nil
+}
@@ -0,0 +1,41 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"go-wework-svc/internal/wework/token"
+)
+
+// OAuthClient 基于 auth/getuserinfo API 的 auth.UserResolver 实现
+type OAuthClient struct {
+	api *weworkAPI
+}
+
+// NewOAuthClient 创建网页授权客户端，使用发起授权的应用的 access_token
+func NewOAuthClient(baseURL string, tokens token.TokenProvider) *OAuthClient {
+	return &OAuthClient{
+		api: &weworkAPI{
+			baseURL:    baseURL,
+			tokens:     tokens,
+			httpClient: &http.Client{Timeout: weworkAPITimeout},
+		},
+	}
+}
+
+// userInfoResponse auth/getuserinfo 响应，企业成员返回 userid，非企业成员只返回 openid
+type userInfoResponse struct {
+	UserID string `json:"userid"`
+	OpenID string `json:"openid"`
+}
+
+// UserID 实现 auth.UserResolver 接口，code 只能使用一次，5 分钟内有效
+func (c *OAuthClient) UserID(ctx context.Context, code string) (string, error) {
+	var resp userInfoResponse
+	if err := c.api.getJSON(ctx, "/cgi-bin/auth/getuserinfo", url.Values{"code": {code}}, &resp); err != nil {
+		return "", fmt.Errorf("get user info: %w", err)
+	}
+	return resp.UserID, nil
+}
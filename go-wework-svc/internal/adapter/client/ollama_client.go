@@ -0,0 +1,284 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"go-wework-svc/internal/ai"
+	"go-wework-svc/internal/shared"
+)
+
+// OllamaClient Ollama /api/chat 协议客户端，用于流量不出内网的本地模型部署
+type OllamaClient struct {
+	settings atomic.Pointer[ollamaSettings]
+	logger   *slog.Logger
+}
+
+// ollamaSettings OllamaClient 的连接与模型设置
+type ollamaSettings struct {
+	*clientSettings
+	ollama shared.OllamaConfig
+}
+
+// NewOllamaClient 创建 Ollama 客户端
+func NewOllamaClient(cfg shared.AIConfig, logger *slog.Logger) *OllamaClient {
+	c := &OllamaClient{logger: logger}
+	c.Update(cfg)
+	return c
+}
+
+// Update 替换后端地址、超时、重试策略和模型参数，进行中的请求沿用旧设置
+func (c *OllamaClient) Update(cfg shared.AIConfig) {
+	c.settings.Store(&ollamaSettings{clientSettings: newClientSettings(cfg), ollama: cfg.Ollama})
+}
+
+// ollamaMessage /api/chat 消息
+type ollamaMessage struct {
+	Role    string   `json:"role"`
+	Content string   `json:"content"`
+	Images  []string `json:"images,omitempty"` // base64 图片，供视觉模型使用
+}
+
+// ollamaOptions 模型参数，零值字段不下发以使用模型默认值
+type ollamaOptions struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	NumPredict  int      `json:"num_predict,omitempty"`
+}
+
+// ollamaChatRequest POST /api/chat 请求体
+type ollamaChatRequest struct {
+	Model     string          `json:"model"`
+	Messages  []ollamaMessage `json:"messages"`
+	Stream    bool            `json:"stream"`
+	Options   *ollamaOptions  `json:"options,omitempty"`
+	KeepAlive string          `json:"keep_alive,omitempty"`
+}
+
+// ollamaChatResponse 非流式响应，流式时每行一个，最后一行 done 为 true
+type ollamaChatResponse struct {
+	Message ollamaMessage `json:"message"`
+	Done    bool          `json:"done"`
+	Error   string        `json:"error"`
+}
+
+// SendMessage 实现 ai.Service 接口
+func (c *OllamaClient) SendMessage(ctx context.Context, req ai.ChatRequest) (*ai.ChatResponse, error) {
+	s := c.settings.Load()
+	ctx, span := tracer.Start(ctx, "ai.SendMessage",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("ai.base_url", s.baseURL),
+			attribute.String("ai.model", s.ollama.Model),
+		),
+	)
+	defer span.End()
+
+	body, err := json.Marshal(s.chatRequest(req, false))
+	if err != nil {
+		return nil, fmt.Errorf("marshal chat request: %w", err)
+	}
+
+	var lastErr error
+	attempts := 0
+	for i := range s.retry + 1 {
+		attempts++
+		reply, err := c.chat(ctx, s, body)
+		if err == nil {
+			span.SetAttributes(attribute.Int("ai.attempts", attempts))
+			return &ai.ChatResponse{Reply: reply}, nil
+		}
+		lastErr = err
+		if i == s.retry || !waitRetry(ctx, c.logger, s.clientSettings, err, i) {
+			break
+		}
+	}
+
+	span.SetAttributes(attribute.Int("ai.attempts", attempts))
+	span.RecordError(lastErr)
+	span.SetStatus(codes.Error, "ai request failed")
+	c.logger.ErrorContext(ctx, "AI request failed", "user_id", req.UserID, "attempts", attempts, "error", lastErr)
+	return nil, fmt.Errorf("send message after %d attempts: %w", attempts, lastErr)
+}
+
+// chat 执行单次非流式请求
+func (c *OllamaClient) chat(ctx context.Context, s *ollamaSettings, body []byte) (string, error) {
+	resp, err := s.do(ctx, s.httpClient, body)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var out ollamaChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", fmt.Errorf("decode response: %w", err)
+	}
+	if out.Error != "" {
+		return "", errors.New(out.Error)
+	}
+	return out.Message.Content, nil
+}
+
+// SendMessageStream 实现 ai.StreamingService 接口，以 stream=true 请求并逐行解析 NDJSON 增量
+// 尚未收到任何增量时可重试的失败会按重试策略重试
+func (c *OllamaClient) SendMessageStream(ctx context.Context, req ai.ChatRequest, onDelta func(string)) (*ai.ChatResponse, error) {
+	s := c.settings.Load()
+	ctx, span := tracer.Start(ctx, "ai.SendMessageStream",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("ai.base_url", s.baseURL),
+			attribute.String("ai.model", s.ollama.Model),
+		),
+	)
+	defer span.End()
+
+	body, err := json.Marshal(s.chatRequest(req, true))
+	if err != nil {
+		return nil, fmt.Errorf("marshal chat request: %w", err)
+	}
+
+	var lastErr error
+	for i := range s.retry + 1 {
+		var reply strings.Builder
+		received := false
+		err := c.stream(ctx, s, body, func(delta string) {
+			received = true
+			reply.WriteString(delta)
+			onDelta(delta)
+		})
+		if err == nil {
+			span.SetAttributes(attribute.Int("ai.attempts", i+1))
+			return &ai.ChatResponse{Reply: reply.String()}, nil
+		}
+		lastErr = err
+		if received || i == s.retry || !waitRetry(ctx, c.logger, s.clientSettings, err, i) {
+			break
+		}
+	}
+
+	span.RecordError(lastErr)
+	span.SetStatus(codes.Error, "ai stream failed")
+	c.logger.ErrorContext(ctx, "AI stream request failed", "user_id", req.UserID, "error", lastErr)
+	return nil, fmt.Errorf("stream message: %w", lastErr)
+}
+
+// stream 执行单次流式请求，未收到 done 即断开视为失败
+func (c *OllamaClient) stream(ctx context.Context, s *ollamaSettings, body []byte, onDelta func(string)) error {
+	resp, err := s.do(ctx, s.streamClient, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxStreamLine)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var chunk ollamaChatResponse
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return fmt.Errorf("decode stream chunk: %w", err)
+		}
+		if chunk.Error != "" {
+			return errors.New(chunk.Error)
+		}
+		if chunk.Message.Content != "" {
+			onDelta(chunk.Message.Content)
+		}
+		if chunk.Done {
+			return nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read stream: %w", err)
+	}
+	return io.ErrUnexpectedEOF
+}
+
+// Ping 请求 GET {base_url}/api/tags 探测后端是否可达，5xx 视为不可用
+func (c *OllamaClient) Ping(ctx context.Context) error {
+	s := c.settings.Load()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.endpoint("/api/tags"), nil)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("execute request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// do 发送 POST /api/chat，非 200 响应返回 statusError
+func (s *ollamaSettings) do(ctx context.Context, client *http.Client, body []byte) (*http.Response, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint("/api/chat"), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(httpReq.Header))
+
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("execute request: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, newStatusError(resp, respBody)
+	}
+	return resp, nil
+}
+
+func (s *ollamaSettings) endpoint(path string) string {
+	return strings.TrimSuffix(s.baseURL, "/") + path
+}
+
+// chatRequest 将 ChatRequest 转换为 /api/chat 请求：系统提示 → 历史对话 → 本次消息
+// 已下载内容的图片通过 images 内联发送，其余附件以文字描述附在正文后
+func (s *ollamaSettings) chatRequest(req ai.ChatRequest, stream bool) ollamaChatRequest {
+	messages := make([]ollamaMessage, 0, len(req.History)+2)
+	if prompt := systemPrompt(s.ollama.SystemPrompt, req); prompt != "" {
+		messages = append(messages, ollamaMessage{Role: "system", Content: prompt})
+	}
+	for _, t := range req.History {
+		messages = append(messages, ollamaMessage{Role: t.Role, Content: t.Content})
+	}
+	user := ollamaMessage{Role: ai.RoleUser, Content: plainContent(req)}
+	if a := req.Attachment; a != nil && a.Type == "image" && len(a.Data) > 0 {
+		user = ollamaMessage{Role: ai.RoleUser, Content: req.Content, Images: []string{base64.StdEncoding.EncodeToString(a.Data)}}
+	}
+	messages = append(messages, user)
+
+	out := ollamaChatRequest{
+		Model:     s.ollama.Model,
+		Messages:  messages,
+		Stream:    stream,
+		KeepAlive: s.ollama.KeepAlive,
+	}
+	if s.ollama.Temperature != nil || s.ollama.NumPredict > 0 {
+		out.Options = &ollamaOptions{Temperature: s.ollama.Temperature, NumPredict: s.ollama.NumPredict}
+	}
+	return out
+}
@@ -0,0 +1,488 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"go-wework-svc/internal/ai"
+	"go-wework-svc/internal/shared"
+)
+
+// OpenAIClient OpenAI Chat Completions 协议客户端，兼容 Azure OpenAI、FastGPT 和其他兼容网关
+type OpenAIClient struct {
+	settings atomic.Pointer[openAISettings]
+	// fastgpt 使用 ai.fastgpt 配置，并以会话标识作为 chatId
+	fastgpt bool
+	logger  *slog.Logger
+}
+
+// openAISettings OpenAIClient 的连接与模型设置
+type openAISettings struct {
+	*clientSettings
+	openai shared.OpenAIConfig
+	// fastgpt FastGPT 应用配置，其他后端为 nil
+	fastgpt *shared.FastGPTConfig
+}
+
+// NewOpenAIClient 创建 OpenAI 兼容客户端
+func NewOpenAIClient(cfg shared.AIConfig, logger *slog.Logger) *OpenAIClient {
+	c := &OpenAIClient{logger: logger}
+	c.Update(cfg)
+	return c
+}
+
+// NewFastGPTClient 创建 FastGPT 应用客户端，会话上下文由 FastGPT 按 chatId 保存
+func NewFastGPTClient(cfg shared.AIConfig, logger *slog.Logger) *OpenAIClient {
+	c := &OpenAIClient{fastgpt: true, logger: logger}
+	c.Update(cfg)
+	return c
+}
+
+// Update 替换后端地址、超时、重试策略和模型参数，进行中的请求沿用旧设置
+func (c *OpenAIClient) Update(cfg shared.AIConfig) {
+	s := &openAISettings{clientSettings: newClientSettings(cfg), openai: cfg.OpenAI}
+	if c.fastgpt {
+		s.openai = shared.OpenAIConfig{APIKey: cfg.FastGPT.APIKey}
+		s.fastgpt = &cfg.FastGPT
+	}
+	c.settings.Store(s)
+}
+
+// chatMessage Chat Completions 消息，Content 为字符串或多段内容
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content any    `json:"content"`
+	// ToolCalls 模型发起的工具调用，ToolCallID 为 tool 消息对应的调用
+	ToolCalls  []toolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+}
+
+// toolSpec 请求中声明的工具
+type toolSpec struct {
+	Type     string       `json:"type"` // function
+	Function toolFunction `json:"function"`
+}
+
+type toolFunction struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+
+// toolCall 响应中的工具调用，Arguments 为 JSON 字符串
+type toolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// contentPart 多段消息内容
+type contentPart struct {
+	Type     string    `json:"type"` // text | image_url
+	Text     string    `json:"text,omitempty"`
+	ImageURL *imageURL `json:"image_url,omitempty"`
+}
+
+type imageURL struct {
+	URL string `json:"url"`
+}
+
+// completionRequest POST /chat/completions 请求体
+type completionRequest struct {
+	Model       string        `json:"model,omitempty"`
+	Messages    []chatMessage `json:"messages"`
+	Temperature *float64      `json:"temperature,omitempty"`
+	MaxTokens   int           `json:"max_tokens,omitempty"`
+	User        string        `json:"user,omitempty"`
+	Stream      bool          `json:"stream,omitempty"`
+	Tools       []toolSpec    `json:"tools,omitempty"`
+
+	// FastGPT 扩展字段
+	ChatID    string            `json:"chatId,omitempty"`
+	Variables map[string]string `json:"variables,omitempty"`
+}
+
+// completionResponse 非流式响应
+type completionResponse struct {
+	Choices []struct {
+		Message completionMessage `json:"message"`
+	} `json:"choices"`
+}
+
+// completionMessage 非流式响应中的消息
+type completionMessage struct {
+	Content   string     `json:"content"`
+	ToolCalls []toolCall `json:"tool_calls"`
+}
+
+// completionChunk 流式响应的单个数据块
+type completionChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+}
+
+// SendMessage 实现 ai.Service 接口
+func (c *OpenAIClient) SendMessage(ctx context.Context, req ai.ChatRequest) (*ai.ChatResponse, error) {
+	s := c.settings.Load()
+	ctx, span := tracer.Start(ctx, "ai.SendMessage",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("ai.base_url", s.baseURL),
+			attribute.String("ai.model", s.openai.Model),
+		),
+	)
+	defer span.End()
+
+	creq := s.completionRequest(req, false)
+	for round := 0; ; round++ {
+		body, err := json.Marshal(creq)
+		if err != nil {
+			return nil, fmt.Errorf("marshal completion request: %w", err)
+		}
+
+		var (
+			msg      *completionMessage
+			lastErr  error
+			attempts int
+		)
+		for i := range s.retry + 1 {
+			attempts++
+			msg, lastErr = c.complete(ctx, s, body)
+			if lastErr == nil || i == s.retry || !waitRetry(ctx, c.logger, s.clientSettings, lastErr, i) {
+				break
+			}
+		}
+		span.SetAttributes(attribute.Int("ai.attempts", attempts))
+		if lastErr != nil {
+			span.RecordError(lastErr)
+			span.SetStatus(codes.Error, "ai request failed")
+			c.logger.ErrorContext(ctx, "AI request failed", "user_id", req.UserID, "attempts", attempts, "error", lastErr)
+			return nil, fmt.Errorf("send message after %d attempts: %w", attempts, lastErr)
+		}
+
+		if len(msg.ToolCalls) == 0 || round == ai.MaxToolRounds {
+			span.SetAttributes(attribute.Int("ai.tool_rounds", round))
+			return &ai.ChatResponse{Reply: msg.Content}, nil
+		}
+		creq.Messages = append(creq.Messages, chatMessage{Role: ai.RoleAssistant, Content: msg.Content, ToolCalls: msg.ToolCalls})
+		for _, tc := range msg.ToolCalls {
+			creq.Messages = append(creq.Messages, chatMessage{Role: "tool", Content: c.callTool(ctx, req, tc), ToolCallID: tc.ID})
+		}
+	}
+}
+
+// complete 执行单次非流式请求
+func (c *OpenAIClient) complete(ctx context.Context, s *openAISettings, body []byte) (*completionMessage, error) {
+	resp, err := s.do(ctx, s.httpClient, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var out completionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	if len(out.Choices) == 0 {
+		return nil, errors.New("empty choices in response")
+	}
+	return &out.Choices[0].Message, nil
+}
+
+// callTool 执行模型发起的工具调用，失败时将错误信息作为结果交给模型
+func (c *OpenAIClient) callTool(ctx context.Context, req ai.ChatRequest, tc toolCall) string {
+	res := runTool(ctx, c.logger, req, ai.ToolCall{ID: tc.ID, Name: tc.Function.Name, Arguments: json.RawMessage(tc.Function.Arguments)})
+	if res.IsError {
+		return "error: " + res.Content
+	}
+	return res.Content
+}
+
+// SendMessageStream 实现 ai.StreamingService 接口，以 stream=true 请求并解析 SSE 增量
+// 尚未收到任何增量时可重试的失败会按重试策略重试；请求带工具时退化为一次性回复
+func (c *OpenAIClient) SendMessageStream(ctx context.Context, req ai.ChatRequest, onDelta func(string)) (*ai.ChatResponse, error) {
+	s := c.settings.Load()
+	if len(req.Tools) > 0 && s.fastgpt == nil {
+		resp, err := c.SendMessage(ctx, req)
+		if err == nil && resp.Reply != "" {
+			onDelta(resp.Reply)
+		}
+		return resp, err
+	}
+	ctx, span := tracer.Start(ctx, "ai.SendMessageStream",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("ai.base_url", s.baseURL),
+			attribute.String("ai.model", s.openai.Model),
+		),
+	)
+	defer span.End()
+
+	body, err := json.Marshal(s.completionRequest(req, true))
+	if err != nil {
+		return nil, fmt.Errorf("marshal completion request: %w", err)
+	}
+
+	var lastErr error
+	for i := range s.retry + 1 {
+		var reply strings.Builder
+		received := false
+		err := c.stream(ctx, s, body, func(delta string) {
+			received = true
+			reply.WriteString(delta)
+			onDelta(delta)
+		})
+		if err == nil {
+			span.SetAttributes(attribute.Int("ai.attempts", i+1))
+			return &ai.ChatResponse{Reply: reply.String()}, nil
+		}
+		lastErr = err
+		if received || i == s.retry || !waitRetry(ctx, c.logger, s.clientSettings, err, i) {
+			break
+		}
+	}
+
+	span.RecordError(lastErr)
+	span.SetStatus(codes.Error, "ai stream failed")
+	c.logger.ErrorContext(ctx, "AI stream request failed", "user_id", req.UserID, "error", lastErr)
+	return nil, fmt.Errorf("stream message: %w", lastErr)
+}
+
+// stream 执行单次流式请求
+func (c *OpenAIClient) stream(ctx context.Context, s *openAISettings, body []byte, onDelta func(string)) error {
+	resp, err := s.do(ctx, s.streamClient, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxStreamLine)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			return nil
+		}
+		var chunk completionChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("decode stream chunk: %w", err)
+		}
+		if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != "" {
+			onDelta(chunk.Choices[0].Delta.Content)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read stream: %w", err)
+	}
+	return nil
+}
+
+// Ping 请求 GET {base_url}/models 探测后端是否可达，5xx 视为不可用
+func (c *OpenAIClient) Ping(ctx context.Context) error {
+	s := c.settings.Load()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.endpoint("/models"), nil)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	s.authorize(req)
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("execute request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// do 发送 POST /chat/completions，非 200 响应返回 statusError
+func (s *openAISettings) do(ctx context.Context, client *http.Client, body []byte) (*http.Response, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint("/chat/completions"), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	s.authorize(httpReq)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(httpReq.Header))
+
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("execute request: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, newStatusError(resp, respBody)
+	}
+	return resp, nil
+}
+
+// endpoint 拼接接口地址，Azure OpenAI 附加 api-version 参数
+func (s *openAISettings) endpoint(path string) string {
+	u := strings.TrimSuffix(s.baseURL, "/") + path
+	if s.openai.APIVersion != "" {
+		u += "?api-version=" + url.QueryEscape(s.openai.APIVersion)
+	}
+	return u
+}
+
+// authorize 设置认证头：Azure OpenAI 使用 api-key，其余使用 Bearer
+func (s *openAISettings) authorize(req *http.Request) {
+	switch {
+	case s.openai.APIKey == "":
+	case s.openai.APIVersion != "":
+		req.Header.Set("api-key", s.openai.APIKey)
+	default:
+		req.Header.Set("Authorization", "Bearer "+s.openai.APIKey)
+	}
+}
+
+// completionRequest 将 ChatRequest 转换为 Chat Completions 请求：系统提示 → 历史对话 → 本次消息
+// FastGPT 携带 chatId 时由服务端补全历史，只发送本次消息
+func (s *openAISettings) completionRequest(req ai.ChatRequest, stream bool) completionRequest {
+	if s.fastgpt != nil && req.ConversationID != "" {
+		return completionRequest{
+			Messages:  []chatMessage{{Role: ai.RoleUser, Content: userContent(req)}},
+			User:      req.UserID,
+			Stream:    stream,
+			ChatID:    req.ConversationID,
+			Variables: requestVars(s.fastgpt.Variables, req),
+		}
+	}
+
+	messages := make([]chatMessage, 0, len(req.History)+2)
+	if prompt := systemPrompt(s.openai.SystemPrompt, req); prompt != "" {
+		messages = append(messages, chatMessage{Role: "system", Content: prompt})
+	}
+	for _, t := range req.History {
+		messages = append(messages, chatMessage{Role: t.Role, Content: t.Content})
+	}
+	messages = append(messages, chatMessage{Role: ai.RoleUser, Content: userContent(req)})
+
+	out := completionRequest{
+		Model:       s.openai.Model,
+		Messages:    messages,
+		Temperature: s.openai.Temperature,
+		MaxTokens:   s.openai.MaxTokens,
+		User:        req.UserID,
+		Stream:      stream,
+	}
+	if s.fastgpt != nil {
+		out.Variables = requestVars(s.fastgpt.Variables, req)
+		return out
+	}
+	for _, t := range req.Tools {
+		out.Tools = append(out.Tools, toolSpec{Type: "function", Function: toolFunction{Name: t.Name, Description: t.Description, Parameters: t.Parameters}})
+	}
+	return out
+}
+
+// userContent 构造用户消息：图片使用 image_url（已下载内容时为 data URL），其余附件以文字描述附在正文后
+func userContent(req ai.ChatRequest) any {
+	a := req.Attachment
+	if a == nil {
+		return req.Content
+	}
+	if u := cmp.Or(a.DataURL(), a.URL); a.Type == "image" && u != "" {
+		parts := []contentPart{{Type: "image_url", ImageURL: &imageURL{URL: u}}}
+		if req.Content != "" {
+			parts = append([]contentPart{{Type: "text", Text: req.Content}}, parts...)
+		}
+		return parts
+	}
+
+	return plainContent(req)
+}
+
+// systemPrompt 组合系统提示：路由指定的系统提示、人设的系统提示、配置的 base 择先非空者，
+// 再依次附加人设的语气、语言、主题说明和发送者的通讯录信息
+func systemPrompt(base string, req ai.ChatRequest) string {
+	var persona string
+	if req.Persona != nil {
+		persona = req.Persona.SystemPrompt
+	}
+	var parts []string
+	for _, p := range []string{cmp.Or(req.SystemPrompt, persona, base), req.Persona.Instructions(), req.Profile.Describe()} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// requestVars 在应用变量中加入 user_name、user_department、user_position 和 persona_tone、persona_language、persona_topics，不修改原 map
+func requestVars(base map[string]string, req ai.ChatRequest) map[string]string {
+	p, ps := req.Profile, req.Persona
+	if p == nil && ps == nil {
+		return base
+	}
+	vars := map[string]string{}
+	if p != nil {
+		vars["user_name"], vars["user_department"], vars["user_position"] = p.Name, p.Department, p.Position
+	}
+	if ps != nil {
+		vars["persona_tone"], vars["persona_language"], vars["persona_topics"] = ps.Tone, ps.Language, strings.Join(ps.Topics, "、")
+	}
+	out := make(map[string]string, len(base)+len(vars))
+	maps.Copy(out, base)
+	for k, v := range vars {
+		if v != "" {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// plainContent 将附件以文字描述附在正文后，用于只接受纯文本的模型
+func plainContent(req ai.ChatRequest) string {
+	a := req.Attachment
+	if a == nil {
+		return req.Content
+	}
+	desc := []string{"[" + a.Type + "]"}
+	for _, v := range []string{a.Title, a.Description, a.Label, a.URL} {
+		if v != "" {
+			desc = append(desc, v)
+		}
+	}
+	if a.Type == "location" {
+		desc = append(desc, fmt.Sprintf("(%f, %f)", a.Latitude, a.Longitude))
+	}
+	text := strings.Join(desc, " ")
+	if a.Text != "" {
+		text += "\n" + a.Text
+	}
+	if req.Content != "" {
+		text = req.Content + "\n" + text
+	}
+	return text
+}
@@ -0,0 +1,108 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// 重试退避默认值
+const (
+	defaultRetryBaseDelay = 500 * time.Millisecond
+	defaultRetryMaxDelay  = 10 * time.Second
+)
+
+// statusError AI 后端返回的非 200 响应
+type statusError struct {
+	code       int
+	body       string
+	retryAfter time.Duration // Retry-After 头，未设置时为 0
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status %d: %s", e.code, e.body)
+}
+
+// StatusCode 返回 HTTP 状态码，错误上报只使用状态码而不带响应体
+func (e *statusError) StatusCode() int {
+	return e.code
+}
+
+// newStatusError 读取响应体并解析 Retry-After
+func newStatusError(resp *http.Response, body []byte) *statusError {
+	return &statusError{
+		code:       resp.StatusCode,
+		body:       string(body),
+		retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+}
+
+// retryable 仅网络错误、429 和 5xx 可重试，4xx 和响应解析错误重试也不会成功
+func retryable(err error) bool {
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.code == http.StatusTooManyRequests || se.code >= http.StatusInternalServerError
+	}
+	var ue *url.Error
+	var ne net.Error
+	return errors.As(err, &ue) || errors.As(err, &ne)
+}
+
+// waitRetry 判断第 attempt 次失败是否应重试，并等待退避时长；返回 false 表示放弃
+func waitRetry(ctx context.Context, logger *slog.Logger, s *clientSettings, err error, attempt int) bool {
+	if ctx.Err() != nil || !retryable(err) {
+		return false
+	}
+	delay, ok := retryDelay(err, attempt, s.baseDelay, s.maxDelay)
+	if !ok {
+		logger.WarnContext(ctx, "AI backend Retry-After exceeds retry_max_delay, giving up", "retry_after", delay)
+		return false
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// retryDelay 计算第 attempt 次（从 0 开始）失败后的等待时长
+// 默认 full jitter：在 [0, min(maxDelay, base*2^attempt)) 内随机；后端返回 Retry-After 时按其等待
+// Retry-After 超过 maxDelay 时返回 false，不再重试
+func retryDelay(err error, attempt int, base, maxDelay time.Duration) (time.Duration, bool) {
+	var se *statusError
+	if errors.As(err, &se) && se.retryAfter > 0 {
+		return se.retryAfter, se.retryAfter <= maxDelay
+	}
+	ceiling := maxDelay
+	if shifted := base << min(attempt, 30); shifted > 0 && shifted < maxDelay {
+		ceiling = shifted
+	}
+	if ceiling <= 0 {
+		return 0, true
+	}
+	return rand.N(ceiling), true
+}
+
+// parseRetryAfter 解析秒数或 HTTP 日期格式的 Retry-After
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,60 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go-wework-svc/internal/wework"
+	"go-wework-svc/internal/wework/token"
+)
+
+// TodoClient 基于 todo/create API 的 wework.TodoClient 实现
+type TodoClient struct {
+	api *weworkAPI
+}
+
+// NewTodoClient 创建待办客户端，应用需在管理后台开启待办的 API 权限
+func NewTodoClient(baseURL string, tokens token.TokenProvider) *TodoClient {
+	return &TodoClient{
+		api: &weworkAPI{
+			baseURL:    baseURL,
+			tokens:     tokens,
+			httpClient: &http.Client{Timeout: weworkAPITimeout},
+		},
+	}
+}
+
+// todoFollower 待办跟进人
+type todoFollower struct {
+	FollowerID     string `json:"follower_id"`
+	FollowerStatus int    `json:"follower_status"` // 1 未完成
+}
+
+// todoRequest todo/create 请求体
+type todoRequest struct {
+	CreatorID    string `json:"creator_id"`
+	Content      string `json:"content"`
+	FollowerList struct {
+		Followers []todoFollower `json:"followers"`
+	} `json:"follower_list"`
+	RemindTime int64 `json:"remind_time,omitempty"`
+}
+
+// CreateTodo 实现 wework.TodoClient 接口
+func (c *TodoClient) CreateTodo(ctx context.Context, todo wework.Todo) (string, error) {
+	req := todoRequest{CreatorID: todo.Creator, Content: todo.Content}
+	for _, u := range todo.Followers {
+		req.FollowerList.Followers = append(req.FollowerList.Followers, todoFollower{FollowerID: u, FollowerStatus: 1})
+	}
+	if !todo.RemindTime.IsZero() {
+		req.RemindTime = todo.RemindTime.Unix()
+	}
+	var resp struct {
+		TodoID string `json:"todo_id"`
+	}
+	if err := c.api.postJSON(ctx, "/cgi-bin/todo/create", req, &resp); err != nil {
+		return "", fmt.Errorf("create todo: %w", err)
+	}
+	return resp.TodoID, nil
+}
@@ -0,0 +1,121 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"go-wework-svc/internal/wework"
+)
+
+// Webhook 群机器人 webhook 客户端，实现 wework.Webhook
+type Webhook struct {
+	url        string
+	httpClient *http.Client
+}
+
+// NewWebhook 创建群机器人客户端，url 为完整的 webhook 地址（含 key）
+func NewWebhook(url string) *Webhook {
+	return &Webhook{
+		url:        url,
+		httpClient: &http.Client{Timeout: weworkAPITimeout},
+	}
+}
+
+// webhookRequest 群机器人请求体
+type webhookRequest struct {
+	MsgType  string           `json:"msgtype"`
+	Text     *webhookText     `json:"text,omitempty"`
+	Markdown *textContent     `json:"markdown,omitempty"`
+	Image    *webhookImage    `json:"image,omitempty"`
+	News     *webhookNewsBody `json:"news,omitempty"`
+}
+
+type webhookText struct {
+	Content             string   `json:"content"`
+	MentionedList       []string `json:"mentioned_list,omitempty"`
+	MentionedMobileList []string `json:"mentioned_mobile_list,omitempty"`
+}
+
+type webhookImage struct {
+	Base64 string `json:"base64"`
+	MD5    string `json:"md5"`
+}
+
+type webhookNewsBody struct {
+	Articles []webhookArticle `json:"articles"`
+}
+
+type webhookArticle struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	URL         string `json:"url"`
+	PicURL      string `json:"picurl,omitempty"`
+}
+
+// Post 实现 wework.Webhook 接口
+func (w *Webhook) Post(ctx context.Context, msg wework.WebhookMessage) error {
+	req := webhookRequest{MsgType: msg.MsgType}
+	switch msg.MsgType {
+	case wework.MsgTypeText:
+		req.Text = &webhookText{
+			Content:             msg.Content,
+			MentionedList:       msg.MentionedList,
+			MentionedMobileList: msg.MentionedMobileList,
+		}
+	case wework.MsgTypeMarkdown:
+		req.Markdown = &textContent{Content: msg.Content}
+	case wework.MsgTypeImage:
+		sum := md5.Sum(msg.Image)
+		req.Image = &webhookImage{
+			Base64: base64.StdEncoding.EncodeToString(msg.Image),
+			MD5:    hex.EncodeToString(sum[:]),
+		}
+	case wework.MsgTypeNews:
+		news := &webhookNewsBody{Articles: make([]webhookArticle, 0, len(msg.Articles))}
+		for _, a := range msg.Articles {
+			news.Articles = append(news.Articles, webhookArticle{
+				Title:       a.Title,
+				Description: a.Description,
+				URL:         a.URL,
+				PicURL:      a.PicURL,
+			})
+		}
+		req.News = news
+	default:
+		return fmt.Errorf("unsupported webhook msg type %q", msg.MsgType)
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("marshal webhook request: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	var result apiResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	if result.ErrCode != 0 {
+		return &wework.APIError{Code: result.ErrCode, Msg: result.ErrMsg}
+	}
+	return nil
+}
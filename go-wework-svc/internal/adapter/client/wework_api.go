@@ -0,0 +1,102 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"go-wework-svc/internal/wework"
+	"go-wework-svc/internal/wework/token"
+)
+
+// apiResult 企业微信服务端 API 响应的公共字段
+type apiResult struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+// weworkAPI 企业微信服务端 API 的公共调用逻辑：附加 access_token、解析 errcode、token 失效重试
+type weworkAPI struct {
+	baseURL    string
+	tokens     token.TokenProvider
+	httpClient *http.Client
+}
+
+// postJSON 以 JSON 调用 POST 接口，out 为 nil 时只检查 errcode
+func (a *weworkAPI) postJSON(ctx context.Context, path string, in, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("marshal request: %w", err)
+	}
+	return token.Do(ctx, a.tokens, func(tok string) error {
+		return a.do(ctx, http.MethodPost, path, url.Values{"access_token": {tok}}, "application/json", body, out)
+	})
+}
+
+// getJSON 调用 GET 接口
+func (a *weworkAPI) getJSON(ctx context.Context, path string, query url.Values, out any) error {
+	return token.Do(ctx, a.tokens, func(tok string) error {
+		q := url.Values{"access_token": {tok}}
+		for k, v := range query {
+			q[k] = v
+		}
+		return a.do(ctx, http.MethodGet, path, q, "", nil, out)
+	})
+}
+
+// postBody 以指定 Content-Type 调用 POST 接口，如 multipart 上传
+func (a *weworkAPI) postBody(ctx context.Context, path string, query url.Values, contentType string, body []byte, out any) error {
+	return token.Do(ctx, a.tokens, func(tok string) error {
+		q := url.Values{"access_token": {tok}}
+		for k, v := range query {
+			q[k] = v
+		}
+		return a.do(ctx, http.MethodPost, path, q, contentType, body, out)
+	})
+}
+
+func (a *weworkAPI) do(ctx context.Context, method, path string, query url.Values, contentType string, body []byte, out any) error {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, a.baseURL+path+"?"+query.Encode(), reader)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read response: %w", err)
+	}
+
+	var result apiResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	if result.ErrCode != 0 {
+		return &wework.APIError{Code: result.ErrCode, Msg: result.ErrMsg}
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("decode response: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package grpcapi
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"go-wework-svc/internal/wework"
+)
+
+// Hub 将入站消息分发给订阅者，实现 wework.Archive
+// 每个订阅者有独立的缓冲队列，队列满时丢弃该订阅者的消息，不阻塞回调处理
+type Hub struct {
+	bufferSize int
+	logger     *slog.Logger
+
+	mu     sync.Mutex
+	subs   map[*subscriber]struct{}
+	closed bool
+}
+
+type subscriber struct {
+	peer     string
+	agents   map[string]bool
+	msgTypes map[string]bool
+	ch       chan *Message
+}
+
+// NewHub 创建消息分发器，bufferSize 为每个订阅者的缓冲消息数
+func NewHub(bufferSize int, logger *slog.Logger) *Hub {
+	return &Hub{bufferSize: bufferSize, logger: logger, subs: make(map[*subscriber]struct{})}
+}
+
+// Inbound 实现 wework.Archive
+func (h *Hub) Inbound(_ context.Context, agent string, msg wework.Message) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.subs) == 0 {
+		return
+	}
+	m := &Message{
+		MsgID:      msg.MsgID,
+		Agent:      agent,
+		UserID:     msg.FromUserName,
+		ChatID:     msg.ChatID,
+		MsgType:    msg.MsgType,
+		Content:    msg.Content,
+		MediaID:    msg.MediaID,
+		PicURL:     msg.PicURL,
+		Title:      msg.Title,
+		URL:        msg.URL,
+		CreateTime: msg.CreateTime,
+	}
+	for sub := range h.subs {
+		if !sub.matches(m) {
+			continue
+		}
+		select {
+		case sub.ch <- m:
+		default:
+			h.logger.Warn("grpc subscriber too slow, dropping message", "peer", sub.peer, "msg_id", m.MsgID)
+		}
+	}
+}
+
+// Outbound 实现 wework.Archive，回复不推送给订阅者
+func (h *Hub) Outbound(context.Context, string, wework.Message, string) {}
+
+// Subscribers 返回当前订阅者数量
+func (h *Hub) Subscribers() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs)
+}
+
+// subscribe 注册订阅者，服务停止后返回 nil
+func (h *Hub) subscribe(peer string, req *SubscribeRequest) *subscriber {
+	sub := &subscriber{peer: peer, ch: make(chan *Message, h.bufferSize)}
+	if len(req.Agents) > 0 {
+		sub.agents = make(map[string]bool, len(req.Agents))
+		for _, a := range req.Agents {
+			sub.agents[a] = true
+		}
+	}
+	if len(req.MsgTypes) > 0 {
+		sub.msgTypes = make(map[string]bool, len(req.MsgTypes))
+		for _, t := range req.MsgTypes {
+			sub.msgTypes[t] = true
+		}
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil
+	}
+	h.subs[sub] = struct{}{}
+	return sub
+}
+
+func (h *Hub) unsubscribe(sub *subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.subs, sub)
+}
+
+// close 关闭所有订阅者的队列，之后的订阅请求被拒绝
+func (h *Hub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for sub := range h.subs {
+		close(sub.ch)
+		delete(h.subs, sub)
+	}
+}
+
+func (sub *subscriber) matches(m *Message) bool {
+	if sub.agents != nil && !sub.agents[m.Agent] {
+		return false
+	}
+	return sub.msgTypes == nil || sub.msgTypes[m.MsgType]
+}
@@ -0,0 +1,169 @@
+package grpcapi
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// 以下消息与 stream.proto 一一对应，按 protobuf 线格式手写编解码，客户端可直接使用 stream.proto 生成的代码
+
+// SubscribeRequest 订阅请求
+type SubscribeRequest struct {
+	Agents   []string
+	MsgTypes []string
+}
+
+// Message 推送给订阅者的入站消息
+type Message struct {
+	MsgID      string
+	Agent      string
+	UserID     string
+	ChatID     string
+	MsgType    string
+	Content    string
+	MediaID    string
+	PicURL     string
+	Title      string
+	URL        string
+	CreateTime int64
+}
+
+// ReplyRequest 回复请求
+type ReplyRequest struct {
+	Agent   string
+	UserID  string
+	MsgType string
+	Content string
+}
+
+// ReplyResponse 回复结果
+type ReplyResponse struct{}
+
+func (m *SubscribeRequest) marshal() []byte {
+	var b []byte
+	for _, s := range m.Agents {
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendString(b, s)
+	}
+	for _, s := range m.MsgTypes {
+		b = protowire.AppendTag(b, 2, protowire.BytesType)
+		b = protowire.AppendString(b, s)
+	}
+	return b
+}
+
+func (m *SubscribeRequest) unmarshal(b []byte) error {
+	return consumeFields(b, func(num protowire.Number, typ protowire.Type, b []byte) int {
+		var s string
+		n := consumeString(num, typ, b, &s)
+		switch {
+		case n < 0 || typ != protowire.BytesType:
+		case num == 1:
+			m.Agents = append(m.Agents, s)
+		case num == 2:
+			m.MsgTypes = append(m.MsgTypes, s)
+		}
+		return n
+	})
+}
+
+func (m *Message) marshal() []byte {
+	var b []byte
+	b = appendString(b, 1, m.MsgID)
+	b = appendString(b, 2, m.Agent)
+	b = appendString(b, 3, m.UserID)
+	b = appendString(b, 4, m.ChatID)
+	b = appendString(b, 5, m.MsgType)
+	b = appendString(b, 6, m.Content)
+	b = appendString(b, 7, m.MediaID)
+	b = appendString(b, 8, m.PicURL)
+	b = appendString(b, 9, m.Title)
+	b = appendString(b, 10, m.URL)
+	if m.CreateTime != 0 {
+		b = protowire.AppendTag(b, 11, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(m.CreateTime))
+	}
+	return b
+}
+
+func (m *Message) unmarshal(b []byte) error {
+	fields := map[protowire.Number]*string{
+		1: &m.MsgID, 2: &m.Agent, 3: &m.UserID, 4: &m.ChatID, 5: &m.MsgType,
+		6: &m.Content, 7: &m.MediaID, 8: &m.PicURL, 9: &m.Title, 10: &m.URL,
+	}
+	return consumeFields(b, func(num protowire.Number, typ protowire.Type, b []byte) int {
+		if num == 11 && typ == protowire.VarintType {
+			v, n := protowire.ConsumeVarint(b)
+			m.CreateTime = int64(v)
+			return n
+		}
+		if dst, ok := fields[num]; ok {
+			return consumeString(num, typ, b, dst)
+		}
+		return protowire.ConsumeFieldValue(num, typ, b)
+	})
+}
+
+func (m *ReplyRequest) marshal() []byte {
+	var b []byte
+	b = appendString(b, 1, m.Agent)
+	b = appendString(b, 2, m.UserID)
+	b = appendString(b, 3, m.MsgType)
+	b = appendString(b, 4, m.Content)
+	return b
+}
+
+func (m *ReplyRequest) unmarshal(b []byte) error {
+	fields := map[protowire.Number]*string{1: &m.Agent, 2: &m.UserID, 3: &m.MsgType, 4: &m.Content}
+	return consumeFields(b, func(num protowire.Number, typ protowire.Type, b []byte) int {
+		if dst, ok := fields[num]; ok {
+			return consumeString(num, typ, b, dst)
+		}
+		return protowire.ConsumeFieldValue(num, typ, b)
+	})
+}
+
+func (m *ReplyResponse) marshal() []byte { return nil }
+
+func (m *ReplyResponse) unmarshal(b []byte) error {
+	return consumeFields(b, protowire.ConsumeFieldValue)
+}
+
+// appendString 追加字符串字段，空字符串按 proto3 默认值省略
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+// consumeFields 逐个解析字段，field 返回字段值占用的字节数，负数表示解析错误
+func consumeFields(b []byte, field func(num protowire.Number, typ protowire.Type, b []byte) int) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return fmt.Errorf("parse tag: %w", protowire.ParseError(n))
+		}
+		b = b[n:]
+		n = field(num, typ, b)
+		if n < 0 {
+			return fmt.Errorf("parse field %d: %w", num, protowire.ParseError(n))
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+// consumeString 解析字符串字段写入 dst，类型不符时跳过该字段
+func consumeString(num protowire.Number, typ protowire.Type, b []byte, dst *string) int {
+	if typ != protowire.BytesType {
+		return protowire.ConsumeFieldValue(num, typ, b)
+	}
+	v, n := protowire.ConsumeString(b)
+	if n >= 0 {
+		*dst = v
+	}
+	return n
+}
@@ -0,0 +1,219 @@
+// Package grpcapi 消息流 gRPC 服务：内部服务订阅解密后的入站消息，并通过应用发送回复
+package grpcapi
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"go-wework-svc/internal/shared"
+	"go-wework-svc/internal/wework"
+)
+
+// wireMessage 手写编解码的消息
+type wireMessage interface {
+	marshal() []byte
+	unmarshal([]byte) error
+}
+
+// codec 只处理本包消息的 protobuf 编解码器，仅用于本服务，不影响进程内其他 gRPC 客户端
+type codec struct{}
+
+func (codec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(wireMessage)
+	if !ok {
+		return nil, fmt.Errorf("unsupported message type %T", v)
+	}
+	return m.marshal(), nil
+}
+
+func (codec) Unmarshal(data []byte, v any) error {
+	m, ok := v.(wireMessage)
+	if !ok {
+		return fmt.Errorf("unsupported message type %T", v)
+	}
+	return m.unmarshal(data)
+}
+
+func (codec) Name() string { return "proto" }
+
+// Server 消息流 gRPC 服务
+type Server struct {
+	cfg     shared.GRPCConfig
+	hub     *Hub
+	senders map[string]wework.Sender
+	logger  *slog.Logger
+}
+
+// NewServer 创建 gRPC 服务，senders 按应用名称索引，空字符串为默认应用
+func NewServer(cfg shared.GRPCConfig, hub *Hub, senders map[string]wework.Sender, logger *slog.Logger) *Server {
+	return &Server{cfg: cfg, hub: hub, senders: senders, logger: logger}
+}
+
+// Run 监听并处理请求，ctx 取消后断开订阅并停止服务
+func (s *Server) Run(ctx context.Context) {
+	opts := []grpc.ServerOption{
+		grpc.ForceServerCodec(codec{}),
+		grpc.UnaryInterceptor(func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, h grpc.UnaryHandler) (any, error) {
+			if err := s.authorize(ctx); err != nil {
+				return nil, err
+			}
+			return h(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, h grpc.StreamHandler) error {
+			if err := s.authorize(ss.Context()); err != nil {
+				return err
+			}
+			return h(srv, ss)
+		}),
+	}
+	if s.cfg.CertFile != "" {
+		creds, err := credentials.NewServerTLSFromFile(s.cfg.CertFile, s.cfg.KeyFile)
+		if err != nil {
+			s.logger.Error("failed to load grpc tls certificate", "error", err)
+			return
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+	srv := grpc.NewServer(opts...)
+	srv.RegisterService(&serviceDesc, s)
+
+	ln, err := net.Listen("tcp", s.cfg.Addr)
+	if err != nil {
+		s.logger.Error("failed to listen grpc", "addr", s.cfg.Addr, "error", err)
+		return
+	}
+	go func() {
+		<-ctx.Done()
+		// 订阅流不会自行结束，先断开订阅者再等待进行中的回复请求
+		s.hub.close()
+		srv.GracefulStop()
+	}()
+	s.logger.Info("grpc server started", "addr", ln.Addr().String())
+	if err := srv.Serve(ln); err != nil {
+		s.logger.Error("grpc server error", "error", err)
+	}
+}
+
+// authorize 校验 authorization 元数据中的 Bearer 令牌
+func (s *Server) authorize(ctx context.Context) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, v := range md.Get("authorization") {
+		token, ok := strings.CutPrefix(v, "Bearer ")
+		if !ok {
+			continue
+		}
+		for _, t := range s.cfg.Tokens {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+				return nil
+			}
+		}
+	}
+	return status.Error(codes.Unauthenticated, "missing or invalid token")
+}
+
+// Subscribe 推送入站消息直到客户端断开或服务停止
+func (s *Server) Subscribe(req *SubscribeRequest, stream grpc.ServerStream) error {
+	sub := s.hub.subscribe(peerAddr(stream.Context()), req)
+	if sub == nil {
+		return status.Error(codes.Unavailable, "server is shutting down")
+	}
+	defer s.hub.unsubscribe(sub)
+	s.logger.Info("grpc subscriber connected", "peer", sub.peer, "agents", req.Agents, "msg_types", req.MsgTypes)
+	for {
+		select {
+		case m, ok := <-sub.ch:
+			if !ok {
+				return status.Error(codes.Unavailable, "server is shutting down")
+			}
+			if err := stream.SendMsg(m); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			s.logger.Info("grpc subscriber disconnected", "peer", sub.peer)
+			return nil
+		}
+	}
+}
+
+// Reply 通过应用的 message/send API 发送消息，不经过出站变换和归档
+func (s *Server) Reply(ctx context.Context, req *ReplyRequest) (*ReplyResponse, error) {
+	if req.UserID == "" || req.Content == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id and content are required")
+	}
+	msgType := req.MsgType
+	if msgType == "" {
+		msgType = wework.MsgTypeText
+	}
+	if msgType != wework.MsgTypeText && msgType != wework.MsgTypeMarkdown {
+		return nil, status.Errorf(codes.InvalidArgument, "msg_type must be text or markdown, got %q", msgType)
+	}
+	sender, ok := s.senders[req.Agent]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "agent %q has no sender configured", req.Agent)
+	}
+	if err := sender.Send(ctx, wework.OutgoingMessage{ToUser: req.UserID, MsgType: msgType, Content: req.Content}); err != nil {
+		s.logger.Error("grpc reply send failed", "agent", req.Agent, "user_id", req.UserID, "error", err)
+		return nil, status.Errorf(codes.Unavailable, "send failed: %v", err)
+	}
+	s.logger.Info("grpc reply sent", "agent", req.Agent, "user_id", req.UserID, "msg_type", msgType, "peer", peerAddr(ctx))
+	return &ReplyResponse{}, nil
+}
+
+func peerAddr(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok {
+		return p.Addr.String()
+	}
+	return ""
+}
+
+// messageStreamServer serviceDesc 要求的服务实现
+type messageStreamServer interface {
+	Subscribe(*SubscribeRequest, grpc.ServerStream) error
+	Reply(context.Context, *ReplyRequest) (*ReplyResponse, error)
+}
+
+// serviceDesc 对应 stream.proto 中的 wework.v1.MessageStream
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: "wework.v1.MessageStream",
+	HandlerType: (*messageStreamServer)(nil),
+	Methods: []grpc.MethodDesc{{
+		MethodName: "Reply",
+		Handler: func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+			req := new(ReplyRequest)
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			h := func(ctx context.Context, req any) (any, error) {
+				return srv.(messageStreamServer).Reply(ctx, req.(*ReplyRequest))
+			}
+			if interceptor == nil {
+				return h(ctx, req)
+			}
+			info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/wework.v1.MessageStream/Reply"}
+			return interceptor(ctx, req, info, h)
+		},
+	}},
+	Streams: []grpc.StreamDesc{{
+		StreamName: "Subscribe",
+		Handler: func(srv any, stream grpc.ServerStream) error {
+			req := new(SubscribeRequest)
+			if err := stream.RecvMsg(req); err != nil {
+				return err
+			}
+			return srv.(messageStreamServer).Subscribe(req, stream)
+		},
+		ServerStreams: true,
+	}},
+	Metadata: "stream.proto",
+}
@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"crypto/rand"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"time"
+
+	"go-wework-svc/internal/shared"
+)
+
+// RequestIDHeader 请求 ID 头，上游已设置时沿用，并写回响应
+const RequestIDHeader = "X-Request-Id"
+
+// validRequestID 上游请求 ID 的格式限制，防止日志注入
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// probePaths 探针路径，以 debug 级别记录访问日志
+var probePaths = map[string]bool{"/health": true, "/healthz": true, "/readyz": true}
+
+// AccessLog 为每个请求分配请求 ID 并写入 context，请求结束后记录方法、路径、状态码、耗时和响应字节数
+func AccessLog(next http.Handler, logger *slog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = rand.Text()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		ctx := shared.WithRequestID(r.Context(), id)
+
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		level := slog.LevelInfo
+		if probePaths[r.URL.Path] {
+			level = slog.LevelDebug
+		}
+		logger.Log(ctx, level, "http request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", status,
+			"duration_ms", time.Since(start).Milliseconds(),
+			"bytes", rec.bytes,
+			"remote_ip", remoteIP(r),
+			"scheme", requestScheme(r),
+		)
+	})
+}
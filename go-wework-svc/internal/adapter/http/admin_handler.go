@@ -0,0 +1,25 @@
+package handler
+
+import (
+	"net/http"
+
+	"go-wework-svc/internal/auth"
+)
+
+// WhoAmIHandler 返回当前管理端登录身份
+type WhoAmIHandler struct{}
+
+// NewWhoAmIHandler 创建身份查询处理器
+func NewWhoAmIHandler() *WhoAmIHandler {
+	return &WhoAmIHandler{}
+}
+
+// ServeHTTP 以 JSON 返回 context 中的身份信息
+func (h *WhoAmIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id, ok := auth.IdentityFrom(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	writeJSON(w, http.StatusOK, id)
+}
@@ -1,32 +1,148 @@
 package handler
 
 import (
+	"context"
 	"errors"
 	"io"
 	"log/slog"
 	"net/http"
 	"strings"
+	"time"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"go-wework-svc/internal/store"
 	"go-wework-svc/internal/wework"
 )
 
+// defaultMaxBodyBytes 回调请求体默认上限，企业微信加密消息通常只有几 KB
+const defaultMaxBodyBytes = 256 << 10
+
 // CallbackHandler 企业微信回调 HTTP 处理器
 type CallbackHandler struct {
-	svc    wework.Service
-	logger *slog.Logger
+	svc     wework.CallbackService
+	logger  *slog.Logger
+	limiter *failureLimiter
+	maxBody int64
+	observe func(r *http.Request, status int, elapsed time.Duration)
+	onError func(reason string)
+	report  func(ctx context.Context, reason string, err error)
+	audit   func(ctx context.Context, e SecurityEvent)
+	// allowlist 非空时只接受来自企业微信回调 IP 段的请求
+	allowlist *SourceAllowlist
+	// backpressure 非空时 POST 回调需先占用处理槽位
+	backpressure *wework.Backpressure
+}
+
+// CallbackOption 回调处理器可选配置
+type CallbackOption func(*CallbackHandler)
+
+// WithSignatureFailureLimit 启用签名失败限流：同一 IP 在 window 内失败 limit 次后返回 429，直到窗口过期
+func WithSignatureFailureLimit(kv store.Store, limit int, window time.Duration) CallbackOption {
+	return func(h *CallbackHandler) {
+		h.limiter = &failureLimiter{kv: kv, limit: int64(limit), window: window, logger: h.logger}
+	}
+}
+
+// WithMaxBodyBytes 设置回调请求体上限，超过时返回 413，默认 256KB
+func WithMaxBodyBytes(n int64) CallbackOption {
+	return func(h *CallbackHandler) { h.maxBody = n }
+}
+
+// WithRequestObserver 每个回调请求处理完成后调用 fn，包括签名失败等被拒绝的请求
+func WithRequestObserver(fn func(r *http.Request, status int, elapsed time.Duration)) CallbackOption {
+	return func(h *CallbackHandler) { h.observe = fn }
+}
+
+// WithErrorObserver 回调处理失败时以原因（Reason* 常量）调用 fn，用于按原因计数
+func WithErrorObserver(fn func(reason string)) CallbackOption {
+	return func(h *CallbackHandler) { h.onError = fn }
+}
+
+// WithErrorReporter 解密失败、corp_id 不一致和内部错误时调用 fn，用于上报到错误收集服务
+// 签名失败、重放等由外部请求引起的错误不上报
+func WithErrorReporter(fn func(ctx context.Context, reason string, err error)) CallbackOption {
+	return func(h *CallbackHandler) { h.report = fn }
+}
+
+// WithSecurityObserver 签名失败、corp_id 不一致、解密失败和重放请求时调用 fn，用于安全计数和审计
+func WithSecurityObserver(fn func(ctx context.Context, e SecurityEvent)) CallbackOption {
+	return func(h *CallbackHandler) { h.audit = fn }
+}
+
+// WithSourceAllowlist 拒绝不在回调 IP 白名单内的请求，返回 403 并计入安全事件
+func WithSourceAllowlist(a *SourceAllowlist) CallbackOption {
+	return func(h *CallbackHandler) { h.allowlist = a }
+}
+
+// WithBackpressure 启用并发限制：槽位和等待队列已满时立即返回 503，由企业微信稍后重试
+// 在验签和重放检查之前拒绝，重试请求不会被当作重放
+func WithBackpressure(b *wework.Backpressure) CallbackOption {
+	return func(h *CallbackHandler) { h.backpressure = b }
 }
 
 // NewCallbackHandler 创建回调处理器实例
-func NewCallbackHandler(svc wework.Service, logger *slog.Logger) *CallbackHandler {
-	return &CallbackHandler{svc: svc, logger: logger}
+func NewCallbackHandler(svc wework.CallbackService, logger *slog.Logger, opts ...CallbackOption) *CallbackHandler {
+	h := &CallbackHandler{svc: svc, logger: logger, maxBody: defaultMaxBodyBytes}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
 }
 
+var tracer = otel.Tracer("go-wework-svc/internal/adapter/http")
+
 // ServeHTTP 统一处理 GET（URL 验证）和 POST（消息回调）请求
 func (h *CallbackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	ctx, span := tracer.Start(ctx, "callback "+r.Method,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("http.request.method", r.Method),
+			attribute.String("url.path", r.URL.Path),
+		),
+	)
+	defer span.End()
+
+	start := time.Now()
+	rec := &statusRecorder{ResponseWriter: w}
+	w, r = rec, r.WithContext(ctx)
+	defer func() {
+		span.SetAttributes(attribute.Int("http.response.status_code", rec.status))
+		if rec.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(rec.status))
+		}
+		if h.observe != nil {
+			h.observe(r, rec.status, time.Since(start))
+		}
+	}()
+
+	if h.allowlist != nil && !h.allowlist.Allowed(remoteIP(r)) {
+		h.fail(w, r, wework.CallbackQuery{}, "callback", errSourceNotAllowed)
+		return
+	}
+	if h.limiter != nil && h.limiter.blocked(ctx, remoteIP(r)) {
+		http.Error(w, "too many requests", http.StatusTooManyRequests)
+		return
+	}
+
 	switch r.Method {
 	case http.MethodGet:
 		h.handleVerifyURL(w, r)
 	case http.MethodPost:
+		if h.backpressure != nil {
+			release, err := h.backpressure.Acquire(ctx)
+			if err != nil {
+				w.Header().Set("Retry-After", "1")
+				h.fail(w, r, wework.CallbackQuery{}, "callback", err)
+				return
+			}
+			defer release()
+		}
 		h.handleCallback(w, r)
 	default:
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -44,16 +160,7 @@ func (h *CallbackHandler) handleVerifyURL(w http.ResponseWriter, r *http.Request
 
 	plaintext, err := h.svc.VerifyURL(r.Context(), q)
 	if err != nil {
-		if errors.Is(err, wework.ErrInvalidSignature) {
-			h.logger.Warn("URL verification signature failed",
-				"timestamp", q.Timestamp,
-				"nonce", q.Nonce,
-			)
-			http.Error(w, "forbidden", http.StatusForbidden)
-			return
-		}
-		h.logger.Error("URL verification failed", "error", err)
-		http.Error(w, "internal server error", http.StatusInternalServerError)
+		h.fail(w, r, q, "URL verification", err)
 		return
 	}
 
@@ -63,9 +170,15 @@ func (h *CallbackHandler) handleVerifyURL(w http.ResponseWriter, r *http.Request
 
 // handleCallback 处理 POST 请求的消息回调
 func (h *CallbackHandler) handleCallback(w http.ResponseWriter, r *http.Request) {
-	body, err := io.ReadAll(r.Body)
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.maxBody))
 	if err != nil {
-		h.logger.Error("failed to read request body", "error", err)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			h.logger.WarnContext(r.Context(), "callback body too large", "limit", tooLarge.Limit, "remote_ip", remoteIP(r))
+			http.Error(w, "request entity too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		h.logger.ErrorContext(r.Context(), "failed to read request body", "error", err)
 		http.Error(w, "internal server error", http.StatusInternalServerError)
 		return
 	}
@@ -77,26 +190,130 @@ func (h *CallbackHandler) handleCallback(w http.ResponseWriter, r *http.Request)
 		Nonce:        r.URL.Query().Get("nonce"),
 	}
 
-	err = h.svc.HandleCallback(r.Context(), q, body)
+	reply, err := h.svc.HandleCallback(r.Context(), q, body)
 	if err != nil {
-		if strings.Contains(err.Error(), "unmarshal") {
-			h.logger.Warn("callback XML parse failed", "error", err)
-			http.Error(w, "bad request", http.StatusBadRequest)
-			return
-		}
-		if errors.Is(err, wework.ErrInvalidSignature) {
-			h.logger.Warn("callback signature failed",
-				"timestamp", q.Timestamp,
-				"nonce", q.Nonce,
-			)
-			http.Error(w, "forbidden", http.StatusForbidden)
-			return
+		h.fail(w, r, q, "callback", err)
+		return
+	}
+
+	if reply != nil {
+		// JSON 格式回调（如智能机器人）的被动回复同样为 JSON
+		if reply[0] == '{' {
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		} else {
+			w.Header().Set("Content-Type", "application/xml; charset=utf-8")
 		}
-		h.logger.Error("callback processing failed", "error", err)
-		http.Error(w, "internal server error", http.StatusInternalServerError)
+		w.WriteHeader(http.StatusOK)
+		w.Write(reply)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("success"))
 }
+
+// 回调错误原因，用作指标标签和 span 的 error.type
+const (
+	ReasonMalformedBody      = "malformed_body"
+	ReasonInvalidSignature   = "invalid_signature"
+	ReasonStaleTimestamp     = "stale_timestamp"
+	ReasonReplayedRequest    = "replayed_request"
+	ReasonCorpMismatch       = "corp_mismatch"
+	ReasonDecryptFailed      = "decrypt_failed"
+	ReasonUnsupportedMsgType = "unsupported_msg_type"
+	ReasonOverloaded         = "overloaded"
+	ReasonInternal           = "internal"
+	ReasonSourceNotAllowed   = "source_not_allowed"
+)
+
+// errSourceNotAllowed 来源 IP 不在回调白名单内
+var errSourceNotAllowed = errors.New("source ip not in callback allowlist")
+
+// callbackErrors 回调错误到 HTTP 状态码和原因的映射，按顺序匹配，未匹配的错误返回 500
+// 缺少 MsgType 的消息返回 200，避免企业微信重复推送无法处理的消息
+var callbackErrors = []struct {
+	err    error
+	status int
+	reason string
+}{
+	{wework.ErrMalformedBody, http.StatusBadRequest, ReasonMalformedBody},
+	{wework.ErrInvalidSignature, http.StatusForbidden, ReasonInvalidSignature},
+	{wework.ErrStaleTimestamp, http.StatusForbidden, ReasonStaleTimestamp},
+	{wework.ErrReplayedRequest, http.StatusForbidden, ReasonReplayedRequest},
+	{wework.ErrCorpMismatch, http.StatusForbidden, ReasonCorpMismatch},
+	{wework.ErrDecryptFailed, http.StatusInternalServerError, ReasonDecryptFailed},
+	{wework.ErrUnsupportedMsgType, http.StatusOK, ReasonUnsupportedMsgType},
+	{wework.ErrOverloaded, http.StatusServiceUnavailable, ReasonOverloaded},
+	{errSourceNotAllowed, http.StatusForbidden, ReasonSourceNotAllowed},
+}
+
+// classifyError 返回错误对应的 HTTP 状态码和原因
+func classifyError(err error) (int, string) {
+	for _, e := range callbackErrors {
+		if errors.Is(err, e.err) {
+			return e.status, e.reason
+		}
+	}
+	return http.StatusInternalServerError, ReasonInternal
+}
+
+// fail 按错误类型记录日志并写出响应，op 为日志中的操作名称
+func (h *CallbackHandler) fail(w http.ResponseWriter, r *http.Request, q wework.CallbackQuery, op string, err error) {
+	ctx := r.Context()
+	status, reason := classifyError(err)
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("error.type", reason))
+	if h.onError != nil {
+		h.onError(reason)
+	}
+	if h.audit != nil && isSecurityReason(reason) {
+		h.audit(ctx, SecurityEvent{
+			Time:      time.Now(),
+			Reason:    reason,
+			Method:    r.Method,
+			Path:      r.URL.Path,
+			RemoteIP:  remoteIP(r),
+			UserAgent: r.UserAgent(),
+			Timestamp: q.Timestamp,
+			Nonce:     q.Nonce,
+			Error:     err.Error(),
+		})
+	}
+
+	switch reason {
+	case ReasonInvalidSignature:
+		h.logger.WarnContext(ctx, op+" signature failed",
+			"timestamp", q.Timestamp,
+			"nonce", q.Nonce,
+			"remote_ip", remoteIP(r),
+		)
+		// 签名失败计入限流，未启用限流时忽略
+		if h.limiter != nil {
+			h.limiter.record(ctx, remoteIP(r))
+		}
+	case ReasonSourceNotAllowed:
+		h.logger.WarnContext(ctx, op+" rejected, source not in allowlist", "remote_ip", remoteIP(r))
+	case ReasonStaleTimestamp, ReasonReplayedRequest:
+		h.logger.WarnContext(ctx, op+" replay rejected", "error", err, "nonce", q.Nonce)
+	case ReasonDecryptFailed:
+		h.logger.ErrorContext(ctx, op+" decrypt failed, check encoding_aes_key", "error", err)
+	case ReasonCorpMismatch:
+		h.logger.ErrorContext(ctx, op+" corp_id mismatch, check corp_id", "error", err)
+	case ReasonInternal:
+		h.logger.ErrorContext(ctx, op+" processing failed", "error", err)
+	default:
+		h.logger.WarnContext(ctx, op+" rejected", "reason", reason, "error", err)
+	}
+	if h.report != nil {
+		switch reason {
+		case ReasonDecryptFailed, ReasonCorpMismatch, ReasonInternal:
+			h.report(ctx, reason, err)
+		}
+	}
+
+	if status == http.StatusOK {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("success"))
+		return
+	}
+	http.Error(w, strings.ToLower(http.StatusText(status)), status)
+}
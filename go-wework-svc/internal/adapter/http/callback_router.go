@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+)
+
+// CallbackRouter 按路径参数 {name} 将回调分发到对应应用或租户的处理器
+type CallbackRouter struct {
+	handlers map[string]http.Handler
+	logger   *slog.Logger
+}
+
+// NewCallbackRouter 创建回调路由，需挂载在包含 {name} 路径参数的模式上，如 /callback/{name}
+func NewCallbackRouter(logger *slog.Logger) *CallbackRouter {
+	return &CallbackRouter{handlers: make(map[string]http.Handler), logger: logger}
+}
+
+// Register 注册名称对应的回调处理器
+func (cr *CallbackRouter) Register(name string, h http.Handler) {
+	cr.handlers[name] = h
+}
+
+// ServeHTTP 实现 http.Handler，未知名称返回 404
+func (cr *CallbackRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	h, ok := cr.handlers[name]
+	if !ok {
+		cr.logger.Warn("callback for unknown agent or tenant", "name", name, "remote_ip", remoteIP(r))
+		http.NotFound(w, r)
+		return
+	}
+	h.ServeHTTP(w, r)
+}
@@ -0,0 +1,186 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"go-wework-svc/internal/auth"
+	"go-wework-svc/internal/monitor"
+	"go-wework-svc/internal/wework"
+)
+
+const (
+	// consoleStatsInterval 推送管道状态的间隔，同时用于发现已断开的连接
+	consoleStatsInterval = 5 * time.Second
+	// consoleMaxFrame 客户端消息上限
+	consoleMaxFrame = 64 << 10
+)
+
+// ConsoleFrame /admin/ws 上的 JSON 消息，Type 决定其余字段
+// 服务端推送 event（实时事件）、stats（管道状态）、ack（请求结果）；客户端发送 reply（人工回复）
+type ConsoleFrame struct {
+	Type string `json:"type"`
+	// ID 客户端请求的标识，原样带回 ack
+	ID string `json:"id,omitempty"`
+
+	Event *DebugEvent     `json:"event,omitempty"`
+	Stats *monitor.Status `json:"stats,omitempty"`
+
+	// reply
+	Agent   string `json:"agent,omitempty"` // 发送应用，为空时使用默认应用
+	UserID  string `json:"user_id,omitempty"`
+	MsgType string `json:"msg_type,omitempty"` // text | markdown，默认 text
+	Content string `json:"content,omitempty"`
+
+	// ack
+	OK    bool   `json:"ok,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// 控制台消息类型
+const (
+	ConsoleFrameEvent = "event"
+	ConsoleFrameStats = "stats"
+	ConsoleFrameReply = "reply"
+	ConsoleFrameAck   = "ack"
+)
+
+// ConsoleHandler /admin/ws 运维控制台的 WebSocket 通道：实时消息、队列状态和人工回复
+type ConsoleHandler struct {
+	events  *DebugStream
+	monitor *monitor.Monitor
+	// senders 按应用名称索引，空字符串为默认应用
+	senders map[string]wework.Sender
+	logger  *slog.Logger
+}
+
+// NewConsoleHandler 创建控制台处理器
+func NewConsoleHandler(events *DebugStream, mon *monitor.Monitor, senders map[string]wework.Sender, logger *slog.Logger) *ConsoleHandler {
+	return &ConsoleHandler{events: events, monitor: mon, senders: senders, logger: logger}
+}
+
+// ServeHTTP 升级为 WebSocket 连接
+// 浏览器会话携带 Origin，必须与请求 Host 一致，防止跨站页面借用登录会话
+func (h *ConsoleHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	srv := websocket.Server{
+		Handshake: func(cfg *websocket.Config, r *http.Request) error {
+			if origin := r.Header.Get("Origin"); origin != "" {
+				u, err := url.Parse(origin)
+				if err != nil || u.Host != r.Host {
+					return websocket.ErrBadWebSocketOrigin
+				}
+			}
+			return nil
+		},
+		Handler: h.serve,
+	}
+	srv.ServeHTTP(w, r)
+}
+
+func (h *ConsoleHandler) serve(ws *websocket.Conn) {
+	defer ws.Close()
+	r := ws.Request()
+	id, _ := auth.IdentityFrom(r.Context())
+	ws.MaxPayloadBytes = consoleMaxFrame
+	// 连接已被接管，清除服务器设置的读写超时
+	ws.SetDeadline(time.Time{})
+
+	events, ok := h.events.subscribe()
+	if !ok {
+		websocket.JSON.Send(ws, ConsoleFrame{Type: ConsoleFrameAck, Error: "too many live event clients"})
+		return
+	}
+	defer h.events.unsubscribe(events)
+	h.logger.Info("admin console connected", "subject", id.Subject, "remote_ip", remoteIP(r))
+
+	// 读协程只解析客户端请求，所有写入都在当前协程完成
+	requests := make(chan ConsoleFrame)
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			var f ConsoleFrame
+			if err := websocket.JSON.Receive(ws, &f); err != nil {
+				return
+			}
+			select {
+			case requests <- f:
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}()
+
+	stats := time.NewTicker(consoleStatsInterval)
+	defer stats.Stop()
+	send := func(f ConsoleFrame) bool {
+		return websocket.JSON.Send(ws, f) == nil
+	}
+	snapshot := h.monitor.Snapshot()
+	if !send(ConsoleFrame{Type: ConsoleFrameStats, Stats: &snapshot}) {
+		return
+	}
+	for {
+		var out ConsoleFrame
+		select {
+		case ev := <-events:
+			out = ConsoleFrame{Type: ConsoleFrameEvent, Event: &ev}
+		case <-stats.C:
+			snapshot := h.monitor.Snapshot()
+			out = ConsoleFrame{Type: ConsoleFrameStats, Stats: &snapshot}
+		case f := <-requests:
+			out = h.handle(r, id, f)
+		case <-closed:
+			h.logger.Info("admin console disconnected", "subject", id.Subject)
+			return
+		case <-h.events.done:
+			return
+		}
+		if !send(out) {
+			return
+		}
+	}
+}
+
+// handle 处理客户端请求，返回 ack
+func (h *ConsoleHandler) handle(r *http.Request, id *auth.Identity, f ConsoleFrame) ConsoleFrame {
+	ack := ConsoleFrame{Type: ConsoleFrameAck, ID: f.ID}
+	if f.Type != ConsoleFrameReply {
+		ack.Error = "unknown frame type " + f.Type
+		return ack
+	}
+	// 连接只要求 viewer，发送消息与 POST /admin/messages 的要求相同
+	if !id.Role.Allows(auth.RoleOperator) || !id.HasScope(auth.ScopeMessagesSend) {
+		ack.Error = "forbidden"
+		return ack
+	}
+	msgType := f.MsgType
+	if msgType == "" {
+		msgType = wework.MsgTypeText
+	}
+	if f.UserID == "" || f.Content == "" {
+		ack.Error = "user_id and content are required"
+		return ack
+	}
+	if msgType != wework.MsgTypeText && msgType != wework.MsgTypeMarkdown {
+		ack.Error = "msg_type must be text or markdown"
+		return ack
+	}
+	sender, ok := h.senders[f.Agent]
+	if !ok {
+		ack.Error = "agent has no sender configured"
+		return ack
+	}
+	if err := sender.Send(r.Context(), wework.OutgoingMessage{ToUser: f.UserID, MsgType: msgType, Content: f.Content}); err != nil {
+		h.logger.Error("admin console reply failed", "agent", f.Agent, "user_id", f.UserID, "subject", id.Subject, "error", err)
+		ack.Error = "send failed: " + err.Error()
+		return ack
+	}
+	h.logger.Info("admin console reply sent", "agent", f.Agent, "user_id", f.UserID, "msg_type", msgType, "subject", id.Subject)
+	ack.OK = true
+	return ack
+}
@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+
+	"go-wework-svc/internal/monitor"
+)
+
+//go:embed web
+var webFS embed.FS
+
+// NewDashboardHandler 返回嵌入式管理端页面处理器，挂载于 /admin/
+// 页面本身不含数据，所有数据经鉴权后的管理端 API 获取
+func NewDashboardHandler() http.Handler {
+	sub, err := fs.Sub(webFS, "web")
+	if err != nil {
+		panic(err) // 嵌入目录在编译期确定
+	}
+	return http.StripPrefix("/admin/", http.FileServerFS(sub))
+}
+
+// StatusHandler 管理端管道状态接口
+type StatusHandler struct {
+	mon *monitor.Monitor
+}
+
+// NewStatusHandler 创建管道状态处理器
+func NewStatusHandler(mon *monitor.Monitor) *StatusHandler {
+	return &StatusHandler{mon: mon}
+}
+
+// ServeHTTP 以 JSON 返回当前管道状态快照
+func (h *StatusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.mon.Snapshot())
+}
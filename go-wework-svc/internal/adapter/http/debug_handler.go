@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+)
+
+// Pprof 返回 /debug/pprof/ 下的性能分析处理器
+// profile 和 trace 按 seconds 参数持续采样，因此取消服务器的写超时
+func Pprof() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.NewResponseController(w).SetWriteDeadline(time.Time{})
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// RuntimeStats GET /debug/runtime 响应
+type RuntimeStats struct {
+	Goroutines int         `json:"goroutines"`
+	NumCPU     int         `json:"num_cpu"`
+	GOMAXPROCS int         `json:"gomaxprocs"`
+	Memory     MemoryStats `json:"memory"`
+	GC         GCStats     `json:"gc"`
+}
+
+// MemoryStats 堆内存统计，单位字节
+type MemoryStats struct {
+	HeapAlloc   uint64 `json:"heap_alloc"`
+	HeapInuse   uint64 `json:"heap_inuse"`
+	HeapIdle    uint64 `json:"heap_idle"`
+	HeapObjects uint64 `json:"heap_objects"`
+	StackInuse  uint64 `json:"stack_inuse"`
+	Sys         uint64 `json:"sys"`
+	TotalAlloc  uint64 `json:"total_alloc"`
+}
+
+// GCStats 垃圾回收统计
+type GCStats struct {
+	NumGC         uint32     `json:"num_gc"`
+	NextGC        uint64     `json:"next_gc"`
+	LastGC        *time.Time `json:"last_gc,omitempty"`
+	LastPauseMs   float64    `json:"last_pause_ms"`
+	PauseTotalMs  float64    `json:"pause_total_ms"`
+	GCCPUFraction float64    `json:"gc_cpu_fraction"`
+}
+
+// RuntimeHandler 运行时诊断信息处理器
+type RuntimeHandler struct{}
+
+// NewRuntimeHandler 创建运行时诊断信息处理器
+func NewRuntimeHandler() *RuntimeHandler {
+	return &RuntimeHandler{}
+}
+
+// ServeHTTP 实现 http.Handler，ReadMemStats 会短暂 stop-the-world，勿高频调用
+func (h *RuntimeHandler) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	gc := GCStats{
+		NumGC:         m.NumGC,
+		NextGC:        m.NextGC,
+		PauseTotalMs:  float64(m.PauseTotalNs) / 1e6,
+		GCCPUFraction: m.GCCPUFraction,
+	}
+	if m.NumGC > 0 {
+		last := time.Unix(0, int64(m.LastGC))
+		gc.LastGC = &last
+		gc.LastPauseMs = float64(m.PauseNs[(m.NumGC+255)%256]) / 1e6
+	}
+
+	writeJSON(w, http.StatusOK, RuntimeStats{
+		Goroutines: runtime.NumGoroutine(),
+		NumCPU:     runtime.NumCPU(),
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+		Memory: MemoryStats{
+			HeapAlloc:   m.HeapAlloc,
+			HeapInuse:   m.HeapInuse,
+			HeapIdle:    m.HeapIdle,
+			HeapObjects: m.HeapObjects,
+			StackInuse:  m.StackInuse,
+			Sys:         m.Sys,
+			TotalAlloc:  m.TotalAlloc,
+		},
+		GC: gc,
+	})
+}
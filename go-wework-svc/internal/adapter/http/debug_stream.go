@@ -0,0 +1,249 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"go-wework-svc/internal/wework"
+)
+
+const (
+	// debugStreamBuffer 每个订阅者缓冲的事件数，消费过慢时丢弃
+	debugStreamBuffer = 256
+	// debugStreamMaxClients 同时订阅的客户端上限
+	debugStreamMaxClients = 8
+	// debugStreamHeartbeat SSE 心跳间隔，避免代理因空闲断开连接
+	debugStreamHeartbeat = 15 * time.Second
+	// debugStreamContentRunes 事件中消息正文保留的最大字符数
+	debugStreamContentRunes = 200
+)
+
+// 调试事件类型
+const (
+	DebugEventCallback = "callback" // 收到回调 HTTP 请求（含签名失败等被拒绝的请求）
+	DebugEventMessage  = "message"  // 解密后的入站消息
+	DebugEventOutcome  = "outcome"  // 消息处理结果
+	DebugEventForward  = "forward"  // AI 转发完成
+	DebugEventReply    = "reply"    // 发出的回复
+)
+
+// DebugEvent /debug/stream 推送的事件
+type DebugEvent struct {
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+
+	// callback
+	Method   string `json:"method,omitempty"`
+	Path     string `json:"path,omitempty"`
+	RemoteIP string `json:"remote_ip,omitempty"`
+	Status   int    `json:"status,omitempty"`
+
+	// message、outcome、forward、reply
+	Agent   string `json:"agent,omitempty"`
+	MsgID   string `json:"msg_id,omitempty"`
+	MsgType string `json:"msg_type,omitempty"`
+	UserID  string `json:"user_id,omitempty"`
+	ChatID  string `json:"chat_id,omitempty"`
+	Content string `json:"content,omitempty"` // 脱敏并截断后的正文或回复
+	Outcome string `json:"outcome,omitempty"`
+	Error   string `json:"error,omitempty"`
+
+	LatencyMs int64 `json:"latency_ms,omitempty"`
+}
+
+// DebugStream 实时调试事件流，实现 wework.Observer 和 wework.Archive，供 /debug/stream 和 /admin/ws 订阅
+// 没有订阅者时不产生任何开销；消息正文经 sanitize 脱敏后截断，媒体 ID 和链接不推送
+type DebugStream struct {
+	sanitize func(string) string
+	logger   *slog.Logger
+
+	mu   sync.Mutex
+	subs map[chan DebugEvent]struct{}
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewDebugStream 创建调试事件流，sanitize 用于消息正文脱敏，可为 nil
+func NewDebugStream(sanitize func(string) string, logger *slog.Logger) *DebugStream {
+	return &DebugStream{sanitize: sanitize, logger: logger, subs: make(map[chan DebugEvent]struct{}), done: make(chan struct{})}
+}
+
+// Close 结束所有订阅连接，注册为 http.Server 的 RegisterOnShutdown，避免长连接拖慢优雅退出
+func (s *DebugStream) Close() {
+	s.closeOnce.Do(func() { close(s.done) })
+}
+
+// ObserveCallback 记录一次回调 HTTP 请求，用作 WithRequestObserver 的回调
+func (s *DebugStream) ObserveCallback(r *http.Request, status int, elapsed time.Duration) {
+	if !s.active() {
+		return
+	}
+	s.publish(DebugEvent{
+		Type:      DebugEventCallback,
+		Method:    r.Method,
+		Path:      r.URL.Path,
+		RemoteIP:  remoteIP(r),
+		Status:    status,
+		LatencyMs: elapsed.Milliseconds(),
+	})
+}
+
+// Inbound 实现 wework.Archive
+func (s *DebugStream) Inbound(_ context.Context, agent string, msg wework.Message) {
+	if !s.active() {
+		return
+	}
+	s.publish(DebugEvent{
+		Type:    DebugEventMessage,
+		Agent:   agent,
+		MsgID:   msg.MsgID,
+		MsgType: msg.MsgType,
+		UserID:  msg.FromUserName,
+		ChatID:  msg.ChatID,
+		Content: s.clean(msg.Content),
+	})
+}
+
+// Outbound 实现 wework.Archive
+func (s *DebugStream) Outbound(_ context.Context, agent string, msg wework.Message, reply string) {
+	if !s.active() {
+		return
+	}
+	s.publish(DebugEvent{
+		Type:    DebugEventReply,
+		Agent:   agent,
+		MsgID:   msg.MsgID,
+		UserID:  msg.FromUserName,
+		ChatID:  msg.ChatID,
+		Content: s.clean(reply),
+	})
+}
+
+// OnMessage 实现 wework.Observer
+func (s *DebugStream) OnMessage(_ context.Context, msg wework.Message, outcome string) {
+	if !s.active() {
+		return
+	}
+	s.publish(DebugEvent{
+		Type:    DebugEventOutcome,
+		MsgID:   msg.MsgID,
+		MsgType: msg.MsgType,
+		UserID:  msg.FromUserName,
+		Outcome: outcome,
+	})
+}
+
+// OnForwardDone 实现 wework.Observer
+func (s *DebugStream) OnForwardDone(_ context.Context, msg wework.Message, latency time.Duration, err error) {
+	if !s.active() {
+		return
+	}
+	ev := DebugEvent{
+		Type:      DebugEventForward,
+		MsgID:     msg.MsgID,
+		UserID:    msg.FromUserName,
+		LatencyMs: latency.Milliseconds(),
+	}
+	if err != nil {
+		ev.Error = err.Error()
+	}
+	s.publish(ev)
+}
+
+// clean 脱敏并截断正文
+func (s *DebugStream) clean(content string) string {
+	if s.sanitize != nil {
+		content = s.sanitize(content)
+	}
+	if r := []rune(content); len(r) > debugStreamContentRunes {
+		content = string(r[:debugStreamContentRunes]) + "…"
+	}
+	return content
+}
+
+func (s *DebugStream) active() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.subs) > 0
+}
+
+func (s *DebugStream) publish(ev DebugEvent) {
+	ev.Time = time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.subs {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// subscribe 注册订阅者，超过客户端上限时返回 false
+func (s *DebugStream) subscribe() (chan DebugEvent, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.subs) >= debugStreamMaxClients {
+		return nil, false
+	}
+	ch := make(chan DebugEvent, debugStreamBuffer)
+	s.subs[ch] = struct{}{}
+	return ch, true
+}
+
+func (s *DebugStream) unsubscribe(ch chan DebugEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.subs, ch)
+}
+
+// ServeHTTP GET /debug/stream 以 Server-Sent Events 推送调试事件，直到客户端断开
+func (s *DebugStream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+	ch, ok := s.subscribe()
+	if !ok {
+		http.Error(w, "too many debug stream clients", http.StatusServiceUnavailable)
+		return
+	}
+	defer s.unsubscribe(ch)
+
+	// 长连接不受服务器写超时限制
+	rc.SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	if err := rc.Flush(); err != nil {
+		s.logger.Warn("debug stream flush unsupported", "error", err)
+		return
+	}
+
+	heartbeat := time.NewTicker(debugStreamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case ev := <-ch:
+			data, err := json.Marshal(ev)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, data)
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case <-r.Context().Done():
+			return
+		case <-s.done:
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-wework-svc/internal/auth"
+	"go-wework-svc/internal/outbox"
+)
+
+// DLQHandler /admin/dlq 失败消息管理接口：查看、重新入队和清除 outbox 记录
+// 重新入队只重置重投状态，由后台重投器在下一轮按 ID 顺序投递
+type DLQHandler struct {
+	store  outbox.Store
+	logger *slog.Logger
+}
+
+// NewDLQHandler 创建失败消息管理处理器
+func NewDLQHandler(store outbox.Store, logger *slog.Logger) *DLQHandler {
+	return &DLQHandler{store: store, logger: logger}
+}
+
+// HandleList GET /admin/dlq?limit=N&cursor=ID&dead=true|false 按 ID 升序返回 ID 大于 cursor 的记录，dead 为空时返回全部
+// 还有后续记录时响应中的 next_cursor 为下一页的 cursor
+func (h *DLQHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var cursor uint64
+	if v := q.Get("cursor"); v != "" {
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid cursor", http.StatusBadRequest)
+			return
+		}
+		cursor = n
+	}
+	limit := 50
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	dead, filter, ok := parseDeadFilter(q.Get("dead"))
+	if !ok {
+		http.Error(w, "invalid dead", http.StatusBadRequest)
+		return
+	}
+	total, err := h.store.Len(r.Context())
+	if err != nil {
+		h.logger.Error("failed to count outbox entries", "error", err)
+		http.Error(w, "list failed", http.StatusInternalServerError)
+		return
+	}
+	// 按 dead 过滤时逐页读取直到凑满 limit，多读一条用于判断是否还有下一页
+	items := make([]outbox.Entry, 0, limit)
+	more := false
+	for !more {
+		page, err := h.store.List(r.Context(), cursor, limit+1)
+		if err != nil {
+			h.logger.Error("failed to list outbox entries", "error", err)
+			http.Error(w, "list failed", http.StatusInternalServerError)
+			return
+		}
+		for _, e := range page {
+			if filter && e.Dead != dead {
+				cursor = e.ID
+				continue
+			}
+			if len(items) == limit {
+				more = true
+				break
+			}
+			items = append(items, e)
+			cursor = e.ID
+		}
+		if len(page) <= limit {
+			break
+		}
+	}
+	resp := map[string]any{"items": items, "total": total}
+	if more {
+		resp["next_cursor"] = strconv.FormatUint(items[len(items)-1].ID, 10)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// HandleGet GET /admin/dlq/{id} 返回单条记录，包含完整的 AI 请求
+func (h *DLQHandler) HandleGet(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseEntryID(w, r)
+	if !ok {
+		return
+	}
+	e, err := h.store.Get(r.Context(), id)
+	if err != nil {
+		h.writeStoreError(w, id, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, e)
+}
+
+// HandleRequeue POST /admin/dlq/{id}/requeue 清除重投次数和死信标记
+func (h *DLQHandler) HandleRequeue(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseEntryID(w, r)
+	if !ok {
+		return
+	}
+	e, err := h.store.Get(r.Context(), id)
+	if err != nil {
+		h.writeStoreError(w, id, err)
+		return
+	}
+	requeue(e)
+	if err := h.store.Update(r.Context(), *e); err != nil {
+		h.writeStoreError(w, id, err)
+		return
+	}
+	h.logger.Info("outbox entry requeued", "id", id, "msg_id", e.MsgID, "subject", subjectOf(r))
+	writeJSON(w, http.StatusOK, e)
+}
+
+// HandleRequeueDead POST /admin/dlq/requeue 将所有死信记录重新入队，AI 后端恢复后批量恢复用
+func (h *DLQHandler) HandleRequeueDead(w http.ResponseWriter, r *http.Request) {
+	entries, err := h.store.List(r.Context(), 0, 0)
+	if err != nil {
+		h.logger.Error("failed to list outbox entries", "error", err)
+		http.Error(w, "list failed", http.StatusInternalServerError)
+		return
+	}
+	n := 0
+	for _, e := range entries {
+		if !e.Dead {
+			continue
+		}
+		requeue(&e)
+		// 记录可能已被重投器删除，跳过即可
+		if err := h.store.Update(r.Context(), e); err != nil && !errors.Is(err, outbox.ErrNotFound) {
+			h.logger.Error("failed to requeue outbox entry", "id", e.ID, "error", err)
+			http.Error(w, "requeue failed", http.StatusInternalServerError)
+			return
+		}
+		n++
+	}
+	h.logger.Info("outbox dead entries requeued", "count", n, "subject", subjectOf(r))
+	writeJSON(w, http.StatusOK, map[string]int{"requeued": n})
+}
+
+// HandleDelete DELETE /admin/dlq/{id} 删除单条记录，消息不再投递
+func (h *DLQHandler) HandleDelete(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseEntryID(w, r)
+	if !ok {
+		return
+	}
+	if err := h.store.Delete(r.Context(), id); err != nil {
+		h.writeStoreError(w, id, err)
+		return
+	}
+	h.logger.Info("outbox entry deleted", "id", id, "subject", subjectOf(r))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandlePurge DELETE /admin/dlq?dead=true|false 批量删除记录，默认只删除死信，dead=false 删除待重投的记录
+func (h *DLQHandler) HandlePurge(w http.ResponseWriter, r *http.Request) {
+	dead, filter, ok := parseDeadFilter(r.URL.Query().Get("dead"))
+	if !ok {
+		http.Error(w, "invalid dead", http.StatusBadRequest)
+		return
+	}
+	if !filter {
+		dead = true
+	}
+	entries, err := h.store.List(r.Context(), 0, 0)
+	if err != nil {
+		h.logger.Error("failed to list outbox entries", "error", err)
+		http.Error(w, "list failed", http.StatusInternalServerError)
+		return
+	}
+	n := 0
+	for _, e := range entries {
+		if e.Dead != dead {
+			continue
+		}
+		if err := h.store.Delete(r.Context(), e.ID); err != nil && !errors.Is(err, outbox.ErrNotFound) {
+			h.logger.Error("failed to delete outbox entry", "id", e.ID, "error", err)
+			http.Error(w, "purge failed", http.StatusInternalServerError)
+			return
+		}
+		n++
+	}
+	h.logger.Info("outbox entries purged", "dead", dead, "count", n, "subject", subjectOf(r))
+	writeJSON(w, http.StatusOK, map[string]int{"purged": n})
+}
+
+func (h *DLQHandler) writeStoreError(w http.ResponseWriter, id uint64, err error) {
+	if errors.Is(err, outbox.ErrNotFound) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	h.logger.Error("outbox store error", "id", id, "error", err)
+	http.Error(w, "store error", http.StatusInternalServerError)
+}
+
+// requeue 重置重投状态，保留 LastError 便于排查
+func requeue(e *outbox.Entry) {
+	e.Dead = false
+	e.Attempts = 0
+	e.LastAttemptAt = time.Time{}
+}
+
+func parseEntryID(w http.ResponseWriter, r *http.Request) (uint64, bool) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// parseDeadFilter 解析 dead 查询参数，返回值依次为过滤值、是否过滤、是否合法
+func parseDeadFilter(v string) (dead, filter, ok bool) {
+	if v == "" {
+		return false, false, true
+	}
+	dead, err := strconv.ParseBool(v)
+	return dead, true, err == nil
+}
+
+func subjectOf(r *http.Request) string {
+	if id, ok := auth.IdentityFrom(r.Context()); ok {
+		return id.Subject
+	}
+	return ""
+}
@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-wework-svc/internal/store"
+)
+
+// failureLimiter 按来源 IP 统计签名失败次数，超过上限后在窗口期内直接拒绝该 IP 的请求
+type failureLimiter struct {
+	kv     store.Store
+	limit  int64
+	window time.Duration
+	logger *slog.Logger
+}
+
+// blocked 返回该 IP 当前是否已超过失败上限，存储异常时放行
+func (l *failureLimiter) blocked(ctx context.Context, ip string) bool {
+	v, ok, err := l.kv.Get(ctx, l.key(ip))
+	if err != nil {
+		l.logger.Warn("signature failure limiter get failed", "error", err)
+		return false
+	}
+	if !ok {
+		return false
+	}
+	n, _ := strconv.ParseInt(v, 10, 64)
+	return n >= l.limit
+}
+
+// record 记录一次签名失败，首次达到上限时输出告警日志
+func (l *failureLimiter) record(ctx context.Context, ip string) {
+	n, err := l.kv.Incr(ctx, l.key(ip), l.window)
+	if err != nil {
+		l.logger.Warn("signature failure limiter incr failed", "error", err)
+		return
+	}
+	if n == l.limit {
+		l.logger.Warn("signature failures exceeded, throttling source",
+			"remote_ip", ip,
+			"failures", n,
+			"window", l.window,
+		)
+	}
+}
+
+func (l *failureLimiter) key(ip string) string {
+	return "sigfail:" + ip
+}
+
+// remoteIP 提取请求来源 IP，不直接读取 X-Forwarded-For 以免被伪造绕过限流
+// 来自可信代理的请求已由 TrustedProxies 将客户端地址写入 RemoteAddr
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
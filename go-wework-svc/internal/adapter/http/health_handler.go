@@ -1,13 +1,38 @@
 package handler
 
-import "net/http"
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// defaultReadinessTimeout 单次就绪检查中每个依赖的超时
+const defaultReadinessTimeout = 2 * time.Second
+
+// CheckFunc 依赖检查，返回 nil 表示可用
+type CheckFunc func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	check CheckFunc
+}
 
 // HealthHandler 健康检查处理器
-type HealthHandler struct{}
+// 存活检查只表示进程可响应；就绪检查并发探测各依赖，任一失败返回 503
+type HealthHandler struct {
+	checks  []namedCheck
+	timeout time.Duration
+}
 
 // NewHealthHandler 创建健康检查处理器
 func NewHealthHandler() *HealthHandler {
-	return &HealthHandler{}
+	return &HealthHandler{timeout: defaultReadinessTimeout}
+}
+
+// AddCheck 注册就绪检查依赖，需在开始处理请求前调用
+func (h *HealthHandler) AddCheck(name string, check CheckFunc) {
+	h.checks = append(h.checks, namedCheck{name: name, check: check})
 }
 
 // ServeHTTP 返回 HTTP 200 表示服务正常
@@ -15,3 +40,52 @@ func (h *HealthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("ok"))
 }
+
+// CheckResult 单个依赖的检查结果
+type CheckResult struct {
+	Status    string `json:"status"` // ok | error
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ReadinessResponse GET /readyz 响应
+type ReadinessResponse struct {
+	Status string                 `json:"status"` // ok | unavailable
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+// Ready 处理 GET /readyz
+func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+
+	resp := ReadinessResponse{Status: "ok", Checks: make(map[string]CheckResult, len(h.checks))}
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for _, c := range h.checks {
+		wg.Go(func() {
+			start := time.Now()
+			err := c.check(ctx)
+			res := CheckResult{Status: "ok", LatencyMs: time.Since(start).Milliseconds()}
+			if err != nil {
+				res.Status = "error"
+				res.Error = err.Error()
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			resp.Checks[c.name] = res
+			if err != nil {
+				resp.Status = "unavailable"
+			}
+		})
+	}
+	wg.Wait()
+
+	status := http.StatusOK
+	if resp.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, resp)
+}
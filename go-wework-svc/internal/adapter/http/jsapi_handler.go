@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"go-wework-svc/internal/wework/token"
+)
+
+// JSAPISigner 一个应用的 JS-SDK 签名凭证
+type JSAPISigner struct {
+	CorpID      string
+	AgentID     int64
+	CorpTicket  token.TokenProvider // 企业 jsapi_ticket，用于 wx.config
+	AgentTicket token.TokenProvider // 应用 jsapi_ticket，用于 wx.agentConfig
+	// AllowedHosts 允许签名的页面域名，同时作为跨域请求允许的来源
+	AllowedHosts []string
+}
+
+// JSAPISignature GET /jsapi/signature 的响应，wx.config 使用 signature，wx.agentConfig 使用 agent_signature
+type JSAPISignature struct {
+	CorpID         string `json:"corp_id"`
+	AgentID        int64  `json:"agent_id"`
+	Timestamp      int64  `json:"timestamp"`
+	NonceStr       string `json:"nonce_str"`
+	Signature      string `json:"signature"`
+	AgentSignature string `json:"agent_signature"`
+}
+
+// JSAPIHandler 为企业微信内嵌的 H5 页面计算 JS-SDK 签名
+type JSAPIHandler struct {
+	// signers 按应用名称索引，空字符串为默认应用
+	signers map[string]JSAPISigner
+	logger  *slog.Logger
+}
+
+// NewJSAPIHandler 创建 JS-SDK 签名处理器
+func NewJSAPIHandler(signers map[string]JSAPISigner, logger *slog.Logger) *JSAPIHandler {
+	return &JSAPIHandler{signers: signers, logger: logger}
+}
+
+// ServeHTTP GET /jsapi/signature?url=...&agent=name 返回页面 url 的签名，agent 为空时使用默认应用
+// url 须为 allowed_hosts 中域名的 http(s) 地址，# 及其后部分不参与签名
+func (h *JSAPIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	agent := q.Get("agent")
+	signer, ok := h.signers[agent]
+	if !ok {
+		http.Error(w, fmt.Sprintf("agent %q has no jsapi configured", agent), http.StatusNotFound)
+		return
+	}
+	if origin := r.Header.Get("Origin"); origin != "" {
+		if u, err := url.Parse(origin); err == nil && slices.Contains(signer.AllowedHosts, u.Hostname()) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		}
+		w.Header().Add("Vary", "Origin")
+	}
+
+	page, _, _ := strings.Cut(q.Get("url"), "#")
+	u, err := url.Parse(page)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		http.Error(w, "url must be an absolute http(s) url", http.StatusBadRequest)
+		return
+	}
+	if !slices.Contains(signer.AllowedHosts, u.Hostname()) {
+		http.Error(w, fmt.Sprintf("host %q is not allowed", u.Hostname()), http.StatusForbidden)
+		return
+	}
+
+	corpTicket, err := signer.CorpTicket.Token(r.Context())
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to get jsapi ticket", "agent", agent, "error", err)
+		http.Error(w, "get jsapi ticket failed", http.StatusBadGateway)
+		return
+	}
+	agentTicket, err := signer.AgentTicket.Token(r.Context())
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to get agent jsapi ticket", "agent", agent, "error", err)
+		http.Error(w, "get agent jsapi ticket failed", http.StatusBadGateway)
+		return
+	}
+
+	b := make([]byte, 8)
+	rand.Read(b)
+	sig := JSAPISignature{
+		CorpID:    signer.CorpID,
+		AgentID:   signer.AgentID,
+		Timestamp: time.Now().Unix(),
+		NonceStr:  hex.EncodeToString(b),
+	}
+	sig.Signature = token.JSAPISignature(corpTicket, sig.NonceStr, sig.Timestamp, page)
+	sig.AgentSignature = token.JSAPISignature(agentTicket, sig.NonceStr, sig.Timestamp, page)
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, sig)
+}
@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"go-wework-svc/internal/auth"
+)
+
+// LogLevelHandler 运行时查看和调整日志级别
+// SIGHUP 热加载会恢复为配置文件中的 log.level
+type LogLevelHandler struct {
+	level  *slog.LevelVar
+	logger *slog.Logger
+}
+
+// NewLogLevelHandler 创建日志级别处理器
+func NewLogLevelHandler(level *slog.LevelVar, logger *slog.Logger) *LogLevelHandler {
+	return &LogLevelHandler{level: level, logger: logger}
+}
+
+// logLevelBody GET / PUT /admin/log-level 请求和响应体
+type logLevelBody struct {
+	Level string `json:"level"` // debug | info | warn | error
+}
+
+// HandleGet GET /admin/log-level
+func (h *LogLevelHandler) HandleGet(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, logLevelBody{Level: levelName(h.level.Level())})
+}
+
+// HandlePut PUT /admin/log-level
+func (h *LogLevelHandler) HandlePut(w http.ResponseWriter, r *http.Request) {
+	var req logLevelBody
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<10)).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(req.Level)); err != nil {
+		http.Error(w, "level must be debug, info, warn or error", http.StatusBadRequest)
+		return
+	}
+
+	var subject string
+	if id, ok := auth.IdentityFrom(r.Context()); ok {
+		subject = id.Subject
+	}
+	previous := h.level.Level()
+	h.level.Set(level)
+	// 以 warn 记录，调高级别后仍可见
+	h.logger.WarnContext(r.Context(), "log level changed",
+		"from", levelName(previous),
+		"to", levelName(level),
+		"subject", subject,
+	)
+	writeJSON(w, http.StatusOK, logLevelBody{Level: levelName(level)})
+}
+
+func levelName(l slog.Level) string {
+	return strings.ToLower(l.String())
+}
@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"go-wework-svc/internal/auth"
+	"go-wework-svc/internal/wework"
+)
+
+// maxMessageBody 主动消息请求体上限
+const maxMessageBody = 64 << 10
+
+// MessageHandler 主动消息发送接口，供运维脚本和其他系统推送通知
+type MessageHandler struct {
+	// senders 按应用名称索引，空字符串为默认应用
+	senders map[string]wework.Sender
+	logger  *slog.Logger
+}
+
+// NewMessageHandler 创建主动消息处理器
+func NewMessageHandler(senders map[string]wework.Sender, logger *slog.Logger) *MessageHandler {
+	return &MessageHandler{senders: senders, logger: logger}
+}
+
+// sendMessageRequest POST /admin/messages 请求体，接收者至少填写一项
+type sendMessageRequest struct {
+	Agent   string   `json:"agent"` // 发送应用，为空时使用默认应用
+	ToUser  []string `json:"touser"`
+	ToParty []string `json:"toparty"`
+	ToTag   []string `json:"totag"`
+	MsgType string   `json:"msgtype"` // text | markdown | textcard | template_card | image | voice | file
+	Content string   `json:"content"`
+
+	// textcard
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	URL         string `json:"url"`
+	BtnTxt      string `json:"btntxt"`
+
+	// template_card，结构与 message/send API 的 template_card 字段一致
+	TemplateCard *wework.TemplateCard `json:"template_card"`
+
+	// image、voice、file
+	MediaID string `json:"media_id"`
+}
+
+// toOutgoing 校验请求并转换为 wework.OutgoingMessage
+func (req sendMessageRequest) toOutgoing() (wework.OutgoingMessage, error) {
+	msg := wework.OutgoingMessage{
+		ToUser:      strings.Join(req.ToUser, "|"),
+		ToParty:     strings.Join(req.ToParty, "|"),
+		ToTag:       strings.Join(req.ToTag, "|"),
+		MsgType:     req.MsgType,
+		Content:     req.Content,
+		Title:       req.Title,
+		Description: req.Description,
+		URL:         req.URL,
+		BtnTxt:      req.BtnTxt,
+		Card:        req.TemplateCard,
+		MediaID:     req.MediaID,
+	}
+	if msg.ToUser == "" && msg.ToParty == "" && msg.ToTag == "" {
+		return msg, errors.New("at least one of touser, toparty, totag is required")
+	}
+	switch req.MsgType {
+	case wework.MsgTypeText, wework.MsgTypeMarkdown:
+		if req.Content == "" {
+			return msg, errors.New("content is required")
+		}
+	case wework.MsgTypeTextCard:
+		if req.Title == "" || req.Description == "" || req.URL == "" {
+			return msg, errors.New("title, description and url are required for textcard")
+		}
+	case wework.MsgTypeTemplateCard:
+		if req.TemplateCard == nil {
+			return msg, errors.New("template_card is required")
+		}
+		if err := req.TemplateCard.Validate(); err != nil {
+			return msg, fmt.Errorf("template_card: %w", err)
+		}
+	case wework.MsgTypeImage, wework.MsgTypeVoice, wework.MsgTypeFile:
+		if req.MediaID == "" {
+			return msg, fmt.Errorf("media_id is required for %s", req.MsgType)
+		}
+	default:
+		return msg, fmt.Errorf("msgtype must be text, markdown, textcard, template_card, image, voice or file, got %q", req.MsgType)
+	}
+	return msg, nil
+}
+
+// ServeHTTP POST /admin/messages 通过 message/send API 发送应用消息
+func (h *MessageHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req sendMessageRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxMessageBody)).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	msg, err := req.toOutgoing()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sender, ok := h.senders[req.Agent]
+	if !ok {
+		http.Error(w, fmt.Sprintf("agent %q has no sender configured", req.Agent), http.StatusNotFound)
+		return
+	}
+
+	var subject string
+	if id, ok := auth.IdentityFrom(r.Context()); ok {
+		subject = id.Subject
+	}
+	if err := sender.Send(r.Context(), msg); err != nil {
+		h.logger.Error("admin message send failed", "agent", req.Agent, "msg_type", msg.MsgType, "subject", subject, "error", err)
+		http.Error(w, "send failed: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	h.logger.Info("admin message sent",
+		"agent", req.Agent,
+		"msg_type", msg.MsgType,
+		"to_user", msg.ToUser,
+		"to_party", msg.ToParty,
+		"to_tag", msg.ToTag,
+		"subject", subject,
+	)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "sent"})
+}
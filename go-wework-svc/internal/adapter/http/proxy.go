@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+type schemeKey struct{}
+
+// TrustedProxies 直连地址属于可信代理时，按 X-Forwarded-For 还原客户端地址并记录 X-Forwarded-Proto
+// X-Forwarded-For 从右向左跳过可信代理，取第一个不可信的地址，客户端自行添加的条目不会被采用
+// 还原后的地址写入 RemoteAddr，访问日志、签名失败限流和安全审计随之使用客户端地址
+// 监听 unix 套接字时对端只能是本机的反向代理，配置了可信代理即视为可信
+func TrustedProxies(next http.Handler, proxies []netip.Prefix) http.Handler {
+	if len(proxies) == 0 {
+		return next
+	}
+	trusted := func(ip string) bool {
+		addr, err := netip.ParseAddr(ip)
+		if err != nil {
+			return false
+		}
+		addr = addr.Unmap()
+		for _, p := range proxies {
+			if p.Contains(addr) {
+				return true
+			}
+		}
+		return false
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !unixPeer(r) && !trusted(remoteIP(r)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		r2 := r.Clone(r.Context())
+		if client := forwardedFor(r.Header.Values("X-Forwarded-For"), trusted); client != "" {
+			r2.RemoteAddr = net.JoinHostPort(client, "0")
+		}
+		if proto := strings.ToLower(strings.TrimSpace(r.Header.Get("X-Forwarded-Proto"))); proto == "http" || proto == "https" {
+			r2 = r2.WithContext(context.WithValue(r2.Context(), schemeKey{}, proto))
+		}
+		next.ServeHTTP(w, r2)
+	})
+}
+
+// unixPeer 请求是否经 unix 套接字到达
+func unixPeer(r *http.Request) bool {
+	addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	return ok && addr.Network() == "unix"
+}
+
+// forwardedFor 返回 X-Forwarded-For 中最右侧的非可信地址，全部可信时返回最左侧地址
+func forwardedFor(values []string, trusted func(string) bool) string {
+	var hops []string
+	for _, v := range values {
+		for _, h := range strings.Split(v, ",") {
+			if h = strings.TrimSpace(h); h != "" {
+				hops = append(hops, h)
+			}
+		}
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		if _, err := netip.ParseAddr(hops[i]); err != nil {
+			// 无法解析的条目之前的内容不可信
+			return ""
+		}
+		if !trusted(hops[i]) || i == 0 {
+			return hops[i]
+		}
+	}
+	return ""
+}
+
+// requestScheme 返回请求的协议，来自可信代理时以 X-Forwarded-Proto 为准
+func requestScheme(r *http.Request) string {
+	if s, ok := r.Context().Value(schemeKey{}).(string); ok {
+		return s
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// BasePath 去掉路径前缀后交给 next，前缀之外的请求返回 404；探针路径在根路径下同样可用，便于容器健康检查
+func BasePath(next http.Handler, prefix string) http.Handler {
+	if prefix == "" {
+		return next
+	}
+	strip := http.StripPrefix(prefix, next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == prefix:
+			http.Redirect(w, r, prefix+"/", http.StatusMovedPermanently)
+		case strings.HasPrefix(r.URL.Path, prefix+"/"):
+			strip.ServeHTTP(w, r)
+		case probePaths[r.URL.Path]:
+			next.ServeHTTP(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+)
+
+// Recover 恢复处理器中的 panic，记录堆栈和请求信息后返回 500，onPanic 可为 nil
+// http.ErrAbortHandler 按标准库约定继续向上抛出
+func Recover(next http.Handler, logger *slog.Logger, onPanic func(r *http.Request, v any, stack []byte)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w}
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			stack := debug.Stack()
+			logger.ErrorContext(r.Context(), "panic recovered in http handler",
+				"method", r.Method,
+				"path", r.URL.Path,
+				"remote_ip", remoteIP(r),
+				"panic", fmt.Sprint(v),
+				"stack", string(stack),
+			)
+			if onPanic != nil {
+				onPanic(r, v, stack)
+			}
+			// 已写出响应头时无法再修改状态码
+			if rec.status == 0 {
+				http.Error(rec, "internal server error", http.StatusInternalServerError)
+			}
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
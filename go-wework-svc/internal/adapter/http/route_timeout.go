@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RouteTimeout 路由前缀的处理超时，Timeout 为 0 时不限制
+type RouteTimeout struct {
+	Prefix  string
+	Timeout time.Duration
+}
+
+// RouteTimeouts 按最长前缀匹配为请求设置处理超时：context 到期取消，响应写入截止时间同步调整
+// 与 http.TimeoutHandler 不同，不缓冲响应，WebSocket 和 SSE 仍可 Hijack / Flush
+// 未匹配的路由沿用服务器的 write_timeout
+func RouteTimeouts(next http.Handler, routes []RouteTimeout) http.Handler {
+	if len(routes) == 0 {
+		return next
+	}
+	routes = append([]RouteTimeout(nil), routes...)
+	sort.Slice(routes, func(i, j int) bool { return len(routes[i].Prefix) > len(routes[j].Prefix) })
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeout, ok := matchTimeout(routes, r.URL.Path)
+		if !ok || timeout <= 0 {
+			if ok {
+				// 长连接路由不限制写入时间，由处理器自行管理
+				http.NewResponseController(w).SetWriteDeadline(time.Time{})
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		// 写入截止时间留出余量，使超时后的错误响应仍能写出
+		http.NewResponseController(w).SetWriteDeadline(time.Now().Add(timeout + time.Second))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// matchTimeout 返回最长匹配前缀的超时，prefix 匹配自身及其子路径
+func matchTimeout(routes []RouteTimeout, path string) (time.Duration, bool) {
+	for _, rt := range routes {
+		p := strings.TrimSuffix(rt.Prefix, "/")
+		if path == p || strings.HasPrefix(path, p+"/") {
+			return rt.Timeout, true
+		}
+	}
+	return 0, false
+}
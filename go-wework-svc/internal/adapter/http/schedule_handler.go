@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"go-wework-svc/internal/schedule"
+)
+
+// maxScheduleBody 定时消息请求体上限
+const maxScheduleBody = 64 << 10
+
+// ScheduleHandler /admin/schedules 定时消息管理接口：查看、创建、修改、删除和立即发送
+// 配置中 schedule.jobs 定义的定时消息只读
+type ScheduleHandler struct {
+	scheduler *schedule.Scheduler
+	logger    *slog.Logger
+}
+
+// NewScheduleHandler 创建定时消息管理处理器
+func NewScheduleHandler(scheduler *schedule.Scheduler, logger *slog.Logger) *ScheduleHandler {
+	return &ScheduleHandler{scheduler: scheduler, logger: logger}
+}
+
+// scheduleRequest PUT /admin/schedules/{name} 请求体，名称取自路径
+type scheduleRequest struct {
+	Cron     string   `json:"cron"`
+	Timezone string   `json:"timezone"`
+	Agent    string   `json:"agent"`
+	ToUser   []string `json:"touser"`
+	ToParty  []string `json:"toparty"`
+	ToTag    []string `json:"totag"`
+	Webhooks []string `json:"webhooks"`
+	MsgType  string   `json:"msgtype"` // text（默认）| markdown
+	Content  string   `json:"content"`
+	Paused   bool     `json:"paused"`
+}
+
+// HandleList GET /admin/schedules 按名称升序返回全部定时消息
+func (h *ScheduleHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	list, err := h.scheduler.List()
+	if err != nil {
+		h.logger.Error("failed to list schedules", "error", err)
+		http.Error(w, "list failed", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"items": list, "total": len(list)})
+}
+
+// HandleGet GET /admin/schedules/{name} 返回单条定时消息及其运行状态
+func (h *ScheduleHandler) HandleGet(w http.ResponseWriter, r *http.Request) {
+	sc, err := h.scheduler.Get(r.PathValue("name"))
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, sc)
+}
+
+// HandlePut PUT /admin/schedules/{name} 创建或覆盖定时消息，修改后从当前时间起重新计算下次触发时间
+func (h *ScheduleHandler) HandlePut(w http.ResponseWriter, r *http.Request) {
+	var req scheduleRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxScheduleBody)).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	sc, err := h.scheduler.Put(schedule.Schedule{
+		Name:     r.PathValue("name"),
+		Cron:     req.Cron,
+		Timezone: req.Timezone,
+		Agent:    req.Agent,
+		ToUser:   req.ToUser,
+		ToParty:  req.ToParty,
+		ToTag:    req.ToTag,
+		Webhooks: req.Webhooks,
+		MsgType:  req.MsgType,
+		Content:  req.Content,
+		Paused:   req.Paused,
+	})
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	h.logger.Info("schedule saved", "schedule", sc.Name, "cron", sc.Cron, "paused", sc.Paused, "subject", subjectOf(r))
+	writeJSON(w, http.StatusOK, sc)
+}
+
+// HandleDelete DELETE /admin/schedules/{name} 删除定时消息
+func (h *ScheduleHandler) HandleDelete(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if err := h.scheduler.Delete(name); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	h.logger.Info("schedule deleted", "schedule", name, "subject", subjectOf(r))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleRun POST /admin/schedules/{name}/run 立即发送一次，用于确认接收者和内容，不影响下次触发时间
+func (h *ScheduleHandler) HandleRun(w http.ResponseWriter, r *http.Request) {
+	sc, err := h.scheduler.Trigger(r.Context(), r.PathValue("name"))
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	h.logger.Info("schedule triggered", "schedule", sc.Name, "subject", subjectOf(r))
+	status := http.StatusOK
+	if sc.LastError != "" {
+		status = http.StatusBadGateway
+	}
+	writeJSON(w, status, sc)
+}
+
+func (h *ScheduleHandler) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, schedule.ErrNotFound):
+		http.Error(w, "not found", http.StatusNotFound)
+	case errors.Is(err, schedule.ErrInvalid):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, schedule.ErrReadOnly):
+		http.Error(w, "schedule is defined in config and cannot be changed through the api", http.StatusConflict)
+	default:
+		h.logger.Error("schedule store error", "schedule", r.PathValue("name"), "error", err)
+		http.Error(w, "store error", http.StatusInternalServerError)
+	}
+}
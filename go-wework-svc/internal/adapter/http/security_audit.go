@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// SecurityEvent 一次签名、corp_id、解密或重放校验失败，用于发现对回调地址的探测
+// 不包含请求体和消息内容
+type SecurityEvent struct {
+	Time      time.Time `json:"time"`
+	Reason    string    `json:"reason"` // Reason* 常量
+	Agent     string    `json:"agent,omitempty"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	RemoteIP  string    `json:"remote_ip"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	Timestamp string    `json:"timestamp,omitempty"` // 请求中的 timestamp 参数
+	Nonce     string    `json:"nonce,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// isSecurityReason 是否为需要审计的失败原因
+func isSecurityReason(reason string) bool {
+	switch reason {
+	case ReasonInvalidSignature, ReasonCorpMismatch, ReasonDecryptFailed, ReasonStaleTimestamp, ReasonReplayedRequest, ReasonSourceNotAllowed:
+		return true
+	}
+	return false
+}
+
+// SecurityAuditLog 将安全事件以 JSON Lines 写入 w，每行一条，便于 SIEM 采集
+type SecurityAuditLog struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewSecurityAuditLog 创建安全审计日志
+func NewSecurityAuditLog(w io.Writer) *SecurityAuditLog {
+	return &SecurityAuditLog{enc: json.NewEncoder(w)}
+}
+
+// Record 写入一条安全事件，写入失败时忽略，不影响回调响应
+func (l *SecurityAuditLog) Record(e SecurityEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.enc.Encode(struct {
+		Type string `json:"type"`
+		SecurityEvent
+	}{"security_audit", e})
+}
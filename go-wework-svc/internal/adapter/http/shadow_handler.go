@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"go-wework-svc/internal/ai"
+)
+
+// ShadowHandler 影子评估记录查询接口
+type ShadowHandler struct {
+	store *ai.ShadowStore
+}
+
+// NewShadowHandler 创建影子评估处理器
+func NewShadowHandler(store *ai.ShadowStore) *ShadowHandler {
+	return &ShadowHandler{store: store}
+}
+
+// HandleList GET /admin/shadow?limit=N 返回最近的对比记录
+func (h *ShadowHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"items": h.store.List(limit)})
+}
+
+// HandleGet GET /admin/shadow/{id} 返回单条对比记录
+func (h *ShadowHandler) HandleGet(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	rec, ok := h.store.Get(id)
+	if !ok {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, rec)
+}
+
+// HandleReport GET /admin/shadow/report 返回汇总报告
+func (h *ShadowHandler) HandleReport(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.store.Report())
+}
@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go-wework-svc/internal/shared"
+)
+
+const defaultAllowlistRefresh = time.Hour
+
+// CallbackIPSource 企业微信回调服务器的出口 IP 段来源，见 client.CallbackIPClient
+type CallbackIPSource interface {
+	CallbackIPs(ctx context.Context) ([]string, error)
+}
+
+// SourceAllowlist 回调来源 IP 白名单，作为签名校验之外的纵深防御
+// 静态列表始终放行；拉取到的 IP 段定期刷新，刷新失败时沿用上次结果
+// 既没有静态列表也尚未拉取成功时不做限制，避免企业微信 API 故障导致回调全部被拒
+type SourceAllowlist struct {
+	static   []netip.Prefix
+	fetched  atomic.Pointer[[]netip.Prefix]
+	source   CallbackIPSource // 为 nil 时只使用静态列表
+	interval time.Duration
+	logger   *slog.Logger
+}
+
+// NewSourceAllowlist 创建来源 IP 白名单，interval <= 0 时每小时刷新
+func NewSourceAllowlist(static []netip.Prefix, source CallbackIPSource, interval time.Duration, logger *slog.Logger) *SourceAllowlist {
+	if interval <= 0 {
+		interval = defaultAllowlistRefresh
+	}
+	return &SourceAllowlist{static: static, source: source, interval: interval, logger: logger}
+}
+
+// Allowed 返回 ip 是否允许访问回调地址
+func (a *SourceAllowlist) Allowed(ip string) bool {
+	fetched := a.fetched.Load()
+	if len(a.static) == 0 && fetched == nil {
+		return true
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range a.static {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	if fetched != nil {
+		for _, p := range *fetched {
+			if p.Contains(addr) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Run 立即拉取一次，之后按周期刷新直到 ctx 取消
+func (a *SourceAllowlist) Run(ctx context.Context) {
+	if a.source == nil {
+		return
+	}
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+	for {
+		a.refresh(ctx)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (a *SourceAllowlist) refresh(ctx context.Context) {
+	list, err := a.source.CallbackIPs(ctx)
+	if err != nil {
+		a.logger.Warn("failed to refresh callback ip allowlist, keeping previous list", "error", err)
+		return
+	}
+	prefixes := make([]netip.Prefix, 0, len(list))
+	for _, s := range list {
+		p, err := shared.ParsePrefixes([]string{strings.TrimSpace(s)})
+		if err != nil {
+			a.logger.Warn("ignoring malformed callback ip", "ip", s, "error", err)
+			continue
+		}
+		prefixes = append(prefixes, p...)
+	}
+	if len(prefixes) == 0 {
+		a.logger.Warn("callback ip list is empty, keeping previous list")
+		return
+	}
+	a.fetched.Store(&prefixes)
+	a.logger.Info("callback ip allowlist refreshed", "count", len(prefixes))
+}
@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"go-wework-svc/internal/version"
+)
+
+// VersionHandler GET /version，返回构建信息和当前配置摘要
+type VersionHandler struct {
+	info atomic.Pointer[version.Info]
+}
+
+// NewVersionHandler 创建版本信息处理器
+func NewVersionHandler(configHash string) *VersionHandler {
+	h := &VersionHandler{}
+	h.SetConfigHash(configHash)
+	return h
+}
+
+// SetConfigHash 配置热加载后更新摘要
+func (h *VersionHandler) SetConfigHash(configHash string) {
+	info := version.Get(configHash)
+	h.info.Store(&info)
+}
+
+// ServeHTTP 实现 http.Handler
+func (h *VersionHandler) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, h.info.Load())
+}
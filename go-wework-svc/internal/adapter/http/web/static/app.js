@@ -0,0 +1,115 @@
+(function () {
+  "use strict";
+
+  const REFRESH_MS = 5000;
+
+  async function getJSON(path) {
+    const resp = await fetch(path, { credentials: "same-origin" });
+    if (resp.status === 401) {
+      window.location.href = "login";
+      throw new Error("unauthorized");
+    }
+    if (!resp.ok) {
+      const err = new Error("HTTP " + resp.status);
+      err.status = resp.status;
+      throw err;
+    }
+    return resp.json();
+  }
+
+  function text(v) {
+    return v === undefined || v === null || v === "" ? "-" : String(v);
+  }
+
+  function fillGrid(el, pairs) {
+    el.replaceChildren();
+    for (const [k, v, cls] of pairs) {
+      const dt = document.createElement("dt");
+      dt.textContent = k;
+      const dd = document.createElement("dd");
+      dd.textContent = text(v);
+      if (cls) dd.className = cls;
+      el.append(dt, dd);
+    }
+  }
+
+  function fmtTime(s) {
+    return s ? new Date(s).toLocaleString() : "-";
+  }
+
+  function renderStatus(st) {
+    const c = st.counters;
+    fillGrid(document.getElementById("counters"), [
+      ["已接收", c.received],
+      ["已忽略", c.ignored],
+      ["已转发", c.forwarded],
+      ["事件", c.events],
+      ["已回复", c.replied],
+      ["失败", c.failed],
+    ]);
+    document.getElementById("uptime").textContent = st.uptime_sec + "s";
+
+    const ai = st.ai;
+    fillGrid(document.getElementById("ai"), [
+      ["状态", ai.status, "status-" + ai.status],
+      ["最近失败", ai.recent_failures + " / " + ai.recent_total],
+      ["平均耗时", ai.avg_latency_ms + " ms"],
+      ["最近成功", fmtTime(ai.last_success_at)],
+      ["最近错误", fmtTime(ai.last_error_at)],
+      ["错误信息", ai.last_error],
+    ]);
+
+    fillGrid(document.getElementById("queues"),
+      Object.keys(st.queues).sort().map((k) => [k, st.queues[k]]));
+
+    const tbody = document.getElementById("recent");
+    tbody.replaceChildren();
+    for (const m of st.recent) {
+      const tr = document.createElement("tr");
+      for (const v of [fmtTime(m.time), m.msg_id, m.user, m.msg_type, m.length, m.outcome,
+        m.latency_ms ? m.latency_ms + " ms" : "", m.error]) {
+        const td = document.createElement("td");
+        td.textContent = text(v);
+        tr.append(td);
+      }
+      tbody.append(tr);
+    }
+  }
+
+  async function renderDLQ() {
+    const el = document.getElementById("dlq");
+    try {
+      const data = await getJSON("dlq");
+      const items = data.items || [];
+      el.textContent = items.length === 0 ? "空" : items.length + " 条待处理消息";
+    } catch (e) {
+      el.textContent = e.status === 404 ? "未启用" : "加载失败";
+    }
+  }
+
+  async function refresh() {
+    try {
+      renderStatus(await getJSON("status"));
+    } catch (e) {
+      console.error(e);
+    }
+    renderDLQ();
+  }
+
+  async function init() {
+    try {
+      const id = await getJSON("whoami");
+      document.getElementById("whoami").textContent = (id.name || id.email || id.subject) + " (" + id.role + ")";
+    } catch (e) {
+      return;
+    }
+    document.getElementById("logout").addEventListener("click", async () => {
+      await fetch("logout", { method: "POST", credentials: "same-origin" });
+      window.location.href = "login";
+    });
+    refresh();
+    setInterval(refresh, REFRESH_MS);
+  }
+
+  init();
+})();
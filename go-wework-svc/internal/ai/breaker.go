@@ -0,0 +1,94 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrBreakerOpen 后端连续失败后熔断，冷却期内的请求直接失败，不再访问后端
+var ErrBreakerOpen = errors.New("ai breaker open")
+
+// Breaker 为单个后端加上熔断：连续失败 failures 次后打开，cooldown 后放行一个探测请求，成功即关闭
+type Breaker struct {
+	svc      Service
+	failures int
+	cooldown time.Duration
+	// onChange 熔断打开或关闭时调用，可为 nil
+	onChange func(open bool)
+
+	mu       sync.Mutex
+	failed   int
+	openedAt time.Time // 零值表示关闭
+	probing  bool
+}
+
+// NewBreaker 创建熔断器，onChange 可为 nil
+func NewBreaker(svc Service, failures int, cooldown time.Duration, onChange func(open bool)) *Breaker {
+	return &Breaker{svc: svc, failures: failures, cooldown: cooldown, onChange: onChange}
+}
+
+// SendMessage 实现 Service 接口
+func (b *Breaker) SendMessage(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	if err := b.allow(); err != nil {
+		return nil, err
+	}
+	resp, err := b.svc.SendMessage(ctx, req)
+	b.done(ctx, err)
+	return resp, err
+}
+
+// SendMessageStream 实现 StreamingService 接口
+func (b *Breaker) SendMessageStream(ctx context.Context, req ChatRequest, onDelta func(string)) (*ChatResponse, error) {
+	if err := b.allow(); err != nil {
+		return nil, err
+	}
+	resp, err := SendStream(ctx, b.svc, req, onDelta)
+	b.done(ctx, err)
+	return resp, err
+}
+
+// allow 熔断打开且未到冷却期，或已有探测请求进行中时返回 ErrBreakerOpen
+func (b *Breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.openedAt.IsZero() {
+		return nil
+	}
+	if b.probing || time.Since(b.openedAt) < b.cooldown {
+		return ErrBreakerOpen
+	}
+	b.probing = true
+	return nil
+}
+
+// done 记录请求结果；调用方取消的请求不计入失败
+func (b *Breaker) done(ctx context.Context, err error) {
+	if err != nil && ctx.Err() != nil {
+		b.mu.Lock()
+		b.probing = false
+		b.mu.Unlock()
+		return
+	}
+
+	b.mu.Lock()
+	wasOpen := !b.openedAt.IsZero()
+	b.probing = false
+	if err == nil {
+		b.failed = 0
+		b.openedAt = time.Time{}
+	} else {
+		b.failed++
+		// 探测失败时重新计算冷却期
+		if wasOpen || b.failed >= b.failures {
+			b.openedAt = time.Now()
+		}
+	}
+	isOpen := !b.openedAt.IsZero()
+	b.mu.Unlock()
+
+	if isOpen != wasOpen && b.onChange != nil {
+		b.onChange(isOpen)
+	}
+}
@@ -0,0 +1,226 @@
+package ai
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"math"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"go-wework-svc/internal/shared"
+	"go-wework-svc/internal/store"
+)
+
+// BackendCache 由回复缓存直接提供的回复
+const BackendCache = "cache"
+
+// Embedder 计算文本的向量，用于按语义相似度命中回复缓存
+type Embedder interface {
+	Embed(ctx context.Context, text string) ([]float32, error)
+}
+
+// ResponseCache 按规范化后的问题缓存 AI 回复，相同（或语义相近）的问题直接返回缓存的回复
+// 只缓存不带附件、会话历史和工具调用结果的请求，回复过程中执行过工具的不缓存
+type ResponseCache struct {
+	next      Service
+	kv        store.Store
+	namespace string
+	ttl       time.Duration
+	embedder  Embedder
+	threshold float64
+	onHit     func()
+	logger    *slog.Logger
+
+	// index 语义索引，保存最近写入缓存的问题向量，仅在配置 embedder 时使用
+	mu      sync.Mutex
+	index   []cacheEntry
+	pos     int
+	maxSize int
+}
+
+// cacheEntry 语义索引中的一条记录
+type cacheEntry struct {
+	scope string
+	key   string
+	vec   []float32
+}
+
+// NewResponseCache 创建回复缓存，namespace 区分使用不同后端的应用；embedder 为 nil 时只按问题原文命中，onHit 可为 nil
+func NewResponseCache(next Service, kv store.Store, namespace string, cfg shared.ResponseCacheConfig, embedder Embedder, onHit func(), logger *slog.Logger) *ResponseCache {
+	return &ResponseCache{
+		next:      next,
+		kv:        kv,
+		namespace: namespace,
+		ttl:       cfg.TTL,
+		embedder:  embedder,
+		threshold: cfg.Similarity,
+		onHit:     onHit,
+		logger:    logger,
+		maxSize:   cfg.MaxEntries,
+	}
+}
+
+// SendMessage 实现 Service 接口
+func (c *ResponseCache) SendMessage(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	return c.send(ctx, req, func(req ChatRequest) (*ChatResponse, error) {
+		return c.next.SendMessage(ctx, req)
+	}, nil)
+}
+
+// SendMessageStream 实现 StreamingService 接口，命中缓存时一次性输出完整回复
+func (c *ResponseCache) SendMessageStream(ctx context.Context, req ChatRequest, onDelta func(string)) (*ChatResponse, error) {
+	return c.send(ctx, req, func(req ChatRequest) (*ChatResponse, error) {
+		return SendStream(ctx, c.next, req, onDelta)
+	}, onDelta)
+}
+
+func (c *ResponseCache) send(ctx context.Context, req ChatRequest, call func(ChatRequest) (*ChatResponse, error), onDelta func(string)) (*ChatResponse, error) {
+	question := normalizeQuestion(req.Content)
+	if question == "" || req.Attachment != nil || len(req.History) > 0 || len(req.ToolResults) > 0 {
+		return call(req)
+	}
+	scope := c.scope(req)
+	key := "ai-cache:" + c.namespace + ":" + hashKey(scope, question)
+
+	var vec []float32
+	reply, ok := c.lookup(ctx, key)
+	if !ok && c.embedder != nil {
+		var err error
+		if vec, err = c.embedder.Embed(ctx, question); err != nil {
+			c.logger.WarnContext(ctx, "failed to embed question for response cache", "error", err)
+		} else {
+			reply, ok = c.similar(ctx, scope, vec)
+		}
+	}
+	if ok {
+		if c.onHit != nil {
+			c.onHit()
+		}
+		c.logger.DebugContext(ctx, "ai response served from cache", "user_id", req.UserID)
+		if onDelta != nil {
+			onDelta(reply)
+		}
+		return &ChatResponse{Reply: reply, Backend: BackendCache}, nil
+	}
+
+	// 包装工具，回复依赖工具结果（通常因人而异）时不缓存
+	var toolUsed bool
+	if len(req.Tools) > 0 {
+		tools := make([]Tool, len(req.Tools))
+		for i, t := range req.Tools {
+			call := t.Call
+			t.Call = func(ctx context.Context, tc ToolCall) (string, error) {
+				toolUsed = true
+				return call(ctx, tc)
+			}
+			tools[i] = t
+		}
+		req.Tools = tools
+	}
+
+	resp, err := call(req)
+	if err != nil || resp.Reply == "" || toolUsed {
+		return resp, err
+	}
+	if err := c.kv.Set(ctx, key, resp.Reply, c.ttl); err != nil {
+		c.logger.WarnContext(ctx, "failed to store ai response cache", "error", err)
+		return resp, nil
+	}
+	if vec != nil {
+		c.remember(cacheEntry{scope: scope, key: key, vec: vec})
+	}
+	return resp, nil
+}
+
+// lookup 读取缓存，存储失败视为未命中
+func (c *ResponseCache) lookup(ctx context.Context, key string) (string, bool) {
+	v, ok, err := c.kv.Get(ctx, key)
+	if err != nil {
+		c.logger.WarnContext(ctx, "failed to read ai response cache", "error", err)
+		return "", false
+	}
+	return v, ok
+}
+
+// similar 在语义索引中查找同一 scope 下相似度最高且未过期的问题
+func (c *ResponseCache) similar(ctx context.Context, scope string, vec []float32) (string, bool) {
+	c.mu.Lock()
+	best, bestKey := c.threshold, ""
+	for _, e := range c.index {
+		if e.scope != scope {
+			continue
+		}
+		if s := cosine(vec, e.vec); s >= best {
+			best, bestKey = s, e.key
+		}
+	}
+	c.mu.Unlock()
+	if bestKey == "" {
+		return "", false
+	}
+	return c.lookup(ctx, bestKey)
+}
+
+// remember 将问题向量加入语义索引，超过容量时覆盖最早的记录
+func (c *ResponseCache) remember(e cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.index) < c.maxSize {
+		c.index = append(c.index, e)
+		return
+	}
+	c.index[c.pos] = e
+	c.pos = (c.pos + 1) % c.maxSize
+}
+
+// scope 影响回复内容的请求设置：路由、系统提示和人设，不同 scope 的缓存互不命中
+func (c *ResponseCache) scope(req ChatRequest) string {
+	persona, _ := json.Marshal(req.Persona)
+	return req.Route + "\x00" + req.SystemPrompt + "\x00" + string(persona)
+}
+
+// normalizeQuestion 转为小写，去掉标点和多余空白，使仅有大小写、标点差异的问题命中同一缓存
+func normalizeQuestion(s string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteRune(r)
+			space = false
+			continue
+		}
+		space = true
+	}
+	return b.String()
+}
+
+// hashKey 计算缓存键
+func hashKey(scope, question string) string {
+	sum := sha256.Sum256([]byte(scope + "\x00" + question))
+	return hex.EncodeToString(sum[:16])
+}
+
+// cosine 计算余弦相似度，维度不一致时返回 0
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
@@ -0,0 +1,93 @@
+package ai
+
+import (
+	"context"
+	"hash/fnv"
+	"log/slog"
+	"slices"
+	"time"
+
+	"go-wework-svc/internal/shared"
+)
+
+// CanaryRouter 将部分流量路由到灰度 AI 后端，并为结果打上后端标签
+type CanaryRouter struct {
+	primary Service
+	canary  Service
+	percent int
+	groups  []string
+	users   []string
+	logger  *slog.Logger
+}
+
+// NewCanaryRouter 创建灰度路由
+func NewCanaryRouter(primary, canary Service, cfg shared.CanaryConfig, logger *slog.Logger) *CanaryRouter {
+	return &CanaryRouter{
+		primary: primary,
+		canary:  canary,
+		percent: cfg.Percent,
+		groups:  cfg.Groups,
+		users:   cfg.Users,
+		logger:  logger,
+	}
+}
+
+// SendMessage 实现 Service 接口
+func (r *CanaryRouter) SendMessage(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	return r.route(ctx, req, func(svc Service) (*ChatResponse, error) {
+		return svc.SendMessage(ctx, req)
+	})
+}
+
+// SendMessageStream 实现 StreamingService 接口，选中的后端不支持流式时退化为一次性回复
+func (r *CanaryRouter) SendMessageStream(ctx context.Context, req ChatRequest, onDelta func(string)) (*ChatResponse, error) {
+	return r.route(ctx, req, func(svc Service) (*ChatResponse, error) {
+		return SendStream(ctx, svc, req, onDelta)
+	})
+}
+
+// route 选择后端并调用 call，记录结果并打上后端标签
+func (r *CanaryRouter) route(ctx context.Context, req ChatRequest, call func(Service) (*ChatResponse, error)) (*ChatResponse, error) {
+	backend, svc := BackendPrimary, r.primary
+	if r.useCanary(req) {
+		backend, svc = BackendCanary, r.canary
+	}
+
+	start := time.Now()
+	resp, err := call(svc)
+	latency := time.Since(start)
+	if err != nil {
+		r.logger.WarnContext(ctx, "ai backend request failed",
+			"backend", backend,
+			"user_id", req.UserID,
+			"latency_ms", latency.Milliseconds(),
+			"error", err,
+		)
+		return nil, err
+	}
+
+	resp.Backend = backend
+	r.logger.InfoContext(ctx, "ai backend responded",
+		"backend", backend,
+		"user_id", req.UserID,
+		"latency_ms", latency.Milliseconds(),
+		"reply_len", len(resp.Reply),
+	)
+	return resp, nil
+}
+
+// useCanary 群组/用户白名单优先，其余按用户 ID 哈希分桶，保证同一用户路由稳定
+func (r *CanaryRouter) useCanary(req ChatRequest) bool {
+	if req.GroupID != "" && slices.Contains(r.groups, req.GroupID) {
+		return true
+	}
+	if slices.Contains(r.users, req.UserID) {
+		return true
+	}
+	if r.percent <= 0 {
+		return false
+	}
+	h := fnv.New32a()
+	h.Write([]byte(req.UserID))
+	return int(h.Sum32()%100) < r.percent
+}
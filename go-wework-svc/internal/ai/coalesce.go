@@ -0,0 +1,98 @@
+package ai
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// coalesceTimeout 合并后共享请求的超时，与发起方的 context 无关，任一调用方取消都不会影响其他调用方
+const coalesceTimeout = 2 * time.Minute
+
+// Coalescer 合并同一会话中进行中的相同请求：用户重复发送同一问题时只请求一次后端，回复分发给每个请求
+type Coalescer struct {
+	next   Service
+	group  singleflight.Group
+	logger *slog.Logger
+}
+
+// NewCoalescer 创建请求合并器
+func NewCoalescer(next Service, logger *slog.Logger) *Coalescer {
+	return &Coalescer{next: next, logger: logger}
+}
+
+// SendMessage 实现 Service 接口
+func (c *Coalescer) SendMessage(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	return c.do(ctx, req, nil)
+}
+
+// SendMessageStream 实现 StreamingService 接口，只有首个请求流式输出，被合并的请求收到完整回复后一次性输出
+func (c *Coalescer) SendMessageStream(ctx context.Context, req ChatRequest, onDelta func(string)) (*ChatResponse, error) {
+	return c.do(ctx, req, onDelta)
+}
+
+// do 发送请求，onDelta 为 nil 时不流式输出
+// 共享请求使用独立的 context，每个调用方只按自己的 context 等待，先放弃的调用方不影响其他调用方拿到回复
+func (c *Coalescer) do(ctx context.Context, req ChatRequest, onDelta func(string)) (*ChatResponse, error) {
+	send := func(ctx context.Context, onDelta func(string)) (*ChatResponse, error) {
+		if onDelta == nil {
+			return c.next.SendMessage(ctx, req)
+		}
+		return SendStream(ctx, c.next, req, onDelta)
+	}
+	// 附件内容可能不同，不合并
+	if req.Attachment != nil || req.Content == "" {
+		return send(ctx, onDelta)
+	}
+	key := req.UserID + "\x00" + req.GroupID + "\x00" + req.ConversationID + "\x00" + req.Route + "\x00" + req.Content
+
+	// 首个调用方放弃等待后不再向其输出增量
+	var (
+		mu   sync.Mutex
+		gone bool
+	)
+	var leaderDelta func(string)
+	if onDelta != nil {
+		leaderDelta = func(delta string) {
+			mu.Lock()
+			defer mu.Unlock()
+			if !gone {
+				onDelta(delta)
+			}
+		}
+	}
+
+	leader := false
+	ch := c.group.DoChan(key, func() (any, error) {
+		leader = true
+		shareCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), coalesceTimeout)
+		defer cancel()
+		return send(shareCtx, leaderDelta)
+	})
+	var res singleflight.Result
+	select {
+	case res = <-ch:
+	case <-ctx.Done():
+		mu.Lock()
+		gone = true
+		mu.Unlock()
+		return nil, ctx.Err()
+	}
+	if res.Err != nil {
+		return nil, res.Err
+	}
+	resp := res.Val.(*ChatResponse)
+	if leader {
+		return resp, nil
+	}
+	c.logger.InfoContext(ctx, "ai request coalesced", "user_id", req.UserID, "shared", res.Shared)
+	if onDelta != nil && resp.Reply != "" {
+		onDelta(resp.Reply)
+	}
+	// 外层路由会改写 Backend 等字段，每个调用方使用独立的副本
+	out := *resp
+	return &out, nil
+}
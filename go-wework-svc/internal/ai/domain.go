@@ -1,14 +1,148 @@
 package ai
 
+import (
+	"encoding/base64"
+	"strings"
+)
+
 // ChatRequest AI 助手请求
 type ChatRequest struct {
 	UserID  string `json:"user_id"`
 	Content string `json:"content"`
 	Source  string `json:"source"` // "wework"
 	GroupID string `json:"group_id,omitempty"`
+
+	// Attachment 非文本消息的附加信息，文本消息为 nil
+	Attachment *Attachment `json:"attachment,omitempty"`
+
+	// History 同一会话最近的若干轮对话，按时间升序，不含本次消息
+	History []Turn `json:"history,omitempty"`
+
+	// ConversationID 会话标识，同一用户（或群组）的连续对话相同
+	// 在服务端保存上下文的后端（Dify、FastGPT）据此延续会话
+	ConversationID string `json:"conversation_id,omitempty"`
+
+	// Profile 发送者的通讯录信息，未启用通讯录查询或查询失败时为 nil
+	Profile *UserProfile `json:"profile,omitempty"`
+
+	// Route 按部门或标签匹配到的路由名称，由 RouteRouter 选择后端
+	Route string `json:"route,omitempty"`
+	// SystemPrompt 路由指定的系统提示，非空时 openai、ollama 后端用它代替配置的系统提示
+	SystemPrompt string `json:"system_prompt,omitempty"`
+
+	// Persona 按应用或群聊配置的人设，未配置时为 nil
+	Persona *Persona `json:"persona,omitempty"`
+
+	// Tools 本次对话中后端可调用的工具，不序列化，outbox 重投时由服务重新挂载
+	Tools []Tool `json:"-"`
+	// ToolResults 本次对话中已执行的工具调用及结果，按调用顺序排列，供后端生成最终回复
+	ToolResults []ToolResult `json:"tool_results,omitempty"`
+}
+
+// UserProfile 发送者在企业通讯录中的信息，字段可能因应用的通讯录权限而为空
+type UserProfile struct {
+	Name          string  `json:"name,omitempty"`
+	Department    string  `json:"department,omitempty"` // 主部门名称
+	DepartmentIDs []int64 `json:"department_ids,omitempty"`
+	Position      string  `json:"position,omitempty"`
+}
+
+// Describe 返回供系统提示使用的一句话描述，没有可用信息时为空
+func (p *UserProfile) Describe() string {
+	if p == nil {
+		return ""
+	}
+	var parts []string
+	for _, f := range [][2]string{{"姓名", p.Name}, {"部门", p.Department}, {"职务", p.Position}} {
+		if f[1] != "" {
+			parts = append(parts, f[0]+"："+f[1])
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "当前提问的用户信息（" + strings.Join(parts, "，") + "）"
+}
+
+// Persona 机器人人设，同一部署中不同应用、不同群聊可以使用不同的风格
+type Persona struct {
+	SystemPrompt string   `json:"system_prompt,omitempty"` // 非空时代替后端配置的系统提示，路由指定的系统提示优先
+	Tone         string   `json:"tone,omitempty"`          // 语气，如「正式、礼貌」
+	Language     string   `json:"language,omitempty"`      // 回复使用的语言，如「简体中文」
+	Topics       []string `json:"topics,omitempty"`        // 允许回答的主题，为空时不限制
+}
+
+// Instructions 返回语气、语言和主题限制的说明，供附加在系统提示之后，均未配置时为空
+func (p *Persona) Instructions() string {
+	if p == nil {
+		return ""
+	}
+	var lines []string
+	if p.Tone != "" {
+		lines = append(lines, "回复语气："+p.Tone+"。")
+	}
+	if p.Language != "" {
+		lines = append(lines, "请使用"+p.Language+"回复。")
+	}
+	if len(p.Topics) > 0 {
+		lines = append(lines, "只回答与以下主题相关的问题："+strings.Join(p.Topics, "、")+"；其他问题礼貌地说明无法回答。")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Turn 一条历史对话
+type Turn struct {
+	Role    string `json:"role"` // user | assistant
+	Content string `json:"content"`
+}
+
+// 对话角色常量
+const (
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// Attachment 非文本消息内容，Type 与企业微信 MsgType 一致
+type Attachment struct {
+	Type         string  `json:"type"` // image | voice | video | file | location | link
+	MediaID      string  `json:"media_id,omitempty"`
+	URL          string  `json:"url,omitempty"` // 图片地址或链接地址
+	Format       string  `json:"format,omitempty"`
+	ThumbMediaID string  `json:"thumb_media_id,omitempty"`
+	Title        string  `json:"title,omitempty"`
+	Description  string  `json:"description,omitempty"`
+	Latitude     float64 `json:"latitude,omitempty"`
+	Longitude    float64 `json:"longitude,omitempty"`
+	Label        string  `json:"label,omitempty"`
+
+	// Text 从文件中提取的正文
+	Text string `json:"text,omitempty"`
+
+	// MimeType、Data 启用视觉输入时下载的图片内容，Data 在 JSON 中为 base64
+	MimeType string `json:"mime_type,omitempty"`
+	Data     []byte `json:"data,omitempty"`
+}
+
+// DataURL 返回图片内容的 data URL，未下载内容时为空
+func (a *Attachment) DataURL() string {
+	if len(a.Data) == 0 {
+		return ""
+	}
+	return "data:" + a.MimeType + ";base64," + base64.StdEncoding.EncodeToString(a.Data)
 }
 
 // ChatResponse AI 助手响应
 type ChatResponse struct {
 	Reply string `json:"reply"`
+	// ToolCalls 后端要求执行的工具调用，非空时执行后携带 ToolResults 再次请求
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	Backend   string     `json:"-"` // 实际处理请求的后端，由路由层填写
+	// Provider 降级链中实际提供回复的后端名称，未配置备用后端时为空
+	Provider string `json:"-"`
 }
+
+// 后端标识常量
+const (
+	BackendPrimary = "primary"
+	BackendCanary  = "canary"
+)
@@ -0,0 +1,113 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+)
+
+// Provider 降级链中的一个具名后端
+type Provider struct {
+	Name    string
+	Service Service
+}
+
+// FallbackChain 按顺序尝试各后端，前一个失败（已耗尽自身重试）后降级到下一个
+type FallbackChain struct {
+	providers []Provider
+	// onServed 每次请求成功后以提供回复的后端调用，index 为其在链中的位置，0 为主后端
+	onServed func(name string, index int)
+	logger   *slog.Logger
+}
+
+// NewFallbackChain 创建降级链，providers[0] 为主后端；onServed 可为 nil
+func NewFallbackChain(providers []Provider, onServed func(name string, index int), logger *slog.Logger) *FallbackChain {
+	return &FallbackChain{providers: providers, onServed: onServed, logger: logger}
+}
+
+// SendMessage 实现 Service 接口
+func (c *FallbackChain) SendMessage(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	return c.try(ctx, req, func(svc Service, req ChatRequest) (*ChatResponse, error) {
+		return svc.SendMessage(ctx, req)
+	})
+}
+
+// SendMessageStream 实现 StreamingService 接口
+// 已输出部分内容后不再降级，以免用户收到两段不同的回复
+func (c *FallbackChain) SendMessageStream(ctx context.Context, req ChatRequest, onDelta func(string)) (*ChatResponse, error) {
+	received := false
+	return c.try(ctx, req, func(svc Service, req ChatRequest) (*ChatResponse, error) {
+		if received {
+			return nil, errStreamStarted
+		}
+		return SendStream(ctx, svc, req, func(delta string) {
+			received = true
+			onDelta(delta)
+		})
+	})
+}
+
+// errStreamStarted 流式回复已开始输出，终止降级
+var errStreamStarted = errors.New("stream already started")
+
+// trackTools 包装工具调用，任一工具执行后置位 ran
+func trackTools(tools []Tool, ran *atomic.Bool) []Tool {
+	if len(tools) == 0 {
+		return tools
+	}
+	wrapped := make([]Tool, len(tools))
+	for i, t := range tools {
+		if call := t.Call; call != nil {
+			t.Call = func(ctx context.Context, tc ToolCall) (string, error) {
+				ran.Store(true)
+				return call(ctx, tc)
+			}
+		}
+		wrapped[i] = t
+	}
+	return wrapped
+}
+
+// try 依次尝试各后端；工具已执行过后不再降级，以免创建日程、发送文件等有副作用的工具重复执行
+func (c *FallbackChain) try(ctx context.Context, req ChatRequest, call func(Service, ChatRequest) (*ChatResponse, error)) (*ChatResponse, error) {
+	var (
+		errs    []error
+		toolRan atomic.Bool
+	)
+	req.Tools = trackTools(req.Tools, &toolRan)
+	for i, p := range c.providers {
+		resp, err := call(p.Service, req)
+		if err == nil {
+			resp.Provider = p.Name
+			if i > 0 {
+				c.logger.WarnContext(ctx, "ai request served by fallback provider", "provider", p.Name, "user_id", req.UserID)
+			}
+			if c.onServed != nil {
+				c.onServed(p.Name, i)
+			}
+			return resp, nil
+		}
+		if errors.Is(err, errStreamStarted) {
+			break
+		}
+		errs = append(errs, err)
+		if ctx.Err() != nil {
+			break
+		}
+		if toolRan.Load() {
+			c.logger.WarnContext(ctx, "ai provider failed after running tools, not falling back", "provider", p.Name, "user_id", req.UserID, "error", err)
+			break
+		}
+		if i+1 < len(c.providers) {
+			c.logger.WarnContext(ctx, "ai provider failed, falling back",
+				"provider", p.Name,
+				"next", c.providers[i+1].Name,
+				"user_id", req.UserID,
+				"error", err,
+			)
+		}
+	}
+	return nil, fmt.Errorf("all %d ai providers failed: %w", len(errs), errors.Join(errs...))
+}
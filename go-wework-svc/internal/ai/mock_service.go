@@ -54,3 +54,57 @@ func (mr *MockServiceMockRecorder) SendMessage(ctx, req any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendMessage", reflect.TypeOf((*MockService)(nil).SendMessage), ctx, req)
 }
+
+// MockStreamingService is a mock of StreamingService interface.
+type MockStreamingService struct {
+	ctrl     *gomock.Controller
+	recorder *MockStreamingServiceMockRecorder
+	isgomock struct{}
+}
+
+// MockStreamingServiceMockRecorder is the mock recorder for MockStreamingService.
+type MockStreamingServiceMockRecorder struct {
+	mock *MockStreamingService
+}
+
+// NewMockStreamingService creates a new mock instance.
+func NewMockStreamingService(ctrl *gomock.Controller) *MockStreamingService {
+	mock := &MockStreamingService{ctrl: ctrl}
+	mock.recorder = &MockStreamingServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockStreamingService) EXPECT() *MockStreamingServiceMockRecorder {
+	return m.recorder
+}
+
+// SendMessage mocks base method.
+func (m *MockStreamingService) SendMessage(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendMessage", ctx, req)
+	ret0, _ := ret[0].(*ChatResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SendMessage indicates an expected call of SendMessage.
+func (mr *MockStreamingServiceMockRecorder) SendMessage(ctx, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendMessage", reflect.TypeOf((*MockStreamingService)(nil).SendMessage), ctx, req)
+}
+
+// SendMessageStream mocks base method.
+func (m *MockStreamingService) SendMessageStream(ctx context.Context, req ChatRequest, onDelta func(string)) (*ChatResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendMessageStream", ctx, req, onDelta)
+	ret0, _ := ret[0].(*ChatResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SendMessageStream indicates an expected call of SendMessageStream.
+func (mr *MockStreamingServiceMockRecorder) SendMessageStream(ctx, req, onDelta any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendMessageStream", reflect.TypeOf((*MockStreamingService)(nil).SendMessageStream), ctx, req, onDelta)
+}
@@ -0,0 +1,61 @@
+package ai
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Route 一条按部门或标签划分的路由，Service 为 nil 时使用默认后端
+type Route struct {
+	Name         string
+	Service      Service
+	SystemPrompt string // 非空时覆盖后端配置的系统提示
+	GroupID      string // 非空时覆盖请求的 GroupID
+}
+
+// RouteRouter 按 ChatRequest.Route 选择路由，未匹配任何路由的请求交给默认后端
+type RouteRouter struct {
+	fallback Service
+	routes   map[string]Route
+	logger   *slog.Logger
+}
+
+// NewRouteRouter 创建路由器，重名的路由后者覆盖前者
+func NewRouteRouter(fallback Service, routes []Route, logger *slog.Logger) *RouteRouter {
+	r := &RouteRouter{fallback: fallback, routes: make(map[string]Route, len(routes)), logger: logger}
+	for _, rt := range routes {
+		r.routes[rt.Name] = rt
+	}
+	return r
+}
+
+// SendMessage 实现 Service 接口
+func (r *RouteRouter) SendMessage(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	svc, req := r.pick(ctx, req)
+	return svc.SendMessage(ctx, req)
+}
+
+// SendMessageStream 实现 StreamingService 接口，选中的后端不支持流式时退化为一次性回复
+func (r *RouteRouter) SendMessageStream(ctx context.Context, req ChatRequest, onDelta func(string)) (*ChatResponse, error) {
+	svc, req := r.pick(ctx, req)
+	return SendStream(ctx, svc, req, onDelta)
+}
+
+// pick 选择后端并应用路由的请求覆盖项
+func (r *RouteRouter) pick(ctx context.Context, req ChatRequest) (Service, ChatRequest) {
+	rt, ok := r.routes[req.Route]
+	if req.Route == "" || !ok {
+		return r.fallback, req
+	}
+	if rt.SystemPrompt != "" {
+		req.SystemPrompt = rt.SystemPrompt
+	}
+	if rt.GroupID != "" {
+		req.GroupID = rt.GroupID
+	}
+	r.logger.DebugContext(ctx, "ai request routed", "route", rt.Name, "user_id", req.UserID)
+	if rt.Service == nil {
+		return r.fallback, req
+	}
+	return rt.Service, req
+}
@@ -7,3 +7,23 @@ type Service interface {
 	// SendMessage 将消息发送给 AI 助手
 	SendMessage(ctx context.Context, req ChatRequest) (*ChatResponse, error)
 }
+
+// StreamingService 支持流式输出的 AI 服务
+type StreamingService interface {
+	Service
+
+	// SendMessageStream 流式请求 AI，每收到一段增量内容调用一次 onDelta，返回拼接后的完整回复
+	SendMessageStream(ctx context.Context, req ChatRequest, onDelta func(delta string)) (*ChatResponse, error)
+}
+
+// SendStream 优先使用流式接口，svc 不支持时退化为一次性回调完整回复
+func SendStream(ctx context.Context, svc Service, req ChatRequest, onDelta func(delta string)) (*ChatResponse, error) {
+	if ss, ok := svc.(StreamingService); ok {
+		return ss.SendMessageStream(ctx, req, onDelta)
+	}
+	resp, err := svc.SendMessage(ctx, req)
+	if err == nil && resp.Reply != "" {
+		onDelta(resp.Reply)
+	}
+	return resp, err
+}
@@ -0,0 +1,99 @@
+package ai
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+
+	"go-wework-svc/internal/shared"
+)
+
+// ShadowRouter 将请求复制一份发给候选后端做离线评估
+// 用户始终收到主后端的回复，候选后端的回复只记录不投递
+type ShadowRouter struct {
+	primary   Service
+	candidate Service
+	store     *ShadowStore
+	percent   int
+	timeout   time.Duration
+	logger    *slog.Logger
+}
+
+// NewShadowRouter 创建影子评估路由
+func NewShadowRouter(primary, candidate Service, store *ShadowStore, cfg shared.ShadowConfig, logger *slog.Logger) *ShadowRouter {
+	return &ShadowRouter{
+		primary:   primary,
+		candidate: candidate,
+		store:     store,
+		percent:   cfg.Percent,
+		timeout:   cfg.Timeout,
+		logger:    logger,
+	}
+}
+
+// SendMessage 实现 Service 接口，主后端同步调用，候选后端异步调用
+func (r *ShadowRouter) SendMessage(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	return r.send(ctx, req, func() (*ChatResponse, error) {
+		return r.primary.SendMessage(ctx, req)
+	})
+}
+
+// SendMessageStream 实现 StreamingService 接口，仅主后端流式输出，候选后端仍一次性调用
+func (r *ShadowRouter) SendMessageStream(ctx context.Context, req ChatRequest, onDelta func(string)) (*ChatResponse, error) {
+	return r.send(ctx, req, func() (*ChatResponse, error) {
+		return SendStream(ctx, r.primary, req, onDelta)
+	})
+}
+
+func (r *ShadowRouter) send(ctx context.Context, req ChatRequest, callPrimary func() (*ChatResponse, error)) (*ChatResponse, error) {
+	start := time.Now()
+	resp, err := callPrimary()
+	primary := ShadowResult{LatencyMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		primary.Error = err.Error()
+	} else {
+		primary.Reply = resp.Reply
+		primary.Backend = resp.Backend
+	}
+
+	if r.sampled() {
+		go r.shadow(context.WithoutCancel(ctx), req, primary)
+	}
+
+	return resp, err
+}
+
+// shadow 调用候选后端并记录对比结果
+func (r *ShadowRouter) shadow(ctx context.Context, req ChatRequest, primary ShadowResult) {
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+
+	// 候选后端不执行工具，避免创建日程、待办或发送文件等副作用重复发生
+	req.Tools = nil
+	start := time.Now()
+	resp, err := r.candidate.SendMessage(ctx, req)
+	candidate := ShadowResult{Backend: "shadow", LatencyMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		candidate.Error = err.Error()
+		r.logger.DebugContext(ctx, "shadow backend request failed", "user_id", req.UserID, "error", err)
+	} else {
+		candidate.Reply = resp.Reply
+	}
+
+	r.store.Add(ShadowRecord{
+		Time:      time.Now(),
+		UserID:    req.UserID,
+		GroupID:   req.GroupID,
+		Content:   req.Content,
+		Primary:   primary,
+		Candidate: candidate,
+	})
+}
+
+func (r *ShadowRouter) sampled() bool {
+	return r.percent >= 100 || rand.IntN(100) < r.percent
+}
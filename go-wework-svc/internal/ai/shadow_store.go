@@ -0,0 +1,199 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ShadowResult 单个后端的响应结果
+type ShadowResult struct {
+	Backend   string `json:"backend,omitempty"`
+	Reply     string `json:"reply,omitempty"`
+	Error     string `json:"error,omitempty"`
+	LatencyMs int64  `json:"latency_ms"`
+}
+
+// ShadowRecord 主后端与候选后端的对比记录
+type ShadowRecord struct {
+	ID         uint64       `json:"id"`
+	Time       time.Time    `json:"time"`
+	UserID     string       `json:"user_id"`
+	GroupID    string       `json:"group_id,omitempty"`
+	Content    string       `json:"content"`
+	Primary    ShadowResult `json:"primary"`
+	Candidate  ShadowResult `json:"candidate"`
+	Similarity float64      `json:"similarity"` // 两个回复的相似度 0-1，任一方失败时为 0
+}
+
+// ShadowReport 对比记录汇总
+type ShadowReport struct {
+	Total               int     `json:"total"`
+	PrimaryErrors       int     `json:"primary_errors"`
+	CandidateErrors     int     `json:"candidate_errors"`
+	ExactMatches        int     `json:"exact_matches"`
+	AvgSimilarity       float64 `json:"avg_similarity"`
+	AvgPrimaryLatency   int64   `json:"avg_primary_latency_ms"`
+	AvgCandidateLatency int64   `json:"avg_candidate_latency_ms"`
+}
+
+// ShadowArchive 对比记录的持久化存储（消息归档）
+type ShadowArchive interface {
+	// SaveShadow 异步保存一条对比记录
+	SaveShadow(rec ShadowRecord)
+	// RecentShadow 返回最近 limit 条对比记录，按时间倒序
+	RecentShadow(ctx context.Context, limit int) ([]ShadowRecord, error)
+}
+
+// ShadowStore 对比记录存储，配置了归档时持久化到归档库，内存中保留最近的记录作为查询缓存
+type ShadowStore struct {
+	mu      sync.Mutex
+	max     int
+	nextID  uint64
+	records []ShadowRecord
+	archive ShadowArchive
+}
+
+// NewShadowStore 创建对比记录存储，最多保留 max 条
+func NewShadowStore(max int) *ShadowStore {
+	if max <= 0 {
+		max = 500
+	}
+	return &ShadowStore{max: max}
+}
+
+// Attach 设置持久化存储并载入最近的记录，重启后报告和查询仍包含之前的记录
+func (s *ShadowStore) Attach(ctx context.Context, archive ShadowArchive) error {
+	recent, err := archive.RecentShadow(ctx, s.max)
+	if err != nil {
+		return fmt.Errorf("load shadow records: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.archive = archive
+	s.records = make([]ShadowRecord, 0, len(recent))
+	for i := len(recent) - 1; i >= 0; i-- {
+		s.records = append(s.records, recent[i])
+		s.nextID = max(s.nextID, recent[i].ID)
+	}
+	return nil
+}
+
+// Add 追加一条记录并计算相似度
+func (s *ShadowStore) Add(rec ShadowRecord) {
+	if rec.Primary.Error == "" && rec.Candidate.Error == "" {
+		rec.Similarity = similarity(rec.Primary.Reply, rec.Candidate.Reply)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	rec.ID = s.nextID
+	s.records = append(s.records, rec)
+	if len(s.records) > s.max {
+		s.records = s.records[len(s.records)-s.max:]
+	}
+	if s.archive != nil {
+		s.archive.SaveShadow(rec)
+	}
+}
+
+// List 返回最近 limit 条记录，按时间倒序
+func (s *ShadowStore) List(limit int) []ShadowRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if limit <= 0 || limit > len(s.records) {
+		limit = len(s.records)
+	}
+	out := make([]ShadowRecord, 0, limit)
+	for i := len(s.records) - 1; i >= len(s.records)-limit; i-- {
+		out = append(out, s.records[i])
+	}
+	return out
+}
+
+// Get 按 ID 查找记录
+func (s *ShadowStore) Get(id uint64) (ShadowRecord, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.records {
+		if r.ID == id {
+			return r, true
+		}
+	}
+	return ShadowRecord{}, false
+}
+
+// Report 汇总当前保留的记录
+func (s *ShadowStore) Report() ShadowReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rep := ShadowReport{Total: len(s.records)}
+	if rep.Total == 0 {
+		return rep
+	}
+	var sim float64
+	var compared int
+	var pLat, cLat int64
+	for _, r := range s.records {
+		pLat += r.Primary.LatencyMs
+		cLat += r.Candidate.LatencyMs
+		if r.Primary.Error != "" {
+			rep.PrimaryErrors++
+		}
+		if r.Candidate.Error != "" {
+			rep.CandidateErrors++
+		}
+		if r.Primary.Error == "" && r.Candidate.Error == "" {
+			compared++
+			sim += r.Similarity
+			if r.Primary.Reply == r.Candidate.Reply {
+				rep.ExactMatches++
+			}
+		}
+	}
+	if compared > 0 {
+		rep.AvgSimilarity = sim / float64(compared)
+	}
+	rep.AvgPrimaryLatency = pLat / int64(rep.Total)
+	rep.AvgCandidateLatency = cLat / int64(rep.Total)
+	return rep
+}
+
+// maxSimilarityRunes 相似度计算的最大字符数，避免长回复的平方级开销
+const maxSimilarityRunes = 1000
+
+// similarity 基于编辑距离的相似度: 1 - distance / max(len(a), len(b))
+func similarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) > maxSimilarityRunes {
+		ra = ra[:maxSimilarityRunes]
+	}
+	if len(rb) > maxSimilarityRunes {
+		rb = rb[:maxSimilarityRunes]
+	}
+	longest := max(len(ra), len(rb))
+	if longest == 0 {
+		return 1
+	}
+
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return 1 - float64(prev[len(rb)])/float64(longest)
+}
@@ -0,0 +1,63 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// MaxToolRounds 单次对话中工具调用的最大轮数，超过后返回后端最后一次的文本回复
+const MaxToolRounds = 4
+
+// Tool 可由后端调用的操作
+// OpenAI 兼容后端（不含 FastGPT）通过 function calling 调用，assistant 协议后端在响应中返回 tool_calls，其他后端忽略
+type Tool struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Parameters 参数的 JSON Schema
+	Parameters json.RawMessage `json:"parameters,omitempty"`
+	// Call 执行调用，返回交给后端的结果文本；返回错误时错误信息作为结果交给后端
+	Call func(ctx context.Context, call ToolCall) (string, error) `json:"-"`
+}
+
+// ToolCall 后端发起的一次工具调用
+type ToolCall struct {
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+	// UserID 发起本次对话的用户，工具据此以用户身份执行操作，由本服务填写
+	UserID string `json:"-"`
+}
+
+// ToolResult 一次工具调用及其结果，随下一轮请求交给后端
+type ToolResult struct {
+	Call    ToolCall `json:"call"`
+	Content string   `json:"content"`
+	IsError bool     `json:"is_error,omitempty"`
+}
+
+// FindTool 按名称查找工具
+func FindTool(tools []Tool, name string) (Tool, bool) {
+	for _, t := range tools {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return Tool{}, false
+}
+
+// RunTool 以 userID 的身份执行工具调用，未知工具和执行失败均作为错误结果返回
+func RunTool(ctx context.Context, tools []Tool, userID string, call ToolCall) ToolResult {
+	call.UserID = userID
+	if len(call.Arguments) == 0 {
+		call.Arguments = json.RawMessage("{}")
+	}
+	tool, ok := FindTool(tools, call.Name)
+	if !ok || tool.Call == nil {
+		return ToolResult{Call: call, Content: "unknown tool " + call.Name, IsError: true}
+	}
+	content, err := tool.Call(ctx, call)
+	if err != nil {
+		return ToolResult{Call: call, Content: err.Error(), IsError: true}
+	}
+	return ToolResult{Call: call, Content: content}
+}
@@ -0,0 +1,277 @@
+// Package archive 将解密后的入站消息和发出的回复写入 SQL 数据库（SQLite / Postgres），用于审计和排查
+package archive
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	// 数据库驱动
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+
+	"go-wework-svc/internal/wework"
+)
+
+// 消息方向常量
+const (
+	DirectionInbound  = "inbound"
+	DirectionOutbound = "outbound"
+	// DirectionAudit 从会话存档拉取的聊天记录，见 Ingester
+	DirectionAudit = "audit"
+)
+
+// 支持的驱动
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+)
+
+const (
+	defaultQueueSize       = 1000
+	defaultCleanupInterval = time.Hour
+)
+
+// Record 一条归档记录
+type Record struct {
+	Direction string
+	Agent     string
+	MsgID     string
+	UserID    string
+	ChatID    string // 群聊 ID，会话存档的单聊为对方 ID
+	MsgType   string
+	Content   string
+	CreatedAt time.Time
+}
+
+// Archiver 消息归档器，实现 wework.Archive
+// 记录先写入内存队列，由 Run 异步落库；队列满时丢弃并记录日志，不阻塞回调处理
+type Archiver struct {
+	db              *sql.DB
+	postgres        bool
+	queue           chan Record
+	retention       time.Duration
+	cleanupInterval time.Duration
+	logger          *slog.Logger
+}
+
+// Option Archiver 的可选配置
+type Option func(*Archiver)
+
+// WithRetention 设置记录保留时长，每隔 interval 删除过期记录；retention 为 0 时不清理
+func WithRetention(retention, interval time.Duration) Option {
+	return func(a *Archiver) {
+		a.retention = retention
+		if interval > 0 {
+			a.cleanupInterval = interval
+		}
+	}
+}
+
+// WithQueueSize 设置异步写入队列长度
+func WithQueueSize(n int) Option {
+	return func(a *Archiver) {
+		if n > 0 {
+			a.queue = make(chan Record, n)
+		}
+	}
+}
+
+// Open 连接数据库并执行表结构迁移
+func Open(ctx context.Context, driver, dsn string, logger *slog.Logger, opts ...Option) (*Archiver, error) {
+	var name string
+	switch driver {
+	case DriverSQLite:
+		name = "sqlite"
+	case DriverPostgres:
+		name = "pgx"
+	default:
+		return nil, fmt.Errorf("unsupported archive driver %q", driver)
+	}
+
+	db, err := sql.Open(name, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open archive db: %w", err)
+	}
+	if driver == DriverSQLite {
+		// SQLite 仅支持单写入者
+		db.SetMaxOpenConns(1)
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("ping archive db: %w", err)
+	}
+
+	a := &Archiver{
+		db:              db,
+		postgres:        driver == DriverPostgres,
+		queue:           make(chan Record, defaultQueueSize),
+		cleanupInterval: defaultCleanupInterval,
+		logger:          logger,
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	if err := a.migrate(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrate archive db: %w", err)
+	}
+	return a, nil
+}
+
+// Inbound 实现 wework.Archive
+func (a *Archiver) Inbound(_ context.Context, agent string, msg wework.Message) {
+	a.enqueue(Record{
+		Direction: DirectionInbound,
+		Agent:     agent,
+		MsgID:     msg.MsgID,
+		UserID:    msg.FromUserName,
+		ChatID:    msg.ChatID,
+		MsgType:   msg.MsgType,
+		Content:   summary(msg),
+		CreatedAt: time.Now(),
+	})
+}
+
+// Outbound 实现 wework.Archive，记录关联到触发回复的入站消息
+func (a *Archiver) Outbound(_ context.Context, agent string, msg wework.Message, reply string) {
+	a.enqueue(Record{
+		Direction: DirectionOutbound,
+		Agent:     agent,
+		MsgID:     msg.MsgID,
+		UserID:    msg.FromUserName,
+		ChatID:    msg.ChatID,
+		MsgType:   wework.MsgTypeText,
+		Content:   reply,
+		CreatedAt: time.Now(),
+	})
+}
+
+func (a *Archiver) enqueue(rec Record) {
+	select {
+	case a.queue <- rec:
+	default:
+		a.logger.Warn("archive queue full, dropping record", "direction", rec.Direction, "msg_id", rec.MsgID)
+	}
+}
+
+// Len 返回等待写入的记录数
+func (a *Archiver) Len() int {
+	return len(a.queue)
+}
+
+// Run 写入队列中的记录并定期清理过期记录，ctx 取消后写完剩余记录再返回
+func (a *Archiver) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.cleanupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case rec := <-a.queue:
+			a.write(ctx, rec)
+		case <-ticker.C:
+			a.purge(ctx)
+		case <-ctx.Done():
+			a.drain()
+			return
+		}
+	}
+}
+
+// drain 写入队列中剩余的记录
+func (a *Archiver) drain() {
+	ctx := context.Background()
+	for {
+		select {
+		case rec := <-a.queue:
+			a.write(ctx, rec)
+		default:
+			return
+		}
+	}
+}
+
+func (a *Archiver) write(ctx context.Context, rec Record) {
+	_, err := a.db.ExecContext(context.WithoutCancel(ctx), a.rebind(`INSERT INTO messages
+		(direction, agent, msg_id, user_id, chat_id, msg_type, content, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`),
+		rec.Direction, rec.Agent, rec.MsgID, rec.UserID, rec.ChatID, rec.MsgType, rec.Content, rec.CreatedAt.UnixMilli(),
+	)
+	if err != nil {
+		a.logger.Error("failed to archive message", "direction", rec.Direction, "msg_id", rec.MsgID, "error", err)
+	}
+}
+
+// Import 同步写入一批记录，已存在相同方向和 msg_id 的记录时跳过，用于可重复执行的批量导入
+func (a *Archiver) Import(ctx context.Context, recs []Record) error {
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin import: %w", err)
+	}
+	defer tx.Rollback()
+
+	exists := a.rebind(`SELECT COUNT(*) FROM messages WHERE msg_id = ? AND direction = ?`)
+	insert := a.rebind(`INSERT INTO messages
+		(direction, agent, msg_id, user_id, chat_id, msg_type, content, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`)
+	for _, rec := range recs {
+		var n int
+		if err := tx.QueryRowContext(ctx, exists, rec.MsgID, rec.Direction).Scan(&n); err != nil {
+			return fmt.Errorf("check message %s: %w", rec.MsgID, err)
+		}
+		if n > 0 {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, insert,
+			rec.Direction, rec.Agent, rec.MsgID, rec.UserID, rec.ChatID, rec.MsgType, rec.Content, rec.CreatedAt.UnixMilli(),
+		); err != nil {
+			return fmt.Errorf("import message %s: %w", rec.MsgID, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit import: %w", err)
+	}
+	return nil
+}
+
+// purge 删除超过保留时长的记录
+func (a *Archiver) purge(ctx context.Context) {
+	if a.retention <= 0 {
+		return
+	}
+	cutoff := time.Now().Add(-a.retention).UnixMilli()
+	res, err := a.db.ExecContext(ctx, a.rebind(`DELETE FROM messages WHERE created_at < ?`), cutoff)
+	if err != nil {
+		a.logger.Error("failed to purge archived messages", "error", err)
+		return
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		a.logger.Info("archived messages purged", "count", n, "retention", a.retention)
+	}
+}
+
+// Ping 检查数据库连通性
+func (a *Archiver) Ping(ctx context.Context) error {
+	return a.db.PingContext(ctx)
+}
+
+// Close 关闭数据库连接，应在 Run 返回后调用
+func (a *Archiver) Close() error {
+	return a.db.Close()
+}
+
+// summary 归档的消息内容，非文本消息记录关键字段
+func summary(msg wework.Message) string {
+	switch msg.MsgType {
+	case wework.MsgTypeImage:
+		return msg.PicURL
+	case wework.MsgTypeVoice, wework.MsgTypeVideo, wework.MsgTypeFile:
+		return msg.MediaID
+	case wework.MsgTypeLocation:
+		return fmt.Sprintf("%s (%f,%f)", msg.Label, msg.LocationX, msg.LocationY)
+	case wework.MsgTypeLink:
+		return msg.Title + " " + msg.URL
+	}
+	return msg.Content
+}
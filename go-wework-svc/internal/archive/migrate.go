@@ -0,0 +1,123 @@
+package archive
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// migration 一个版本的表结构变更，按方言提供语句
+type migration struct {
+	version  int
+	sqlite   []string
+	postgres []string
+}
+
+// migrations 按版本升序排列，已发布的版本不可修改，只能追加
+var migrations = []migration{
+	{
+		version: 1,
+		sqlite: []string{
+			`CREATE TABLE messages (
+				id         INTEGER PRIMARY KEY AUTOINCREMENT,
+				direction  TEXT    NOT NULL,
+				agent      TEXT    NOT NULL DEFAULT '',
+				msg_id     TEXT    NOT NULL DEFAULT '',
+				user_id    TEXT    NOT NULL DEFAULT '',
+				msg_type   TEXT    NOT NULL DEFAULT '',
+				content    TEXT    NOT NULL DEFAULT '',
+				created_at INTEGER NOT NULL
+			)`,
+			`CREATE INDEX idx_messages_user_time ON messages (user_id, created_at)`,
+			`CREATE INDEX idx_messages_time ON messages (created_at)`,
+			`CREATE INDEX idx_messages_msg_id ON messages (msg_id)`,
+		},
+		postgres: []string{
+			`CREATE TABLE messages (
+				id         BIGSERIAL PRIMARY KEY,
+				direction  TEXT   NOT NULL,
+				agent      TEXT   NOT NULL DEFAULT '',
+				msg_id     TEXT   NOT NULL DEFAULT '',
+				user_id    TEXT   NOT NULL DEFAULT '',
+				msg_type   TEXT   NOT NULL DEFAULT '',
+				content    TEXT   NOT NULL DEFAULT '',
+				created_at BIGINT NOT NULL
+			)`,
+			`CREATE INDEX idx_messages_user_time ON messages (user_id, created_at)`,
+			`CREATE INDEX idx_messages_time ON messages (created_at)`,
+			`CREATE INDEX idx_messages_msg_id ON messages (msg_id)`,
+		},
+	},
+	{
+		version:  2,
+		sqlite:   []string{`ALTER TABLE messages ADD COLUMN chat_id TEXT NOT NULL DEFAULT ''`},
+		postgres: []string{`ALTER TABLE messages ADD COLUMN chat_id TEXT NOT NULL DEFAULT ''`},
+	},
+}
+
+// migrate 执行尚未应用的迁移，每个版本在独立事务中完成
+func (a *Archiver) migrate(ctx context.Context) error {
+	if _, err := a.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at BIGINT  NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	var current int
+	if err := a.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("query schema version: %w", err)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		stmts := m.sqlite
+		if a.postgres {
+			stmts = m.postgres
+		}
+		if err := a.apply(ctx, m.version, stmts); err != nil {
+			return fmt.Errorf("apply migration %d: %w", m.version, err)
+		}
+		a.logger.Info("archive migration applied", "version", m.version)
+	}
+	return nil
+}
+
+func (a *Archiver) apply(ctx context.Context, version int, stmts []string) error {
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range stmts {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, a.rebind(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`),
+		version, time.Now().UnixMilli()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// rebind 将 ? 占位符转换为 postgres 的 $n 形式
+func (a *Archiver) rebind(query string) string {
+	if !a.postgres {
+		return query
+	}
+	out := make([]byte, 0, len(query)+8)
+	n := 0
+	for i := 0; i < len(query); i++ {
+		if query[i] == '?' {
+			n++
+			out = fmt.Appendf(out, "$%d", n)
+			continue
+		}
+		out = append(out, query[i])
+	}
+	return string(out)
+}
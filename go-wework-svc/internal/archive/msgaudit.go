@@ -0,0 +1,291 @@
+package archive
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"go-wework-svc/internal/store"
+)
+
+const (
+	defaultIngestInterval = time.Minute
+	defaultIngestBatch    = 100
+
+	// ingestCursorKey 已导入的最大 seq，多副本共享
+	ingestCursorKey = "msgaudit:seq"
+	ingestLockKey   = "msgaudit:lock"
+)
+
+// ChatData 会话存档 GetChatData 返回的一条加密记录
+type ChatData struct {
+	Seq              uint64 `json:"seq"`
+	MsgID            string `json:"msgid"`
+	PublicKeyVer     int    `json:"publickey_ver"`
+	EncryptRandomKey string `json:"encrypt_random_key"`
+	EncryptChatMsg   string `json:"encrypt_chat_msg"`
+}
+
+// ChatSource 会话存档数据来源，对应官方 SDK 的 GetChatData 和 DecryptData
+// SDK 只提供 C 库，通常以 sidecar 部署，见 client.MsgAuditBridge
+type ChatSource interface {
+	// ChatData 拉取 seq 之后的最多 limit 条记录
+	ChatData(ctx context.Context, seq uint64, limit int) ([]ChatData, error)
+	// Decrypt 用 RSA 解密后的 random key 解密 encrypt_chat_msg，返回明文 JSON
+	Decrypt(ctx context.Context, randomKey, encryptChatMsg string) ([]byte, error)
+}
+
+// chatMessage 会话存档明文，只解析归档所需字段，非文本消息保存原始 JSON
+type chatMessage struct {
+	MsgID   string   `json:"msgid"`
+	Action  string   `json:"action"` // send | recall | switch
+	From    string   `json:"from"`
+	ToList  []string `json:"tolist"`
+	RoomID  string   `json:"roomid"`
+	MsgTime int64    `json:"msgtime"` // 毫秒
+	MsgType string   `json:"msgtype"`
+	Text    struct {
+		Content string `json:"content"`
+	} `json:"text"`
+}
+
+// Ingester 定期从会话存档拉取聊天记录，解密后写入归档库，包括成员之间以及成员与机器人的对话
+// 进度保存在共享 Store 中，多副本部署时每轮只有一个副本拉取
+type Ingester struct {
+	src      ChatSource
+	archiver *Archiver
+	keys     map[int]*rsa.PrivateKey // publickey_ver → 私钥
+	kv       store.Store
+	lock     *store.Lock
+	interval time.Duration
+	batch    int
+	logger   *slog.Logger
+}
+
+// IngestOption Ingester 的可选配置
+type IngestOption func(*Ingester)
+
+// WithIngestInterval 设置拉取周期
+func WithIngestInterval(d time.Duration) IngestOption {
+	return func(i *Ingester) {
+		if d > 0 {
+			i.interval = d
+		}
+	}
+}
+
+// WithIngestBatch 设置每次拉取的条数，SDK 上限为 1000
+func WithIngestBatch(n int) IngestOption {
+	return func(i *Ingester) {
+		if n > 0 {
+			i.batch = n
+		}
+	}
+}
+
+// NewIngester 创建会话存档拉取器，keys 为管理后台配置的各版本公钥对应的私钥
+func NewIngester(src ChatSource, a *Archiver, keys map[int]*rsa.PrivateKey, kv store.Store, logger *slog.Logger, opts ...IngestOption) *Ingester {
+	i := &Ingester{
+		src:      src,
+		archiver: a,
+		keys:     keys,
+		kv:       kv,
+		interval: defaultIngestInterval,
+		batch:    defaultIngestBatch,
+		logger:   logger,
+	}
+	for _, opt := range opts {
+		opt(i)
+	}
+	// 每批拉取前续期，持有者异常退出后租约过期，其他副本可接手
+	i.lock = store.NewLock(kv, ingestLockKey, 2*i.interval)
+	return i
+}
+
+// Run 按周期拉取直到 ctx 取消
+func (i *Ingester) Run(ctx context.Context) {
+	ticker := time.NewTicker(i.interval)
+	defer ticker.Stop()
+	for {
+		i.pull(ctx)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// pull 拉取到没有新记录为止，每批拉取前续期租约
+// 游标按读取时的值比较后写入，租约过期后仍在运行的旧持有者不会把游标写回较小的 seq
+func (i *Ingester) pull(ctx context.Context) {
+	lease, ok, err := i.lock.Acquire(ctx)
+	if err != nil {
+		i.logger.Warn("failed to acquire msgaudit lock", "error", err)
+		return
+	}
+	if !ok {
+		return
+	}
+	defer lease.Release(context.WithoutCancel(ctx))
+
+	seq, err := i.cursor(ctx)
+	if err != nil {
+		i.logger.Error("failed to load msgaudit cursor", "error", err)
+		return
+	}
+	for ctx.Err() == nil {
+		held, err := lease.Renew(ctx)
+		if err != nil {
+			i.logger.Warn("failed to renew msgaudit lock", "error", err)
+			return
+		}
+		if !held {
+			i.logger.Warn("msgaudit lock lost, stopping this run", "seq", seq)
+			return
+		}
+		n, next, err := i.ingest(ctx, seq)
+		if next > seq {
+			saved, err := store.CompareAndSet(ctx, i.kv, ingestCursorKey, formatSeq(seq), formatSeq(next), 0)
+			if err != nil {
+				i.logger.Error("failed to save msgaudit cursor", "seq", next, "error", err)
+				return
+			}
+			if !saved {
+				i.logger.Warn("msgaudit cursor moved by another replica, stopping this run", "seq", next)
+				return
+			}
+			seq = next
+		}
+		if err != nil {
+			i.logger.Error("msgaudit ingest failed", "seq", seq, "error", err)
+			return
+		}
+		if n < i.batch {
+			return
+		}
+	}
+}
+
+// ingest 拉取并导入一批记录，返回拉取条数和已处理到的 seq；出错时 seq 停在最后一条成功导入的记录
+func (i *Ingester) ingest(ctx context.Context, seq uint64) (int, uint64, error) {
+	items, err := i.src.ChatData(ctx, seq, i.batch)
+	if err != nil {
+		return 0, seq, fmt.Errorf("get chat data: %w", err)
+	}
+	recs := make([]Record, 0, len(items))
+	next := seq
+	var failed error
+	for _, item := range items {
+		rec, ok, err := i.decode(ctx, item)
+		if err != nil {
+			// 后续记录等下一轮重试，已解码的先导入
+			failed = err
+			break
+		}
+		if ok {
+			recs = append(recs, rec)
+		}
+		next = item.Seq
+	}
+	if len(recs) > 0 {
+		if err := i.archiver.Import(ctx, recs); err != nil {
+			return len(items), seq, err
+		}
+		i.logger.Info("msgaudit messages imported", "count", len(recs), "seq", next)
+	}
+	return len(items), next, failed
+}
+
+// decode 解密一条记录，ok 为 false 表示无需归档（如切换企业日志）或无法解密需跳过
+func (i *Ingester) decode(ctx context.Context, item ChatData) (Record, bool, error) {
+	key, ok := i.keys[item.PublicKeyVer]
+	if !ok {
+		// 缺少私钥时重试也无法解密，跳过并记录
+		i.logger.Error("no private key for msgaudit publickey_ver, skipping", "publickey_ver", item.PublicKeyVer, "msg_id", item.MsgID)
+		return Record{}, false, nil
+	}
+	encKey, err := base64.StdEncoding.DecodeString(item.EncryptRandomKey)
+	if err != nil {
+		i.logger.Error("invalid msgaudit encrypt_random_key, skipping", "msg_id", item.MsgID, "error", err)
+		return Record{}, false, nil
+	}
+	randomKey, err := rsa.DecryptPKCS1v15(nil, key, encKey)
+	if err != nil {
+		i.logger.Error("failed to decrypt msgaudit random key, skipping", "msg_id", item.MsgID, "publickey_ver", item.PublicKeyVer, "error", err)
+		return Record{}, false, nil
+	}
+	plain, err := i.src.Decrypt(ctx, string(randomKey), item.EncryptChatMsg)
+	if err != nil {
+		return Record{}, false, fmt.Errorf("decrypt chat msg %s: %w", item.MsgID, err)
+	}
+
+	var m chatMessage
+	if err := json.Unmarshal(plain, &m); err != nil {
+		i.logger.Error("malformed msgaudit message, skipping", "msg_id", item.MsgID, "error", err)
+		return Record{}, false, nil
+	}
+	if m.Action == "switch" {
+		return Record{}, false, nil
+	}
+	rec := Record{
+		Direction: DirectionAudit,
+		MsgID:     m.MsgID,
+		UserID:    m.From,
+		ChatID:    m.RoomID,
+		MsgType:   m.MsgType,
+		Content:   m.Text.Content,
+		CreatedAt: time.UnixMilli(m.MsgTime),
+	}
+	if rec.ChatID == "" && len(m.ToList) > 0 {
+		rec.ChatID = m.ToList[0]
+	}
+	if m.MsgType != "text" {
+		rec.Content = string(plain)
+	}
+	return rec, true, nil
+}
+
+// formatSeq 游标在 Store 中的值，0 对应尚未保存游标
+func formatSeq(seq uint64) string {
+	if seq == 0 {
+		return ""
+	}
+	return strconv.FormatUint(seq, 10)
+}
+
+func (i *Ingester) cursor(ctx context.Context) (uint64, error) {
+	v, ok, err := i.kv.Get(ctx, ingestCursorKey)
+	if err != nil || !ok {
+		return 0, err
+	}
+	return strconv.ParseUint(v, 10, 64)
+}
+
+// ParsePrivateKey 解析 PEM 格式的 RSA 私钥，支持 PKCS#1 和 PKCS#8
+func ParsePrivateKey(data string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, errors.New("no pem block found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse private key: %w", err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is not rsa")
+	}
+	return rsaKey, nil
+}
@@ -0,0 +1,55 @@
+package archive
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"go-wework-svc/internal/ai"
+)
+
+// DirectionShadow 影子评估的对比记录，content 为 ai.ShadowRecord 的 JSON
+const DirectionShadow = "shadow"
+
+// SaveShadow 实现 ai.ShadowArchive，对比记录与消息一起异步落库并按保留时长清理
+func (a *Archiver) SaveShadow(rec ai.ShadowRecord) {
+	content, err := json.Marshal(rec)
+	if err != nil {
+		a.logger.Error("failed to marshal shadow record", "id", rec.ID, "error", err)
+		return
+	}
+	a.enqueue(Record{
+		Direction: DirectionShadow,
+		MsgID:     strconv.FormatUint(rec.ID, 10),
+		UserID:    rec.UserID,
+		ChatID:    rec.GroupID,
+		MsgType:   DirectionShadow,
+		Content:   string(content),
+		CreatedAt: rec.Time,
+	})
+}
+
+// RecentShadow 实现 ai.ShadowArchive，返回最近 limit 条对比记录，按时间倒序
+func (a *Archiver) RecentShadow(ctx context.Context, limit int) ([]ai.ShadowRecord, error) {
+	rows, err := a.db.QueryContext(ctx, a.rebind(`SELECT content FROM messages
+		WHERE direction = ? ORDER BY created_at DESC, id DESC LIMIT ?`), DirectionShadow, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query shadow records: %w", err)
+	}
+	defer rows.Close()
+	var recs []ai.ShadowRecord
+	for rows.Next() {
+		var content string
+		if err := rows.Scan(&content); err != nil {
+			return nil, fmt.Errorf("scan shadow record: %w", err)
+		}
+		var rec ai.ShadowRecord
+		if err := json.Unmarshal([]byte(content), &rec); err != nil {
+			a.logger.Warn("skipping malformed shadow record", "error", err)
+			continue
+		}
+		recs = append(recs, rec)
+	}
+	return recs, rows.Err()
+}
@@ -0,0 +1,194 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-wework-svc/internal/shared"
+	"go-wework-svc/internal/store"
+)
+
+// HMAC 签名请求头
+const (
+	HeaderKeyID     = "X-Key-Id"
+	HeaderTimestamp = "X-Timestamp" // Unix 秒
+	HeaderSignature = "X-Signature" // hex(HMAC-SHA256(secret, StringToSign))
+)
+
+const (
+	defaultHMACWindow = 5 * time.Minute
+	// maxSignedBody 签名请求的请求体上限，超出时返回 413
+	maxSignedBody = 1 << 20
+)
+
+// ErrBodyTooLarge 签名请求的请求体超过上限
+var ErrBodyTooLarge = errors.New("request body too large")
+
+// apiKey 一个已解析的管理 API 密钥
+type apiKey struct {
+	id       string
+	secret   []byte
+	role     Role
+	scopes   []string
+	hmacOnly bool
+}
+
+// APIKeys 基于静态密钥和 HMAC 签名的认证，供脚本和其他系统调用管理 API
+type APIKeys struct {
+	keys   map[string]*apiKey
+	window time.Duration
+	// nonces 记录窗口内已使用的签名，拒绝重放，多副本部署时应为共享存储
+	nonces store.Store
+	now    func() time.Time
+	logger *slog.Logger
+}
+
+// NewAPIKeys 根据配置创建 API 密钥认证，window 为 HMAC 时间戳允许的偏差，0 使用默认值
+func NewAPIKeys(cfgs []shared.APIKeyConfig, window time.Duration, nonces store.Store, logger *slog.Logger) (*APIKeys, error) {
+	if window <= 0 {
+		window = defaultHMACWindow
+	}
+	a := &APIKeys{
+		keys:   make(map[string]*apiKey, len(cfgs)),
+		window: window,
+		nonces: nonces,
+		now:    time.Now,
+		logger: logger,
+	}
+	for _, c := range cfgs {
+		role, err := ParseRole(c.Role)
+		if err != nil {
+			return nil, fmt.Errorf("api key %s: %w", c.ID, err)
+		}
+		a.keys[c.ID] = &apiKey{
+			id:       c.ID,
+			secret:   []byte(c.Secret),
+			role:     role,
+			scopes:   c.Scopes,
+			hmacOnly: c.HMACOnly,
+		}
+	}
+	return a, nil
+}
+
+// Authenticate 实现 Authenticator 接口
+// 携带 X-Signature 时按 HMAC 签名校验，否则读取 Authorization: Bearer 中的密钥
+func (a *APIKeys) Authenticate(r *http.Request) (*Identity, error) {
+	if r.Header.Get(HeaderSignature) != "" {
+		return a.authenticateHMAC(r)
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return nil, ErrUnauthenticated
+	}
+	// 遍历全部密钥做常量时间比较，避免通过响应时间推测密钥
+	var found *apiKey
+	for _, k := range a.keys {
+		if subtle.ConstantTimeCompare(k.secret, []byte(token)) == 1 {
+			found = k
+		}
+	}
+	if found == nil {
+		return nil, ErrUnauthenticated
+	}
+	if found.hmacOnly {
+		a.logger.Warn("api key requires hmac signature", "key_id", found.id, "path", r.URL.Path)
+		return nil, fmt.Errorf("%w: key %s requires hmac signature", ErrUnauthenticated, found.id)
+	}
+	return found.identity("api_key"), nil
+}
+
+// authenticateHMAC 校验 HMAC 签名，校验时读取请求体并放回；同一签名在时间窗口内只能使用一次
+func (a *APIKeys) authenticateHMAC(r *http.Request) (*Identity, error) {
+	k, ok := a.keys[r.Header.Get(HeaderKeyID)]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key id", ErrUnauthenticated)
+	}
+
+	ts, err := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid timestamp", ErrUnauthenticated)
+	}
+	if d := a.now().Sub(time.Unix(ts, 0)); d > a.window || d < -a.window {
+		a.logger.Warn("hmac timestamp out of window", "key_id", k.id, "skew", d)
+		return nil, fmt.Errorf("%w: timestamp out of window", ErrUnauthenticated)
+	}
+
+	var body []byte
+	if r.Body != nil {
+		body, err = io.ReadAll(http.MaxBytesReader(nil, r.Body, maxSignedBody))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				return nil, ErrBodyTooLarge
+			}
+			return nil, fmt.Errorf("read body: %w", err)
+		}
+		r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	got, err := hex.DecodeString(r.Header.Get(HeaderSignature))
+	if err != nil || !hmac.Equal(got, sign(k.secret, StringToSign(r, ts, body))) {
+		a.logger.Warn("hmac signature mismatch", "key_id", k.id, "path", r.URL.Path)
+		return nil, fmt.Errorf("%w: invalid signature", ErrUnauthenticated)
+	}
+
+	// 签名覆盖方法、路径、时间戳和请求体，相同签名即为重放；窗口外的时间戳已被拒绝，保留两倍窗口即可
+	first, err := a.nonces.SetNX(r.Context(), "hmac:"+k.id+":"+hex.EncodeToString(got), "1", 2*a.window)
+	if err != nil {
+		return nil, fmt.Errorf("check hmac replay: %w", err)
+	}
+	if !first {
+		a.logger.Warn("hmac request replayed", "key_id", k.id, "path", r.URL.Path)
+		return nil, fmt.Errorf("%w: replayed request", ErrUnauthenticated)
+	}
+	return k.identity("hmac"), nil
+}
+
+func (k *apiKey) identity(method string) *Identity {
+	return &Identity{
+		Subject: "apikey:" + k.id,
+		Name:    k.id,
+		Role:    k.role,
+		Method:  method,
+		Scopes:  k.scopes,
+	}
+}
+
+// StringToSign 返回 HMAC 签名内容：
+//
+//	METHOD \n PATH[?QUERY] \n TIMESTAMP \n hex(sha256(body))
+//
+// PATH 为客户端请求的原始路径，包含 server.base_path 前缀；
+// 服务端取 r.RequestURI（StripPrefix 不改写它），客户端构造的请求没有 RequestURI 时取 URL
+func StringToSign(r *http.Request, timestamp int64, body []byte) string {
+	uri := r.RequestURI
+	if uri == "" {
+		uri = r.URL.RequestURI()
+	}
+	bodyHash := sha256.Sum256(body)
+	return strings.Join([]string{
+		r.Method,
+		uri,
+		strconv.FormatInt(timestamp, 10),
+		hex.EncodeToString(bodyHash[:]),
+	}, "\n")
+}
+
+func sign(secret []byte, s string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(s))
+	return mac.Sum(nil)
+}
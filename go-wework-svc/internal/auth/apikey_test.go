@@ -0,0 +1,92 @@
+package auth_test
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	handler "go-wework-svc/internal/adapter/http"
+	"go-wework-svc/internal/auth"
+	"go-wework-svc/internal/shared"
+	"go-wework-svc/internal/store"
+)
+
+const testSecret = "s3cret"
+
+// signedRequest 按客户端视角构造签名请求，path 为客户端实际请求的完整路径
+func signedRequest(t *testing.T, method, path string, body []byte, ts int64) *http.Request {
+	t.Helper()
+	clientReq, err := http.NewRequest(method, "http://example.com"+path, nil)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	mac := hmac.New(sha256.New, []byte(testSecret))
+	mac.Write([]byte(auth.StringToSign(clientReq, ts, body)))
+
+	r := httptest.NewRequest(method, path, bytes.NewReader(body))
+	r.Header.Set(auth.HeaderKeyID, "ops")
+	r.Header.Set(auth.HeaderTimestamp, strconv.FormatInt(ts, 10))
+	r.Header.Set(auth.HeaderSignature, hex.EncodeToString(mac.Sum(nil)))
+	return r
+}
+
+func TestHMAC(t *testing.T) {
+	logger := slog.New(slog.DiscardHandler)
+	now := time.Now().Unix()
+
+	tests := []struct {
+		name     string
+		basePath string
+		path     string
+		body     []byte
+		ts       int64
+		replay   bool
+		want     int
+	}{
+		{name: "no base path", path: "/admin/status?verbose=1", body: []byte(`{"a":1}`), ts: now, want: http.StatusOK},
+		{name: "with base path", basePath: "/wework", path: "/wework/admin/status?verbose=1", body: []byte(`{"a":1}`), ts: now, want: http.StatusOK},
+		{name: "empty body under base path", basePath: "/wework", path: "/wework/admin/status", ts: now, want: http.StatusOK},
+		{name: "stale timestamp", path: "/admin/status", ts: now - int64((time.Hour).Seconds()), want: http.StatusUnauthorized},
+		{name: "replayed", basePath: "/wework", path: "/wework/admin/status", ts: now, replay: true, want: http.StatusUnauthorized},
+		{name: "body too large", path: "/admin/status", body: bytes.Repeat([]byte("x"), 2<<20), ts: now, want: http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := auth.NewAPIKeys([]shared.APIKeyConfig{
+				{ID: "ops", Secret: testSecret, Role: string(auth.RoleOperator), Scopes: []string{auth.ScopeAll}, HMACOnly: true},
+			}, 0, store.NewMemory(), logger)
+			if err != nil {
+				t.Fatalf("new api keys: %v", err)
+			}
+			ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !strings.HasPrefix(r.URL.Path, "/admin/") {
+					t.Errorf("path %q not stripped", r.URL.Path)
+				}
+			})
+			mux := http.NewServeMux()
+			mux.Handle("/admin/", auth.Require(keys, auth.RoleViewer, auth.ScopeAll, logger, ok))
+			h := handler.BasePath(mux, tt.basePath)
+
+			if tt.replay {
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, signedRequest(t, http.MethodPost, tt.path, tt.body, tt.ts))
+				if w.Code != http.StatusOK {
+					t.Fatalf("first request: got %d, want %d", w.Code, http.StatusOK)
+				}
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, signedRequest(t, http.MethodPost, tt.path, tt.body, tt.ts))
+			if w.Code != tt.want {
+				t.Fatalf("got %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+)
+
+// Authenticator 从请求中识别调用者身份
+type Authenticator interface {
+	// Authenticate 返回请求对应的身份，未携带有效凭证时返回 ErrUnauthenticated
+	Authenticate(r *http.Request) (*Identity, error)
+}
+
+// Chain 依次尝试多个认证方式，返回第一个识别出的身份
+type Chain []Authenticator
+
+// Authenticate 实现 Authenticator 接口，凭证无效以外的错误直接返回
+func (c Chain) Authenticate(r *http.Request) (*Identity, error) {
+	for _, a := range c {
+		id, err := a.Authenticate(r)
+		if err == nil {
+			return id, nil
+		}
+		if !errors.Is(err, ErrUnauthenticated) {
+			return nil, err
+		}
+	}
+	return nil, ErrUnauthenticated
+}
+
+// Require 要求请求已认证、角色不低于 role 且被授予 scope，身份写入请求 context
+func Require(authn Authenticator, role Role, scope string, logger *slog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := authn.Authenticate(r)
+		if errors.Is(err, ErrBodyTooLarge) {
+			http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err != nil {
+			if !errors.Is(err, ErrUnauthenticated) {
+				logger.Error("admin authentication failed", "error", err)
+			}
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !id.Role.Allows(role) || !id.HasScope(scope) {
+			logger.Warn("admin access denied",
+				"subject", id.Subject,
+				"method", id.Method,
+				"role", id.Role,
+				"required", role,
+				"scope", scope,
+				"path", r.URL.Path,
+			)
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithIdentity(r.Context(), id)))
+	})
+}
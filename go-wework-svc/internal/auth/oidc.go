@@ -0,0 +1,263 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+
+	"go-wework-svc/internal/shared"
+)
+
+const (
+	sessionCookie = "admin_session"
+	stateCookie   = "admin_oidc_state"
+
+	defaultSessionTTL = 8 * time.Hour
+	defaultRoleClaim  = "roles"
+	stateTTL          = 10 * time.Minute
+)
+
+// OIDC 基于 OpenID Connect 授权码流程的管理端登录
+// 登录成功后签发签名会话 Cookie，后续请求通过 Authenticate 识别身份
+type OIDC struct {
+	oauth2      oauth2.Config
+	verifier    *oidc.IDTokenVerifier
+	codec       *sessionCodec
+	roleClaim   string
+	roleMapping map[string]Role
+	sessionTTL  time.Duration
+	secure      bool
+	// adminPath 控制台路径，登录后跳转使用；以下路径均包含 server.base_path
+	adminPath string
+	// sessionPath 会话 Cookie 路径，覆盖 /admin/ 和 /debug/ 等需要管理员身份的路由
+	sessionPath string
+	// callbackPath state Cookie 路径，只在 IdP 回调时携带
+	callbackPath string
+	logger       *slog.Logger
+}
+
+// NewOIDC 通过 issuer 的 discovery 文档初始化 OIDC 登录，basePath 为 server.base_path
+func NewOIDC(ctx context.Context, cfg shared.OIDCConfig, basePath string, logger *slog.Logger) (*OIDC, error) {
+	provider, err := oidc.NewProvider(ctx, cfg.IssuerURL)
+	if err != nil {
+		return nil, fmt.Errorf("discover oidc provider: %w", err)
+	}
+
+	mapping := make(map[string]Role, len(cfg.RoleMapping))
+	for claim, name := range cfg.RoleMapping {
+		role, err := ParseRole(name)
+		if err != nil {
+			return nil, fmt.Errorf("role_mapping[%s]: %w", claim, err)
+		}
+		mapping[claim] = role
+	}
+
+	scopes := cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"profile", "email"}
+	}
+	roleClaim := cfg.RoleClaim
+	if roleClaim == "" {
+		roleClaim = defaultRoleClaim
+	}
+	ttl := cfg.SessionTTL
+	if ttl <= 0 {
+		ttl = defaultSessionTTL
+	}
+
+	return &OIDC{
+		oauth2: oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       append([]string{oidc.ScopeOpenID}, scopes...),
+		},
+		verifier:     provider.Verifier(&oidc.Config{ClientID: cfg.ClientID}),
+		codec:        &sessionCodec{secret: []byte(cfg.SessionSecret)},
+		roleClaim:    roleClaim,
+		roleMapping:  mapping,
+		sessionTTL:   ttl,
+		secure:       strings.HasPrefix(cfg.RedirectURL, "https://"),
+		adminPath:    basePath + "/admin/",
+		sessionPath:  basePath + "/",
+		callbackPath: basePath + "/admin/oauth/callback",
+		logger:       logger,
+	}, nil
+}
+
+// Authenticate 实现 Authenticator 接口，校验会话 Cookie
+func (o *OIDC) Authenticate(r *http.Request) (*Identity, error) {
+	c, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return nil, ErrUnauthenticated
+	}
+	s, err := o.codec.decode(c.Value, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnauthenticated, err)
+	}
+	return &Identity{
+		Subject: s.Subject,
+		Email:   s.Email,
+		Name:    s.Name,
+		Role:    s.Role,
+		Method:  "oidc",
+	}, nil
+}
+
+// HandleLogin 生成 state/nonce 并跳转到 IdP 登录页
+func (o *OIDC) HandleLogin(w http.ResponseWriter, r *http.Request) {
+	state, err := randomString()
+	if err != nil {
+		o.logger.Error("failed to generate oidc state", "error", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	nonce, err := randomString()
+	if err != nil {
+		o.logger.Error("failed to generate oidc nonce", "error", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     stateCookie,
+		Value:    state + "." + nonce,
+		Path:     o.callbackPath,
+		MaxAge:   int(stateTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   o.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, o.oauth2.AuthCodeURL(state, oidc.Nonce(nonce)), http.StatusFound)
+}
+
+// HandleCallback 处理 IdP 回调：校验 state → 换取 token → 校验 id_token → 映射角色 → 签发会话
+func (o *OIDC) HandleCallback(w http.ResponseWriter, r *http.Request) {
+	c, err := r.Cookie(stateCookie)
+	if err != nil {
+		http.Error(w, "missing login state", http.StatusBadRequest)
+		return
+	}
+	state, nonce, _ := strings.Cut(c.Value, ".")
+	if state == "" || r.URL.Query().Get("state") != state {
+		http.Error(w, "invalid login state", http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: stateCookie, Path: o.callbackPath, MaxAge: -1})
+
+	if e := r.URL.Query().Get("error"); e != "" {
+		o.logger.Warn("oidc login rejected by provider", "error", e)
+		http.Error(w, "login failed", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := o.oauth2.Exchange(r.Context(), r.URL.Query().Get("code"))
+	if err != nil {
+		o.logger.Warn("oidc code exchange failed", "error", err)
+		http.Error(w, "login failed", http.StatusUnauthorized)
+		return
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		o.logger.Warn("oidc token response missing id_token")
+		http.Error(w, "login failed", http.StatusUnauthorized)
+		return
+	}
+	idToken, err := o.verifier.Verify(r.Context(), rawIDToken)
+	if err != nil {
+		o.logger.Warn("oidc id_token verification failed", "error", err)
+		http.Error(w, "login failed", http.StatusUnauthorized)
+		return
+	}
+	if idToken.Nonce != nonce {
+		o.logger.Warn("oidc nonce mismatch", "subject", idToken.Subject)
+		http.Error(w, "login failed", http.StatusUnauthorized)
+		return
+	}
+
+	var claims map[string]any
+	if err := idToken.Claims(&claims); err != nil {
+		o.logger.Warn("failed to parse id_token claims", "error", err)
+		http.Error(w, "login failed", http.StatusUnauthorized)
+		return
+	}
+	role, ok := o.mapRole(claims[o.roleClaim])
+	if !ok {
+		o.logger.Warn("oidc login has no mapped role", "subject", idToken.Subject, "claim", o.roleClaim)
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	email, _ := claims["email"].(string)
+	name, _ := claims["name"].(string)
+	value, err := o.codec.encode(session{
+		Subject: idToken.Subject,
+		Email:   email,
+		Name:    name,
+		Role:    role,
+		Expiry:  time.Now().Add(o.sessionTTL).Unix(),
+	})
+	if err != nil {
+		o.logger.Error("failed to encode admin session", "error", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    value,
+		Path:     o.sessionPath,
+		MaxAge:   int(o.sessionTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   o.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	o.logger.Info("admin login", "subject", idToken.Subject, "email", email, "role", role)
+	http.Redirect(w, r, o.adminPath, http.StatusFound)
+}
+
+// HandleLogout 清除会话 Cookie
+func (o *OIDC) HandleLogout(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Path: o.sessionPath, MaxAge: -1})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// mapRole 将声明值（字符串或字符串数组）映射为最高权限的角色
+func (o *OIDC) mapRole(claim any) (Role, bool) {
+	var values []string
+	switch v := claim.(type) {
+	case string:
+		values = []string{v}
+	case []any:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+
+	var best Role
+	for _, v := range values {
+		if r, ok := o.roleMapping[v]; ok && roleLevels[r] > roleLevels[best] {
+			best = r
+		}
+	}
+	return best, best != ""
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("read random bytes: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
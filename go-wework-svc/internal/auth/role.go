@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Role 管理端角色，权限由低到高: viewer < operator < admin
+type Role string
+
+// 角色常量
+const (
+	RoleViewer   Role = "viewer"
+	RoleOperator Role = "operator"
+	RoleAdmin    Role = "admin"
+)
+
+var roleLevels = map[Role]int{
+	RoleViewer:   1,
+	RoleOperator: 2,
+	RoleAdmin:    3,
+}
+
+// ParseRole 解析角色名
+func ParseRole(s string) (Role, error) {
+	r := Role(s)
+	if _, ok := roleLevels[r]; !ok {
+		return "", fmt.Errorf("unknown role %q", s)
+	}
+	return r, nil
+}
+
+// Allows 判断当前角色是否满足所需角色
+func (r Role) Allows(required Role) bool {
+	return roleLevels[r] >= roleLevels[required] && roleLevels[required] > 0
+}
+
+// 管理 API 访问范围，API 密钥需显式授予，ScopeAll 表示不限
+const (
+	ScopeAll           = "*"
+	ScopeStatusRead    = "status:read"
+	ScopeShadowRead    = "shadow:read"
+	ScopeMessagesSend  = "messages:send"
+	ScopeLogWrite      = "log:write"
+	ScopeDLQRead       = "dlq:read"
+	ScopeDLQWrite      = "dlq:write"
+	ScopeScheduleRead  = "schedules:read"
+	ScopeScheduleWrite = "schedules:write"
+	ScopeDebug         = "debug"
+)
+
+// Identity 已认证的调用者身份
+type Identity struct {
+	Subject string `json:"subject"`
+	Email   string `json:"email,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Role    Role   `json:"role"`
+	Method  string `json:"method"` // 认证方式：oidc | api_key | hmac
+	// Scopes 为 nil 时不限制范围（OIDC 登录仅按角色授权）
+	Scopes []string `json:"scopes,omitempty"`
+}
+
+// HasScope 判断身份是否被授予 scope
+func (id *Identity) HasScope(scope string) bool {
+	if id.Scopes == nil {
+		return true
+	}
+	for _, s := range id.Scopes {
+		if s == ScopeAll || s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrUnauthenticated 请求未携带有效凭证
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+type identityKey struct{}
+
+// WithIdentity 将身份写入 context
+func WithIdentity(ctx context.Context, id *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// IdentityFrom 从 context 中取出身份
+func IdentityFrom(ctx context.Context) (*Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(*Identity)
+	return id, ok
+}
@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// session 签名 Cookie 中保存的登录会话
+type session struct {
+	Subject string `json:"sub"`
+	Email   string `json:"email,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Role    Role   `json:"role"`
+	Expiry  int64  `json:"exp"`
+}
+
+// sessionCodec 使用 HMAC-SHA256 对会话进行签名和校验，也用于签发网页授权的身份令牌
+// 格式: base64url(json) + "." + base64url(hmac)
+type sessionCodec struct {
+	secret []byte
+}
+
+func (c *sessionCodec) encode(s session) (string, error) {
+	return c.seal(s)
+}
+
+func (c *sessionCodec) decode(v string, now time.Time) (*session, error) {
+	var s session
+	if err := c.open(v, &s); err != nil {
+		return nil, err
+	}
+	if now.Unix() >= s.Expiry {
+		return nil, errors.New("session expired")
+	}
+	return &s, nil
+}
+
+// seal 签名任意 JSON 载荷
+func (c *sessionCodec) seal(v any) (string, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("marshal payload: %w", err)
+	}
+	p := base64.RawURLEncoding.EncodeToString(payload)
+	return p + "." + base64.RawURLEncoding.EncodeToString(c.sign(p)), nil
+}
+
+// open 校验签名并解码载荷到 out
+func (c *sessionCodec) open(v string, out any) error {
+	p, sig, ok := strings.Cut(v, ".")
+	if !ok {
+		return errors.New("malformed token")
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil {
+		return fmt.Errorf("decode signature: %w", err)
+	}
+	if !hmac.Equal(got, c.sign(p)) {
+		return errors.New("invalid signature")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(p)
+	if err != nil {
+		return fmt.Errorf("decode payload: %w", err)
+	}
+	if err := json.Unmarshal(payload, out); err != nil {
+		return fmt.Errorf("unmarshal payload: %w", err)
+	}
+	return nil
+}
+
+func (c *sessionCodec) sign(payload string) []byte {
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
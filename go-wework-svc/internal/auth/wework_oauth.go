@@ -0,0 +1,333 @@
+package auth
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-wework-svc/internal/shared"
+	"go-wework-svc/internal/store"
+)
+
+const (
+	// weworkAuthorizeURL 企业微信网页授权地址，须在企业微信内打开
+	weworkAuthorizeURL = "https://open.weixin.qq.com/connect/oauth2/authorize"
+
+	defaultIdentityTTL = 5 * time.Minute
+	// codeTTL 一次性登录码的有效期，Web 工具收到回跳后应立即兑换
+	codeTTL = time.Minute
+
+	// CodeParam 回跳 Web 工具时附带一次性登录码的查询参数，登录码通过 POST /oauth/identity 兑换身份令牌
+	CodeParam = "wework_code"
+
+	// oauthStateCookie 发起授权的浏览器持有的 state，回调时比对，防止登录 CSRF
+	oauthStateCookie = "wework_oauth_state"
+)
+
+// UserResolver 用网页授权 code 换取成员 UserId，非企业成员时返回空字符串
+type UserResolver interface {
+	UserID(ctx context.Context, code string) (string, error)
+}
+
+// OAuthApp 发起网页授权的应用
+type OAuthApp struct {
+	CorpID  string
+	AgentID int64
+	Users   UserResolver
+}
+
+// WebIdentity 网页授权后签发给 Web 工具的员工身份，UserID 与回调消息的 FromUserName 一致
+type WebIdentity struct {
+	UserID string `json:"user_id"`
+	CorpID string `json:"corp_id"`
+	Agent  string `json:"agent,omitempty"`
+	Expiry int64  `json:"exp"`
+}
+
+// oauthState 发起授权时保存的回跳信息
+type oauthState struct {
+	Agent    string `json:"agent"`
+	Redirect string `json:"redirect"`
+}
+
+// WeWorkOAuth 企业微信网页授权（snsapi_base），内部 Web 工具经本服务识别员工身份
+// 授权完成后回跳 Web 工具并附带一次性登录码，工具在服务端以 POST /oauth/identity 兑换短期签名的身份令牌，
+// 身份令牌不出现在浏览器地址栏和 Referer 中；之后通过 GET /oauth/identity 或共享密钥校验
+type WeWorkOAuth struct {
+	// apps 按应用名称索引，空字符串为默认应用
+	apps        map[string]OAuthApp
+	callbackURL string
+	// callbackPath、secure state Cookie 的路径和 Secure 属性，由 callbackURL 得出
+	callbackPath string
+	secure       bool
+	allowedHosts []string
+	codec        *sessionCodec
+	identityTTL  time.Duration
+	kv           store.Store
+	logger       *slog.Logger
+}
+
+// NewWeWorkOAuth 创建网页授权处理器，state 保存在共享 Store 中，多副本部署时任一副本均可处理回调
+func NewWeWorkOAuth(cfg shared.OAuthConfig, apps map[string]OAuthApp, kv store.Store, logger *slog.Logger) *WeWorkOAuth {
+	ttl := cfg.IdentityTTL
+	if ttl <= 0 {
+		ttl = defaultIdentityTTL
+	}
+	callbackPath, secure := "/", false
+	if u, err := url.Parse(cfg.CallbackURL); err == nil {
+		callbackPath = cmp.Or(u.Path, "/")
+		secure = u.Scheme == "https"
+	}
+	return &WeWorkOAuth{
+		apps:         apps,
+		callbackURL:  cfg.CallbackURL,
+		callbackPath: callbackPath,
+		secure:       secure,
+		allowedHosts: cfg.AllowedHosts,
+		codec:        &sessionCodec{secret: []byte(cfg.Secret)},
+		identityTTL:  ttl,
+		kv:           kv,
+		logger:       logger,
+	}
+}
+
+// HandleAuthorize GET /oauth/authorize?redirect_uri=...&agent=name 跳转到企业微信网页授权
+// redirect_uri 为授权完成后回跳的 Web 工具地址，须在 allowed_hosts 内
+func (o *WeWorkOAuth) HandleAuthorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	agent := q.Get("agent")
+	app, ok := o.apps[agent]
+	if !ok {
+		http.Error(w, fmt.Sprintf("agent %q has no oauth configured", agent), http.StatusNotFound)
+		return
+	}
+	redirect := q.Get("redirect_uri")
+	u, err := url.Parse(redirect)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || !slices.Contains(o.allowedHosts, u.Hostname()) {
+		http.Error(w, "redirect_uri must be an http(s) url on an allowed host", http.StatusBadRequest)
+		return
+	}
+
+	// 企业微信要求 state 只含字母和数字且不超过 128 字节
+	state, err := randomHex()
+	if err != nil {
+		o.logger.Error("failed to generate oauth state", "error", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	v, _ := json.Marshal(oauthState{Agent: agent, Redirect: redirect})
+	if err := o.kv.Set(r.Context(), o.stateKey(state), string(v), stateTTL); err != nil {
+		o.logger.Error("failed to save oauth state", "error", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    state,
+		Path:     o.callbackPath,
+		MaxAge:   int(stateTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   o.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	auth := url.Values{
+		"appid":         {app.CorpID},
+		"redirect_uri":  {o.callbackURL},
+		"response_type": {"code"},
+		"scope":         {"snsapi_base"},
+		"state":         {state},
+		"agentid":       {strconv.FormatInt(app.AgentID, 10)},
+	}
+	http.Redirect(w, r, weworkAuthorizeURL+"?"+auth.Encode()+"#wechat_redirect", http.StatusFound)
+}
+
+// HandleCallback GET /oauth/callback?code=...&state=... 换取成员 UserId 并带一次性登录码回跳 Web 工具
+// state 须与发起授权的浏览器持有的 Cookie 一致
+func (o *WeWorkOAuth) HandleCallback(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	state := q.Get("state")
+	c, err := r.Cookie(oauthStateCookie)
+	if err != nil || state == "" || subtle.ConstantTimeCompare([]byte(c.Value), []byte(state)) != 1 {
+		http.Error(w, "invalid login state", http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: oauthStateCookie, Path: o.callbackPath, MaxAge: -1})
+	v, ok, err := o.kv.Get(r.Context(), o.stateKey(state))
+	if err != nil {
+		o.logger.Error("failed to load oauth state", "error", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "invalid or expired login state", http.StatusBadRequest)
+		return
+	}
+	// state 只能使用一次
+	if err := o.kv.Delete(r.Context(), o.stateKey(state)); err != nil {
+		o.logger.Warn("failed to delete oauth state", "error", err)
+	}
+	var st oauthState
+	if err := json.Unmarshal([]byte(v), &st); err != nil {
+		http.Error(w, "invalid login state", http.StatusBadRequest)
+		return
+	}
+	app, ok := o.apps[st.Agent]
+	if !ok {
+		http.Error(w, "invalid login state", http.StatusBadRequest)
+		return
+	}
+
+	code := q.Get("code")
+	if code == "" {
+		// 用户拒绝授权时企业微信不返回 code
+		http.Error(w, "login cancelled", http.StatusUnauthorized)
+		return
+	}
+	userID, err := app.Users.UserID(r.Context(), code)
+	if err != nil {
+		o.logger.Warn("wework oauth code exchange failed", "agent", st.Agent, "error", err)
+		http.Error(w, "login failed", http.StatusUnauthorized)
+		return
+	}
+	if userID == "" {
+		http.Error(w, "only members of the organization can sign in", http.StatusForbidden)
+		return
+	}
+
+	tok, err := o.codec.seal(WebIdentity{
+		UserID: userID,
+		CorpID: app.CorpID,
+		Agent:  st.Agent,
+		Expiry: time.Now().Add(o.identityTTL).Unix(),
+	})
+	if err != nil {
+		o.logger.Error("failed to sign web identity", "error", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	loginCode, err := randomHex()
+	if err != nil {
+		o.logger.Error("failed to generate oauth login code", "error", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	if err := o.kv.Set(r.Context(), o.codeKey(loginCode), tok, codeTTL); err != nil {
+		o.logger.Error("failed to save oauth login code", "error", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	u, _ := url.Parse(st.Redirect)
+	rq := u.Query()
+	rq.Set(CodeParam, loginCode)
+	u.RawQuery = rq.Encode()
+
+	o.logger.Info("wework oauth login", "agent", st.Agent, "user_id", userID, "redirect_host", u.Hostname())
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
+
+// identityExchange POST /oauth/identity 的响应，Token 供后续 GET /oauth/identity 或以共享密钥自行校验
+type identityExchange struct {
+	Token string `json:"token"`
+	*WebIdentity
+}
+
+// HandleExchange POST /oauth/identity 兑换一次性登录码（表单字段 code），返回身份令牌和员工身份
+func (o *WeWorkOAuth) HandleExchange(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 4<<10)
+	loginCode := r.PostFormValue("code")
+	if loginCode == "" {
+		http.Error(w, "missing code", http.StatusBadRequest)
+		return
+	}
+	tok, ok, err := o.kv.Get(r.Context(), o.codeKey(loginCode))
+	if err != nil {
+		o.logger.Error("failed to load oauth login code", "error", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "invalid or expired code", http.StatusUnauthorized)
+		return
+	}
+	// 登录码只能兑换一次，并发兑换时只有先登记的请求成功
+	first, err := o.kv.SetNX(r.Context(), o.codeKey(loginCode)+":used", "1", codeTTL)
+	if err != nil {
+		o.logger.Error("failed to mark oauth login code used", "error", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !first {
+		http.Error(w, "invalid or expired code", http.StatusUnauthorized)
+		return
+	}
+	if err := o.kv.Delete(r.Context(), o.codeKey(loginCode)); err != nil {
+		o.logger.Warn("failed to delete oauth login code", "error", err)
+	}
+	id, err := o.Verify(tok, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(identityExchange{Token: tok, WebIdentity: id})
+}
+
+// HandleIdentity GET /oauth/identity 校验 Authorization: Bearer 中的身份令牌并返回员工身份
+func (o *WeWorkOAuth) HandleIdentity(w http.ResponseWriter, r *http.Request) {
+	tok, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		http.Error(w, "missing bearer token", http.StatusUnauthorized)
+		return
+	}
+	id, err := o.Verify(tok, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(id)
+}
+
+// Verify 校验身份令牌的签名和有效期
+func (o *WeWorkOAuth) Verify(tok string, now time.Time) (*WebIdentity, error) {
+	var id WebIdentity
+	if err := o.codec.open(tok, &id); err != nil {
+		return nil, err
+	}
+	if now.Unix() >= id.Expiry {
+		return nil, errors.New("identity expired")
+	}
+	return &id, nil
+}
+
+func (o *WeWorkOAuth) stateKey(state string) string {
+	return "oauth:state:" + state
+}
+
+func (o *WeWorkOAuth) codeKey(code string) string {
+	return "oauth:code:" + code
+}
+
+// randomHex 返回 32 位十六进制随机串，满足企业微信对 state 的字符要求
+func randomHex() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,122 @@
+// Package autoreply 按关键词或正则匹配消息并返回模板回复，命中时不再转发 AI
+package autoreply
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"text/template"
+
+	"go-wework-svc/internal/shared"
+)
+
+// Input 模板可用的字段
+type Input struct {
+	User    string   // 发送者 UserID
+	Content string   // 经过入站变换后的消息内容
+	Groups  []string // regex 规则的匹配分组，Groups[0] 为整个匹配
+}
+
+// rule 编译后的规则
+type rule struct {
+	match func(content string) ([]string, bool)
+	reply *template.Template
+}
+
+// Engine 自动回复规则集，规则可通过 Reload 在运行时替换
+type Engine struct {
+	rules  atomic.Pointer[[]rule]
+	logger *slog.Logger
+}
+
+// New 根据规则列表创建规则集，规则按声明顺序匹配
+func New(rules []shared.AutoReplyRule, logger *slog.Logger) (*Engine, error) {
+	e := &Engine{logger: logger}
+	if err := e.Reload(rules); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Reload 重新编译规则并原子替换，任一规则无效时保留原规则
+func (e *Engine) Reload(rules []shared.AutoReplyRule) error {
+	compiled := make([]rule, 0, len(rules))
+	for i, r := range rules {
+		c, err := compile(r)
+		if err != nil {
+			return fmt.Errorf("rule %d (%s): %w", i, r.Match, err)
+		}
+		compiled = append(compiled, c)
+	}
+	e.rules.Store(&compiled)
+	return nil
+}
+
+// Reply 返回第一条命中规则的回复，没有规则命中时 ok 为 false
+func (e *Engine) Reply(ctx context.Context, user, content string) (string, bool) {
+	content = strings.TrimSpace(content)
+	for _, r := range *e.rules.Load() {
+		groups, ok := r.match(content)
+		if !ok {
+			continue
+		}
+		var b strings.Builder
+		if err := r.reply.Execute(&b, Input{User: user, Content: content, Groups: groups}); err != nil {
+			// 模板执行失败时交给 AI 处理，避免回复残缺内容
+			e.logger.WarnContext(ctx, "auto reply template failed", "error", err)
+			return "", false
+		}
+		return b.String(), true
+	}
+	return "", false
+}
+
+func compile(r shared.AutoReplyRule) (rule, error) {
+	tmpl, err := template.New("reply").Option("missingkey=zero").Parse(r.Reply)
+	if err != nil {
+		return rule{}, fmt.Errorf("parse reply template: %w", err)
+	}
+	out := rule{reply: tmpl}
+
+	switch r.Match {
+	case shared.AutoReplyExact, shared.AutoReplyPrefix:
+		keywords := r.Keywords
+		if r.IgnoreCase {
+			keywords = make([]string, len(r.Keywords))
+			for i, kw := range r.Keywords {
+				keywords[i] = strings.ToLower(kw)
+			}
+		}
+		prefix := r.Match == shared.AutoReplyPrefix
+		out.match = func(content string) ([]string, bool) {
+			if r.IgnoreCase {
+				content = strings.ToLower(content)
+			}
+			for _, kw := range keywords {
+				if content == kw || (prefix && strings.HasPrefix(content, kw)) {
+					return nil, true
+				}
+			}
+			return nil, false
+		}
+	case shared.AutoReplyRegex:
+		pattern := r.Pattern
+		if r.IgnoreCase {
+			pattern = "(?i)" + pattern
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return rule{}, fmt.Errorf("compile pattern: %w", err)
+		}
+		out.match = func(content string) ([]string, bool) {
+			groups := re.FindStringSubmatch(content)
+			return groups, groups != nil
+		}
+	default:
+		return rule{}, fmt.Errorf("unknown match type %q", r.Match)
+	}
+	return out, nil
+}
@@ -0,0 +1,125 @@
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	handler "go-wework-svc/internal/adapter/http"
+	"go-wework-svc/internal/ai"
+	"go-wework-svc/internal/auth"
+	"go-wework-svc/internal/monitor"
+	"go-wework-svc/internal/outbox"
+	"go-wework-svc/internal/schedule"
+	"go-wework-svc/internal/shared"
+	"go-wework-svc/internal/store"
+	"go-wework-svc/internal/wework"
+)
+
+// adminDeps 管理端路由依赖的组件，未启用的组件为 nil
+type adminDeps struct {
+	monitor *monitor.Monitor
+	shadow  *ai.ShadowStore
+	// senders 按应用名称索引的主动消息发送器，空字符串为默认应用
+	senders map[string]wework.Sender
+	// level 全局日志级别，可通过 /admin/log-level 调整
+	level *slog.LevelVar
+	// events /admin/ws 和 /debug/stream 订阅的实时事件流
+	events *handler.DebugStream
+	// kv HMAC 签名重放检查使用的存储
+	kv store.Store
+	// outbox 失败转发记录，未启用 outbox 时为 nil
+	outbox outbox.Store
+	// scheduler 定时消息，未启用 schedule 时为 nil
+	scheduler *schedule.Scheduler
+}
+
+// adminEnabled 配置了任一管理端认证方式时才注册 /admin 和 /debug 路由
+func adminEnabled(cfg shared.AdminConfig) bool {
+	return cfg.OIDC.Enabled || len(cfg.APIKeys) > 0
+}
+
+// registerAdminRoutes 注册 /admin 下的登录、控制台页面与管理 API
+// OIDC 会话和 API 密钥可同时启用，所有管理 API（含 /debug）都经 require 按角色和范围授权
+func registerAdminRoutes(mux *http.ServeMux, cfg shared.AdminConfig, basePath string, deps adminDeps, logger *slog.Logger) error {
+	var authn auth.Chain
+	if cfg.OIDC.Enabled {
+		oidcAuth, err := auth.NewOIDC(context.Background(), cfg.OIDC, basePath, logger)
+		if err != nil {
+			return fmt.Errorf("init admin oidc: %w", err)
+		}
+		authn = append(authn, oidcAuth)
+
+		mux.HandleFunc("GET /admin/login", oidcAuth.HandleLogin)
+		mux.HandleFunc("GET /admin/oauth/callback", oidcAuth.HandleCallback)
+		mux.HandleFunc("POST /admin/logout", oidcAuth.HandleLogout)
+
+		dashboard := handler.NewDashboardHandler()
+		mux.Handle("GET /admin/{$}", dashboard)
+		mux.Handle("GET /admin/static/", dashboard)
+	}
+	if len(cfg.APIKeys) > 0 {
+		keys, err := auth.NewAPIKeys(cfg.APIKeys, cfg.HMACWindow, deps.kv, logger)
+		if err != nil {
+			return fmt.Errorf("init admin api keys: %w", err)
+		}
+		authn = append(authn, keys)
+	}
+	require := func(role auth.Role, scope string, h http.Handler) http.Handler {
+		return auth.Require(authn, role, scope, logger, h)
+	}
+
+	mux.Handle("GET /admin/whoami", require(auth.RoleViewer, auth.ScopeStatusRead, handler.NewWhoAmIHandler()))
+	mux.Handle("GET /admin/status", require(auth.RoleViewer, auth.ScopeStatusRead, handler.NewStatusHandler(deps.monitor)))
+
+	logLevel := handler.NewLogLevelHandler(deps.level, logger)
+	mux.Handle("GET /admin/log-level", require(auth.RoleViewer, auth.ScopeStatusRead, http.HandlerFunc(logLevel.HandleGet)))
+	mux.Handle("PUT /admin/log-level", require(auth.RoleOperator, auth.ScopeLogWrite, http.HandlerFunc(logLevel.HandlePut)))
+
+	// 连接需 viewer 角色，通过通道发送人工回复另需 operator 角色和 messages:send 范围
+	mux.Handle("GET /admin/ws", require(auth.RoleViewer, auth.ScopeStatusRead, handler.NewConsoleHandler(deps.events, deps.monitor, deps.senders, logger)))
+
+	if len(deps.senders) > 0 {
+		mux.Handle("POST /admin/messages", require(auth.RoleOperator, auth.ScopeMessagesSend, handler.NewMessageHandler(deps.senders, logger)))
+	}
+
+	if deps.shadow != nil {
+		h := handler.NewShadowHandler(deps.shadow)
+		mux.Handle("GET /admin/shadow", require(auth.RoleViewer, auth.ScopeShadowRead, http.HandlerFunc(h.HandleList)))
+		mux.Handle("GET /admin/shadow/report", require(auth.RoleViewer, auth.ScopeShadowRead, http.HandlerFunc(h.HandleReport)))
+		mux.Handle("GET /admin/shadow/{id}", require(auth.RoleViewer, auth.ScopeShadowRead, http.HandlerFunc(h.HandleGet)))
+	}
+
+	// 记录包含用户消息原文，查看需 dlq:read；重新入队、删除需 operator 角色和 dlq:write
+	if deps.outbox != nil {
+		h := handler.NewDLQHandler(deps.outbox, logger)
+		mux.Handle("GET /admin/dlq", require(auth.RoleViewer, auth.ScopeDLQRead, http.HandlerFunc(h.HandleList)))
+		mux.Handle("GET /admin/dlq/{id}", require(auth.RoleViewer, auth.ScopeDLQRead, http.HandlerFunc(h.HandleGet)))
+		mux.Handle("POST /admin/dlq/requeue", require(auth.RoleOperator, auth.ScopeDLQWrite, http.HandlerFunc(h.HandleRequeueDead)))
+		mux.Handle("POST /admin/dlq/{id}/requeue", require(auth.RoleOperator, auth.ScopeDLQWrite, http.HandlerFunc(h.HandleRequeue)))
+		mux.Handle("DELETE /admin/dlq", require(auth.RoleOperator, auth.ScopeDLQWrite, http.HandlerFunc(h.HandlePurge)))
+		mux.Handle("DELETE /admin/dlq/{id}", require(auth.RoleOperator, auth.ScopeDLQWrite, http.HandlerFunc(h.HandleDelete)))
+	}
+
+	// 创建、修改、删除和立即发送需 operator 角色和 schedules:write
+	if deps.scheduler != nil {
+		h := handler.NewScheduleHandler(deps.scheduler, logger)
+		mux.Handle("GET /admin/schedules", require(auth.RoleViewer, auth.ScopeScheduleRead, http.HandlerFunc(h.HandleList)))
+		mux.Handle("GET /admin/schedules/{name}", require(auth.RoleViewer, auth.ScopeScheduleRead, http.HandlerFunc(h.HandleGet)))
+		mux.Handle("PUT /admin/schedules/{name}", require(auth.RoleOperator, auth.ScopeScheduleWrite, http.HandlerFunc(h.HandlePut)))
+		mux.Handle("DELETE /admin/schedules/{name}", require(auth.RoleOperator, auth.ScopeScheduleWrite, http.HandlerFunc(h.HandleDelete)))
+		mux.Handle("POST /admin/schedules/{name}/run", require(auth.RoleOperator, auth.ScopeScheduleWrite, http.HandlerFunc(h.HandleRun)))
+	}
+
+	if cfg.Debug.Enabled {
+		debug := func(h http.Handler) http.Handler {
+			return require(auth.RoleAdmin, auth.ScopeDebug, h)
+		}
+		mux.Handle("GET /debug/runtime", debug(handler.NewRuntimeHandler()))
+		mux.Handle("/debug/pprof/", debug(handler.Pprof()))
+		mux.Handle("GET /debug/stream", debug(deps.events))
+	}
+
+	return nil
+}
@@ -0,0 +1,167 @@
+package bootstrap
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"slices"
+
+	"go-wework-svc/internal/adapter/client"
+	"go-wework-svc/internal/ai"
+	"go-wework-svc/internal/shared"
+	"go-wework-svc/internal/store"
+)
+
+// aiClient 主后端客户端，按 ai.provider 选择协议
+type aiClient interface {
+	ai.StreamingService
+	Update(cfg shared.AIConfig)
+	Ping(ctx context.Context) error
+}
+
+// aiBackend 组装好的 AI 服务及其底层客户端
+type aiBackend struct {
+	svc ai.Service
+	// shadow 未启用影子评估时为 nil
+	shadow *ai.ShadowStore
+
+	provider  string
+	primary   aiClient
+	fallbacks []fallbackClient
+	canary    *client.AIClient
+	candidate *client.AIClient
+	logger    *slog.Logger
+}
+
+// fallbackClient 降级链中的备用后端客户端
+type fallbackClient struct {
+	name     string
+	provider string
+	client   aiClient
+}
+
+// newAIClient 按 provider 创建主后端客户端，kv 保存 Dify 会话对应关系
+func newAIClient(cfg shared.AIConfig, kv store.Store, logger *slog.Logger) aiClient {
+	switch cfg.Provider {
+	case shared.AIProviderOpenAI:
+		return client.NewOpenAIClient(cfg, logger)
+	case shared.AIProviderOllama:
+		return client.NewOllamaClient(cfg, logger)
+	case shared.AIProviderDify:
+		return client.NewDifyClient(cfg, kv, logger)
+	case shared.AIProviderFastGPT:
+		return client.NewFastGPTClient(cfg, logger)
+	default:
+		return client.NewAIClient(cfg, logger)
+	}
+}
+
+// newAIService 组装 AI 服务：主后端 → 降级链（各后端带熔断）→ 灰度路由 → 影子评估
+// 灰度和影子评估的候选后端始终使用 assistant 协议；onServed 记录降级链中提供回复的后端，onBreaker 记录熔断状态变化
+func newAIService(cfg shared.AIConfig, kv store.Store, onServed func(name string, index int), onBreaker func(name string, open bool), logger *slog.Logger) *aiBackend {
+	b := &aiBackend{provider: cfg.Provider, primary: newAIClient(cfg, kv, logger), logger: logger}
+	b.svc = b.primary
+
+	if len(cfg.Fallbacks) > 0 {
+		providers := []ai.Provider{{Name: shared.AIFallbackPrimary, Service: newBreaker(shared.AIFallbackPrimary, b.primary, cfg.Breaker, onBreaker, logger)}}
+		for _, f := range cfg.Fallbacks {
+			fc := fallbackClient{name: f.Name, provider: f.Provider, client: newAIClient(f.AIConfig, kv, logger)}
+			b.fallbacks = append(b.fallbacks, fc)
+			providers = append(providers, ai.Provider{Name: f.Name, Service: newBreaker(f.Name, fc.client, f.Breaker, onBreaker, logger)})
+		}
+		b.svc = ai.NewFallbackChain(providers, onServed, logger)
+	}
+
+	if cfg.Canary.Enabled {
+		b.canary = client.NewAIClient(canaryClientConfig(cfg.Canary), logger)
+		b.svc = ai.NewCanaryRouter(b.svc, b.canary, cfg.Canary, logger)
+	}
+
+	if cfg.Shadow.Enabled {
+		b.candidate = client.NewAIClient(shadowClientConfig(cfg.Shadow), logger)
+		b.shadow = ai.NewShadowStore(cfg.Shadow.MaxRecords)
+		b.svc = ai.NewShadowRouter(b.svc, b.candidate, b.shadow, cfg.Shadow, logger)
+	}
+
+	return b
+}
+
+// newBreaker 为降级链中的后端加上熔断，未设置的阈值使用默认值
+func newBreaker(name string, svc ai.Service, cfg shared.BreakerConfig, onBreaker func(name string, open bool), logger *slog.Logger) *ai.Breaker {
+	failures := cmp.Or(cfg.Failures, defaultBreakerFailures)
+	cooldown := cmp.Or(cfg.Cooldown, defaultBreakerCooldown)
+	return ai.NewBreaker(svc, failures, cooldown, func(open bool) {
+		if open {
+			logger.Warn("ai provider breaker opened", "provider", name, "failures", failures, "cooldown", cooldown)
+		} else {
+			logger.Info("ai provider breaker closed", "provider", name)
+		}
+		if onBreaker != nil {
+			onBreaker(name, open)
+		}
+	})
+}
+
+// update 热更新各后端的地址、超时、重试和模型参数
+// 切换 provider、增删备用后端、启用或关闭灰度、影子评估及其比例需重启生效
+func (b *aiBackend) update(cfg shared.AIConfig) {
+	if cfg.Provider == b.provider {
+		b.primary.Update(cfg)
+	} else {
+		b.logger.Warn("ai provider changed, restart required to apply", "from", b.provider, "to", cfg.Provider)
+	}
+	for _, fc := range b.fallbacks {
+		i := slices.IndexFunc(cfg.Fallbacks, func(f shared.AIFallbackConfig) bool { return f.Name == fc.name })
+		switch {
+		case i < 0:
+			b.logger.Warn("ai fallback removed, restart required to apply", "fallback", fc.name)
+		case cfg.Fallbacks[i].Provider != fc.provider:
+			b.logger.Warn("ai fallback provider changed, restart required to apply", "fallback", fc.name)
+		default:
+			fc.client.Update(cfg.Fallbacks[i].AIConfig)
+		}
+	}
+	if len(cfg.Fallbacks) > len(b.fallbacks) {
+		b.logger.Warn("ai fallback added, restart required to apply")
+	}
+	if b.canary != nil && cfg.Canary.Enabled {
+		b.canary.Update(canaryClientConfig(cfg.Canary))
+	}
+	if b.candidate != nil && cfg.Shadow.Enabled {
+		b.candidate.Update(shadowClientConfig(cfg.Shadow))
+	}
+}
+
+func canaryClientConfig(cfg shared.CanaryConfig) shared.AIConfig {
+	return shared.AIConfig{
+		BaseURL: cfg.BaseURL,
+		Timeout: cfg.Timeout,
+		Retry:   cfg.Retry,
+	}
+}
+
+func shadowClientConfig(cfg shared.ShadowConfig) shared.AIConfig {
+	return shared.AIConfig{
+		BaseURL: cfg.BaseURL,
+		Timeout: cfg.Timeout,
+		Retry:   cfg.Retry,
+	}
+}
+
+// newResponseCache 返回为 AI 服务加上回复缓存的函数，未启用时返回 nil
+// 语义匹配的向量客户端由各应用共用，向量索引按应用分开
+func newResponseCache(cfg shared.ResponseCacheConfig, kv store.Store, onHit func(), logger *slog.Logger) func(string, ai.Service) ai.Service {
+	if !cfg.Enabled {
+		return nil
+	}
+	cfg.TTL = cmp.Or(cfg.TTL, defaultResponseCacheTTL)
+	cfg.Similarity = cmp.Or(cfg.Similarity, defaultResponseCacheSimilarity)
+	cfg.MaxEntries = cmp.Or(cfg.MaxEntries, defaultResponseCacheEntries)
+	var embedder ai.Embedder
+	if cfg.Embedding.BaseURL != "" {
+		embedder = client.NewEmbeddingClient(cfg.Embedding)
+	}
+	return func(name string, svc ai.Service) ai.Service {
+		return ai.NewResponseCache(svc, kv, name, cfg, embedder, onHit, logger)
+	}
+}
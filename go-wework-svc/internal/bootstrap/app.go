@@ -1,82 +1,1050 @@
 package bootstrap
 
 import (
+	"context"
+	"crypto/rsa"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"maps"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"slices"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"gopkg.in/natefinch/lumberjack.v2"
 
 	"go-wework-svc/internal/adapter/client"
+	grpcapi "go-wework-svc/internal/adapter/grpc"
 	handler "go-wework-svc/internal/adapter/http"
+	"go-wework-svc/internal/archive"
+	"go-wework-svc/internal/auth"
+	"go-wework-svc/internal/autoreply"
+	"go-wework-svc/internal/conversation"
+	"go-wework-svc/internal/errreport"
+	"go-wework-svc/internal/i18n"
+	"go-wework-svc/internal/leader"
+	"go-wework-svc/internal/moderation"
+	"go-wework-svc/internal/monitor"
+	"go-wework-svc/internal/outbox"
+	"go-wework-svc/internal/plugin"
+	"go-wework-svc/internal/publish"
+	"go-wework-svc/internal/schedule"
 	"go-wework-svc/internal/shared"
+	"go-wework-svc/internal/store"
+	"go-wework-svc/internal/transform"
+	"go-wework-svc/internal/version"
 	"go-wework-svc/internal/wework"
+	"go-wework-svc/internal/wework/token"
+)
+
+const (
+	// defaultPassiveTimeout 被动回复默认等待时长，需留出加密和网络传输的余量
+	defaultPassiveTimeout = 4 * time.Second
+	// defaultShutdownTimeout 优雅退出时等待进行中请求的默认时长
+	defaultShutdownTimeout = 15 * time.Second
+
+	defaultDedupTTL       = 5 * time.Minute
+	defaultReplayWindow   = 300 * time.Second
+	defaultOutboxInterval = 30 * time.Second
+	defaultOutboxBatch    = 20
+
+	defaultBreakerFailures = 5
+	defaultBreakerCooldown = 30 * time.Second
+
+	defaultStreamFlushInterval = 3 * time.Second
+	defaultStreamMinChars      = 200
+
+	defaultConversationTurns = 10
+	defaultConversationTTL   = 30 * time.Minute
+
+	defaultResponseCacheTTL        = 24 * time.Hour
+	defaultResponseCacheSimilarity = 0.92
+	defaultResponseCacheEntries    = 1000
+
+	defaultPublishInboundTopic  = "wework.inbound"
+	defaultPublishOutboundTopic = "wework.outbound"
+
+	defaultGRPCAddr       = ":9090"
+	defaultGRPCBufferSize = 100
+
+	defaultBackpressureQueueTimeout = time.Second
+
+	// defaultFileTextChars 文件正文的默认字符上限，约合一万多 token
+	defaultFileTextChars = 20000
+
+	// menuSyncTimeout 启动时同步应用菜单的超时
+	menuSyncTimeout = 30 * time.Second
+
+	// defaultSchedulePath 定时消息的默认 BoltDB 文件
+	defaultSchedulePath = "data/schedule.db"
+
+	// defaultLeaderTTL 选主租期，defaultLeaderKey 租约键名，defaultLeaderLease Kubernetes Lease 对象名称
+	defaultLeaderTTL   = 15 * time.Second
+	defaultLeaderKey   = "leader:wework-svc"
+	defaultLeaderLease = "wework-svc"
+
+	// defaultWelcomeInterval 同一用户进入应用时欢迎语的默认发送间隔
+	defaultWelcomeInterval = 24 * time.Hour
+
+	// defaultSignatureFailLimit 单 IP 签名失败上限，正常的企业微信回调不会签名失败
+	defaultSignatureFailLimit  = 10
+	defaultSignatureFailWindow = time.Minute
 )
 
 // App 应用程序，组装所有组件
 type App struct {
-	server *http.Server
-	logger *slog.Logger
+	listeners       []*listener
+	tls             *serverTLS // 为 nil 时未启用 TLS
+	logger          *slog.Logger
+	shutdownTimeout time.Duration
+	// drain HTTP 服务器停止后、后台任务停止前等待各应用的后台转发，使其归档、发布和 outbox 写入仍可完成
+	drain func(context.Context) error
+	// workers 后台任务，在 Run 期间运行，退出时先于 shutdownHooks 停止
+	workers []func(context.Context)
+	// shutdownHooks 在 HTTP 服务器停止后依次执行，用于刷新和释放后台组件
+	shutdownHooks []func(context.Context) error
+
+	// loadConfig 非空时收到 SIGHUP 重新加载配置，reloadHooks 依次应用可热更新的设置
+	loadConfig  func() (*shared.Config, error)
+	reloadHooks []func(*shared.Config)
+	reloadMu    sync.Mutex
 }
 
-// NewApp 初始化应用：slog logger → Crypto → AIClient → WeWork Service → HTTP Handler → 路由
-func NewApp(cfg *shared.Config) (*App, error) {
-	logger := initLogger(cfg.Log)
+// Option App 的可选配置
+type Option func(*App)
+
+// WithConfigReload 启用 SIGHUP 热加载，load 重新读取并校验配置
+func WithConfigReload(load func() (*shared.Config, error)) Option {
+	return func(a *App) { a.loadConfig = load }
+}
+
+// NewApp 初始化应用：slog logger → AIClient → 各应用的 Crypto / WeWork Service / HTTP Handler → 路由
+func NewApp(cfg *shared.Config, opts ...Option) (_ *App, err error) {
+	var level slog.LevelVar
+	level.Set(parseLevel(cfg.Log.Level))
+	logger, logFile := initLogger(cfg.Log, &level)
+	build := version.Get(cfg.Hash)
+	logger.Info("go-wework-svc starting",
+		"commit", build.Commit,
+		"build_time", build.BuildTime,
+		"go_version", build.GoVersion,
+		"config_hash", build.ConfigHash,
+	)
+
+	shutdownTracing, err := initTracing(context.Background(), cfg.Tracing)
+	if err != nil {
+		return nil, fmt.Errorf("init tracing: %w", err)
+	}
+	// closers 按打开顺序记录需要释放的资源：创建成功后作为关闭钩子依次执行，出错返回时逆序关闭
+	closers := []func(context.Context) error{shutdownTracing}
+	defer func() {
+		if err == nil {
+			return
+		}
+		for _, c := range slices.Backward(closers) {
+			c(context.Background())
+		}
+		if logFile != nil {
+			logFile.Close()
+		}
+	}()
+
+	kv, err := newStore(context.Background(), cfg.Store)
+	if err != nil {
+		return nil, fmt.Errorf("init store: %w", err)
+	}
+	closers = append(closers, func(context.Context) error { return kv.Close() })
+
+	mon := monitor.New()
+	var reporter *errreport.Reporter
+	if cfg.ErrorReport.Enabled {
+		reporter, err = newErrorReporter(cfg.ErrorReport, build.Commit, logger)
+		if err != nil {
+			return nil, fmt.Errorf("init error report: %w", err)
+		}
+		mon.RegisterQueue("error_report", reporter.Len)
+	}
+
+	backend := newAIService(cfg.AI, kv, mon.RecordAIProvider, mon.RecordAIBreaker, logger)
+	aiSvc := backend.svc
+	// backends 自定义了 AI 配置的应用和租户，热加载时按名称更新
+	backends := make(map[string]*aiBackend)
+
+	inbound, err := transform.New(cfg.Transform.Inbound, logger)
+	if err != nil {
+		return nil, fmt.Errorf("init inbound transform: %w", err)
+	}
+	outbound, err := transform.New(cfg.Transform.Outbound, logger)
+	if err != nil {
+		return nil, fmt.Errorf("init outbound transform: %w", err)
+	}
+	autoReply, err := autoreply.New(cfg.AutoReply.Rules, logger)
+	if err != nil {
+		return nil, fmt.Errorf("init auto reply: %w", err)
+	}
+	moderator := moderation.New(cfg.Moderation, logger)
+	var localizer *i18n.Localizer
+	if cfg.I18n.Enabled {
+		localizer, err = newLocalizer(cfg.I18n, cfg.WeWork, kv, logger)
+		if err != nil {
+			return nil, fmt.Errorf("init i18n: %w", err)
+		}
+	}
 
-	crypto, err := wework.NewCrypto(cfg.WeWork.Token, cfg.WeWork.EncodingAESKey, cfg.WeWork.CorpID)
+	processors, err := plugin.Build(cfg.Plugins, logger)
 	if err != nil {
-		return nil, fmt.Errorf("init crypto: %w", err)
+		return nil, fmt.Errorf("init plugins: %w", err)
+	}
+
+	var store outbox.Store
+	if cfg.Outbox.Enabled {
+		store, err = outbox.NewBoltStore(cfg.Outbox.Path)
+		if err != nil {
+			return nil, fmt.Errorf("init outbox: %w", err)
+		}
+		closers = append(closers, func(context.Context) error { return store.Close() })
+		mon.RegisterQueue("outbox", func() int {
+			n, _ := store.Len(context.Background())
+			return n
+		})
 	}
 
-	aiClient := client.NewAIClient(cfg.AI, logger)
+	var arch *archive.Archiver
+	if cfg.Archive.Enabled {
+		arch, err = archive.Open(context.Background(), cfg.Archive.Driver, cfg.Archive.DSN, logger,
+			archive.WithRetention(cfg.Archive.Retention, cfg.Archive.CleanupInterval),
+			archive.WithQueueSize(cfg.Archive.QueueSize),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("init archive: %w", err)
+		}
+		closers = append(closers, func(context.Context) error { return arch.Close() })
+		mon.RegisterQueue("archive", arch.Len)
+		if backend.shadow != nil {
+			if err := backend.shadow.Attach(context.Background(), arch); err != nil {
+				return nil, fmt.Errorf("init shadow records: %w", err)
+			}
+		}
+	}
+	var ingester *archive.Ingester
+	if cfg.Archive.MsgAudit.Enabled {
+		ingester, err = newIngester(cfg.Archive.MsgAudit, arch, kv, logger)
+		if err != nil {
+			return nil, fmt.Errorf("init msgaudit: %w", err)
+		}
+	}
+	var pub *publish.Publisher
+	if cfg.Publish.Enabled {
+		pub, err = newPublisher(cfg.Publish, logger)
+		if err != nil {
+			return nil, fmt.Errorf("init publish: %w", err)
+		}
+		closers = append(closers, func(context.Context) error { return pub.Close() })
+		mon.RegisterQueue("publish", pub.Len)
+	}
 
-	wwSvc := wework.NewService(crypto, aiClient, logger)
+	// /admin/ws 和 /debug/stream 的实时事件流，正文总是脱敏，规则沿用 wework.redaction（未配置时使用默认规则）
+	var liveEvents *handler.DebugStream
+	if adminEnabled(cfg.Admin) {
+		redactor, err := transform.NewRedactor(cfg.WeWork.Redaction)
+		if err != nil {
+			return nil, fmt.Errorf("init live events redaction: %w", err)
+		}
+		liveEvents = handler.NewDebugStream(func(s string) string {
+			return redactor.Transform(context.Background(), s)
+		}, logger)
+	}
+	observers := []wework.Observer{mon}
+	if liveEvents != nil {
+		observers = append(observers, liveEvents)
+	}
+	if reporter != nil {
+		observers = append(observers, reporter)
+	}
 
-	callbackHandler := handler.NewCallbackHandler(wwSvc, logger)
-	healthHandler := handler.NewHealthHandler()
+	svcOpts := []wework.Option{
+		wework.WithInboundTransformer(inbound),
+		wework.WithOutboundTransformer(outbound),
+		wework.WithAutoReply(autoReply),
+		wework.WithModerator(moderator),
+		wework.WithObserver(wework.Observers(observers...)),
+		wework.WithPanicHook(func(_ context.Context, task string, msg wework.Message, v any, stack []byte) {
+			mon.RecordPanic()
+			if reporter != nil {
+				reporter.Panic(task, msg, v, stack)
+			}
+		}),
+		wework.WithProcessors(processors...),
+		wework.WithCommand(statusCommand(mon)),
+	}
+	if localizer != nil {
+		svcOpts = append(svcOpts, wework.WithLocalizer(localizer))
+	}
+	if cfg.Conversation.Enabled {
+		maxTurns := cfg.Conversation.MaxTurns
+		if maxTurns <= 0 {
+			maxTurns = defaultConversationTurns
+		}
+		ttl := cfg.Conversation.TTL
+		if ttl <= 0 {
+			ttl = defaultConversationTTL
+		}
+		svcOpts = append(svcOpts, wework.WithHistory(newHistory(kv, cfg.Store, maxTurns, ttl)))
+	}
+	var archives []wework.Archive
+	if arch != nil {
+		archives = append(archives, arch)
+	}
+	if pub != nil {
+		archives = append(archives, pub)
+	}
+	var hub *grpcapi.Hub
+	if cfg.GRPC.Enabled {
+		bufferSize := cfg.GRPC.BufferSize
+		if bufferSize <= 0 {
+			bufferSize = defaultGRPCBufferSize
+		}
+		hub = grpcapi.NewHub(bufferSize, logger)
+		archives = append(archives, hub)
+	}
+	if liveEvents != nil {
+		archives = append(archives, liveEvents)
+	}
+	if len(archives) > 0 {
+		svcOpts = append(svcOpts, wework.WithArchive(wework.Archives(archives...)))
+	}
+
+	cbOpts := []handler.CallbackOption{handler.WithErrorObserver(mon.RecordCallbackError)}
+	security := func(_ context.Context, e handler.SecurityEvent) {
+		mon.RecordSecurityEvent(e.Reason, e.RemoteIP, e.Time)
+	}
+	var auditFile io.Closer
+	if a := cfg.Log.SecurityAudit; a.Enabled {
+		var out io.Writer
+		out, auditFile = logOutput(a.Output, a.File)
+		if auditFile != nil {
+			closers = append(closers, func(context.Context) error { return auditFile.Close() })
+		}
+		auditLog := handler.NewSecurityAuditLog(out)
+		security = func(_ context.Context, e handler.SecurityEvent) {
+			mon.RecordSecurityEvent(e.Reason, e.RemoteIP, e.Time)
+			auditLog.Record(e)
+		}
+	}
+	// 并发限制由所有应用的回调处理器和服务共享
+	if bp := cfg.Server.Backpressure; bp.MaxInFlight > 0 {
+		wait := bp.QueueTimeout
+		if wait <= 0 {
+			wait = defaultBackpressureQueueTimeout
+		}
+		limit := wework.NewBackpressure(bp.MaxInFlight, bp.MaxQueue, wait)
+		cbOpts = append(cbOpts, handler.WithBackpressure(limit))
+		svcOpts = append(svcOpts, wework.WithBackpressure(limit))
+		mon.RegisterQueue("callback_in_flight", limit.InFlight)
+		mon.RegisterQueue("callback_waiting", limit.Waiting)
+	}
+	if cfg.Server.MaxBodyBytes > 0 {
+		cbOpts = append(cbOpts, handler.WithMaxBodyBytes(cfg.Server.MaxBodyBytes))
+	}
+	var allowlist *handler.SourceAllowlist
+	if cfg.Server.CallbackAllowlist.Enabled {
+		allowlist = newSourceAllowlist(cfg.Server.CallbackAllowlist, cfg.WeWork, kv, logger)
+		cbOpts = append(cbOpts, handler.WithSourceAllowlist(allowlist))
+	}
+	if liveEvents != nil {
+		cbOpts = append(cbOpts, handler.WithRequestObserver(liveEvents.ObserveCallback))
+	}
+	cbDeps := callbackDeps{
+		kv:       kv,
+		outbox:   store,
+		opts:     svcOpts,
+		webhooks: newWebhooks(cfg.Webhooks),
+		cbOpts:   cbOpts,
+		cache:    newResponseCache(cfg.ResponseCache, kv, mon.RecordCacheHit, logger),
+		reporter: reporter,
+		security: security,
+		moderate: moderator,
+		logger:   logger,
+	}
 
 	mux := http.NewServeMux()
-	mux.Handle("/callback", callbackHandler)
+
+	// 默认应用使用 wework 顶层配置，其余应用和租户挂载在 /callback/{name}
+	cb, err := newCallback("", cfg.WeWork, aiSvc, cbDeps)
+	if err != nil {
+		return nil, err
+	}
+	mux.Handle("/callback", cb.handler)
+	services := map[string]wework.Service{"": cb.svc}
+	callbacks := map[string]*callback{"": cb}
+	var menuSyncs []func(context.Context)
+	if cb.syncMenu != nil {
+		menuSyncs = append(menuSyncs, cb.syncMenu)
+	}
+	senders := make(map[string]wework.Sender)
+	if cb.sender != nil {
+		senders[""] = cb.sender
+	}
+	jsapi := make(map[string]handler.JSAPISigner)
+	if cb.jsapi != nil {
+		jsapi[""] = *cb.jsapi
+	}
+	oauthApps := make(map[string]auth.OAuthApp)
+	if cb.oauth != nil {
+		oauthApps[""] = *cb.oauth
+	}
+	router := handler.NewCallbackRouter(logger)
+	mount := func(name, label string, wcfg shared.WeWorkConfig, aiCfg *shared.AIConfig) error {
+		deps := cbDeps
+		deps.logger = logger.With(label, name)
+		svcAI := aiSvc
+		if aiCfg != nil {
+			b := newAIService(*aiCfg, deps.kv, mon.RecordAIProvider, mon.RecordAIBreaker, deps.logger)
+			backends[name] = b
+			svcAI = b.svc
+		}
+		cb, err := newCallback(name, wcfg, svcAI, deps)
+		if err != nil {
+			return fmt.Errorf("%s %s: %w", label, name, err)
+		}
+		router.Register(name, cb.handler)
+		services[name] = cb.svc
+		callbacks[name] = cb
+		if cb.syncMenu != nil {
+			menuSyncs = append(menuSyncs, cb.syncMenu)
+		}
+		if cb.sender != nil {
+			senders[name] = cb.sender
+		}
+		if cb.jsapi != nil {
+			jsapi[name] = *cb.jsapi
+		}
+		if cb.oauth != nil {
+			oauthApps[name] = *cb.oauth
+		}
+		return nil
+	}
+	for _, a := range cfg.WeWork.Agents {
+		if err := mount(a.Name, "agent", cfg.WeWork.ForAgent(a), a.AI); err != nil {
+			return nil, err
+		}
+	}
+	for _, t := range cfg.Tenants {
+		if err := mount(t.Name, "tenant", t.WeWork, t.AI); err != nil {
+			return nil, err
+		}
+	}
+	mux.Handle("/callback/{name}", router)
+	if len(jsapi) > 0 {
+		mux.Handle("GET /jsapi/signature", handler.NewJSAPIHandler(jsapi, logger))
+	}
+	if cfg.OAuth.Enabled {
+		if len(oauthApps) == 0 {
+			return nil, fmt.Errorf("oauth: requires an agent configured with secret")
+		}
+		o := auth.NewWeWorkOAuth(cfg.OAuth, oauthApps, kv, logger)
+		mux.HandleFunc("GET /oauth/authorize", o.HandleAuthorize)
+		mux.HandleFunc("GET /oauth/callback", o.HandleCallback)
+		mux.HandleFunc("GET /oauth/identity", o.HandleIdentity)
+		mux.HandleFunc("POST /oauth/identity", o.HandleExchange)
+	}
+
+	if cfg.Suite.Enabled {
+		suiteOpts := append(slices.Clone(cbOpts), handler.WithSecurityObserver(func(ctx context.Context, e handler.SecurityEvent) {
+			e.Agent = "suite"
+			security(ctx, e)
+		}))
+		h, err := newSuiteCallback(cfg.Suite, apiBaseURL(cfg.WeWork), kv, suiteOpts, logger.With("suite", cfg.Suite.SuiteID))
+		if err != nil {
+			return nil, err
+		}
+		mux.Handle("/suite/callback", h)
+	}
+
+	// /health 保留兼容，与 /healthz 相同
+	healthHandler := handler.NewHealthHandler()
+	healthHandler.AddCheck("ai", backend.primary.Ping)
+	for _, name := range slices.Sorted(maps.Keys(backends)) {
+		healthHandler.AddCheck("ai:"+name, backends[name].primary.Ping)
+	}
+	if p, ok := kv.(interface{ Ping(context.Context) error }); ok {
+		healthHandler.AddCheck("store", p.Ping)
+	}
+	if arch != nil {
+		healthHandler.AddCheck("archive", arch.Ping)
+	}
 	mux.Handle("/health", healthHandler)
+	mux.Handle("GET /healthz", healthHandler)
+	mux.HandleFunc("GET /readyz", healthHandler.Ready)
+	versionHandler := handler.NewVersionHandler(cfg.Hash)
+	mux.Handle("GET /version", versionHandler)
+
+	var scheduler *schedule.Scheduler
+	var scheduleStore schedule.Store
+	if cfg.Schedule.Enabled {
+		scheduleStore, err = newScheduleStore(cfg.Schedule, cfg.Store, kv)
+		if err != nil {
+			return nil, fmt.Errorf("init schedule: %w", err)
+		}
+		closers = append(closers, func(context.Context) error { return scheduleStore.Close() })
+		scheduler = schedule.New(scheduleStore, senders, cbDeps.webhooks, kv, cfg.Schedule.MisfireGrace, logger.With("component", "schedule"))
+		if err := scheduler.Sync(cfg.Schedule.Jobs); err != nil {
+			return nil, fmt.Errorf("init schedule: %w", err)
+		}
+	}
 
-	server := &http.Server{
-		Addr:         cfg.Server.Addr,
-		Handler:      mux,
-		ReadTimeout:  cfg.Server.ReadTimeout,
-		WriteTimeout: cfg.Server.WriteTimeout,
+	if adminEnabled(cfg.Admin) {
+		deps := adminDeps{monitor: mon, shadow: backend.shadow, senders: senders, level: &level, events: liveEvents, kv: kv, outbox: store, scheduler: scheduler}
+		if err := registerAdminRoutes(mux, cfg.Admin, cfg.Server.BasePath, deps, logger); err != nil {
+			return nil, err
+		}
 	}
 
-	return &App{server: server, logger: logger}, nil
+	onPanic := func(r *http.Request, v any, stack []byte) {
+		mon.RecordPanic()
+		if reporter != nil {
+			reporter.Panic("http "+r.Method+" "+r.URL.Path, wework.Message{}, v, stack)
+		}
+	}
+	serverTLS, err := newServerTLS(cfg.Server.TLS, cfg.Server.ReadTimeout, logger)
+	if err != nil {
+		return nil, err
+	}
+	// 已在配置校验中解析过
+	proxies, _ := shared.ParsePrefixes(cfg.Server.TrustedProxies)
+	routes := handler.RouteTimeouts(mux, routeTimeouts(cfg.Server.RouteTimeouts))
+	root := handler.TrustedProxies(handler.AccessLog(handler.Recover(routes, logger, onPanic), logger), proxies)
+	listeners := newListeners(cfg.Server, root, serverTLS)
+	if liveEvents != nil {
+		for _, l := range listeners {
+			l.server.RegisterOnShutdown(liveEvents.Close)
+		}
+	}
+
+	shutdownTimeout := cfg.Server.ShutdownTimeout
+	if shutdownTimeout <= 0 {
+		shutdownTimeout = defaultShutdownTimeout
+	}
+
+	app := &App{
+		listeners:       listeners,
+		tls:             serverTLS,
+		logger:          logger,
+		shutdownTimeout: shutdownTimeout,
+		drain:           drainServices(services),
+	}
+
+	for _, opt := range opts {
+		opt(app)
+	}
+
+	// 可热更新的设置：回调 Token / EncodingAESKey、限流策略、日志级别、AI 后端地址 / 超时 / 重试、消息变换规则、自动回复规则
+	startSecrets := appSecrets(cfg)
+	app.reloadHooks = append(app.reloadHooks,
+		func(c *shared.Config) {
+			for name, w := range callbackConfigs(c) {
+				cb, ok := callbacks[name]
+				if !ok {
+					continue
+				}
+				cb.reloadRateLimit(w.RateLimit)
+				if err := cb.reloadKeys(w); err != nil {
+					logger.Error("failed to apply rotated callback keys", "agent", name, "error", err)
+				}
+			}
+			if !maps.Equal(appSecrets(c), startSecrets) {
+				logger.Warn("wework app secrets or agents changed, restart required to apply")
+			}
+		},
+		func(c *shared.Config) { level.Set(parseLevel(c.Log.Level)) },
+		func(c *shared.Config) { versionHandler.SetConfigHash(c.Hash) },
+		func(c *shared.Config) {
+			backend.update(c.AI)
+			for _, a := range c.WeWork.Agents {
+				if b, ok := backends[a.Name]; ok && a.AI != nil {
+					b.update(*a.AI)
+				}
+			}
+			for _, t := range c.Tenants {
+				if b, ok := backends[t.Name]; ok && t.AI != nil {
+					b.update(*t.AI)
+				}
+			}
+		},
+		func(c *shared.Config) {
+			if err := inbound.Reload(c.Transform.Inbound); err != nil {
+				logger.Error("failed to reload inbound transform", "error", err)
+			}
+			if err := outbound.Reload(c.Transform.Outbound); err != nil {
+				logger.Error("failed to reload outbound transform", "error", err)
+			}
+			if err := autoReply.Reload(c.AutoReply.Rules); err != nil {
+				logger.Error("failed to reload auto reply rules", "error", err)
+			}
+			moderator.Reload(c.Moderation)
+			if localizer != nil {
+				if err := localizer.Reload(c.I18n); err != nil {
+					logger.Error("failed to reload i18n messages", "error", err)
+				}
+			}
+			if scheduler != nil {
+				if err := scheduler.Sync(c.Schedule.Jobs); err != nil {
+					logger.Error("failed to reload schedule jobs", "error", err)
+				}
+			}
+		},
+	)
+
+	// 定期重新加载配置，拉取轮换后的外部密钥
+	if interval := cfg.Secrets.RefreshInterval; interval > 0 && app.loadConfig != nil {
+		app.workers = append(app.workers, func(ctx context.Context) {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					app.reload()
+				}
+			}
+		})
+	}
+
+	if store != nil {
+		interval := cfg.Outbox.Interval
+		if interval <= 0 {
+			interval = defaultOutboxInterval
+		}
+		batch := cfg.Outbox.BatchSize
+		if batch <= 0 {
+			batch = defaultOutboxBatch
+		}
+		// 按记录所属应用路由回对应的服务
+		redeliver := func(ctx context.Context, e outbox.Entry) error {
+			svc, ok := services[e.Agent]
+			if !ok {
+				return fmt.Errorf("unknown agent %q", e.Agent)
+			}
+			return svc.Redeliver(ctx, e)
+		}
+		redeliverer := outbox.NewRedeliverer(store, redeliver, interval, cfg.Outbox.MaxAttempts, batch, logger)
+		app.workers = append(app.workers, redeliverer.Run)
+	}
+
+	// 启用选主时单例任务只在主实例上运行，否则每个实例都运行
+	singleton := func(job func(context.Context)) { app.workers = append(app.workers, job) }
+	if cfg.Leader.Enabled {
+		elector, err := newElector(cfg.Leader, kv, logger)
+		if err != nil {
+			return nil, fmt.Errorf("init leader election: %w", err)
+		}
+		singleton = elector.Add
+		app.workers = append(app.workers, elector.Run)
+	}
+
+	// 菜单在启动后同步一次，失败只记录日志，不影响回调处理
+	for _, sync := range menuSyncs {
+		singleton(func(ctx context.Context) {
+			ctx, cancel := context.WithTimeout(ctx, menuSyncTimeout)
+			defer cancel()
+			sync(ctx)
+		})
+	}
+	if arch != nil {
+		app.workers = append(app.workers, arch.Run)
+		if ingester != nil {
+			singleton(ingester.Run)
+		}
+	}
+	if hub != nil {
+		grpcCfg := cfg.GRPC
+		if grpcCfg.Addr == "" {
+			grpcCfg.Addr = defaultGRPCAddr
+		}
+		app.workers = append(app.workers, grpcapi.NewServer(grpcCfg, hub, senders, logger).Run)
+	}
+	if pub != nil {
+		app.workers = append(app.workers, pub.Run)
+	}
+	if a := cfg.Alert; a.Enabled {
+		// 群机器人名称已在配置校验中检查
+		alerter := monitor.NewAlerter(mon, cbDeps.webhooks[a.Webhook], kv, monitor.AlertPolicy{
+			Interval:          a.Interval,
+			Cooldown:          a.Cooldown,
+			DLQThreshold:      a.DLQThreshold,
+			SignatureFailures: a.SignatureFailures,
+			Mentions:          a.Mentions,
+		}, logger)
+		singleton(alerter.Run)
+	}
+	if reporter != nil {
+		app.workers = append(app.workers, reporter.Run)
+	}
+	if allowlist != nil {
+		app.workers = append(app.workers, allowlist.Run)
+	}
+	if scheduler != nil {
+		singleton(scheduler.Run)
+	}
+	app.shutdownHooks = append(app.shutdownHooks, closers...)
+	if logFile != nil {
+		// 最后关闭，确保关闭过程中的日志都写入文件
+		app.shutdownHooks = append(app.shutdownHooks, func(context.Context) error { return logFile.Close() })
+	}
+
+	return app, nil
 }
 
-// Run 启动 HTTP 服务器
+// Run 启动 HTTP 服务器，收到 SIGINT/SIGTERM 后停止接收新连接并等待进行中的请求完成
 func (a *App) Run() error {
-	a.logger.Info("starting server", "addr", a.server.Addr)
-	return a.server.ListenAndServe()
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	hup := make(chan os.Signal, 1)
+	if a.loadConfig != nil {
+		signal.Notify(hup, syscall.SIGHUP)
+		defer signal.Stop(hup)
+	}
+
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	for _, w := range a.workers {
+		wg.Go(func() { w(workerCtx) })
+	}
+	defer func() {
+		stopWorkers()
+		wg.Wait()
+	}()
+
+	// 先监听全部地址，任一地址不可用时直接退出
+	lns := make([]net.Listener, 0, len(a.listeners))
+	for _, l := range a.listeners {
+		ln, err := listen(l.addr)
+		if err != nil {
+			for _, ln := range lns {
+				ln.Close()
+			}
+			return fmt.Errorf("listen %s: %w", l.addr, err)
+		}
+		lns = append(lns, ln)
+	}
+	errCh := make(chan error, len(a.listeners)+1)
+	for i, l := range a.listeners {
+		go func() {
+			a.logger.Info("starting server", "addr", l.addr, "tls", l.tls, "admin", l.admin)
+			if l.tls {
+				errCh <- l.server.ServeTLS(lns[i], a.tls.certFile, a.tls.keyFile)
+				return
+			}
+			errCh <- l.server.Serve(lns[i])
+		}()
+	}
+	if a.tls != nil && a.tls.challenge != nil {
+		go func() {
+			a.logger.Info("starting acme http challenge server", "addr", a.tls.challenge.Addr)
+			if err := a.tls.challenge.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				errCh <- fmt.Errorf("acme http challenge server: %w", err)
+			}
+		}()
+	}
+
+	// 任一服务器异常退出时同样走完关闭流程，排空后台任务并执行关闭钩子后再返回错误
+	var serveErr error
+	for running := true; running; {
+		select {
+		case serveErr = <-errCh:
+			a.logger.Error("server failed", "error", serveErr)
+			running = false
+		case <-hup:
+			a.reload()
+		case <-ctx.Done():
+			running = false
+		}
+	}
+
+	a.logger.Info("shutting down server", "timeout", a.shutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), a.shutdownTimeout)
+	defer cancel()
+	if a.tls != nil && a.tls.challenge != nil {
+		a.tls.challenge.Shutdown(shutdownCtx)
+	}
+	var wgServers sync.WaitGroup
+	shutdownErrs := make([]error, len(a.listeners))
+	for i, l := range a.listeners {
+		wgServers.Go(func() {
+			if err := l.server.Shutdown(shutdownCtx); err != nil {
+				shutdownErrs[i] = fmt.Errorf("%s: %w", l.addr, err)
+			}
+		})
+	}
+	wgServers.Wait()
+	// 服务器关闭失败时也要等待后台转发，未完成的写入 outbox
+	if err := a.drain(shutdownCtx); err != nil {
+		a.logger.Error("failed to drain agent services", "error", err)
+	}
+	stopWorkers()
+	wg.Wait()
+	for _, hook := range a.shutdownHooks {
+		if err := hook(shutdownCtx); err != nil {
+			a.logger.Error("shutdown hook failed", "error", err)
+		}
+	}
+	if err := errors.Join(shutdownErrs...); err != nil {
+		return errors.Join(serveErr, fmt.Errorf("shutdown server: %w", err))
+	}
+	if serveErr != nil {
+		return serveErr
+	}
+	a.logger.Info("server stopped")
+	return nil
 }
 
-// initLogger 根据配置初始化 slog logger
-func initLogger(cfg shared.LogConfig) *slog.Logger {
-	var level slog.Level
-	switch strings.ToLower(cfg.Level) {
-	case "debug":
-		level = slog.LevelDebug
-	case "warn":
-		level = slog.LevelWarn
-	case "error":
-		level = slog.LevelError
+// drainServices 并行等待各应用的后台任务，返回超时未完成的应用
+func drainServices(services map[string]wework.Service) func(context.Context) error {
+	return func(ctx context.Context) error {
+		errs := make([]error, 0, len(services))
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		for name, svc := range services {
+			wg.Go(func() {
+				if err := svc.Drain(ctx); err != nil {
+					mu.Lock()
+					errs = append(errs, fmt.Errorf("agent %q: %w", name, err))
+					mu.Unlock()
+				}
+			})
+		}
+		wg.Wait()
+		return errors.Join(errs...)
+	}
+}
+
+// reload 重新加载配置并应用可热更新的设置，配置无效时保持当前设置
+// 其余配置（监听地址、应用 secret、存储等）修改后需重启生效
+func (a *App) reload() {
+	a.reloadMu.Lock()
+	defer a.reloadMu.Unlock()
+
+	cfg, err := a.loadConfig()
+	if err != nil {
+		a.logger.Error("config reload rejected, keeping current settings", "error", err)
+		return
+	}
+	for _, hook := range a.reloadHooks {
+		hook(cfg)
+	}
+	a.logger.Info("config reloaded", "log_level", parseLevel(cfg.Log.Level), "config_hash", cfg.Hash)
+}
+
+// callbackConfigs 按名称返回默认应用、各应用和租户的企业微信配置
+func callbackConfigs(cfg *shared.Config) map[string]shared.WeWorkConfig {
+	configs := map[string]shared.WeWorkConfig{"": cfg.WeWork}
+	for _, a := range cfg.WeWork.Agents {
+		configs[a.Name] = cfg.WeWork.ForAgent(a)
+	}
+	for _, t := range cfg.Tenants {
+		configs[t.Name] = t.WeWork
+	}
+	return configs
+}
+
+// appSecrets 各应用和租户的 secret，用于检测热加载无法应用的变更（secret 轮换、增删应用）
+func appSecrets(cfg *shared.Config) map[string]string {
+	secrets := make(map[string]string)
+	for name, w := range callbackConfigs(cfg) {
+		secrets[name] = w.Secret
+	}
+	return secrets
+}
+
+// newHistory 创建会话历史，使用 Redis 时改用列表结构以保证多副本并发追加的原子性
+func newHistory(kv store.Store, cfg shared.StoreConfig, maxTurns int, ttl time.Duration) wework.History {
+	if r, ok := kv.(*store.Redis); ok {
+		return conversation.NewRedis(r.Client(), cfg.Redis.KeyPrefix, maxTurns, ttl)
+	}
+	return conversation.New(kv, maxTurns, ttl)
+}
+
+// newIngester 创建会话存档拉取器，私钥在启动时解析
+func newIngester(cfg shared.MsgAuditConfig, arch *archive.Archiver, kv store.Store, logger *slog.Logger) (*archive.Ingester, error) {
+	keys := make(map[int]*rsa.PrivateKey, len(cfg.PrivateKeys))
+	for ver, data := range cfg.PrivateKeys {
+		key, err := archive.ParsePrivateKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("private_keys[%d]: %w", ver, err)
+		}
+		keys[ver] = key
+	}
+	src := client.NewMsgAuditBridge(cfg.BridgeURL, cfg.BridgeToken)
+	return archive.NewIngester(src, arch, keys, kv, logger,
+		archive.WithIngestInterval(cfg.Interval),
+		archive.WithIngestBatch(cfg.BatchSize),
+	), nil
+}
+
+// newPublisher 按驱动创建消息发布器
+func newPublisher(cfg shared.PublishConfig, logger *slog.Logger) (*publish.Publisher, error) {
+	var sink publish.Sink
+	switch cfg.Driver {
+	case shared.PublishDriverKafka:
+		sink = publish.NewKafka(cfg.Kafka)
 	default:
-		level = slog.LevelInfo
+		n, err := publish.NewNATS(cfg.NATS, logger)
+		if err != nil {
+			return nil, err
+		}
+		sink = n
+	}
+	inbound := cfg.InboundTopic
+	if inbound == "" {
+		inbound = defaultPublishInboundTopic
+	}
+	outbound := cfg.OutboundTopic
+	if outbound == "" {
+		outbound = defaultPublishOutboundTopic
 	}
+	return publish.New(sink, inbound, outbound, cfg.QueueSize, logger), nil
+}
+
+// newSourceAllowlist 创建回调来源 IP 白名单，配置了 wework.secret 时定期拉取企业微信回调 IP 段
+func newSourceAllowlist(cfg shared.CallbackAllowlistConfig, wcfg shared.WeWorkConfig, kv store.Store, logger *slog.Logger) *handler.SourceAllowlist {
+	// 已在配置校验中解析过
+	static, _ := shared.ParsePrefixes(cfg.Static)
+	var source handler.CallbackIPSource
+	if wcfg.Secret != "" {
+		tokens := token.NewManager(apiBaseURL(wcfg), wcfg.CorpID, wcfg.Secret, kv, logger)
+		source = client.NewCallbackIPClient(apiBaseURL(wcfg), tokens)
+	}
+	return handler.NewSourceAllowlist(static, source, cfg.RefreshInterval, logger)
+}
+
+// newLocalizer 创建系统回复的多语言文案，配置了 contacts_attr 时按 wework 应用的通讯录查询成员语言
+func newLocalizer(cfg shared.I18nConfig, wcfg shared.WeWorkConfig, kv store.Store, logger *slog.Logger) (*i18n.Localizer, error) {
+	var source i18n.LanguageSource
+	if cfg.ContactsAttr != "" {
+		tokens := token.NewManager(apiBaseURL(wcfg), wcfg.CorpID, wcfg.Secret, kv, logger)
+		source = client.NewDirectory(apiBaseURL(wcfg), wcfg.CorpID, tokens, kv)
+	}
+	return i18n.New(cfg, source, logger)
+}
+
+// newScheduleStore 按驱动创建定时消息存储，redis 驱动使用共享存储的连接（已在配置校验中要求 redis）
+func newScheduleStore(cfg shared.ScheduleConfig, storeCfg shared.StoreConfig, kv store.Store) (schedule.Store, error) {
+	if r, ok := kv.(*store.Redis); ok && cfg.Driver == shared.ScheduleDriverRedis {
+		return schedule.NewRedisStore(r.Client(), storeCfg.Redis.KeyPrefix), nil
+	}
+	path := cfg.Path
+	if path == "" {
+		path = defaultSchedulePath
+	}
+	return schedule.NewBoltStore(path)
+}
 
+// newElector 按驱动创建选主器，store 驱动使用共享存储（已在配置校验中要求 redis）
+func newElector(cfg shared.LeaderConfig, kv store.Store, logger *slog.Logger) (*leader.Elector, error) {
+	ttl := cfg.TTL
+	if ttl <= 0 {
+		ttl = defaultLeaderTTL
+	}
+	identity := cfg.Identity
+	if identity == "" {
+		identity = leader.DefaultIdentity()
+	}
+	var lease leader.Lease
+	switch cfg.Driver {
+	case shared.LeaderDriverKubernetes:
+		name := cfg.Key
+		if name == "" {
+			name = defaultLeaderLease
+		}
+		l, err := leader.NewKubeLease(cfg.Namespace, name, identity, ttl)
+		if err != nil {
+			return nil, err
+		}
+		lease = l
+	default:
+		key := cfg.Key
+		if key == "" {
+			key = defaultLeaderKey
+		}
+		lease = leader.NewStoreLease(kv, key, identity, ttl)
+	}
+	return leader.NewElector(lease, ttl, logger.With("component", "leader", "identity", identity)), nil
+}
+
+// newErrorReporter 按驱动创建错误上报器，release 为构建的 commit
+func newErrorReporter(cfg shared.ErrorReportConfig, release string, logger *slog.Logger) (*errreport.Reporter, error) {
+	var sink errreport.Sink
+	switch cfg.Driver {
+	case "webhook":
+		sink = errreport.NewWebhookSink(cfg.URL, release)
+	default:
+		s, err := errreport.NewSentrySink(cfg.DSN, cfg.Environment, release)
+		if err != nil {
+			return nil, err
+		}
+		sink = s
+	}
+	return errreport.New(sink, cfg.QueueSize, logger), nil
+}
+
+// newStore 根据配置创建共享状态存储
+func newStore(ctx context.Context, cfg shared.StoreConfig) (store.Store, error) {
+	if cfg.Driver == shared.StoreDriverRedis {
+		return store.NewRedis(ctx, cfg.Redis)
+	}
+	return store.NewMemory(), nil
+}
+
+// initLogger 根据配置初始化 slog logger，日志级别由 level 控制以便运行时调整
+// 输出到文件时返回需在退出时关闭的文件，否则为 nil
+func initLogger(cfg shared.LogConfig, level *slog.LevelVar) (*slog.Logger, io.Closer) {
 	opts := &slog.HandlerOptions{Level: level}
+	out, closer := logOutput(cfg.Output, cfg.File)
 
 	var h slog.Handler
 	if strings.ToLower(cfg.Format) == "json" {
-		h = slog.NewJSONHandler(os.Stdout, opts)
+		h = slog.NewJSONHandler(out, opts)
 	} else {
-		h = slog.NewTextHandler(os.Stdout, opts)
+		h = slog.NewTextHandler(out, opts)
 	}
 
-	return slog.New(h)
+	return slog.New(shared.NewContextHandler(h)), closer
+}
+
+// logOutput 按 stdout | file 创建日志输出，输出到文件时按大小滚动，closer 非 nil 时需在退出时关闭
+func logOutput(output string, cfg shared.LogFileConfig) (io.Writer, io.Closer) {
+	if output != "file" {
+		return os.Stdout, nil
+	}
+	f := &lumberjack.Logger{
+		Filename:   cfg.Path,
+		MaxSize:    cfg.MaxSizeMB,
+		MaxBackups: cfg.MaxBackups,
+		MaxAge:     cfg.MaxAgeDays,
+		Compress:   cfg.Compress,
+	}
+	return f, f
+}
+
+// parseLevel 解析日志级别，无法识别时为 info
+func parseLevel(s string) slog.Level {
+	switch strings.ToLower(s) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
 }
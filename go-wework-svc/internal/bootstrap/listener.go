@@ -0,0 +1,125 @@
+package bootstrap
+
+import (
+	"cmp"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	handler "go-wework-svc/internal/adapter/http"
+	"go-wework-svc/internal/shared"
+)
+
+const (
+	// unixSocketMode Unix 域套接字的文件权限，允许同组的反向代理连接
+	unixSocketMode = 0o660
+
+	defaultReadHeaderTimeout = 5 * time.Second
+	defaultIdleTimeout       = 60 * time.Second
+	defaultMaxHeaderBytes    = 64 << 10
+)
+
+// defaultRouteTimeouts 内置的路由超时：企业微信要求回调在 5 秒内响应，管理 API 可以更长，长连接不限制
+var defaultRouteTimeouts = []handler.RouteTimeout{
+	{Prefix: "/callback", Timeout: 5 * time.Second},
+	{Prefix: "/suite/callback", Timeout: 5 * time.Second},
+	{Prefix: "/admin", Timeout: time.Minute},
+	{Prefix: "/admin/ws", Timeout: 0},
+	{Prefix: "/debug/stream", Timeout: 0},
+	{Prefix: "/debug/pprof", Timeout: 0},
+}
+
+// adminPrefixes 管理端路由前缀，设置了 admin 监听时只在其上提供
+var adminPrefixes = []string{"/admin", "/debug"}
+
+// listener 一个 HTTP 监听地址及其服务器，各监听共用路由和超时配置
+type listener struct {
+	addr   string
+	tls    bool
+	admin  bool
+	server *http.Server
+}
+
+// newListeners 按 server.addr 和 server.listeners 创建监听，任一监听设置 admin 时其余监听屏蔽管理端路由
+// 配置了 base_path 时先去掉前缀再匹配路由
+func newListeners(cfg shared.ServerConfig, h http.Handler, t *serverTLS) []*listener {
+	ls := []*listener{{addr: cfg.Addr, tls: t != nil}}
+	hasAdmin := false
+	for _, l := range cfg.Listeners {
+		ls = append(ls, &listener{addr: l.Addr, tls: l.TLS, admin: l.Admin})
+		hasAdmin = hasAdmin || l.Admin
+	}
+	for _, l := range ls {
+		next := h
+		if hasAdmin && !l.admin {
+			next = withoutAdmin(h)
+		}
+		l.server = &http.Server{
+			Addr:              l.addr,
+			Handler:           handler.BasePath(next, cfg.BasePath),
+			ReadTimeout:       cfg.ReadTimeout,
+			WriteTimeout:      cfg.WriteTimeout,
+			ReadHeaderTimeout: cmp.Or(cfg.ReadHeaderTimeout, defaultReadHeaderTimeout),
+			IdleTimeout:       cmp.Or(cfg.IdleTimeout, defaultIdleTimeout),
+			MaxHeaderBytes:    cmp.Or(cfg.MaxHeaderBytes, defaultMaxHeaderBytes),
+		}
+		if l.tls {
+			l.server.TLSConfig = t.config.Clone()
+		}
+	}
+	return ls
+}
+
+// routeTimeouts 合并内置和配置的路由超时，同一前缀以配置为准
+func routeTimeouts(cfg []shared.RouteTimeoutConfig) []handler.RouteTimeout {
+	routes := make([]handler.RouteTimeout, 0, len(defaultRouteTimeouts)+len(cfg))
+	for _, d := range defaultRouteTimeouts {
+		if !slices.ContainsFunc(cfg, func(c shared.RouteTimeoutConfig) bool { return c.Prefix == d.Prefix }) {
+			routes = append(routes, d)
+		}
+	}
+	for _, c := range cfg {
+		routes = append(routes, handler.RouteTimeout{Prefix: c.Prefix, Timeout: c.Timeout})
+	}
+	return routes
+}
+
+// withoutAdmin 对管理端路由返回 404，与未注册的路由一致
+func withoutAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, p := range adminPrefixes {
+			if r.URL.Path == p || strings.HasPrefix(r.URL.Path, p+"/") {
+				http.NotFound(w, r)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// listen 监听 TCP 地址或 unix:// 套接字，遗留的套接字文件先删除
+func listen(addr string) (net.Listener, error) {
+	path, ok := strings.CutPrefix(addr, shared.UnixSocketPrefix)
+	if !ok {
+		return net.Listen("tcp", addr)
+	}
+	if fi, err := os.Stat(path); err == nil && fi.Mode().Type() == fs.ModeSocket {
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("remove stale socket: %w", err)
+		}
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, unixSocketMode); err != nil {
+		l.Close()
+		return nil, fmt.Errorf("chmod socket: %w", err)
+	}
+	return l, nil
+}
@@ -0,0 +1,6 @@
+package bootstrap
+
+// 编译进服务的插件，新增插件在此追加空导入后即可在配置 plugins 中启用
+import (
+	_ "go-wework-svc/internal/plugin/keywordstats"
+)
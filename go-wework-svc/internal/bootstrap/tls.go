@@ -0,0 +1,63 @@
+package bootstrap
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+
+	"go-wework-svc/internal/shared"
+)
+
+const defaultAutocertCacheDir = "data/autocert"
+
+// serverTLS HTTPS 监听配置
+type serverTLS struct {
+	certFile, keyFile string
+	// config 各 TLS 监听共用的配置
+	config *tls.Config
+	// challenge 非空时另行监听，处理 ACME HTTP-01 验证并将其余请求跳转到 HTTPS
+	challenge *http.Server
+}
+
+// newServerTLS 按配置创建 TLS 监听配置，未启用时返回 nil
+func newServerTLS(cfg shared.TLSConfig, readTimeout time.Duration, logger *slog.Logger) (*serverTLS, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if !cfg.Autocert.Enabled {
+		// 启动前加载一次，证书文件有误时尽早失败
+		if _, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile); err != nil {
+			return nil, fmt.Errorf("load tls key pair: %w", err)
+		}
+		return &serverTLS{certFile: cfg.CertFile, keyFile: cfg.KeyFile, config: &tls.Config{MinVersion: tls.VersionTLS12}}, nil
+	}
+
+	cacheDir := cfg.Autocert.CacheDir
+	if cacheDir == "" {
+		cacheDir = defaultAutocertCacheDir
+	}
+	m := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(cfg.Autocert.Domains...),
+		Cache:      autocert.DirCache(cacheDir),
+		Email:      cfg.Autocert.Email,
+	}
+	// TLSConfig 已包含 TLS-ALPN-01 验证所需的 acme-tls/1 协议
+	config := m.TLSConfig()
+	config.MinVersion = tls.VersionTLS12
+	logger.Info("tls certificates managed by acme", "domains", cfg.Autocert.Domains, "cache_dir", cacheDir)
+
+	t := &serverTLS{config: config}
+	if cfg.Autocert.HTTPAddr != "" {
+		t.challenge = &http.Server{
+			Addr:              cfg.Autocert.HTTPAddr,
+			Handler:           m.HTTPHandler(nil),
+			ReadHeaderTimeout: readTimeout,
+		}
+	}
+	return t, nil
+}
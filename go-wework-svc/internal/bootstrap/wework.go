@@ -0,0 +1,479 @@
+package bootstrap
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"slices"
+	"text/template"
+	"time"
+
+	"go-wework-svc/internal/adapter/client"
+	handler "go-wework-svc/internal/adapter/http"
+	"go-wework-svc/internal/ai"
+	"go-wework-svc/internal/auth"
+	"go-wework-svc/internal/errreport"
+	"go-wework-svc/internal/kf"
+	"go-wework-svc/internal/monitor"
+	"go-wework-svc/internal/outbox"
+	"go-wework-svc/internal/shared"
+	"go-wework-svc/internal/store"
+	"go-wework-svc/internal/transform"
+	"go-wework-svc/internal/wework"
+	"go-wework-svc/internal/wework/suite"
+	"go-wework-svc/internal/wework/token"
+)
+
+// callbackDeps 各应用共享的回调管道组件
+type callbackDeps struct {
+	kv     store.Store
+	outbox outbox.Store // 未启用时为 nil
+	// opts 所有应用共用的服务选项：变换、观察者、插件
+	opts []wework.Option
+	// webhooks 按名称引用的群机器人
+	webhooks map[string]wework.Webhook
+	// cbOpts 所有回调处理器共用的选项
+	cbOpts []handler.CallbackOption
+	// cache 非空时为应用消息的 AI 请求加上回复缓存，name 为应用或租户名称
+	cache func(name string, svc ai.Service) ai.Service
+	// reporter 未启用错误上报时为 nil
+	reporter *errreport.Reporter
+	// security 记录回调安全校验失败，agent 由各回调处理器填写
+	security func(ctx context.Context, e handler.SecurityEvent)
+	// moderate 内容审核，应用消息通过 opts 设置，微信客服另行挂载
+	moderate wework.Moderator
+	logger   *slog.Logger
+}
+
+// callback 一个应用或租户组装好的回调组件
+type callback struct {
+	svc     wework.Service
+	handler http.Handler
+	sender  wework.Sender        // 未配置 secret 时为 nil
+	jsapi   *handler.JSAPISigner // 未启用 jsapi 时为 nil
+	oauth   *auth.OAuthApp       // 未配置 secret 时为 nil
+	// syncMenu 非空时在启动后同步应用菜单
+	syncMenu func(context.Context)
+	// reloadKeys 热加载时应用轮换后的回调 Token 和 EncodingAESKey
+	reloadKeys func(cfg shared.WeWorkConfig) error
+	// reloadRateLimit 热加载时替换限流策略
+	reloadRateLimit func(cfg shared.RateLimitConfig)
+}
+
+// newCallback 按应用或租户配置组装企业微信服务和回调处理器，name 为空表示默认应用
+func newCallback(name string, cfg shared.WeWorkConfig, aiSvc ai.Service, deps callbackDeps) (*callback, error) {
+	logger := deps.logger
+
+	initial, err := wework.NewCrypto(cfg.Token, cfg.EncodingAESKey, cfg.CorpID,
+		wework.WithRotationKeys(logger, cfg.EncodingAESKeys...))
+	if err != nil {
+		return nil, fmt.Errorf("init crypto: %w", err)
+	}
+	crypto := wework.NewReloadableCrypto(initial)
+	// 当前密钥保留为轮换密钥，应用新密钥前已发出的回调仍可解密
+	applied := cfg
+	reloadKeys := func(next shared.WeWorkConfig) error {
+		if next.Token == applied.Token && next.EncodingAESKey == applied.EncodingAESKey &&
+			slices.Equal(next.EncodingAESKeys, applied.EncodingAESKeys) {
+			return nil
+		}
+		rotation := slices.Clone(next.EncodingAESKeys)
+		for _, k := range append([]string{applied.EncodingAESKey}, applied.EncodingAESKeys...) {
+			if k != next.EncodingAESKey && !slices.Contains(rotation, k) {
+				rotation = append(rotation, k)
+			}
+		}
+		c, err := wework.NewCrypto(next.Token, next.EncodingAESKey, next.CorpID, wework.WithRotationKeys(logger, rotation...))
+		if err != nil {
+			return fmt.Errorf("init crypto: %w", err)
+		}
+		crypto.Swap(c)
+		applied = next
+		return nil
+	}
+
+	mention := wework.MentionPolicy{Names: cfg.Mention.Names, DirectAlways: cfg.Mention.DirectAlways}
+	if cfg.Mention.Pattern != "" {
+		mention.Pattern, err = regexp.Compile(cfg.Mention.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("compile mention pattern: %w", err)
+		}
+	}
+
+	svcOpts := append([]wework.Option{
+		wework.WithAgent(name),
+		wework.WithMention(mention),
+		wework.WithReplyFormat(wework.ReplyFormat{Format: cfg.ReplyFormat.Format, MaxBytes: cfg.ReplyFormat.MaxBytes}),
+	}, deps.opts...)
+	if dedupTTL := cfg.DedupTTL; dedupTTL >= 0 {
+		if dedupTTL == 0 {
+			dedupTTL = defaultDedupTTL
+		}
+		svcOpts = append(svcOpts, wework.WithDedup(deps.kv, dedupTTL))
+	}
+	if window := cfg.ReplayWindow; window >= 0 {
+		if window == 0 {
+			window = defaultReplayWindow
+		}
+		svcOpts = append(svcOpts, wework.WithReplayProtection(deps.kv, window))
+	}
+	kfOpts := []kf.Option{kf.WithModerator(deps.moderate)}
+	if cfg.Redaction.Enabled {
+		redactor, err := transform.NewRedactor(cfg.Redaction)
+		if err != nil {
+			return nil, fmt.Errorf("init redaction: %w", err)
+		}
+		svcOpts = append(svcOpts, wework.WithRedactor(redactor))
+		kfOpts = append(kfOpts, kf.WithRedactor(redactor))
+	}
+	var sender wework.Sender
+	var directory wework.Directory
+	var tags wework.TagDirectory
+	var syncMenu func(context.Context)
+	var jsapi *handler.JSAPISigner
+	var oauthApp *auth.OAuthApp
+	if cfg.Secret != "" {
+		tokens := token.NewManager(apiBaseURL(cfg), cfg.CorpID, cfg.Secret, deps.kv, logger)
+		msgSender := client.NewMessageSender(apiBaseURL(cfg), cfg.AgentID, tokens, logger)
+		sender = msgSender
+		svcOpts = append(svcOpts, wework.WithSender(sender))
+		if len(cfg.CardButtons) > 0 {
+			actions := make(map[string]wework.CardButtonAction, len(cfg.CardButtons))
+			for key, b := range cfg.CardButtons {
+				actions[key] = wework.CardButtonAction{Reply: b.Reply, ReplaceName: b.ReplaceName}
+			}
+			svcOpts = append(svcOpts, wework.WithEventHandler(wework.EventTemplateCard, wework.CardButtons(actions, msgSender)))
+		}
+		dir := client.NewDirectory(apiBaseURL(cfg), cfg.CorpID, tokens, deps.kv)
+		directory, tags = dir, dir
+		if cfg.ContactsProfile {
+			svcOpts = append(svcOpts, wework.WithContacts(dir))
+		}
+		svcOpts = append(svcOpts, wework.WithMediaDownloader(
+			client.NewMediaClient(apiBaseURL(cfg), tokens, cfg.Media.MaxBytes, cfg.Media.TempDir)))
+		if cfg.Media.Vision {
+			svcOpts = append(svcOpts, wework.WithVisionInput())
+		}
+		// 时区已在配置校验时检查
+		loc := time.Local
+		if cfg.Calendar.Timezone != "" {
+			loc, _ = time.LoadLocation(cfg.Calendar.Timezone)
+		}
+		if cfg.Calendar.Enabled {
+			svcOpts = append(svcOpts, wework.WithTools(wework.CalendarTools(wework.CalendarPolicy{
+				Client:     client.NewCalendarClient(apiBaseURL(cfg), tokens),
+				CalendarID: cfg.Calendar.CalendarID,
+				Location:   loc,
+			})...))
+		}
+		if cfg.Actions.Enabled() {
+			actions := wework.ActionPolicy{Location: loc}
+			if cfg.Actions.SendFile {
+				actions.Sender = msgSender
+				actions.Uploader = client.NewMediaUploader(apiBaseURL(cfg), cfg.CorpID, tokens, deps.kv, cfg.Media.ReplyHosts)
+			}
+			if cfg.Actions.CreateTodo {
+				actions.Todos = client.NewTodoClient(apiBaseURL(cfg), tokens)
+			}
+			if cfg.Actions.LookupUser {
+				actions.Contacts = dir
+			}
+			svcOpts = append(svcOpts, wework.WithTools(wework.ActionTools(actions)...))
+		}
+		oauthApp = &auth.OAuthApp{
+			CorpID:  cfg.CorpID,
+			AgentID: cfg.AgentID,
+			Users:   client.NewOAuthClient(apiBaseURL(cfg), tokens),
+		}
+		if cfg.JSAPI.Enabled {
+			jsapi = &handler.JSAPISigner{
+				CorpID:       cfg.CorpID,
+				AgentID:      cfg.AgentID,
+				CorpTicket:   token.NewTicketManager(apiBaseURL(cfg), cfg.CorpID, cfg.AgentID, token.TicketCorp, tokens, deps.kv, logger),
+				AgentTicket:  token.NewTicketManager(apiBaseURL(cfg), cfg.CorpID, cfg.AgentID, token.TicketAgent, tokens, deps.kv, logger),
+				AllowedHosts: cfg.JSAPI.AllowedHosts,
+			}
+		}
+		if cfg.Approval.Enabled {
+			approvalTokens := tokens
+			if cfg.Approval.Secret != "" {
+				approvalTokens = token.NewManager(apiBaseURL(cfg), cfg.CorpID, cfg.Approval.Secret, deps.kv, logger)
+			}
+			svcOpts = append(svcOpts, wework.WithApproval(wework.ApprovalPolicy{
+				Client:          client.NewApprovalClient(apiBaseURL(cfg), approvalTokens),
+				NotifyApprovers: cfg.Approval.NotifyApprovers,
+				NotifyApplicant: cfg.Approval.NotifyApplicant,
+				SummaryChars:    cfg.Approval.SummaryChars,
+				Store:           deps.kv,
+			}))
+		}
+		if cfg.ExternalContact.Enabled() {
+			policy, err := externalContactPolicy(cfg.ExternalContact, client.NewExternalContactClient(apiBaseURL(cfg), tokens))
+			if err != nil {
+				return nil, err
+			}
+			svcOpts = append(svcOpts, wework.WithExternalContact(policy))
+		}
+		if len(cfg.Menu.Buttons) > 0 {
+			menus := client.NewMenuClient(apiBaseURL(cfg), cfg.AgentID, tokens)
+			menu := wework.Menu{Buttons: menuButtons(cfg.Menu.Buttons)}
+			syncMenu = func(ctx context.Context) {
+				if err := menus.SetMenu(ctx, menu); err != nil {
+					logger.Error("failed to sync app menu", "error", err)
+					return
+				}
+				logger.Info("app menu synced", "buttons", len(menu.Buttons))
+			}
+		}
+		if cfg.Media.ReplyMedia {
+			svcOpts = append(svcOpts, wework.WithMediaUploader(
+				client.NewMediaUploader(apiBaseURL(cfg), cfg.CorpID, tokens, deps.kv, cfg.Media.ReplyHosts)))
+		}
+		if cfg.Media.ExtractText {
+			svcOpts = append(svcOpts, wework.WithFileText(cmp.Or(cfg.Media.MaxTextChars, defaultFileTextChars)))
+		}
+	}
+	if cfg.Access.Enabled() {
+		// 部门规则要求配置 secret，已在配置校验中保证 directory 非空
+		svcOpts = append(svcOpts, wework.WithAccessControl(wework.AccessPolicy{
+			AllowUsers:       cfg.Access.AllowUsers,
+			AllowDepartments: cfg.Access.AllowDepartments,
+			BlockUsers:       cfg.Access.BlockUsers,
+			BlockDepartments: cfg.Access.BlockDepartments,
+			AllowCorps:       cfg.Access.AllowCorps,
+			DenyReply:        cfg.Access.DenyReply,
+			Directory:        directory,
+		}))
+	}
+	// 部门路由只作用于应用消息，微信客服沿用默认后端
+	chatAI := aiSvc
+	if len(cfg.Routes) > 0 {
+		routes := make([]ai.Route, len(cfg.Routes))
+		rules := make([]wework.RouteRule, len(cfg.Routes))
+		for i, r := range cfg.Routes {
+			routes[i] = ai.Route{Name: r.Name, SystemPrompt: r.SystemPrompt, GroupID: r.GroupID}
+			if r.AI != nil {
+				routes[i].Service = newAIClient(*r.AI, deps.kv, logger.With("route", r.Name))
+			}
+			rules[i] = wework.RouteRule{Name: r.Name, Users: r.Users, Departments: r.Departments, Tags: r.Tags}
+		}
+		chatAI = ai.NewRouteRouter(aiSvc, routes, logger)
+		svcOpts = append(svcOpts, wework.WithRouting(wework.RoutingPolicy{Rules: rules, Directory: directory, Tags: tags}))
+	}
+	if deps.cache != nil {
+		chatAI = deps.cache(name, chatAI)
+	}
+	// 在缓存之外合并，同一问题并发未命中时只请求一次后端
+	chatAI = ai.NewCoalescer(chatAI, logger)
+	if !cfg.Persona.IsZero() || len(cfg.GroupPersonas) > 0 {
+		groups := make(map[string]*ai.Persona, len(cfg.GroupPersonas))
+		for chatID, p := range cfg.GroupPersonas {
+			groups[chatID] = persona(p)
+		}
+		svcOpts = append(svcOpts, wework.WithPersona(wework.PersonaPolicy{Default: persona(cfg.Persona), Groups: groups}))
+	}
+	if cfg.Commands.Enabled {
+		// 在 event_replies 之前注册，显式配置的 click 回复优先
+		svcOpts = append(svcOpts, wework.WithMenuCommands())
+	}
+	for event, text := range cfg.EventReplies {
+		svcOpts = append(svcOpts, wework.WithEventHandler(event, wework.StaticReply(text)))
+	}
+	if cfg.Welcome.Message != "" {
+		tmpl, err := template.New("welcome").Parse(cfg.Welcome.Message)
+		if err != nil {
+			return nil, fmt.Errorf("parse welcome message: %w", err)
+		}
+		interval := cmp.Or(cfg.Welcome.Interval, defaultWelcomeInterval)
+		svcOpts = append(svcOpts, wework.WithWelcome(wework.WelcomePolicy{
+			Template: tmpl,
+			Interval: max(interval, 0),
+			Store:    deps.kv,
+		}))
+	}
+	if cfg.KF.Enabled {
+		secret := cfg.KF.Secret
+		if secret == "" {
+			secret = cfg.Secret
+		}
+		tokens := token.NewManager(apiBaseURL(cfg), cfg.CorpID, secret, deps.kv, logger)
+		kfSvc := kf.NewService(client.NewKFClient(apiBaseURL(cfg), tokens), aiSvc, deps.kv, logger.With("component", "kf"), kfOpts...)
+		svcOpts = append(svcOpts, wework.WithEventHandler(kf.EventMsgOrEvent, kfSvc))
+	}
+	// 限流器始终挂载，热加载时可以启用、停用或调整次数
+	limiter := wework.NewRateLimiter(rateLimitPolicy(cfg.RateLimit, deps.kv))
+	svcOpts = append(svcOpts, wework.WithRateLimit(limiter))
+	if cfg.Commands.Enabled {
+		svcOpts = append(svcOpts, wework.WithCommands(wework.CommandPolicy{
+			Intro:    cfg.Commands.Intro,
+			Keywords: cfg.Commands.Keywords,
+			Store:    deps.kv,
+		}))
+	}
+	if cfg.Stream.Enabled {
+		policy := wework.StreamPolicy{
+			FlushInterval: cfg.Stream.FlushInterval,
+			MinChars:      cfg.Stream.MinChars,
+		}
+		if policy.FlushInterval <= 0 {
+			policy.FlushInterval = defaultStreamFlushInterval
+		}
+		if policy.MinChars <= 0 {
+			policy.MinChars = defaultStreamMinChars
+		}
+		svcOpts = append(svcOpts, wework.WithStreaming(policy))
+	}
+	if cfg.ReplyMode == shared.ReplyModePassive {
+		timeout := cfg.PassiveTimeout
+		if timeout <= 0 {
+			timeout = defaultPassiveTimeout
+		}
+		svcOpts = append(svcOpts, wework.WithPassiveReply(timeout))
+	}
+	if deps.outbox != nil {
+		svcOpts = append(svcOpts, wework.WithOutbox(deps.outbox))
+	}
+	if cfg.ReplyWebhook != "" {
+		svcOpts = append(svcOpts, wework.WithReplyWebhook(deps.webhooks[cfg.ReplyWebhook]))
+	}
+	// 智能机器人 JSON 回调的 response_url 与群机器人 webhook 的请求格式相同
+	svcOpts = append(svcOpts, wework.WithResponseURL(func(url string) wework.Webhook { return client.NewWebhook(url) }))
+
+	svc := wework.NewService(crypto, chatAI, logger, svcOpts...)
+
+	cbOpts := slices.Clone(deps.cbOpts)
+	if limit := cfg.SignatureFailLimit; limit >= 0 {
+		if limit == 0 {
+			limit = defaultSignatureFailLimit
+		}
+		window := cfg.SignatureFailWindow
+		if window <= 0 {
+			window = defaultSignatureFailWindow
+		}
+		cbOpts = append(cbOpts, handler.WithSignatureFailureLimit(deps.kv, limit, window))
+	}
+	if deps.security != nil {
+		cbOpts = append(cbOpts, handler.WithSecurityObserver(func(ctx context.Context, e handler.SecurityEvent) {
+			e.Agent = name
+			deps.security(ctx, e)
+		}))
+	}
+	if deps.reporter != nil {
+		cbOpts = append(cbOpts, handler.WithErrorReporter(func(_ context.Context, reason string, err error) {
+			deps.reporter.CallbackError(name, reason, err)
+		}))
+	}
+	return &callback{
+		svc:        svc,
+		handler:    handler.NewCallbackHandler(svc, logger, cbOpts...),
+		sender:     sender,
+		jsapi:      jsapi,
+		oauth:      oauthApp,
+		syncMenu:   syncMenu,
+		reloadKeys: reloadKeys,
+		reloadRateLimit: func(c shared.RateLimitConfig) {
+			limiter.Reload(rateLimitPolicy(c, deps.kv))
+		},
+	}, nil
+}
+
+// rateLimitPolicy 由配置生成限流策略，未启用时返回 nil
+func rateLimitPolicy(cfg shared.RateLimitConfig, kv store.Store) *wework.RateLimitPolicy {
+	if !cfg.Enabled {
+		return nil
+	}
+	return &wework.RateLimitPolicy{
+		PerMinute:  cfg.PerMinute,
+		Daily:      cfg.Daily,
+		Reply:      cfg.Reply,
+		QuotaReply: cfg.QuotaReply,
+		Exempt:     cfg.ExemptUsers,
+		Store:      kv,
+	}
+}
+
+// externalContactPolicy 解析客户联系的欢迎语和通知模板
+func externalContactPolicy(cfg shared.ExternalContactConfig, c wework.ExternalContactClient) (wework.ExternalContactPolicy, error) {
+	p := wework.ExternalContactPolicy{Client: c, Notices: make(map[string]*template.Template, len(cfg.Notices))}
+	if cfg.Welcome != "" {
+		tmpl, err := template.New("external_welcome").Parse(cfg.Welcome)
+		if err != nil {
+			return p, fmt.Errorf("parse external contact welcome: %w", err)
+		}
+		p.Welcome = tmpl
+	}
+	for change, text := range cfg.Notices {
+		tmpl, err := template.New(change).Parse(text)
+		if err != nil {
+			return p, fmt.Errorf("parse external contact notice %s: %w", change, err)
+		}
+		p.Notices[change] = tmpl
+	}
+	return p, nil
+}
+
+// persona 将人设配置转换为 ai.Persona，未配置时返回 nil
+func persona(c shared.PersonaConfig) *ai.Persona {
+	if c.IsZero() {
+		return nil
+	}
+	return &ai.Persona{SystemPrompt: c.SystemPrompt, Tone: c.Tone, Language: c.Language, Topics: c.Topics}
+}
+
+// menuButtons 将菜单配置转换为 menu/create 请求的按钮
+func menuButtons(cfgs []shared.MenuButtonConfig) []wework.MenuButton {
+	buttons := make([]wework.MenuButton, len(cfgs))
+	for i, c := range cfgs {
+		buttons[i] = wework.MenuButton{Type: c.Type, Name: c.Name, Key: c.Key, URL: c.URL}
+		if len(c.SubButtons) > 0 {
+			buttons[i].SubButtons = menuButtons(c.SubButtons)
+		}
+	}
+	return buttons
+}
+
+// newSuiteCallback 组装第三方应用指令回调处理器，签名失败限流沿用默认值
+func newSuiteCallback(cfg shared.SuiteConfig, baseURL string, kv store.Store, cbOpts []handler.CallbackOption, logger *slog.Logger) (http.Handler, error) {
+	crypto, err := wework.NewCrypto(cfg.Token, cfg.EncodingAESKey, cfg.SuiteID)
+	if err != nil {
+		return nil, fmt.Errorf("init suite crypto: %w", err)
+	}
+	s := suite.New(cfg.SuiteID, cfg.Secret, crypto, baseURL, kv, logger)
+	cbOpts = append(slices.Clone(cbOpts), handler.WithSignatureFailureLimit(kv, defaultSignatureFailLimit, defaultSignatureFailWindow))
+	return handler.NewCallbackHandler(s, logger, cbOpts...), nil
+}
+
+// newWebhooks 创建命名的群机器人客户端
+func newWebhooks(cfgs map[string]shared.WebhookConfig) map[string]wework.Webhook {
+	webhooks := make(map[string]wework.Webhook, len(cfgs))
+	for name, c := range cfgs {
+		webhooks[name] = client.NewWebhook(c.URL)
+	}
+	return webhooks
+}
+
+// apiBaseURL 返回企业微信服务端 API 地址，未配置时使用默认值
+func apiBaseURL(cfg shared.WeWorkConfig) string {
+	if cfg.APIBaseURL != "" {
+		return cfg.APIBaseURL
+	}
+	return token.DefaultAPIBaseURL
+}
+
+// statusCommand /status 命令：回复 AI 后端健康状态和运行时长
+func statusCommand(mon *monitor.Monitor) wework.CommandSpec {
+	return wework.CommandSpec{
+		Name:        "status",
+		Description: "查看 AI 服务状态",
+		Command: wework.CommandFunc(func(context.Context, wework.Message, string) (string, error) {
+			st := mon.Snapshot()
+			reply := fmt.Sprintf("AI 服务状态：%s\n最近 %d 次请求失败 %d 次，平均耗时 %dms\n已运行 %s",
+				st.AI.Status, st.AI.RecentTotal, st.AI.RecentFailures, st.AI.AvgLatencyMs,
+				(time.Duration(st.UptimeSec) * time.Second).String())
+			return reply, nil
+		}),
+	}
+}
@@ -0,0 +1,92 @@
+// Package conversation 按用户或群组保存最近的对话记录，作为 AI 请求的上下文
+package conversation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"go-wework-svc/internal/ai"
+	"go-wework-svc/internal/store"
+)
+
+// History 基于共享 Store 的对话记录，每个会话保存为一个 JSON 数组
+// 每次写入都会重置 TTL，会话空闲超过 TTL 后自动清空
+type History struct {
+	kv       store.Store
+	maxTurns int
+	ttl      time.Duration
+
+	// locks 按会话哈希分片的锁，同一会话的读改写串行执行
+	locks [lockShards]sync.Mutex
+}
+
+const lockShards = 64
+
+// New 创建对话记录，maxTurns 为每个会话保留的最大条数
+func New(kv store.Store, maxTurns int, ttl time.Duration) *History {
+	return &History{
+		kv:       kv,
+		maxTurns: maxTurns,
+		ttl:      ttl,
+	}
+}
+
+// Load 返回会话的历史记录，按时间升序
+func (h *History) Load(ctx context.Context, key string) ([]ai.Turn, error) {
+	v, ok, err := h.kv.Get(ctx, h.storeKey(key))
+	if err != nil {
+		return nil, fmt.Errorf("read conversation: %w", err)
+	}
+	if !ok {
+		return nil, nil
+	}
+	var turns []ai.Turn
+	if err := json.Unmarshal([]byte(v), &turns); err != nil {
+		return nil, fmt.Errorf("decode conversation: %w", err)
+	}
+	return turns, nil
+}
+
+// Append 追加记录，超过 maxTurns 时丢弃最早的记录
+func (h *History) Append(ctx context.Context, key string, turns ...ai.Turn) error {
+	lock := h.lock(key)
+	lock.Lock()
+	defer lock.Unlock()
+
+	all, err := h.Load(ctx, key)
+	if err != nil {
+		return err
+	}
+	all = append(all, turns...)
+	if len(all) > h.maxTurns {
+		all = all[len(all)-h.maxTurns:]
+	}
+
+	data, err := json.Marshal(all)
+	if err != nil {
+		return fmt.Errorf("encode conversation: %w", err)
+	}
+	if err := h.kv.Set(ctx, h.storeKey(key), string(data), h.ttl); err != nil {
+		return fmt.Errorf("write conversation: %w", err)
+	}
+	return nil
+}
+
+// Clear 清空会话记录
+func (h *History) Clear(ctx context.Context, key string) error {
+	return h.kv.Delete(ctx, h.storeKey(key))
+}
+
+func (h *History) storeKey(key string) string {
+	return "conv:" + key
+}
+
+func (h *History) lock(key string) *sync.Mutex {
+	f := fnv.New32a()
+	f.Write([]byte(key))
+	return &h.locks[f.Sum32()%lockShards]
+}
@@ -0,0 +1,82 @@
+package conversation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"go-wework-svc/internal/ai"
+)
+
+// Redis 基于 Redis 列表的对话记录，追加、截断和续期在同一事务中执行
+// 多副本并发写入同一会话时不会丢失记录
+type Redis struct {
+	client   *redis.Client
+	prefix   string
+	maxTurns int
+	ttl      time.Duration
+}
+
+// NewRedis 创建 Redis 对话记录，prefix 与 store.redis.key_prefix 保持一致
+func NewRedis(client *redis.Client, prefix string, maxTurns int, ttl time.Duration) *Redis {
+	return &Redis{client: client, prefix: prefix, maxTurns: maxTurns, ttl: ttl}
+}
+
+// Load 返回会话的历史记录，按时间升序
+func (r *Redis) Load(ctx context.Context, key string) ([]ai.Turn, error) {
+	items, err := r.client.LRange(ctx, r.key(key), 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("redis lrange: %w", err)
+	}
+	turns := make([]ai.Turn, 0, len(items))
+	for _, item := range items {
+		var t ai.Turn
+		if err := json.Unmarshal([]byte(item), &t); err != nil {
+			return nil, fmt.Errorf("decode conversation turn: %w", err)
+		}
+		turns = append(turns, t)
+	}
+	return turns, nil
+}
+
+// Append 追加记录，超过 maxTurns 时丢弃最早的记录并重置会话 TTL
+func (r *Redis) Append(ctx context.Context, key string, turns ...ai.Turn) error {
+	if len(turns) == 0 {
+		return nil
+	}
+	values := make([]any, 0, len(turns))
+	for _, t := range turns {
+		data, err := json.Marshal(t)
+		if err != nil {
+			return fmt.Errorf("encode conversation turn: %w", err)
+		}
+		values = append(values, data)
+	}
+
+	k := r.key(key)
+	_, err := r.client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.RPush(ctx, k, values...)
+		p.LTrim(ctx, k, int64(-r.maxTurns), -1)
+		p.PExpire(ctx, k, r.ttl)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("redis append conversation: %w", err)
+	}
+	return nil
+}
+
+// Clear 清空会话记录
+func (r *Redis) Clear(ctx context.Context, key string) error {
+	if err := r.client.Del(ctx, r.key(key)).Err(); err != nil {
+		return fmt.Errorf("redis del: %w", err)
+	}
+	return nil
+}
+
+func (r *Redis) key(key string) string {
+	return r.prefix + "conv:" + key
+}
@@ -0,0 +1,188 @@
+// Package errreport 将 panic、解密失败和 AI 错误上报到 Sentry 或通用 webhook
+// 事件只携带 msg_id、agent_id 等定位信息，不包含消息正文和用户输入
+package errreport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"go-wework-svc/internal/wework"
+)
+
+const (
+	defaultQueueSize = 100
+	// maxErrorLen 错误信息截断长度，避免过长的 panic 值等进入上报
+	maxErrorLen = 1000
+	sendTimeout = 10 * time.Second
+)
+
+// 事件类型
+const (
+	KindPanic    = "panic"
+	KindCallback = "callback_error" // 解密失败、corp_id 不一致等回调处理错误
+	KindAI       = "ai_error"
+)
+
+// Event 一次错误上报
+type Event struct {
+	Kind  string            `json:"kind"`
+	Error string            `json:"error"`
+	Stack string            `json:"stack,omitempty"`
+	Tags  map[string]string `json:"tags,omitempty"` // agent、agent_id、msg_id、msg_type、reason 等
+	Time  time.Time         `json:"time"`
+}
+
+// Sink 上报目标
+type Sink interface {
+	Send(ctx context.Context, e Event) error
+}
+
+// Reporter 异步上报错误，实现 wework.Observer 以上报 AI 转发失败
+// 事件先写入内存队列，队列满时丢弃，不阻塞消息处理
+type Reporter struct {
+	sink   Sink
+	queue  chan Event
+	logger *slog.Logger
+}
+
+// New 创建错误上报器，queueSize <= 0 时使用默认值
+func New(sink Sink, queueSize int, logger *slog.Logger) *Reporter {
+	if queueSize <= 0 {
+		queueSize = defaultQueueSize
+	}
+	return &Reporter{sink: sink, queue: make(chan Event, queueSize), logger: logger}
+}
+
+// Panic 上报 panic，source 为 http 或后台任务名称
+func (r *Reporter) Panic(source string, msg wework.Message, v any, stack []byte) {
+	tags := msgTags(msg)
+	tags["source"] = source
+	r.report(Event{Kind: KindPanic, Error: fmt.Sprint(v), Stack: string(stack), Tags: tags})
+}
+
+// CallbackError 上报回调处理错误，reason 为 handler.Reason* 常量
+func (r *Reporter) CallbackError(agent, reason string, err error) {
+	r.report(Event{Kind: KindCallback, Error: err.Error(), Tags: map[string]string{"agent": agent, "reason": reason}})
+}
+
+// OnMessage 实现 wework.Observer
+func (r *Reporter) OnMessage(context.Context, wework.Message, string) {}
+
+// OnForwardDone 实现 wework.Observer，只上报失败的转发
+func (r *Reporter) OnForwardDone(_ context.Context, msg wework.Message, latency time.Duration, err error) {
+	if err == nil {
+		return
+	}
+	tags := msgTags(msg)
+	tags["latency_ms"] = strconv.FormatInt(latency.Milliseconds(), 10)
+	r.report(Event{Kind: KindAI, Error: errorClass(err), Tags: tags})
+}
+
+// statusCoder AI 后端返回非 200 响应的错误
+type statusCoder interface {
+	StatusCode() int
+}
+
+// errorClass 返回 AI 错误的类别，不使用 err.Error()：后端响应体常回显用户输入
+func errorClass(err error) string {
+	var sc statusCoder
+	var ne net.Error
+	switch {
+	case errors.As(err, &sc):
+		return "unexpected status " + strconv.Itoa(sc.StatusCode())
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &ne) && ne.Timeout():
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.As(err, &ne):
+		return "network error"
+	}
+	for {
+		inner := errors.Unwrap(err)
+		if inner == nil {
+			return fmt.Sprintf("%T", err)
+		}
+		err = inner
+	}
+}
+
+func (r *Reporter) report(e Event) {
+	e.Time = time.Now()
+	e.Error = truncate(e.Error, maxErrorLen)
+	select {
+	case r.queue <- e:
+	default:
+		r.logger.Warn("error report queue full, dropping event", "kind", e.Kind)
+	}
+}
+
+// Len 返回等待上报的事件数
+func (r *Reporter) Len() int {
+	return len(r.queue)
+}
+
+// Run 发送队列中的事件，ctx 取消后尽力发送剩余事件再返回
+func (r *Reporter) Run(ctx context.Context) {
+	for {
+		select {
+		case e := <-r.queue:
+			r.send(ctx, e)
+		case <-ctx.Done():
+			r.drain()
+			return
+		}
+	}
+}
+
+func (r *Reporter) drain() {
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+	for {
+		select {
+		case e := <-r.queue:
+			r.send(ctx, e)
+		default:
+			return
+		}
+	}
+}
+
+func (r *Reporter) send(ctx context.Context, e Event) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sendTimeout)
+	defer cancel()
+	if err := r.sink.Send(ctx, e); err != nil {
+		r.logger.Warn("failed to send error report", "kind", e.Kind, "error", err)
+	}
+}
+
+// msgTags 消息的定位信息，不包含正文；用户 ID 不上报
+func msgTags(msg wework.Message) map[string]string {
+	tags := map[string]string{}
+	if msg.MsgID != "" {
+		tags["msg_id"] = msg.MsgID
+	}
+	if msg.MsgType != "" {
+		tags["msg_type"] = msg.MsgType
+	}
+	if msg.AgentID != 0 {
+		tags["agent_id"] = strconv.FormatInt(msg.AgentID, 10)
+	}
+	return tags
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	s = s[:n]
+	for len(s) > 0 && !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	return s + "…"
+}
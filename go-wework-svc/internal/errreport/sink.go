@@ -0,0 +1,154 @@
+package errreport
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const sinkTimeout = 5 * time.Second
+
+// SentrySink 通过 Sentry envelope 接口上报，不依赖 Sentry SDK
+type SentrySink struct {
+	endpoint    string
+	auth        string
+	environment string
+	release     string
+	serverName  string
+	httpClient  *http.Client
+}
+
+// NewSentrySink 解析 DSN（https://<key>@<host>/<project_id>）创建 Sentry 上报目标
+func NewSentrySink(dsn, environment, release string) (*SentrySink, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("parse dsn: %w", err)
+	}
+	key := u.User.Username()
+	path, project := "", strings.Trim(u.Path, "/")
+	if i := strings.LastIndex(project, "/"); i >= 0 {
+		path, project = "/"+project[:i], project[i+1:]
+	}
+	if u.Scheme == "" || u.Host == "" || key == "" || project == "" {
+		return nil, errors.New("dsn must be like https://<key>@<host>/<project_id>")
+	}
+	host, _ := os.Hostname()
+	return &SentrySink{
+		endpoint:    fmt.Sprintf("%s://%s%s/api/%s/envelope/", u.Scheme, u.Host, path, project),
+		auth:        "Sentry sentry_version=7, sentry_client=go-wework-svc, sentry_key=" + key,
+		environment: environment,
+		release:     release,
+		serverName:  host,
+		httpClient:  &http.Client{Timeout: sinkTimeout},
+	}, nil
+}
+
+// sentryEvent Sentry 事件的最小字段集，堆栈以原始文本放在 extra 中
+type sentryEvent struct {
+	EventID     string            `json:"event_id"`
+	Timestamp   string            `json:"timestamp"`
+	Platform    string            `json:"platform"`
+	Level       string            `json:"level"`
+	Environment string            `json:"environment,omitempty"`
+	Release     string            `json:"release,omitempty"`
+	ServerName  string            `json:"server_name,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	Exception   struct {
+		Values []sentryException `json:"values"`
+	} `json:"exception"`
+	Extra map[string]string `json:"extra,omitempty"`
+}
+
+type sentryException struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// Send 实现 Sink 接口
+func (s *SentrySink) Send(ctx context.Context, e Event) error {
+	id := make([]byte, 16)
+	rand.Read(id)
+	ev := sentryEvent{
+		EventID:     hex.EncodeToString(id),
+		Timestamp:   e.Time.UTC().Format(time.RFC3339),
+		Platform:    "go",
+		Level:       "error",
+		Environment: s.environment,
+		Release:     s.release,
+		ServerName:  s.serverName,
+		Tags:        e.Tags,
+	}
+	if e.Kind == KindPanic {
+		ev.Level = "fatal"
+	}
+	ev.Exception.Values = []sentryException{{Type: e.Kind, Value: e.Error}}
+	if e.Stack != "" {
+		ev.Extra = map[string]string{"stack": e.Stack}
+	}
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+
+	var body bytes.Buffer
+	fmt.Fprintf(&body, `{"event_id":%q}`+"\n", ev.EventID)
+	fmt.Fprintf(&body, `{"type":"event","length":%d}`+"\n", len(payload))
+	body.Write(payload)
+	body.WriteByte('\n')
+	return post(ctx, s.httpClient, s.endpoint, "application/x-sentry-envelope", body.Bytes(), map[string]string{"X-Sentry-Auth": s.auth})
+}
+
+// WebhookSink 以 JSON POST 事件到任意地址，便于接入自建的错误收集服务
+type WebhookSink struct {
+	url        string
+	release    string
+	httpClient *http.Client
+}
+
+// NewWebhookSink 创建通用 webhook 上报目标
+func NewWebhookSink(url, release string) *WebhookSink {
+	return &WebhookSink{url: url, release: release, httpClient: &http.Client{Timeout: sinkTimeout}}
+}
+
+// Send 实现 Sink 接口
+func (w *WebhookSink) Send(ctx context.Context, e Event) error {
+	body, err := json.Marshal(struct {
+		Event
+		Release string `json:"release,omitempty"`
+	}{e, w.release})
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+	return post(ctx, w.httpClient, w.url, "application/json", body, nil)
+}
+
+func post(ctx context.Context, c *http.Client, url, contentType string, body []byte, headers map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return fmt.Errorf("execute request: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
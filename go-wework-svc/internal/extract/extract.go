@@ -0,0 +1,201 @@
+// Package extract 从用户发送的文件中提取纯文本，作为 AI 请求的上下文
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// ErrUnsupported 不支持提取文本的文件类型
+var ErrUnsupported = errors.New("unsupported file type")
+
+// 支持的文件格式
+const (
+	FormatPDF  = "pdf"
+	FormatDOCX = "docx"
+	FormatText = "text"
+)
+
+// textExts 按纯文本读取的扩展名
+var textExts = map[string]bool{
+	".txt": true, ".md": true, ".csv": true, ".log": true, ".json": true, ".yaml": true, ".yml": true, ".xml": true,
+}
+
+// maxDocumentXML docx 正文 XML 的解压上限，防止压缩炸弹
+const maxDocumentXML = 50 << 20
+
+// Result 提取结果
+type Result struct {
+	Format    string
+	Text      string
+	Truncated bool // 超过 maxChars 被截断
+}
+
+// Text 提取文件正文，name 为原始文件名（可为空，此时按文件头识别），最多保留 maxChars 个字符
+func Text(path, name string, maxChars int) (*Result, error) {
+	format, err := detect(path, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var text string
+	switch format {
+	case FormatPDF:
+		text, err = readPDF(path)
+	case FormatDOCX:
+		text, err = readDOCX(path)
+	default:
+		text, err = readText(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("extract %s: %w", format, err)
+	}
+
+	res := &Result{Format: format, Text: strings.TrimSpace(text)}
+	if maxChars > 0 && utf8.RuneCountInString(res.Text) > maxChars {
+		res.Text = string([]rune(res.Text)[:maxChars])
+		res.Truncated = true
+	}
+	return res, nil
+}
+
+// detect 优先按扩展名识别格式，没有扩展名时读取文件头
+func detect(path, name string) (string, error) {
+	switch ext := strings.ToLower(filepath.Ext(name)); {
+	case ext == ".pdf":
+		return FormatPDF, nil
+	case ext == ".docx":
+		return FormatDOCX, nil
+	case textExts[ext]:
+		return FormatText, nil
+	case ext != "":
+		return "", fmt.Errorf("%w: %s", ErrUnsupported, ext)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("open file: %w", err)
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("read file: %w", err)
+	}
+	head = head[:n]
+	switch {
+	case bytes.HasPrefix(head, []byte("%PDF-")):
+		return FormatPDF, nil
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")):
+		// zip 容器中只支持 docx，其余在读取 word/document.xml 时报错
+		return FormatDOCX, nil
+	case utf8.Valid(head) && !bytes.ContainsRune(head, 0):
+		return FormatText, nil
+	}
+	return "", ErrUnsupported
+}
+
+func readText(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read file: %w", err)
+	}
+	if !utf8.Valid(data) {
+		return "", fmt.Errorf("%w: text is not utf-8", ErrUnsupported)
+	}
+	return string(data), nil
+}
+
+// readPDF 提取 PDF 文本，解析库遇到异常结构时可能 panic，统一转换为错误
+func readPDF(path string) (text string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("malformed pdf: %v", r)
+		}
+	}()
+	f, r, err := pdf.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("open pdf: %w", err)
+	}
+	defer f.Close()
+	plain, err := r.GetPlainText()
+	if err != nil {
+		return "", fmt.Errorf("read pdf text: %w", err)
+	}
+	data, err := io.ReadAll(plain)
+	if err != nil {
+		return "", fmt.Errorf("read pdf text: %w", err)
+	}
+	return string(data), nil
+}
+
+// readDOCX 读取 word/document.xml 中的文本，段落之间换行
+func readDOCX(path string) (string, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return "", fmt.Errorf("open docx: %w", err)
+	}
+	defer zr.Close()
+
+	var doc *zip.File
+	for _, f := range zr.File {
+		if f.Name == "word/document.xml" {
+			doc = f
+			break
+		}
+	}
+	if doc == nil {
+		return "", fmt.Errorf("%w: zip archive is not a docx document", ErrUnsupported)
+	}
+	rc, err := doc.Open()
+	if err != nil {
+		return "", fmt.Errorf("open document.xml: %w", err)
+	}
+	defer rc.Close()
+
+	var b strings.Builder
+	dec := xml.NewDecoder(io.LimitReader(rc, maxDocumentXML))
+	inText := false
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("parse document.xml: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				b.WriteString("\t")
+			case "br":
+				b.WriteString("\n")
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				b.WriteString("\n")
+			}
+		case xml.CharData:
+			if inText {
+				b.Write(t)
+			}
+		}
+	}
+	return b.String(), nil
+}
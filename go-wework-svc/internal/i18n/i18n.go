@@ -0,0 +1,241 @@
+// Package i18n 系统回复的多语言文案，按成员的语言（配置或通讯录自定义字段）选择，内置 zh-CN 和 en-US
+package i18n
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"text/template"
+
+	"go-wework-svc/internal/shared"
+	"go-wework-svc/internal/wework"
+)
+
+// 内置语言
+const (
+	ZhCN = "zh-CN"
+	EnUS = "en-US"
+)
+
+// builtin 内置文案，欢迎语没有内置文案，需配置 welcome.message
+var builtin = map[string]map[string]string{
+	ZhCN: {
+		wework.ReplyRateLimited:   "消息发送过于频繁，请稍后再试。",
+		wework.ReplyQuotaExceeded: "今天的提问次数已用完，请明天再试。",
+		wework.ReplyAccessDenied:  "抱歉，你暂无使用该应用的权限。",
+		wework.ReplyInputBlocked:  "消息包含不当内容，请修改后重试。",
+		wework.ReplyOutputBlocked: "回复包含不当内容，已拦截。",
+		wework.ReplyAIError:       "抱歉，AI 服务暂时不可用，请稍后再试。",
+	},
+	EnUS: {
+		wework.ReplyRateLimited:   "You are sending messages too quickly. Please try again later.",
+		wework.ReplyQuotaExceeded: "You have used up today's quota. Please try again tomorrow.",
+		wework.ReplyAccessDenied:  "Sorry, you do not have permission to use this app.",
+		wework.ReplyInputBlocked:  "Your message contains inappropriate content. Please revise it and try again.",
+		wework.ReplyOutputBlocked: "The reply contained inappropriate content and was withheld.",
+		wework.ReplyAIError:       "Sorry, the AI service is temporarily unavailable. Please try again later.",
+	},
+}
+
+// keys 可配置的文案键
+var keys = []string{
+	wework.ReplyWelcome,
+	wework.ReplyRateLimited,
+	wework.ReplyQuotaExceeded,
+	wework.ReplyAccessDenied,
+	wework.ReplyInputBlocked,
+	wework.ReplyOutputBlocked,
+	wework.ReplyAIError,
+}
+
+// aliases 常见的语言写法，键为小写
+var aliases = map[string]string{
+	"zh":         ZhCN,
+	"zh-hans":    ZhCN,
+	"zh-hans-cn": ZhCN,
+	"chinese":    ZhCN,
+	"中文":         ZhCN,
+	"简体中文":       ZhCN,
+	"en":         EnUS,
+	"english":    EnUS,
+	"英文":         EnUS,
+	"英语":         EnUS,
+}
+
+// LanguageSource 通讯录中成员的自定义字段，见 client.Directory
+type LanguageSource interface {
+	Attribute(ctx context.Context, userID, name string) (string, error)
+}
+
+// catalog 编译后的配置
+type catalog struct {
+	defaultLocale string
+	users         map[string]string // UserID → 语言
+	contactsAttr  string
+	// messages 语言 → 文案键 → 模板，模板为 nil 表示不回复
+	messages map[string]map[string]*template.Template
+}
+
+// Localizer 按成员语言选择系统回复，配置可通过 Reload 在运行时替换
+type Localizer struct {
+	catalog atomic.Pointer[catalog]
+	source  LanguageSource // 为 nil 时不查询通讯录
+	logger  *slog.Logger
+}
+
+// New 创建多语言文案，source 可为 nil
+func New(cfg shared.I18nConfig, source LanguageSource, logger *slog.Logger) (*Localizer, error) {
+	l := &Localizer{source: source, logger: logger}
+	if err := l.Reload(cfg); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Reload 重新编译文案并原子替换，任一文案无效时保留原配置
+func (l *Localizer) Reload(cfg shared.I18nConfig) error {
+	c := &catalog{
+		users:        make(map[string]string, len(cfg.Users)),
+		contactsAttr: cfg.ContactsAttr,
+		messages:     make(map[string]map[string]*template.Template),
+	}
+	for locale, msgs := range builtin {
+		if err := c.add(locale, msgs); err != nil {
+			return err
+		}
+	}
+	for locale, msgs := range cfg.Messages {
+		for key := range msgs {
+			if !slices.Contains(keys, key) {
+				return fmt.Errorf("messages.%s: unknown key %q", locale, key)
+			}
+		}
+		if err := c.add(normalize(locale), msgs); err != nil {
+			return err
+		}
+	}
+	c.defaultLocale = c.canonical(cmp.Or(cfg.DefaultLocale, ZhCN))
+	if _, ok := c.messages[c.defaultLocale]; !ok {
+		return fmt.Errorf("default_locale: no messages for %q", cfg.DefaultLocale)
+	}
+	for user, locale := range cfg.Users {
+		c.users[user] = c.canonical(locale)
+	}
+	l.catalog.Store(c)
+	return nil
+}
+
+// add 编译 locale 的文案，覆盖同名文案
+func (c *catalog) add(locale string, msgs map[string]string) error {
+	m := c.messages[locale]
+	if m == nil {
+		m = make(map[string]*template.Template, len(msgs))
+		c.messages[locale] = m
+	}
+	for key, text := range msgs {
+		if text == "" {
+			m[key] = nil
+			continue
+		}
+		tmpl, err := template.New(key).Option("missingkey=zero").Parse(text)
+		if err != nil {
+			return fmt.Errorf("messages.%s.%s: %w", locale, key, err)
+		}
+		m[key] = tmpl
+	}
+	return nil
+}
+
+// normalize 规范语言写法，如 en_us、English 规范为 en-US
+func normalize(locale string) string {
+	s := strings.ReplaceAll(strings.TrimSpace(locale), "_", "-")
+	if a, ok := aliases[strings.ToLower(s)]; ok {
+		return a
+	}
+	for l := range builtin {
+		if strings.EqualFold(l, s) {
+			return l
+		}
+	}
+	return s
+}
+
+// canonical 返回与 locale 匹配的已有文案语言，语言相同而地区不同时取该语言的任一文案，无法匹配时原样返回
+func (c *catalog) canonical(locale string) string {
+	s := normalize(locale)
+	if _, ok := c.messages[s]; ok {
+		return s
+	}
+	locales := make([]string, 0, len(c.messages))
+	for l := range c.messages {
+		locales = append(locales, l)
+	}
+	slices.Sort(locales)
+	for _, l := range locales {
+		if strings.EqualFold(l, s) {
+			return l
+		}
+	}
+	base, _, _ := strings.Cut(s, "-")
+	for _, l := range locales {
+		if b, _, _ := strings.Cut(l, "-"); strings.EqualFold(b, base) {
+			return l
+		}
+	}
+	return s
+}
+
+// Text 实现 wework.Localizer 接口
+func (l *Localizer) Text(ctx context.Context, userID, key, fallback string, data any) string {
+	c := l.catalog.Load()
+	if locale := l.locale(ctx, c, userID); locale != c.defaultLocale {
+		if text, ok := l.render(ctx, c, locale, key, data); ok {
+			return text
+		}
+	}
+	if fallback != "" {
+		return fallback
+	}
+	text, _ := l.render(ctx, c, c.defaultLocale, key, data)
+	return text
+}
+
+// locale 返回成员的语言：先查配置，再查通讯录，都没有时使用默认语言
+func (l *Localizer) locale(ctx context.Context, c *catalog, userID string) string {
+	if locale, ok := c.users[userID]; ok {
+		return locale
+	}
+	if l.source == nil || c.contactsAttr == "" || userID == "" {
+		return c.defaultLocale
+	}
+	v, err := l.source.Attribute(ctx, userID, c.contactsAttr)
+	if err != nil {
+		l.logger.WarnContext(ctx, "failed to load user language", "user_id", userID, "error", err)
+		return c.defaultLocale
+	}
+	if v == "" {
+		return c.defaultLocale
+	}
+	return c.canonical(v)
+}
+
+// render 渲染 locale 下的文案，ok 为 false 表示该语言没有此文案
+func (l *Localizer) render(ctx context.Context, c *catalog, locale, key string, data any) (string, bool) {
+	tmpl, ok := c.messages[locale][key]
+	if !ok {
+		return "", false
+	}
+	if tmpl == nil {
+		return "", true
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		l.logger.WarnContext(ctx, "i18n template failed", "locale", locale, "key", key, "error", err)
+		return "", false
+	}
+	return strings.TrimSpace(b.String()), true
+}
@@ -0,0 +1,269 @@
+// Package kf 微信客服：拉取外部微信用户发给客服账号的消息，转发给 AI 并通过客服接口回复
+package kf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go-wework-svc/internal/ai"
+	"go-wework-svc/internal/store"
+	"go-wework-svc/internal/wework"
+)
+
+// EventMsgOrEvent 客服消息或事件通知，回调只携带拉取凭证，消息需通过 sync_msg 拉取
+const EventMsgOrEvent = "kf_msg_or_event"
+
+// OriginCustomer 消息来源：微信客户发送
+const OriginCustomer = 3
+
+// Message sync_msg 返回的客服消息
+type Message struct {
+	MsgID          string `json:"msgid"`
+	OpenKfID       string `json:"open_kfid"`
+	ExternalUserID string `json:"external_userid"`
+	SendTime       int64  `json:"send_time"`
+	Origin         int    `json:"origin"`
+	MsgType        string `json:"msgtype"`
+	Text           struct {
+		Content string `json:"content"`
+	} `json:"text"`
+}
+
+// SyncRequest sync_msg 请求参数
+type SyncRequest struct {
+	Cursor   string
+	Token    string // 回调事件中的 Token，10 分钟内有效，可提高调用频率限制
+	OpenKfID string
+	Limit    int
+}
+
+// SyncResult sync_msg 返回结果
+type SyncResult struct {
+	NextCursor string
+	HasMore    bool
+	Messages   []Message
+}
+
+// Client 微信客服 API
+type Client interface {
+	// SyncMsg 按游标拉取客服消息
+	SyncMsg(ctx context.Context, req SyncRequest) (*SyncResult, error)
+
+	// SendText 向微信客户发送文本消息
+	SendText(ctx context.Context, openKfID, externalUserID, content string) error
+}
+
+const (
+	syncLimit = 1000
+	// lockTTL 拉取租约的有效期，每批消息处理前续期
+	lockTTL = 2 * time.Minute
+	// firstSyncSkew 首次拉取（没有游标）时，早于事件时间超过该值的历史消息不再处理
+	firstSyncSkew = time.Minute
+)
+
+// errLockLost 拉取过程中租约过期并被其他副本获取
+var errLockLost = errors.New("kf sync lock lost")
+
+// Service 处理 kf_msg_or_event 事件，实现 wework.EventHandler
+type Service struct {
+	client Client
+	aiSvc  ai.Service
+	kv     store.Store
+	logger *slog.Logger
+
+	// redactor 非空时在转发 AI 前脱敏客户消息
+	redactor wework.Transformer
+	// moderator 非空时审核客户消息和 AI 回复
+	moderator wework.Moderator
+}
+
+// Option Service 的可选配置
+type Option func(*Service)
+
+// WithRedactor 设置个人信息脱敏，客户消息在转发 AI 前经过该变换
+func WithRedactor(t wework.Transformer) Option {
+	return func(s *Service) { s.redactor = t }
+}
+
+// WithModerator 设置内容审核，客户消息在脱敏后、转发 AI 前审核，AI 回复在发送前审核
+func WithModerator(m wework.Moderator) Option {
+	return func(s *Service) { s.moderator = m }
+}
+
+// NewService 创建微信客服服务，游标保存在 kv 中，重启后从上次位置继续拉取
+func NewService(client Client, aiSvc ai.Service, kv store.Store, logger *slog.Logger, opts ...Option) *Service {
+	s := &Service{
+		client: client,
+		aiSvc:  aiSvc,
+		kv:     kv,
+		logger: logger,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// HandleEvent 实现 wework.EventHandler，回复通过客服接口发送，不经过应用消息
+// 每个客服账号通过共享 Store 中的租约串行拉取；租约被其他副本持有时登记待拉取标记，由持有者拉完后再拉取一次
+func (s *Service) HandleEvent(ctx context.Context, ev wework.Event) (string, error) {
+	pendingKey := "kf:pending:" + ev.OpenKfID
+	lease, ok, err := store.NewLock(s.kv, "kf:lock:"+ev.OpenKfID, lockTTL).Acquire(ctx)
+	if err != nil {
+		return "", fmt.Errorf("acquire kf sync lock: %w", err)
+	}
+	if !ok {
+		if err := s.kv.Set(ctx, pendingKey, ev.Token, lockTTL); err != nil {
+			return "", fmt.Errorf("mark kf sync pending: %w", err)
+		}
+		return "", nil
+	}
+	defer lease.Release(context.WithoutCancel(ctx))
+
+	token := ev.Token
+	for {
+		if err := s.sync(ctx, lease, ev, token); err != nil {
+			return "", err
+		}
+		next, pending, err := s.kv.Get(ctx, pendingKey)
+		if err != nil {
+			return "", fmt.Errorf("read kf sync pending: %w", err)
+		}
+		if !pending {
+			return "", nil
+		}
+		if err := s.kv.Delete(ctx, pendingKey); err != nil {
+			return "", fmt.Errorf("clear kf sync pending: %w", err)
+		}
+		token = next
+	}
+}
+
+// sync 从保存的游标拉取到最新位置，每条消息转发 AI 前续期租约
+// 没有游标时（首次启用）跳过事件之前的历史消息，避免回放客服账号的全部历史
+func (s *Service) sync(ctx context.Context, lease *store.Lease, ev wework.Event, token string) error {
+	cursorKey := "kf:cursor:" + ev.OpenKfID
+	cursor, _, err := s.kv.Get(ctx, cursorKey)
+	if err != nil {
+		return fmt.Errorf("read kf cursor: %w", err)
+	}
+	var since int64
+	if cursor == "" {
+		since = time.Now().Add(-firstSyncSkew).Unix()
+		if ev.CreateTime > 0 {
+			since = ev.CreateTime - int64(firstSyncSkew/time.Second)
+		}
+	}
+
+	skipped := 0
+	for {
+		res, err := s.client.SyncMsg(ctx, SyncRequest{
+			Cursor:   cursor,
+			Token:    token,
+			OpenKfID: ev.OpenKfID,
+			Limit:    syncLimit,
+		})
+		if err != nil {
+			return fmt.Errorf("sync kf messages: %w", err)
+		}
+		for _, msg := range res.Messages {
+			if msg.SendTime < since {
+				skipped++
+				continue
+			}
+			if err := renew(ctx, lease); err != nil {
+				return err
+			}
+			s.handleMessage(ctx, msg)
+		}
+		if err := renew(ctx, lease); err != nil {
+			return err
+		}
+
+		cursor = res.NextCursor
+		if err := s.kv.Set(ctx, cursorKey, cursor, 0); err != nil {
+			return fmt.Errorf("save kf cursor: %w", err)
+		}
+		if !res.HasMore {
+			break
+		}
+	}
+	if skipped > 0 {
+		s.logger.Info("skipped kf history on first sync", "open_kfid", ev.OpenKfID, "skipped", skipped)
+	}
+	return nil
+}
+
+// renew 续期拉取租约，租约已被其他副本获取时停止拉取，游标由新的持有者推进
+func renew(ctx context.Context, lease *store.Lease) error {
+	held, err := lease.Renew(ctx)
+	if err != nil {
+		return fmt.Errorf("renew kf sync lock: %w", err)
+	}
+	if !held {
+		return errLockLost
+	}
+	return nil
+}
+
+// handleMessage 转发微信客户的文本消息给 AI 并回复，单条失败不影响后续消息
+func (s *Service) handleMessage(ctx context.Context, msg Message) {
+	if msg.Origin != OriginCustomer || msg.MsgType != wework.MsgTypeText {
+		return
+	}
+
+	content := msg.Text.Content
+	if s.redactor != nil {
+		content = s.redactor.Transform(ctx, content)
+	}
+	content, blocked := s.moderate(ctx, msg, wework.DirectionInbound, content)
+	if blocked {
+		s.reply(ctx, msg, content)
+		return
+	}
+	resp, err := s.aiSvc.SendMessage(ctx, ai.ChatRequest{
+		UserID:  msg.ExternalUserID,
+		Content: content,
+		Source:  "wework_kf",
+		// 同一客户在不同客服账号下为不同会话
+		ConversationID: "kf:" + msg.OpenKfID + ":" + msg.ExternalUserID,
+	})
+	if err != nil {
+		s.logger.Error("failed to forward kf message to AI", "msg_id", msg.MsgID, "error", err)
+		return
+	}
+	reply, _ := s.moderate(ctx, msg, wework.DirectionOutbound, resp.Reply)
+	s.reply(ctx, msg, reply)
+}
+
+// moderate 审核客户消息或 AI 回复；blocked 为 true 时返回拦截提示，为空则不回复
+func (s *Service) moderate(ctx context.Context, msg Message, direction, content string) (_ string, blocked bool) {
+	if s.moderator == nil || content == "" {
+		return content, false
+	}
+	v := s.moderator.Moderate(ctx, direction, msg.ExternalUserID, content)
+	if v.Action != wework.ModerationAllow {
+		s.logger.Warn("kf content moderated",
+			"msg_id", msg.MsgID,
+			"direction", direction,
+			"action", v.Action,
+			"reasons", v.Reasons,
+		)
+	}
+	return v.Content, v.Action == wework.ModerationBlock
+}
+
+// reply 通过客服接口回复微信客户，内容为空时不发送
+func (s *Service) reply(ctx context.Context, msg Message, content string) {
+	if content == "" {
+		return
+	}
+	if err := s.client.SendText(ctx, msg.OpenKfID, msg.ExternalUserID, content); err != nil {
+		s.logger.Error("failed to send kf reply", "msg_id", msg.MsgID, "error", err)
+		return
+	}
+	s.logger.Info("kf reply sent", "msg_id", msg.MsgID, "open_kfid", msg.OpenKfID)
+}
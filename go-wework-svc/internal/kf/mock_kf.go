@@ -0,0 +1,70 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal/kf/kf.go
+//
+// Generated by this command:
+//
+//	mockgen -source=internal/kf/kf.go -destination=internal/kf/mock_kf.go -package=kf
+//
+
+// Package kf is a generated GoMock package.
+package kf
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockClient is a mock of Client interface.
+type MockClient struct {
+	ctrl     *gomock.Controller
+	recorder *MockClientMockRecorder
+	isgomock struct{}
+}
+
+// MockClientMockRecorder is the mock recorder for MockClient.
+type MockClientMockRecorder struct {
+	mock *MockClient
+}
+
+// NewMockClient creates a new mock instance.
+func NewMockClient(ctrl *gomock.Controller) *MockClient {
+	mock := &MockClient{ctrl: ctrl}
+	mock.recorder = &MockClientMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockClient) EXPECT() *MockClientMockRecorder {
+	return m.recorder
+}
+
+// SendText mocks base method.
+func (m *MockClient) SendText(ctx context.Context, openKfID, externalUserID, content string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendText", ctx, openKfID, externalUserID, content)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendText indicates an expected call of SendText.
+func (mr *MockClientMockRecorder) SendText(ctx, openKfID, externalUserID, content any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendText", reflect.TypeOf((*MockClient)(nil).SendText), ctx, openKfID, externalUserID, content)
+}
+
+// SyncMsg mocks base method.
+func (m *MockClient) SyncMsg(ctx context.Context, req SyncRequest) (*SyncResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SyncMsg", ctx, req)
+	ret0, _ := ret[0].(*SyncResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SyncMsg indicates an expected call of SyncMsg.
+func (mr *MockClientMockRecorder) SyncMsg(ctx, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SyncMsg", reflect.TypeOf((*MockClient)(nil).SyncMsg), ctx, req)
+}
@@ -0,0 +1,271 @@
+package leader
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// serviceAccountDir Pod 内挂载的 ServiceAccount 凭据目录
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// microTime Kubernetes MicroTime 序列化格式
+const microTime = "2006-01-02T15:04:05.000000Z07:00"
+
+// errConflict 租约已被其他实例更新（resourceVersion 不一致）
+var errConflict = errors.New("lease conflict")
+
+// KubeLease 基于 coordination.k8s.io/v1 Lease 对象的租约，使用 Pod 内的 ServiceAccount 访问 API Server
+// ServiceAccount 需要对该命名空间 leases 资源的 get、create、update 权限
+type KubeLease struct {
+	client    *http.Client
+	baseURL   string
+	token     string
+	namespace string
+	name      string
+	identity  string
+	ttl       time.Duration
+}
+
+// kubeLease Lease 对象中用到的字段
+type kubeLease struct {
+	APIVersion string         `json:"apiVersion"`
+	Kind       string         `json:"kind"`
+	Metadata   kubeObjectMeta `json:"metadata"`
+	Spec       kubeLeaseSpec  `json:"spec"`
+}
+
+type kubeObjectMeta struct {
+	Name            string `json:"name"`
+	Namespace       string `json:"namespace,omitempty"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+type kubeLeaseSpec struct {
+	HolderIdentity       *string `json:"holderIdentity,omitempty"`
+	LeaseDurationSeconds *int32  `json:"leaseDurationSeconds,omitempty"`
+	AcquireTime          *string `json:"acquireTime,omitempty"`
+	RenewTime            *string `json:"renewTime,omitempty"`
+	LeaseTransitions     *int32  `json:"leaseTransitions,omitempty"`
+}
+
+// NewKubeLease 使用集群内配置创建租约，namespace 为空时取 Pod 所在命名空间
+func NewKubeLease(namespace, name, identity string, ttl time.Duration) (*KubeLease, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("not running in a kubernetes cluster: KUBERNETES_SERVICE_HOST/PORT not set")
+	}
+	token, err := os.ReadFile(serviceAccountDir + "/token")
+	if err != nil {
+		return nil, fmt.Errorf("read service account token: %w", err)
+	}
+	ca, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, fmt.Errorf("read service account ca: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("parse service account ca: no certificates found")
+	}
+	if namespace == "" {
+		ns, err := os.ReadFile(serviceAccountDir + "/namespace")
+		if err != nil {
+			return nil, fmt.Errorf("read service account namespace: %w", err)
+		}
+		namespace = strings.TrimSpace(string(ns))
+	}
+	return &KubeLease{
+		client: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}},
+		},
+		baseURL:   "https://" + net.JoinHostPort(host, port),
+		token:     strings.TrimSpace(string(token)),
+		namespace: namespace,
+		name:      name,
+		identity:  identity,
+		ttl:       ttl,
+	}, nil
+}
+
+// Acquire 租约不存在时创建；由自己持有或已过期时更新持有者和续约时间，依赖 resourceVersion 防止并发覆盖
+func (l *KubeLease) Acquire(ctx context.Context) (bool, error) {
+	now := time.Now()
+	lease, err := l.get(ctx)
+	if err != nil {
+		return false, err
+	}
+	if lease == nil {
+		err := l.write(ctx, http.MethodPost, l.collectionURL(), l.newLease(now))
+		if errors.Is(err, errConflict) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+
+	spec := &lease.Spec
+	holder := ""
+	if spec.HolderIdentity != nil {
+		holder = *spec.HolderIdentity
+	}
+	if holder != l.identity && holder != "" && !expired(spec, now) {
+		return false, nil
+	}
+	ts := now.UTC().Format(microTime)
+	if holder != l.identity {
+		transitions := int32(0)
+		if spec.LeaseTransitions != nil {
+			transitions = *spec.LeaseTransitions
+		}
+		transitions++
+		spec.LeaseTransitions = &transitions
+		spec.AcquireTime = &ts
+		spec.HolderIdentity = &l.identity
+	}
+	seconds := l.seconds()
+	spec.LeaseDurationSeconds = &seconds
+	spec.RenewTime = &ts
+	err = l.write(ctx, http.MethodPut, l.objectURL(), lease)
+	if errors.Is(err, errConflict) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Release 清空持有者，其他实例下次续约时即可接手
+func (l *KubeLease) Release(ctx context.Context) error {
+	lease, err := l.get(ctx)
+	if err != nil || lease == nil {
+		return err
+	}
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != l.identity {
+		return nil
+	}
+	empty := ""
+	lease.Spec.HolderIdentity = &empty
+	err = l.write(ctx, http.MethodPut, l.objectURL(), lease)
+	if errors.Is(err, errConflict) {
+		return nil
+	}
+	return err
+}
+
+// expired 续约时间加租期早于当前时间
+func expired(spec *kubeLeaseSpec, now time.Time) bool {
+	if spec.RenewTime == nil || spec.LeaseDurationSeconds == nil {
+		return true
+	}
+	renew, err := time.Parse(microTime, *spec.RenewTime)
+	if err != nil {
+		renew, err = time.Parse(time.RFC3339, *spec.RenewTime)
+		if err != nil {
+			return true
+		}
+	}
+	return renew.Add(time.Duration(*spec.LeaseDurationSeconds) * time.Second).Before(now)
+}
+
+func (l *KubeLease) newLease(now time.Time) *kubeLease {
+	ts := now.UTC().Format(microTime)
+	seconds := l.seconds()
+	transitions := int32(0)
+	return &kubeLease{
+		APIVersion: "coordination.k8s.io/v1",
+		Kind:       "Lease",
+		Metadata:   kubeObjectMeta{Name: l.name, Namespace: l.namespace},
+		Spec: kubeLeaseSpec{
+			HolderIdentity:       &l.identity,
+			LeaseDurationSeconds: &seconds,
+			AcquireTime:          &ts,
+			RenewTime:            &ts,
+			LeaseTransitions:     &transitions,
+		},
+	}
+}
+
+func (l *KubeLease) seconds() int32 {
+	return int32(max(l.ttl/time.Second, 1))
+}
+
+func (l *KubeLease) collectionURL() string {
+	return l.baseURL + "/apis/coordination.k8s.io/v1/namespaces/" + l.namespace + "/leases"
+}
+
+func (l *KubeLease) objectURL() string {
+	return l.collectionURL() + "/" + l.name
+}
+
+// get 读取租约，不存在时返回 nil
+func (l *KubeLease) get(ctx context.Context) (*kubeLease, error) {
+	resp, err := l.do(ctx, http.MethodGet, l.objectURL(), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, nil
+	default:
+		return nil, statusError("get lease", resp)
+	}
+	var lease kubeLease
+	if err := json.NewDecoder(resp.Body).Decode(&lease); err != nil {
+		return nil, fmt.Errorf("decode lease: %w", err)
+	}
+	return &lease, nil
+}
+
+// write 创建或更新租约，409 返回 errConflict
+func (l *KubeLease) write(ctx context.Context, method, url string, lease *kubeLease) error {
+	body, err := json.Marshal(lease)
+	if err != nil {
+		return fmt.Errorf("marshal lease: %w", err)
+	}
+	resp, err := l.do(ctx, method, url, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	case http.StatusConflict:
+		return errConflict
+	default:
+		return statusError("write lease", resp)
+	}
+}
+
+func (l *KubeLease) do(ctx context.Context, method, url string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("build lease request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+l.token)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s lease: %w", strings.ToLower(method), err)
+	}
+	return resp, nil
+}
+
+func statusError(op string, resp *http.Response) error {
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("%s: status %d: %s", op, resp.StatusCode, strings.TrimSpace(string(msg)))
+}
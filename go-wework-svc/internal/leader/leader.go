@@ -0,0 +1,128 @@
+// Package leader 多副本部署时的选主，定时消息、会话存档拉取等单例后台任务只在持有租约的实例上运行
+package leader
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Lease 跨副本互斥的租约
+type Lease interface {
+	// Acquire 获取或续期租约，其他实例持有未过期的租约时返回 false
+	Acquire(ctx context.Context) (bool, error)
+	// Release 主动释放自己持有的租约，使其他实例无需等待过期即可接手
+	Release(ctx context.Context) error
+}
+
+// Elector 周期性获取租约，成为主实例时启动单例任务，失去租约时取消任务并等待其退出
+type Elector struct {
+	lease  Lease
+	ttl    time.Duration
+	jobs   []func(context.Context)
+	logger *slog.Logger
+
+	leading atomic.Bool
+}
+
+// NewElector 创建选主器，每 ttl/3 续约一次；续约失败超过 ttl 的 2/3 仍未成功时主动退位，避免与新主实例同时运行
+func NewElector(lease Lease, ttl time.Duration, logger *slog.Logger) *Elector {
+	return &Elector{lease: lease, ttl: ttl, logger: logger}
+}
+
+// Add 注册单例任务，须在 Run 之前调用；任务应在 ctx 取消后尽快返回
+func (e *Elector) Add(job func(context.Context)) {
+	e.jobs = append(e.jobs, job)
+}
+
+// Leading 当前实例是否为主实例
+func (e *Elector) Leading() bool {
+	return e.leading.Load()
+}
+
+// Run 参与选主直到 ctx 取消，退出前停止单例任务并释放租约
+func (e *Elector) Run(ctx context.Context) {
+	interval := e.ttl / 3
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var (
+		cancel    context.CancelFunc
+		wg        sync.WaitGroup
+		lastRenew time.Time
+	)
+	stepDown := func() {
+		if cancel == nil {
+			return
+		}
+		cancel()
+		wg.Wait()
+		cancel = nil
+		e.setLeading(false)
+	}
+	start := func() {
+		jobCtx, stop := context.WithCancel(ctx)
+		cancel = stop
+		for _, job := range e.jobs {
+			wg.Go(func() { job(jobCtx) })
+		}
+		e.setLeading(true)
+	}
+	defer func() {
+		stepDown()
+		if !lastRenew.IsZero() {
+			releaseCtx, done := context.WithTimeout(context.WithoutCancel(ctx), interval)
+			defer done()
+			if err := e.lease.Release(releaseCtx); err != nil {
+				e.logger.Warn("failed to release leader lease", "error", err)
+			}
+		}
+	}()
+
+	for {
+		ok, err := e.lease.Acquire(ctx)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			e.logger.Warn("leader lease renewal failed", "error", err)
+			// 续约请求失败时在剩余租期内继续担任主实例
+			if cancel != nil && time.Since(lastRenew) > e.ttl*2/3 {
+				e.logger.Warn("leader lease not renewed in time, stepping down")
+				stepDown()
+			}
+		case ok:
+			lastRenew = time.Now()
+			if cancel == nil {
+				start()
+			}
+		case err == nil:
+			stepDown()
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (e *Elector) setLeading(leading bool) {
+	e.leading.Store(leading)
+	if leading {
+		e.logger.Info("became leader, starting singleton jobs", "jobs", len(e.jobs))
+	} else {
+		e.logger.Info("lost leadership, singleton jobs stopped")
+	}
+}
+
+// DefaultIdentity 返回主机名加随机后缀，同一主机上的多个进程也能区分；Kubernetes 中主机名即 Pod 名称
+func DefaultIdentity() string {
+	host, _ := os.Hostname()
+	b := make([]byte, 4)
+	rand.Read(b)
+	return host + "-" + hex.EncodeToString(b)
+}
@@ -0,0 +1,74 @@
+package leader
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go-wework-svc/internal/store"
+)
+
+// valueExpirer 支持按值原子续期的存储
+type valueExpirer interface {
+	ExpireIfValue(ctx context.Context, key, value string, ttl time.Duration) (bool, error)
+}
+
+// valueDeleter 支持按值原子删除的存储
+type valueDeleter interface {
+	DeleteIfValue(ctx context.Context, key, value string) error
+}
+
+// StoreLease 基于共享存储（Redis）的租约，键值为持有者标识
+type StoreLease struct {
+	kv       store.Store
+	key      string
+	identity string
+	ttl      time.Duration
+}
+
+// NewStoreLease 创建存储租约，kv 须为多副本共享的存储
+func NewStoreLease(kv store.Store, key, identity string, ttl time.Duration) *StoreLease {
+	return &StoreLease{kv: kv, key: key, identity: identity, ttl: ttl}
+}
+
+// Acquire 键不存在时抢占，已由自己持有时续期
+func (l *StoreLease) Acquire(ctx context.Context) (bool, error) {
+	ok, err := l.kv.SetNX(ctx, l.key, l.identity, l.ttl)
+	if err != nil {
+		return false, fmt.Errorf("acquire lease %s: %w", l.key, err)
+	}
+	if ok {
+		return true, nil
+	}
+	if e, ok := l.kv.(valueExpirer); ok {
+		ok, err = e.ExpireIfValue(ctx, l.key, l.identity, l.ttl)
+		if err != nil {
+			return false, fmt.Errorf("renew lease %s: %w", l.key, err)
+		}
+		return ok, nil
+	}
+	// 不支持按值续期的存储先读后写，存在极小的竞争窗口
+	v, ok, err := l.kv.Get(ctx, l.key)
+	if err != nil {
+		return false, fmt.Errorf("get lease %s: %w", l.key, err)
+	}
+	if !ok || v != l.identity {
+		return false, nil
+	}
+	if err := l.kv.Set(ctx, l.key, l.identity, l.ttl); err != nil {
+		return false, fmt.Errorf("renew lease %s: %w", l.key, err)
+	}
+	return true, nil
+}
+
+// Release 仅删除自己持有的租约
+func (l *StoreLease) Release(ctx context.Context) error {
+	if d, ok := l.kv.(valueDeleter); ok {
+		return d.DeleteIfValue(ctx, l.key, l.identity)
+	}
+	v, ok, err := l.kv.Get(ctx, l.key)
+	if err != nil || !ok || v != l.identity {
+		return err
+	}
+	return l.kv.Delete(ctx, l.key)
+}
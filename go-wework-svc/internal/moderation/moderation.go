@@ -0,0 +1,178 @@
+// Package moderation 敏感词过滤与外部审核，在用户消息转发 AI 前和 AI 回复发送前检查内容
+package moderation
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"go-wework-svc/internal/shared"
+	"go-wework-svc/internal/wework"
+)
+
+const (
+	defaultAPITimeout = 2 * time.Second
+	defaultMask       = "*"
+	// maxAPIResponse 外部审核响应体上限
+	maxAPIResponse = 1 << 20
+)
+
+// 外部审核命中但未给出类别、或接口不可用时记录的原因
+const (
+	reasonAPIFlagged     = "api"
+	reasonAPIUnavailable = "api_unavailable"
+)
+
+// settings 编译后的审核配置
+type settings struct {
+	cfg        shared.ModerationConfig
+	words      []string
+	masker     *strings.Replacer
+	httpClient *http.Client
+}
+
+// Filter 实现 wework.Moderator，配置可通过 Reload 在运行时替换
+type Filter struct {
+	settings atomic.Pointer[settings]
+	logger   *slog.Logger
+}
+
+// New 创建内容审核，cfg.Enabled 为 false 时放行所有内容
+func New(cfg shared.ModerationConfig, logger *slog.Logger) *Filter {
+	f := &Filter{logger: logger}
+	f.Reload(cfg)
+	return f
+}
+
+// Reload 替换敏感词和审核策略，进行中的审核沿用旧配置
+func (f *Filter) Reload(cfg shared.ModerationConfig) {
+	// 长词在前，打码时优先替换较长的敏感词
+	words := slices.Clone(cfg.Words)
+	slices.SortFunc(words, func(a, b string) int { return cmp.Compare(len(b), len(a)) })
+	mask := cmp.Or(cfg.Mask, defaultMask)
+	pairs := make([]string, 0, 2*len(words))
+	for _, w := range words {
+		pairs = append(pairs, w, strings.Repeat(mask, utf8.RuneCountInString(w)))
+	}
+	f.settings.Store(&settings{
+		cfg:        cfg,
+		words:      words,
+		masker:     strings.NewReplacer(pairs...),
+		httpClient: &http.Client{Timeout: cmp.Or(cfg.API.Timeout, defaultAPITimeout)},
+	})
+}
+
+// Moderate 实现 wework.Moderator 接口
+func (f *Filter) Moderate(ctx context.Context, direction, user, content string) wework.Verdict {
+	s := f.settings.Load()
+	if !s.cfg.Enabled {
+		return wework.Verdict{Action: wework.ModerationAllow, Content: content}
+	}
+	policy := s.cfg.Inbound
+	if direction == wework.DirectionOutbound {
+		policy = s.cfg.Outbound
+	}
+
+	var reasons []string
+	for _, w := range s.words {
+		if strings.Contains(content, w) {
+			reasons = append(reasons, w)
+		}
+	}
+
+	// apiMasked 外部审核返回的打码内容，apiFlagged 为外部审核是否命中
+	var apiMasked string
+	apiFlagged := false
+	if s.cfg.API.URL != "" {
+		res, err := s.review(ctx, direction, user, content)
+		switch {
+		case err != nil && s.cfg.API.FailClosed:
+			f.logger.ErrorContext(ctx, "moderation api failed, blocking content", "direction", direction, "error", err)
+			return blocked(policy, append(reasons, reasonAPIUnavailable))
+		case err != nil:
+			f.logger.WarnContext(ctx, "moderation api failed, using word list only", "direction", direction, "error", err)
+		case res.Flagged:
+			apiFlagged = true
+			apiMasked = res.Content
+			if len(res.Categories) == 0 {
+				res.Categories = []string{reasonAPIFlagged}
+			}
+			reasons = append(reasons, res.Categories...)
+		}
+	}
+	if len(reasons) == 0 {
+		return wework.Verdict{Action: wework.ModerationAllow, Content: content}
+	}
+
+	switch policy.Action {
+	case shared.ModerationMask:
+		if apiFlagged && apiMasked == "" {
+			// 外部审核命中但未返回打码内容，无法定位敏感片段，按拦截处理
+			return blocked(policy, reasons)
+		}
+		return wework.Verdict{Action: wework.ModerationMask, Content: s.masker.Replace(cmp.Or(apiMasked, content)), Reasons: reasons}
+	case shared.ModerationFlag:
+		return wework.Verdict{Action: wework.ModerationFlag, Content: content, Reasons: reasons}
+	default:
+		return blocked(policy, reasons)
+	}
+}
+
+func blocked(policy shared.ModerationPolicy, reasons []string) wework.Verdict {
+	return wework.Verdict{Action: wework.ModerationBlock, Content: policy.Reply, Reasons: reasons}
+}
+
+// reviewRequest 外部审核请求体
+type reviewRequest struct {
+	Direction string `json:"direction"`
+	UserID    string `json:"user_id"`
+	Content   string `json:"content"`
+}
+
+// reviewResponse 外部审核响应体
+type reviewResponse struct {
+	Flagged    bool     `json:"flagged"`
+	Categories []string `json:"categories"`
+	Content    string   `json:"content"` // 可选，打码后的内容
+}
+
+// review 调用外部审核接口，非 2xx 响应视为失败
+func (s *settings) review(ctx context.Context, direction, user, content string) (*reviewResponse, error) {
+	body, err := json.Marshal(reviewRequest{Direction: direction, UserID: user, Content: content})
+	if err != nil {
+		return nil, fmt.Errorf("marshal review request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.API.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.cfg.API.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.cfg.API.APIKey)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("execute request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var out reviewResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxAPIResponse)).Decode(&out); err != nil {
+		return nil, fmt.Errorf("decode review response: %w", err)
+	}
+	return &out, nil
+}
@@ -0,0 +1,165 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"go-wework-svc/internal/store"
+	"go-wework-svc/internal/wework"
+)
+
+const (
+	defaultAlertInterval     = 30 * time.Second
+	defaultAlertCooldown     = 10 * time.Minute
+	defaultDLQThreshold      = 100
+	defaultSignatureFailures = 20
+
+	// signatureFailureReason 与 handler.ReasonInvalidSignature 一致
+	signatureFailureReason = "invalid_signature"
+	// outboxQueue outbox 队列在快照中的名称，即失败消息的死信队列
+	outboxQueue = "outbox"
+)
+
+// 告警类型
+const (
+	AlertAIDown            = "ai_down"
+	AlertAIRecovered       = "ai_recovered"
+	AlertAIBreakerOpen     = "ai_breaker_open"
+	AlertDLQ               = "dlq_backlog"
+	AlertSignatureFailures = "signature_failures"
+)
+
+// AlertPolicy 告警阈值，为 0 的字段使用默认值
+type AlertPolicy struct {
+	Interval time.Duration // 检查周期
+	Cooldown time.Duration // 同类告警的最短间隔，多副本共享
+	// DLQThreshold outbox 中待重投和死信记录数超过该值时告警
+	DLQThreshold int
+	// SignatureFailures 一个检查周期内签名失败次数超过该值时告警
+	SignatureFailures int
+	// Mentions 告警时 @ 的成员 userid
+	Mentions []string
+}
+
+// Alerter 定期检查 Monitor 快照，AI 后端不可用或熔断、DLQ 积压、签名失败激增时推送 Markdown 告警到群机器人
+// 告警经共享 Store 去重，同类告警在 Cooldown 内只发送一次
+type Alerter struct {
+	mon      *Monitor
+	hook     wework.Webhook
+	kv       store.Store
+	policy   AlertPolicy
+	instance string
+	logger   *slog.Logger
+
+	// 以下状态只在 Run 所在协程中访问
+	aiDown      bool
+	breakers    []string // 上次检查时处于熔断状态的后端
+	lastSigFail uint64
+}
+
+// NewAlerter 创建告警器
+func NewAlerter(mon *Monitor, hook wework.Webhook, kv store.Store, policy AlertPolicy, logger *slog.Logger) *Alerter {
+	if policy.Interval <= 0 {
+		policy.Interval = defaultAlertInterval
+	}
+	if policy.Cooldown <= 0 {
+		policy.Cooldown = defaultAlertCooldown
+	}
+	if policy.DLQThreshold <= 0 {
+		policy.DLQThreshold = defaultDLQThreshold
+	}
+	if policy.SignatureFailures <= 0 {
+		policy.SignatureFailures = defaultSignatureFailures
+	}
+	instance, _ := os.Hostname()
+	return &Alerter{mon: mon, hook: hook, kv: kv, policy: policy, instance: instance, logger: logger}
+}
+
+// Run 按周期检查直到 ctx 取消
+func (a *Alerter) Run(ctx context.Context) {
+	a.lastSigFail = a.mon.Snapshot().CallbackErrors[signatureFailureReason]
+	ticker := time.NewTicker(a.policy.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			a.check(ctx, a.mon.Snapshot())
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// check 对比快照与阈值，发送需要的告警
+func (a *Alerter) check(ctx context.Context, s Status) {
+	switch down := s.AI.Status == "down"; {
+	case down && !a.aiDown:
+		a.aiDown = true
+		a.fire(ctx, AlertAIDown, "AI 后端不可用",
+			fmt.Sprintf("最近 %d 次转发全部失败", s.AI.RecentTotal),
+			"最后错误："+s.AI.LastError)
+	case !down && a.aiDown && s.AI.Status == "healthy":
+		a.aiDown = false
+		a.fire(ctx, AlertAIRecovered, "AI 后端已恢复",
+			fmt.Sprintf("最近 %d 次转发失败 %d 次", s.AI.RecentTotal, s.AI.RecentFailures))
+	}
+
+	// 有新的后端熔断时告警，列出当前全部熔断的后端
+	for _, name := range s.AI.OpenBreakers {
+		if !slices.Contains(a.breakers, name) {
+			a.fire(ctx, AlertAIBreakerOpen, "AI 后端熔断",
+				"熔断中的后端："+strings.Join(s.AI.OpenBreakers, "、"),
+				"冷却期内请求直接降级到下一个后端")
+			break
+		}
+	}
+	a.breakers = s.AI.OpenBreakers
+
+	if n := s.Queues[outboxQueue]; n > a.policy.DLQThreshold {
+		a.fire(ctx, AlertDLQ, "失败消息积压",
+			fmt.Sprintf("outbox 中有 %d 条待重投或死信消息，阈值 %d", n, a.policy.DLQThreshold),
+			"可在 /admin/dlq 查看和重新入队")
+	}
+
+	sigFail := s.CallbackErrors[signatureFailureReason]
+	if d := sigFail - a.lastSigFail; d > uint64(a.policy.SignatureFailures) {
+		a.fire(ctx, AlertSignatureFailures, "回调签名失败激增",
+			fmt.Sprintf("%s 内 %d 次签名校验失败，阈值 %d", a.policy.Interval, d, a.policy.SignatureFailures),
+			"可能是 Token 配置错误或有伪造请求")
+	}
+	a.lastSigFail = sigFail
+}
+
+// fire 发送告警，同类告警在冷却期内跳过；存储故障时仍然发送
+func (a *Alerter) fire(ctx context.Context, kind, title string, lines ...string) {
+	first, err := a.kv.SetNX(ctx, "alert:"+kind, a.instance, a.policy.Cooldown)
+	if err != nil {
+		a.logger.Warn("alert dedup check failed", "kind", kind, "error", err)
+	} else if !first {
+		return
+	}
+
+	color := "warning"
+	if kind == AlertAIRecovered {
+		color = "info"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "### <font color=\"%s\">%s</font>\n", color, title)
+	for _, l := range lines {
+		fmt.Fprintf(&b, "> %s\n", l)
+	}
+	fmt.Fprintf(&b, "> 实例：%s\n> 时间：%s", a.instance, time.Now().Format(time.DateTime))
+	for _, u := range a.policy.Mentions {
+		fmt.Fprintf(&b, "\n<@%s>", u)
+	}
+	if err := a.hook.Post(ctx, wework.WebhookMessage{MsgType: wework.MsgTypeMarkdown, Content: b.String()}); err != nil {
+		a.logger.Error("failed to send alert", "kind", kind, "error", err)
+		return
+	}
+	a.logger.Info("alert sent", "kind", kind)
+}
@@ -0,0 +1,316 @@
+package monitor
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"go-wework-svc/internal/wework"
+)
+
+const (
+	defaultRecentSize = 50
+	// healthWindow AI 健康度统计的最近转发次数
+	healthWindow = 20
+)
+
+// 转发完成后的记录结果，其余结果见 wework.Outcome*
+const (
+	outcomeReplied = "replied"
+	outcomeFailed  = "failed"
+)
+
+// MessageRecord 最近消息记录，不包含消息正文
+type MessageRecord struct {
+	Time      time.Time `json:"time"`
+	MsgID     string    `json:"msg_id"`
+	User      string    `json:"user"` // 脱敏后的用户 ID
+	MsgType   string    `json:"msg_type"`
+	Length    int       `json:"length"` // 正文字符数
+	Outcome   string    `json:"outcome"`
+	LatencyMs int64     `json:"latency_ms,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// AIHealth AI 后端健康状态
+type AIHealth struct {
+	Status         string     `json:"status"` // healthy | degraded | down | unknown
+	LastSuccessAt  *time.Time `json:"last_success_at,omitempty"`
+	LastErrorAt    *time.Time `json:"last_error_at,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	RecentFailures int        `json:"recent_failures"`
+	RecentTotal    int        `json:"recent_total"`
+	AvgLatencyMs   int64      `json:"avg_latency_ms"`
+	// OpenBreakers 降级链中处于熔断状态的后端
+	OpenBreakers []string `json:"open_breakers,omitempty"`
+}
+
+// Counters 消息计数
+type Counters struct {
+	Received  uint64 `json:"received"`
+	Ignored   uint64 `json:"ignored"`
+	Forwarded uint64 `json:"forwarded"`
+	Events    uint64 `json:"events"`
+	Replied   uint64 `json:"replied"`
+	Failed    uint64 `json:"failed"`
+	Panics    uint64 `json:"panics"`     // HTTP 处理器和后台任务中恢复的 panic
+	Fallbacks uint64 `json:"fallbacks"`  // 由备用 AI 后端提供的回复
+	Throttled uint64 `json:"throttled"`  // 超出用户频率限制或每日额度
+	Denied    uint64 `json:"denied"`     // 访问控制拒绝
+	CacheHits uint64 `json:"cache_hits"` // 由回复缓存直接提供的回复
+}
+
+// Status 管道状态快照
+type Status struct {
+	StartedAt time.Time      `json:"started_at"`
+	UptimeSec int64          `json:"uptime_sec"`
+	Counters  Counters       `json:"counters"`
+	Queues    map[string]int `json:"queues"`
+	AI        AIHealth       `json:"ai"`
+	// AIProviders 配置了备用后端时各后端提供的回复数
+	AIProviders map[string]uint64 `json:"ai_providers,omitempty"`
+	// CallbackErrors 按原因统计的回调处理失败数，如 invalid_signature、decrypt_failed
+	CallbackErrors map[string]uint64 `json:"callback_errors,omitempty"`
+	// Security 回调安全校验失败统计，没有失败时为空
+	Security *SecurityStats  `json:"security,omitempty"`
+	Recent   []MessageRecord `json:"recent"`
+}
+
+type forwardResult struct {
+	latency time.Duration
+	failed  bool
+}
+
+// Monitor 收集消息管道运行状态，实现 wework.Observer
+type Monitor struct {
+	mu        sync.Mutex
+	startedAt time.Time
+	counters  Counters
+	inFlight  int64
+	recent    []MessageRecord // 环形缓冲
+	next      int
+	results   []forwardResult // 最近 healthWindow 次转发结果
+	ai        AIHealth
+	queues    map[string]func() int
+	providers map[string]uint64
+	breakers  map[string]bool // 处于熔断状态的后端
+	cbErrors  map[string]uint64
+	// security 中的 TopSources 不使用，来源计数保存在 secSources
+	security   SecurityStats
+	secSources map[string]*SourceCount
+}
+
+// New 创建 Monitor
+func New() *Monitor {
+	return &Monitor{
+		startedAt:  time.Now(),
+		recent:     make([]MessageRecord, 0, defaultRecentSize),
+		queues:     make(map[string]func() int),
+		providers:  make(map[string]uint64),
+		breakers:   make(map[string]bool),
+		cbErrors:   make(map[string]uint64),
+		security:   SecurityStats{ByReason: make(map[string]uint64)},
+		secSources: make(map[string]*SourceCount),
+	}
+}
+
+// RegisterQueue 注册队列深度采集函数，在快照中展示
+func (m *Monitor) RegisterQueue(name string, depth func() int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.queues[name] = depth
+}
+
+// RecordPanic 记录一次已恢复的 panic
+func (m *Monitor) RecordPanic() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counters.Panics++
+}
+
+// RecordAIProvider 记录一次由降级链中第 index 个后端提供的回复，index 为 0 表示主后端
+func (m *Monitor) RecordAIProvider(name string, index int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.providers[name]++
+	if index > 0 {
+		m.counters.Fallbacks++
+	}
+}
+
+// RecordAIBreaker 记录降级链中后端的熔断状态变化
+func (m *Monitor) RecordAIBreaker(name string, open bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if open {
+		m.breakers[name] = true
+	} else {
+		delete(m.breakers, name)
+	}
+}
+
+// RecordCacheHit 记录一次由回复缓存提供的回复
+func (m *Monitor) RecordCacheHit() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counters.CacheHits++
+}
+
+// RecordCallbackError 记录一次回调处理失败，reason 为失败原因
+func (m *Monitor) RecordCallbackError(reason string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cbErrors[reason]++
+}
+
+// OnMessage 实现 wework.Observer
+func (m *Monitor) OnMessage(_ context.Context, msg wework.Message, outcome string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.counters.Received++
+	switch outcome {
+	case wework.OutcomeIgnored:
+		m.counters.Ignored++
+	case wework.OutcomeForwarded:
+		m.counters.Forwarded++
+		m.inFlight++
+	case wework.OutcomeHandled:
+		m.counters.Events++
+	case wework.OutcomeThrottled:
+		m.counters.Throttled++
+	case wework.OutcomeDenied:
+		m.counters.Denied++
+	}
+	m.push(MessageRecord{
+		Time:    time.Now(),
+		MsgID:   msg.MsgID,
+		User:    maskUser(msg.FromUserName),
+		MsgType: msg.MsgType,
+		Length:  utf8.RuneCountInString(msg.Content),
+		Outcome: outcome,
+	})
+}
+
+// OnForwardDone 实现 wework.Observer
+func (m *Monitor) OnForwardDone(_ context.Context, msg wework.Message, latency time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.inFlight--
+	now := time.Now()
+	outcome := outcomeReplied
+	var errText string
+	if err != nil {
+		outcome = outcomeFailed
+		errText = err.Error()
+		m.counters.Failed++
+		m.ai.LastErrorAt = &now
+		m.ai.LastError = errText
+	} else {
+		m.counters.Replied++
+		m.ai.LastSuccessAt = &now
+	}
+
+	m.results = append(m.results, forwardResult{latency: latency, failed: err != nil})
+	if len(m.results) > healthWindow {
+		m.results = m.results[len(m.results)-healthWindow:]
+	}
+
+	m.push(MessageRecord{
+		Time:      now,
+		MsgID:     msg.MsgID,
+		User:      maskUser(msg.FromUserName),
+		MsgType:   msg.MsgType,
+		Length:    utf8.RuneCountInString(msg.Content),
+		Outcome:   outcome,
+		LatencyMs: latency.Milliseconds(),
+		Error:     errText,
+	})
+}
+
+// Snapshot 返回当前状态快照，Recent 按时间倒序
+func (m *Monitor) Snapshot() Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	recent := make([]MessageRecord, len(m.recent))
+	copy(recent, m.recent)
+	sort.Slice(recent, func(i, j int) bool { return recent[i].Time.After(recent[j].Time) })
+
+	queues := make(map[string]int, len(m.queues)+1)
+	queues["ai_forward_in_flight"] = int(m.inFlight)
+	for name, depth := range m.queues {
+		queues[name] = depth()
+	}
+	var providers, cbErrors map[string]uint64
+	if len(m.providers) > 0 {
+		providers = maps.Clone(m.providers)
+	}
+	if len(m.cbErrors) > 0 {
+		cbErrors = maps.Clone(m.cbErrors)
+	}
+
+	return Status{
+		StartedAt:      m.startedAt,
+		UptimeSec:      int64(time.Since(m.startedAt).Seconds()),
+		Counters:       m.counters,
+		Queues:         queues,
+		AI:             m.aiHealth(),
+		AIProviders:    providers,
+		CallbackErrors: cbErrors,
+		Security:       m.securityStats(),
+		Recent:         recent,
+	}
+}
+
+// aiHealth 根据最近的转发结果计算 AI 健康状态，调用方需持有锁
+func (m *Monitor) aiHealth() AIHealth {
+	h := m.ai
+	h.RecentTotal = len(m.results)
+	h.RecentFailures = 0
+	h.OpenBreakers = slices.Sorted(maps.Keys(m.breakers))
+	var total time.Duration
+	for _, r := range m.results {
+		total += r.latency
+		if r.failed {
+			h.RecentFailures++
+		}
+	}
+	switch {
+	case h.RecentTotal == 0:
+		h.Status = "unknown"
+		return h
+	case h.RecentFailures == h.RecentTotal:
+		h.Status = "down"
+	case h.RecentFailures*4 >= h.RecentTotal:
+		h.Status = "degraded"
+	default:
+		h.Status = "healthy"
+	}
+	h.AvgLatencyMs = (total / time.Duration(h.RecentTotal)).Milliseconds()
+	return h
+}
+
+// push 写入环形缓冲，调用方需持有锁
+func (m *Monitor) push(rec MessageRecord) {
+	if len(m.recent) < cap(m.recent) {
+		m.recent = append(m.recent, rec)
+		return
+	}
+	m.recent[m.next] = rec
+	m.next = (m.next + 1) % len(m.recent)
+}
+
+// maskUser 用户 ID 脱敏，仅保留首尾字符
+func maskUser(id string) string {
+	r := []rune(id)
+	if len(r) <= 2 {
+		return "***"
+	}
+	return string(r[0]) + "***" + string(r[len(r)-1])
+}
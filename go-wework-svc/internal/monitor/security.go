@@ -0,0 +1,78 @@
+package monitor
+
+import (
+	"maps"
+	"sort"
+	"time"
+)
+
+const (
+	// maxSecuritySources 单独计数的来源 IP 上限，超出后计入 otherSource，避免被伪造来源撑大内存
+	maxSecuritySources = 1000
+	otherSource        = "other"
+	topSecuritySources = 10
+)
+
+// SecurityStats 回调安全校验失败统计，用于发现对回调地址的探测
+type SecurityStats struct {
+	Total       uint64            `json:"total"`
+	ByReason    map[string]uint64 `json:"by_reason"`
+	LastEventAt time.Time         `json:"last_event_at"`
+	// TopSources 失败次数最多的来源 IP
+	TopSources []SourceCount `json:"top_sources"`
+}
+
+// SourceCount 一个来源 IP 的失败次数
+type SourceCount struct {
+	IP         string    `json:"ip"`
+	Count      uint64    `json:"count"`
+	LastReason string    `json:"last_reason"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+}
+
+// RecordSecurityEvent 记录一次签名、corp_id、解密或重放校验失败
+func (m *Monitor) RecordSecurityEvent(reason, ip string, at time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.security.Total++
+	m.security.ByReason[reason]++
+	m.security.LastEventAt = at
+
+	src, ok := m.secSources[ip]
+	if !ok {
+		if len(m.secSources) >= maxSecuritySources {
+			ip = otherSource
+			src = m.secSources[ip]
+		}
+		if src == nil {
+			src = &SourceCount{IP: ip}
+			m.secSources[ip] = src
+		}
+	}
+	src.Count++
+	src.LastReason = reason
+	src.LastSeenAt = at
+}
+
+// securityStats 复制安全统计，没有事件时返回 nil，调用方需持有锁
+func (m *Monitor) securityStats() *SecurityStats {
+	if m.security.Total == 0 {
+		return nil
+	}
+	s := m.security
+	s.ByReason = maps.Clone(m.security.ByReason)
+	s.TopSources = make([]SourceCount, 0, len(m.secSources))
+	for _, src := range m.secSources {
+		s.TopSources = append(s.TopSources, *src)
+	}
+	sort.Slice(s.TopSources, func(i, j int) bool {
+		if s.TopSources[i].Count != s.TopSources[j].Count {
+			return s.TopSources[i].Count > s.TopSources[j].Count
+		}
+		return s.TopSources[i].IP < s.TopSources[j].IP
+	})
+	if len(s.TopSources) > topSecuritySources {
+		s.TopSources = s.TopSources[:topSecuritySources]
+	}
+	return &s
+}
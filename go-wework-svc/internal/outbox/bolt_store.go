@@ -0,0 +1,141 @@
+package outbox
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var bucketName = []byte("outbox")
+
+// BoltStore 基于 BoltDB 的 Store 实现，单文件、单进程
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// NewBoltStore 打开（或创建）BoltDB 文件
+func NewBoltStore(path string) (*BoltStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create outbox dir: %w", err)
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open outbox db: %w", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucketName)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create outbox bucket: %w", err)
+	}
+	return &BoltStore{db: db}, nil
+}
+
+// Add 实现 Store 接口
+func (s *BoltStore) Add(_ context.Context, e *Entry) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketName)
+		id, err := b.NextSequence()
+		if err != nil {
+			return fmt.Errorf("next sequence: %w", err)
+		}
+		e.ID = id
+		return put(b, *e)
+	})
+}
+
+// Get 实现 Store 接口
+func (s *BoltStore) Get(_ context.Context, id uint64) (*Entry, error) {
+	var e Entry
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(bucketName).Get(itob(id))
+		if v == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(v, &e)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// List 实现 Store 接口
+func (s *BoltStore) List(_ context.Context, after uint64, limit int) ([]Entry, error) {
+	var entries []Entry
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketName).Cursor()
+		for k, v := c.Seek(itob(after + 1)); k != nil; k, v = c.Next() {
+			if limit > 0 && len(entries) >= limit {
+				break
+			}
+			var e Entry
+			if err := json.Unmarshal(v, &e); err != nil {
+				return fmt.Errorf("unmarshal entry %d: %w", binary.BigEndian.Uint64(k), err)
+			}
+			entries = append(entries, e)
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// Update 实现 Store 接口
+func (s *BoltStore) Update(_ context.Context, e Entry) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketName)
+		if b.Get(itob(e.ID)) == nil {
+			return ErrNotFound
+		}
+		return put(b, e)
+	})
+}
+
+// Delete 实现 Store 接口
+func (s *BoltStore) Delete(_ context.Context, id uint64) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketName)
+		if b.Get(itob(id)) == nil {
+			return ErrNotFound
+		}
+		return b.Delete(itob(id))
+	})
+}
+
+// Len 实现 Store 接口
+func (s *BoltStore) Len(_ context.Context) (int, error) {
+	var n int
+	err := s.db.View(func(tx *bolt.Tx) error {
+		n = tx.Bucket(bucketName).Stats().KeyN
+		return nil
+	})
+	return n, err
+}
+
+// Close 实现 Store 接口
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+func put(b *bolt.Bucket, e Entry) error {
+	v, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("marshal entry: %w", err)
+	}
+	return b.Put(itob(e.ID), v)
+}
+
+// itob 大端序编码保证游标按 ID 升序遍历
+func itob(id uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, id)
+	return b
+}
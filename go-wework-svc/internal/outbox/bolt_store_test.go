@@ -0,0 +1,143 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"go-wework-svc/internal/ai"
+)
+
+func newTestStore(t *testing.T) *BoltStore {
+	t.Helper()
+	s, err := NewBoltStore(filepath.Join(t.TempDir(), "outbox", "outbox.db"))
+	if err != nil {
+		t.Fatalf("new bolt store: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestBoltStoreRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	created := time.Date(2026, 10, 14, 9, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		entry Entry
+	}{
+		{
+			name: "single chat",
+			entry: Entry{
+				MsgID:     "m1",
+				UserID:    "zhangsan",
+				Request:   ai.ChatRequest{UserID: "zhangsan", Content: "你好", Source: "wework"},
+				CreatedAt: created,
+				Attempts:  1,
+				LastError: "ai timeout",
+			},
+		},
+		{
+			name: "group chat with response url",
+			entry: Entry{
+				MsgID:         "m2",
+				Agent:         "hr",
+				UserID:        "lisi",
+				ChatID:        "wrchat001",
+				ChatType:      "group",
+				ResponseURL:   "https://qyapi.weixin.qq.com/cgi-bin/aibot/response?response_code=abc",
+				Request:       ai.ChatRequest{UserID: "lisi", Content: "请假流程", Source: "wework", GroupID: "wrchat001", ConversationID: "group:wrchat001"},
+				CreatedAt:     created,
+				Attempts:      3,
+				LastError:     "send: 502",
+				LastAttemptAt: created.Add(time.Minute),
+				Dead:          true,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStore(t)
+			e := tt.entry
+			if err := s.Add(ctx, &e); err != nil {
+				t.Fatalf("add: %v", err)
+			}
+			if e.ID == 0 {
+				t.Fatal("add did not assign an id")
+			}
+			got, err := s.Get(ctx, e.ID)
+			if err != nil {
+				t.Fatalf("get: %v", err)
+			}
+			if !reflect.DeepEqual(*got, e) {
+				t.Fatalf("round trip mismatch:\ngot  %+v\nwant %+v", *got, e)
+			}
+
+			e.Attempts++
+			e.LastError = "retry failed"
+			if err := s.Update(ctx, e); err != nil {
+				t.Fatalf("update: %v", err)
+			}
+			got, err = s.Get(ctx, e.ID)
+			if err != nil {
+				t.Fatalf("get after update: %v", err)
+			}
+			if !reflect.DeepEqual(*got, e) {
+				t.Fatalf("update mismatch:\ngot  %+v\nwant %+v", *got, e)
+			}
+
+			if err := s.Delete(ctx, e.ID); err != nil {
+				t.Fatalf("delete: %v", err)
+			}
+			if _, err := s.Get(ctx, e.ID); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("get after delete: got %v, want ErrNotFound", err)
+			}
+			if err := s.Update(ctx, e); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("update after delete: got %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestBoltStoreList(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	for _, id := range []string{"m1", "m2", "m3", "m4", "m5"} {
+		if err := s.Add(ctx, &Entry{MsgID: id}); err != nil {
+			t.Fatalf("add %s: %v", id, err)
+		}
+	}
+	if n, err := s.Len(ctx); err != nil || n != 5 {
+		t.Fatalf("len: got %d, %v, want 5", n, err)
+	}
+
+	tests := []struct {
+		name  string
+		after uint64
+		limit int
+		want  []uint64
+	}{
+		{name: "first page", after: 0, limit: 2, want: []uint64{1, 2}},
+		{name: "next page", after: 2, limit: 2, want: []uint64{3, 4}},
+		{name: "no limit", after: 3, limit: 0, want: []uint64{4, 5}},
+		{name: "past the end", after: 5, limit: 2, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := s.List(ctx, tt.after, tt.limit)
+			if err != nil {
+				t.Fatalf("list: %v", err)
+			}
+			var got []uint64
+			for _, e := range entries {
+				got = append(got, e.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got ids %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package outbox
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// ProcessFunc 重投一条记录，返回 nil 表示成功并删除记录
+type ProcessFunc func(ctx context.Context, e Entry) error
+
+// Redeliverer 后台定期重投 outbox 中的失败消息
+type Redeliverer struct {
+	store       Store
+	process     ProcessFunc
+	interval    time.Duration
+	maxAttempts int
+	batchSize   int
+	logger      *slog.Logger
+}
+
+// NewRedeliverer 创建重投器
+func NewRedeliverer(store Store, process ProcessFunc, interval time.Duration, maxAttempts, batchSize int, logger *slog.Logger) *Redeliverer {
+	return &Redeliverer{
+		store:       store,
+		process:     process,
+		interval:    interval,
+		maxAttempts: maxAttempts,
+		batchSize:   batchSize,
+		logger:      logger,
+	}
+}
+
+// Run 按 interval 周期重投，直到 ctx 取消
+func (r *Redeliverer) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.drain(ctx)
+		}
+	}
+}
+
+// drain 按 ID 顺序重投一批记录，遇到失败即停止本轮（AI 后端大概率仍不可用）
+func (r *Redeliverer) drain(ctx context.Context) {
+	entries, err := r.store.List(ctx, 0, 0)
+	if err != nil {
+		r.logger.Error("failed to list outbox entries", "error", err)
+		return
+	}
+
+	processed := 0
+	for _, e := range entries {
+		if e.Dead {
+			continue
+		}
+		if processed >= r.batchSize || ctx.Err() != nil {
+			return
+		}
+		processed++
+
+		err := r.process(ctx, e)
+		if err == nil {
+			if err := r.store.Delete(ctx, e.ID); err != nil {
+				r.logger.Error("failed to delete redelivered entry", "id", e.ID, "error", err)
+			}
+			r.logger.Info("outbox entry redelivered", "id", e.ID, "msg_id", e.MsgID, "attempts", e.Attempts+1)
+			continue
+		}
+
+		e.Attempts++
+		e.LastError = err.Error()
+		e.LastAttemptAt = time.Now()
+		if r.maxAttempts > 0 && e.Attempts >= r.maxAttempts {
+			e.Dead = true
+			r.logger.Warn("outbox entry exceeded max attempts", "id", e.ID, "msg_id", e.MsgID, "attempts", e.Attempts)
+		}
+		if err := r.store.Update(ctx, e); err != nil {
+			r.logger.Error("failed to update outbox entry", "id", e.ID, "error", err)
+		}
+		r.logger.Debug("outbox redelivery failed", "id", e.ID, "error", e.LastError)
+		return
+	}
+}
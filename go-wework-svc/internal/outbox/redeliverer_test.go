@@ -0,0 +1,63 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestRedelivererDrain(t *testing.T) {
+	ctx := context.Background()
+	errSend := errors.New("no sender or response_url available")
+
+	tests := []struct {
+		name        string
+		fail        bool
+		attempts    int
+		maxAttempts int
+		wantKept    bool
+		wantDead    bool
+	}{
+		{name: "success deletes the entry", wantKept: false},
+		{name: "failure keeps the entry", fail: true, attempts: 1, maxAttempts: 5, wantKept: true},
+		{name: "failure at max attempts marks it dead", fail: true, attempts: 4, maxAttempts: 5, wantKept: true, wantDead: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStore(t)
+			e := &Entry{MsgID: "m1", UserID: "zhangsan", ChatID: "wrchat001", Attempts: tt.attempts}
+			if err := s.Add(ctx, e); err != nil {
+				t.Fatalf("add: %v", err)
+			}
+
+			var got Entry
+			process := func(_ context.Context, e Entry) error {
+				got = e
+				if tt.fail {
+					return errSend
+				}
+				return nil
+			}
+			NewRedeliverer(s, process, time.Minute, tt.maxAttempts, 10, slog.New(slog.DiscardHandler)).drain(ctx)
+
+			if got.ChatID != e.ChatID || got.UserID != e.UserID {
+				t.Fatalf("processed entry %+v, want the stored one %+v", got, *e)
+			}
+			stored, err := s.Get(ctx, e.ID)
+			if !tt.wantKept {
+				if !errors.Is(err, ErrNotFound) {
+					t.Fatalf("get: got %v, want ErrNotFound", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("get: %v", err)
+			}
+			if stored.Attempts != tt.attempts+1 || stored.LastError != errSend.Error() || stored.Dead != tt.wantDead {
+				t.Fatalf("stored entry: attempts %d, last error %q, dead %v", stored.Attempts, stored.LastError, stored.Dead)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go-wework-svc/internal/ai"
+)
+
+// ErrNotFound 记录不存在
+var ErrNotFound = errors.New("outbox entry not found")
+
+// Entry 转发 AI 失败的消息记录
+type Entry struct {
+	ID            uint64         `json:"id"`
+	MsgID         string         `json:"msg_id"`
+	Agent         string         `json:"agent,omitempty"`        // 消息所属应用或租户，默认应用为空
+	UserID        string         `json:"user_id"`                // AI 回复的接收者
+	ChatID        string         `json:"chat_id,omitempty"`      // 群聊来源，重投时回复仍回到该群
+	ChatType      string         `json:"chat_type,omitempty"`    // single | group
+	ResponseURL   string         `json:"response_url,omitempty"` // 智能机器人消息的主动回复地址
+	Request       ai.ChatRequest `json:"request"`
+	CreatedAt     time.Time      `json:"created_at"`
+	Attempts      int            `json:"attempts"`
+	LastError     string         `json:"last_error,omitempty"`
+	LastAttemptAt time.Time      `json:"last_attempt_at,omitzero"`
+	Dead          bool           `json:"dead"` // 超过最大重投次数，不再自动重投
+}
+
+// Store 失败消息的持久化存储
+type Store interface {
+	// Add 写入新记录并分配 ID
+	Add(ctx context.Context, e *Entry) error
+
+	// Get 按 ID 读取记录，不存在时返回 ErrNotFound
+	Get(ctx context.Context, id uint64) (*Entry, error)
+
+	// List 按 ID 升序返回 ID 大于 after 的最多 limit 条记录，limit <= 0 表示不限
+	List(ctx context.Context, after uint64, limit int) ([]Entry, error)
+
+	// Update 覆盖已有记录
+	Update(ctx context.Context, e Entry) error
+
+	// Delete 删除记录，不存在时返回 ErrNotFound
+	Delete(ctx context.Context, id uint64) error
+
+	// Len 返回记录总数
+	Len(ctx context.Context) (int, error)
+
+	// Close 释放底层资源
+	Close() error
+}
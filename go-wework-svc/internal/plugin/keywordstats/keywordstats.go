@@ -0,0 +1,82 @@
+// Package keywordstats 关键词命中统计插件，同时作为自定义处理器的参考实现
+package keywordstats
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"go-wework-svc/internal/plugin"
+	"go-wework-svc/internal/wework"
+)
+
+// Name 插件注册名称
+const Name = "keyword_stats"
+
+func init() {
+	plugin.Register(Name, New)
+}
+
+// Options 插件配置
+type Options struct {
+	Keywords []string `yaml:"keywords"`
+}
+
+// Processor 统计文本消息中各关键词的命中次数
+type Processor struct {
+	keywords []string
+	logger   *slog.Logger
+
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+// New 实现 plugin.Factory
+func New(decode func(v any) error, logger *slog.Logger) (wework.MessageProcessor, error) {
+	var opts Options
+	if err := decode(&opts); err != nil {
+		return nil, err
+	}
+	if len(opts.Keywords) == 0 {
+		return nil, errors.New("keywords is required")
+	}
+	return &Processor{
+		keywords: opts.Keywords,
+		logger:   logger,
+		counts:   make(map[string]int64, len(opts.Keywords)),
+	}, nil
+}
+
+// Name 实现 wework.MessageProcessor
+func (p *Processor) Name() string { return Name }
+
+// Process 实现 wework.MessageProcessor
+func (p *Processor) Process(_ context.Context, req *wework.Request) error {
+	if req.Message.MsgType != wework.MsgTypeText {
+		return nil
+	}
+	for _, kw := range p.keywords {
+		if !strings.Contains(req.Message.Content, kw) {
+			continue
+		}
+		p.mu.Lock()
+		p.counts[kw]++
+		n := p.counts[kw]
+		p.mu.Unlock()
+		p.logger.Info("keyword hit", "keyword", kw, "count", n)
+	}
+	return nil
+}
+
+// Counts 返回各关键词累计命中次数
+func (p *Processor) Counts() map[string]int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := make(map[string]int64, len(p.counts))
+	for k, v := range p.counts {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,79 @@
+// Package plugin 自定义消息处理器的编译期注册机制
+//
+// 插件包在 init 中调用 Register 注册工厂函数，在 internal/bootstrap/plugins.go 中空导入即可随服务一同编译，
+// 再通过配置 plugins 列表按名称启用。不使用 Go plugin 动态加载，以避免 cgo 和构建环境强一致的限制。
+package plugin
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+
+	"go-wework-svc/internal/shared"
+	"go-wework-svc/internal/wework"
+)
+
+// Factory 根据配置创建处理器，decode 将插件的 options 配置解码到目标结构体
+type Factory func(decode func(v any) error, logger *slog.Logger) (wework.MessageProcessor, error)
+
+var (
+	mu        sync.RWMutex
+	factories = make(map[string]Factory)
+)
+
+// Register 注册插件工厂，名称重复时 panic，应在插件包的 init 中调用
+func Register(name string, f Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, dup := factories[name]; dup {
+		panic("plugin: Register called twice for " + name)
+	}
+	factories[name] = f
+}
+
+// Names 返回已注册的插件名称
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return names()
+}
+
+func names() []string {
+	out := make([]string, 0, len(factories))
+	for name := range factories {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Build 按配置顺序创建已启用的处理器
+func Build(cfgs []shared.PluginConfig, logger *slog.Logger) ([]wework.MessageProcessor, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	ps := make([]wework.MessageProcessor, 0, len(cfgs))
+	for _, cfg := range cfgs {
+		f, ok := factories[cfg.Name]
+		if !ok {
+			return nil, fmt.Errorf("unknown plugin %q (registered: %v)", cfg.Name, names())
+		}
+		p, err := f(decoder(cfg), logger.With("plugin", cfg.Name))
+		if err != nil {
+			return nil, fmt.Errorf("init plugin %q: %w", cfg.Name, err)
+		}
+		ps = append(ps, p)
+	}
+	return ps, nil
+}
+
+// decoder 返回插件 options 的解码函数，未配置 options 时保持目标结构体零值
+func decoder(cfg shared.PluginConfig) func(v any) error {
+	return func(v any) error {
+		if cfg.Options.IsZero() {
+			return nil
+		}
+		return cfg.Options.Decode(v)
+	}
+}
@@ -0,0 +1,77 @@
+package publish
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go-wework-svc/internal/shared"
+)
+
+// defaultKafkaTimeout 请求 Kafka REST Proxy 的默认超时
+const defaultKafkaTimeout = 5 * time.Second
+
+// Kafka 通过 Kafka REST Proxy（v2 API，如 Confluent REST Proxy）发布消息的 Sink
+type Kafka struct {
+	cfg        shared.KafkaConfig
+	httpClient *http.Client
+}
+
+// kafkaRecords POST /topics/{topic} 请求体
+type kafkaRecords struct {
+	Records []kafkaRecord `json:"records"`
+}
+
+type kafkaRecord struct {
+	Key   string          `json:"key,omitempty"`
+	Value json.RawMessage `json:"value"`
+}
+
+// NewKafka 创建 Kafka REST Proxy 发布端
+func NewKafka(cfg shared.KafkaConfig) *Kafka {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultKafkaTimeout
+	}
+	return &Kafka{cfg: cfg, httpClient: &http.Client{Timeout: timeout}}
+}
+
+// Publish 实现 Sink 接口，key 为空时由 Kafka 轮询分区
+func (k *Kafka) Publish(ctx context.Context, topic, key string, payload []byte) error {
+	body, err := json.Marshal(kafkaRecords{Records: []kafkaRecord{{Key: key, Value: payload}}})
+	if err != nil {
+		return fmt.Errorf("marshal records: %w", err)
+	}
+	endpoint := strings.TrimSuffix(k.cfg.RESTURL, "/") + "/topics/" + url.PathEscape(topic)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+	if k.cfg.Username != "" {
+		req.SetBasicAuth(k.cfg.Username, k.cfg.Password)
+	}
+	resp, err := k.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("execute request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
+
+// Close 实现 Sink 接口
+func (k *Kafka) Close() error {
+	k.httpClient.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,223 @@
+package publish
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"go-wework-svc/internal/shared"
+)
+
+// defaultNATSTimeout 连接和写入 NATS 的默认超时
+const defaultNATSTimeout = 5 * time.Second
+
+// NATS 基于 NATS core 协议（PUB）的 Sink，连接断开后在下一次发布时重连
+// 只使用发布功能，不依赖 JetStream；需要持久化时在服务端为主题配置 stream
+type NATS struct {
+	cfg     shared.NATSConfig
+	addr    string
+	tls     bool
+	timeout time.Duration
+	logger  *slog.Logger
+
+	mu   sync.Mutex
+	conn net.Conn
+	w    *bufio.Writer
+}
+
+// natsInfo 服务端 INFO 中用到的字段
+type natsInfo struct {
+	TLSRequired bool `json:"tls_required"`
+}
+
+// natsConnect CONNECT 命令的参数
+type natsConnect struct {
+	Verbose  bool   `json:"verbose"`
+	Pedantic bool   `json:"pedantic"`
+	Name     string `json:"name"`
+	Lang     string `json:"lang"`
+	Version  string `json:"version"`
+	User     string `json:"user,omitempty"`
+	Pass     string `json:"pass,omitempty"`
+	Token    string `json:"auth_token,omitempty"`
+}
+
+// NewNATS 创建 NATS 发布端，url 形如 nats://host:4222 或 tls://host:4222，首次发布时建立连接
+func NewNATS(cfg shared.NATSConfig, logger *slog.Logger) (*NATS, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("parse nats url: %w", err)
+	}
+	if u.Scheme != "nats" && u.Scheme != "tls" {
+		return nil, fmt.Errorf("unsupported nats url scheme %q", u.Scheme)
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "4222")
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultNATSTimeout
+	}
+	return &NATS{cfg: cfg, addr: addr, tls: u.Scheme == "tls", timeout: timeout, logger: logger}, nil
+}
+
+// Publish 实现 Sink 接口，写入失败时关闭连接并重连重试一次
+func (n *NATS) Publish(ctx context.Context, topic, _ string, payload []byte) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	var err error
+	for range 2 {
+		if n.conn == nil {
+			if err := n.connect(ctx); err != nil {
+				return fmt.Errorf("connect nats: %w", err)
+			}
+		}
+		err = n.writeLocked(ctx, func(w *bufio.Writer) {
+			fmt.Fprintf(w, "PUB %s %d\r\n", topic, len(payload))
+			w.Write(payload)
+			w.WriteString("\r\n")
+		})
+		if err == nil || ctx.Err() != nil {
+			break
+		}
+		n.logger.Warn("nats publish failed, reconnecting", "error", err)
+	}
+	if err != nil {
+		return fmt.Errorf("publish to nats: %w", err)
+	}
+	return nil
+}
+
+// writeLocked 在本次写入的截止时间内写入并刷新，失败时关闭连接，调用方需持有锁
+// bufio.Writer 出错后会一直返回同一错误，关闭连接后由下一次写入重建
+func (n *NATS) writeLocked(ctx context.Context, write func(w *bufio.Writer)) error {
+	deadline := time.Now().Add(n.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = n.conn.SetWriteDeadline(deadline)
+	write(n.w)
+	if err := n.w.Flush(); err != nil {
+		n.closeLocked()
+		return err
+	}
+	return nil
+}
+
+// connect 建立连接并完成 INFO / CONNECT 握手，调用方需持有锁
+func (n *NATS) connect(ctx context.Context) error {
+	dialer := net.Dialer{Timeout: n.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", n.addr)
+	if err != nil {
+		return err
+	}
+	_ = conn.SetDeadline(time.Now().Add(n.timeout))
+	r := bufio.NewReader(conn)
+	line, err := r.ReadString('\n')
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("read info: %w", err)
+	}
+	infoJSON, ok := strings.CutPrefix(strings.TrimSpace(line), "INFO ")
+	if !ok {
+		conn.Close()
+		return fmt.Errorf("unexpected greeting %q", line)
+	}
+	var info natsInfo
+	if err := json.Unmarshal([]byte(infoJSON), &info); err != nil {
+		conn.Close()
+		return fmt.Errorf("decode info: %w", err)
+	}
+	if n.tls || info.TLSRequired {
+		host, _, _ := net.SplitHostPort(n.addr)
+		tc := tls.Client(conn, &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12})
+		if err := tc.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return fmt.Errorf("tls handshake: %w", err)
+		}
+		conn, r = tc, bufio.NewReader(tc)
+	}
+
+	opts, err := json.Marshal(natsConnect{Name: "go-wework-svc", Lang: "go", Version: "1", User: n.cfg.User, Pass: n.cfg.Password, Token: n.cfg.Token})
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("marshal connect: %w", err)
+	}
+	// PING 的响应确认 CONNECT 已被接受，认证失败时服务端返回 -ERR
+	if _, err := fmt.Fprintf(conn, "CONNECT %s\r\nPING\r\n", opts); err != nil {
+		conn.Close()
+		return fmt.Errorf("write connect: %w", err)
+	}
+	line, err = r.ReadString('\n')
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("read connect response: %w", err)
+	}
+	if line = strings.TrimSpace(line); line != "PONG" {
+		conn.Close()
+		return fmt.Errorf("connect rejected: %s", line)
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	n.conn, n.w = conn, bufio.NewWriter(conn)
+	go n.read(conn, r)
+	n.logger.Info("nats connected", "addr", n.addr)
+	return nil
+}
+
+// read 处理服务端的 PING 和错误通知，连接断开后退出
+func (n *NATS) read(conn net.Conn, r *bufio.Reader) {
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			n.mu.Lock()
+			if n.conn == conn {
+				if !errors.Is(err, net.ErrClosed) {
+					n.logger.Warn("nats connection lost", "error", err)
+				}
+				n.closeLocked()
+			}
+			n.mu.Unlock()
+			return
+		}
+		switch line = strings.TrimSpace(line); {
+		case line == "PING":
+			n.mu.Lock()
+			if n.conn == conn {
+				err := n.writeLocked(context.Background(), func(w *bufio.Writer) { w.WriteString("PONG\r\n") })
+				if err != nil {
+					n.logger.Warn("failed to answer nats ping", "error", err)
+				}
+			}
+			n.mu.Unlock()
+		case strings.HasPrefix(line, "-ERR"):
+			n.logger.Warn("nats server error", "error", line)
+		}
+	}
+}
+
+// closeLocked 关闭当前连接，调用方需持有锁
+func (n *NATS) closeLocked() {
+	if n.conn != nil {
+		n.conn.Close()
+		n.conn, n.w = nil, nil
+	}
+}
+
+// Close 实现 Sink 接口
+func (n *NATS) Close() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.closeLocked()
+	return nil
+}
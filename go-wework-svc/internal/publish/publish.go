@@ -0,0 +1,169 @@
+// Package publish 将解密后的入站消息和发出的回复以 JSON 发布到 NATS 或 Kafka，供数据分析等下游消费
+package publish
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"go-wework-svc/internal/wework"
+)
+
+// 消息方向常量
+const (
+	DirectionInbound  = "inbound"
+	DirectionOutbound = "outbound"
+)
+
+const (
+	defaultQueueSize = 1000
+	// publishTimeout 单条消息的发布超时
+	publishTimeout = 5 * time.Second
+)
+
+// Sink 消息队列的发布端
+type Sink interface {
+	// Publish 将 payload 发布到 topic，key 用于 Kafka 分区，NATS 忽略
+	Publish(ctx context.Context, topic, key string, payload []byte) error
+	Close() error
+}
+
+// Event 发布的消息
+type Event struct {
+	Direction string `json:"direction"`
+	Agent     string `json:"agent"` // 应用或租户名称，默认应用为空
+	MsgID     string `json:"msg_id,omitempty"`
+	UserID    string `json:"user_id"`
+	ChatID    string `json:"chat_id,omitempty"`
+	MsgType   string `json:"msg_type"`
+	Content   string `json:"content,omitempty"`
+
+	// 非文本入站消息的素材和链接字段
+	MediaID string `json:"media_id,omitempty"`
+	PicURL  string `json:"pic_url,omitempty"`
+	Title   string `json:"title,omitempty"`
+	URL     string `json:"url,omitempty"`
+
+	// CreateTime 入站消息为企业微信的消息时间，回复为发出时间
+	CreateTime time.Time `json:"create_time"`
+}
+
+// Publisher 消息发布器，实现 wework.Archive
+// 消息先写入内存队列，由 Run 异步发布；队列满或发布失败时丢弃并记录日志，不阻塞回调处理
+type Publisher struct {
+	sink          Sink
+	inboundTopic  string
+	outboundTopic string
+	queue         chan message
+	logger        *slog.Logger
+}
+
+// message 队列中待发布的消息
+type message struct {
+	topic string
+	event Event
+}
+
+// New 创建发布器，queueSize 不大于 0 时使用默认值
+func New(sink Sink, inboundTopic, outboundTopic string, queueSize int, logger *slog.Logger) *Publisher {
+	if queueSize <= 0 {
+		queueSize = defaultQueueSize
+	}
+	return &Publisher{
+		sink:          sink,
+		inboundTopic:  inboundTopic,
+		outboundTopic: outboundTopic,
+		queue:         make(chan message, queueSize),
+		logger:        logger,
+	}
+}
+
+// Inbound 实现 wework.Archive
+func (p *Publisher) Inbound(_ context.Context, agent string, msg wework.Message) {
+	p.enqueue(p.inboundTopic, Event{
+		Direction:  DirectionInbound,
+		Agent:      agent,
+		MsgID:      msg.MsgID,
+		UserID:     msg.FromUserName,
+		ChatID:     msg.ChatID,
+		MsgType:    msg.MsgType,
+		Content:    msg.Content,
+		MediaID:    msg.MediaID,
+		PicURL:     msg.PicURL,
+		Title:      msg.Title,
+		URL:        msg.URL,
+		CreateTime: time.Unix(msg.CreateTime, 0),
+	})
+}
+
+// Outbound 实现 wework.Archive，MsgID 为触发回复的入站消息
+func (p *Publisher) Outbound(_ context.Context, agent string, msg wework.Message, reply string) {
+	p.enqueue(p.outboundTopic, Event{
+		Direction:  DirectionOutbound,
+		Agent:      agent,
+		MsgID:      msg.MsgID,
+		UserID:     msg.FromUserName,
+		ChatID:     msg.ChatID,
+		MsgType:    wework.MsgTypeText,
+		Content:    reply,
+		CreateTime: time.Now(),
+	})
+}
+
+func (p *Publisher) enqueue(topic string, ev Event) {
+	select {
+	case p.queue <- message{topic: topic, event: ev}:
+	default:
+		p.logger.Warn("publish queue full, dropping message", "direction", ev.Direction, "msg_id", ev.MsgID)
+	}
+}
+
+// Len 返回等待发布的消息数
+func (p *Publisher) Len() int {
+	return len(p.queue)
+}
+
+// Run 发布队列中的消息，ctx 取消后发布完剩余消息再返回
+func (p *Publisher) Run(ctx context.Context) {
+	for {
+		select {
+		case m := <-p.queue:
+			p.publish(ctx, m)
+		case <-ctx.Done():
+			p.drain()
+			return
+		}
+	}
+}
+
+// drain 发布队列中剩余的消息
+func (p *Publisher) drain() {
+	ctx := context.Background()
+	for {
+		select {
+		case m := <-p.queue:
+			p.publish(ctx, m)
+		default:
+			return
+		}
+	}
+}
+
+func (p *Publisher) publish(ctx context.Context, m message) {
+	payload, err := json.Marshal(m.event)
+	if err != nil {
+		p.logger.Error("failed to marshal published message", "msg_id", m.event.MsgID, "error", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), publishTimeout)
+	defer cancel()
+	if err := p.sink.Publish(ctx, m.topic, m.event.UserID, payload); err != nil {
+		p.logger.Error("failed to publish message", "topic", m.topic, "direction", m.event.Direction, "msg_id", m.event.MsgID, "error", err)
+	}
+}
+
+// Close 关闭发布端连接，应在 Run 返回后调用
+func (p *Publisher) Close() error {
+	return p.sink.Close()
+}
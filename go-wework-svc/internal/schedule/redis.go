@@ -0,0 +1,95 @@
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisTimeout 单次 Redis 读写的超时，Store 接口不带 ctx
+const redisTimeout = 5 * time.Second
+
+// RedisStore 基于 Redis 哈希的定时消息存储，多副本共享，管理 API 在任一副本上的修改都由主实例触发
+type RedisStore struct {
+	client *redis.Client
+	key    string
+}
+
+// NewRedisStore 创建 Redis 存储，prefix 与 store.redis.key_prefix 保持一致
+func NewRedisStore(client *redis.Client, prefix string) *RedisStore {
+	return &RedisStore{client: client, key: prefix + "schedules"}
+}
+
+// Get 按名称读取，不存在时返回 ErrNotFound
+func (s *RedisStore) Get(name string) (*Schedule, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	v, err := s.client.HGet(ctx, s.key, name).Result()
+	if err == redis.Nil {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("redis hget: %w", err)
+	}
+	var sc Schedule
+	if err := json.Unmarshal([]byte(v), &sc); err != nil {
+		return nil, fmt.Errorf("unmarshal schedule %s: %w", name, err)
+	}
+	return &sc, nil
+}
+
+// List 按名称升序返回全部定时消息
+func (s *RedisStore) List() ([]Schedule, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	all, err := s.client.HGetAll(ctx, s.key).Result()
+	if err != nil {
+		return nil, fmt.Errorf("redis hgetall: %w", err)
+	}
+	list := make([]Schedule, 0, len(all))
+	for name, v := range all {
+		var sc Schedule
+		if err := json.Unmarshal([]byte(v), &sc); err != nil {
+			return nil, fmt.Errorf("unmarshal schedule %s: %w", name, err)
+		}
+		list = append(list, sc)
+	}
+	slices.SortFunc(list, func(a, b Schedule) int { return strings.Compare(a.Name, b.Name) })
+	return list, nil
+}
+
+// Put 写入或覆盖
+func (s *RedisStore) Put(sc Schedule) error {
+	v, err := json.Marshal(sc)
+	if err != nil {
+		return fmt.Errorf("marshal schedule: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	if err := s.client.HSet(ctx, s.key, sc.Name, v).Err(); err != nil {
+		return fmt.Errorf("redis hset: %w", err)
+	}
+	return nil
+}
+
+// Delete 删除，不存在时返回 ErrNotFound
+func (s *RedisStore) Delete(name string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	n, err := s.client.HDel(ctx, s.key, name).Result()
+	if err != nil {
+		return fmt.Errorf("redis hdel: %w", err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Close 连接由共享存储管理，这里不关闭
+func (s *RedisStore) Close() error { return nil }
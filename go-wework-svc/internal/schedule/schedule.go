@@ -0,0 +1,383 @@
+// Package schedule 定时消息：按 cron 表达式通过应用消息或群机器人发送站会提醒、周报提醒等，定时消息及其运行状态持久化在 BoltDB 或 Redis 中
+package schedule
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go-wework-svc/internal/shared"
+	"go-wework-svc/internal/store"
+	"go-wework-svc/internal/wework"
+)
+
+const (
+	defaultMisfireGrace = 5 * time.Minute
+	// dedupTTL 多副本按触发时间去重的记录保留时长
+	dedupTTL = 24 * time.Hour
+)
+
+// 定时消息来源
+const (
+	SourceConfig = "config" // schedule.jobs，只能通过配置修改
+	SourceAPI    = "api"    // /admin/schedules 创建
+)
+
+var (
+	// ErrNotFound 定时消息不存在
+	ErrNotFound = errors.New("schedule not found")
+	// ErrReadOnly 配置中的定时消息不能通过管理 API 修改或删除
+	ErrReadOnly = errors.New("schedule is defined in config")
+	// ErrInvalid 定时消息校验失败
+	ErrInvalid = errors.New("invalid schedule")
+)
+
+// Schedule 一条定时消息及其运行状态
+type Schedule struct {
+	Name     string   `json:"name"`
+	Cron     string   `json:"cron"`
+	Timezone string   `json:"timezone,omitempty"` // IANA 时区，默认本地时区
+	Agent    string   `json:"agent,omitempty"`    // 发送应用，默认应用为空
+	ToUser   []string `json:"touser,omitempty"`
+	ToParty  []string `json:"toparty,omitempty"`
+	ToTag    []string `json:"totag,omitempty"`
+	Webhooks []string `json:"webhooks,omitempty"` // 群机器人名称
+	MsgType  string   `json:"msgtype"`            // text | markdown
+	Content  string   `json:"content"`
+	Paused   bool     `json:"paused,omitempty"`
+
+	Source    string    `json:"source"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	NextRunAt time.Time `json:"next_run_at,omitzero"`
+	LastRunAt time.Time `json:"last_run_at,omitzero"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// sameSpec 发送内容和触发规则是否相同，运行状态不参与比较
+func (s Schedule) sameSpec(o Schedule) bool {
+	return s.Cron == o.Cron && s.Timezone == o.Timezone && s.Agent == o.Agent &&
+		slices.Equal(s.ToUser, o.ToUser) && slices.Equal(s.ToParty, o.ToParty) && slices.Equal(s.ToTag, o.ToTag) &&
+		slices.Equal(s.Webhooks, o.Webhooks) && s.MsgType == o.MsgType && s.Content == o.Content
+}
+
+// direct 是否有应用消息接收者
+func (s Schedule) direct() bool {
+	return len(s.ToUser) > 0 || len(s.ToParty) > 0 || len(s.ToTag) > 0
+}
+
+// Scheduler 每分钟检查到期的定时消息并发送
+type Scheduler struct {
+	store Store
+	// senders 按应用名称索引的主动消息发送器，空字符串为默认应用
+	senders  map[string]wework.Sender
+	webhooks map[string]wework.Webhook
+	// kv 多副本部署时为共享存储，按触发时间去重，避免重复发送
+	kv     store.Store
+	grace  time.Duration
+	logger *slog.Logger
+
+	// mu 串行化定时消息的读改写，避免管理 API 的修改被运行状态覆盖
+	mu sync.Mutex
+}
+
+// New 创建调度器，grace <= 0 时错过 5 分钟内的触发仍补发
+func New(st Store, senders map[string]wework.Sender, webhooks map[string]wework.Webhook, kv store.Store, grace time.Duration, logger *slog.Logger) *Scheduler {
+	return &Scheduler{
+		store:    st,
+		senders:  senders,
+		webhooks: webhooks,
+		kv:       kv,
+		grace:    cmp.Or(grace, defaultMisfireGrace),
+		logger:   logger,
+	}
+}
+
+// Sync 以配置中的 jobs 覆盖来源为 config 的定时消息，删除配置中已移除的，保留未变化定时消息的运行状态
+func (s *Scheduler) Sync(jobs []shared.ScheduleJobConfig) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, err := s.store.List()
+	if err != nil {
+		return fmt.Errorf("list schedules: %w", err)
+	}
+	byName := make(map[string]Schedule, len(existing))
+	for _, sc := range existing {
+		byName[sc.Name] = sc
+	}
+	now := time.Now()
+	keep := make(map[string]bool, len(jobs))
+	for _, j := range jobs {
+		sc := Schedule{
+			Name:     j.Name,
+			Cron:     j.Cron,
+			Timezone: j.Timezone,
+			Agent:    j.Agent,
+			ToUser:   j.ToUser,
+			ToParty:  j.ToParty,
+			ToTag:    j.ToTag,
+			Webhooks: j.Webhooks,
+			MsgType:  cmp.Or(j.MsgType, wework.MsgTypeText),
+			Content:  j.Content,
+			Source:   SourceConfig,
+		}
+		if err := s.validate(sc); err != nil {
+			return fmt.Errorf("job %s: %w", j.Name, err)
+		}
+		keep[j.Name] = true
+		if old, ok := byName[j.Name]; ok {
+			if old.Source != SourceConfig {
+				return fmt.Errorf("job %s: name is used by a schedule created through the admin api", j.Name)
+			}
+			if old.sameSpec(sc) {
+				continue
+			}
+			sc.CreatedAt, sc.LastRunAt, sc.LastError = old.CreatedAt, old.LastRunAt, old.LastError
+		} else {
+			sc.CreatedAt = now
+		}
+		sc.UpdatedAt = now
+		sc.NextRunAt = s.next(sc, now)
+		if err := s.store.Put(sc); err != nil {
+			return fmt.Errorf("save schedule %s: %w", sc.Name, err)
+		}
+	}
+	for _, sc := range existing {
+		if sc.Source == SourceConfig && !keep[sc.Name] {
+			if err := s.store.Delete(sc.Name); err != nil {
+				return fmt.Errorf("delete schedule %s: %w", sc.Name, err)
+			}
+			s.logger.Info("schedule removed from config", "schedule", sc.Name)
+		}
+	}
+	return nil
+}
+
+// List 按名称升序返回全部定时消息
+func (s *Scheduler) List() ([]Schedule, error) {
+	return s.store.List()
+}
+
+// Get 按名称读取定时消息
+func (s *Scheduler) Get(name string) (*Schedule, error) {
+	return s.store.Get(name)
+}
+
+// Put 创建或更新来源为 api 的定时消息，修改触发规则后重新计算下次触发时间
+func (s *Scheduler) Put(sc Schedule) (*Schedule, error) {
+	sc.Source = SourceAPI
+	sc.MsgType = cmp.Or(sc.MsgType, wework.MsgTypeText)
+	if err := s.validate(sc); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	old, err := s.store.Get(sc.Name)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		sc.CreatedAt = now
+	case err != nil:
+		return nil, err
+	case old.Source == SourceConfig:
+		return nil, ErrReadOnly
+	default:
+		sc.CreatedAt, sc.LastRunAt, sc.LastError = old.CreatedAt, old.LastRunAt, old.LastError
+	}
+	sc.UpdatedAt = now
+	sc.NextRunAt = s.next(sc, now)
+	if err := s.store.Put(sc); err != nil {
+		return nil, err
+	}
+	return &sc, nil
+}
+
+// Delete 删除来源为 api 的定时消息
+func (s *Scheduler) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sc, err := s.store.Get(name)
+	if err != nil {
+		return err
+	}
+	if sc.Source == SourceConfig {
+		return ErrReadOnly
+	}
+	return s.store.Delete(name)
+}
+
+// Trigger 立即发送一次，不影响下次触发时间
+func (s *Scheduler) Trigger(ctx context.Context, name string) (*Schedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sc, err := s.store.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	s.record(ctx, sc, s.send(ctx, *sc))
+	if err := s.store.Put(*sc); err != nil {
+		return nil, err
+	}
+	return sc, nil
+}
+
+// Run 每分钟整点检查一次到期的定时消息，直到 ctx 取消
+func (s *Scheduler) Run(ctx context.Context) {
+	for {
+		now := time.Now()
+		wait := now.Truncate(time.Minute).Add(time.Minute).Sub(now)
+		select {
+		case <-time.After(wait):
+			s.tick(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// tick 发送到期的定时消息；错过触发时间超过 grace 时跳过本次，只推进下次触发时间
+func (s *Scheduler) tick(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list, err := s.store.List()
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to list schedules", "error", err)
+		return
+	}
+	now := time.Now()
+	for _, sc := range list {
+		if sc.Paused || sc.NextRunAt.IsZero() || sc.NextRunAt.After(now) {
+			continue
+		}
+		switch late := now.Sub(sc.NextRunAt); {
+		case late > s.grace:
+			s.logger.WarnContext(ctx, "schedule missed, skipping", "schedule", sc.Name, "due", sc.NextRunAt, "late", late.Round(time.Second))
+		case s.claim(ctx, sc):
+			s.record(ctx, &sc, s.send(ctx, sc))
+		}
+		sc.NextRunAt = s.next(sc, now)
+		if err := s.store.Put(sc); err != nil {
+			s.logger.ErrorContext(ctx, "failed to save schedule", "schedule", sc.Name, "error", err)
+		}
+	}
+}
+
+// claim 按触发时间在共享存储中占位，其他副本已发送时返回 false；存储异常时仍发送
+func (s *Scheduler) claim(ctx context.Context, sc Schedule) bool {
+	if s.kv == nil {
+		return true
+	}
+	key := "schedule:" + sc.Name + ":" + strconv.FormatInt(sc.NextRunAt.Unix(), 10)
+	ok, err := s.kv.SetNX(ctx, key, "1", dedupTTL)
+	if err != nil {
+		s.logger.WarnContext(ctx, "schedule dedup failed", "schedule", sc.Name, "error", err)
+		return true
+	}
+	return ok
+}
+
+// record 记录一次发送的结果
+func (s *Scheduler) record(ctx context.Context, sc *Schedule, err error) {
+	sc.LastRunAt = time.Now()
+	sc.LastError = ""
+	if err != nil {
+		sc.LastError = err.Error()
+		s.logger.ErrorContext(ctx, "failed to send scheduled message", "schedule", sc.Name, "error", err)
+		return
+	}
+	s.logger.InfoContext(ctx, "scheduled message sent", "schedule", sc.Name)
+}
+
+// send 向应用消息接收者和各群机器人发送，部分失败时继续发送其余目标
+func (s *Scheduler) send(ctx context.Context, sc Schedule) error {
+	var errs []error
+	if sc.direct() {
+		sender, ok := s.senders[sc.Agent]
+		if !ok {
+			return fmt.Errorf("agent %q has no sender configured", sc.Agent)
+		}
+		err := sender.Send(ctx, wework.OutgoingMessage{
+			ToUser:  strings.Join(sc.ToUser, "|"),
+			ToParty: strings.Join(sc.ToParty, "|"),
+			ToTag:   strings.Join(sc.ToTag, "|"),
+			MsgType: sc.MsgType,
+			Content: sc.Content,
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("send message: %w", err))
+		}
+	}
+	for _, name := range sc.Webhooks {
+		wh, ok := s.webhooks[name]
+		if !ok {
+			errs = append(errs, fmt.Errorf("webhook %q is not defined", name))
+			continue
+		}
+		if err := wh.Post(ctx, wework.WebhookMessage{MsgType: sc.MsgType, Content: sc.Content}); err != nil {
+			errs = append(errs, fmt.Errorf("post webhook %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// next 返回 after 之后的下次触发时间
+func (s *Scheduler) next(sc Schedule, after time.Time) time.Time {
+	// 已在 validate 中校验
+	c, _ := ParseCron(sc.Cron)
+	loc, _ := location(sc.Timezone)
+	return c.Next(after.In(loc))
+}
+
+// validate 校验触发规则、接收者和发送应用
+func (s *Scheduler) validate(sc Schedule) error {
+	if sc.Name == "" || strings.ContainsAny(sc.Name, "/ ") {
+		return fmt.Errorf("%w: name must be non-empty without spaces or slashes", ErrInvalid)
+	}
+	c, err := ParseCron(sc.Cron)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalid, err)
+	}
+	loc, err := location(sc.Timezone)
+	if err != nil {
+		return fmt.Errorf("%w: timezone: %w", ErrInvalid, err)
+	}
+	if c.Next(time.Now().In(loc)).IsZero() {
+		return fmt.Errorf("%w: cron %q never fires", ErrInvalid, sc.Cron)
+	}
+	switch sc.MsgType {
+	case wework.MsgTypeText, wework.MsgTypeMarkdown:
+	default:
+		return fmt.Errorf("%w: msgtype must be text or markdown, got %q", ErrInvalid, sc.MsgType)
+	}
+	if sc.Content == "" {
+		return fmt.Errorf("%w: content is required", ErrInvalid)
+	}
+	direct := sc.direct()
+	if !direct && len(sc.Webhooks) == 0 {
+		return fmt.Errorf("%w: at least one of touser, toparty, totag, webhooks is required", ErrInvalid)
+	}
+	if _, ok := s.senders[sc.Agent]; direct && !ok {
+		return fmt.Errorf("%w: agent %q has no sender configured", ErrInvalid, sc.Agent)
+	}
+	for _, name := range sc.Webhooks {
+		if _, ok := s.webhooks[name]; !ok {
+			return fmt.Errorf("%w: webhook %q is not defined in webhooks", ErrInvalid, name)
+		}
+	}
+	return nil
+}
+
+// location 解析时区，为空时使用本地时区
+func location(name string) (*time.Location, error) {
+	if name == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(name)
+}
@@ -0,0 +1,106 @@
+package schedule
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var bucketName = []byte("schedules")
+
+// Store 定时消息存储，List 按名称升序返回
+type Store interface {
+	Get(name string) (*Schedule, error)
+	List() ([]Schedule, error)
+	Put(sc Schedule) error
+	Delete(name string) error
+	Close() error
+}
+
+// BoltStore 基于 BoltDB 的定时消息存储，单文件、单进程，按名称升序遍历
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// NewBoltStore 打开（或创建）BoltDB 文件
+func NewBoltStore(path string) (*BoltStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create schedule dir: %w", err)
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open schedule db: %w", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucketName)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create schedule bucket: %w", err)
+	}
+	return &BoltStore{db: db}, nil
+}
+
+// Get 按名称读取，不存在时返回 ErrNotFound
+func (s *BoltStore) Get(name string) (*Schedule, error) {
+	var sc Schedule
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(bucketName).Get([]byte(name))
+		if v == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(v, &sc)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &sc, nil
+}
+
+// List 按名称升序返回全部定时消息
+func (s *BoltStore) List() ([]Schedule, error) {
+	var list []Schedule
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketName).ForEach(func(k, v []byte) error {
+			var sc Schedule
+			if err := json.Unmarshal(v, &sc); err != nil {
+				return fmt.Errorf("unmarshal schedule %s: %w", k, err)
+			}
+			list = append(list, sc)
+			return nil
+		})
+	})
+	return list, err
+}
+
+// Put 写入或覆盖
+func (s *BoltStore) Put(sc Schedule) error {
+	v, err := json.Marshal(sc)
+	if err != nil {
+		return fmt.Errorf("marshal schedule: %w", err)
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketName).Put([]byte(sc.Name), v)
+	})
+}
+
+// Delete 删除，不存在时返回 ErrNotFound
+func (s *BoltStore) Delete(name string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketName)
+		if b.Get([]byte(name)) == nil {
+			return ErrNotFound
+		}
+		return b.Delete([]byte(name))
+	})
+}
+
+// Close 关闭数据库文件
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
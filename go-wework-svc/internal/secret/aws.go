@@ -0,0 +1,67 @@
+package secret
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+
+	"go-wework-svc/internal/shared"
+)
+
+const defaultAWSTimeout = 10 * time.Second
+
+// AWS 基于 AWS Secrets Manager 的密钥后端，路径为 secret id 或 ARN
+// JSON 对象密钥可用 #<key> 取单个字段，否则返回整个 SecretString
+type AWS struct {
+	region  string
+	timeout time.Duration
+
+	once   sync.Once
+	client *secretsmanager.Client
+	err    error
+}
+
+// NewAWS 创建 Secrets Manager 后端，首次读取时才加载 SDK 配置和凭证
+func NewAWS(cfg shared.AWSConfig) *AWS {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultAWSTimeout
+	}
+	return &AWS{region: cfg.Region, timeout: timeout}
+}
+
+// Fetch 实现 Provider 接口
+func (a *AWS) Fetch(ctx context.Context, path string) (map[string]string, error) {
+	// 凭证链中的实例元数据服务在非云环境下可能长时间无响应
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+	a.once.Do(func() {
+		var opts []func(*awsconfig.LoadOptions) error
+		if a.region != "" {
+			opts = append(opts, awsconfig.WithRegion(a.region))
+		}
+		cfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+		if err != nil {
+			a.err = fmt.Errorf("load aws config: %w", err)
+			return
+		}
+		a.client = secretsmanager.NewFromConfig(cfg)
+	})
+	if a.err != nil {
+		return nil, a.err
+	}
+
+	out, err := a.client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{SecretId: aws.String(path)})
+	if err != nil {
+		return nil, fmt.Errorf("get secret value: %w", err)
+	}
+	if out.SecretString == nil {
+		return nil, fmt.Errorf("secret %s has no string value", path)
+	}
+	return jsonFields(*out.SecretString), nil
+}
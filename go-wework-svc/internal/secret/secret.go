@@ -0,0 +1,211 @@
+// Package secret 解析配置中的 secret:// 引用，从 HashiCorp Vault 或 AWS Secrets Manager 读取密钥
+package secret
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go-wework-svc/internal/shared"
+)
+
+// Scheme 密钥引用前缀
+const Scheme = "secret://"
+
+// 密钥后端名称
+const (
+	BackendVault = "vault"
+	BackendAWS   = "aws"
+)
+
+// Ref 解析后的密钥引用：secret://<backend>/<path>[#<field>]
+type Ref struct {
+	Backend string
+	Path    string
+	Field   string // 为空时使用整个密钥值（AWS）或唯一字段（Vault）
+}
+
+// ParseRef 解析密钥引用，s 不以 secret:// 开头时返回 false
+func ParseRef(s string) (Ref, bool, error) {
+	rest, ok := strings.CutPrefix(s, Scheme)
+	if !ok {
+		return Ref{}, false, nil
+	}
+	rest, field, _ := strings.Cut(rest, "#")
+	backend, path, _ := strings.Cut(rest, "/")
+	if backend == "" || path == "" {
+		return Ref{}, true, fmt.Errorf("invalid secret reference %q, want secret://<backend>/<path>[#<field>]", s)
+	}
+	return Ref{Backend: backend, Path: path, Field: field}, true, nil
+}
+
+func (r Ref) String() string {
+	s := Scheme + r.Backend + "/" + r.Path
+	if r.Field != "" {
+		s += "#" + r.Field
+	}
+	return s
+}
+
+// Provider 密钥后端
+type Provider interface {
+	// Fetch 读取路径下的全部字段；纯文本密钥以空字符串为键
+	Fetch(ctx context.Context, path string) (map[string]string, error)
+}
+
+// Resolver 将结构体中所有 secret:// 字符串替换为密钥值
+type Resolver struct {
+	providers map[string]Provider
+}
+
+// NewResolver 创建解析器，providers 按后端名称索引
+func NewResolver(providers map[string]Provider) *Resolver {
+	return &Resolver{providers: providers}
+}
+
+// ResolveConfig 实现 shared.ResolveFunc：按 cfg.Secrets 创建后端并解析配置中的引用
+// 配置中没有引用时不连接任何后端
+func ResolveConfig(ctx context.Context, cfg *shared.Config) error {
+	if !hasRefs(reflect.ValueOf(cfg).Elem()) {
+		return nil
+	}
+	providers := map[string]Provider{}
+	if cfg.Secrets.Vault.Addr != "" {
+		providers[BackendVault] = NewVault(cfg.Secrets.Vault)
+	}
+	providers[BackendAWS] = NewAWS(cfg.Secrets.AWS)
+	return NewResolver(providers).Resolve(ctx, cfg)
+}
+
+// Resolve 遍历 v（结构体指针）中的字符串字段，包括切片、map 和指针内的字段，原地替换引用
+// 同一路径只读取一次
+func (r *Resolver) Resolve(ctx context.Context, v any) error {
+	cache := make(map[Ref]map[string]string)
+	return r.walk(ctx, reflect.ValueOf(v), cache)
+}
+
+func (r *Resolver) walk(ctx context.Context, v reflect.Value, cache map[Ref]map[string]string) error {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !v.IsNil() {
+			return r.walk(ctx, v.Elem(), cache)
+		}
+	case reflect.Struct:
+		for i := range v.NumField() {
+			if v.Type().Field(i).IsExported() {
+				if err := r.walk(ctx, v.Field(i), cache); err != nil {
+					return err
+				}
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := range v.Len() {
+			if err := r.walk(ctx, v.Index(i), cache); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		// map 的值不可寻址，复制后替换
+		iter := v.MapRange()
+		for iter.Next() {
+			elem := reflect.New(iter.Value().Type()).Elem()
+			elem.Set(iter.Value())
+			if err := r.walk(ctx, elem, cache); err != nil {
+				return err
+			}
+			v.SetMapIndex(iter.Key(), elem)
+		}
+	case reflect.String:
+		if !v.CanSet() {
+			return nil
+		}
+		value, err := r.lookup(ctx, v.String(), cache)
+		if err != nil {
+			return err
+		}
+		v.SetString(value)
+	}
+	return nil
+}
+
+// lookup 解析单个字符串，非引用原样返回
+func (r *Resolver) lookup(ctx context.Context, s string, cache map[Ref]map[string]string) (string, error) {
+	ref, ok, err := ParseRef(s)
+	if !ok || err != nil {
+		return s, err
+	}
+	p, ok := r.providers[ref.Backend]
+	if !ok {
+		return "", fmt.Errorf("%s: secret backend %q is not configured", ref, ref.Backend)
+	}
+
+	key := Ref{Backend: ref.Backend, Path: ref.Path}
+	fields, ok := cache[key]
+	if !ok {
+		fields, err = p.Fetch(ctx, ref.Path)
+		if err != nil {
+			return "", fmt.Errorf("fetch %s: %w", ref, err)
+		}
+		cache[key] = fields
+	}
+
+	if value, ok := fields[ref.Field]; ok {
+		return value, nil
+	}
+	if ref.Field == "" && len(fields) == 1 {
+		for _, value := range fields {
+			return value, nil
+		}
+	}
+	return "", fmt.Errorf("%s: field not found", ref)
+}
+
+// hasRefs 判断结构体中是否存在密钥引用
+func hasRefs(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return !v.IsNil() && hasRefs(v.Elem())
+	case reflect.Struct:
+		for i := range v.NumField() {
+			if v.Type().Field(i).IsExported() && hasRefs(v.Field(i)) {
+				return true
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := range v.Len() {
+			if hasRefs(v.Index(i)) {
+				return true
+			}
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			if hasRefs(iter.Value()) {
+				return true
+			}
+		}
+	case reflect.String:
+		return strings.HasPrefix(v.String(), Scheme)
+	}
+	return false
+}
+
+// jsonFields 将 JSON 对象密钥展开为字段，非 JSON 对象时整个值以空字符串为键
+func jsonFields(s string) map[string]string {
+	var obj map[string]any
+	if err := json.Unmarshal([]byte(s), &obj); err != nil {
+		return map[string]string{"": s}
+	}
+	fields := make(map[string]string, len(obj)+1)
+	fields[""] = s
+	for k, v := range obj {
+		if str, ok := v.(string); ok {
+			fields[k] = str
+		} else {
+			fields[k] = fmt.Sprint(v)
+		}
+	}
+	return fields
+}
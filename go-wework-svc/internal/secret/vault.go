@@ -0,0 +1,92 @@
+package secret
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"go-wework-svc/internal/shared"
+)
+
+const defaultVaultTimeout = 5 * time.Second
+
+// Vault 基于 HashiCorp Vault HTTP API 的密钥后端，使用 token 认证
+// 路径为不含 /v1/ 的完整 API 路径，兼容 KV v1（secret/wework）和 KV v2（secret/data/wework）
+type Vault struct {
+	addr       string
+	token      string
+	namespace  string
+	httpClient *http.Client
+}
+
+// NewVault 创建 Vault 后端，未配置 token 时读取 VAULT_TOKEN
+func NewVault(cfg shared.VaultConfig) *Vault {
+	token := cfg.Token
+	if token == "" {
+		token = os.Getenv("VAULT_TOKEN")
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultVaultTimeout
+	}
+	return &Vault{
+		addr:       strings.TrimRight(cfg.Addr, "/"),
+		token:      token,
+		namespace:  cfg.Namespace,
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// vaultResponse 读取密钥的响应，KV v2 的字段位于 data.data
+type vaultResponse struct {
+	Data   map[string]any `json:"data"`
+	Errors []string       `json:"errors"`
+}
+
+// Fetch 实现 Provider 接口
+func (v *Vault) Fetch(ctx context.Context, path string) (map[string]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.addr+"/v1/"+strings.TrimLeft(path, "/"), nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+	if v.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.namespace)
+	}
+
+	resp, err := v.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("execute request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, body)
+	}
+
+	var out vaultResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	data := out.Data
+	if inner, ok := data["data"].(map[string]any); ok {
+		if _, versioned := data["metadata"]; versioned {
+			data = inner
+		}
+	}
+
+	fields := make(map[string]string, len(data))
+	for k, val := range data {
+		if s, ok := val.(string); ok {
+			fields[k] = s
+		} else {
+			fields[k] = fmt.Sprint(val)
+		}
+	}
+	return fields, nil
+}
@@ -1,11 +1,18 @@
 package shared
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"net"
+	"net/netip"
 	"net/url"
 	"os"
 	"regexp"
+	"slices"
+	"strings"
+	"text/template"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -13,53 +20,1090 @@ import (
 
 // Config 应用配置
 type Config struct {
-	Server ServerConfig `yaml:"server"`
-	WeWork WeWorkConfig `yaml:"wework"`
-	AI     AIConfig     `yaml:"ai"`
-	Log    LogConfig    `yaml:"log"`
+	Server    ServerConfig    `yaml:"server"`
+	WeWork    WeWorkConfig    `yaml:"wework"`
+	AI        AIConfig        `yaml:"ai"`
+	Log       LogConfig       `yaml:"log"`
+	Transform TransformConfig `yaml:"transform"`
+	Admin     AdminConfig     `yaml:"admin"`
+	OAuth     OAuthConfig     `yaml:"oauth"`
+	Tracing   TracingConfig   `yaml:"tracing"`
+	Outbox    OutboxConfig    `yaml:"outbox"`
+	Store     StoreConfig     `yaml:"store"`
+	Plugins   []PluginConfig  `yaml:"plugins"`
+	Tenants   []TenantConfig  `yaml:"tenants"`
+	Suite     SuiteConfig     `yaml:"suite"`
+	// Webhooks 命名的群机器人，供回复推送、告警等功能按名称引用
+	Webhooks map[string]WebhookConfig `yaml:"webhooks"`
+	Alert    AlertConfig              `yaml:"alert"`
+	// ErrorReport 错误上报，事件只包含 msg_id、agent_id 等定位信息，不包含消息正文
+	ErrorReport ErrorReportConfig `yaml:"error_report"`
+
+	Conversation  ConversationConfig  `yaml:"conversation"`
+	ResponseCache ResponseCacheConfig `yaml:"response_cache"`
+	Archive       ArchiveConfig       `yaml:"archive"`
+	Publish       PublishConfig       `yaml:"publish"`
+	GRPC          GRPCConfig          `yaml:"grpc"`
+	Secrets       SecretsConfig       `yaml:"secrets"`
+	AutoReply     AutoReplyConfig     `yaml:"auto_reply"`
+	Moderation    ModerationConfig    `yaml:"moderation"`
+	I18n          I18nConfig          `yaml:"i18n"`
+	Schedule      ScheduleConfig      `yaml:"schedule"`
+	Leader        LeaderConfig        `yaml:"leader"`
+
+	// Hash 展开环境变量后的配置文件 SHA-256 前 12 位，用于确认部署的配置版本
+	Hash string `yaml:"-"`
+}
+
+// SecretsConfig 外部密钥后端配置
+// 任意字符串字段可写为 secret://vault/<path>#<field> 或 secret://aws/<secret-id>[#<json-key>]，加载时替换为密钥值
+type SecretsConfig struct {
+	Vault VaultConfig `yaml:"vault"`
+	AWS   AWSConfig   `yaml:"aws"`
+	// RefreshInterval 大于 0 时按周期重新加载配置并拉取密钥，轮换后的值按热加载规则生效
+	RefreshInterval time.Duration `yaml:"refresh_interval"`
+}
+
+// VaultConfig HashiCorp Vault 配置，路径为完整的 API 路径，如 secret/data/wework（KV v2）
+type VaultConfig struct {
+	Addr      string        `yaml:"addr"`
+	Token     string        `yaml:"token"`     // 为空时读取 VAULT_TOKEN
+	Namespace string        `yaml:"namespace"` // 企业版命名空间，可选
+	Timeout   time.Duration `yaml:"timeout"`   // 默认 5s
+}
+
+// AWSConfig AWS Secrets Manager 配置，凭证使用 AWS SDK 默认链（环境变量、共享配置、实例角色等）
+type AWSConfig struct {
+	Region  string        `yaml:"region"`  // 为空时使用 SDK 默认区域
+	Timeout time.Duration `yaml:"timeout"` // 加载 SDK 配置和读取单个密钥的超时，默认 10s
+}
+
+// ResolveFunc 在配置解析后、校验前执行，用于替换外部引用
+type ResolveFunc func(ctx context.Context, cfg *Config) error
+
+// ArchiveConfig 消息归档配置，记录解密后的入站消息和发出的回复，用于审计和排查
+type ArchiveConfig struct {
+	Enabled         bool           `yaml:"enabled"`
+	Driver          string         `yaml:"driver"`           // sqlite | postgres
+	DSN             string         `yaml:"dsn"`              // sqlite 为文件路径，postgres 为连接串
+	Retention       time.Duration  `yaml:"retention"`        // 记录保留时长，0 表示不清理
+	CleanupInterval time.Duration  `yaml:"cleanup_interval"` // 过期记录清理周期，默认 1h
+	QueueSize       int            `yaml:"queue_size"`       // 异步写入队列长度，默认 1000
+	MsgAudit        MsgAuditConfig `yaml:"msgaudit"`
+}
+
+// MsgAuditConfig 会话存档拉取：定期拉取企业的聊天记录，解密后写入归档库，与机器人的对话一起检索
+// 官方 SDK 只提供 C 库，需部署封装 GetChatData / DecryptData 的 sidecar，接口见 client.MsgAuditBridge
+type MsgAuditConfig struct {
+	Enabled     bool   `yaml:"enabled"`
+	BridgeURL   string `yaml:"bridge_url"`
+	BridgeToken string `yaml:"bridge_token"`
+	// PrivateKeys 按管理后台的公钥版本号配置 PEM 格式的 RSA 私钥，轮换公钥后保留旧版本以解密历史记录
+	PrivateKeys map[int]string `yaml:"private_keys"`
+	Interval    time.Duration  `yaml:"interval"`   // 拉取周期，默认 1m
+	BatchSize   int            `yaml:"batch_size"` // 每次拉取条数，默认 100，最大 1000
+}
+
+// 归档数据库驱动常量
+const (
+	ArchiveDriverSQLite   = "sqlite"
+	ArchiveDriverPostgres = "postgres"
+)
+
+// PublishConfig 消息发布配置，将解密后的入站消息和发出的回复以 JSON 发布到 NATS 或 Kafka，供下游消费
+// 发布为尽力而为：队列满或发布失败时丢弃并记录日志，不影响回调处理
+type PublishConfig struct {
+	Enabled       bool        `yaml:"enabled"`
+	Driver        string      `yaml:"driver"`         // nats | kafka
+	InboundTopic  string      `yaml:"inbound_topic"`  // 入站消息主题，默认 wework.inbound
+	OutboundTopic string      `yaml:"outbound_topic"` // 回复主题，默认 wework.outbound
+	QueueSize     int         `yaml:"queue_size"`     // 异步发布队列长度，默认 1000
+	NATS          NATSConfig  `yaml:"nats"`
+	Kafka         KafkaConfig `yaml:"kafka"`
+}
+
+// 消息发布驱动常量
+const (
+	PublishDriverNATS  = "nats"
+	PublishDriverKafka = "kafka"
+)
+
+// NATSConfig NATS 连接配置，使用 core 协议发布
+type NATSConfig struct {
+	URL      string        `yaml:"url"` // nats://host:4222，tls:// 表示使用 TLS
+	User     string        `yaml:"user"`
+	Password string        `yaml:"password"`
+	Token    string        `yaml:"token"`
+	Timeout  time.Duration `yaml:"timeout"` // 连接和写入超时，默认 5s
+}
+
+// KafkaConfig Kafka 配置，通过 Kafka REST Proxy（v2 API）发布
+type KafkaConfig struct {
+	RESTURL  string        `yaml:"rest_url"` // 如 http://kafka-rest:8082
+	Username string        `yaml:"username"` // basic 认证，可选
+	Password string        `yaml:"password"`
+	Timeout  time.Duration `yaml:"timeout"` // 默认 5s
+}
+
+// GRPCConfig 消息流 gRPC 服务配置，内部服务订阅入站消息并通过应用发送回复
+// 订阅是实时推送，不补发订阅前或断线期间的消息；需要可靠消费时使用 publish
+type GRPCConfig struct {
+	Enabled    bool     `yaml:"enabled"`
+	Addr       string   `yaml:"addr"`        // 监听地址，默认 ":9090"
+	Tokens     []string `yaml:"tokens"`      // 客户端在 authorization 元数据中携带 "Bearer <token>"
+	BufferSize int      `yaml:"buffer_size"` // 每个订阅者缓冲的消息数，超出时丢弃，默认 100
+	CertFile   string   `yaml:"cert_file"`   // 与 key_file 同时配置时启用 TLS
+	KeyFile    string   `yaml:"key_file"`
+}
+
+// ConversationConfig 会话历史配置，记录保存在 store 中，store 为 redis 时多副本共享
+type ConversationConfig struct {
+	Enabled  bool          `yaml:"enabled"`
+	MaxTurns int           `yaml:"max_turns"` // 每个会话保留的最大条数（用户和 AI 各计一条），默认 10
+	TTL      time.Duration `yaml:"ttl"`       // 会话空闲超过该时长后清空，默认 30m
+}
+
+// ResponseCacheConfig AI 回复缓存，相同问题在 TTL 内直接返回缓存的回复，记录保存在 store 中
+// 只缓存不带附件和会话历史的问题，回复中调用过工具的不缓存；不同用户共享缓存，不适合回答中含个人信息的场景
+type ResponseCacheConfig struct {
+	Enabled bool          `yaml:"enabled"`
+	TTL     time.Duration `yaml:"ttl"` // 默认 24h
+	// Embedding 配置后按语义相似度命中缓存，向量索引保存在进程内存中
+	Embedding  EmbeddingConfig `yaml:"embedding"`
+	Similarity float64         `yaml:"similarity"`  // 命中所需的最低余弦相似度，默认 0.92
+	MaxEntries int             `yaml:"max_entries"` // 向量索引保留的问题数，默认 1000
+}
+
+// EmbeddingConfig OpenAI 兼容的 /embeddings 接口
+type EmbeddingConfig struct {
+	BaseURL string        `yaml:"base_url"` // 为空时不启用语义匹配
+	APIKey  string        `yaml:"api_key"`
+	Model   string        `yaml:"model"`
+	Timeout time.Duration `yaml:"timeout"` // 默认 5s
+}
+
+// WebhookConfig 群机器人配置
+type WebhookConfig struct {
+	URL string `yaml:"url"` // 完整 webhook 地址，含 key
+}
+
+// AlertConfig 运维告警：AI 后端不可用、DLQ 积压或签名失败激增时推送 Markdown 告警到群机器人
+type AlertConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Webhook string `yaml:"webhook"` // webhooks 中的群机器人名称
+	// Interval 检查周期，默认 30s
+	Interval time.Duration `yaml:"interval"`
+	// Cooldown 同类告警的最短间隔，默认 10m，多副本经 store 共享
+	Cooldown time.Duration `yaml:"cooldown"`
+	// DLQThreshold outbox 记录数超过该值时告警，默认 100
+	DLQThreshold int `yaml:"dlq_threshold"`
+	// SignatureFailures 一个检查周期内签名失败次数超过该值时告警，默认 20
+	SignatureFailures int      `yaml:"signature_failures"`
+	Mentions          []string `yaml:"mentions"` // 告警时 @ 的成员 userid
+}
+
+// ErrorReportConfig panic、解密失败和 AI 错误的上报配置
+type ErrorReportConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Driver  string `yaml:"driver"` // sentry | webhook
+	// DSN Sentry 项目 DSN，driver 为 sentry 时必填
+	DSN string `yaml:"dsn"`
+	// URL 接收 JSON 事件的地址，driver 为 webhook 时必填
+	URL         string `yaml:"url"`
+	Environment string `yaml:"environment"`
+	// QueueSize 待发送事件队列长度，队列满时丢弃新事件，默认 100
+	QueueSize int `yaml:"queue_size"`
+}
+
+// SuiteConfig 服务商第三方应用配置，指令回调地址为 /suite/callback
+type SuiteConfig struct {
+	Enabled        bool   `yaml:"enabled"`
+	SuiteID        string `yaml:"suite_id"`
+	Secret         string `yaml:"secret"`
+	Token          string `yaml:"token"`
+	EncodingAESKey string `yaml:"encoding_aes_key"`
+}
+
+// TenantConfig 多企业部署中的租户，回调地址为 /callback/{name}
+type TenantConfig struct {
+	Name   string       `yaml:"name"`
+	WeWork WeWorkConfig `yaml:"wework"` // 租户企业的完整配置，暂不支持 agents
+	AI     *AIConfig    `yaml:"ai"`     // 为空时使用顶层 ai 配置
 }
 
 // ServerConfig HTTP 服务器配置
 type ServerConfig struct {
-	Addr         string        `yaml:"addr"`
-	ReadTimeout  time.Duration `yaml:"read_timeout"`
-	WriteTimeout time.Duration `yaml:"write_timeout"`
+	Addr            string        `yaml:"addr"` // host:port 或 unix:///path/to.sock
+	ReadTimeout     time.Duration `yaml:"read_timeout"`
+	WriteTimeout    time.Duration `yaml:"write_timeout"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"` // 优雅退出等待时长，默认 15s
+	MaxBodyBytes    int64         `yaml:"max_body_bytes"`   // 回调请求体上限，超过返回 413，默认 256KB
+	// ReadHeaderTimeout 读取请求头的超时，默认 5s，防止慢速请求头占用连接
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	// IdleTimeout keep-alive 连接的空闲超时，默认 60s
+	IdleTimeout time.Duration `yaml:"idle_timeout"`
+	// MaxHeaderBytes 请求头上限，默认 64KB
+	MaxHeaderBytes int `yaml:"max_header_bytes"`
+	// RouteTimeouts 按路由前缀的处理超时，与内置默认值合并，同一前缀以配置为准
+	RouteTimeouts []RouteTimeoutConfig `yaml:"route_timeouts"`
+	TLS           TLSConfig            `yaml:"tls"`
+	// Backpressure 回调并发限制，所有应用共享
+	Backpressure BackpressureConfig `yaml:"backpressure"`
+	// Listeners 额外的监听地址，如仅对本机开放的管理端口
+	Listeners []ListenerConfig `yaml:"listeners"`
+	// BasePath 所有路由的路径前缀，如挂载在 nginx 的 /wework/ 下时为 /wework；探针路径同时保留在根路径
+	BasePath string `yaml:"base_path"`
+	// TrustedProxies 可信反向代理的 IP 或 CIDR，只有来自这些地址的请求才采用 X-Forwarded-For / X-Forwarded-Proto
+	TrustedProxies []string `yaml:"trusted_proxies"`
+	// CallbackAllowlist 回调来源 IP 白名单
+	CallbackAllowlist CallbackAllowlistConfig `yaml:"callback_allowlist"`
+}
+
+// RouteTimeoutConfig 一个路由前缀的处理超时，Timeout 为 0 表示不限制（用于 WebSocket、SSE 等长连接）
+// 超时后请求 context 取消，响应写入截止时间同步调整，可长于或短于 write_timeout
+type RouteTimeoutConfig struct {
+	Prefix  string        `yaml:"prefix"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// CallbackAllowlistConfig 只接受来自企业微信回调 IP 段的回调请求，作为签名校验之外的纵深防御
+// IP 段由 getcallbackip 接口定期拉取（使用 wework.secret 的 access_token），Static 始终放行并在拉取成功前兜底
+type CallbackAllowlistConfig struct {
+	Enabled         bool          `yaml:"enabled"`
+	Static          []string      `yaml:"static"`           // IP 或 CIDR
+	RefreshInterval time.Duration `yaml:"refresh_interval"` // 默认 1h
+}
+
+// UnixSocketPrefix 监听地址使用 Unix 域套接字时的前缀，如 unix:///run/go-wework-svc.sock
+const UnixSocketPrefix = "unix://"
+
+// ListenerConfig 额外的 HTTP 监听地址
+// 任一监听设置 admin 后，/admin 和 /debug 只在 admin 监听上提供，其余监听（包括 server.addr）返回 404
+type ListenerConfig struct {
+	Addr  string `yaml:"addr"`  // host:port 或 unix:///path/to.sock
+	TLS   bool   `yaml:"tls"`   // 使用 server.tls 的证书，需启用 server.tls
+	Admin bool   `yaml:"admin"` // 提供管理端路由
+}
+
+// BackpressureConfig 回调并发限制，MaxInFlight 为 0 时不限制
+// 处理中的数量包括转入后台的 AI 转发；槽位已满时最多 MaxQueue 个请求排队等待 QueueTimeout，其余立即返回 503
+type BackpressureConfig struct {
+	MaxInFlight  int           `yaml:"max_in_flight"`
+	MaxQueue     int           `yaml:"max_queue"`     // 默认 0，不排队
+	QueueTimeout time.Duration `yaml:"queue_timeout"` // 默认 1s，须小于企业微信的 5 秒超时
+}
+
+// TLSConfig 服务自身终止 HTTPS，前面没有反向代理时使用
+// 使用证书文件或 ACME 自动签发，二者择一
+type TLSConfig struct {
+	Enabled  bool           `yaml:"enabled"`
+	CertFile string         `yaml:"cert_file"`
+	KeyFile  string         `yaml:"key_file"`
+	Autocert AutocertConfig `yaml:"autocert"`
+}
+
+// AutocertConfig ACME（如 Let's Encrypt）自动签发证书
+type AutocertConfig struct {
+	Enabled  bool     `yaml:"enabled"`
+	Domains  []string `yaml:"domains"`   // 允许签发的域名，其余 SNI 一律拒绝
+	Email    string   `yaml:"email"`     // ACME 账户联系邮箱，可选
+	CacheDir string   `yaml:"cache_dir"` // 证书缓存目录，默认 data/autocert
+	HTTPAddr string   `yaml:"http_addr"` // HTTP-01 验证及跳转 HTTPS 的监听地址，如 ":80"，为空时仅使用 TLS-ALPN-01
 }
 
 // WeWorkConfig 企业微信配置
 type WeWorkConfig struct {
-	CorpID         string `yaml:"corp_id"`
+	CorpID              string        `yaml:"corp_id"`
+	Token               string        `yaml:"token"`
+	EncodingAESKey      string        `yaml:"encoding_aes_key"`
+	EncodingAESKeys     []string      `yaml:"encoding_aes_keys"` // 轮换期间的其他密钥，解密时在 encoding_aes_key 之后依次尝试
+	AgentID             int64         `yaml:"agent_id"`
+	Secret              string        `yaml:"secret"`                // 应用 Secret，用于获取 access_token
+	APIBaseURL          string        `yaml:"api_base_url"`          // 服务端 API 地址，默认 https://qyapi.weixin.qq.com
+	ReplyMode           string        `yaml:"reply_mode"`            // async（默认）| passive
+	PassiveTimeout      time.Duration `yaml:"passive_timeout"`       // passive 模式下等待 AI 回复的时长，默认 4s
+	DedupTTL            time.Duration `yaml:"dedup_ttl"`             // 回调消息去重窗口，默认 5m，负数关闭
+	ReplayWindow        time.Duration `yaml:"replay_window"`         // 回调时间戳允许偏差，默认 300s，负数关闭重放防护
+	SignatureFailLimit  int           `yaml:"signature_fail_limit"`  // 单 IP 在计数窗口内允许的签名失败次数，默认 10，负数关闭限流
+	SignatureFailWindow time.Duration `yaml:"signature_fail_window"` // 签名失败计数窗口，默认 1m
+
+	// EventReplies 事件类型到固定回复的映射，如 enter_agent 的欢迎语，需配置 Secret
+	EventReplies map[string]string `yaml:"event_replies"`
+
+	Welcome WelcomeConfig `yaml:"welcome"`
+
+	Menu MenuConfig `yaml:"menu"`
+
+	ExternalContact ExternalContactConfig `yaml:"external_contact"`
+
+	Approval ApprovalConfig `yaml:"approval"`
+
+	Calendar CalendarConfig `yaml:"calendar"`
+
+	Actions ActionsConfig `yaml:"actions"`
+
+	JSAPI JSAPIConfig `yaml:"jsapi"`
+
+	// CardButtons 模板卡片按钮 key 到点击处理方式的映射，需配置 Secret
+	CardButtons map[string]CardButtonConfig `yaml:"card_buttons"`
+
+	// Agents 同一企业下的其他自建应用，回调地址为 /callback/{name}
+	Agents []AgentConfig `yaml:"agents"`
+
+	KF KFConfig `yaml:"kf"`
+
+	Stream StreamConfig `yaml:"stream"`
+
+	// ReplyWebhook AI 回复同时推送到的群机器人名称，引用顶层 webhooks
+	ReplyWebhook string `yaml:"reply_webhook"`
+
+	Mention MentionConfig `yaml:"mention"`
+
+	Commands CommandsConfig `yaml:"commands"`
+
+	Redaction RedactionConfig `yaml:"redaction"`
+
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+
+	Access AccessConfig `yaml:"access"`
+
+	Media MediaConfig `yaml:"media"`
+
+	// Routes 按发送者的部门或标签将 AI 请求路由到不同的后端、系统提示或 group_id，按顺序匹配，未命中时使用 ai 配置
+	Routes []RouteConfig `yaml:"routes"`
+
+	// ContactsProfile AI 请求附带发送者的姓名、主部门和职务（user/get，缓存 1 小时），需配置 Secret
+	// openai、ollama 写入系统提示，dify、fastgpt 写入 user_name、user_department、user_position 变量
+	ContactsProfile bool `yaml:"contacts_profile"`
+
+	ReplyFormat ReplyFormatConfig `yaml:"reply_format"`
+
+	// Persona 应用的人设，agents 可单独配置
+	Persona PersonaConfig `yaml:"persona"`
+	// GroupPersonas 按群聊 chat_id 覆盖应用的人设
+	GroupPersonas map[string]PersonaConfig `yaml:"group_personas"`
+}
+
+// PersonaConfig 机器人人设，openai、ollama 写入系统提示，dify、fastgpt 写入 persona_tone、persona_language、persona_topics 变量
+type PersonaConfig struct {
+	// SystemPrompt 代替 openai、ollama 后端配置的系统提示，routes 指定的系统提示优先
+	SystemPrompt string   `yaml:"system_prompt"`
+	Tone         string   `yaml:"tone"`     // 语气，如「正式、礼貌」
+	Language     string   `yaml:"language"` // 回复语言，如「简体中文」
+	Topics       []string `yaml:"topics"`   // 允许回答的主题，为空时不限制
+}
+
+// IsZero 未配置任何字段
+func (p PersonaConfig) IsZero() bool {
+	return p.SystemPrompt == "" && p.Tone == "" && p.Language == "" && len(p.Topics) == 0
+}
+
+// RouteConfig 一条 AI 路由，命中 users、departments（直属部门）或 tags 中任一项即匹配，部门和标签需配置 Secret
+type RouteConfig struct {
+	Name        string   `yaml:"name"`
+	Users       []string `yaml:"users"`
+	Departments []int64  `yaml:"departments"`
+	Tags        []int64  `yaml:"tags"` // 标签 ID，成员直接或通过直属部门属于标签均视为命中
+	// SystemPrompt 覆盖 openai、ollama 后端的系统提示
+	SystemPrompt string `yaml:"system_prompt"`
+	GroupID      string `yaml:"group_id"` // 覆盖请求的 group_id
+	// AI 该路由使用的后端，为空时沿用默认后端；不支持热更新，修改后需重启
+	AI *AIConfig `yaml:"ai"`
+}
+
+// WelcomeConfig 关注应用和进入应用时的欢迎语，需配置 Secret；优先于 event_replies 中相同事件的回复
+type WelcomeConfig struct {
+	// Message 欢迎语模板（text/template），为空时不启用，可用 {{.User}}、{{.Event}}、{{.Commands}}（可用命令列表）
+	Message string `yaml:"message"`
+	// Interval 同一用户进入应用时的最短发送间隔，默认 24h，负数表示每次进入都发送
+	Interval time.Duration `yaml:"interval"`
+}
+
+// MenuConfig 应用自定义菜单，配置按钮时启动后通过 menu/create 覆盖现有菜单，需配置 Secret
+// 启用 commands 时 click 按钮的 key 按 "命令名 [参数]" 执行命令
+type MenuConfig struct {
+	Buttons []MenuButtonConfig `yaml:"buttons"` // 最多 3 个
+}
+
+// MenuButtonConfig 菜单按钮，含子菜单时不填写 type
+type MenuButtonConfig struct {
+	Name       string             `yaml:"name"`
+	Type       string             `yaml:"type"` // click | view
+	Key        string             `yaml:"key"`
+	URL        string             `yaml:"url"`
+	SubButtons []MenuButtonConfig `yaml:"sub_buttons"` // 最多 5 个
+}
+
+func (m MenuConfig) validate() error {
+	if len(m.Buttons) > 3 {
+		return fmt.Errorf("at most 3 buttons, got %d", len(m.Buttons))
+	}
+	for i, b := range m.Buttons {
+		if len(b.SubButtons) > 5 {
+			return fmt.Errorf("buttons[%d]: at most 5 sub_buttons, got %d", i, len(b.SubButtons))
+		}
+		if len(b.SubButtons) > 0 {
+			if b.Name == "" || b.Type != "" {
+				return fmt.Errorf("buttons[%d]: button with sub_buttons requires name and no type", i)
+			}
+			for j, sub := range b.SubButtons {
+				if err := sub.validate(); err != nil {
+					return fmt.Errorf("buttons[%d].sub_buttons[%d]: %w", i, j, err)
+				}
+			}
+			continue
+		}
+		if err := b.validate(); err != nil {
+			return fmt.Errorf("buttons[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (b MenuButtonConfig) validate() error {
+	if b.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(b.SubButtons) > 0 {
+		return fmt.Errorf("sub_buttons are only allowed on top-level buttons")
+	}
+	switch b.Type {
+	case "click":
+		if b.Key == "" {
+			return fmt.Errorf("key is required for click")
+		}
+	case "view":
+		if b.URL == "" {
+			return fmt.Errorf("url is required for view")
+		}
+	default:
+		return fmt.Errorf("type must be click or view, got %q", b.Type)
+	}
+	return nil
+}
+
+// ApprovalConfig 审批状态变化事件（sys_approval_change、open_approval_change）的处理，需配置 Secret 用于发送通知
+// 审批应用的事件需在审批应用的「可调用接口的应用」中加入本应用
+type ApprovalConfig struct {
+	Enabled         bool   `yaml:"enabled"`
+	Secret          string `yaml:"secret"`           // 调用审批详情 API 的 secret，默认使用应用 secret
+	NotifyApprovers bool   `yaml:"notify_approvers"` // 通知当前节点的审批人，附带申请内容
+	NotifyApplicant bool   `yaml:"notify_applicant"` // 审批通过、驳回或撤销时通知申请人
+	SummaryChars    int    `yaml:"summary_chars"`    // 申请内容超过该字符数时由 AI 生成摘要，0 表示不摘要
+}
+
+// JSAPIConfig 为内嵌在企业微信中的 H5 页面提供 JS-SDK 签名（GET /jsapi/signature），需配置 Secret
+type JSAPIConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// AllowedHosts 允许签名的页面域名，须与应用的可信域名一致，同时作为跨域请求允许的来源
+	AllowedHosts []string `yaml:"allowed_hosts"`
+}
+
+// CalendarConfig 以工具调用的形式让 AI 创建会议、查询日程，支持 openai（不含 fastgpt）和 assistant 协议后端，需配置 Secret
+type CalendarConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// CalendarID 应用日历的 cal_id，为空时新日程加入应用默认日历，且不提供查询日程的工具
+	CalendarID string `yaml:"calendar_id"`
+	// Timezone 解析和展示时间的时区，如 Asia/Shanghai，默认使用系统时区
+	Timezone string `yaml:"timezone"`
+}
+
+// ActionsConfig AI 可通过工具调用执行的企业微信操作，以发起对话的用户身份执行，需配置 Secret
+// OpenAI 兼容后端通过 function calling 调用，assistant 协议后端在响应中返回 tool_calls
+type ActionsConfig struct {
+	SendFile   bool `yaml:"send_file"`   // 将文件或图片发给当前用户，下载域名限于 media.reply_hosts
+	CreateTodo bool `yaml:"create_todo"` // 创建待办，需开启待办 API 权限；提醒时间按 calendar.timezone 解析
+	LookupUser bool `yaml:"lookup_user"` // 按 userid 查询成员的姓名、部门和职务
+}
+
+// Enabled 是否启用了任一操作
+func (a ActionsConfig) Enabled() bool {
+	return a.SendFile || a.CreateTodo || a.LookupUser
+}
+
+// ExternalContactConfig 客户联系变更事件（change_external_contact）的处理，需配置 Secret 且应用为客户联系的可调用应用
+// 模板可用 {{.UserID}}（跟进成员）、{{.ExternalUserID}}、{{.ExternalName}}、{{.CorpName}}、{{.State}}、{{.ChangeType}}
+type ExternalContactConfig struct {
+	Welcome string            `yaml:"welcome"` // 成员添加客户后发送给客户的欢迎语，为空不发送；成员已配置联系我欢迎语时不会触发
+	Notices map[string]string `yaml:"notices"` // 按变更类型通知跟进成员，如 add_external_contact、del_follow_user、msg_audit_approved
+}
+
+// Enabled 是否配置了欢迎语或通知
+func (e ExternalContactConfig) Enabled() bool {
+	return e.Welcome != "" || len(e.Notices) > 0
+}
+
+// externalChangeTypes 支持通知的客户联系变更类型
+var externalChangeTypes = []string{
+	"add_external_contact", "add_half_external_contact", "edit_external_contact",
+	"del_external_contact", "del_follow_user", "transfer_fail", "msg_audit_approved",
+}
+
+func (e ExternalContactConfig) validate() error {
+	if _, err := template.New("welcome").Parse(e.Welcome); err != nil {
+		return fmt.Errorf("welcome: %w", err)
+	}
+	for change, text := range e.Notices {
+		if !slices.Contains(externalChangeTypes, change) {
+			return fmt.Errorf("notices: unknown change type %q", change)
+		}
+		if _, err := template.New(change).Parse(text); err != nil {
+			return fmt.Errorf("notices.%s: %w", change, err)
+		}
+	}
+	return nil
+}
+
+// CardButtonConfig 模板卡片按钮的点击处理
+type CardButtonConfig struct {
+	Reply       string `yaml:"reply"`        // 发送给点击者的回复
+	ReplaceName string `yaml:"replace_name"` // 点击后按钮替换为的文字，如"已确认"
+}
+
+// ReplyFormatConfig 回复的格式转换与分段，超过单条消息上限的回复拆分为多条发送
+type ReplyFormatConfig struct {
+	// Format raw（默认，原样发送）| text（去掉 Markdown 标记）| markdown（转换为企业微信 Markdown 子集，仅企业微信客户端可见）
+	Format   string `yaml:"format"`
+	MaxBytes int    `yaml:"max_bytes"` // 单条消息字节上限，默认 text 2048、markdown 4096
+}
+
+// MediaConfig 素材下载配置，需配置 secret
+type MediaConfig struct {
+	MaxBytes int64  `yaml:"max_bytes"` // 单个素材下载上限，默认 20MB
+	TempDir  string `yaml:"temp_dir"`  // 临时文件目录，默认系统临时目录
+	// Vision 图片消息下载后随 AI 请求发送图片内容，供支持视觉输入的后端使用（openai 为 data URL，ollama 为 images）
+	Vision bool `yaml:"vision"`
+	// ExtractText 提取文件消息（pdf、docx、纯文本）的正文随 AI 请求发送，不支持的类型直接回复原因
+	ExtractText  bool `yaml:"extract_text"`
+	MaxTextChars int  `yaml:"max_text_chars"` // 提取正文的字符上限，超出部分截断，默认 20000
+	// ReplyMedia AI 回复中的 Markdown 图片和文档链接上传为临时素材，另以图片、文件消息发送
+	ReplyMedia bool     `yaml:"reply_media"`
+	ReplyHosts []string `yaml:"reply_hosts"` // 允许下载的素材域名，data URL 不受限制
+}
+
+// AccessConfig 按用户和部门控制谁可以使用机器人，黑名单优先；白名单均为空时除黑名单外的成员都可使用
+// 部门按成员直属部门匹配，需配置 secret 且应用对成员有通讯录可见范围
+type AccessConfig struct {
+	AllowUsers       []string `yaml:"allow_users"`
+	AllowDepartments []int64  `yaml:"allow_departments"`
+	BlockUsers       []string `yaml:"block_users"`
+	BlockDepartments []int64  `yaml:"block_departments"`
+	AllowCorps       []string `yaml:"allow_corps"` // 互联企业（含上下游）CorpID，其成员以 CorpID/UserID 发消息
+	DenyReply        string   `yaml:"deny_reply"`  // 拒绝时的回复，为空则不回复
+}
+
+// Enabled 是否配置了任一名单
+func (a AccessConfig) Enabled() bool {
+	return len(a.AllowUsers) > 0 || len(a.AllowDepartments) > 0 || len(a.BlockUsers) > 0 || len(a.BlockDepartments) > 0 || len(a.AllowCorps) > 0
+}
+
+// RateLimitConfig 按用户限制转发 AI 的频率和每日次数，计数保存在 store 中，命令和自动回复不计入
+type RateLimitConfig struct {
+	Enabled     bool     `yaml:"enabled"`
+	PerMinute   int      `yaml:"per_minute"`  // 0 表示不限
+	Daily       int      `yaml:"daily"`       // 每个自然日的次数，0 表示不限
+	Reply       string   `yaml:"reply"`       // 超出每分钟限制时的回复
+	QuotaReply  string   `yaml:"quota_reply"` // 超出每日额度时的回复
+	ExemptUsers []string `yaml:"exempt_users"`
+}
+
+// RedactionConfig 转发 AI 前脱敏消息中的个人信息，替换为占位符
+type RedactionConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Kinds phone | id_card | email | employee_id，为空时启用全部（employee_id 需配置 employee_id_pattern）
+	Kinds             []string          `yaml:"kinds"`
+	EmployeeIDPattern string            `yaml:"employee_id_pattern"` // 工号正则，如 \bE\d{6}\b
+	Placeholders      map[string]string `yaml:"placeholders"`        // 类型 → 占位符，覆盖默认的 [手机号] 等
+}
+
+// 脱敏类型常量
+const (
+	RedactPhone      = "phone"
+	RedactIDCard     = "id_card"
+	RedactEmail      = "email"
+	RedactEmployeeID = "employee_id"
+)
+
+// CommandsConfig 内置命令配置，/help、/reset、/status 在转发 AI 前处理
+type CommandsConfig struct {
+	Enabled  bool              `yaml:"enabled"`
+	Intro    string            `yaml:"intro"`    // /help 回复开头的能力说明
+	Keywords map[string]string `yaml:"keywords"` // 关键词 → 命令名，消息与关键词完全相同时触发，如 重置: reset
+}
+
+// MentionConfig 文本消息的触发规则，names 和 pattern 均为空时只回复单聊消息
+type MentionConfig struct {
+	Names        []string `yaml:"names"`         // 机器人显示名称及别名，内容包含 "@名称" 时触发
+	Pattern      string   `yaml:"pattern"`       // 正则，内容匹配时触发
+	DirectAlways bool     `yaml:"direct_always"` // 单聊消息无需 @ 即回复，群聊仍需提及
+}
+
+// StreamConfig 流式回复配置，仅 async 模式且配置了 Secret 时生效
+// 增量内容累积到 min_chars 或距上次发送超过 flush_interval 时，作为一条主动消息发出
+type StreamConfig struct {
+	Enabled       bool          `yaml:"enabled"`
+	FlushInterval time.Duration `yaml:"flush_interval"` // 默认 3s
+	MinChars      int           `yaml:"min_chars"`      // 默认 200
+}
+
+// KFConfig 微信客服配置，客服消息事件推送到应用回调地址
+type KFConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Secret  string `yaml:"secret"` // 微信客服 secret，为空时使用应用 Secret
+}
+
+// AgentConfig 自建应用配置，未填写的字段继承 wework 顶层配置
+type AgentConfig struct {
+	Name           string `yaml:"name"` // 回调路径 /callback/{name}
+	AgentID        int64  `yaml:"agent_id"`
 	Token          string `yaml:"token"`
 	EncodingAESKey string `yaml:"encoding_aes_key"`
-	AgentID        int64  `yaml:"agent_id"`
+	// EncodingAESKeys 轮换期间的其他密钥，仅在设置了 encoding_aes_key 时生效
+	EncodingAESKeys []string       `yaml:"encoding_aes_keys"`
+	Secret          string         `yaml:"secret"`
+	AI              *AIConfig      `yaml:"ai"`      // 为空时使用顶层 ai 配置，否则需完整配置
+	Mention         *MentionConfig `yaml:"mention"` // 为空时使用顶层 mention 配置
+	Persona         *PersonaConfig `yaml:"persona"` // 为空时使用顶层 persona 配置
+}
+
+// ForAgent 返回合并了应用配置的 WeWorkConfig
+func (c WeWorkConfig) ForAgent(a AgentConfig) WeWorkConfig {
+	out := c
+	out.Agents = nil
+	out.AgentID = a.AgentID
+	if a.Token != "" {
+		out.Token = a.Token
+	}
+	if a.EncodingAESKey != "" {
+		out.EncodingAESKey = a.EncodingAESKey
+		out.EncodingAESKeys = a.EncodingAESKeys
+	}
+	// Secret 按应用区分，不继承顶层配置，未配置时该应用不主动发送消息
+	out.Secret = a.Secret
+	if a.Mention != nil {
+		out.Mention = *a.Mention
+	}
+	if a.Persona != nil {
+		out.Persona = *a.Persona
+	}
+	return out
 }
 
+// 回复模式常量
+const (
+	ReplyModeAsync   = "async"
+	ReplyModePassive = "passive"
+)
+
 // AIConfig AI 助手配置
 type AIConfig struct {
+	// Provider 后端协议：assistant（默认，POST /chat）| openai（Chat Completions）| ollama（/api/chat）
+	// | dify（Dify 对话型应用）| fastgpt（FastGPT 应用）
+	Provider       string        `yaml:"provider"`
+	BaseURL        string        `yaml:"base_url"`
+	Timeout        time.Duration `yaml:"timeout"`
+	StreamTimeout  time.Duration `yaml:"stream_timeout"`   // 流式请求整体超时，默认 2m
+	Retry          int           `yaml:"retry"`            // 最大重试次数，仅重试网络错误、429 和 5xx
+	RetryBaseDelay time.Duration `yaml:"retry_base_delay"` // 退避基准时长，第 n 次重试前随机等待 [0, base*2^n)，默认 500ms
+	RetryMaxDelay  time.Duration `yaml:"retry_max_delay"`  // 单次等待上限，默认 10s；Retry-After 超过该值时不再重试
+	OpenAI         OpenAIConfig  `yaml:"openai"`           // provider 为 openai 时生效
+	Ollama         OllamaConfig  `yaml:"ollama"`           // provider 为 ollama 时生效
+	Dify           DifyConfig    `yaml:"dify"`             // provider 为 dify 时生效
+	FastGPT        FastGPTConfig `yaml:"fastgpt"`          // provider 为 fastgpt 时生效
+	Canary         CanaryConfig  `yaml:"canary"`
+	Shadow         ShadowConfig  `yaml:"shadow"`
+
+	// Fallbacks 主后端重试后仍失败时按顺序尝试的备用后端
+	Fallbacks []AIFallbackConfig `yaml:"fallbacks"`
+	// Breaker 配置了 fallbacks 时为链中每个后端加上熔断，熔断期间直接降级到下一个后端
+	Breaker BreakerConfig `yaml:"breaker"`
+}
+
+// BreakerConfig AI 后端熔断，连续失败 failures 次后在 cooldown 内不再请求该后端，之后放行一个探测请求
+type BreakerConfig struct {
+	Failures int           `yaml:"failures"` // 默认 5
+	Cooldown time.Duration `yaml:"cooldown"` // 默认 30s
+}
+
+// AIFallbackConfig 备用 AI 后端，字段与 ai 相同，不支持其中的 canary、shadow 和 fallbacks
+type AIFallbackConfig struct {
+	Name     string `yaml:"name"` // 用于日志和统计
+	AIConfig `yaml:",inline"`
+}
+
+// AI 后端协议常量
+const (
+	AIProviderAssistant = "assistant"
+	AIProviderOpenAI    = "openai"
+	AIProviderOllama    = "ollama"
+	AIProviderDify      = "dify"
+	AIProviderFastGPT   = "fastgpt"
+)
+
+// AIFallbackPrimary 降级链中主后端的名称
+const AIFallbackPrimary = "primary"
+
+// OpenAIConfig OpenAI 兼容协议配置，base_url 填写到版本路径，如 https://api.openai.com/v1
+// Azure OpenAI 的 base_url 为 https://{resource}.openai.azure.com/openai/deployments/{deployment}
+type OpenAIConfig struct {
+	Model        string   `yaml:"model"`
+	APIKey       string   `yaml:"api_key"`
+	APIVersion   string   `yaml:"api_version"` // Azure OpenAI，设置后使用 api-key 头认证并附加 api-version 参数
+	Temperature  *float64 `yaml:"temperature"` // 为空时使用模型默认值
+	MaxTokens    int      `yaml:"max_tokens"`
+	SystemPrompt string   `yaml:"system_prompt"`
+}
+
+// OllamaConfig Ollama 本地模型配置，base_url 如 http://ollama:11434
+type OllamaConfig struct {
+	Model        string   `yaml:"model"`
+	Temperature  *float64 `yaml:"temperature"` // 为空时使用模型默认值
+	NumPredict   int      `yaml:"num_predict"` // 最大生成 token 数，0 表示模型默认
+	SystemPrompt string   `yaml:"system_prompt"`
+	KeepAlive    string   `yaml:"keep_alive"` // 模型常驻内存时长，如 30m，为空时使用服务端默认
+}
+
+// DifyConfig Dify 对话型应用配置，base_url 如 https://api.dify.ai/v1
+// Dify 在服务端保存会话上下文，同一会话的 conversation_id 保存在 store 中
+type DifyConfig struct {
+	APIKey          string            `yaml:"api_key"`          // 应用 API 密钥，app- 开头
+	Inputs          map[string]string `yaml:"inputs"`           // 应用定义的输入变量
+	ConversationTTL time.Duration     `yaml:"conversation_ttl"` // 会话空闲多久后开启新会话，默认 24h
+}
+
+// FastGPTConfig FastGPT 应用配置，base_url 如 https://fastgpt.example.com/api/v1
+// 以会话标识作为 chatId，由 FastGPT 保存会话上下文
+type FastGPTConfig struct {
+	APIKey    string            `yaml:"api_key"`   // 应用 API 密钥，fastgpt- 开头
+	Variables map[string]string `yaml:"variables"` // 应用定义的全局变量
+}
+
+// CanaryConfig 灰度 AI 后端配置
+// 按用户 ID 哈希分桶，Percent% 的用户固定路由到灰度后端；Groups/Users 中的会话始终走灰度
+type CanaryConfig struct {
+	Enabled bool          `yaml:"enabled"`
 	BaseURL string        `yaml:"base_url"`
 	Timeout time.Duration `yaml:"timeout"`
 	Retry   int           `yaml:"retry"`
+	Percent int           `yaml:"percent"` // 0-100
+	Groups  []string      `yaml:"groups"`
+	Users   []string      `yaml:"users"`
 }
 
 // LogConfig 日志配置
 type LogConfig struct {
-	Level  string `yaml:"level"`
-	Format string `yaml:"format"`
+	Level  string        `yaml:"level"`
+	Format string        `yaml:"format"`
+	Output string        `yaml:"output"` // stdout | file，默认 stdout
+	File   LogFileConfig `yaml:"file"`
+	// SecurityAudit 回调签名、corp_id、解密和重放校验失败的审计日志
+	SecurityAudit SecurityAuditConfig `yaml:"security_audit"`
+}
+
+// SecurityAuditConfig 安全审计日志配置，每条失败写一行 JSON，供 SIEM 采集
+type SecurityAuditConfig struct {
+	Enabled bool          `yaml:"enabled"`
+	Output  string        `yaml:"output"` // stdout | file，默认 stdout
+	File    LogFileConfig `yaml:"file"`
+}
+
+// LogFileConfig 日志文件及滚动策略，output 为 file 时生效
+type LogFileConfig struct {
+	Path       string `yaml:"path"`
+	MaxSizeMB  int    `yaml:"max_size_mb"`  // 单个文件达到该大小后滚动，默认 100
+	MaxBackups int    `yaml:"max_backups"`  // 保留的旧文件数，0 表示不限
+	MaxAgeDays int    `yaml:"max_age_days"` // 旧文件保留天数，0 表示不按时间清理
+	Compress   bool   `yaml:"compress"`     // gzip 压缩旧文件
+}
+
+// ShadowConfig 影子评估配置
+// 按 Percent% 采样复制请求到候选后端，候选回复只记录不投递；启用 archive 时对比记录同时写入归档库
+type ShadowConfig struct {
+	Enabled    bool          `yaml:"enabled"`
+	BaseURL    string        `yaml:"base_url"`
+	Timeout    time.Duration `yaml:"timeout"`
+	Retry      int           `yaml:"retry"`
+	Percent    int           `yaml:"percent"`     // 0-100
+	MaxRecords int           `yaml:"max_records"` // 内存中缓存的对比记录数，启动时从归档库载入
+}
+
+// TracingConfig OpenTelemetry 链路追踪配置
+type TracingConfig struct {
+	Enabled     bool    `yaml:"enabled"`
+	Endpoint    string  `yaml:"endpoint"`     // OTLP/HTTP 地址，如 http://otel-collector:4318
+	SampleRatio float64 `yaml:"sample_ratio"` // 0-1，对无上游采样决策的请求生效
+	ServiceName string  `yaml:"service_name"`
+}
+
+// OutboxConfig 失败转发 outbox 配置
+type OutboxConfig struct {
+	Enabled     bool          `yaml:"enabled"`
+	Path        string        `yaml:"path"`         // BoltDB 文件路径
+	Interval    time.Duration `yaml:"interval"`     // 重投周期，默认 30s
+	MaxAttempts int           `yaml:"max_attempts"` // 超过后停止自动重投，0 表示不限
+	BatchSize   int           `yaml:"batch_size"`   // 每轮最多重投条数，默认 20
+}
+
+// StoreConfig 共享状态存储配置
+type StoreConfig struct {
+	Driver string      `yaml:"driver"` // memory（默认）| redis
+	Redis  RedisConfig `yaml:"redis"`
+}
+
+// RedisConfig Redis 连接配置
+type RedisConfig struct {
+	Addr      string `yaml:"addr"`
+	Password  string `yaml:"password"`
+	DB        int    `yaml:"db"`
+	KeyPrefix string `yaml:"key_prefix"`
+}
+
+// 存储驱动常量
+const (
+	StoreDriverMemory = "memory"
+	StoreDriverRedis  = "redis"
+)
+
+// PluginConfig 自定义消息处理器插件配置
+type PluginConfig struct {
+	Name    string    `yaml:"name"`    // 插件注册名称
+	Options yaml.Node `yaml:"options"` // 插件自定义配置，由插件自行解码
 }
 
+// TransformConfig 消息变换规则配置
+// Inbound 在转发 AI 前作用于用户消息，Outbound 在发送前作用于 AI 回复
+type TransformConfig struct {
+	Inbound  []TransformRule `yaml:"inbound"`
+	Outbound []TransformRule `yaml:"outbound"`
+}
+
+// TransformRule 单条变换规则
+type TransformRule struct {
+	Type        string        `yaml:"type"`        // regex_replace | strip_prefix | emoji | expand_url
+	Pattern     string        `yaml:"pattern"`     // regex_replace: 正则表达式
+	Replacement string        `yaml:"replacement"` // regex_replace: 替换内容，支持 $1 引用
+	Prefixes    []string      `yaml:"prefixes"`    // strip_prefix: 待去除的前缀
+	Mode        string        `yaml:"mode"`        // emoji: strip | normalize
+	Hosts       []string      `yaml:"hosts"`       // expand_url: 允许展开的短链域名
+	Timeout     time.Duration `yaml:"timeout"`     // expand_url: 单次展开超时
+}
+
+// AutoReplyConfig 关键词自动回复，在转发 AI 前按顺序匹配，命中第一条规则即直接回复
+type AutoReplyConfig struct {
+	Rules []AutoReplyRule `yaml:"rules"`
+}
+
+// AutoReplyRule 单条自动回复规则，匹配经过入站变换后的消息内容
+type AutoReplyRule struct {
+	Match      string   `yaml:"match"`       // exact | prefix | regex
+	Keywords   []string `yaml:"keywords"`    // exact / prefix: 任一关键词命中即可
+	Pattern    string   `yaml:"pattern"`     // regex: 正则表达式
+	IgnoreCase bool     `yaml:"ignore_case"` // 忽略大小写
+	// Reply 回复模板（text/template），可用 {{.User}}、{{.Content}}、{{index .Groups 1}}（正则分组）
+	Reply string `yaml:"reply"`
+}
+
+// I18nConfig 系统回复（欢迎语、限流、拒绝、审核拦截、AI 失败提示）的多语言文案，按成员的语言选择
+// 默认语言的成员仍使用各功能配置的回复，未配置回复时使用内置文案
+type I18nConfig struct {
+	Enabled       bool   `yaml:"enabled"`
+	DefaultLocale string `yaml:"default_locale"` // 默认 zh-CN
+	// Users UserID 到语言的映射，优先于通讯录
+	Users map[string]string `yaml:"users"`
+	// ContactsAttr 通讯录中记录成员语言的自定义字段名，需配置 wework.secret
+	ContactsAttr string `yaml:"contacts_attr"`
+	// Messages 语言 → 文案键 → 文案模板（text/template，可用 {{.User}}），覆盖内置的 zh-CN、en-US 文案或新增语言
+	// 文案键：welcome、rate_limited、quota_exceeded、access_denied、input_blocked、output_blocked、ai_error，设为空字符串表示不回复
+	Messages map[string]map[string]string `yaml:"messages"`
+}
+
+// ScheduleConfig 定时消息，按 cron 表达式通过应用消息或群机器人发送，如站会提醒、周报提醒
+// jobs 与 /admin/schedules 创建的定时消息一起保存在 path 中，jobs 中的定时消息只能通过配置修改
+type ScheduleConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Driver  string `yaml:"driver"` // bolt（默认，本地文件）| redis（多副本共享，需 store.driver 为 redis）
+	Path    string `yaml:"path"`   // BoltDB 文件路径，默认 data/schedule.db
+	// MisfireGrace 触发时间已过多久仍补发，超过则跳过本次（如服务停机期间），默认 5m
+	MisfireGrace time.Duration       `yaml:"misfire_grace"`
+	Jobs         []ScheduleJobConfig `yaml:"jobs"`
+}
+
+// 定时消息存储驱动常量
+const (
+	ScheduleDriverBolt  = "bolt"
+	ScheduleDriverRedis = "redis"
+)
+
+// LeaderConfig 多副本选主，定时消息、会话存档拉取、告警和启动时的菜单同步只在主实例上运行
+// access_token 刷新已通过存储锁保证单实例拉取，outbox 重投和本地归档依赖本机文件，仍在每个实例上运行
+type LeaderConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Driver  string `yaml:"driver"` // store（默认，需 store.driver 为 redis）| kubernetes
+	Key     string `yaml:"key"`    // store 驱动的键名或 Lease 对象名称，默认 leader:wework-svc / wework-svc
+	// Namespace Lease 所在命名空间，默认 Pod 所在命名空间
+	Namespace string        `yaml:"namespace"`
+	TTL       time.Duration `yaml:"ttl"`      // 租期，每 ttl/3 续约一次，主实例宕机后最长 ttl 后由其他实例接手，默认 15s
+	Identity  string        `yaml:"identity"` // 实例标识，默认主机名加随机后缀
+}
+
+// 选主驱动常量
+const (
+	LeaderDriverStore      = "store"
+	LeaderDriverKubernetes = "kubernetes"
+)
+
+// ScheduleJobConfig 单条定时消息，接收者至少填写一项
+type ScheduleJobConfig struct {
+	Name     string   `yaml:"name"`
+	Cron     string   `yaml:"cron"`     // 五段式 cron 表达式（分 时 日 月 周）或 @daily 等
+	Timezone string   `yaml:"timezone"` // IANA 时区，如 Asia/Shanghai，默认本地时区
+	Agent    string   `yaml:"agent"`    // 发送应用，引用 wework.agents 或 tenants 的名称，为空时使用默认应用
+	ToUser   []string `yaml:"touser"`
+	ToParty  []string `yaml:"toparty"`
+	ToTag    []string `yaml:"totag"`
+	Webhooks []string `yaml:"webhooks"` // 发往群聊的群机器人名称，引用顶层 webhooks
+	MsgType  string   `yaml:"msgtype"`  // text（默认）| markdown
+	Content  string   `yaml:"content"`
+}
+
+// 自动回复匹配方式常量
+const (
+	AutoReplyExact  = "exact"
+	AutoReplyPrefix = "prefix"
+	AutoReplyRegex  = "regex"
+)
+
+// ModerationConfig 内容审核配置：用户消息转发 AI 前、AI 回复发送前检查敏感词，可选调用外部审核接口
+type ModerationConfig struct {
+	Enabled  bool                `yaml:"enabled"`
+	Words    []string            `yaml:"words"` // 敏感词，区分大小写
+	Mask     string              `yaml:"mask"`  // mask 时每个字符替换为该字符串，默认 *
+	Inbound  ModerationPolicy    `yaml:"inbound"`
+	Outbound ModerationPolicy    `yaml:"outbound"`
+	API      ModerationAPIConfig `yaml:"api"`
+}
+
+// ModerationPolicy 单个方向命中后的处理方式
+type ModerationPolicy struct {
+	Action string `yaml:"action"` // block | mask | flag，默认 block
+	Reply  string `yaml:"reply"`  // block 时回复给用户的提示，为空则不回复
+}
+
+// ModerationAPIConfig 外部审核接口，POST JSON {direction, user_id, content}，
+// 响应 {flagged, categories, content}，content 为可选的打码后内容
+type ModerationAPIConfig struct {
+	URL     string        `yaml:"url"` // 为空时仅使用敏感词
+	APIKey  string        `yaml:"api_key"`
+	Timeout time.Duration `yaml:"timeout"` // 默认 2s
+	// FailClosed 接口不可用时拦截，默认放行并仅使用敏感词结果
+	FailClosed bool `yaml:"fail_closed"`
+}
+
+// 内容审核处理方式常量
+const (
+	ModerationBlock = "block"
+	ModerationMask  = "mask"
+	ModerationFlag  = "flag"
+)
+
+// AdminConfig 管理端配置
+type AdminConfig struct {
+	OIDC OIDCConfig `yaml:"oidc"`
+	// APIKeys 供脚本和其他系统调用管理 API 的密钥
+	APIKeys []APIKeyConfig `yaml:"api_keys"`
+	// HMACWindow HMAC 签名请求允许的时间戳偏差，默认 5m
+	HMACWindow time.Duration `yaml:"hmac_window"`
+	Debug      DebugConfig   `yaml:"debug"`
+}
+
+// DebugConfig /debug 诊断接口，需 admin 角色和 debug 范围
+type DebugConfig struct {
+	// Enabled 开启 /debug/pprof、/debug/runtime 和 /debug/stream（实时回调事件，正文脱敏），需同时配置 oidc 或 api_keys
+	Enabled bool `yaml:"enabled"`
+}
+
+// APIKeyConfig 管理 API 密钥
+// 可直接放在 Authorization: Bearer 头中，或作为 HMAC 密钥对请求签名
+type APIKeyConfig struct {
+	ID       string   `yaml:"id"`
+	Secret   string   `yaml:"secret"`
+	Role     string   `yaml:"role"`      // viewer | operator | admin
+	Scopes   []string `yaml:"scopes"`    // 允许访问的范围，如 messages:send，"*" 表示不限
+	HMACOnly bool     `yaml:"hmac_only"` // 只接受 HMAC 签名请求，禁止明文传递密钥
+}
+
+// OAuthConfig 企业微信网页授权，内部 Web 工具经 /oauth/authorize 识别员工身份，可用的应用需配置 Secret
+type OAuthConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// CallbackURL 指向本服务的 /oauth/callback，域名须为应用的可信域名
+	CallbackURL string `yaml:"callback_url"`
+	// Secret 身份令牌的签名密钥，Web 工具持有同一密钥时可自行校验
+	Secret string `yaml:"secret"`
+	// IdentityTTL 身份令牌有效期，默认 5m
+	IdentityTTL time.Duration `yaml:"identity_ttl"`
+	// AllowedHosts 授权完成后允许回跳的 Web 工具域名
+	AllowedHosts []string `yaml:"allowed_hosts"`
+}
+
+// OIDCConfig 管理端 OIDC 单点登录配置
+type OIDCConfig struct {
+	Enabled       bool              `yaml:"enabled"`
+	IssuerURL     string            `yaml:"issuer_url"`
+	ClientID      string            `yaml:"client_id"`
+	ClientSecret  string            `yaml:"client_secret"`
+	RedirectURL   string            `yaml:"redirect_url"` // 需指向 /admin/oauth/callback
+	Scopes        []string          `yaml:"scopes"`
+	RoleClaim     string            `yaml:"role_claim"`   // 用于角色映射的 token 声明，默认 "roles"
+	RoleMapping   map[string]string `yaml:"role_mapping"` // 声明值 → viewer | operator | admin
+	SessionSecret string            `yaml:"session_secret"`
+	SessionTTL    time.Duration     `yaml:"session_ttl"`
+}
+
+// 变换规则类型常量
+const (
+	TransformRegexReplace = "regex_replace"
+	TransformStripPrefix  = "strip_prefix"
+	TransformEmoji        = "emoji"
+	TransformExpandURL    = "expand_url"
+)
+
 var alphanumericRegex = regexp.MustCompile(`^[a-zA-Z0-9]+$`)
 
+// agentNameRegex 应用名称用作 URL 路径段
+var agentNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
 // LoadConfig 从 YAML 文件加载并验证配置
-func LoadConfig(path string) (*Config, error) {
+// 文件中标量值的 ${VAR} 引用在解码前展开，解码后再用同名环境变量覆盖字段（见 applyEnvOverrides），
+// 然后依次执行 resolvers（如 secret:// 密钥引用），最后校验
+func LoadConfig(path string, resolvers ...ResolveFunc) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("read config file: %w", err)
 	}
 
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parse config file: %w", err)
+	}
+	expandEnv(&doc)
 	var cfg Config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
+	if err := doc.Decode(&cfg); err != nil {
 		return nil, fmt.Errorf("parse config file: %w", err)
 	}
+	expanded, err := yaml.Marshal(&doc)
+	if err != nil {
+		return nil, fmt.Errorf("marshal expanded config: %w", err)
+	}
+	sum := sha256.Sum256(expanded)
+	cfg.Hash = hex.EncodeToString(sum[:6])
+	if err := applyEnvOverrides(&cfg); err != nil {
+		return nil, fmt.Errorf("apply env overrides: %w", err)
+	}
+	for _, resolve := range resolvers {
+		if err := resolve(context.Background(), &cfg); err != nil {
+			return nil, fmt.Errorf("resolve config: %w", err)
+		}
+	}
 
 	if err := cfg.validate(); err != nil {
 		return nil, err
@@ -70,39 +1114,953 @@ func LoadConfig(path string) (*Config, error) {
 
 func (c *Config) validate() error {
 	// server.addr
-	if err := validateAddr(c.Server.Addr); err != nil {
+	if err := validateListenAddr(c.Server.Addr); err != nil {
 		return fmt.Errorf("server.addr: %w", err)
 	}
+	addrs := map[string]bool{c.Server.Addr: true}
+	for i, l := range c.Server.Listeners {
+		if err := validateListenAddr(l.Addr); err != nil {
+			return fmt.Errorf("server.listeners[%d].addr: %w", i, err)
+		}
+		if addrs[l.Addr] {
+			return fmt.Errorf("server.listeners[%d].addr: duplicate address %q", i, l.Addr)
+		}
+		addrs[l.Addr] = true
+		if l.TLS && !c.Server.TLS.Enabled {
+			return fmt.Errorf("server.listeners[%d].tls: requires server.tls.enabled", i)
+		}
+	}
+	if c.Server.MaxBodyBytes < 0 {
+		return fmt.Errorf("server.max_body_bytes: must not be negative")
+	}
+	if b := c.Server.BasePath; b != "" && (!strings.HasPrefix(b, "/") || strings.HasSuffix(b, "/")) {
+		return fmt.Errorf("server.base_path: must start with / and not end with /, got %q", b)
+	}
+	if _, err := ParsePrefixes(c.Server.TrustedProxies); err != nil {
+		return fmt.Errorf("server.trusted_proxies: %w", err)
+	}
+	if a := c.Server.CallbackAllowlist; a.Enabled {
+		if _, err := ParsePrefixes(a.Static); err != nil {
+			return fmt.Errorf("server.callback_allowlist.static: %w", err)
+		}
+		if len(a.Static) == 0 && c.WeWork.Secret == "" {
+			return fmt.Errorf("server.callback_allowlist: requires static or wework.secret to fetch callback ips")
+		}
+	}
+	if c.Server.ReadHeaderTimeout < 0 || c.Server.IdleTimeout < 0 || c.Server.MaxHeaderBytes < 0 {
+		return fmt.Errorf("server: read_header_timeout, idle_timeout and max_header_bytes must not be negative")
+	}
+	for i, rt := range c.Server.RouteTimeouts {
+		if !strings.HasPrefix(rt.Prefix, "/") {
+			return fmt.Errorf("server.route_timeouts[%d].prefix: must start with /, got %q", i, rt.Prefix)
+		}
+		if rt.Timeout < 0 {
+			return fmt.Errorf("server.route_timeouts[%d].timeout: must not be negative", i)
+		}
+	}
+	if err := c.Server.TLS.validate(); err != nil {
+		return fmt.Errorf("server.tls.%w", err)
+	}
+	if err := c.Server.Backpressure.validate(); err != nil {
+		return fmt.Errorf("server.backpressure.%w", err)
+	}
 
-	// wework.corp_id
-	if c.WeWork.CorpID == "" {
-		return fmt.Errorf("wework.corp_id: must not be empty")
+	// log
+	if err := c.Log.validate(); err != nil {
+		return fmt.Errorf("log.%w", err)
 	}
 
-	// wework.token
-	if c.WeWork.Token == "" {
-		return fmt.Errorf("wework.token: must not be empty")
+	// wework
+	if err := c.WeWork.validate(); err != nil {
+		return fmt.Errorf("wework.%w", err)
+	}
+
+	// webhooks
+	for name, w := range c.Webhooks {
+		if err := validateBaseURL(w.URL); err != nil {
+			return fmt.Errorf("webhooks.%s.url: %w", name, err)
+		}
+	}
+	if c.Alert.Enabled {
+		if c.Alert.Webhook == "" {
+			return fmt.Errorf("alert.webhook: must not be empty")
+		}
+		if err := c.checkWebhook("alert.webhook", c.Alert.Webhook); err != nil {
+			return err
+		}
+		if c.Alert.DLQThreshold < 0 || c.Alert.SignatureFailures < 0 {
+			return fmt.Errorf("alert: dlq_threshold and signature_failures must not be negative")
+		}
+	}
+	if c.ErrorReport.Enabled {
+		switch c.ErrorReport.Driver {
+		case "sentry":
+			if err := validateBaseURL(c.ErrorReport.DSN); err != nil {
+				return fmt.Errorf("error_report.dsn: %w", err)
+			}
+		case "webhook":
+			if err := validateBaseURL(c.ErrorReport.URL); err != nil {
+				return fmt.Errorf("error_report.url: %w", err)
+			}
+		default:
+			return fmt.Errorf("error_report.driver: must be sentry or webhook, got %q", c.ErrorReport.Driver)
+		}
+		if c.ErrorReport.QueueSize < 0 {
+			return fmt.Errorf("error_report.queue_size: must not be negative")
+		}
 	}
-	if len(c.WeWork.Token) > 32 {
-		return fmt.Errorf("wework.token: must be at most 32 characters, got %d", len(c.WeWork.Token))
+	if err := c.checkWebhook("wework.reply_webhook", c.WeWork.ReplyWebhook); err != nil {
+		return err
 	}
-	if !alphanumericRegex.MatchString(c.WeWork.Token) {
-		return fmt.Errorf("wework.token: must contain only alphanumeric characters")
+	for i, t := range c.Tenants {
+		if err := c.checkWebhook(fmt.Sprintf("tenants[%d].wework.reply_webhook", i), t.WeWork.ReplyWebhook); err != nil {
+			return err
+		}
 	}
 
-	// wework.encoding_aes_key
-	if len(c.WeWork.EncodingAESKey) != 43 {
-		return fmt.Errorf("wework.encoding_aes_key: must be exactly 43 characters, got %d", len(c.WeWork.EncodingAESKey))
+	// tenants，名称与 wework.agents 共用 /callback/{name} 路径空间
+	paths := make(map[string]bool)
+	for _, a := range c.WeWork.Agents {
+		paths[a.Name] = true
 	}
-	if !alphanumericRegex.MatchString(c.WeWork.EncodingAESKey) {
-		return fmt.Errorf("wework.encoding_aes_key: must contain only alphanumeric characters")
+	for i, t := range c.Tenants {
+		if !agentNameRegex.MatchString(t.Name) {
+			return fmt.Errorf("tenants[%d].name: must match %s, got %q", i, agentNameRegex, t.Name)
+		}
+		if paths[t.Name] {
+			return fmt.Errorf("tenants[%d].name: duplicate callback path %q", i, t.Name)
+		}
+		paths[t.Name] = true
+		if len(t.WeWork.Agents) > 0 {
+			return fmt.Errorf("tenants[%d].wework.agents: not supported for tenants", i)
+		}
+		if err := t.WeWork.validate(); err != nil {
+			return fmt.Errorf("tenants[%d].wework.%w", i, err)
+		}
+		if t.AI != nil {
+			if err := validateBaseURL(t.AI.BaseURL); err != nil {
+				return fmt.Errorf("tenants[%d].ai.base_url: %w", i, err)
+			}
+			if err := t.AI.validateProvider(); err != nil {
+				return fmt.Errorf("tenants[%d].ai.%w", i, err)
+			}
+			if err := t.AI.validateFallbacks(); err != nil {
+				return fmt.Errorf("tenants[%d].ai.%w", i, err)
+			}
+		}
 	}
 
 	// ai.base_url
 	if err := validateBaseURL(c.AI.BaseURL); err != nil {
 		return fmt.Errorf("ai.base_url: %w", err)
 	}
+	if c.AI.Retry < 0 || c.AI.RetryBaseDelay < 0 || c.AI.RetryMaxDelay < 0 {
+		return fmt.Errorf("ai: retry, retry_base_delay and retry_max_delay must not be negative")
+	}
+	if err := c.AI.validateProvider(); err != nil {
+		return fmt.Errorf("ai.%w", err)
+	}
+	if err := c.AI.validateFallbacks(); err != nil {
+		return fmt.Errorf("ai.%w", err)
+	}
+
+	// ai.canary
+	if c.AI.Canary.Enabled {
+		if err := validateBaseURL(c.AI.Canary.BaseURL); err != nil {
+			return fmt.Errorf("ai.canary.base_url: %w", err)
+		}
+		if c.AI.Canary.Percent < 0 || c.AI.Canary.Percent > 100 {
+			return fmt.Errorf("ai.canary.percent: must be between 0 and 100, got %d", c.AI.Canary.Percent)
+		}
+	}
+
+	// ai.shadow
+	if c.AI.Shadow.Enabled {
+		if err := validateBaseURL(c.AI.Shadow.BaseURL); err != nil {
+			return fmt.Errorf("ai.shadow.base_url: %w", err)
+		}
+		if c.AI.Shadow.Percent < 0 || c.AI.Shadow.Percent > 100 {
+			return fmt.Errorf("ai.shadow.percent: must be between 0 and 100, got %d", c.AI.Shadow.Percent)
+		}
+	}
+
+	// tracing
+	if c.Tracing.Enabled {
+		if err := validateBaseURL(c.Tracing.Endpoint); err != nil {
+			return fmt.Errorf("tracing.endpoint: %w", err)
+		}
+		if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+			return fmt.Errorf("tracing.sample_ratio: must be between 0 and 1, got %v", c.Tracing.SampleRatio)
+		}
+	}
+
+	// store
+	switch c.Store.Driver {
+	case "", StoreDriverMemory:
+	case StoreDriverRedis:
+		if err := validateAddr(c.Store.Redis.Addr); err != nil {
+			return fmt.Errorf("store.redis.addr: %w", err)
+		}
+	default:
+		return fmt.Errorf("store.driver: must be memory or redis, got %q", c.Store.Driver)
+	}
+
+	// outbox
+	if c.Outbox.Enabled && c.Outbox.Path == "" {
+		return fmt.Errorf("outbox.path: must not be empty")
+	}
+
+	// response_cache
+	if c.ResponseCache.Enabled {
+		rc := c.ResponseCache
+		if rc.TTL < 0 || rc.MaxEntries < 0 {
+			return fmt.Errorf("response_cache: ttl and max_entries must not be negative")
+		}
+		if rc.Similarity < 0 || rc.Similarity > 1 {
+			return fmt.Errorf("response_cache.similarity: must be between 0 and 1, got %v", rc.Similarity)
+		}
+		if rc.Embedding.BaseURL != "" {
+			if err := validateBaseURL(rc.Embedding.BaseURL); err != nil {
+				return fmt.Errorf("response_cache.embedding.base_url: %w", err)
+			}
+			if rc.Embedding.Model == "" {
+				return fmt.Errorf("response_cache.embedding.model: must not be empty")
+			}
+		}
+	}
+
+	// archive
+	if c.Archive.Enabled {
+		if c.Archive.Driver != ArchiveDriverSQLite && c.Archive.Driver != ArchiveDriverPostgres {
+			return fmt.Errorf("archive.driver: must be sqlite or postgres, got %q", c.Archive.Driver)
+		}
+		if c.Archive.DSN == "" {
+			return fmt.Errorf("archive.dsn: must not be empty")
+		}
+		if c.Archive.Retention < 0 {
+			return fmt.Errorf("archive.retention: must not be negative")
+		}
+	}
+	if m := c.Archive.MsgAudit; m.Enabled {
+		if !c.Archive.Enabled {
+			return fmt.Errorf("archive.msgaudit: requires archive.enabled")
+		}
+		if err := validateBaseURL(m.BridgeURL); err != nil {
+			return fmt.Errorf("archive.msgaudit.bridge_url: %w", err)
+		}
+		if len(m.PrivateKeys) == 0 {
+			return fmt.Errorf("archive.msgaudit.private_keys: must not be empty")
+		}
+		if m.BatchSize < 0 || m.BatchSize > 1000 {
+			return fmt.Errorf("archive.msgaudit.batch_size: must be between 0 and 1000, got %d", m.BatchSize)
+		}
+	}
+
+	// publish
+	if p := c.Publish; p.Enabled {
+		switch p.Driver {
+		case PublishDriverNATS:
+			u, err := url.Parse(p.NATS.URL)
+			if err != nil || (u.Scheme != "nats" && u.Scheme != "tls") || u.Host == "" {
+				return fmt.Errorf("publish.nats.url: must be a nats:// or tls:// url, got %q", p.NATS.URL)
+			}
+		case PublishDriverKafka:
+			if err := validateBaseURL(p.Kafka.RESTURL); err != nil {
+				return fmt.Errorf("publish.kafka.rest_url: %w", err)
+			}
+		default:
+			return fmt.Errorf("publish.driver: must be nats or kafka, got %q", p.Driver)
+		}
+		if p.QueueSize < 0 {
+			return fmt.Errorf("publish.queue_size: must not be negative")
+		}
+	}
+
+	// grpc
+	if g := c.GRPC; g.Enabled {
+		if g.Addr != "" {
+			if err := validateAddr(g.Addr); err != nil {
+				return fmt.Errorf("grpc.addr: %w", err)
+			}
+		}
+		if len(g.Tokens) == 0 || slices.Contains(g.Tokens, "") {
+			return fmt.Errorf("grpc.tokens: must not be empty")
+		}
+		if g.BufferSize < 0 {
+			return fmt.Errorf("grpc.buffer_size: must not be negative")
+		}
+		if (g.CertFile == "") != (g.KeyFile == "") {
+			return fmt.Errorf("grpc.cert_file, grpc.key_file: must be set together")
+		}
+	}
+
+	// transform
+	for i, r := range c.Transform.Inbound {
+		if err := r.validate(); err != nil {
+			return fmt.Errorf("transform.inbound[%d]: %w", i, err)
+		}
+	}
+	for i, r := range c.Transform.Outbound {
+		if err := r.validate(); err != nil {
+			return fmt.Errorf("transform.outbound[%d]: %w", i, err)
+		}
+	}
+
+	// auto_reply
+	for i, r := range c.AutoReply.Rules {
+		if err := r.validate(); err != nil {
+			return fmt.Errorf("auto_reply.rules[%d]: %w", i, err)
+		}
+	}
+
+	// moderation
+	if err := c.Moderation.validate(); err != nil {
+		return fmt.Errorf("moderation.%w", err)
+	}
+
+	// schedule
+	if c.Schedule.Enabled {
+		switch c.Schedule.Driver {
+		case "", ScheduleDriverBolt:
+			// 本地文件只有主实例的调度器会读取，其他副本上通过管理 API 的修改不会触发
+			if c.Leader.Enabled {
+				return fmt.Errorf("schedule.driver: leader election requires redis so schedules are shared across replicas")
+			}
+		case ScheduleDriverRedis:
+			if c.Store.Driver != StoreDriverRedis {
+				return fmt.Errorf("schedule.driver: redis requires store.driver redis")
+			}
+		default:
+			return fmt.Errorf("schedule.driver: must be bolt or redis, got %q", c.Schedule.Driver)
+		}
+		names := make(map[string]bool, len(c.Schedule.Jobs))
+		for i, j := range c.Schedule.Jobs {
+			if j.Name == "" {
+				return fmt.Errorf("schedule.jobs[%d].name: must not be empty", i)
+			}
+			if names[j.Name] {
+				return fmt.Errorf("schedule.jobs[%d].name: duplicate name %q", i, j.Name)
+			}
+			names[j.Name] = true
+			for _, w := range j.Webhooks {
+				if err := c.checkWebhook(fmt.Sprintf("schedule.jobs[%d].webhooks", i), w); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	// leader
+	if c.Leader.Enabled {
+		switch c.Leader.Driver {
+		case "", LeaderDriverStore:
+			if c.Store.Driver != StoreDriverRedis {
+				return fmt.Errorf("leader.driver: store requires store.driver redis")
+			}
+		case LeaderDriverKubernetes:
+		default:
+			return fmt.Errorf("leader.driver: must be store or kubernetes, got %q", c.Leader.Driver)
+		}
+		if c.Leader.TTL != 0 && c.Leader.TTL < 3*time.Second {
+			return fmt.Errorf("leader.ttl: must be at least 3s, got %s", c.Leader.TTL)
+		}
+	}
+
+	// i18n
+	if c.I18n.Enabled {
+		if c.I18n.ContactsAttr != "" && c.WeWork.Secret == "" {
+			return fmt.Errorf("i18n.contacts_attr: requires wework.secret")
+		}
+		for locale, msgs := range c.I18n.Messages {
+			for key, text := range msgs {
+				if _, err := template.New(key).Parse(text); err != nil {
+					return fmt.Errorf("i18n.messages.%s.%s: invalid template: %w", locale, key, err)
+				}
+			}
+		}
+	}
+
+	// suite
+	if c.Suite.Enabled {
+		if c.Suite.SuiteID == "" {
+			return fmt.Errorf("suite.suite_id: must not be empty")
+		}
+		if c.Suite.Secret == "" {
+			return fmt.Errorf("suite.secret: must not be empty")
+		}
+		if err := validateCallbackKeys(c.Suite.Token, c.Suite.EncodingAESKey); err != nil {
+			return fmt.Errorf("suite.%w", err)
+		}
+	}
+
+	// plugins
+	for i, p := range c.Plugins {
+		if p.Name == "" {
+			return fmt.Errorf("plugins[%d].name: must not be empty", i)
+		}
+	}
+
+	// admin.oidc
+	if c.Admin.OIDC.Enabled {
+		if err := c.Admin.OIDC.validate(); err != nil {
+			return fmt.Errorf("admin.oidc.%w", err)
+		}
+	}
+
+	// oauth
+	if c.OAuth.Enabled {
+		if err := validateBaseURL(c.OAuth.CallbackURL); err != nil {
+			return fmt.Errorf("oauth.callback_url: %w", err)
+		}
+		if len(c.OAuth.Secret) < 32 {
+			return fmt.Errorf("oauth.secret: must be at least 32 characters, got %d", len(c.OAuth.Secret))
+		}
+		if len(c.OAuth.AllowedHosts) == 0 {
+			return fmt.Errorf("oauth.allowed_hosts: must not be empty")
+		}
+	}
+
+	// admin.api_keys
+	keyIDs := make(map[string]bool, len(c.Admin.APIKeys))
+	for i, k := range c.Admin.APIKeys {
+		if err := k.validate(); err != nil {
+			return fmt.Errorf("admin.api_keys[%d].%w", i, err)
+		}
+		if keyIDs[k.ID] {
+			return fmt.Errorf("admin.api_keys[%d].id: duplicate id %q", i, k.ID)
+		}
+		keyIDs[k.ID] = true
+	}
+
+	// admin.debug
+	if c.Admin.Debug.Enabled && !c.Admin.OIDC.Enabled && len(c.Admin.APIKeys) == 0 {
+		return fmt.Errorf("admin.debug.enabled: requires admin.oidc or admin.api_keys")
+	}
+
+	return nil
+}
+
+func (o OIDCConfig) validate() error {
+	if err := validateBaseURL(o.IssuerURL); err != nil {
+		return fmt.Errorf("issuer_url: %w", err)
+	}
+	if o.ClientID == "" {
+		return fmt.Errorf("client_id: must not be empty")
+	}
+	if err := validateBaseURL(o.RedirectURL); err != nil {
+		return fmt.Errorf("redirect_url: %w", err)
+	}
+	if len(o.SessionSecret) < 32 {
+		return fmt.Errorf("session_secret: must be at least 32 characters, got %d", len(o.SessionSecret))
+	}
+	if len(o.RoleMapping) == 0 {
+		return fmt.Errorf("role_mapping: must not be empty")
+	}
+	for claim, role := range o.RoleMapping {
+		switch role {
+		case "viewer", "operator", "admin":
+		default:
+			return fmt.Errorf("role_mapping[%s]: unknown role %q", claim, role)
+		}
+	}
+	return nil
+}
+
+func (k APIKeyConfig) validate() error {
+	if k.ID == "" {
+		return fmt.Errorf("id: must not be empty")
+	}
+	if len(k.Secret) < 32 {
+		return fmt.Errorf("secret: must be at least 32 characters, got %d", len(k.Secret))
+	}
+	switch k.Role {
+	case "viewer", "operator", "admin":
+	default:
+		return fmt.Errorf("role: unknown role %q", k.Role)
+	}
+	if len(k.Scopes) == 0 {
+		return fmt.Errorf("scopes: must not be empty")
+	}
+	return nil
+}
+
+func (m ModerationConfig) validate() error {
+	if !m.Enabled {
+		return nil
+	}
+	if len(m.Words) == 0 && m.API.URL == "" {
+		return fmt.Errorf("words: must not be empty when api.url is not set")
+	}
+	for i, w := range m.Words {
+		if w == "" {
+			return fmt.Errorf("words[%d]: must not be empty", i)
+		}
+	}
+	for name, p := range map[string]ModerationPolicy{"inbound": m.Inbound, "outbound": m.Outbound} {
+		switch p.Action {
+		case "", ModerationBlock, ModerationMask, ModerationFlag:
+		default:
+			return fmt.Errorf("%s.action: must be block, mask or flag, got %q", name, p.Action)
+		}
+	}
+	if m.API.URL != "" {
+		if err := validateBaseURL(m.API.URL); err != nil {
+			return fmt.Errorf("api.url: %w", err)
+		}
+	}
+	if m.API.Timeout < 0 {
+		return fmt.Errorf("api.timeout: must not be negative")
+	}
+	return nil
+}
+
+func (r AutoReplyRule) validate() error {
+	switch r.Match {
+	case AutoReplyExact, AutoReplyPrefix:
+		if len(r.Keywords) == 0 {
+			return fmt.Errorf("keywords must not be empty")
+		}
+	case AutoReplyRegex:
+		if r.Pattern == "" {
+			return fmt.Errorf("pattern must not be empty")
+		}
+		if _, err := regexp.Compile(r.Pattern); err != nil {
+			return fmt.Errorf("invalid pattern: %w", err)
+		}
+	default:
+		return fmt.Errorf("match: must be exact, prefix or regex, got %q", r.Match)
+	}
+	if r.Reply == "" {
+		return fmt.Errorf("reply must not be empty")
+	}
+	if _, err := template.New("reply").Parse(r.Reply); err != nil {
+		return fmt.Errorf("invalid reply template: %w", err)
+	}
+	return nil
+}
+
+func (r TransformRule) validate() error {
+	switch r.Type {
+	case TransformRegexReplace:
+		if r.Pattern == "" {
+			return fmt.Errorf("pattern must not be empty")
+		}
+		if _, err := regexp.Compile(r.Pattern); err != nil {
+			return fmt.Errorf("invalid pattern: %w", err)
+		}
+	case TransformStripPrefix:
+		if len(r.Prefixes) == 0 {
+			return fmt.Errorf("prefixes must not be empty")
+		}
+	case TransformEmoji:
+		if r.Mode != "strip" && r.Mode != "normalize" {
+			return fmt.Errorf("mode must be strip or normalize, got %q", r.Mode)
+		}
+	case TransformExpandURL:
+		if len(r.Hosts) == 0 {
+			return fmt.Errorf("hosts must not be empty")
+		}
+	default:
+		return fmt.Errorf("unknown type %q", r.Type)
+	}
+	return nil
+}
+
+// checkWebhook 校验引用的群机器人名称已在 webhooks 中定义，name 为空表示未引用
+func (c *Config) checkWebhook(field, name string) error {
+	if name == "" {
+		return nil
+	}
+	if _, ok := c.Webhooks[name]; !ok {
+		return fmt.Errorf("%s: webhook %q is not defined in webhooks", field, name)
+	}
+	return nil
+}
+
+// validate 校验企业微信配置，错误信息以字段名开头，由调用方补充前缀
+func (w WeWorkConfig) validate() error {
+	// corp_id
+	if w.CorpID == "" {
+		return fmt.Errorf("corp_id: must not be empty")
+	}
+
+	// token / encoding_aes_key
+	if err := validateCallbackKeys(w.Token, w.EncodingAESKey); err != nil {
+		return err
+	}
+	if err := validateRotationKeys(w.EncodingAESKeys); err != nil {
+		return err
+	}
+	if err := w.Mention.validate(); err != nil {
+		return err
+	}
+	for kw, name := range w.Commands.Keywords {
+		if strings.TrimSpace(kw) == "" || name == "" {
+			return fmt.Errorf("commands.keywords: keyword and command name must not be empty")
+		}
+	}
+	if err := w.Redaction.validate(); err != nil {
+		return err
+	}
+	if w.RateLimit.PerMinute < 0 || w.RateLimit.Daily < 0 {
+		return fmt.Errorf("rate_limit: per_minute and daily must not be negative")
+	}
+	if w.Media.MaxBytes < 0 {
+		return fmt.Errorf("media.max_bytes: must not be negative")
+	}
+	if (w.Media.Vision || w.Media.ExtractText || w.Media.ReplyMedia) && w.Secret == "" {
+		return fmt.Errorf("media: vision, extract_text and reply_media require secret")
+	}
+	switch w.ReplyFormat.Format {
+	case "", "raw", "text", "markdown":
+	default:
+		return fmt.Errorf("reply_format.format: must be raw, text or markdown, got %q", w.ReplyFormat.Format)
+	}
+	if w.ReplyFormat.MaxBytes != 0 && w.ReplyFormat.MaxBytes < 256 {
+		return fmt.Errorf("reply_format.max_bytes: must be at least 256, got %d", w.ReplyFormat.MaxBytes)
+	}
+	if w.ContactsProfile && w.Secret == "" {
+		return fmt.Errorf("contacts_profile: requires secret")
+	}
+	if w.Welcome.Message != "" {
+		if w.Secret == "" {
+			return fmt.Errorf("welcome: requires secret")
+		}
+		if _, err := template.New("welcome").Parse(w.Welcome.Message); err != nil {
+			return fmt.Errorf("welcome.message: %w", err)
+		}
+	}
+	if w.Actions.Enabled() && w.Secret == "" {
+		return fmt.Errorf("actions: requires secret")
+	}
+	if w.Actions.SendFile && len(w.Media.ReplyHosts) == 0 {
+		return fmt.Errorf("actions.send_file: requires media.reply_hosts")
+	}
+	if w.Calendar.Enabled && w.Secret == "" {
+		return fmt.Errorf("calendar: requires secret")
+	}
+	if w.Calendar.Timezone != "" {
+		if _, err := time.LoadLocation(w.Calendar.Timezone); err != nil {
+			return fmt.Errorf("calendar.timezone: %w", err)
+		}
+	}
+	if w.Approval.Enabled && w.Secret == "" {
+		return fmt.Errorf("approval: requires secret")
+	}
+	if w.JSAPI.Enabled {
+		if w.Secret == "" {
+			return fmt.Errorf("jsapi: requires secret")
+		}
+		if len(w.JSAPI.AllowedHosts) == 0 {
+			return fmt.Errorf("jsapi.allowed_hosts: must not be empty")
+		}
+	}
+	if w.Approval.SummaryChars < 0 {
+		return fmt.Errorf("approval.summary_chars: must not be negative")
+	}
+	if w.ExternalContact.Enabled() && w.Secret == "" {
+		return fmt.Errorf("external_contact: requires secret")
+	}
+	if err := w.ExternalContact.validate(); err != nil {
+		return fmt.Errorf("external_contact.%w", err)
+	}
+	if len(w.Menu.Buttons) > 0 && w.Secret == "" {
+		return fmt.Errorf("menu: requires secret")
+	}
+	if err := w.Menu.validate(); err != nil {
+		return fmt.Errorf("menu: %w", err)
+	}
+	if w.Media.MaxTextChars < 0 {
+		return fmt.Errorf("media.max_text_chars: must not be negative")
+	}
+	if (len(w.Access.AllowDepartments) > 0 || len(w.Access.BlockDepartments) > 0) && w.Secret == "" {
+		return fmt.Errorf("access: department rules require secret")
+	}
 
+	// agents
+	agentNames := make(map[string]bool, len(w.Agents))
+	for i, a := range w.Agents {
+		if !agentNameRegex.MatchString(a.Name) {
+			return fmt.Errorf("agents[%d].name: must match %s, got %q", i, agentNameRegex, a.Name)
+		}
+		if agentNames[a.Name] {
+			return fmt.Errorf("agents[%d].name: duplicate %q", i, a.Name)
+		}
+		agentNames[a.Name] = true
+		merged := w.ForAgent(a)
+		if err := validateCallbackKeys(merged.Token, merged.EncodingAESKey); err != nil {
+			return fmt.Errorf("agents[%d].%w", i, err)
+		}
+		if err := validateRotationKeys(merged.EncodingAESKeys); err != nil {
+			return fmt.Errorf("agents[%d].%w", i, err)
+		}
+		if err := merged.Mention.validate(); err != nil {
+			return fmt.Errorf("agents[%d].%w", i, err)
+		}
+		if a.AI != nil {
+			if err := validateBaseURL(a.AI.BaseURL); err != nil {
+				return fmt.Errorf("agents[%d].ai.base_url: %w", i, err)
+			}
+			if err := a.AI.validateProvider(); err != nil {
+				return fmt.Errorf("agents[%d].ai.%w", i, err)
+			}
+			if err := a.AI.validateFallbacks(); err != nil {
+				return fmt.Errorf("agents[%d].ai.%w", i, err)
+			}
+		}
+	}
+
+	routeNames := make(map[string]bool)
+	for i, r := range w.Routes {
+		if r.Name == "" || routeNames[r.Name] {
+			return fmt.Errorf("routes[%d].name: must be unique and not empty", i)
+		}
+		routeNames[r.Name] = true
+		if len(r.Users) == 0 && len(r.Departments) == 0 && len(r.Tags) == 0 {
+			return fmt.Errorf("routes[%d]: at least one of users, departments, tags is required", i)
+		}
+		if (len(r.Departments) > 0 || len(r.Tags) > 0) && w.Secret == "" {
+			return fmt.Errorf("routes[%d]: department and tag rules require secret", i)
+		}
+		if r.AI != nil {
+			if err := validateBaseURL(r.AI.BaseURL); err != nil {
+				return fmt.Errorf("routes[%d].ai.base_url: %w", i, err)
+			}
+			if err := r.AI.validateProvider(); err != nil {
+				return fmt.Errorf("routes[%d].ai.%w", i, err)
+			}
+		}
+	}
+
+	// kf
+	if w.KF.Enabled && w.KF.Secret == "" && w.Secret == "" {
+		return fmt.Errorf("kf.secret: must not be empty when secret is not set")
+	}
+
+	// api_base_url
+	if w.APIBaseURL != "" {
+		if err := validateBaseURL(w.APIBaseURL); err != nil {
+			return fmt.Errorf("api_base_url: %w", err)
+		}
+	}
+
+	// reply_mode
+	switch w.ReplyMode {
+	case "", ReplyModeAsync, ReplyModePassive:
+	default:
+		return fmt.Errorf("reply_mode: must be async or passive, got %q", w.ReplyMode)
+	}
+	if w.PassiveTimeout >= 5*time.Second {
+		return fmt.Errorf("passive_timeout: must be less than 5s, got %s", w.PassiveTimeout)
+	}
+	return nil
+}
+
+// validateCallbackKeys 校验回调 Token 与 EncodingAESKey，错误信息以字段名开头
+func validateCallbackKeys(token, aesKey string) error {
+	if token == "" {
+		return fmt.Errorf("token: must not be empty")
+	}
+	if len(token) > 32 {
+		return fmt.Errorf("token: must be at most 32 characters, got %d", len(token))
+	}
+	if !alphanumericRegex.MatchString(token) {
+		return fmt.Errorf("token: must contain only alphanumeric characters")
+	}
+	if len(aesKey) != 43 {
+		return fmt.Errorf("encoding_aes_key: must be exactly 43 characters, got %d", len(aesKey))
+	}
+	if !alphanumericRegex.MatchString(aesKey) {
+		return fmt.Errorf("encoding_aes_key: must contain only alphanumeric characters")
+	}
+	return nil
+}
+
+func (r RedactionConfig) validate() error {
+	if !r.Enabled {
+		return nil
+	}
+	for i, k := range r.Kinds {
+		switch k {
+		case RedactPhone, RedactIDCard, RedactEmail:
+		case RedactEmployeeID:
+			if r.EmployeeIDPattern == "" {
+				return fmt.Errorf("redaction.kinds[%d]: employee_id requires employee_id_pattern", i)
+			}
+		default:
+			return fmt.Errorf("redaction.kinds[%d]: must be phone, id_card, email or employee_id, got %q", i, k)
+		}
+	}
+	if r.EmployeeIDPattern != "" {
+		if _, err := regexp.Compile(r.EmployeeIDPattern); err != nil {
+			return fmt.Errorf("redaction.employee_id_pattern: %w", err)
+		}
+	}
+	return nil
+}
+
+func (m MentionConfig) validate() error {
+	for i, name := range m.Names {
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("mention.names[%d]: must not be empty", i)
+		}
+	}
+	if m.Pattern != "" {
+		if _, err := regexp.Compile(m.Pattern); err != nil {
+			return fmt.Errorf("mention.pattern: %w", err)
+		}
+	}
+	return nil
+}
+
+// validateRotationKeys 校验轮换密钥列表
+func validateRotationKeys(keys []string) error {
+	for i, k := range keys {
+		if len(k) != 43 || !alphanumericRegex.MatchString(k) {
+			return fmt.Errorf("encoding_aes_keys[%d]: must be exactly 43 alphanumeric characters", i)
+		}
+	}
+	return nil
+}
+
+func (a AIConfig) validateProvider() error {
+	switch a.Provider {
+	case "", AIProviderAssistant:
+		return nil
+	case AIProviderOpenAI:
+		if a.OpenAI.Model == "" && a.OpenAI.APIVersion == "" {
+			return fmt.Errorf("openai.model: must not be empty")
+		}
+		if t := a.OpenAI.Temperature; t != nil && (*t < 0 || *t > 2) {
+			return fmt.Errorf("openai.temperature: must be between 0 and 2, got %v", *t)
+		}
+		if a.OpenAI.MaxTokens < 0 {
+			return fmt.Errorf("openai.max_tokens: must not be negative")
+		}
+	case AIProviderOllama:
+		if a.Ollama.Model == "" {
+			return fmt.Errorf("ollama.model: must not be empty")
+		}
+		if t := a.Ollama.Temperature; t != nil && *t < 0 {
+			return fmt.Errorf("ollama.temperature: must not be negative, got %v", *t)
+		}
+	case AIProviderDify:
+		if a.Dify.APIKey == "" {
+			return fmt.Errorf("dify.api_key: must not be empty")
+		}
+		if a.Dify.ConversationTTL < 0 {
+			return fmt.Errorf("dify.conversation_ttl: must not be negative")
+		}
+	case AIProviderFastGPT:
+		if a.FastGPT.APIKey == "" {
+			return fmt.Errorf("fastgpt.api_key: must not be empty")
+		}
+	default:
+		return fmt.Errorf("provider: must be assistant, openai, ollama, dify or fastgpt, got %q", a.Provider)
+	}
+	return nil
+}
+
+// validateFallbacks 校验备用后端，名称需唯一且不能为 primary
+func (a AIConfig) validateFallbacks() error {
+	if a.Breaker.Failures < 0 || a.Breaker.Cooldown < 0 {
+		return fmt.Errorf("breaker: failures and cooldown must not be negative")
+	}
+	seen := map[string]bool{AIFallbackPrimary: true}
+	for i, f := range a.Fallbacks {
+		if f.Name == "" {
+			return fmt.Errorf("fallbacks[%d].name: must not be empty", i)
+		}
+		if seen[f.Name] {
+			return fmt.Errorf("fallbacks[%d].name: duplicate or reserved name %q", i, f.Name)
+		}
+		seen[f.Name] = true
+		if err := validateBaseURL(f.BaseURL); err != nil {
+			return fmt.Errorf("fallbacks[%d].base_url: %w", i, err)
+		}
+		if f.Retry < 0 || f.RetryBaseDelay < 0 || f.RetryMaxDelay < 0 {
+			return fmt.Errorf("fallbacks[%d]: retry, retry_base_delay and retry_max_delay must not be negative", i)
+		}
+		if f.Breaker.Failures < 0 || f.Breaker.Cooldown < 0 {
+			return fmt.Errorf("fallbacks[%d].breaker: failures and cooldown must not be negative", i)
+		}
+		if err := f.validateProvider(); err != nil {
+			return fmt.Errorf("fallbacks[%d].%w", i, err)
+		}
+		if f.Canary.Enabled || f.Shadow.Enabled || len(f.Fallbacks) > 0 {
+			return fmt.Errorf("fallbacks[%d]: canary, shadow and fallbacks are not supported", i)
+		}
+	}
+	return nil
+}
+
+func (l LogConfig) validate() error {
+	if err := validateLogOutput(l.Output, l.File); err != nil {
+		return err
+	}
+	if a := l.SecurityAudit; a.Enabled {
+		if err := validateLogOutput(a.Output, a.File); err != nil {
+			return fmt.Errorf("security_audit.%w", err)
+		}
+	}
+	return nil
+}
+
+// validateLogOutput 校验 stdout | file 输出及文件滚动配置
+func validateLogOutput(output string, f LogFileConfig) error {
+	switch output {
+	case "", "stdout":
+		return nil
+	case "file":
+	default:
+		return fmt.Errorf("output: must be stdout or file, got %q", output)
+	}
+	if f.Path == "" {
+		return fmt.Errorf("file.path: must not be empty when output is file")
+	}
+	if f.MaxSizeMB < 0 || f.MaxBackups < 0 || f.MaxAgeDays < 0 {
+		return fmt.Errorf("file: max_size_mb, max_backups and max_age_days must not be negative")
+	}
+	return nil
+}
+
+func (t TLSConfig) validate() error {
+	if !t.Enabled {
+		return nil
+	}
+	files := t.CertFile != "" || t.KeyFile != ""
+	switch {
+	case files && t.Autocert.Enabled:
+		return fmt.Errorf("autocert: cannot be enabled together with cert_file / key_file")
+	case t.Autocert.Enabled:
+		if len(t.Autocert.Domains) == 0 {
+			return fmt.Errorf("autocert.domains: must not be empty")
+		}
+		for i, d := range t.Autocert.Domains {
+			if d == "" || strings.ContainsAny(d, "/:* ") {
+				return fmt.Errorf("autocert.domains[%d]: invalid domain %q", i, d)
+			}
+		}
+		if t.Autocert.HTTPAddr != "" {
+			if err := validateAddr(t.Autocert.HTTPAddr); err != nil {
+				return fmt.Errorf("autocert.http_addr: %w", err)
+			}
+		}
+	case t.CertFile == "":
+		return fmt.Errorf("cert_file: must not be empty unless autocert is enabled")
+	case t.KeyFile == "":
+		return fmt.Errorf("key_file: must not be empty unless autocert is enabled")
+	}
+	return nil
+}
+
+func (b BackpressureConfig) validate() error {
+	if b.MaxInFlight < 0 || b.MaxQueue < 0 {
+		return fmt.Errorf("max_in_flight and max_queue must not be negative")
+	}
+	if b.QueueTimeout < 0 || b.QueueTimeout >= 5*time.Second {
+		return fmt.Errorf("queue_timeout: must be between 0 and 5s, got %s", b.QueueTimeout)
+	}
 	return nil
 }
 
@@ -117,6 +2075,38 @@ func validateAddr(addr string) error {
 	return nil
 }
 
+// ParsePrefixes 解析 IP 或 CIDR 列表，单个 IP 视为只包含该地址的前缀
+func ParsePrefixes(list []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(list))
+	for _, s := range list {
+		if !strings.Contains(s, "/") {
+			addr, err := netip.ParseAddr(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid ip %q: %w", s, err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cidr %q: %w", s, err)
+		}
+		prefixes = append(prefixes, p.Masked())
+	}
+	return prefixes, nil
+}
+
+// validateListenAddr 校验 HTTP 监听地址，支持 host:port 和 unix:// 套接字路径
+func validateListenAddr(addr string) error {
+	if path, ok := strings.CutPrefix(addr, UnixSocketPrefix); ok {
+		if path == "" {
+			return fmt.Errorf("unix socket path must not be empty")
+		}
+		return nil
+	}
+	return validateAddr(addr)
+}
+
 func validateBaseURL(rawURL string) error {
 	if rawURL == "" {
 		return fmt.Errorf("must not be empty")
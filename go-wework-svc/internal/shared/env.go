@@ -0,0 +1,141 @@
+package shared
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// envRefRegex 匹配 ${VAR} 和 ${VAR:-default}，不处理 $VAR 以免误伤正则替换中的 $1
+var envRefRegex = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+// expandEnv 展开已解析文档中标量值的 ${VAR} 引用，变量未设置时使用默认值或空字符串
+// 在节点上展开而非原始文本，变量值中的换行、": " 等不会改变文档结构，注释中的引用也不展开
+func expandEnv(n *yaml.Node) {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		expanded := envRefRegex.ReplaceAllStringFunc(n.Value, func(ref string) string {
+			m := envRefRegex.FindStringSubmatch(ref)
+			if v, ok := os.LookupEnv(m[1]); ok {
+				return v
+			}
+			return m[2]
+		})
+		if expanded == n.Value {
+			return
+		}
+		n.Value = expanded
+		// 未加引号且未显式标注类型的值按展开后的内容重新推断类型，如 port: ${PORT} 解码为整数
+		if n.Style&(yaml.TaggedStyle|yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle|yaml.LiteralStyle|yaml.FoldedStyle) == 0 {
+			n.Tag = ""
+		}
+	case yaml.MappingNode:
+		// 只展开值，不展开键
+		for i := 1; i < len(n.Content); i += 2 {
+			expandEnv(n.Content[i])
+		}
+	default:
+		for _, c := range n.Content {
+			expandEnv(c)
+		}
+	}
+}
+
+var durationType = reflect.TypeFor[time.Duration]()
+
+// applyEnvOverrides 用环境变量覆盖配置字段
+// 变量名为 yaml 路径转大写并以 "_" 连接，如 wework.token → WEWORK_TOKEN、ai.base_url → AI_BASE_URL
+// 支持字符串、布尔、数值、时长和字符串列表（逗号分隔）；map 和结构体列表（如 wework.agents）需在 YAML 中配置
+func applyEnvOverrides(cfg *Config) error {
+	return overrideStruct(reflect.ValueOf(cfg).Elem(), "")
+}
+
+func overrideStruct(v reflect.Value, prefix string) error {
+	t := v.Type()
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if tag == "" || tag == "-" || !f.IsExported() {
+			continue
+		}
+		name := strings.ToUpper(prefix + tag)
+		fv := v.Field(i)
+
+		if fv.Kind() == reflect.Pointer {
+			// 可选的子配置（如 agents[].ai）不从环境变量创建
+			continue
+		}
+		if fv.Kind() == reflect.Struct && fv.Type() != durationType {
+			if err := overrideStruct(fv, name+"_"); err != nil {
+				return err
+			}
+			continue
+		}
+		raw, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err := setField(fv, raw); err != nil {
+			return fmt.Errorf("env %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// setField 将字符串解析为字段类型并赋值，不支持的类型忽略
+func setField(v reflect.Value, raw string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return nil
+		}
+		var items []string
+		for s := range strings.SplitSeq(raw, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				items = append(items, s)
+			}
+		}
+		v.Set(reflect.ValueOf(items).Convert(v.Type()))
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package shared
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+)
+
+type (
+	requestIDKey struct{}
+	logAttrsKey  struct{}
+)
+
+// WithRequestID 将请求 ID 写入 context，使用 *Context 日志方法时自动附带
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID 返回 context 中的请求 ID，没有时为空
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// WithLogAttrs 将关联字段（msg_id、agent_id、corp_id 等）追加到 context，使用 *Context 日志方法时自动附带
+// 后台任务从请求 context 派生（context.WithoutCancel 保留值），日志仍可关联到原请求
+func WithLogAttrs(ctx context.Context, attrs ...slog.Attr) context.Context {
+	prev := LogAttrs(ctx)
+	merged := make([]slog.Attr, 0, len(prev)+len(attrs))
+	for _, a := range prev {
+		if !slices.ContainsFunc(attrs, func(b slog.Attr) bool { return b.Key == a.Key }) {
+			merged = append(merged, a)
+		}
+	}
+	return context.WithValue(ctx, logAttrsKey{}, append(merged, attrs...))
+}
+
+// LogAttrs 返回 context 中的关联字段
+func LogAttrs(ctx context.Context) []slog.Attr {
+	attrs, _ := ctx.Value(logAttrsKey{}).([]slog.Attr)
+	return attrs
+}
+
+// contextHandler 为日志记录追加 context 中的请求 ID 和关联字段
+type contextHandler struct {
+	slog.Handler
+}
+
+// NewContextHandler 包装 h，记录日志时从 context 读取请求 ID 写入 request_id 字段，并追加 WithLogAttrs 设置的字段
+// 日志调用已显式传入的同名字段不重复追加
+func NewContextHandler(h slog.Handler) slog.Handler {
+	return contextHandler{h}
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if attrs := LogAttrs(ctx); len(attrs) > 0 {
+		present := make(map[string]bool, r.NumAttrs())
+		r.Attrs(func(a slog.Attr) bool {
+			present[a.Key] = true
+			return true
+		})
+		for _, a := range attrs {
+			if !present[a.Key] {
+				r.AddAttrs(a)
+			}
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
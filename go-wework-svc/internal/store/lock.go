@@ -0,0 +1,101 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// valueDeleter 支持按值原子删除的存储，Lock 释放时避免删掉已过期后被其他副本重新获取的租约
+type valueDeleter interface {
+	DeleteIfValue(ctx context.Context, key, value string) error
+}
+
+// valueExpirer 支持按值原子续期的存储
+type valueExpirer interface {
+	ExpireIfValue(ctx context.Context, key, value string, ttl time.Duration) (bool, error)
+}
+
+// Lock 基于 SetNX 的跨副本互斥租约，持有者异常退出时随 ttl 自动过期
+type Lock struct {
+	kv  Store
+	key string
+	ttl time.Duration
+}
+
+// NewLock 创建租约，ttl 应大于持有期间操作的最长耗时
+func NewLock(kv Store, key string, ttl time.Duration) *Lock {
+	return &Lock{kv: kv, key: key, ttl: ttl}
+}
+
+// TryLock 尝试获取租约，成功时返回释放函数；租约被其他持有者占用时 ok 为 false
+func (l *Lock) TryLock(ctx context.Context) (unlock func(), ok bool, err error) {
+	lease, ok, err := l.Acquire(ctx)
+	if !ok {
+		return nil, false, err
+	}
+	return func() { lease.Release(context.WithoutCancel(ctx)) }, true, nil
+}
+
+// Lease 已获取的租约，持有期间较长时应在每批操作前续期
+type Lease struct {
+	lock  *Lock
+	owner string
+}
+
+// Acquire 尝试获取租约，租约被其他持有者占用时 ok 为 false
+func (l *Lock) Acquire(ctx context.Context) (lease *Lease, ok bool, err error) {
+	b := make([]byte, 8)
+	rand.Read(b)
+	owner := hex.EncodeToString(b)
+	ok, err = l.kv.SetNX(ctx, l.key, owner, l.ttl)
+	if err != nil {
+		return nil, false, fmt.Errorf("acquire lock %s: %w", l.key, err)
+	}
+	if !ok {
+		return nil, false, nil
+	}
+	return &Lease{lock: l, owner: owner}, true, nil
+}
+
+// Renew 把租约有效期重置为 ttl，租约已过期或被其他持有者获取时返回 false
+func (h *Lease) Renew(ctx context.Context) (bool, error) {
+	l := h.lock
+	if e, ok := l.kv.(valueExpirer); ok {
+		ok, err := e.ExpireIfValue(ctx, l.key, h.owner, l.ttl)
+		if err != nil {
+			return false, fmt.Errorf("renew lock %s: %w", l.key, err)
+		}
+		return ok, nil
+	}
+	// 不支持按值续期的存储先读后写，存在极小的竞争窗口
+	v, ok, err := l.kv.Get(ctx, l.key)
+	if err != nil {
+		return false, fmt.Errorf("renew lock %s: %w", l.key, err)
+	}
+	if !ok || v != h.owner {
+		return false, nil
+	}
+	if err := l.kv.Set(ctx, l.key, h.owner, l.ttl); err != nil {
+		return false, fmt.Errorf("renew lock %s: %w", l.key, err)
+	}
+	return true, nil
+}
+
+// Release 释放租约
+func (h *Lease) Release(ctx context.Context) {
+	h.lock.release(ctx, h.owner)
+}
+
+// release 仅删除自己持有的租约，不支持按值删除的存储先读后删
+func (l *Lock) release(ctx context.Context, owner string) {
+	if d, ok := l.kv.(valueDeleter); ok {
+		d.DeleteIfValue(ctx, l.key, owner)
+		return
+	}
+	if v, ok, err := l.kv.Get(ctx, l.key); err == nil && ok && v == owner {
+		l.kv.Delete(ctx, l.key)
+	}
+}
@@ -0,0 +1,147 @@
+package store
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// sweepEvery 每写入多少次清理一次过期键
+const sweepEvery = 1024
+
+type memoryItem struct {
+	value     string
+	expiresAt time.Time // 零值表示不过期
+}
+
+func (it memoryItem) expired(now time.Time) bool {
+	return !it.expiresAt.IsZero() && !now.Before(it.expiresAt)
+}
+
+// Memory 进程内 Store 实现，仅适用于单副本部署
+type Memory struct {
+	mu     sync.Mutex
+	items  map[string]memoryItem
+	writes int
+}
+
+// NewMemory 创建内存存储
+func NewMemory() *Memory {
+	return &Memory{items: make(map[string]memoryItem)}
+}
+
+// Get 实现 Store 接口
+func (m *Memory) Get(_ context.Context, key string) (string, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	it, ok := m.items[key]
+	if !ok || it.expired(time.Now()) {
+		return "", false, nil
+	}
+	return it.value, true, nil
+}
+
+// Set 实现 Store 接口
+func (m *Memory) Set(_ context.Context, key, value string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.put(key, value, ttl)
+	return nil
+}
+
+// SetNX 实现 Store 接口
+func (m *Memory) SetNX(_ context.Context, key, value string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if it, ok := m.items[key]; ok && !it.expired(time.Now()) {
+		return false, nil
+	}
+	m.put(key, value, ttl)
+	return true, nil
+}
+
+// Incr 实现 Store 接口
+func (m *Memory) Incr(_ context.Context, key string, ttl time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	it, ok := m.items[key]
+	if !ok || it.expired(time.Now()) {
+		m.put(key, "1", ttl)
+		return 1, nil
+	}
+	n, err := strconv.ParseInt(it.value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	n++
+	it.value = strconv.FormatInt(n, 10)
+	m.items[key] = it
+	return n, nil
+}
+
+// Delete 实现 Store 接口
+func (m *Memory) Delete(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.items, key)
+	return nil
+}
+
+// DeleteIfValue 键的值等于 value 时删除
+func (m *Memory) DeleteIfValue(_ context.Context, key, value string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if it, ok := m.items[key]; ok && it.value == value {
+		delete(m.items, key)
+	}
+	return nil
+}
+
+// ExpireIfValue 键的值等于 value 时重设过期时间
+func (m *Memory) ExpireIfValue(_ context.Context, key, value string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if it, ok := m.items[key]; !ok || it.expired(time.Now()) || it.value != value {
+		return false, nil
+	}
+	m.put(key, value, ttl)
+	return true, nil
+}
+
+// SetIfValue 键的当前值等于 old 时写入 value，old 为空表示键必须不存在，返回是否写入
+func (m *Memory) SetIfValue(_ context.Context, key, old, value string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cur := ""
+	if it, ok := m.items[key]; ok && !it.expired(time.Now()) {
+		cur = it.value
+	}
+	if cur != old {
+		return false, nil
+	}
+	m.put(key, value, ttl)
+	return true, nil
+}
+
+// Close 实现 Store 接口
+func (m *Memory) Close() error { return nil }
+
+// put 写入键值并定期清理过期键，调用方需持有锁
+func (m *Memory) put(key, value string, ttl time.Duration) {
+	now := time.Now()
+	it := memoryItem{value: value}
+	if ttl > 0 {
+		it.expiresAt = now.Add(ttl)
+	}
+	m.items[key] = it
+
+	m.writes++
+	if m.writes%sweepEvery == 0 {
+		for k, v := range m.items {
+			if v.expired(now) {
+				delete(m.items, k)
+			}
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"go-wework-svc/internal/shared"
+)
+
+// incrScript 原子地自增并在首次创建时设置过期时间
+var incrScript = redis.NewScript(`
+local n = redis.call("INCR", KEYS[1])
+if n == 1 and tonumber(ARGV[1]) > 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return n
+`)
+
+// deleteIfValueScript 值相等时才删除，用于释放租约
+var deleteIfValueScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// expireIfValueScript 值相等时才续期，用于租约持有者续约
+var expireIfValueScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// setIfValueScript 当前值等于 ARGV[1] 时写入，ARGV[1] 为空表示键必须不存在，用于推进共享游标
+var setIfValueScript = redis.NewScript(`
+local cur = redis.call("GET", KEYS[1])
+if (cur == false and ARGV[1] == "") or cur == ARGV[1] then
+	if tonumber(ARGV[3]) > 0 then
+		redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+	else
+		redis.call("SET", KEYS[1], ARGV[2])
+	end
+	return 1
+end
+return 0
+`)
+
+// Redis 基于 Redis 的 Store 实现，用于多副本共享状态
+type Redis struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedis 连接 Redis 并校验连通性
+func NewRedis(ctx context.Context, cfg shared.RedisConfig) (*Redis, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.Addr,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("ping redis: %w", err)
+	}
+	return &Redis{client: client, prefix: cfg.KeyPrefix}, nil
+}
+
+// Client 返回底层客户端，供需要 Redis 专有能力的组件使用
+func (r *Redis) Client() *redis.Client {
+	return r.client
+}
+
+// Ping 检查 Redis 连通性
+func (r *Redis) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx).Err()
+}
+
+// Get 实现 Store 接口
+func (r *Redis) Get(ctx context.Context, key string) (string, bool, error) {
+	v, err := r.client.Get(ctx, r.prefix+key).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("redis get: %w", err)
+	}
+	return v, true, nil
+}
+
+// Set 实现 Store 接口
+func (r *Redis) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	if err := r.client.Set(ctx, r.prefix+key, value, max(ttl, 0)).Err(); err != nil {
+		return fmt.Errorf("redis set: %w", err)
+	}
+	return nil
+}
+
+// SetNX 实现 Store 接口
+func (r *Redis) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	ok, err := r.client.SetNX(ctx, r.prefix+key, value, max(ttl, 0)).Result()
+	if err != nil {
+		return false, fmt.Errorf("redis setnx: %w", err)
+	}
+	return ok, nil
+}
+
+// Incr 实现 Store 接口
+func (r *Redis) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	n, err := incrScript.Run(ctx, r.client, []string{r.prefix + key}, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return 0, fmt.Errorf("redis incr: %w", err)
+	}
+	return n, nil
+}
+
+// Delete 实现 Store 接口
+func (r *Redis) Delete(ctx context.Context, key string) error {
+	if err := r.client.Del(ctx, r.prefix+key).Err(); err != nil {
+		return fmt.Errorf("redis del: %w", err)
+	}
+	return nil
+}
+
+// DeleteIfValue 键的值等于 value 时删除
+func (r *Redis) DeleteIfValue(ctx context.Context, key, value string) error {
+	if err := deleteIfValueScript.Run(ctx, r.client, []string{r.prefix + key}, value).Err(); err != nil {
+		return fmt.Errorf("redis del if value: %w", err)
+	}
+	return nil
+}
+
+// ExpireIfValue 键的值等于 value 时重设过期时间，返回是否续期成功
+func (r *Redis) ExpireIfValue(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	n, err := expireIfValueScript.Run(ctx, r.client, []string{r.prefix + key}, value, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return false, fmt.Errorf("redis expire if value: %w", err)
+	}
+	return n == 1, nil
+}
+
+// SetIfValue 键的当前值等于 old 时写入 value，old 为空表示键必须不存在，返回是否写入
+func (r *Redis) SetIfValue(ctx context.Context, key, old, value string, ttl time.Duration) (bool, error) {
+	n, err := setIfValueScript.Run(ctx, r.client, []string{r.prefix + key}, old, value, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return false, fmt.Errorf("redis set if value: %w", err)
+	}
+	return n == 1, nil
+}
+
+// Close 实现 Store 接口
+func (r *Redis) Close() error {
+	return r.client.Close()
+}
@@ -0,0 +1,52 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// Store 键值存储接口，去重、token 缓存、限流等共享状态均基于此实现
+// 单副本使用内存实现，多副本部署时使用 Redis 实现共享状态
+type Store interface {
+	// Get 读取键值，键不存在或已过期时 ok 为 false
+	Get(ctx context.Context, key string) (value string, ok bool, err error)
+
+	// Set 写入键值，ttl <= 0 表示不过期
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+
+	// SetNX 仅在键不存在时写入，返回是否写入成功
+	SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error)
+
+	// Incr 计数加一并返回新值，键首次创建时设置 ttl
+	Incr(ctx context.Context, key string, ttl time.Duration) (int64, error)
+
+	// Delete 删除键，键不存在时不报错
+	Delete(ctx context.Context, key string) error
+
+	// Close 释放底层资源
+	Close() error
+}
+
+// valueSetter 支持按值原子写入的存储
+type valueSetter interface {
+	SetIfValue(ctx context.Context, key, old, value string, ttl time.Duration) (bool, error)
+}
+
+// CompareAndSet 键的当前值等于 old 时写入 value，old 为空表示键必须不存在，返回是否写入
+// 不支持按值写入的存储先读后写，存在极小的竞争窗口
+func CompareAndSet(ctx context.Context, kv Store, key, old, value string, ttl time.Duration) (bool, error) {
+	if s, ok := kv.(valueSetter); ok {
+		return s.SetIfValue(ctx, key, old, value, ttl)
+	}
+	cur, _, err := kv.Get(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	if cur != old {
+		return false, nil
+	}
+	if err := kv.Set(ctx, key, value, ttl); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,124 @@
+package transform
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"go-wework-svc/internal/shared"
+)
+
+// defaultPlaceholders 各类个人信息的默认占位符，保留类型便于 AI 理解上下文
+var defaultPlaceholders = map[string]string{
+	shared.RedactPhone:      "[手机号]",
+	shared.RedactIDCard:     "[身份证号]",
+	shared.RedactEmail:      "[邮箱]",
+	shared.RedactEmployeeID: "[工号]",
+}
+
+var (
+	// phoneRegex 大陆手机号，可带 +86 / 86 前缀
+	phoneRegex = regexp.MustCompile(`(?:\+?86[- ]?)?1[3-9]\d{9}`)
+	// idCardRegex 18 位居民身份证号，校验位另行验证
+	idCardRegex = regexp.MustCompile(`\d{17}[\dXx]`)
+	emailRegex  = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+)
+
+// detector 单类个人信息的识别规则
+type detector struct {
+	re          *regexp.Regexp
+	numeric     bool                // 要求匹配前后不是数字，避免误伤订单号等长数字串
+	valid       func(s string) bool // 为空时不额外校验
+	placeholder string
+}
+
+// Redactor 脱敏消息中的手机号、身份证号、邮箱和工号，实现 wework.Transformer
+type Redactor struct {
+	detectors []detector
+}
+
+// NewRedactor 根据配置创建脱敏器，身份证号先于手机号识别
+func NewRedactor(cfg shared.RedactionConfig) (*Redactor, error) {
+	kinds := cfg.Kinds
+	if len(kinds) == 0 {
+		kinds = []string{shared.RedactIDCard, shared.RedactPhone, shared.RedactEmail}
+		if cfg.EmployeeIDPattern != "" {
+			kinds = append(kinds, shared.RedactEmployeeID)
+		}
+	}
+
+	r := &Redactor{}
+	for _, kind := range []string{shared.RedactIDCard, shared.RedactPhone, shared.RedactEmail, shared.RedactEmployeeID} {
+		if !slices.Contains(kinds, kind) {
+			continue
+		}
+		d := detector{placeholder: cmp.Or(cfg.Placeholders[kind], defaultPlaceholders[kind])}
+		switch kind {
+		case shared.RedactIDCard:
+			d.re, d.numeric, d.valid = idCardRegex, true, validIDCard
+		case shared.RedactPhone:
+			d.re, d.numeric = phoneRegex, true
+		case shared.RedactEmail:
+			d.re = emailRegex
+		case shared.RedactEmployeeID:
+			re, err := regexp.Compile(cfg.EmployeeIDPattern)
+			if err != nil {
+				return nil, fmt.Errorf("compile employee id pattern: %w", err)
+			}
+			d.re = re
+		}
+		r.detectors = append(r.detectors, d)
+	}
+	return r, nil
+}
+
+// Transform 将识别到的个人信息替换为占位符
+func (r *Redactor) Transform(_ context.Context, content string) string {
+	for _, d := range r.detectors {
+		content = d.replace(content)
+	}
+	return content
+}
+
+// replace 替换所有通过校验的匹配
+func (d detector) replace(content string) string {
+	matches := d.re.FindAllStringIndex(content, -1)
+	if len(matches) == 0 {
+		return content
+	}
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		if d.numeric && (start > 0 && isDigit(content[start-1]) || end < len(content) && isDigit(content[end])) {
+			continue
+		}
+		if d.valid != nil && !d.valid(content[start:end]) {
+			continue
+		}
+		b.WriteString(content[last:start])
+		b.WriteString(d.placeholder)
+		last = end
+	}
+	b.WriteString(content[last:])
+	return b.String()
+}
+
+// idCardWeights 身份证号前 17 位的加权因子
+var idCardWeights = [17]int{7, 9, 10, 5, 8, 4, 2, 1, 6, 3, 7, 9, 10, 5, 8, 4, 2}
+
+// validIDCard 校验 GB 11643 校验位
+func validIDCard(s string) bool {
+	sum := 0
+	for i, w := range idCardWeights {
+		sum += int(s[i]-'0') * w
+	}
+	return "10X98765432"[sum%11] == strings.ToUpper(s[17:])[0]
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,191 @@
+package transform
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode"
+
+	"go-wework-svc/internal/shared"
+)
+
+// defaultExpandTimeout 短链展开默认超时
+const defaultExpandTimeout = 2 * time.Second
+
+// step 单个变换步骤
+type step func(ctx context.Context, content string) string
+
+// Pipeline 按配置顺序执行的消息变换链，规则可通过 Reload 在运行时替换
+type Pipeline struct {
+	steps  atomic.Pointer[[]step]
+	logger *slog.Logger
+}
+
+// New 根据规则列表构建变换链，规则按声明顺序执行
+func New(rules []shared.TransformRule, logger *slog.Logger) (*Pipeline, error) {
+	p := &Pipeline{logger: logger}
+	if err := p.Reload(rules); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Reload 重新构建变换链并原子替换，任一规则无效时保留原规则
+func (p *Pipeline) Reload(rules []shared.TransformRule) error {
+	steps := make([]step, 0, len(rules))
+	for i, r := range rules {
+		s, err := newStep(r, p.logger)
+		if err != nil {
+			return fmt.Errorf("rule %d (%s): %w", i, r.Type, err)
+		}
+		steps = append(steps, s)
+	}
+	p.steps.Store(&steps)
+	return nil
+}
+
+// Transform 依次执行所有变换步骤
+func (p *Pipeline) Transform(ctx context.Context, content string) string {
+	for _, s := range *p.steps.Load() {
+		content = s(ctx, content)
+	}
+	return content
+}
+
+func newStep(r shared.TransformRule, logger *slog.Logger) (step, error) {
+	switch r.Type {
+	case shared.TransformRegexReplace:
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("compile pattern: %w", err)
+		}
+		return func(_ context.Context, content string) string {
+			return re.ReplaceAllString(content, r.Replacement)
+		}, nil
+	case shared.TransformStripPrefix:
+		return stripPrefix(r.Prefixes), nil
+	case shared.TransformEmoji:
+		return emoji(r.Mode), nil
+	case shared.TransformExpandURL:
+		timeout := r.Timeout
+		if timeout <= 0 {
+			timeout = defaultExpandTimeout
+		}
+		return newURLExpander(r.Hosts, timeout, logger).expand, nil
+	default:
+		return nil, fmt.Errorf("unknown type %q", r.Type)
+	}
+}
+
+// stripPrefix 去除内容开头的任一前缀（忽略前导空白），如 "@AI助手"
+func stripPrefix(prefixes []string) step {
+	return func(_ context.Context, content string) string {
+		trimmed := strings.TrimLeftFunc(content, unicode.IsSpace)
+		for _, p := range prefixes {
+			if rest, ok := strings.CutPrefix(trimmed, p); ok {
+				return strings.TrimLeftFunc(rest, unicode.IsSpace)
+			}
+		}
+		return content
+	}
+}
+
+// emoji 处理 emoji 字符
+// strip: 移除所有 emoji；normalize: 去除变体选择符和肤色修饰符，统一为基础 emoji
+func emoji(mode string) step {
+	return func(_ context.Context, content string) string {
+		return strings.Map(func(r rune) rune {
+			if isEmojiModifier(r) {
+				return -1
+			}
+			if mode == "strip" && isEmoji(r) {
+				return -1
+			}
+			return r
+		}, content)
+	}
+}
+
+// isEmojiModifier 变体选择符、零宽连接符与肤色修饰符
+func isEmojiModifier(r rune) bool {
+	return r == 0xFE0E || r == 0xFE0F || r == 0x200D || (r >= 0x1F3FB && r <= 0x1F3FF)
+}
+
+// isEmoji 常见 emoji 码位区间
+func isEmoji(r rune) bool {
+	switch {
+	case r >= 0x1F000 && r <= 0x1FAFF: // 符号、表情、交通、补充符号等
+		return true
+	case r >= 0x2600 && r <= 0x27BF: // 杂项符号与装饰符号
+		return true
+	case r >= 0x2B00 && r <= 0x2BFF: // 杂项符号和箭头
+		return true
+	case r >= 0xE0020 && r <= 0xE007F: // 旗帜标签
+		return true
+	}
+	return false
+}
+
+var urlRegex = regexp.MustCompile(`https?://[^\s<>"]+`)
+
+// urlExpander 将白名单域名下的短链替换为其跳转目标
+type urlExpander struct {
+	hosts      []string
+	httpClient *http.Client
+	logger     *slog.Logger
+}
+
+func newURLExpander(hosts []string, timeout time.Duration, logger *slog.Logger) *urlExpander {
+	return &urlExpander{
+		hosts: hosts,
+		httpClient: &http.Client{
+			Timeout: timeout,
+			// 只取第一跳的 Location，不跟随跳转
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		logger: logger,
+	}
+}
+
+func (e *urlExpander) expand(ctx context.Context, content string) string {
+	return urlRegex.ReplaceAllStringFunc(content, func(raw string) string {
+		u, err := url.Parse(raw)
+		if err != nil || !slices.Contains(e.hosts, u.Hostname()) {
+			return raw
+		}
+		target, err := e.resolve(ctx, raw)
+		if err != nil {
+			e.logger.Debug("failed to expand url", "url", raw, "error", err)
+			return raw
+		}
+		return target
+	})
+}
+
+// resolve 发送 HEAD 请求并返回 Location 头
+func (e *urlExpander) resolve(ctx context.Context, raw string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, raw, nil)
+	if err != nil {
+		return "", fmt.Errorf("create request: %w", err)
+	}
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	loc, err := resp.Location()
+	if err != nil {
+		return "", fmt.Errorf("no redirect location (status %d)", resp.StatusCode)
+	}
+	return loc.String(), nil
+}
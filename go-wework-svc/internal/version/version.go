@@ -0,0 +1,58 @@
+// Package version 构建信息，通过 ldflags 注入：
+//
+//	go build -ldflags "-X go-wework-svc/internal/version.Commit=$(git rev-parse --short HEAD) \
+//	  -X go-wework-svc/internal/version.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)" ./cmd/server
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// 由 ldflags 注入，未注入时 Commit 取自 Go 工具链记录的 VCS 信息
+var (
+	Commit    = ""
+	BuildTime = ""
+)
+
+// Info 构建与运行信息
+type Info struct {
+	Commit     string `json:"commit"`
+	BuildTime  string `json:"build_time"`
+	GoVersion  string `json:"go_version"`
+	ConfigHash string `json:"config_hash"`
+}
+
+// Get 返回构建信息，configHash 为当前配置的摘要
+func Get(configHash string) Info {
+	info := Info{
+		Commit:     Commit,
+		BuildTime:  BuildTime,
+		GoVersion:  runtime.Version(),
+		ConfigHash: configHash,
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok && (info.Commit == "" || info.BuildTime == "") {
+		var modified bool
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = s.Value
+				}
+			case "vcs.time":
+				if info.BuildTime == "" {
+					info.BuildTime = s.Value
+				}
+			case "vcs.modified":
+				modified = s.Value == "true"
+			}
+		}
+		if modified && Commit == "" && info.Commit != "" {
+			info.Commit += "-dirty"
+		}
+	}
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	return info
+}
@@ -0,0 +1,93 @@
+package wework
+
+import (
+	"context"
+	"slices"
+)
+
+// Directory 通讯录查询
+type Directory interface {
+	// Departments 返回成员直属的部门 ID
+	Departments(ctx context.Context, userID string) ([]int64, error)
+}
+
+// AccessPolicy 按用户和部门控制谁可以使用机器人，黑名单优先于白名单
+// 白名单均为空时除黑名单外的成员都可使用；部门按成员直属部门匹配
+type AccessPolicy struct {
+	AllowUsers       []string
+	AllowDepartments []int64
+	BlockUsers       []string
+	BlockDepartments []int64
+	AllowCorps       []string  // 允许使用的互联企业（含上下游）CorpID，其成员视同白名单用户；只约束互联企业成员，不限制本企业成员
+	DenyReply        string    // 拒绝时的回复，为空则不回复
+	Directory        Directory // 配置了部门规则时必填
+}
+
+// WithAccessControl 启用访问控制，被拒绝的消息不再识别命令、自动回复或转发 AI
+func WithAccessControl(p AccessPolicy) Option {
+	return func(s *serviceImpl) { s.access = &p }
+}
+
+// accessMiddleware 拦截无权使用机器人的成员发来的消息，其余消息交给 next
+func (s *serviceImpl) accessMiddleware(next Handler) Handler {
+	return HandlerFunc(func(ctx context.Context, req *Request) ([]byte, error) {
+		if s.access == nil {
+			return next.Handle(ctx, req)
+		}
+		reason, denied := s.denied(ctx, req.Message.FromUserName)
+		if !denied {
+			return next.Handle(ctx, req)
+		}
+		s.observer.OnMessage(ctx, req.Message, OutcomeDenied)
+		s.logger.WarnContext(ctx, "access denied",
+			"msg_id", req.Message.MsgID,
+			"from_user", req.Message.FromUserName,
+			"msg_type", req.Message.MsgType,
+			"reason", reason,
+		)
+		return s.respond(ctx, req, "access_denied", func(ctx context.Context) string {
+			return s.localize(ctx, req.Message.FromUserName, ReplyAccessDenied, s.access.DenyReply, nil)
+		})
+	})
+}
+
+// denied 判断成员是否无权使用机器人
+// 部门查询失败时视为不属于任何部门：部门黑名单不生效，仅凭部门白名单放行的成员被拒绝
+func (s *serviceImpl) denied(ctx context.Context, user string) (reason string, denied bool) {
+	p := s.access
+	if slices.Contains(p.BlockUsers, user) {
+		return "blocked_user", true
+	}
+	// restricted 为 true 时需命中部门白名单才能使用；白名单用户仍受部门黑名单约束
+	// 本企业成员只受用户和部门白名单约束，互联企业成员另受企业白名单约束
+	allowed := slices.Contains(p.AllowUsers, user)
+	whitelisted := len(p.AllowUsers) > 0 || len(p.AllowDepartments) > 0
+	if corpID, _, ok := SplitLinkedUser(user); ok {
+		allowed = allowed || slices.Contains(p.AllowCorps, corpID)
+		whitelisted = whitelisted || len(p.AllowCorps) > 0
+	}
+	restricted := whitelisted && !allowed
+
+	var depts []int64
+	if len(p.BlockDepartments) > 0 || (restricted && len(p.AllowDepartments) > 0) {
+		var err error
+		depts, err = p.Directory.Departments(ctx, user)
+		if err != nil {
+			s.logger.WarnContext(ctx, "failed to load user departments", "user_id", user, "error", err)
+		}
+	}
+	for _, d := range depts {
+		if slices.Contains(p.BlockDepartments, d) {
+			return "blocked_department", true
+		}
+	}
+	if !restricted {
+		return "", false
+	}
+	for _, d := range depts {
+		if slices.Contains(p.AllowDepartments, d) {
+			return "", false
+		}
+	}
+	return "not_allowed", true
+}
@@ -0,0 +1,167 @@
+package wework
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go-wework-svc/internal/ai"
+)
+
+// Todo 待办事项
+type Todo struct {
+	Creator    string
+	Content    string
+	Followers  []string  // 待办的跟进人，为空时为创建者本人
+	RemindTime time.Time // 零值表示不提醒
+}
+
+// TodoClient 待办 API
+type TodoClient interface {
+	// CreateTodo 创建待办，返回待办 ID
+	CreateTodo(ctx context.Context, todo Todo) (string, error)
+}
+
+// ActionPolicy AI 可调用的企业微信操作，依赖为 nil 的操作不提供
+type ActionPolicy struct {
+	// Sender、Uploader 用于 send_file：将下载地址的文件作为素材消息发给当前用户
+	Sender   Sender
+	Uploader MediaUploader
+	// Todos 用于 create_todo
+	Todos TodoClient
+	// Contacts 用于 lookup_user：按 userid 查询成员的姓名、部门和职务
+	Contacts Contacts
+	// Location 解析提醒时间使用的时区，nil 时为 time.Local
+	Location *time.Location
+}
+
+// ActionTools 返回发送文件、创建待办、查询成员的工具，以发起对话的用户身份执行
+func ActionTools(p ActionPolicy) []ai.Tool {
+	var tools []ai.Tool
+	if p.Sender != nil && p.Uploader != nil {
+		tools = append(tools, ai.Tool{
+			Name:        "send_file",
+			Description: "将指定地址的文件或图片发送给当前用户，仅支持配置允许的下载域名",
+			Parameters: json.RawMessage(`{"type":"object","properties":{` +
+				`"url":{"type":"string","description":"文件的下载地址"},` +
+				`"type":{"type":"string","enum":["file","image"],"description":"默认 file"}` +
+				`},"required":["url"]}`),
+			Call: func(ctx context.Context, call ai.ToolCall) (string, error) {
+				return sendFile(ctx, p, call)
+			},
+		})
+	}
+	if p.Todos != nil {
+		tools = append(tools, ai.Tool{
+			Name:        "create_todo",
+			Description: "为当前用户创建待办事项。提醒时间格式为 YYYY-MM-DD HH:MM，相对时间先调用 current_time 换算",
+			Parameters: json.RawMessage(`{"type":"object","properties":{` +
+				`"content":{"type":"string","description":"待办内容"},` +
+				`"followers":{"type":"array","items":{"type":"string"},"description":"跟进人的企业微信 userid，默认为当前用户"},` +
+				`"remind_time":{"type":"string","description":"提醒时间，YYYY-MM-DD HH:MM"}` +
+				`},"required":["content"]}`),
+			Call: func(ctx context.Context, call ai.ToolCall) (string, error) {
+				return createTodo(ctx, p, call)
+			},
+		})
+	}
+	if p.Contacts != nil {
+		tools = append(tools, ai.Tool{
+			Name:        "lookup_user",
+			Description: "按企业微信 userid 查询成员的姓名、部门和职务",
+			Parameters: json.RawMessage(`{"type":"object","properties":{` +
+				`"userid":{"type":"string"}` +
+				`},"required":["userid"]}`),
+			Call: func(ctx context.Context, call ai.ToolCall) (string, error) {
+				return lookupUser(ctx, p, call)
+			},
+		})
+	}
+	return tools
+}
+
+// sendFile 上传下载地址的内容并以素材消息发给当前用户
+func sendFile(ctx context.Context, p ActionPolicy, call ai.ToolCall) (string, error) {
+	var args struct {
+		URL  string `json:"url"`
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(call.Arguments, &args); err != nil {
+		return "", fmt.Errorf("decode arguments: %w", err)
+	}
+	if args.URL == "" {
+		return "", errors.New("url is required")
+	}
+	mediaType := MsgTypeFile
+	if args.Type == MsgTypeImage {
+		mediaType = MsgTypeImage
+	}
+	mediaID, err := p.Uploader.UploadURL(ctx, mediaType, args.URL)
+	if err != nil {
+		return "", fmt.Errorf("upload: %w", err)
+	}
+	if err := p.Sender.Send(ctx, OutgoingMessage{ToUser: call.UserID, MsgType: mediaType, MediaID: mediaID}); err != nil {
+		return "", fmt.Errorf("send: %w", err)
+	}
+	return "已发送给用户", nil
+}
+
+// createTodo 解析参数并创建待办
+func createTodo(ctx context.Context, p ActionPolicy, call ai.ToolCall) (string, error) {
+	var args struct {
+		Content    string   `json:"content"`
+		Followers  []string `json:"followers"`
+		RemindTime string   `json:"remind_time"`
+	}
+	if err := json.Unmarshal(call.Arguments, &args); err != nil {
+		return "", fmt.Errorf("decode arguments: %w", err)
+	}
+	if strings.TrimSpace(args.Content) == "" {
+		return "", errors.New("content is required")
+	}
+	todo := Todo{Creator: call.UserID, Content: args.Content, Followers: args.Followers}
+	if len(todo.Followers) == 0 {
+		todo.Followers = []string{call.UserID}
+	}
+	if args.RemindTime != "" {
+		loc := p.Location
+		if loc == nil {
+			loc = time.Local
+		}
+		t, err := time.ParseInLocation(scheduleTimeLayout, args.RemindTime, loc)
+		if err != nil {
+			return "", fmt.Errorf("parse remind_time: %w", err)
+		}
+		todo.RemindTime = t
+	}
+	id, err := p.Todos.CreateTodo(ctx, todo)
+	if err != nil {
+		return "", err
+	}
+	return "已创建待办（ID " + id + "）", nil
+}
+
+// lookupUser 查询成员信息
+func lookupUser(ctx context.Context, p ActionPolicy, call ai.ToolCall) (string, error) {
+	var args struct {
+		UserID string `json:"userid"`
+	}
+	if err := json.Unmarshal(call.Arguments, &args); err != nil {
+		return "", fmt.Errorf("decode arguments: %w", err)
+	}
+	if args.UserID == "" {
+		return "", errors.New("userid is required")
+	}
+	profile, err := p.Contacts.Profile(ctx, args.UserID)
+	if err != nil {
+		return "", err
+	}
+	out, err := json.Marshal(profile)
+	if err != nil {
+		return "", fmt.Errorf("marshal profile: %w", err)
+	}
+	return string(out), nil
+}
@@ -0,0 +1,275 @@
+package wework
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go-wework-svc/internal/ai"
+	"go-wework-svc/internal/store"
+)
+
+// ApprovalInfo 审批事件中的审批信息，sys_approval_change 与 open_approval_change 只填充各自的字段
+type ApprovalInfo struct {
+	// sys_approval_change
+	SpNo             string           `xml:"SpNo"`
+	SpName           string           `xml:"SpName"`
+	SpStatus         int              `xml:"SpStatus"`
+	TemplateID       string           `xml:"TemplateId"`
+	ApplyTime        int64            `xml:"ApplyTime"`
+	ApplyerID        string           `xml:"Applyer>UserId"`
+	SpRecords        []ApprovalRecord `xml:"SpRecord"`
+	StatuChangeEvent int              `xml:"StatuChangeEvent"`
+
+	// open_approval_change
+	ThirdNo       string             `xml:"ThirdNo"`
+	OpenSpName    string             `xml:"OpenSpName"`
+	OpenSpStatus  int                `xml:"OpenSpStatus"`
+	ApplyUserID   string             `xml:"ApplyUserId"`
+	ApplyUserName string             `xml:"ApplyUserName"`
+	ApprovalNodes []OpenApprovalNode `xml:"ApprovalNodes>ApprovalNode"`
+}
+
+// ApprovalRecord 审批流程中的一个节点
+type ApprovalRecord struct {
+	SpStatus     int              `xml:"SpStatus"`
+	ApproverAttr int              `xml:"ApproverAttr"` // 1 或签 2 会签
+	Details      []ApprovalDetail `xml:"Details"`
+}
+
+// ApprovalDetail 节点中一位审批人的处理情况
+type ApprovalDetail struct {
+	ApproverID string `xml:"Approver>UserId"`
+	Speech     string `xml:"Speech"`
+	SpStatus   int    `xml:"SpStatus"`
+	SpTime     int64  `xml:"SpTime"`
+}
+
+// OpenApprovalNode 自建审批流程的节点
+type OpenApprovalNode struct {
+	NodeStatus int                `xml:"NodeStatus"`
+	Items      []OpenApprovalItem `xml:"Items>Item"`
+}
+
+// OpenApprovalItem 自建审批流程节点中的审批人
+type OpenApprovalItem struct {
+	UserID string `xml:"ItemUserId"`
+	Name   string `xml:"ItemName"`
+	Status int    `xml:"ItemStatus"`
+}
+
+// 审批状态，sys_approval_change 的 SpStatus 与 open_approval_change 的 OpenSpStatus 取值一致
+const (
+	ApprovalPending  = 1 // 审批中
+	ApprovalApproved = 2
+	ApprovalRejected = 3
+	ApprovalRevoked  = 4
+)
+
+// 审批状态变化类型（StatuChangeEvent）的常用取值
+const (
+	ApprovalSubmitted = 1  // 提单
+	ApprovalAgreed    = 2  // 同意
+	ApprovalDeclined  = 3  // 驳回
+	ApprovalForwarded = 4  // 转审
+	ApprovalUrged     = 5  // 催办
+	ApprovalCancelled = 6  // 撤销
+	ApprovalCommented = 10 // 添加备注
+)
+
+// Approval 审批单详情
+type Approval struct {
+	SpNo      string
+	SpName    string
+	Status    int
+	Applicant string
+	Fields    []ApprovalField
+}
+
+// ApprovalField 审批申请中的一个控件
+type ApprovalField struct {
+	Title string
+	Value string
+}
+
+// ApprovalClient 审批 API
+type ApprovalClient interface {
+	// Detail 查询审批单详情
+	Detail(ctx context.Context, spNo string) (*Approval, error)
+}
+
+// ApprovalPolicy 审批事件的处理方式
+type ApprovalPolicy struct {
+	Client          ApprovalClient // 查询申请内容，为 nil 时通知中不含申请内容
+	NotifyApprovers bool           // 通知当前节点待处理的审批人
+	NotifyApplicant bool           // 审批通过、驳回或撤销时通知申请人
+	// SummaryChars 申请内容超过该字符数时由 AI 生成摘要，0 表示不摘要，过长时截断
+	SummaryChars int
+	// Store 非空时同一审批人对同一审批单只通知一次（催办除外），申请人对同一状态只通知一次
+	Store store.Store
+}
+
+const (
+	// maxApprovalText 通知中申请内容的字符上限
+	maxApprovalText = 500
+	// approvalSummaryUser 生成审批摘要时 AI 请求使用的系统身份
+	approvalSummaryUser = "system:approval"
+	// approvalNotifiedTTL 通知去重记录的保留时长
+	approvalNotifiedTTL = 30 * 24 * time.Hour
+)
+
+// approvalChange 统一两类审批事件后的状态变化
+type approvalChange struct {
+	no        string
+	name      string
+	status    int
+	change    int // open_approval_change 为 0
+	applicant string
+	pending   []string
+}
+
+// WithApproval 处理 sys_approval_change 和 open_approval_change 事件，通过 Sender 通知审批人和申请人
+func WithApproval(p ApprovalPolicy) Option {
+	return func(s *serviceImpl) {
+		h := s.eventHandler(EventHandlerFunc(func(ctx context.Context, ev Event) (string, error) {
+			s.approvalChanged(ctx, p, ev)
+			return "", nil
+		}))
+		s.registry.RegisterEvent(EventSysApprovalChange, h)
+		s.registry.RegisterEvent(EventOpenApprovalChange, h)
+	}
+}
+
+// approvalChanged 按状态变化通知审批人和申请人，失败时记录日志
+func (s *serviceImpl) approvalChanged(ctx context.Context, p ApprovalPolicy, ev Event) {
+	if ev.ApprovalInfo == nil {
+		s.logger.WarnContext(ctx, "approval event without ApprovalInfo", "event", ev.Event)
+		return
+	}
+	c := approvalChangeOf(ev)
+	s.logger.InfoContext(ctx, "approval changed", "sp_no", c.no, "status", c.status, "change", c.change, "pending", len(c.pending))
+
+	if p.NotifyApprovers && c.status == ApprovalPending && c.change != ApprovalCommented && len(c.pending) > 0 {
+		var targets []string
+		for _, user := range c.pending {
+			if c.change == ApprovalUrged || s.firstApprovalNotice(ctx, p, c.no+":"+user) {
+				targets = append(targets, user)
+			}
+		}
+		title := "【待审批】"
+		if c.change == ApprovalUrged {
+			title = "【审批催办】"
+		}
+		text := title + c.name + "\n申请人：" + c.applicant
+		if len(targets) > 0 && ev.Event == EventSysApprovalChange {
+			if content := s.approvalContent(ctx, p, c); content != "" {
+				text += "\n" + content
+			}
+		}
+		for _, user := range targets {
+			s.deliver(ctx, Message{FromUserName: user}, text)
+		}
+	}
+
+	if p.NotifyApplicant && c.applicant != "" {
+		var result string
+		switch c.status {
+		case ApprovalApproved:
+			result = "已通过"
+		case ApprovalRejected:
+			result = "已驳回"
+		case ApprovalRevoked:
+			result = "已撤销"
+		}
+		if result != "" && s.firstApprovalNotice(ctx, p, c.no+":status:"+strconv.Itoa(c.status)) {
+			s.deliver(ctx, Message{FromUserName: c.applicant}, "你提交的「"+c.name+"」"+result)
+		}
+	}
+}
+
+// approvalChangeOf 从事件中取出审批单号、状态和当前待处理的审批人
+func approvalChangeOf(ev Event) approvalChange {
+	info := ev.ApprovalInfo
+	if ev.Event == EventOpenApprovalChange {
+		c := approvalChange{no: info.ThirdNo, name: info.OpenSpName, status: info.OpenSpStatus, applicant: info.ApplyUserID}
+		for _, node := range info.ApprovalNodes {
+			if node.NodeStatus != ApprovalPending {
+				continue
+			}
+			for _, item := range node.Items {
+				if item.Status == ApprovalPending && item.UserID != "" {
+					c.pending = append(c.pending, item.UserID)
+				}
+			}
+		}
+		return c
+	}
+
+	c := approvalChange{no: info.SpNo, name: info.SpName, status: info.SpStatus, change: info.StatuChangeEvent, applicant: info.ApplyerID}
+	for _, r := range info.SpRecords {
+		if r.SpStatus != ApprovalPending {
+			continue
+		}
+		for _, d := range r.Details {
+			if d.SpStatus == ApprovalPending && d.ApproverID != "" {
+				c.pending = append(c.pending, d.ApproverID)
+			}
+		}
+		// 只通知第一个审批中的节点
+		break
+	}
+	return c
+}
+
+// firstApprovalNotice 登记一次通知，已通知过时返回 false；未配置存储或存储失败时总是通知
+func (s *serviceImpl) firstApprovalNotice(ctx context.Context, p ApprovalPolicy, key string) bool {
+	if p.Store == nil {
+		return true
+	}
+	ok, err := p.Store.SetNX(ctx, "approval:"+s.agent+":"+key, "1", approvalNotifiedTTL)
+	if err != nil {
+		s.logger.WarnContext(ctx, "failed to record approval notice", "key", key, "error", err)
+		return true
+	}
+	return ok
+}
+
+// approvalContent 查询申请内容，超过 SummaryChars 时由 AI 生成摘要，摘要失败时截断原文
+func (s *serviceImpl) approvalContent(ctx context.Context, p ApprovalPolicy, c approvalChange) string {
+	if p.Client == nil || c.no == "" {
+		return ""
+	}
+	detail, err := p.Client.Detail(ctx, c.no)
+	if err != nil {
+		s.logger.WarnContext(ctx, "failed to load approval detail", "sp_no", c.no, "error", err)
+		return ""
+	}
+	lines := make([]string, 0, len(detail.Fields))
+	for _, f := range detail.Fields {
+		if f.Value != "" {
+			lines = append(lines, f.Title+"："+f.Value)
+		}
+	}
+	text := strings.Join(lines, "\n")
+
+	if p.SummaryChars > 0 && utf8.RuneCountInString(text) > p.SummaryChars {
+		// 摘要是系统任务，不以申请人身份请求，避免写入申请人的会话或带上其身份相关的工具和路由
+		resp, err := s.aiSvc.SendMessage(ctx, ai.ChatRequest{
+			UserID:         approvalSummaryUser,
+			Source:         "wework_approval",
+			ConversationID: "approval:" + c.no,
+			Content:        fmt.Sprintf("请用不超过 %d 字概括以下审批申请的要点，便于审批人快速判断：\n%s", p.SummaryChars/2, text),
+		})
+		if err == nil && resp.Reply != "" {
+			return "摘要：" + strings.TrimSpace(resp.Reply)
+		}
+		s.logger.WarnContext(ctx, "failed to summarize approval", "sp_no", c.no, "error", err)
+	}
+	if utf8.RuneCountInString(text) > maxApprovalText {
+		text = string([]rune(text)[:maxApprovalText]) + "…"
+	}
+	return text
+}
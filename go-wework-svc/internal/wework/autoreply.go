@@ -0,0 +1,32 @@
+package wework
+
+import (
+	"context"
+)
+
+// AutoReplier 关键词自动回复
+type AutoReplier interface {
+	// Reply 返回命中规则的回复，content 为去掉 @提及后的消息内容，未命中时 ok 为 false
+	Reply(ctx context.Context, user, content string) (reply string, ok bool)
+}
+
+// WithAutoReply 启用自动回复，命中规则的文本消息不再转发 AI
+func WithAutoReply(r AutoReplier) Option {
+	return func(s *serviceImpl) { s.autoReply = r }
+}
+
+// autoReplyMiddleware 拦截命中自动回复规则的消息，其余消息交给 next
+func (s *serviceImpl) autoReplyMiddleware(next Handler) Handler {
+	return HandlerFunc(func(ctx context.Context, req *Request) ([]byte, error) {
+		if s.autoReply == nil {
+			return next.Handle(ctx, req)
+		}
+		reply, ok := s.autoReply.Reply(ctx, req.Message.FromUserName, s.bareText(ctx, req.Message))
+		if !ok {
+			return next.Handle(ctx, req)
+		}
+		s.observer.OnMessage(ctx, req.Message, OutcomeHandled)
+		s.logger.InfoContext(ctx, "auto reply matched", "msg_id", req.Message.MsgID, "from_user", req.Message.FromUserName)
+		return s.respond(ctx, req, "auto_reply", func(context.Context) string { return reply })
+	})
+}
@@ -0,0 +1,113 @@
+package wework
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrOverloaded 处理槽位和等待队列已满，回调被拒绝，由企业微信稍后重试
+var ErrOverloaded = errors.New("callback processing overloaded")
+
+// Backpressure 限制同时处理的回调数，进程内所有应用共享
+// 处理中的计数包括回调请求本身和转入后台的 AI 转发，槽位已满时新请求最多排队等待 wait，
+// 超过队列长度或等待超时立即拒绝，避免协程堆积并在企业微信 5 秒超时前无法应答
+type Backpressure struct {
+	max      int
+	maxQueue int
+	wait     time.Duration
+
+	mu       sync.Mutex
+	inFlight int
+	// waiters 按到达顺序排队，释放的槽位直接转交给队首
+	waiters []chan struct{}
+}
+
+// WithBackpressure 后台 AI 转发占用 b 的槽位，与回调处理器共享同一实例
+func WithBackpressure(b *Backpressure) Option {
+	return func(s *serviceImpl) { s.backpressure = b }
+}
+
+// holdSlot 后台任务占用槽位，未启用时返回空函数
+func (s *serviceImpl) holdSlot() func() {
+	if s.backpressure == nil {
+		return func() {}
+	}
+	return s.backpressure.hold()
+}
+
+// NewBackpressure 创建并发限制，maxInFlight 为处理槽位数，maxQueue 为最多等待的请求数
+func NewBackpressure(maxInFlight, maxQueue int, wait time.Duration) *Backpressure {
+	return &Backpressure{max: maxInFlight, maxQueue: maxQueue, wait: wait}
+}
+
+// Acquire 占用一个槽位，成功时返回释放函数；队列已满、等待超时或 ctx 取消时返回 ErrOverloaded
+func (b *Backpressure) Acquire(ctx context.Context) (func(), error) {
+	b.mu.Lock()
+	if b.inFlight < b.max && len(b.waiters) == 0 {
+		b.inFlight++
+		b.mu.Unlock()
+		return b.release, nil
+	}
+	if len(b.waiters) >= b.maxQueue {
+		b.mu.Unlock()
+		return nil, ErrOverloaded
+	}
+	ch := make(chan struct{})
+	b.waiters = append(b.waiters, ch)
+	b.mu.Unlock()
+
+	timer := time.NewTimer(b.wait)
+	defer timer.Stop()
+	select {
+	case <-ch:
+		return b.release, nil
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, w := range b.waiters {
+		if w == ch {
+			b.waiters = append(b.waiters[:i], b.waiters[i+1:]...)
+			return nil, ErrOverloaded
+		}
+	}
+	// 超时的同时已被转交槽位
+	return b.release, nil
+}
+
+// hold 不等待地占用槽位，用于已接受的回调转入后台处理，可能暂时超出上限
+func (b *Backpressure) hold() func() {
+	b.mu.Lock()
+	b.inFlight++
+	b.mu.Unlock()
+	return b.release
+}
+
+func (b *Backpressure) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.waiters) > 0 && b.inFlight <= b.max {
+		close(b.waiters[0])
+		b.waiters = b.waiters[1:]
+		return
+	}
+	b.inFlight--
+}
+
+// InFlight 返回处理中的数量
+func (b *Backpressure) InFlight() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.inFlight
+}
+
+// Waiting 返回排队等待的请求数
+func (b *Backpressure) Waiting() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.waiters)
+}
@@ -0,0 +1,220 @@
+package wework
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"go-wework-svc/internal/ai"
+)
+
+// Schedule 日程
+type Schedule struct {
+	ID          string
+	Organizer   string
+	Attendees   []string
+	Summary     string
+	Description string
+	Location    string
+	Start       time.Time
+	End         time.Time
+}
+
+// CalendarClient 日程 API
+type CalendarClient interface {
+	// AddSchedule 在日历 calID 中创建日程，calID 为空时加入应用的默认日历，返回日程 ID
+	AddSchedule(ctx context.Context, calID string, sch Schedule) (string, error)
+	// Schedules 列出日历 calID 中未取消的日程
+	Schedules(ctx context.Context, calID string) ([]Schedule, error)
+}
+
+// CalendarPolicy 日程工具的设置
+type CalendarPolicy struct {
+	Client CalendarClient
+	// CalendarID 应用日历的 cal_id（oa/calendar/add 创建），为空时新日程加入应用默认日历，且不提供查询日程的工具
+	CalendarID string
+	// Location 解析和展示时间使用的时区，nil 时为 time.Local
+	Location *time.Location
+}
+
+const (
+	// defaultMeetingMinutes 未指定结束时间的会议时长
+	defaultMeetingMinutes = 30
+	// defaultScheduleDays 未指定查询范围时列出今天起的天数
+	defaultScheduleDays = 7
+	// scheduleTimeLayout 工具参数和结果中的时间格式
+	scheduleTimeLayout = "2006-01-02 15:04"
+	scheduleDateLayout = "2006-01-02"
+)
+
+// WithTools 为转发 AI 的请求附带可调用的工具，后端不支持工具时忽略
+func WithTools(tools ...ai.Tool) Option {
+	return func(s *serviceImpl) { s.tools = append(s.tools, tools...) }
+}
+
+// CalendarTools 返回以发送者身份创建会议、查询日程的工具，需配置 Secret 并授予应用日程权限
+func CalendarTools(p CalendarPolicy) []ai.Tool {
+	loc := cmp.Or(p.Location, time.Local)
+	now := func() time.Time { return time.Now().In(loc) }
+
+	tools := []ai.Tool{
+		{
+			Name:        "current_time",
+			Description: "返回当前的日期、时间和星期，用于换算「明天下午三点」之类的相对时间",
+			Parameters:  json.RawMessage(`{"type":"object","properties":{}}`),
+			Call: func(ctx context.Context, call ai.ToolCall) (string, error) {
+				return now().Format(scheduleTimeLayout + " Monday MST"), nil
+			},
+		},
+		{
+			Name:        "schedule_meeting",
+			Description: "以当前用户为组织者创建会议日程并邀请参会人。时间格式为 YYYY-MM-DD HH:MM，相对时间先调用 current_time 换算",
+			Parameters: json.RawMessage(`{"type":"object","properties":{` +
+				`"summary":{"type":"string","description":"会议主题"},` +
+				`"start_time":{"type":"string","description":"开始时间，YYYY-MM-DD HH:MM"},` +
+				`"end_time":{"type":"string","description":"结束时间，YYYY-MM-DD HH:MM，不填时按 duration_minutes 计算"},` +
+				`"duration_minutes":{"type":"integer","description":"会议时长（分钟），默认 30"},` +
+				`"attendees":{"type":"array","items":{"type":"string"},"description":"参会人的企业微信 userid"},` +
+				`"location":{"type":"string"},` +
+				`"description":{"type":"string"}` +
+				`},"required":["summary","start_time"]}`),
+			Call: func(ctx context.Context, call ai.ToolCall) (string, error) {
+				return createMeeting(ctx, p, loc, call)
+			},
+		},
+	}
+	if p.CalendarID != "" {
+		tools = append(tools, ai.Tool{
+			Name:        "list_my_schedule",
+			Description: "列出当前用户作为组织者或参会人的日程。日期格式为 YYYY-MM-DD，默认从今天起 7 天",
+			Parameters: json.RawMessage(`{"type":"object","properties":{` +
+				`"from":{"type":"string","description":"开始日期，YYYY-MM-DD"},` +
+				`"to":{"type":"string","description":"结束日期（含），YYYY-MM-DD"}` +
+				`}}`),
+			Call: func(ctx context.Context, call ai.ToolCall) (string, error) {
+				return listSchedule(ctx, p, loc, now(), call)
+			},
+		})
+	}
+	return tools
+}
+
+// meetingArgs schedule_meeting 的参数
+type meetingArgs struct {
+	Summary         string   `json:"summary"`
+	StartTime       string   `json:"start_time"`
+	EndTime         string   `json:"end_time"`
+	DurationMinutes int      `json:"duration_minutes"`
+	Attendees       []string `json:"attendees"`
+	Location        string   `json:"location"`
+	Description     string   `json:"description"`
+}
+
+// createMeeting 解析参数并创建日程
+func createMeeting(ctx context.Context, p CalendarPolicy, loc *time.Location, call ai.ToolCall) (string, error) {
+	var args meetingArgs
+	if err := json.Unmarshal(call.Arguments, &args); err != nil {
+		return "", fmt.Errorf("decode arguments: %w", err)
+	}
+	if args.Summary == "" {
+		return "", errors.New("summary is required")
+	}
+	start, err := time.ParseInLocation(scheduleTimeLayout, args.StartTime, loc)
+	if err != nil {
+		return "", fmt.Errorf("parse start_time: %w", err)
+	}
+	end := start.Add(time.Duration(cmp.Or(args.DurationMinutes, defaultMeetingMinutes)) * time.Minute)
+	if args.EndTime != "" {
+		if end, err = time.ParseInLocation(scheduleTimeLayout, args.EndTime, loc); err != nil {
+			return "", fmt.Errorf("parse end_time: %w", err)
+		}
+	}
+	if !end.After(start) {
+		return "", errors.New("end_time must be after start_time")
+	}
+
+	sch := Schedule{
+		Organizer:   call.UserID,
+		Attendees:   slices.DeleteFunc(slices.Clone(args.Attendees), func(u string) bool { return u == "" || u == call.UserID }),
+		Summary:     args.Summary,
+		Description: args.Description,
+		Location:    args.Location,
+		Start:       start,
+		End:         end,
+	}
+	id, err := p.Client.AddSchedule(ctx, p.CalendarID, sch)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("已创建日程（ID %s）：%s，%s", id, sch.Summary, scheduleSpan(sch)), nil
+}
+
+// scheduleArgs list_my_schedule 的参数
+type scheduleArgs struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// listSchedule 列出用户在查询范围内的日程
+func listSchedule(ctx context.Context, p CalendarPolicy, loc *time.Location, now time.Time, call ai.ToolCall) (string, error) {
+	var args scheduleArgs
+	if err := json.Unmarshal(call.Arguments, &args); err != nil {
+		return "", fmt.Errorf("decode arguments: %w", err)
+	}
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	if args.From != "" {
+		d, err := time.ParseInLocation(scheduleDateLayout, args.From, loc)
+		if err != nil {
+			return "", fmt.Errorf("parse from: %w", err)
+		}
+		from = d
+	}
+	to := from.AddDate(0, 0, defaultScheduleDays)
+	if args.To != "" {
+		d, err := time.ParseInLocation(scheduleDateLayout, args.To, loc)
+		if err != nil {
+			return "", fmt.Errorf("parse to: %w", err)
+		}
+		to = d.AddDate(0, 0, 1)
+	}
+
+	all, err := p.Client.Schedules(ctx, p.CalendarID)
+	if err != nil {
+		return "", err
+	}
+	var mine []Schedule
+	for _, sch := range all {
+		if sch.End.After(from) && sch.Start.Before(to) && (sch.Organizer == call.UserID || slices.Contains(sch.Attendees, call.UserID)) {
+			mine = append(mine, sch)
+		}
+	}
+	if len(mine) == 0 {
+		return "该时间范围内没有日程", nil
+	}
+	slices.SortFunc(mine, func(a, b Schedule) int { return a.Start.Compare(b.Start) })
+
+	lines := make([]string, 0, len(mine))
+	for _, sch := range mine {
+		sch.Start, sch.End = sch.Start.In(loc), sch.End.In(loc)
+		line := scheduleSpan(sch) + " " + sch.Summary
+		if sch.Location != "" {
+			line += "（" + sch.Location + "）"
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// scheduleSpan 格式化日程的起止时间，同一天结束时只显示结束时刻
+func scheduleSpan(sch Schedule) string {
+	end := sch.End.Format(scheduleTimeLayout)
+	if sch.End.Format(scheduleDateLayout) == sch.Start.Format(scheduleDateLayout) {
+		end = sch.End.Format("15:04")
+	}
+	return sch.Start.Format(scheduleTimeLayout) + "-" + end
+}
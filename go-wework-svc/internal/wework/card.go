@@ -0,0 +1,183 @@
+package wework
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// 模板卡片类型
+const (
+	CardTypeTextNotice        = "text_notice"
+	CardTypeNewsNotice        = "news_notice"
+	CardTypeButtonInteraction = "button_interaction"
+)
+
+// TemplateCard 模板卡片消息（msgtype 为 template_card），字段与 message/send API 一致
+type TemplateCard struct {
+	CardType              string         `json:"card_type"`
+	Source                *CardSource    `json:"source,omitempty"`
+	MainTitle             *CardText      `json:"main_title,omitempty"`
+	EmphasisContent       *CardText      `json:"emphasis_content,omitempty"` // text_notice 关键数据
+	QuoteArea             *CardQuote     `json:"quote_area,omitempty"`
+	SubTitleText          string         `json:"sub_title_text,omitempty"`
+	HorizontalContentList []CardField    `json:"horizontal_content_list,omitempty"`
+	JumpList              []CardJump     `json:"jump_list,omitempty"`
+	CardAction            *CardAction    `json:"card_action,omitempty"` // text_notice、news_notice 必填
+	CardImage             *CardImage     `json:"card_image,omitempty"`  // news_notice
+	ImageTextArea         *CardImageText `json:"image_text_area,omitempty"`
+	VerticalContentList   []CardText     `json:"vertical_content_list,omitempty"`
+	TaskID                string         `json:"task_id,omitempty"` // button_interaction 必填，应用内唯一，点击事件中原样返回
+	ButtonList            []CardButton   `json:"button_list,omitempty"`
+}
+
+// CardSource 卡片来源
+type CardSource struct {
+	IconURL   string `json:"icon_url,omitempty"`
+	Desc      string `json:"desc,omitempty"`
+	DescColor int    `json:"desc_color,omitempty"` // 0 灰色 1 黑色 2 红色 3 绿色
+}
+
+// CardText 标题和辅助信息
+type CardText struct {
+	Title string `json:"title,omitempty"`
+	Desc  string `json:"desc,omitempty"`
+}
+
+// CardQuote 引用文献样式
+type CardQuote struct {
+	Type      int    `json:"type,omitempty"` // 0 无点击 1 跳转 URL 2 跳转小程序
+	URL       string `json:"url,omitempty"`
+	AppID     string `json:"appid,omitempty"`
+	PagePath  string `json:"pagepath,omitempty"`
+	Title     string `json:"title,omitempty"`
+	QuoteText string `json:"quote_text,omitempty"`
+}
+
+// CardField 二级标题 + 文本列表中的一项
+type CardField struct {
+	KeyName string `json:"keyname"`
+	Value   string `json:"value,omitempty"`
+	Type    int    `json:"type,omitempty"` // 0 文本 1 跳转 URL 2 下载附件 3 成员详情
+	URL     string `json:"url,omitempty"`
+	MediaID string `json:"media_id,omitempty"`
+	UserID  string `json:"userid,omitempty"`
+}
+
+// CardJump 跳转指引
+type CardJump struct {
+	Type     int    `json:"type,omitempty"` // 1 跳转 URL 2 跳转小程序
+	Title    string `json:"title"`
+	URL      string `json:"url,omitempty"`
+	AppID    string `json:"appid,omitempty"`
+	PagePath string `json:"pagepath,omitempty"`
+}
+
+// CardAction 整体卡片的点击跳转
+type CardAction struct {
+	Type     int    `json:"type"` // 1 跳转 URL 2 跳转小程序
+	URL      string `json:"url,omitempty"`
+	AppID    string `json:"appid,omitempty"`
+	PagePath string `json:"pagepath,omitempty"`
+}
+
+// CardImage news_notice 的图片
+type CardImage struct {
+	URL         string  `json:"url"`
+	AspectRatio float64 `json:"aspect_ratio,omitempty"`
+}
+
+// CardImageText news_notice 的左图右文
+type CardImageText struct {
+	Type     int    `json:"type,omitempty"`
+	URL      string `json:"url,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Desc     string `json:"desc,omitempty"`
+	ImageURL string `json:"image_url"`
+}
+
+// CardButton button_interaction 的按钮
+type CardButton struct {
+	Type  int    `json:"type,omitempty"` // 0 回调点击事件 1 跳转 URL
+	Text  string `json:"text"`
+	Style int    `json:"style,omitempty"` // 1~4
+	Key   string `json:"key,omitempty"`   // type 为 0 时必填，点击事件的 EventKey
+	URL   string `json:"url,omitempty"`
+}
+
+// Validate 校验各卡片类型的必填字段
+func (c *TemplateCard) Validate() error {
+	switch c.CardType {
+	case CardTypeTextNotice:
+		if (c.MainTitle == nil || c.MainTitle.Title == "") && c.SubTitleText == "" {
+			return errors.New("text_notice requires main_title.title or sub_title_text")
+		}
+		if c.CardAction == nil {
+			return errors.New("text_notice requires card_action")
+		}
+	case CardTypeNewsNotice:
+		if c.MainTitle == nil || c.MainTitle.Title == "" {
+			return errors.New("news_notice requires main_title.title")
+		}
+		if c.CardImage == nil && c.ImageTextArea == nil {
+			return errors.New("news_notice requires card_image or image_text_area")
+		}
+		if c.CardAction == nil {
+			return errors.New("news_notice requires card_action")
+		}
+	case CardTypeButtonInteraction:
+		if c.MainTitle == nil || c.MainTitle.Title == "" {
+			return errors.New("button_interaction requires main_title.title")
+		}
+		if c.TaskID == "" {
+			return errors.New("button_interaction requires task_id")
+		}
+		if len(c.ButtonList) == 0 || len(c.ButtonList) > 6 {
+			return fmt.Errorf("button_interaction requires 1 to 6 buttons, got %d", len(c.ButtonList))
+		}
+		for i, b := range c.ButtonList {
+			if b.Text == "" {
+				return fmt.Errorf("button_list[%d]: text is required", i)
+			}
+			if b.Type == 0 && b.Key == "" {
+				return fmt.Errorf("button_list[%d]: key is required for callback buttons", i)
+			}
+			if b.Type == 1 && b.URL == "" {
+				return fmt.Errorf("button_list[%d]: url is required for link buttons", i)
+			}
+		}
+	default:
+		return fmt.Errorf("card_type must be text_notice, news_notice or button_interaction, got %q", c.CardType)
+	}
+	return nil
+}
+
+// CardUpdater 更新已发送的模板卡片
+type CardUpdater interface {
+	// UpdateTemplateCardButton 将点击者卡片上的按钮替换为不可点击的 replaceName，
+	// responseCode 取自点击事件，72 小时内有效且只能使用一次
+	UpdateTemplateCardButton(ctx context.Context, userID, responseCode, replaceName string) error
+}
+
+// CardButtonAction 按钮点击后的处理方式
+type CardButtonAction struct {
+	Reply       string // 通过 Sender 发送给点击者的回复，为空时不回复
+	ReplaceName string // 点击后按钮替换为的文字，为空时不更新卡片
+}
+
+// CardButtons 按按钮 key 处理 template_card_event 的事件处理器，未配置的 key 记录日志后忽略
+// updater 为 nil 时不更新卡片
+func CardButtons(actions map[string]CardButtonAction, updater CardUpdater) EventHandler {
+	return EventHandlerFunc(func(ctx context.Context, ev Event) (string, error) {
+		action, ok := actions[ev.EventKey]
+		if !ok {
+			return "", fmt.Errorf("no action for card button %q (task %s)", ev.EventKey, ev.TaskID)
+		}
+		if action.ReplaceName != "" && updater != nil && ev.ResponseCode != "" {
+			if err := updater.UpdateTemplateCardButton(ctx, ev.FromUserName, ev.ResponseCode, action.ReplaceName); err != nil {
+				return "", fmt.Errorf("update template card: %w", err)
+			}
+		}
+		return action.Reply, nil
+	})
+}
@@ -0,0 +1,177 @@
+package wework
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-wework-svc/internal/store"
+)
+
+// Command 文本命令，在转发 AI 之前识别并处理
+type Command interface {
+	// Run 执行命令，args 为命令名之后的内容，返回非空 reply 时回复给发送者
+	Run(ctx context.Context, msg Message, args string) (reply string, err error)
+}
+
+// CommandFunc 函数形式的 Command
+type CommandFunc func(ctx context.Context, msg Message, args string) (string, error)
+
+// Run 实现 Command 接口
+func (f CommandFunc) Run(ctx context.Context, msg Message, args string) (string, error) {
+	return f(ctx, msg, args)
+}
+
+// CommandSpec 命令注册信息
+type CommandSpec struct {
+	Name        string // 斜杠命令名，不含 "/"，如 reset 对应 /reset
+	Description string // 在 /help 中展示
+	Command     Command
+}
+
+// CommandPolicy 命令识别配置
+type CommandPolicy struct {
+	Intro    string            // /help 回复开头的说明
+	Keywords map[string]string // 关键词 → 命令名，消息内容与关键词完全相同时触发
+	// Store 保存 /reset 后的会话代数，多副本部署时应为共享存储
+	Store store.Store
+}
+
+// 内置命令名
+const (
+	CommandHelp  = "help"
+	CommandReset = "reset"
+)
+
+// conversationResetTTL 会话代数的保留时长，应长于各后端的会话空闲时长
+const conversationResetTTL = 30 * 24 * time.Hour
+
+// WithCommands 启用命令识别，并注册内置的 /help 和 /reset
+func WithCommands(p CommandPolicy) Option {
+	return func(s *serviceImpl) {
+		s.commandPolicy = &p
+		s.registerCommand(CommandSpec{Name: CommandHelp, Description: "查看可用命令", Command: CommandFunc(s.help)})
+		s.registerCommand(CommandSpec{Name: CommandReset, Description: "清空对话记忆，开始新的对话", Command: CommandFunc(s.reset)})
+	}
+}
+
+// WithCommand 注册自定义命令，重复注册时覆盖；需同时启用 WithCommands 才会识别
+func WithCommand(spec CommandSpec) Option {
+	return func(s *serviceImpl) { s.registerCommand(spec) }
+}
+
+func (s *serviceImpl) registerCommand(spec CommandSpec) {
+	if s.commands == nil {
+		s.commands = make(map[string]CommandSpec)
+	}
+	s.commands[strings.ToLower(spec.Name)] = spec
+}
+
+// parseCommand 识别命令：匹配去掉 @提及后的 /命令 或关键词
+func (s *serviceImpl) parseCommand(ctx context.Context, msg Message) (CommandSpec, string, bool) {
+	if s.commandPolicy == nil {
+		return CommandSpec{}, "", false
+	}
+	text := s.bareText(ctx, msg)
+
+	if rest, ok := strings.CutPrefix(text, "/"); ok {
+		name, args, _ := strings.Cut(rest, " ")
+		spec, ok := s.commands[strings.ToLower(name)]
+		return spec, strings.TrimSpace(args), ok
+	}
+	if name, ok := s.commandPolicy.Keywords[text]; ok {
+		spec, ok := s.commands[strings.ToLower(name)]
+		return spec, "", ok
+	}
+	return CommandSpec{}, "", false
+}
+
+// commandMiddleware 拦截命令消息，其余消息交给 next
+func (s *serviceImpl) commandMiddleware(next Handler) Handler {
+	return HandlerFunc(func(ctx context.Context, req *Request) ([]byte, error) {
+		spec, args, ok := s.parseCommand(ctx, req.Message)
+		if !ok {
+			return next.Handle(ctx, req)
+		}
+		s.observer.OnMessage(ctx, req.Message, OutcomeHandled)
+		return s.respond(ctx, req, "command", func(ctx context.Context) string {
+			return s.runCommand(ctx, spec, req.Message, args)
+		})
+	})
+}
+
+// runCommand 执行命令并记录日志，失败时返回空回复
+func (s *serviceImpl) runCommand(ctx context.Context, spec CommandSpec, msg Message, args string) string {
+	reply, err := spec.Command.Run(ctx, msg, args)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "command failed", "command", spec.Name, "msg_id", msg.MsgID, "error", err)
+		return ""
+	}
+	s.logger.InfoContext(ctx, "command handled", "command", spec.Name, "msg_id", msg.MsgID, "from_user", msg.FromUserName)
+	return reply
+}
+
+// help 列出已注册的命令及关键词
+func (s *serviceImpl) help(context.Context, Message, string) (string, error) {
+	keywords := make(map[string][]string)
+	for kw, name := range s.commandPolicy.Keywords {
+		keywords[strings.ToLower(name)] = append(keywords[strings.ToLower(name)], kw)
+	}
+	specs := make([]CommandSpec, 0, len(s.commands))
+	for _, spec := range s.commands {
+		specs = append(specs, spec)
+	}
+	slices.SortFunc(specs, func(a, b CommandSpec) int { return cmp.Compare(a.Name, b.Name) })
+
+	var b strings.Builder
+	if s.commandPolicy.Intro != "" {
+		b.WriteString(s.commandPolicy.Intro)
+		b.WriteString("\n")
+	}
+	b.WriteString("可用命令：")
+	for _, spec := range specs {
+		fmt.Fprintf(&b, "\n/%s %s", spec.Name, spec.Description)
+		if kws := keywords[strings.ToLower(spec.Name)]; len(kws) > 0 {
+			slices.Sort(kws)
+			fmt.Fprintf(&b, "（也可发送：%s）", strings.Join(kws, "、"))
+		}
+	}
+	return b.String(), nil
+}
+
+// reset 更新会话代数，之后的 AI 请求使用新的会话标识，会话历史与后端保存的上下文随之失效
+func (s *serviceImpl) reset(ctx context.Context, msg Message, _ string) (string, error) {
+	if s.commandPolicy.Store == nil {
+		return "", fmt.Errorf("reset conversation: no store configured")
+	}
+	key := s.conversationKey(msg)
+	epoch := strconv.FormatInt(time.Now().UnixNano(), 36)
+	if err := s.commandPolicy.Store.Set(ctx, conversationEpochKey(key), epoch, conversationResetTTL); err != nil {
+		return "", fmt.Errorf("reset conversation: %w", err)
+	}
+	return "对话已重置，我们重新开始吧。", nil
+}
+
+// conversationID 返回消息所属会话的标识，执行过 /reset 的会话附加代数
+func (s *serviceImpl) conversationID(ctx context.Context, msg Message) string {
+	key := s.conversationKey(msg)
+	if s.commandPolicy == nil || s.commandPolicy.Store == nil {
+		return key
+	}
+	epoch, ok, err := s.commandPolicy.Store.Get(ctx, conversationEpochKey(key))
+	if err != nil {
+		s.logger.WarnContext(ctx, "failed to load conversation epoch", "conversation", key, "error", err)
+	}
+	if !ok || epoch == "" {
+		return key
+	}
+	return key + "#" + epoch
+}
+
+func conversationEpochKey(key string) string {
+	return "conv-epoch:" + key
+}
@@ -0,0 +1,31 @@
+package wework
+
+import (
+	"context"
+
+	"go-wework-svc/internal/ai"
+)
+
+// Contacts 查询发送者的通讯录信息，用于个性化 AI 回复
+type Contacts interface {
+	// Profile 返回成员的姓名、部门和职务，实现方应缓存结果
+	Profile(ctx context.Context, userID string) (*ai.UserProfile, error)
+}
+
+// WithContacts AI 请求附带发送者的通讯录信息，需配置 Secret 且应用对成员有通讯录可见范围
+func WithContacts(c Contacts) Option {
+	return func(s *serviceImpl) { s.contacts = c }
+}
+
+// profile 查询发送者信息，失败时记录日志并返回 nil，不影响转发
+func (s *serviceImpl) profile(ctx context.Context, msg Message) *ai.UserProfile {
+	if s.contacts == nil || msg.FromUserName == "" {
+		return nil
+	}
+	p, err := s.contacts.Profile(ctx, msg.FromUserName)
+	if err != nil {
+		s.logger.WarnContext(ctx, "failed to load user profile", "from_user", msg.FromUserName, "error", err)
+		return nil
+	}
+	return p
+}
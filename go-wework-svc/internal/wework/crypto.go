@@ -1,70 +1,144 @@
 package wework
 
 import (
+	"context"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha1"
 	"encoding/base64"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"sort"
 	"strings"
+	"sync/atomic"
 )
 
 // Crypto 企业微信消息加解密接口
+// 配置了轮换密钥时，Decrypt 先尝试最近成功的密钥以减少无效解密，每条消息成功解密后以 debug 级别记录所用的 key_index
 type Crypto interface {
 	// VerifySignature 验证消息签名
 	// 签名算法: SHA1(sort(token, timestamp, nonce, msgEncrypt))
 	VerifySignature(signature, timestamp, nonce, msgEncrypt string) bool
 
+	// Sign 计算消息签名（用于被动回复）
+	Sign(timestamp, nonce, msgEncrypt string) string
+
 	// Decrypt 解密消息
 	// AES-CBC 解密，密钥由 EncodingAESKey base64 解码得到
-	Decrypt(encrypted string) ([]byte, error)
+	// 失败时返回包装 ErrCorpMismatch 或 ErrDecryptFailed 的错误；ctx 用于日志关联
+	Decrypt(ctx context.Context, encrypted string) ([]byte, error)
 
 	// Encrypt 加密消息（用于主动回复）
 	Encrypt(plaintext []byte) (string, error)
 }
 
+// emptyReceiveIDKey 允许明文 ReceiveId 为空的 context 键
+type emptyReceiveIDKey struct{}
+
+// WithEmptyReceiveID 允许本次解密的明文 ReceiveId 为空：智能机器人的 JSON 回调和 URL 验证不携带 CorpID
+// 其余回调仍要求 ReceiveId 与 CorpID 一致
+func WithEmptyReceiveID(ctx context.Context) context.Context {
+	return context.WithValue(ctx, emptyReceiveIDKey{}, true)
+}
+
+func allowEmptyReceiveID(ctx context.Context) bool {
+	allow, _ := ctx.Value(emptyReceiveIDKey{}).(bool)
+	return allow
+}
+
 // cryptoImpl Crypto 接口的实现
 type cryptoImpl struct {
 	token  string
-	aesKey []byte
 	corpID string
+	logger *slog.Logger
+
+	rotation []string // 轮换密钥，解码后追加到 keys
+	keys     [][]byte // keys[0] 为 encoding_aes_key
+	current  atomic.Int32
+}
+
+// CryptoOption 加解密服务可选配置
+type CryptoOption func(*cryptoImpl)
+
+// WithRotationKeys 设置轮换期间的其他 EncodingAESKey
+// 解密时先尝试最近成功的密钥，再按配置顺序尝试其余密钥；加密使用最近成功解密的密钥
+func WithRotationKeys(logger *slog.Logger, keys ...string) CryptoOption {
+	return func(c *cryptoImpl) {
+		c.logger = logger
+		c.rotation = keys
+	}
 }
 
 // NewCrypto 创建企业微信加解密服务实例
 // encodingAESKey 为 43 字符的 Base64 编码密钥，追加 "=" 后解码得到 32 字节 AES 密钥
-func NewCrypto(token, encodingAESKey, corpID string) (Crypto, error) {
+func NewCrypto(token, encodingAESKey, corpID string, opts ...CryptoOption) (Crypto, error) {
+	c := &cryptoImpl{
+		token:  token,
+		corpID: corpID,
+		logger: slog.Default(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	for i, k := range append([]string{encodingAESKey}, c.rotation...) {
+		aesKey, err := decodeAESKey(k)
+		if err != nil {
+			if i == 0 {
+				return nil, fmt.Errorf("encoding_aes_key: %w", err)
+			}
+			return nil, fmt.Errorf("encoding_aes_keys[%d]: %w", i-1, err)
+		}
+		c.keys = append(c.keys, aesKey)
+	}
+	return c, nil
+}
+
+// decodeAESKey 解码 43 字符的 EncodingAESKey
+func decodeAESKey(encodingAESKey string) ([]byte, error) {
 	aesKey, err := base64.StdEncoding.DecodeString(encodingAESKey + "=")
 	if err != nil {
-		return nil, fmt.Errorf("decode encoding_aes_key: %w", err)
+		return nil, fmt.Errorf("decode: %w", err)
 	}
 	if len(aesKey) != 32 {
 		return nil, fmt.Errorf("invalid aes key length: got %d, want 32", len(aesKey))
 	}
-	return &cryptoImpl{
-		token:  token,
-		aesKey: aesKey,
-		corpID: corpID,
-	}, nil
+	return aesKey, nil
 }
 
 // VerifySignature 验证消息签名
-// SHA1(sort(token, timestamp, nonce, msgEncrypt)) == signature
+// SHA1(sort(token, timestamp, nonce, msgEncrypt)) == signature，使用常量时间比较防止时序探测
 func (c *cryptoImpl) VerifySignature(signature, timestamp, nonce, msgEncrypt string) bool {
+	expected := c.Sign(timestamp, nonce, msgEncrypt)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+// Sign 计算消息签名 SHA1(sort(token, timestamp, nonce, msgEncrypt))
+func (c *cryptoImpl) Sign(timestamp, nonce, msgEncrypt string) string {
 	params := []string{c.token, timestamp, nonce, msgEncrypt}
 	sort.Strings(params)
 	raw := strings.Join(params, "")
 	hash := sha1.Sum([]byte(raw))
-	computed := fmt.Sprintf("%x", hash)
-	return computed == signature
+	return fmt.Sprintf("%x", hash)
 }
 
 // Decrypt 解密企业微信加密消息
 // Base64 解码 → AES-CBC 解密（IV = aesKey[:16]）→ PKCS#7 去填充 → 解析明文 → 验证 corpID
-func (c *cryptoImpl) Decrypt(encrypted string) ([]byte, error) {
+// 配置了轮换密钥时依次尝试，返回最近成功密钥的错误
+// 错误包装 ErrCorpMismatch（CorpID 不一致）或 ErrDecryptFailed（其余失败）
+func (c *cryptoImpl) Decrypt(ctx context.Context, encrypted string) ([]byte, error) {
+	msg, err := c.decrypt(ctx, encrypted)
+	if err != nil && !errors.Is(err, ErrCorpMismatch) {
+		return nil, fmt.Errorf("%w: %w", ErrDecryptFailed, err)
+	}
+	return msg, err
+}
+
+func (c *cryptoImpl) decrypt(ctx context.Context, encrypted string) ([]byte, error) {
 	// 1. Base64 解码
 	ciphertext, err := base64.StdEncoding.DecodeString(encrypted)
 	if err != nil {
@@ -76,12 +150,39 @@ func (c *cryptoImpl) Decrypt(encrypted string) ([]byte, error) {
 		return nil, fmt.Errorf("ciphertext length %d is not a multiple of block size %d", len(ciphertext), aes.BlockSize)
 	}
 
+	allowEmpty := allowEmptyReceiveID(ctx)
+	current := int(c.current.Load())
+	msg, firstErr := c.decryptWith(c.keys[current], ciphertext, allowEmpty)
+	if len(c.keys) == 1 {
+		return msg, firstErr
+	}
+	if firstErr == nil {
+		c.logger.DebugContext(ctx, "wework message decrypted", "corp_id", c.corpID, "key_index", current)
+		return msg, nil
+	}
+	for i, key := range c.keys {
+		if i == current {
+			continue
+		}
+		if msg, err := c.decryptWith(key, ciphertext, allowEmpty); err == nil {
+			if c.current.CompareAndSwap(int32(current), int32(i)) {
+				c.logger.InfoContext(ctx, "wework aes key switched", "corp_id", c.corpID, "key_index", i, "previous_index", current)
+			}
+			c.logger.DebugContext(ctx, "wework message decrypted", "corp_id", c.corpID, "key_index", i)
+			return msg, nil
+		}
+	}
+	return nil, firstErr
+}
+
+// decryptWith 使用指定 AES 密钥解密并校验明文，allowEmpty 为 true 时接受空的 ReceiveId
+func (c *cryptoImpl) decryptWith(aesKey, ciphertext []byte, allowEmpty bool) ([]byte, error) {
 	// 3. AES-CBC 解密，IV = aesKey[:16]
-	block, err := aes.NewCipher(c.aesKey)
+	block, err := aes.NewCipher(aesKey)
 	if err != nil {
 		return nil, fmt.Errorf("new aes cipher: %w", err)
 	}
-	mode := cipher.NewCBCDecrypter(block, c.aesKey[:aes.BlockSize])
+	mode := cipher.NewCBCDecrypter(block, aesKey[:aes.BlockSize])
 	plaintext := make([]byte, len(ciphertext))
 	mode.CryptBlocks(plaintext, ciphertext)
 
@@ -102,9 +203,9 @@ func (c *cryptoImpl) Decrypt(encrypted string) ([]byte, error) {
 	msg := plaintext[20 : 20+msgLen]
 	corpID := string(plaintext[20+msgLen:])
 
-	// 6. 验证 CorpID
-	if corpID != c.corpID {
-		return nil, fmt.Errorf("corp_id mismatch: got %s, want %s", corpID, c.corpID)
+	// 6. 验证 CorpID，智能机器人回调的 ReceiveId 为空字符串
+	if corpID != c.corpID && (corpID != "" || !allowEmpty) {
+		return nil, fmt.Errorf("%w: got %s, want %s", ErrCorpMismatch, corpID, c.corpID)
 	}
 
 	return msg, nil
@@ -131,12 +232,13 @@ func (c *cryptoImpl) Encrypt(plaintext []byte) (string, error) {
 	// 2. PKCS#7 填充
 	padded := pkcs7Pad(buf, aes.BlockSize)
 
-	// 3. AES-CBC 加密，IV = aesKey[:16]
-	block, err := aes.NewCipher(c.aesKey)
+	// 3. AES-CBC 加密，IV = aesKey[:16]，使用最近成功解密的密钥
+	aesKey := c.keys[c.current.Load()]
+	block, err := aes.NewCipher(aesKey)
 	if err != nil {
 		return "", fmt.Errorf("new aes cipher: %w", err)
 	}
-	mode := cipher.NewCBCEncrypter(block, c.aesKey[:aes.BlockSize])
+	mode := cipher.NewCBCEncrypter(block, aesKey[:aes.BlockSize])
 	ciphertext := make([]byte, len(padded))
 	mode.CryptBlocks(ciphertext, padded)
 
@@ -173,3 +275,42 @@ func pkcs7Unpad(data []byte) ([]byte, error) {
 	}
 	return data[:len(data)-padding], nil
 }
+
+// ReloadableCrypto 可在运行时替换密钥的 Crypto，配置热加载时应用轮换后的 Token 和 EncodingAESKey
+type ReloadableCrypto struct {
+	current atomic.Pointer[Crypto]
+}
+
+// NewReloadableCrypto 以 c 为初始密钥创建
+func NewReloadableCrypto(c Crypto) *ReloadableCrypto {
+	r := &ReloadableCrypto{}
+	r.current.Store(&c)
+	return r
+}
+
+// Swap 替换为新的密钥，进行中的请求仍使用替换前取得的实例
+func (r *ReloadableCrypto) Swap(c Crypto) {
+	r.current.Store(&c)
+}
+
+func (r *ReloadableCrypto) load() Crypto { return *r.current.Load() }
+
+// VerifySignature 实现 Crypto 接口
+func (r *ReloadableCrypto) VerifySignature(signature, timestamp, nonce, msgEncrypt string) bool {
+	return r.load().VerifySignature(signature, timestamp, nonce, msgEncrypt)
+}
+
+// Sign 实现 Crypto 接口
+func (r *ReloadableCrypto) Sign(timestamp, nonce, msgEncrypt string) string {
+	return r.load().Sign(timestamp, nonce, msgEncrypt)
+}
+
+// Decrypt 实现 Crypto 接口
+func (r *ReloadableCrypto) Decrypt(ctx context.Context, encrypted string) ([]byte, error) {
+	return r.load().Decrypt(ctx, encrypted)
+}
+
+// Encrypt 实现 Crypto 接口
+func (r *ReloadableCrypto) Encrypt(plaintext []byte) (string, error) {
+	return r.load().Encrypt(plaintext)
+}
@@ -0,0 +1,97 @@
+package wework
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+const testCorpID = "wwcorp001"
+
+// testAESKey 生成随机的 43 字符 EncodingAESKey
+func testAESKey(t *testing.T) string {
+	t.Helper()
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("generate aes key: %v", err)
+	}
+	return strings.TrimSuffix(base64.StdEncoding.EncodeToString(key), "=")
+}
+
+func TestCryptoRotatedKeys(t *testing.T) {
+	oldKey, newKey, otherKey := testAESKey(t), testAESKey(t), testAESKey(t)
+	senders := make(map[string]Crypto)
+	for name, key := range map[string]string{"old": oldKey, "new": newKey, "other": otherKey} {
+		c, err := NewCrypto("token", key, testCorpID)
+		if err != nil {
+			t.Fatalf("new crypto %s: %v", name, err)
+		}
+		senders[name] = c
+	}
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	c, err := NewCrypto("token", newKey, testCorpID, WithRotationKeys(logger, oldKey))
+	if err != nil {
+		t.Fatalf("new crypto: %v", err)
+	}
+
+	// 按顺序执行：解密成功的密钥会成为下一条消息首先尝试的密钥，回复也使用该密钥加密
+	tests := []struct {
+		name     string
+		sender   string
+		wantKey  int
+		wantErr  error
+		wantSwap bool
+	}{
+		{name: "current key", sender: "new", wantKey: 0},
+		{name: "rotation key", sender: "old", wantKey: 1, wantSwap: true},
+		{name: "sticky rotation key", sender: "old", wantKey: 1},
+		{name: "back to current key", sender: "new", wantKey: 0, wantSwap: true},
+		{name: "unknown key", sender: "other", wantErr: ErrDecryptFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs.Reset()
+			want := []byte("<xml><Content>" + tt.name + "</Content></xml>")
+			encrypted, err := senders[tt.sender].Encrypt(want)
+			if err != nil {
+				t.Fatalf("encrypt: %v", err)
+			}
+
+			got, err := c.Decrypt(context.Background(), encrypted)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("decrypt: got %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decrypt: %v", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Fatalf("decrypt: got %q, want %q", got, want)
+			}
+			if s := logs.String(); !strings.Contains(s, "wework message decrypted") || !strings.Contains(s, fmt.Sprintf("key_index=%d", tt.wantKey)) {
+				t.Fatalf("missing key_index=%d debug log, got:\n%s", tt.wantKey, s)
+			}
+			if swapped := strings.Contains(logs.String(), "wework aes key switched"); swapped != tt.wantSwap {
+				t.Fatalf("key switched logged: got %v, want %v", swapped, tt.wantSwap)
+			}
+
+			reply, err := c.Encrypt(want)
+			if err != nil {
+				t.Fatalf("encrypt reply: %v", err)
+			}
+			if got, err := senders[tt.sender].Decrypt(context.Background(), reply); err != nil || !bytes.Equal(got, want) {
+				t.Fatalf("reply not decryptable with the sender key: %q, %v", got, err)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package wework
 import (
 	"encoding/xml"
 	"errors"
+	"fmt"
 )
 
 // CallbackQuery 回调请求的 URL 查询参数
@@ -11,17 +12,19 @@ type CallbackQuery struct {
 	Timestamp    string
 	Nonce        string
 	Echostr      string // 仅 GET 验证时使用
+	// JSON 请求体为 JSON 格式（如智能机器人回调），解析时设置，被动回复也使用 JSON
+	JSON bool
 }
 
-// EncryptedBody POST 请求的加密 XML 消息体
+// EncryptedBody POST 请求的加密消息体，XML 或 JSON 格式
 type EncryptedBody struct {
-	XMLName    xml.Name `xml:"xml"`
-	ToUserName string   `xml:"ToUserName"`
-	AgentID    string   `xml:"AgentID"`
-	Encrypt    string   `xml:"Encrypt"`
+	XMLName    xml.Name `xml:"xml" json:"-"`
+	ToUserName string   `xml:"ToUserName" json:"tousername"`
+	AgentID    string   `xml:"AgentID" json:"agentid"`
+	Encrypt    string   `xml:"Encrypt" json:"encrypt"`
 }
 
-// Message 解密后的企业微信消息
+// Message 解密后的企业微信消息，不同 MsgType 只填充对应的字段
 type Message struct {
 	XMLName      xml.Name `xml:"xml"`
 	ToUserName   string   `xml:"ToUserName"`
@@ -31,14 +34,121 @@ type Message struct {
 	Content      string   `xml:"Content"`
 	MsgID        string   `xml:"MsgId"`
 	AgentID      int64    `xml:"AgentID"`
+
+	// 群聊来源（如智能机器人回调），自建应用消息为空
+	ChatID   string `xml:"ChatId"`
+	ChatType string `xml:"ChatType"` // single | group
+	// ResponseURL 智能机器人 JSON 回调的主动回复地址
+	ResponseURL string `xml:"-"`
+
+	// 图片、语音、视频、文件
+	MediaID      string `xml:"MediaId"`
+	PicURL       string `xml:"PicUrl"`       // 图片、链接封面
+	Format       string `xml:"Format"`       // 语音格式，如 amr
+	ThumbMediaID string `xml:"ThumbMediaId"` // 视频缩略图
+
+	// 位置
+	LocationX float64 `xml:"Location_X"` // 纬度
+	LocationY float64 `xml:"Location_Y"` // 经度
+	Scale     int     `xml:"Scale"`
+	Label     string  `xml:"Label"`
+
+	// 链接
+	Title       string `xml:"Title"`
+	Description string `xml:"Description"`
+	URL         string `xml:"Url"`
+}
+
+// ReplyMessage 被动回复的明文消息，通常由 NewTextReply / NewImageReply / NewNewsReply 构造
+// 序列化规则见 MarshalXML
+type ReplyMessage struct {
+	ToUserName   string
+	FromUserName string
+	CreateTime   int64     // 为 0 时使用序列化时的当前时间
+	MsgType      string    // text | image | news
+	Content      string    // text
+	MediaID      string    // image
+	Articles     []Article // news，1~8 条
+}
+
+// EncryptedReply 被动回复的加密 XML 响应体
+type EncryptedReply struct {
+	XMLName      xml.Name `xml:"xml"`
+	Encrypt      string   `xml:"Encrypt"`
+	MsgSignature string   `xml:"MsgSignature"`
+	TimeStamp    string   `xml:"TimeStamp"`
+	Nonce        string   `xml:"Nonce"`
 }
 
 // MsgType 消息类型常量
 const (
-	MsgTypeText  = "text"
-	MsgTypeImage = "image"
-	MsgTypeEvent = "event"
+	MsgTypeText         = "text"
+	MsgTypeImage        = "image"
+	MsgTypeVoice        = "voice"
+	MsgTypeVideo        = "video"
+	MsgTypeFile         = "file"
+	MsgTypeLocation     = "location"
+	MsgTypeLink         = "link"
+	MsgTypeEvent        = "event"
+	MsgTypeMarkdown     = "markdown"
+	MsgTypeNews         = "news"
+	MsgTypeTextCard     = "textcard"
+	MsgTypeTemplateCard = "template_card"
+)
+
+// 消息处理结果常量，见 Observer.OnMessage
+const (
+	OutcomeIgnored   = "ignored"
+	OutcomeForwarded = "forwarded"
+	OutcomeHandled   = "handled"   // 事件已交给处理器
+	OutcomeThrottled = "throttled" // 超出用户频率限制或每日额度
+	OutcomeDenied    = "denied"    // 访问控制拒绝
 )
 
 // ErrInvalidSignature 签名验证失败错误
 var ErrInvalidSignature = errors.New("invalid signature")
+
+// 回调处理错误，回调处理器据此选择 HTTP 状态码和指标标签
+var (
+	// ErrMalformedBody 请求体或解密后的明文不是合法的 XML
+	ErrMalformedBody = errors.New("malformed body")
+	// ErrDecryptFailed 签名有效但解密失败，通常是 EncodingAESKey 配置错误
+	ErrDecryptFailed = errors.New("decrypt failed")
+	// ErrCorpMismatch 解密后的 CorpID 与配置不一致，消息不属于本企业或应用
+	ErrCorpMismatch = errors.New("corp_id mismatch")
+	// ErrUnsupportedMsgType 明文缺少 MsgType，无法路由
+	ErrUnsupportedMsgType = errors.New("unsupported msg type")
+)
+
+// 重放防护错误
+var (
+	// ErrStaleTimestamp 请求时间戳超出允许的时间窗口
+	ErrStaleTimestamp = errors.New("timestamp outside allowed window")
+	// ErrReplayedRequest 同一 timestamp/nonce 的请求已处理过
+	ErrReplayedRequest = errors.New("replayed request")
+)
+
+// 企业微信服务端 API 错误码
+const (
+	ErrCodeInvalidToken = 40014 // 不合法的 access_token
+	ErrCodeTokenExpired = 42001 // access_token 已过期
+)
+
+// APIError 企业微信服务端 API 返回的非零 errcode
+type APIError struct {
+	Code int
+	Msg  string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("wework api error %d: %s", e.Code, e.Msg)
+}
+
+// IsTokenInvalid 判断错误是否由 access_token 失效引起，需要强制刷新后重试
+func IsTokenInvalid(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.Code == ErrCodeInvalidToken || apiErr.Code == ErrCodeTokenExpired
+}
@@ -0,0 +1,46 @@
+package wework
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// drainCancelWait 取消后台转发后等待其写入 outbox 并退出的时长
+const drainCancelWait = time.Second
+
+// shutdownContext 保留请求 ctx 中的值（trace、日志字段等），取消信号来自服务关闭
+type shutdownContext struct {
+	context.Context
+	values context.Context
+}
+
+func (c shutdownContext) Value(key any) any { return c.values.Value(key) }
+
+// detach 返回脱离请求生命周期的 ctx，供 goSafe 跟踪的后台任务使用，Drain 超时后取消
+func (s *serviceImpl) detach(ctx context.Context) context.Context {
+	return shutdownContext{Context: s.stopCtx, values: ctx}
+}
+
+// Drain 实现 Service 接口
+// 等待后台任务完成；ctx 结束时取消仍在进行的后台任务，失败的消息按常规流程写入 outbox，再最多等待 drainCancelWait
+func (s *serviceImpl) Drain(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.jobs.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	s.stop()
+	s.logger.Warn("shutdown timeout reached, cancelling background tasks")
+	select {
+	case <-done:
+	case <-time.After(drainCancelWait):
+	}
+	return fmt.Errorf("drain background tasks: %w", ctx.Err())
+}
@@ -0,0 +1,82 @@
+package wework
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"go.uber.org/mock/gomock"
+
+	"go-wework-svc/internal/shared"
+)
+
+// holdProcessor 在 hold 时长内或 ctx 取消前阻塞，并记录结束原因和 ctx 中的请求 ID
+type holdProcessor struct {
+	hold      time.Duration
+	err       chan error
+	requestID chan string
+}
+
+func (p *holdProcessor) Name() string { return "hold" }
+
+func (p *holdProcessor) Process(ctx context.Context, _ *Request) error {
+	p.requestID <- shared.RequestID(ctx)
+	select {
+	case <-time.After(p.hold):
+		p.err <- nil
+	case <-ctx.Done():
+		p.err <- ctx.Err()
+	}
+	return nil
+}
+
+func TestDrain(t *testing.T) {
+	tests := []struct {
+		name         string
+		hold         time.Duration
+		drainTimeout time.Duration
+		wantDrainErr error
+		wantJobErr   error
+	}{
+		{name: "job finishes before the deadline", hold: 0, drainTimeout: time.Second},
+		{name: "job outlives its request", hold: 50 * time.Millisecond, drainTimeout: time.Second},
+		{name: "running job is cancelled on timeout", hold: time.Minute, drainTimeout: 20 * time.Millisecond,
+			wantDrainErr: context.DeadlineExceeded, wantJobErr: context.Canceled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewService(NewMockCrypto(gomock.NewController(t)), nil, slog.New(slog.DiscardHandler)).(*serviceImpl)
+			p := &holdProcessor{hold: tt.hold, err: make(chan error, 1), requestID: make(chan string, 1)}
+			h := s.processorMiddleware([]MessageProcessor{p})(HandlerFunc(func(context.Context, *Request) ([]byte, error) {
+				return nil, nil
+			}))
+
+			// 回调应答后请求 ctx 即被取消，后台任务不应随之结束
+			reqCtx, cancelReq := context.WithCancel(shared.WithRequestID(context.Background(), "req-1"))
+			if _, err := h.Handle(reqCtx, &Request{Message: Message{MsgID: "m1", MsgType: MsgTypeText}}); err != nil {
+				t.Fatalf("handle: %v", err)
+			}
+			cancelReq()
+			if id := <-p.requestID; id != "req-1" {
+				t.Fatalf("job request id: got %q, want %q", id, "req-1")
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), tt.drainTimeout)
+			defer cancel()
+			err := s.Drain(ctx)
+			if tt.wantDrainErr == nil && err != nil || tt.wantDrainErr != nil && !errors.Is(err, tt.wantDrainErr) {
+				t.Fatalf("drain: got %v, want %v", err, tt.wantDrainErr)
+			}
+			select {
+			case err := <-p.err:
+				if !errors.Is(err, tt.wantJobErr) {
+					t.Fatalf("job: got %v, want %v", err, tt.wantJobErr)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("job still running after drain")
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package wework
+
+import (
+	"context"
+	"encoding/xml"
+)
+
+// Event 企业微信事件回调（MsgType 为 event）
+type Event struct {
+	XMLName      xml.Name `xml:"xml"`
+	ToUserName   string   `xml:"ToUserName"`
+	FromUserName string   `xml:"FromUserName"`
+	CreateTime   int64    `xml:"CreateTime"`
+	MsgType      string   `xml:"MsgType"`
+	AgentID      int64    `xml:"AgentID"`
+	Event        string   `xml:"Event"`
+	EventKey     string   `xml:"EventKey"` // click 为菜单 key，view 为跳转 URL，template_card_event 为按钮 key
+
+	// 微信客服（Event 为 kf_msg_or_event）
+	Token    string `xml:"Token"`
+	OpenKfID string `xml:"OpenKfId"`
+
+	// 上报地理位置（Event 为 LOCATION）
+	Latitude  float64 `xml:"Latitude"`
+	Longitude float64 `xml:"Longitude"`
+	Precision float64 `xml:"Precision"`
+
+	// 模板卡片按钮点击（Event 为 template_card_event）
+	TaskID       string `xml:"TaskId"`
+	CardType     string `xml:"CardType"`
+	ResponseCode string `xml:"ResponseCode"` // 用于更新卡片，72 小时内有效且只能使用一次
+
+	// 客户联系变更（Event 为 change_external_contact），FromUserName 固定为 sys
+	ChangeType     string `xml:"ChangeType"`
+	UserID         string `xml:"UserID"` // 跟进成员
+	ExternalUserID string `xml:"ExternalUserID"`
+	State          string `xml:"State"`       // 添加时「联系我」配置的渠道参数
+	WelcomeCode    string `xml:"WelcomeCode"` // 20 秒内有效，用于发送新客户欢迎语
+	FailReason     string `xml:"FailReason"`
+
+	// 审批状态变化（Event 为 sys_approval_change 或 open_approval_change）
+	ApprovalInfo *ApprovalInfo `xml:"ApprovalInfo"`
+}
+
+// 事件类型常量
+const (
+	EventSubscribe    = "subscribe"
+	EventUnsubscribe  = "unsubscribe"
+	EventEnterAgent   = "enter_agent"
+	EventClick        = "click"
+	EventView         = "view"
+	EventLocation     = "LOCATION"
+	EventTemplateCard = "template_card_event"
+
+	EventChangeExternalContact = "change_external_contact"
+	EventSysApprovalChange     = "sys_approval_change"  // 审批应用中的审批
+	EventOpenApprovalChange    = "open_approval_change" // 自建应用审批流程（第三方审批）
+)
+
+// EventHandler 事件处理器
+type EventHandler interface {
+	// HandleEvent 处理事件，返回非空 reply 时通过 Sender 主动发送给事件触发者
+	HandleEvent(ctx context.Context, ev Event) (reply string, err error)
+}
+
+// EventHandlerFunc 函数形式的 EventHandler
+type EventHandlerFunc func(ctx context.Context, ev Event) (string, error)
+
+// HandleEvent 实现 EventHandler 接口
+func (f EventHandlerFunc) HandleEvent(ctx context.Context, ev Event) (string, error) {
+	return f(ctx, ev)
+}
+
+// StaticReply 返回固定回复的事件处理器，如进入应用时的欢迎语
+func StaticReply(text string) EventHandler {
+	return EventHandlerFunc(func(context.Context, Event) (string, error) {
+		return text, nil
+	})
+}
+
+// eventHandler 将 EventHandler 适配为 Handler，在回调应答后异步执行并投递回复
+func (s *serviceImpl) eventHandler(h EventHandler) Handler {
+	return HandlerFunc(func(ctx context.Context, req *Request) ([]byte, error) {
+		s.observer.OnMessage(ctx, req.Message, OutcomeHandled)
+		asyncCtx, ev := s.detach(ctx), *req.Event
+		s.goSafe(asyncCtx, "event", req.Message, func() { s.dispatchEvent(asyncCtx, h, ev) })
+		return nil, nil
+	})
+}
+
+// dispatchEvent 调用事件处理器并投递回复
+func (s *serviceImpl) dispatchEvent(ctx context.Context, h EventHandler, ev Event) {
+	reply, err := h.HandleEvent(ctx, ev)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "event handler failed",
+			"event", ev.Event,
+			"event_key", ev.EventKey,
+			"from_user", ev.FromUserName,
+			"error", err,
+		)
+		return
+	}
+	s.logger.InfoContext(ctx, "event handled", "event", ev.Event, "from_user", ev.FromUserName)
+	s.deliver(ctx, Message{FromUserName: ev.FromUserName}, reply)
+}
@@ -0,0 +1,129 @@
+package wework
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// 客户联系变更类型（Event 为 change_external_contact 时的 ChangeType）
+const (
+	ChangeAddExternalContact     = "add_external_contact"      // 成员添加了客户
+	ChangeAddHalfExternalContact = "add_half_external_contact" // 客户添加了成员，成员尚未通过
+	ChangeEditExternalContact    = "edit_external_contact"
+	ChangeDelExternalContact     = "del_external_contact" // 成员删除了客户
+	ChangeDelFollowUser          = "del_follow_user"      // 客户删除了成员
+	ChangeTransferFail           = "transfer_fail"
+	ChangeMsgAuditApproved       = "msg_audit_approved" // 客户同意会话存档
+)
+
+// ExternalContact 外部联系人（客户）的基本信息
+type ExternalContact struct {
+	Name     string
+	CorpName string // 企业微信客户的企业简称，微信客户为空
+}
+
+// ExternalContactClient 客户联系 API
+type ExternalContactClient interface {
+	// SendWelcome 使用添加事件中的 WelcomeCode 向新客户发送欢迎语
+	SendWelcome(ctx context.Context, welcomeCode, content string) error
+	// Contact 查询客户信息
+	Contact(ctx context.Context, externalUserID string) (*ExternalContact, error)
+}
+
+// ExternalContactHook 客户联系变更的扩展回调，在内置处理之后依次调用
+type ExternalContactHook func(ctx context.Context, ev Event) error
+
+// ExternalContactPolicy 客户联系变更事件的处理方式
+// 模板可用 {{.UserID}}、{{.ExternalUserID}}、{{.ExternalName}}、{{.CorpName}}、{{.State}}、{{.ChangeType}}
+type ExternalContactPolicy struct {
+	Welcome *template.Template            // 添加客户且事件带 WelcomeCode 时发送给客户的欢迎语
+	Notices map[string]*template.Template // 按 ChangeType 通过 Sender 通知跟进成员
+	Client  ExternalContactClient
+	Hooks   []ExternalContactHook
+}
+
+// externalInput 客户联系模板可用的字段
+type externalInput struct {
+	UserID         string
+	ExternalUserID string
+	ExternalName   string
+	CorpName       string
+	State          string
+	ChangeType     string
+}
+
+// WithExternalContact 处理 change_external_contact 事件：向新客户发送欢迎语、通知跟进成员并调用扩展回调
+// 需在客户联系中将应用设为可调用应用，并配置 Secret
+func WithExternalContact(p ExternalContactPolicy) Option {
+	return func(s *serviceImpl) {
+		s.registry.RegisterEvent(EventChangeExternalContact, s.eventHandler(EventHandlerFunc(func(ctx context.Context, ev Event) (string, error) {
+			s.externalContact(ctx, p, ev)
+			return "", nil
+		})))
+	}
+}
+
+// externalContact 处理一次客户联系变更，各步骤失败时记录日志并继续
+func (s *serviceImpl) externalContact(ctx context.Context, p ExternalContactPolicy, ev Event) {
+	s.logger.InfoContext(ctx, "external contact changed",
+		"change_type", ev.ChangeType,
+		"user_id", ev.UserID,
+		"external_user_id", ev.ExternalUserID,
+		"state", ev.State,
+	)
+	welcome := ev.ChangeType == ChangeAddExternalContact && ev.WelcomeCode != "" && p.Welcome != nil
+	notice := p.Notices[ev.ChangeType]
+
+	fail := func(err error) {
+		s.logger.WarnContext(ctx, "external contact handling failed", "change_type", ev.ChangeType, "external_user_id", ev.ExternalUserID, "error", err)
+	}
+
+	in := externalInput{
+		UserID:         ev.UserID,
+		ExternalUserID: ev.ExternalUserID,
+		State:          ev.State,
+		ChangeType:     ev.ChangeType,
+	}
+	// 删除事件发生后通常已查不到客户信息
+	if (welcome || notice != nil) && p.Client != nil && ev.ExternalUserID != "" && ev.ChangeType != ChangeDelFollowUser && ev.ChangeType != ChangeDelExternalContact {
+		if c, err := p.Client.Contact(ctx, ev.ExternalUserID); err != nil {
+			fail(fmt.Errorf("get external contact: %w", err))
+		} else {
+			in.ExternalName, in.CorpName = c.Name, c.CorpName
+		}
+	}
+
+	if welcome && p.Client != nil {
+		text, err := render(p.Welcome, in)
+		if err == nil && text != "" {
+			err = p.Client.SendWelcome(ctx, ev.WelcomeCode, text)
+		}
+		if err != nil {
+			fail(fmt.Errorf("send welcome: %w", err))
+		}
+	}
+	if notice != nil && ev.UserID != "" {
+		text, err := render(notice, in)
+		if err != nil {
+			fail(err)
+		} else {
+			s.deliver(ctx, Message{FromUserName: ev.UserID}, text)
+		}
+	}
+	for _, h := range p.Hooks {
+		if err := h(ctx, ev); err != nil {
+			fail(err)
+		}
+	}
+}
+
+// render 渲染模板并去掉首尾空白
+func render(t *template.Template, data any) (string, error) {
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("render %s: %w", t.Name(), err)
+	}
+	return strings.TrimSpace(b.String()), nil
+}
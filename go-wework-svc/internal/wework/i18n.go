@@ -0,0 +1,47 @@
+package wework
+
+import "context"
+
+// 系统回复的文案键，见 Localizer
+const (
+	ReplyWelcome       = "welcome"        // 关注和进入应用时的欢迎语
+	ReplyRateLimited   = "rate_limited"   // 超出每分钟限制
+	ReplyQuotaExceeded = "quota_exceeded" // 超出每日额度
+	ReplyAccessDenied  = "access_denied"  // 无权使用机器人
+	ReplyInputBlocked  = "input_blocked"  // 用户消息被审核拦截
+	ReplyOutputBlocked = "output_blocked" // AI 回复被审核拦截
+	ReplyAIError       = "ai_error"       // 转发 AI 失败
+)
+
+// Localizer 按成员的语言返回系统回复
+type Localizer interface {
+	// Text 以 data 渲染 key 在成员语言下的文案
+	// 成员使用默认语言或该语言没有对应文案时返回 fallback（已配置的回复），fallback 为空时使用默认语言的文案
+	Text(ctx context.Context, userID, key, fallback string, data any) string
+}
+
+// WithLocalizer 启用系统回复的多语言文案，未配置回复的场景也会使用默认语言的内置文案
+func WithLocalizer(l Localizer) Option {
+	return func(s *serviceImpl) { s.localizer = l }
+}
+
+// replyInput 系统回复模板可用的字段
+type replyInput struct {
+	User string
+}
+
+// localize 返回成员语言的系统回复，未启用多语言时返回 fallback
+func (s *serviceImpl) localize(ctx context.Context, user, key, fallback string, data any) string {
+	if s.localizer == nil {
+		return fallback
+	}
+	if data == nil {
+		data = replyInput{User: user}
+	}
+	return s.localizer.Text(ctx, user, key, fallback, data)
+}
+
+// errorReply 转发 AI 失败时回复给用户的提示，未启用多语言时为空，不回复
+func (s *serviceImpl) errorReply(ctx context.Context, msg Message) string {
+	return s.localize(ctx, msg.FromUserName, ReplyAIError, "", nil)
+}
@@ -0,0 +1,168 @@
+package wework
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 部分回调模式（如智能机器人）的请求体与明文为 JSON，加解密与签名规则与 XML 相同：
+// 请求体 {"encrypt": "..."}，明文字段为小写，被动回复为 {"encrypt","msgsignature","timestamp","nonce"}
+
+// isJSONBody 按首个非空白字符判断请求体或明文是否为 JSON
+func isJSONBody(body []byte) bool {
+	body = bytes.TrimSpace(body)
+	return len(body) > 0 && body[0] == '{'
+}
+
+// jsonMessage JSON 格式回调的明文消息
+type jsonMessage struct {
+	MsgID      string `json:"msgid"`
+	AIBotID    string `json:"aibotid"`
+	ChatID     string `json:"chatid"`
+	ChatType   string `json:"chattype"`
+	CreateTime int64  `json:"create_time"`
+	From       struct {
+		UserID string `json:"userid"`
+	} `json:"from"`
+	ResponseURL string `json:"response_url"`
+	MsgType     string `json:"msgtype"`
+
+	Text  jsonText  `json:"text"`
+	Image jsonImage `json:"image"`
+	Voice jsonText  `json:"voice"` // 语音已转为文字
+	File  struct {
+		URL string `json:"url"`
+	} `json:"file"`
+	Mixed struct {
+		Items []struct {
+			MsgType string    `json:"msgtype"`
+			Text    jsonText  `json:"text"`
+			Image   jsonImage `json:"image"`
+		} `json:"msg_item"`
+	} `json:"mixed"`
+	Event struct {
+		EventType string `json:"eventtype"`
+		EventKey  string `json:"event_key"`
+		TaskID    string `json:"task_id"`
+	} `json:"event"`
+}
+
+type jsonText struct {
+	Content string `json:"content"`
+}
+
+type jsonImage struct {
+	URL string `json:"url"`
+}
+
+// parseJSONMessage 将 JSON 明文转换为 Message，事件消息同时返回 Event
+// 语音按转写后的文本处理，图文混排合并文本并取第一张图片
+func parseJSONMessage(plaintext []byte) (Message, *Event, error) {
+	var m jsonMessage
+	if err := json.Unmarshal(plaintext, &m); err != nil {
+		return Message{}, nil, err
+	}
+	msg := Message{
+		ToUserName:   m.AIBotID,
+		FromUserName: m.From.UserID,
+		CreateTime:   m.CreateTime,
+		MsgType:      m.MsgType,
+		MsgID:        m.MsgID,
+		ChatID:       m.ChatID,
+		ChatType:     m.ChatType,
+		ResponseURL:  m.ResponseURL,
+	}
+	if msg.CreateTime == 0 {
+		msg.CreateTime = time.Now().Unix()
+	}
+	switch m.MsgType {
+	case MsgTypeText:
+		msg.Content = m.Text.Content
+	case MsgTypeVoice:
+		msg.MsgType = MsgTypeText
+		msg.Content = m.Voice.Content
+	case MsgTypeImage:
+		msg.PicURL = m.Image.URL
+	case MsgTypeFile:
+		msg.URL = m.File.URL
+	case "mixed":
+		var texts []string
+		for _, item := range m.Mixed.Items {
+			switch item.MsgType {
+			case MsgTypeText:
+				texts = append(texts, item.Text.Content)
+			case MsgTypeImage:
+				if msg.PicURL == "" {
+					msg.PicURL = item.Image.URL
+				}
+			}
+		}
+		msg.MsgType = MsgTypeText
+		msg.Content = strings.Join(texts, "\n")
+	case MsgTypeEvent:
+		return msg, &Event{
+			ToUserName:   msg.ToUserName,
+			FromUserName: msg.FromUserName,
+			CreateTime:   msg.CreateTime,
+			MsgType:      MsgTypeEvent,
+			Event:        m.Event.EventType,
+			EventKey:     m.Event.EventKey,
+			TaskID:       m.Event.TaskID,
+		}, nil
+	}
+	return msg, nil, nil
+}
+
+// jsonReply JSON 格式回调的被动回复明文，只支持文本
+type jsonReply struct {
+	MsgType string   `json:"msgtype"`
+	Text    jsonText `json:"text"`
+}
+
+// jsonEncryptedReply JSON 格式回调的加密被动回复
+type jsonEncryptedReply struct {
+	Encrypt      string `json:"encrypt"`
+	MsgSignature string `json:"msgsignature"`
+	TimeStamp    int64  `json:"timestamp"`
+	Nonce        string `json:"nonce"`
+}
+
+// marshalJSONReply 序列化被动回复明文，JSON 回调暂不支持图片和图文回复
+func marshalJSONReply(reply ReplyMessage) ([]byte, error) {
+	if reply.MsgType != MsgTypeText {
+		return nil, fmt.Errorf("%s reply is not supported for json callbacks", reply.MsgType)
+	}
+	if reply.Content == "" {
+		return nil, errors.New("text reply requires content")
+	}
+	return json.Marshal(jsonReply{MsgType: MsgTypeText, Text: jsonText{Content: reply.Content}})
+}
+
+// encryptJSONReply 加密 JSON 格式的被动回复
+func (s *serviceImpl) encryptJSONReply(q CallbackQuery, reply ReplyMessage) ([]byte, error) {
+	plain, err := marshalJSONReply(reply)
+	if err != nil {
+		return nil, fmt.Errorf("marshal reply: %w", err)
+	}
+	encrypted, err := s.crypto.Encrypt(plain)
+	if err != nil {
+		return nil, fmt.Errorf("encrypt reply: %w", err)
+	}
+
+	timestamp := time.Now().Unix()
+	out, err := json.Marshal(jsonEncryptedReply{
+		Encrypt:      encrypted,
+		MsgSignature: s.crypto.Sign(strconv.FormatInt(timestamp, 10), q.Nonce, encrypted),
+		TimeStamp:    timestamp,
+		Nonce:        q.Nonce,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal encrypted reply: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,39 @@
+package wework
+
+import "strings"
+
+// 互联企业（含上下游）成员在回调的 FromUserName 和发送接收人中以 CorpID/UserID 表示，
+// 本企业成员仍为 UserID；发送给互联企业成员需走 linkedcorp/message/send
+
+// SplitLinkedUser 解析互联企业成员 ID，本企业成员返回 ok 为 false
+func SplitLinkedUser(id string) (corpID, userID string, ok bool) {
+	corpID, userID, ok = strings.Cut(id, "/")
+	if !ok || corpID == "" || userID == "" {
+		return "", id, false
+	}
+	return corpID, userID, true
+}
+
+// IsLinkedUser 是否为互联企业成员 ID
+func IsLinkedUser(id string) bool {
+	_, _, ok := SplitLinkedUser(id)
+	return ok
+}
+
+// LinkedCorpID 发送者所属的互联企业 CorpID，本企业成员返回空字符串
+func (m Message) LinkedCorpID() string {
+	corpID, _, _ := SplitLinkedUser(m.FromUserName)
+	return corpID
+}
+
+// Linked 接收人中是否包含互联企业的成员或部门（部门格式为 LinkedID/DepartmentID）
+func (m OutgoingMessage) Linked() bool {
+	for _, list := range []string{m.ToUser, m.ToParty} {
+		for id := range strings.SplitSeq(list, "|") {
+			if IsLinkedUser(id) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,175 @@
+package wework
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// 回复格式
+const (
+	ReplyFormatRaw      = "raw"      // 原样以文本消息发送
+	ReplyFormatText     = "text"     // 去掉 Markdown 标记后以文本消息发送
+	ReplyFormatMarkdown = "markdown" // 转换为企业微信支持的 Markdown 子集，主动发送时使用 markdown 消息
+)
+
+// 企业微信单条消息的字节上限
+const (
+	maxTextBytes     = 2048
+	maxMarkdownBytes = 4096
+)
+
+// ReplyFormat AI 回复的格式转换与分段策略
+type ReplyFormat struct {
+	Format   string // raw（默认）| text | markdown；被动回复只支持文本消息，markdown 按 text 处理
+	MaxBytes int    // 单条消息的字节上限，0 或超过企业微信上限时使用 2048（text）/ 4096（markdown）
+}
+
+// WithReplyFormat 设置回复的格式转换方式，超长回复按上限拆分为多条发送
+func WithReplyFormat(f ReplyFormat) Option {
+	return func(s *serviceImpl) { s.replyFormat = f }
+}
+
+// formatReply 转换回复格式并按上限拆分，passive 为 true 时只能使用文本消息
+func (s *serviceImpl) formatReply(reply string, passive bool) (msgType string, parts []string) {
+	f := s.replyFormat
+	msgType, limit := MsgTypeText, maxTextBytes
+	switch {
+	case f.Format == ReplyFormatMarkdown && !passive:
+		msgType, limit = MsgTypeMarkdown, maxMarkdownBytes
+		reply = toWeWorkMarkdown(reply)
+	case f.Format == ReplyFormatText || f.Format == ReplyFormatMarkdown:
+		reply = toPlainText(reply)
+	}
+	if f.MaxBytes > 0 && f.MaxBytes < limit {
+		limit = f.MaxBytes
+	}
+	return msgType, splitReply(reply, limit)
+}
+
+var (
+	mdFence     = regexp.MustCompile("^\\s*(```|~~~)")
+	mdHeading   = regexp.MustCompile(`^(#{1,6})\s+`)
+	mdImage     = regexp.MustCompile(`!\[([^\]]*)\]\(([^)\s]+)[^)]*\)`)
+	mdLink      = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)[^)]*\)`)
+	mdBold      = regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`)
+	mdStrike    = regexp.MustCompile(`~~([^~]+)~~`)
+	mdItalic    = regexp.MustCompile(`(^|[^*\w])\*([^*\s][^*]*)\*`)
+	mdCode      = regexp.MustCompile("`([^`]+)`")
+	mdQuote     = regexp.MustCompile(`^\s*>\s?`)
+	mdBullet    = regexp.MustCompile(`^(\s*)[-*+]\s+`)
+	mdRule      = regexp.MustCompile(`^\s*([-*_])(\s*[-*_]){2,}\s*$`)
+	mdTableSep  = regexp.MustCompile(`^\s*\|?\s*:?-{3,}:?\s*(\|\s*:?-{3,}:?\s*)*\|?\s*$`)
+	mdTableEdge = regexp.MustCompile(`^\s*\|(.*)\|\s*$`)
+)
+
+// toWeWorkMarkdown 转换为企业微信支持的 Markdown 子集：保留标题、加粗、链接、行内代码和引用，
+// 图片转为链接，去掉斜体、删除线、分隔线和代码块围栏，表格转为以 | 分隔的文本行
+func toWeWorkMarkdown(s string) string {
+	return convertMarkdown(s, func(line string) string {
+		line = mdImage.ReplaceAllStringFunc(line, func(m string) string {
+			sub := mdImage.FindStringSubmatch(m)
+			return "[" + cmp.Or(sub[1], "图片") + "](" + sub[2] + ")"
+		})
+		line = mdBold.ReplaceAllString(line, "**$1$2**")
+		line = mdStrike.ReplaceAllString(line, "$1")
+		line = mdItalic.ReplaceAllString(line, "$1$2")
+		return line
+	})
+}
+
+// toPlainText 去掉 Markdown 标记，链接和图片保留地址，供文本消息使用
+func toPlainText(s string) string {
+	return convertMarkdown(s, func(line string) string {
+		line = mdHeading.ReplaceAllString(line, "")
+		line = mdQuote.ReplaceAllString(line, "")
+		line = mdImage.ReplaceAllStringFunc(line, func(m string) string {
+			sub := mdImage.FindStringSubmatch(m)
+			return strings.TrimSpace(sub[1] + " " + sub[2])
+		})
+		line = mdLink.ReplaceAllString(line, "$1 ($2)")
+		line = mdBold.ReplaceAllString(line, "$1$2")
+		line = mdStrike.ReplaceAllString(line, "$1")
+		line = mdItalic.ReplaceAllString(line, "$1$2")
+		line = mdCode.ReplaceAllString(line, "$1")
+		return line
+	})
+}
+
+// convertMarkdown 逐行转换，代码块内容原样保留，inline 处理代码块之外的行
+func convertMarkdown(s string, inline func(string) string) string {
+	lines := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	out := make([]string, 0, len(lines))
+	inCode := false
+	for _, line := range lines {
+		if mdFence.MatchString(line) {
+			inCode = !inCode
+			continue
+		}
+		if inCode {
+			out = append(out, line)
+			continue
+		}
+		switch {
+		case mdRule.MatchString(line), mdTableSep.MatchString(line):
+			continue
+		case mdTableEdge.MatchString(line):
+			line = strings.TrimSpace(mdTableEdge.ReplaceAllString(line, "$1"))
+		}
+		line = mdBullet.ReplaceAllString(line, "$1• ")
+		out = append(out, inline(line))
+	}
+	return strings.TrimSpace(strings.Join(out, "\n"))
+}
+
+// partSuffixBytes 分段序号 "\n(10/10)" 预留的字节数
+const partSuffixBytes = 10
+
+// splitReply 将回复按字节上限拆分，依次优先在空行、换行、句末标点处断开，不会拆开多字节字符
+// 拆分为多条时在每条末尾附加序号
+func splitReply(s string, limit int) []string {
+	if s == "" {
+		return nil
+	}
+	if len(s) <= limit {
+		return []string{s}
+	}
+	limit -= partSuffixBytes
+	var parts []string
+	for len(s) > limit {
+		cut := cutPoint(s, limit)
+		parts = append(parts, strings.TrimRight(s[:cut], " \n"))
+		s = strings.TrimLeft(s[cut:], " \n")
+	}
+	if s != "" {
+		parts = append(parts, s)
+	}
+	for i := range parts {
+		parts[i] += fmt.Sprintf("\n(%d/%d)", i+1, len(parts))
+	}
+	return parts
+}
+
+// cutPoint 返回不超过 limit 的断开位置，优先位置需在后半段，避免产生过短的分段
+func cutPoint(s string, limit int) int {
+	for limit > 0 && !utf8.RuneStart(s[limit]) {
+		limit--
+	}
+	if limit == 0 {
+		_, size := utf8.DecodeRuneInString(s)
+		return size
+	}
+	window := s[:limit]
+	for _, sep := range []string{"\n\n", "\n"} {
+		if i := strings.LastIndex(window, sep); i > limit/2 {
+			return i + len(sep)
+		}
+	}
+	if i := strings.LastIndexAny(window, "。！？；.!?;"); i > limit/2 {
+		_, size := utf8.DecodeRuneInString(window[i:])
+		return i + size
+	}
+	return limit
+}
@@ -0,0 +1,186 @@
+package wework
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"go-wework-svc/internal/ai"
+	"go-wework-svc/internal/extract"
+)
+
+// ErrMediaTooLarge 素材超过下载大小上限
+var ErrMediaTooLarge = errors.New("media too large")
+
+// Media 下载到本地临时文件的素材，使用完毕后需调用 Remove
+type Media struct {
+	MediaID     string
+	FileName    string // 响应 Content-Disposition 中的文件名，可能为空
+	ContentType string
+	Size        int64
+	Path        string // 临时文件路径
+}
+
+// Remove 删除临时文件
+func (m *Media) Remove() error {
+	if err := os.Remove(m.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// MediaDownloader 下载消息中 MediaId 引用的图片、语音、视频和文件
+type MediaDownloader interface {
+	// Download 下载素材到临时文件，超过大小上限时返回 ErrMediaTooLarge
+	Download(ctx context.Context, mediaID string) (*Media, error)
+}
+
+// MediaUploader 上传临时素材，用于以图片、文件消息发送 AI 回复中引用的内容
+type MediaUploader interface {
+	// Upload 上传素材，mediaType 为 image | voice | file，返回 3 天内有效的 media_id
+	Upload(ctx context.Context, mediaType, fileName string, data []byte) (mediaID string, err error)
+	// UploadURL 下载地址（或 data URL）的内容后上传
+	UploadURL(ctx context.Context, mediaType, rawURL string) (mediaID string, err error)
+}
+
+// WithMediaDownloader 设置素材下载器，用于处理非文本消息的内容，需配置 Secret
+func WithMediaDownloader(d MediaDownloader) Option {
+	return func(s *serviceImpl) { s.media = d }
+}
+
+// WithVisionInput 图片消息下载后随 AI 请求发送图片内容，供支持视觉输入的后端使用，需同时设置 MediaDownloader
+func WithVisionInput() Option {
+	return func(s *serviceImpl) { s.vision = true }
+}
+
+// attachImage 下载图片并写入附件，失败时保留图片地址，由后端自行决定如何处理
+func (s *serviceImpl) attachImage(ctx context.Context, a *ai.Attachment) {
+	if !s.vision || s.media == nil || a.MediaID == "" {
+		return
+	}
+	m, err := s.media.Download(ctx, a.MediaID)
+	if err != nil {
+		s.logger.WarnContext(ctx, "failed to download image", "media_id", a.MediaID, "error", err)
+		return
+	}
+	defer func() {
+		if err := m.Remove(); err != nil {
+			s.logger.WarnContext(ctx, "failed to remove media temp file", "path", m.Path, "error", err)
+		}
+	}()
+	data, err := os.ReadFile(m.Path)
+	if err != nil {
+		s.logger.WarnContext(ctx, "failed to read image", "media_id", a.MediaID, "error", err)
+		return
+	}
+	a.Data = data
+	a.MimeType = m.ContentType
+	if !strings.HasPrefix(a.MimeType, "image/") {
+		a.MimeType = http.DetectContentType(data)
+	}
+}
+
+// WithFileText 提取文件消息的正文随 AI 请求发送，最多保留 maxChars 个字符，需同时设置 MediaDownloader
+func WithFileText(maxChars int) Option {
+	return func(s *serviceImpl) { s.fileTextChars = maxChars }
+}
+
+// attachFileText 下载文件并提取正文写入附件，提取后的正文同样经过脱敏和入站审核
+// 无法提取时 ok 为 false，reply 为回复给用户的原因说明
+func (s *serviceImpl) attachFileText(ctx context.Context, msg Message, a *ai.Attachment) (reply string, ok bool) {
+	if s.fileTextChars <= 0 || s.media == nil || a.MediaID == "" {
+		return "", true
+	}
+	m, err := s.media.Download(ctx, a.MediaID)
+	if err != nil {
+		s.logger.WarnContext(ctx, "failed to download file", "msg_id", msg.MsgID, "media_id", a.MediaID, "error", err)
+		if errors.Is(err, ErrMediaTooLarge) {
+			return "文件过大，暂时无法读取，请发送较小的文件或直接粘贴相关内容", false
+		}
+		return "文件下载失败，请稍后重试", false
+	}
+	defer func() {
+		if err := m.Remove(); err != nil {
+			s.logger.WarnContext(ctx, "failed to remove media temp file", "path", m.Path, "error", err)
+		}
+	}()
+
+	res, err := extract.Text(m.Path, m.FileName, s.fileTextChars)
+	if err != nil {
+		s.logger.WarnContext(ctx, "failed to extract file text", "msg_id", msg.MsgID, "file_name", m.FileName, "error", err)
+		if errors.Is(err, extract.ErrUnsupported) {
+			return "暂不支持读取该类型的文件，目前支持 PDF、Word（.docx）和纯文本文件", false
+		}
+		return "文件内容解析失败，请确认文件未损坏或未加密", false
+	}
+	if res.Text == "" {
+		return "未能从文件中读取到文字内容，扫描件等图片型文件暂不支持", false
+	}
+
+	text := s.redactor.Transform(ctx, res.Text)
+	text, blocked := s.moderate(ctx, msg, DirectionInbound, text)
+	if blocked {
+		return text, false
+	}
+	if res.Truncated {
+		text += "\n（文件内容过长，以上为前 " + strconv.Itoa(s.fileTextChars) + " 个字符）"
+	}
+	a.Title = m.FileName
+	a.Text = text
+	s.logger.InfoContext(ctx, "file text extracted", "msg_id", msg.MsgID, "format", res.Format, "chars", utf8.RuneCountInString(res.Text), "truncated", res.Truncated)
+	return "", true
+}
+
+// WithMediaUploader 设置素材上传器，AI 回复中的图片和文件链接另以图片、文件消息发送，需配置 Sender
+func WithMediaUploader(u MediaUploader) Option {
+	return func(s *serviceImpl) { s.uploader = u }
+}
+
+// maxReplyMedia 单条回复最多附带发送的素材数
+const maxReplyMedia = 5
+
+// replyFileLink 回复中指向文档的 Markdown 链接
+var replyFileLink = regexp.MustCompile(`\[[^\]]*\]\(([^)\s]+\.(?i:pdf|docx?|xlsx?|pptx?|csv|zip|txt))\)`)
+
+// replyMedia 回复中引用的素材，图片取 Markdown 图片，文件取指向文档的链接，按 URL 去重
+func replyMedia(reply string) (types, urls []string) {
+	seen := make(map[string]bool)
+	add := func(typ, u string) {
+		if seen[u] || len(urls) >= maxReplyMedia {
+			return
+		}
+		seen[u] = true
+		types, urls = append(types, typ), append(urls, u)
+	}
+	for _, m := range mdImage.FindAllStringSubmatch(reply, -1) {
+		add(MsgTypeImage, m[2])
+	}
+	for _, m := range replyFileLink.FindAllStringSubmatch(reply, -1) {
+		add(MsgTypeFile, m[1])
+	}
+	return types, urls
+}
+
+// sendReplyMedia 上传回复中引用的图片和文件并逐条主动发送，失败时记录日志，文本回复中仍保留链接
+func (s *serviceImpl) sendReplyMedia(ctx context.Context, msg Message, reply string) {
+	if s.uploader == nil || s.sender == nil {
+		return
+	}
+	types, urls := replyMedia(reply)
+	for i, u := range urls {
+		mediaID, err := s.uploader.UploadURL(ctx, types[i], u)
+		if err != nil {
+			s.logger.WarnContext(ctx, "failed to upload reply media", "msg_id", msg.MsgID, "type", types[i], "error", err)
+			continue
+		}
+		err = s.sender.Send(ctx, OutgoingMessage{ToUser: msg.FromUserName, MsgType: types[i], MediaID: mediaID})
+		if err != nil {
+			s.logger.ErrorContext(ctx, "failed to send reply media", "msg_id", msg.MsgID, "type", types[i], "error", err)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package wework
+
+import (
+	"regexp"
+	"strings"
+)
+
+// ChatType 消息来源会话类型
+const (
+	ChatTypeSingle = "single"
+	ChatTypeGroup  = "group"
+)
+
+// MentionPolicy 文本消息的触发规则
+// Names 和 Pattern 均为空时无法判断群聊中 @ 的是否为本机器人，只回复单聊消息
+type MentionPolicy struct {
+	Names        []string       // 机器人显示名称及别名，内容包含 "@名称" 即视为提及
+	Pattern      *regexp.Regexp // 内容匹配时视为提及
+	DirectAlways bool           // 单聊消息无需提及即回复，仅群聊要求提及
+}
+
+// WithMention 设置文本消息的触发规则，未设置时使用默认规则
+func WithMention(p MentionPolicy) Option {
+	return func(s *serviceImpl) { s.mention = p }
+}
+
+// Triggered 判断文本消息是否应转发给 AI
+func (p MentionPolicy) Triggered(msg Message) bool {
+	if len(p.Names) == 0 && p.Pattern == nil {
+		return !msg.IsGroup()
+	}
+	if p.DirectAlways && !msg.IsGroup() {
+		return true
+	}
+	return p.mentioned(msg.Content)
+}
+
+func (p MentionPolicy) mentioned(content string) bool {
+	for _, name := range p.Names {
+		if strings.Contains(content, "@"+name) {
+			return true
+		}
+	}
+	return p.Pattern != nil && p.Pattern.MatchString(content)
+}
+
+// IsGroup 消息是否来自群聊，自建应用的消息均为单聊
+func (m Message) IsGroup() bool {
+	return m.ChatType == ChatTypeGroup || (m.ChatType == "" && m.ChatID != "")
+}
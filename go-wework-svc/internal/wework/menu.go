@@ -0,0 +1,65 @@
+package wework
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// 菜单按钮类型
+const (
+	MenuClick = "click"
+	MenuView  = "view"
+)
+
+// Menu 应用自定义菜单，最多 3 个一级菜单，每个一级菜单最多 5 个子菜单，字段与 menu/create API 一致
+type Menu struct {
+	Buttons []MenuButton `json:"button"`
+}
+
+// MenuButton 菜单按钮，含子菜单的一级菜单不填写 Type
+type MenuButton struct {
+	Type       string       `json:"type,omitempty"` // click | view 等
+	Name       string       `json:"name"`
+	Key        string       `json:"key,omitempty"` // click 的 EventKey
+	URL        string       `json:"url,omitempty"` // view 的跳转地址
+	SubButtons []MenuButton `json:"sub_button,omitempty"`
+}
+
+// MenuManager 管理应用自定义菜单
+type MenuManager interface {
+	// SetMenu 创建或覆盖应用菜单
+	SetMenu(ctx context.Context, menu Menu) error
+}
+
+// WithMenuCommands 菜单点击（click）事件按 EventKey 执行命令，key 格式为 "命令名 [参数]"，如 "reset"、"faq 退货"
+// 点击事件与文本命令一样先做访问控制；跳转（view）事件只记录日志；需同时启用命令
+func WithMenuCommands() Option {
+	return func(s *serviceImpl) {
+		s.registry.RegisterEvent(EventClick, s.accessMiddleware(s.eventHandler(EventHandlerFunc(s.menuClick))))
+		s.registry.RegisterEvent(EventView, s.eventHandler(EventHandlerFunc(func(ctx context.Context, ev Event) (string, error) {
+			s.logger.InfoContext(ctx, "menu link opened", "from_user", ev.FromUserName, "url", ev.EventKey)
+			return "", nil
+		})))
+	}
+}
+
+// menuClick 将 EventKey 解析为命令并执行，回复由事件处理器主动发送
+func (s *serviceImpl) menuClick(ctx context.Context, ev Event) (string, error) {
+	if s.commandPolicy == nil {
+		return "", fmt.Errorf("menu click %q: commands not enabled", ev.EventKey)
+	}
+	name, args, _ := strings.Cut(strings.TrimSpace(strings.TrimPrefix(ev.EventKey, "/")), " ")
+	spec, ok := s.commands[strings.ToLower(name)]
+	if !ok {
+		return "", fmt.Errorf("menu click: unknown command %q", name)
+	}
+	msg := Message{
+		ToUserName:   ev.ToUserName,
+		FromUserName: ev.FromUserName,
+		CreateTime:   ev.CreateTime,
+		MsgType:      MsgTypeEvent,
+		AgentID:      ev.AgentID,
+	}
+	return s.runCommand(ctx, spec, msg, strings.TrimSpace(args)), nil
+}
@@ -10,6 +10,7 @@
 package wework
 
 import (
+	context "context"
 	reflect "reflect"
 
 	gomock "go.uber.org/mock/gomock"
@@ -40,18 +41,18 @@ func (m *MockCrypto) EXPECT() *MockCryptoMockRecorder {
 }
 
 // Decrypt mocks base method.
-func (m *MockCrypto) Decrypt(encrypted string) ([]byte, error) {
+func (m *MockCrypto) Decrypt(ctx context.Context, encrypted string) ([]byte, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Decrypt", encrypted)
+	ret := m.ctrl.Call(m, "Decrypt", ctx, encrypted)
 	ret0, _ := ret[0].([]byte)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Decrypt indicates an expected call of Decrypt.
-func (mr *MockCryptoMockRecorder) Decrypt(encrypted any) *gomock.Call {
+func (mr *MockCryptoMockRecorder) Decrypt(ctx, encrypted any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Decrypt", reflect.TypeOf((*MockCrypto)(nil).Decrypt), encrypted)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Decrypt", reflect.TypeOf((*MockCrypto)(nil).Decrypt), ctx, encrypted)
 }
 
 // Encrypt mocks base method.
@@ -69,6 +70,20 @@ func (mr *MockCryptoMockRecorder) Encrypt(plaintext any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Encrypt", reflect.TypeOf((*MockCrypto)(nil).Encrypt), plaintext)
 }
 
+// Sign mocks base method.
+func (m *MockCrypto) Sign(timestamp, nonce, msgEncrypt string) string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Sign", timestamp, nonce, msgEncrypt)
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// Sign indicates an expected call of Sign.
+func (mr *MockCryptoMockRecorder) Sign(timestamp, nonce, msgEncrypt any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Sign", reflect.TypeOf((*MockCrypto)(nil).Sign), timestamp, nonce, msgEncrypt)
+}
+
 // VerifySignature mocks base method.
 func (m *MockCrypto) VerifySignature(signature, timestamp, nonce, msgEncrypt string) bool {
 	m.ctrl.T.Helper()
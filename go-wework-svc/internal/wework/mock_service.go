@@ -11,11 +11,68 @@ package wework
 
 import (
 	context "context"
+	ai "go-wework-svc/internal/ai"
+	outbox "go-wework-svc/internal/outbox"
 	reflect "reflect"
+	time "time"
 
 	gomock "go.uber.org/mock/gomock"
 )
 
+// MockCallbackService is a mock of CallbackService interface.
+type MockCallbackService struct {
+	ctrl     *gomock.Controller
+	recorder *MockCallbackServiceMockRecorder
+	isgomock struct{}
+}
+
+// MockCallbackServiceMockRecorder is the mock recorder for MockCallbackService.
+type MockCallbackServiceMockRecorder struct {
+	mock *MockCallbackService
+}
+
+// NewMockCallbackService creates a new mock instance.
+func NewMockCallbackService(ctrl *gomock.Controller) *MockCallbackService {
+	mock := &MockCallbackService{ctrl: ctrl}
+	mock.recorder = &MockCallbackServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCallbackService) EXPECT() *MockCallbackServiceMockRecorder {
+	return m.recorder
+}
+
+// HandleCallback mocks base method.
+func (m *MockCallbackService) HandleCallback(ctx context.Context, q CallbackQuery, body []byte) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HandleCallback", ctx, q, body)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HandleCallback indicates an expected call of HandleCallback.
+func (mr *MockCallbackServiceMockRecorder) HandleCallback(ctx, q, body any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HandleCallback", reflect.TypeOf((*MockCallbackService)(nil).HandleCallback), ctx, q, body)
+}
+
+// VerifyURL mocks base method.
+func (m *MockCallbackService) VerifyURL(ctx context.Context, q CallbackQuery) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyURL", ctx, q)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VerifyURL indicates an expected call of VerifyURL.
+func (mr *MockCallbackServiceMockRecorder) VerifyURL(ctx, q any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyURL", reflect.TypeOf((*MockCallbackService)(nil).VerifyURL), ctx, q)
+}
+
 // MockService is a mock of Service interface.
 type MockService struct {
 	ctrl     *gomock.Controller
@@ -40,20 +97,49 @@ func (m *MockService) EXPECT() *MockServiceMockRecorder {
 	return m.recorder
 }
 
-// HandleCallback mocks base method.
-func (m *MockService) HandleCallback(ctx context.Context, q CallbackQuery, body []byte) error {
+// Drain mocks base method.
+func (m *MockService) Drain(ctx context.Context) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "HandleCallback", ctx, q, body)
+	ret := m.ctrl.Call(m, "Drain", ctx)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
+// Drain indicates an expected call of Drain.
+func (mr *MockServiceMockRecorder) Drain(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Drain", reflect.TypeOf((*MockService)(nil).Drain), ctx)
+}
+
+// HandleCallback mocks base method.
+func (m *MockService) HandleCallback(ctx context.Context, q CallbackQuery, body []byte) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HandleCallback", ctx, q, body)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
 // HandleCallback indicates an expected call of HandleCallback.
 func (mr *MockServiceMockRecorder) HandleCallback(ctx, q, body any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HandleCallback", reflect.TypeOf((*MockService)(nil).HandleCallback), ctx, q, body)
 }
 
+// Redeliver mocks base method.
+func (m *MockService) Redeliver(ctx context.Context, e outbox.Entry) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Redeliver", ctx, e)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Redeliver indicates an expected call of Redeliver.
+func (mr *MockServiceMockRecorder) Redeliver(ctx, e any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Redeliver", reflect.TypeOf((*MockService)(nil).Redeliver), ctx, e)
+}
+
 // VerifyURL mocks base method.
 func (m *MockService) VerifyURL(ctx context.Context, q CallbackQuery) (string, error) {
 	m.ctrl.T.Helper()
@@ -68,3 +154,195 @@ func (mr *MockServiceMockRecorder) VerifyURL(ctx, q any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyURL", reflect.TypeOf((*MockService)(nil).VerifyURL), ctx, q)
 }
+
+// MockTransformer is a mock of Transformer interface.
+type MockTransformer struct {
+	ctrl     *gomock.Controller
+	recorder *MockTransformerMockRecorder
+	isgomock struct{}
+}
+
+// MockTransformerMockRecorder is the mock recorder for MockTransformer.
+type MockTransformerMockRecorder struct {
+	mock *MockTransformer
+}
+
+// NewMockTransformer creates a new mock instance.
+func NewMockTransformer(ctrl *gomock.Controller) *MockTransformer {
+	mock := &MockTransformer{ctrl: ctrl}
+	mock.recorder = &MockTransformerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTransformer) EXPECT() *MockTransformerMockRecorder {
+	return m.recorder
+}
+
+// Transform mocks base method.
+func (m *MockTransformer) Transform(ctx context.Context, content string) string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Transform", ctx, content)
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// Transform indicates an expected call of Transform.
+func (mr *MockTransformerMockRecorder) Transform(ctx, content any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Transform", reflect.TypeOf((*MockTransformer)(nil).Transform), ctx, content)
+}
+
+// MockHistory is a mock of History interface.
+type MockHistory struct {
+	ctrl     *gomock.Controller
+	recorder *MockHistoryMockRecorder
+	isgomock struct{}
+}
+
+// MockHistoryMockRecorder is the mock recorder for MockHistory.
+type MockHistoryMockRecorder struct {
+	mock *MockHistory
+}
+
+// NewMockHistory creates a new mock instance.
+func NewMockHistory(ctrl *gomock.Controller) *MockHistory {
+	mock := &MockHistory{ctrl: ctrl}
+	mock.recorder = &MockHistoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockHistory) EXPECT() *MockHistoryMockRecorder {
+	return m.recorder
+}
+
+// Append mocks base method.
+func (m *MockHistory) Append(ctx context.Context, key string, turns ...ai.Turn) error {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, key}
+	for _, a := range turns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Append", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Append indicates an expected call of Append.
+func (mr *MockHistoryMockRecorder) Append(ctx, key any, turns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, key}, turns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Append", reflect.TypeOf((*MockHistory)(nil).Append), varargs...)
+}
+
+// Load mocks base method.
+func (m *MockHistory) Load(ctx context.Context, key string) ([]ai.Turn, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Load", ctx, key)
+	ret0, _ := ret[0].([]ai.Turn)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Load indicates an expected call of Load.
+func (mr *MockHistoryMockRecorder) Load(ctx, key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Load", reflect.TypeOf((*MockHistory)(nil).Load), ctx, key)
+}
+
+// MockObserver is a mock of Observer interface.
+type MockObserver struct {
+	ctrl     *gomock.Controller
+	recorder *MockObserverMockRecorder
+	isgomock struct{}
+}
+
+// MockObserverMockRecorder is the mock recorder for MockObserver.
+type MockObserverMockRecorder struct {
+	mock *MockObserver
+}
+
+// NewMockObserver creates a new mock instance.
+func NewMockObserver(ctrl *gomock.Controller) *MockObserver {
+	mock := &MockObserver{ctrl: ctrl}
+	mock.recorder = &MockObserverMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockObserver) EXPECT() *MockObserverMockRecorder {
+	return m.recorder
+}
+
+// OnForwardDone mocks base method.
+func (m *MockObserver) OnForwardDone(ctx context.Context, msg Message, latency time.Duration, err error) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "OnForwardDone", ctx, msg, latency, err)
+}
+
+// OnForwardDone indicates an expected call of OnForwardDone.
+func (mr *MockObserverMockRecorder) OnForwardDone(ctx, msg, latency, err any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnForwardDone", reflect.TypeOf((*MockObserver)(nil).OnForwardDone), ctx, msg, latency, err)
+}
+
+// OnMessage mocks base method.
+func (m *MockObserver) OnMessage(ctx context.Context, msg Message, outcome string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "OnMessage", ctx, msg, outcome)
+}
+
+// OnMessage indicates an expected call of OnMessage.
+func (mr *MockObserverMockRecorder) OnMessage(ctx, msg, outcome any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnMessage", reflect.TypeOf((*MockObserver)(nil).OnMessage), ctx, msg, outcome)
+}
+
+// MockArchive is a mock of Archive interface.
+type MockArchive struct {
+	ctrl     *gomock.Controller
+	recorder *MockArchiveMockRecorder
+	isgomock struct{}
+}
+
+// MockArchiveMockRecorder is the mock recorder for MockArchive.
+type MockArchiveMockRecorder struct {
+	mock *MockArchive
+}
+
+// NewMockArchive creates a new mock instance.
+func NewMockArchive(ctrl *gomock.Controller) *MockArchive {
+	mock := &MockArchive{ctrl: ctrl}
+	mock.recorder = &MockArchiveMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockArchive) EXPECT() *MockArchiveMockRecorder {
+	return m.recorder
+}
+
+// Inbound mocks base method.
+func (m *MockArchive) Inbound(ctx context.Context, agent string, msg Message) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Inbound", ctx, agent, msg)
+}
+
+// Inbound indicates an expected call of Inbound.
+func (mr *MockArchiveMockRecorder) Inbound(ctx, agent, msg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Inbound", reflect.TypeOf((*MockArchive)(nil).Inbound), ctx, agent, msg)
+}
+
+// Outbound mocks base method.
+func (m *MockArchive) Outbound(ctx context.Context, agent string, msg Message, reply string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Outbound", ctx, agent, msg, reply)
+}
+
+// Outbound indicates an expected call of Outbound.
+func (mr *MockArchiveMockRecorder) Outbound(ctx, agent, msg, reply any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Outbound", reflect.TypeOf((*MockArchive)(nil).Outbound), ctx, agent, msg, reply)
+}
@@ -0,0 +1,71 @@
+package wework
+
+import (
+	"context"
+)
+
+// 审核方向
+const (
+	DirectionInbound  = "inbound"  // 用户消息，转发 AI 前
+	DirectionOutbound = "outbound" // AI 回复，发送给用户前
+)
+
+// 审核处理方式
+const (
+	ModerationAllow = "allow" // 未命中
+	ModerationBlock = "block" // 拦截：入站不转发 AI，出站不发送原回复
+	ModerationMask  = "mask"  // 打码后继续处理
+	ModerationFlag  = "flag"  // 仅记录日志，内容不变
+)
+
+// Verdict 审核结果
+type Verdict struct {
+	Action string // allow | block | mask | flag
+	// Content 后续使用的内容：mask 时为打码后的内容，block 时为回复给用户的提示（为空则不回复），其余为原内容
+	Content string
+	Reasons []string // 命中的敏感词或外部审核类别
+}
+
+// Moderator 内容审核
+type Moderator interface {
+	// Moderate 审核一条入站消息或出站回复，direction 为 inbound 或 outbound，user 为消息发送者
+	Moderate(ctx context.Context, direction, user, content string) Verdict
+}
+
+// WithModerator 设置内容审核，入站消息在转发 AI 前审核，AI 回复在出站变换后审核
+func WithModerator(m Moderator) Option {
+	return func(s *serviceImpl) { s.moderator = m }
+}
+
+// nopModerator 放行所有内容
+type nopModerator struct{}
+
+func (nopModerator) Moderate(_ context.Context, _, _, content string) Verdict {
+	return Verdict{Action: ModerationAllow, Content: content}
+}
+
+// moderate 审核内容并记录命中日志，返回后续使用的内容；blocked 为 true 时 content 为拦截提示
+func (s *serviceImpl) moderate(ctx context.Context, msg Message, direction, content string) (_ string, blocked bool) {
+	if content == "" {
+		return content, false
+	}
+	v := s.moderator.Moderate(ctx, direction, msg.FromUserName, content)
+	if v.Action == ModerationAllow {
+		return v.Content, false
+	}
+	s.logger.WarnContext(ctx, "content moderated",
+		"msg_id", msg.MsgID,
+		"from_user", msg.FromUserName,
+		"direction", direction,
+		"action", v.Action,
+		"reasons", v.Reasons,
+	)
+	if v.Action != ModerationBlock {
+		return v.Content, false
+	}
+	key := ReplyInputBlocked
+	if direction == DirectionOutbound {
+		key = ReplyOutputBlocked
+	}
+	return s.localize(ctx, msg.FromUserName, key, v.Content, nil), true
+}
@@ -0,0 +1,25 @@
+package wework
+
+import "go-wework-svc/internal/ai"
+
+// PersonaPolicy 应用和群聊的人设
+type PersonaPolicy struct {
+	Default *ai.Persona            // 应用的人设，为 nil 时单聊和未单独配置的群聊不附带人设
+	Groups  map[string]*ai.Persona // 按群聊 ChatID 覆盖应用的人设
+}
+
+// WithPersona AI 请求附带应用或群聊的人设，由后端写入系统提示或应用变量
+func WithPersona(p PersonaPolicy) Option {
+	return func(s *serviceImpl) { s.persona = &p }
+}
+
+// personaOf 返回消息所在会话的人设，群聊优先使用单独配置的人设
+func (s *serviceImpl) personaOf(msg Message) *ai.Persona {
+	if s.persona == nil {
+		return nil
+	}
+	if p, ok := s.persona.Groups[msg.ChatID]; ok && msg.IsGroup() {
+		return p
+	}
+	return s.persona.Default
+}
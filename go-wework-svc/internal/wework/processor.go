@@ -0,0 +1,46 @@
+package wework
+
+import (
+	"context"
+)
+
+// MessageProcessor 自定义消息处理器，与 AI 转发并行处理每条去重后的回调
+// 处理器在回调应答后异步执行，返回的错误仅记录日志，不影响主流程
+type MessageProcessor interface {
+	// Name 处理器名称，用于日志
+	Name() string
+
+	// Process 处理一条回调，req.Event 仅事件回调时非空
+	Process(ctx context.Context, req *Request) error
+}
+
+// WithProcessors 注册自定义消息处理器
+func WithProcessors(ps ...MessageProcessor) Option {
+	return func(s *serviceImpl) {
+		if len(ps) > 0 {
+			s.registry.Use(s.processorMiddleware(ps))
+		}
+	}
+}
+
+// processorMiddleware 在主处理器返回后异步调用所有自定义处理器
+func (s *serviceImpl) processorMiddleware(ps []MessageProcessor) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, req *Request) ([]byte, error) {
+			reply, err := next.Handle(ctx, req)
+			asyncCtx := s.detach(ctx)
+			for _, p := range ps {
+				s.goSafe(asyncCtx, "processor:"+p.Name(), req.Message, func() {
+					if err := p.Process(asyncCtx, req); err != nil {
+						s.logger.ErrorContext(ctx, "message processor failed",
+							"processor", p.Name(),
+							"msg_id", req.Message.MsgID,
+							"error", err,
+						)
+					}
+				})
+			}
+			return reply, err
+		})
+	}
+}
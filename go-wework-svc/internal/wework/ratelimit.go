@@ -0,0 +1,106 @@
+package wework
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"go-wework-svc/internal/store"
+)
+
+// RateLimitPolicy 按发送者限制转发 AI 的频率和每日次数，计数保存在 Store 中
+type RateLimitPolicy struct {
+	PerMinute  int      // 每分钟最多转发次数，0 表示不限
+	Daily      int      // 每个自然日最多转发次数（本地时区），0 表示不限
+	Reply      string   // 超出每分钟限制时的回复，为空则不回复
+	QuotaReply string   // 超出每日额度时的回复，为空则不回复
+	Exempt     []string // 不受限制的 UserID
+	// Store 多副本部署时应为共享存储
+	Store store.Store
+}
+
+// RateLimiter 持有当前生效的限流策略，热加载时整体替换
+type RateLimiter struct {
+	policy atomic.Pointer[RateLimitPolicy]
+}
+
+// NewRateLimiter 创建限流器，p 为 nil 时不限流
+func NewRateLimiter(p *RateLimitPolicy) *RateLimiter {
+	l := &RateLimiter{}
+	l.Reload(p)
+	return l
+}
+
+// Reload 替换限流策略，p 为 nil 时停止限流；已有计数保留在 Store 中
+func (l *RateLimiter) Reload(p *RateLimitPolicy) {
+	if p != nil {
+		cp := *p
+		p = &cp
+	}
+	l.policy.Store(p)
+}
+
+// WithRateLimit 启用按用户限流，命令和自动回复不计入次数
+func WithRateLimit(l *RateLimiter) Option {
+	return func(s *serviceImpl) { s.rateLimit = l }
+}
+
+// rateLimitMiddleware 拦截超出频率或每日额度的消息，其余消息交给 next
+func (s *serviceImpl) rateLimitMiddleware(next Handler) Handler {
+	return HandlerFunc(func(ctx context.Context, req *Request) ([]byte, error) {
+		if s.rateLimit == nil {
+			return next.Handle(ctx, req)
+		}
+		p := s.rateLimit.policy.Load()
+		if p == nil {
+			return next.Handle(ctx, req)
+		}
+		key, reply, limited := s.throttle(ctx, p, req.Message)
+		if !limited {
+			return next.Handle(ctx, req)
+		}
+		s.observer.OnMessage(ctx, req.Message, OutcomeThrottled)
+		return s.respond(ctx, req, "rate_limit", func(ctx context.Context) string {
+			return s.localize(ctx, req.Message.FromUserName, key, reply, nil)
+		})
+	})
+}
+
+// throttle 计入一次转发并判断是否超限，超限时返回回复的文案键和配置的回复，存储异常时放行
+func (s *serviceImpl) throttle(ctx context.Context, p *RateLimitPolicy, msg Message) (replyKey, reply string, limited bool) {
+	user := msg.FromUserName
+	if slices.Contains(p.Exempt, user) {
+		return "", "", false
+	}
+	now := time.Now()
+	prefix := "ratelimit:" + s.agent + ":" + user
+
+	if p.PerMinute > 0 {
+		key := prefix + ":m:" + strconv.FormatInt(now.Unix()/60, 10)
+		if s.exceeded(ctx, p.Store, key, 2*time.Minute, p.PerMinute) {
+			s.logger.WarnContext(ctx, "user rate limited", "msg_id", msg.MsgID, "from_user", user, "per_minute", p.PerMinute)
+			return ReplyRateLimited, p.Reply, true
+		}
+	}
+	if p.Daily > 0 {
+		// 键按日期区分，保留两天即可覆盖当天剩余时间
+		key := prefix + ":d:" + now.Format("20060102")
+		if s.exceeded(ctx, p.Store, key, 48*time.Hour, p.Daily) {
+			s.logger.WarnContext(ctx, "user daily quota exceeded", "msg_id", msg.MsgID, "from_user", user, "daily", p.Daily)
+			return ReplyQuotaExceeded, p.QuotaReply, true
+		}
+	}
+	return "", "", false
+}
+
+// exceeded 计数加一，超过 limit 时返回 true
+func (s *serviceImpl) exceeded(ctx context.Context, kv store.Store, key string, ttl time.Duration, limit int) bool {
+	n, err := kv.Incr(ctx, key, ttl)
+	if err != nil {
+		s.logger.WarnContext(ctx, "rate limit incr failed", "key", key, "error", err)
+		return false
+	}
+	return n > int64(limit)
+}
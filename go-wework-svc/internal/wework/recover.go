@@ -0,0 +1,43 @@
+package wework
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+)
+
+// WithPanicHook 设置后台任务 panic 时的回调，用于计数告警和错误上报
+func WithPanicHook(hook func(ctx context.Context, task string, msg Message, v any, stack []byte)) Option {
+	return func(s *serviceImpl) { s.onPanic = hook }
+}
+
+// goSafe 在新 goroutine 中执行 fn，panic 时记录堆栈后继续运行，避免单条异常消息导致进程退出
+// 任务计入 jobs，优雅退出时由 Drain 等待
+func (s *serviceImpl) goSafe(ctx context.Context, task string, msg Message, fn func()) {
+	s.jobs.Add(1)
+	go func() {
+		defer s.jobs.Done()
+		defer s.recoverPanic(ctx, task, msg)
+		fn()
+	}()
+}
+
+// recoverPanic 需直接 defer 调用
+func (s *serviceImpl) recoverPanic(ctx context.Context, task string, msg Message) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	stack := debug.Stack()
+	s.logger.ErrorContext(ctx, "panic recovered",
+		"task", task,
+		"msg_id", msg.MsgID,
+		"msg_type", msg.MsgType,
+		"from_user", msg.FromUserName,
+		"panic", fmt.Sprint(r),
+		"stack", string(stack),
+	)
+	if s.onPanic != nil {
+		s.onPanic(ctx, task, msg, r, stack)
+	}
+}
@@ -0,0 +1,118 @@
+package wework
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// maxReplyArticles 被动回复图文消息的条目上限
+const maxReplyArticles = 8
+
+// NewTextReply 构造回复 msg 发送者的文本消息
+func NewTextReply(msg Message, content string) ReplyMessage {
+	return ReplyMessage{ToUserName: msg.FromUserName, FromUserName: msg.ToUserName, MsgType: MsgTypeText, Content: content}
+}
+
+// NewImageReply 构造回复 msg 发送者的图片消息，mediaID 为 media/upload 返回的素材 ID
+func NewImageReply(msg Message, mediaID string) ReplyMessage {
+	return ReplyMessage{ToUserName: msg.FromUserName, FromUserName: msg.ToUserName, MsgType: MsgTypeImage, MediaID: mediaID}
+}
+
+// NewNewsReply 构造回复 msg 发送者的图文消息，最多 8 条
+func NewNewsReply(msg Message, articles ...Article) ReplyMessage {
+	return ReplyMessage{ToUserName: msg.FromUserName, FromUserName: msg.ToUserName, MsgType: MsgTypeNews, Articles: articles}
+}
+
+// cdata 序列化为 CDATA 段的字符串，内容中的 "]]>" 由 encoding/xml 拆分处理
+type cdata struct {
+	Value string `xml:",cdata"`
+}
+
+type replyXML struct {
+	XMLName      xml.Name `xml:"xml"`
+	ToUserName   cdata    `xml:"ToUserName"`
+	FromUserName cdata    `xml:"FromUserName"`
+	CreateTime   int64    `xml:"CreateTime"`
+	MsgType      cdata    `xml:"MsgType"`
+	Content      *cdata   `xml:"Content,omitempty"`
+	Image        *struct {
+		MediaID cdata `xml:"MediaId"`
+	} `xml:"Image,omitempty"`
+	ArticleCount int `xml:"ArticleCount,omitempty"`
+	Articles     *struct {
+		Items []articleXML `xml:"item"`
+	} `xml:"Articles,omitempty"`
+}
+
+type articleXML struct {
+	Title       cdata `xml:"Title"`
+	Description cdata `xml:"Description"`
+	PicURL      cdata `xml:"PicUrl"`
+	URL         cdata `xml:"Url"`
+}
+
+// MarshalXML 字符串字段使用 CDATA，CreateTime 为 0 时取当前时间；缺少对应类型的必填字段时返回错误
+func (r ReplyMessage) MarshalXML(e *xml.Encoder, _ xml.StartElement) error {
+	if r.ToUserName == "" || r.FromUserName == "" {
+		return errors.New("reply requires ToUserName and FromUserName")
+	}
+	out := replyXML{
+		ToUserName:   cdata{r.ToUserName},
+		FromUserName: cdata{r.FromUserName},
+		CreateTime:   r.CreateTime,
+		MsgType:      cdata{r.MsgType},
+	}
+	if out.CreateTime == 0 {
+		out.CreateTime = time.Now().Unix()
+	}
+	switch r.MsgType {
+	case MsgTypeText:
+		if r.Content == "" {
+			return errors.New("text reply requires content")
+		}
+		out.Content = &cdata{r.Content}
+	case MsgTypeImage:
+		if r.MediaID == "" {
+			return errors.New("image reply requires media_id")
+		}
+		out.Image = &struct {
+			MediaID cdata `xml:"MediaId"`
+		}{cdata{r.MediaID}}
+	case MsgTypeNews:
+		if len(r.Articles) == 0 || len(r.Articles) > maxReplyArticles {
+			return fmt.Errorf("news reply requires 1 to %d articles, got %d", maxReplyArticles, len(r.Articles))
+		}
+		items := make([]articleXML, 0, len(r.Articles))
+		for i, a := range r.Articles {
+			if a.Title == "" || a.URL == "" {
+				return fmt.Errorf("news reply article %d requires title and url", i)
+			}
+			items = append(items, articleXML{cdata{a.Title}, cdata{a.Description}, cdata{a.PicURL}, cdata{a.URL}})
+		}
+		out.ArticleCount = len(items)
+		out.Articles = &struct {
+			Items []articleXML `xml:"item"`
+		}{items}
+	default:
+		return fmt.Errorf("unsupported reply msg type %q", r.MsgType)
+	}
+	return e.Encode(out)
+}
+
+// MarshalXML 字符串字段使用 CDATA，与企业微信文档示例一致
+func (r EncryptedReply) MarshalXML(e *xml.Encoder, _ xml.StartElement) error {
+	return e.Encode(struct {
+		XMLName      xml.Name `xml:"xml"`
+		Encrypt      cdata    `xml:"Encrypt"`
+		MsgSignature cdata    `xml:"MsgSignature"`
+		TimeStamp    string   `xml:"TimeStamp"`
+		Nonce        cdata    `xml:"Nonce"`
+	}{
+		Encrypt:      cdata{r.Encrypt},
+		MsgSignature: cdata{r.MsgSignature},
+		TimeStamp:    r.TimeStamp,
+		Nonce:        cdata{r.Nonce},
+	})
+}
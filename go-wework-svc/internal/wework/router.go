@@ -0,0 +1,133 @@
+package wework
+
+import (
+	"context"
+	"time"
+)
+
+// Request 一次已解密的回调，在中间件和处理器之间传递
+type Request struct {
+	Query   CallbackQuery
+	Message Message
+	Event   *Event // 仅 MsgType 为 event 时非空
+}
+
+// Handler 回调消息处理器，返回被动回复的加密 XML，为 nil 时表示无需回复
+type Handler interface {
+	Handle(ctx context.Context, req *Request) ([]byte, error)
+}
+
+// HandlerFunc 函数形式的 Handler
+type HandlerFunc func(ctx context.Context, req *Request) ([]byte, error)
+
+// Handle 实现 Handler 接口
+func (f HandlerFunc) Handle(ctx context.Context, req *Request) ([]byte, error) {
+	return f(ctx, req)
+}
+
+// Middleware 包装 Handler，可在处理前后执行逻辑，或不调用 next 直接拦截
+type Middleware func(next Handler) Handler
+
+// Registry 按 MsgType / 事件类型路由到处理器，所有处理器共享同一条中间件链
+type Registry struct {
+	handlers   map[string]Handler
+	events     map[string]Handler
+	middleware []Middleware
+	fallback   Handler
+	chain      Handler
+}
+
+// NewRegistry 创建处理器注册表，未注册的消息类型交给 fallback
+func NewRegistry(fallback Handler) *Registry {
+	r := &Registry{
+		handlers: make(map[string]Handler),
+		events:   make(map[string]Handler),
+		fallback: fallback,
+	}
+	r.chain = HandlerFunc(r.route)
+	return r
+}
+
+// Register 为消息类型注册处理器，重复注册时覆盖
+func (r *Registry) Register(msgType string, h Handler) {
+	r.handlers[msgType] = h
+}
+
+// RegisterEvent 为事件类型注册处理器，重复注册时覆盖
+func (r *Registry) RegisterEvent(event string, h Handler) {
+	r.events[event] = h
+}
+
+// Use 追加中间件，先追加的位于外层
+func (r *Registry) Use(mw ...Middleware) {
+	r.middleware = append(r.middleware, mw...)
+	var h Handler = HandlerFunc(r.route)
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		h = r.middleware[i](h)
+	}
+	r.chain = h
+}
+
+// Handle 实现 Handler 接口，依次经过中间件后路由到处理器
+func (r *Registry) Handle(ctx context.Context, req *Request) ([]byte, error) {
+	return r.chain.Handle(ctx, req)
+}
+
+// route 选择处理器
+func (r *Registry) route(ctx context.Context, req *Request) ([]byte, error) {
+	h, ok := r.handlers[req.Message.MsgType]
+	if req.Event != nil {
+		h, ok = r.events[req.Event.Event]
+	}
+	if !ok {
+		h = r.fallback
+	}
+	return h.Handle(ctx, req)
+}
+
+// filterMiddleware 不满足 pred 的消息记为忽略，不进入后续处理器
+func (s *serviceImpl) filterMiddleware(pred func(Message) bool) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, req *Request) ([]byte, error) {
+			if !pred(req.Message) {
+				s.observer.OnMessage(ctx, req.Message, OutcomeIgnored)
+				return nil, nil
+			}
+			return next.Handle(ctx, req)
+		})
+	}
+}
+
+// dedupMiddleware 丢弃重复推送的消息；处理失败时撤销标记，企业微信重试时重新处理
+func (s *serviceImpl) dedupMiddleware(next Handler) Handler {
+	return HandlerFunc(func(ctx context.Context, req *Request) ([]byte, error) {
+		key, dup := s.isDuplicate(ctx, req.Message)
+		if dup {
+			s.logger.DebugContext(ctx, "duplicate callback ignored", "msg_id", req.Message.MsgID)
+			return nil, nil
+		}
+		reply, err := next.Handle(ctx, req)
+		if err != nil && key != "" {
+			if derr := s.dedup.Delete(context.WithoutCancel(ctx), key); derr != nil {
+				s.logger.WarnContext(ctx, "failed to clear dedup mark", "msg_id", req.Message.MsgID, "error", derr)
+			}
+		}
+		return reply, err
+	})
+}
+
+// loggingMiddleware 记录每条回调的处理耗时和结果
+func (s *serviceImpl) loggingMiddleware(next Handler) Handler {
+	return HandlerFunc(func(ctx context.Context, req *Request) ([]byte, error) {
+		start := time.Now()
+		reply, err := next.Handle(ctx, req)
+		s.logger.DebugContext(ctx, "callback handled",
+			"msg_id", req.Message.MsgID,
+			"msg_type", req.Message.MsgType,
+			"passive_reply", reply != nil,
+			"duration", time.Since(start),
+			"error", err,
+		)
+		return reply, err
+	})
+}
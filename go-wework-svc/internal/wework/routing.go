@@ -0,0 +1,83 @@
+package wework
+
+import (
+	"context"
+	"slices"
+)
+
+// TagDirectory 标签成员查询
+type TagDirectory interface {
+	// TagMembers 返回标签下的成员和部门 ID
+	TagMembers(ctx context.Context, tagID int64) (users []string, departments []int64, err error)
+}
+
+// RouteRule 一条路由规则，发送者命中 Users、Departments（直属部门）或 Tags 中的任一项即匹配
+type RouteRule struct {
+	Name        string // 对应 ai.Route 的名称
+	Users       []string
+	Departments []int64
+	Tags        []int64
+}
+
+// RoutingPolicy 按发送者的部门或标签为 AI 请求选择路由，规则按顺序匹配，命中第一条即停止
+type RoutingPolicy struct {
+	Rules     []RouteRule
+	Directory Directory    // 规则含部门或标签时必填
+	Tags      TagDirectory // 规则含标签时必填
+}
+
+// WithRouting 启用部门和标签路由，匹配结果写入 ChatRequest.Route，由 ai.RouteRouter 选择后端
+func WithRouting(p RoutingPolicy) Option {
+	return func(s *serviceImpl) { s.routing = &p }
+}
+
+// route 返回发送者命中的路由名称，未命中时为空
+// 部门或标签查询失败时跳过依赖该信息的规则
+func (s *serviceImpl) route(ctx context.Context, user string) string {
+	if s.routing == nil || user == "" {
+		return ""
+	}
+	var depts []int64
+	loaded := false
+	departments := func() []int64 {
+		if !loaded && s.routing.Directory != nil {
+			loaded = true
+			var err error
+			if depts, err = s.routing.Directory.Departments(ctx, user); err != nil {
+				s.logger.WarnContext(ctx, "failed to load user departments", "user_id", user, "error", err)
+			}
+		}
+		return depts
+	}
+
+	for _, r := range s.routing.Rules {
+		if slices.Contains(r.Users, user) {
+			return r.Name
+		}
+		if len(r.Departments) > 0 && slices.ContainsFunc(departments(), func(d int64) bool { return slices.Contains(r.Departments, d) }) {
+			return r.Name
+		}
+		for _, tag := range r.Tags {
+			if s.inTag(ctx, tag, user, departments) {
+				return r.Name
+			}
+		}
+	}
+	return ""
+}
+
+// inTag 判断成员是否直接或通过直属部门属于标签
+func (s *serviceImpl) inTag(ctx context.Context, tag int64, user string, departments func() []int64) bool {
+	if s.routing.Tags == nil {
+		return false
+	}
+	users, parties, err := s.routing.Tags.TagMembers(ctx, tag)
+	if err != nil {
+		s.logger.WarnContext(ctx, "failed to load tag members", "tag_id", tag, "error", err)
+		return false
+	}
+	if slices.Contains(users, user) {
+		return true
+	}
+	return len(parties) > 0 && slices.ContainsFunc(departments(), func(d int64) bool { return slices.Contains(parties, d) })
+}
@@ -0,0 +1,31 @@
+package wework
+
+import "context"
+
+// OutgoingMessage 主动发送的应用消息
+// ToUser/ToParty/ToTag 多个接收者用 "|" 分隔，至少填写一项
+type OutgoingMessage struct {
+	ToUser  string
+	ToParty string
+	ToTag   string
+	MsgType string // text | markdown | textcard | template_card | image | voice | file
+	Content string
+
+	// textcard：标题、描述和跳转链接必填，BtnTxt 默认为"详情"
+	Title       string
+	Description string
+	URL         string
+	BtnTxt      string
+
+	// template_card
+	Card *TemplateCard
+
+	// image、voice、file：media/upload 返回的 media_id
+	MediaID string
+}
+
+// Sender 应用消息主动发送接口
+type Sender interface {
+	// Send 通过 message/send API 发送应用消息，接收人包含互联企业成员时使用 linkedcorp/message/send
+	Send(ctx context.Context, msg OutgoingMessage) error
+}
@@ -2,47 +2,391 @@ package wework
 
 import (
 	"context"
+	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"log/slog"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 
 	"go-wework-svc/internal/ai"
+	"go-wework-svc/internal/outbox"
+	"go-wework-svc/internal/shared"
+	"go-wework-svc/internal/store"
 )
 
-// Service 企业微信领域服务接口
-type Service interface {
+// CallbackService 企业微信回调处理接口，HTTP 回调处理器只依赖这部分能力
+type CallbackService interface {
 	// VerifyURL 处理 GET 请求的 URL 验证
 	VerifyURL(ctx context.Context, q CallbackQuery) (string, error)
 
 	// HandleCallback 处理 POST 请求的消息回调
-	HandleCallback(ctx context.Context, q CallbackQuery, body []byte) error
+	// 返回被动回复的加密 XML，为 nil 时表示无需回复
+	HandleCallback(ctx context.Context, q CallbackQuery, body []byte) ([]byte, error)
+}
+
+// Service 企业微信领域服务接口
+type Service interface {
+	CallbackService
+
+	// Redeliver 重新转发 outbox 中的失败消息并投递回复
+	Redeliver(ctx context.Context, e outbox.Entry) error
+
+	// Drain 优雅退出时等待后台转发和回复完成，ctx 结束时取消未完成的 AI 转发
+	Drain(ctx context.Context) error
+}
+
+// Transformer 消息内容变换器
+type Transformer interface {
+	// Transform 返回变换后的内容
+	Transform(ctx context.Context, content string) string
+}
+
+// History 会话历史记录
+type History interface {
+	// Load 返回会话最近的对话记录，按时间升序
+	Load(ctx context.Context, key string) ([]ai.Turn, error)
+
+	// Append 追加对话记录
+	Append(ctx context.Context, key string, turns ...ai.Turn) error
+}
+
+// Observer 消息处理管道的事件观察者
+type Observer interface {
+	// OnMessage 记录一条已解密消息及其处理结果（OutcomeIgnored / OutcomeForwarded）
+	OnMessage(ctx context.Context, msg Message, outcome string)
+
+	// OnForwardDone 记录一次 AI 转发的耗时和结果
+	OnForwardDone(ctx context.Context, msg Message, latency time.Duration, err error)
+}
+
+// Archive 消息归档，实现方应异步写入，避免阻塞回调处理
+type Archive interface {
+	// Inbound 记录一条解密后的入站消息（含事件）
+	Inbound(ctx context.Context, agent string, msg Message)
+
+	// Outbound 记录一条发给 msg 发送者的回复
+	Outbound(ctx context.Context, agent string, msg Message, reply string)
 }
 
 // serviceImpl Service 接口的实现
 type serviceImpl struct {
-	crypto Crypto
-	aiSvc  ai.Service
-	logger *slog.Logger
+	agent    string
+	crypto   Crypto
+	aiSvc    ai.Service
+	logger   *slog.Logger
+	inbound  Transformer
+	outbound Transformer
+	redactor Transformer
+	observer Observer
+	archive  Archive
+	sender   Sender
+	webhook  Webhook
+	// responseURL 按消息的 response_url 创建回复客户端，为 nil 时只使用 sender
+	responseURL func(url string) Webhook
+	outbox      outbox.Store
+	dedup       store.Store
+	dedupTTL    time.Duration
+
+	// nonces 非空时启用重放防护，replayWindow 为允许的时间戳偏差
+	nonces       store.Store
+	replayWindow time.Duration
+
+	// registry 按消息类型 / 事件类型路由的处理器
+	registry *Registry
+
+	// history 非空时在 AI 请求中附带会话历史
+	history History
+
+	// stream 非空时异步模式下使用流式回复
+	stream *StreamPolicy
+
+	// mention 文本消息的触发规则
+	mention MentionPolicy
+
+	// commandPolicy 非空时在转发 AI 前识别命令，commands 按小写命令名索引
+	commandPolicy *CommandPolicy
+	commands      map[string]CommandSpec
+
+	// media 非空时可下载非文本消息引用的素材，vision 为 true 时图片内容随 AI 请求发送
+	// fileTextChars 大于 0 时提取文件消息的正文，最多保留该字符数
+	media         MediaDownloader
+	vision        bool
+	fileTextChars int
+
+	// uploader 非空时回复中的图片和文件链接另以素材消息发送
+	uploader MediaUploader
+
+	// contacts 非空时 AI 请求附带发送者的通讯录信息
+	contacts Contacts
+
+	// routing 非空时按发送者的部门或标签为 AI 请求选择路由
+	routing *RoutingPolicy
+
+	// tools 转发 AI 时附带的可调用工具
+	tools []ai.Tool
+
+	// persona 非空时 AI 请求附带应用或群聊的人设
+	persona *PersonaPolicy
+
+	// access 非空时按用户和部门控制谁可以使用机器人
+	access *AccessPolicy
+
+	// rateLimit 非空且持有策略时按用户限制转发 AI 的频率和每日次数
+	rateLimit *RateLimiter
+
+	// autoReply 非空时命中规则的文本消息直接回复，不转发 AI
+	autoReply AutoReplier
+
+	// moderator 审核入站消息与 AI 回复
+	moderator Moderator
+
+	// localizer 非空时系统回复按成员语言选择文案
+	localizer Localizer
+
+	// replyFormat 回复的格式转换与分段策略
+	replyFormat ReplyFormat
+
+	// passiveTimeout 大于 0 时启用被动回复：同步等待 AI 回复并加密写回响应
+	passiveTimeout time.Duration
+
+	// onPanic 后台任务 panic 后调用
+	onPanic func(ctx context.Context, task string, msg Message, v any, stack []byte)
+
+	// backpressure 非空时后台 AI 转发计入回调并发限制
+	backpressure *Backpressure
+
+	// jobs 进行中的后台任务，stopCtx 在 Drain 超时后取消，中断仍在进行的 AI 转发
+	jobs    sync.WaitGroup
+	stopCtx context.Context
+	stop    context.CancelFunc
+}
+
+// Option serviceImpl 的可选配置
+type Option func(*serviceImpl)
+
+// WithAgent 设置服务所属应用或租户名称，用于隔离去重键，并写入 outbox 记录以便重投时路由回同一服务
+func WithAgent(name string) Option {
+	return func(s *serviceImpl) { s.agent = name }
+}
+
+// WithInboundTransformer 设置转发 AI 前对用户消息的变换
+func WithInboundTransformer(t Transformer) Option {
+	return func(s *serviceImpl) { s.inbound = t }
+}
+
+// WithOutboundTransformer 设置发送前对 AI 回复的变换
+func WithOutboundTransformer(t Transformer) Option {
+	return func(s *serviceImpl) { s.outbound = t }
+}
+
+// WithRedactor 设置个人信息脱敏，在入站变换之后、发送 AI 之前执行，同时作用于会话历史
+func WithRedactor(t Transformer) Option {
+	return func(s *serviceImpl) { s.redactor = t }
+}
+
+// WithObserver 设置管道事件观察者
+func WithObserver(o Observer) Option {
+	return func(s *serviceImpl) { s.observer = o }
+}
+
+// WithArchive 设置消息归档
+func WithArchive(a Archive) Option {
+	return func(s *serviceImpl) { s.archive = a }
+}
+
+// WithSender 设置主动消息发送器，用于投递异步得到的 AI 回复
+func WithSender(sender Sender) Option {
+	return func(s *serviceImpl) { s.sender = sender }
+}
+
+// WithHistory 启用会话历史，AI 请求附带同一用户（或群组）最近的对话
+func WithHistory(h History) Option {
+	return func(s *serviceImpl) { s.history = h }
+}
+
+// WithReplyWebhook 设置群机器人，AI 回复同时推送到群中
+func WithReplyWebhook(w Webhook) Option {
+	return func(s *serviceImpl) { s.webhook = w }
+}
+
+// WithOutbox 设置 outbox，AI 转发最终失败的消息写入其中等待重投
+func WithOutbox(store outbox.Store) Option {
+	return func(s *serviceImpl) { s.outbox = store }
+}
+
+// WithDedup 启用回调消息去重，企业微信未及时收到应答时会重试推送同一条消息
+func WithDedup(kv store.Store, ttl time.Duration) Option {
+	return func(s *serviceImpl) {
+		s.dedup = kv
+		s.dedupTTL = ttl
+	}
+}
+
+// WithReplayProtection 启用回调重放防护：时间戳需在 ±window 内，且 timestamp+nonce 只能使用一次
+func WithReplayProtection(kv store.Store, window time.Duration) Option {
+	return func(s *serviceImpl) {
+		s.nonces = kv
+		s.replayWindow = window
+	}
+}
+
+// WithEventHandler 为指定事件类型（如 EventEnterAgent）注册处理器，重复注册时覆盖
+func WithEventHandler(event string, h EventHandler) Option {
+	return func(s *serviceImpl) { s.registry.RegisterEvent(event, s.eventHandler(h)) }
+}
+
+// WithHandler 为消息类型注册处理器，覆盖内置的转发行为
+func WithHandler(msgType string, h Handler) Option {
+	return func(s *serviceImpl) { s.registry.Register(msgType, h) }
+}
+
+// WithMiddleware 追加中间件，位于内置的日志和去重中间件之后
+func WithMiddleware(mw ...Middleware) Option {
+	return func(s *serviceImpl) { s.registry.Use(mw...) }
+}
+
+// WithPassiveReply 启用被动回复，在 timeout 内同步等待 AI 回复
+// 超时后若配置了 Sender，AI 回复到达时改为主动发送
+func WithPassiveReply(timeout time.Duration) Option {
+	return func(s *serviceImpl) { s.passiveTimeout = timeout }
 }
 
 // NewService 创建企业微信领域服务实例
-func NewService(crypto Crypto, aiSvc ai.Service, logger *slog.Logger) Service {
-	return &serviceImpl{
-		crypto: crypto,
-		aiSvc:  aiSvc,
-		logger: logger,
+func NewService(crypto Crypto, aiSvc ai.Service, logger *slog.Logger, opts ...Option) Service {
+	s := &serviceImpl{
+		crypto:    crypto,
+		aiSvc:     aiSvc,
+		logger:    logger,
+		inbound:   nopTransformer{},
+		outbound:  nopTransformer{},
+		redactor:  nopTransformer{},
+		observer:  nopObserver{},
+		archive:   nopArchive{},
+		moderator: nopModerator{},
+	}
+	s.stopCtx, s.stop = context.WithCancel(context.Background())
+	s.registry = NewRegistry(HandlerFunc(s.ignore))
+	s.registry.Use(s.loggingMiddleware, s.dedupMiddleware)
+
+	// 文本消息按触发规则处理并优先识别命令，媒体消息直接转发；均先做访问控制，转发前按用户限流
+	forward := s.rateLimitMiddleware(HandlerFunc(s.forward))
+	s.registry.Register(MsgTypeText, s.filterMiddleware(func(m Message) bool {
+		return s.mention.Triggered(m)
+	})(s.accessMiddleware(s.commandMiddleware(s.autoReplyMiddleware(forward)))))
+	for _, t := range []string{MsgTypeImage, MsgTypeVoice, MsgTypeVideo, MsgTypeFile, MsgTypeLocation, MsgTypeLink} {
+		s.registry.Register(t, s.accessMiddleware(forward))
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// nopTransformer 不做任何变换
+type nopTransformer struct{}
+
+func (nopTransformer) Transform(_ context.Context, content string) string { return content }
+
+// nopObserver 忽略所有事件
+type nopObserver struct{}
+
+func (nopObserver) OnMessage(context.Context, Message, string) {}
+
+func (nopObserver) OnForwardDone(context.Context, Message, time.Duration, error) {}
+
+// Observers 将管道事件依次交给多个观察者
+func Observers(observers ...Observer) Observer {
+	switch len(observers) {
+	case 0:
+		return nopObserver{}
+	case 1:
+		return observers[0]
+	}
+	return multiObserver(observers)
+}
+
+type multiObserver []Observer
+
+func (m multiObserver) OnMessage(ctx context.Context, msg Message, outcome string) {
+	for _, o := range m {
+		o.OnMessage(ctx, msg, outcome)
+	}
+}
+
+func (m multiObserver) OnForwardDone(ctx context.Context, msg Message, latency time.Duration, err error) {
+	for _, o := range m {
+		o.OnForwardDone(ctx, msg, latency, err)
+	}
+}
+
+// nopArchive 不归档
+type nopArchive struct{}
+
+func (nopArchive) Inbound(context.Context, string, Message) {}
+
+func (nopArchive) Outbound(context.Context, string, Message, string) {}
+
+// Archives 将消息依次交给多个归档，用于同时启用归档和消息发布等场景
+func Archives(archives ...Archive) Archive {
+	switch len(archives) {
+	case 0:
+		return nopArchive{}
+	case 1:
+		return archives[0]
+	}
+	return multiArchive(archives)
+}
+
+type multiArchive []Archive
+
+func (m multiArchive) Inbound(ctx context.Context, agent string, msg Message) {
+	for _, a := range m {
+		a.Inbound(ctx, agent, msg)
+	}
+}
+
+func (m multiArchive) Outbound(ctx context.Context, agent string, msg Message, reply string) {
+	for _, a := range m {
+		a.Outbound(ctx, agent, msg, reply)
 	}
 }
 
+var tracer = otel.Tracer("go-wework-svc/internal/wework")
+
 // VerifyURL 处理企业微信 URL 验证请求
 // 1. 验证签名 2. 解密 echostr 3. 返回明文
 func (s *serviceImpl) VerifyURL(ctx context.Context, q CallbackQuery) (string, error) {
+	ctx, span := tracer.Start(ctx, "wework.VerifyURL")
+	defer span.End()
+
+	plaintext, err := s.verifyURL(ctx, q)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "verify url failed")
+	}
+	return plaintext, err
+}
+
+func (s *serviceImpl) verifyURL(ctx context.Context, q CallbackQuery) (string, error) {
 	if !s.crypto.VerifySignature(q.MsgSignature, q.Timestamp, q.Nonce, q.Echostr) {
 		return "", ErrInvalidSignature
 	}
+	if err := s.checkReplay(ctx, q); err != nil {
+		return "", err
+	}
 
-	plaintext, err := s.crypto.Decrypt(q.Echostr)
+	// URL 验证请求无法区分是否为智能机器人，echostr 只原样返回，允许 ReceiveId 为空
+	plaintext, err := s.crypto.Decrypt(WithEmptyReceiveID(ctx), q.Echostr)
 	if err != nil {
 		return "", fmt.Errorf("decrypt echostr: %w", err)
 	}
@@ -51,74 +395,616 @@ func (s *serviceImpl) VerifyURL(ctx context.Context, q CallbackQuery) (string, e
 }
 
 // HandleCallback 处理企业微信消息回调
-// 1. 解析加密 XML 2. 验证签名 3. 解密 4. 解析明文 XML 5. 检测 @提及 6. 异步转发 AI
-func (s *serviceImpl) HandleCallback(ctx context.Context, q CallbackQuery, body []byte) error {
-	// 1. 解析加密 XML
+// 1. 解析加密消息体（XML 或 JSON） 2. 验证签名与重放检查 3. 解密 4. 解析明文 5. 经中间件链路由到处理器
+func (s *serviceImpl) HandleCallback(ctx context.Context, q CallbackQuery, body []byte) ([]byte, error) {
+	ctx, span := tracer.Start(ctx, "wework.HandleCallback")
+	defer span.End()
+
+	reply, err := s.handleCallback(ctx, q, body)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "handle callback failed")
+	}
+	span.SetAttributes(attribute.Bool("wework.passive_reply", reply != nil))
+	return reply, err
+}
+
+func (s *serviceImpl) handleCallback(ctx context.Context, q CallbackQuery, body []byte) ([]byte, error) {
+	if s.agent != "" {
+		ctx = shared.WithLogAttrs(ctx, slog.String("agent", s.agent))
+	}
+
+	// 1. 解析加密消息体，JSON 请求体按 JSON 回调处理
 	var encBody EncryptedBody
-	if err := xml.Unmarshal(body, &encBody); err != nil {
-		return fmt.Errorf("unmarshal encrypted body: %w", err)
+	q.JSON = isJSONBody(body)
+	if q.JSON {
+		if err := json.Unmarshal(body, &encBody); err != nil {
+			return nil, fmt.Errorf("unmarshal encrypted body: %w: %w", ErrMalformedBody, err)
+		}
+	} else if err := xml.Unmarshal(body, &encBody); err != nil {
+		return nil, fmt.Errorf("unmarshal encrypted body: %w: %w", ErrMalformedBody, err)
 	}
 
 	// 2. 验证签名
 	if !s.crypto.VerifySignature(q.MsgSignature, q.Timestamp, q.Nonce, encBody.Encrypt) {
-		s.logger.Warn("signature verification failed",
+		s.logger.WarnContext(ctx, "signature verification failed",
 			"timestamp", q.Timestamp,
 			"nonce", q.Nonce,
 		)
-		return ErrInvalidSignature
+		return nil, ErrInvalidSignature
+	}
+	if err := s.checkReplay(ctx, q); err != nil {
+		return nil, err
 	}
 
-	// 3. 解密消息
-	plaintext, err := s.crypto.Decrypt(encBody.Encrypt)
+	// 3. 解密消息，只有 JSON 回调（智能机器人）允许 ReceiveId 为空
+	decryptCtx := ctx
+	if q.JSON {
+		decryptCtx = WithEmptyReceiveID(ctx)
+	}
+	plaintext, err := s.crypto.Decrypt(decryptCtx, encBody.Encrypt)
 	if err != nil {
-		s.logger.Error("failed to decrypt message", "error", err)
-		return fmt.Errorf("decrypt message: %w", err)
+		s.logger.ErrorContext(ctx, "failed to decrypt message", "error", err)
+		return nil, fmt.Errorf("decrypt message: %w", err)
 	}
 
-	// 4. 解析明文 XML
+	// 4. 解析明文
 	var msg Message
-	if err := xml.Unmarshal(plaintext, &msg); err != nil {
-		return fmt.Errorf("unmarshal message: %w", err)
+	var ev *Event
+	if isJSONBody(plaintext) {
+		if msg, ev, err = parseJSONMessage(plaintext); err != nil {
+			return nil, fmt.Errorf("unmarshal message: %w: %w", ErrMalformedBody, err)
+		}
+	} else if err := xml.Unmarshal(plaintext, &msg); err != nil {
+		return nil, fmt.Errorf("unmarshal message: %w: %w", ErrMalformedBody, err)
 	}
+	if msg.MsgType == "" {
+		return nil, ErrUnsupportedMsgType
+	}
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.String("wework.msg_id", msg.MsgID),
+		attribute.String("wework.msg_type", msg.MsgType),
+	)
+	// 之后的日志（包括异步转发 AI 和 AI 客户端中的日志）都带上消息关联字段
+	ctx = shared.WithLogAttrs(ctx, messageLogAttrs(msg)...)
+	s.archive.Inbound(ctx, s.agent, msg)
+
+	// 5. 交给处理器注册表，经中间件链（日志、去重等）后按类型路由
+	req := &Request{Query: q, Message: msg, Event: ev}
+	if msg.MsgType == MsgTypeEvent && ev == nil {
+		ev = new(Event)
+		if err := xml.Unmarshal(plaintext, ev); err != nil {
+			return nil, fmt.Errorf("unmarshal event: %w: %w", ErrMalformedBody, err)
+		}
+		req.Event = ev
+	}
+	if ev != nil {
+		trace.SpanFromContext(ctx).SetAttributes(attribute.String("wework.event", ev.Event))
+	}
+	return s.registry.Handle(ctx, req)
+}
 
-	// 5. 仅处理文本消息中的 @提及
-	if msg.MsgType != MsgTypeText {
+// messageLogAttrs 消息的日志关联字段，不包含正文；应用消息的 ToUserName 为 corp_id
+func messageLogAttrs(msg Message) []slog.Attr {
+	attrs := []slog.Attr{slog.String("msg_id", msg.MsgID)}
+	if msg.AgentID != 0 {
+		attrs = append(attrs, slog.Int64("agent_id", msg.AgentID))
+	}
+	if msg.ToUserName != "" {
+		attrs = append(attrs, slog.String("corp_id", msg.ToUserName))
+	}
+	return attrs
+}
+
+// ignore 未注册处理器的消息
+func (s *serviceImpl) ignore(ctx context.Context, req *Request) ([]byte, error) {
+	s.observer.OnMessage(ctx, req.Message, OutcomeIgnored)
+	return nil, nil
+}
+
+// forward 转发消息给 AI：被动回复模式下在企业微信 5 秒窗口内同步等待，否则异步转发不阻塞响应
+func (s *serviceImpl) forward(ctx context.Context, req *Request) ([]byte, error) {
+	s.observer.OnMessage(ctx, req.Message, OutcomeForwarded)
+	if s.passiveTimeout > 0 {
+		return s.passiveReply(ctx, req.Query, req.Message)
+	}
+	asyncCtx := s.detach(ctx)
+	release := s.holdSlot()
+	s.goSafe(asyncCtx, "forward_to_ai", req.Message, func() {
+		defer release()
+		s.forwardToAI(asyncCtx, req.Message)
+	})
+	return nil, nil
+}
+
+// passiveReply 在被动回复窗口内等待 AI 回复并构造加密响应
+// AI 失败时返回 nil 让企业微信收到普通应答；超时则转为异步主动发送
+func (s *serviceImpl) passiveReply(ctx context.Context, q CallbackQuery, msg Message) ([]byte, error) {
+	type result struct {
+		reply string
+		err   error
+	}
+	done := make(chan result, 1)
+	asyncCtx := s.detach(ctx)
+	release := s.holdSlot()
+	s.goSafe(asyncCtx, "passive_reply", msg, func() {
+		defer release()
+		// panic 时也要写入结果，避免等待方永久阻塞
+		r := result{err: fmt.Errorf("ask ai: panic recovered")}
+		defer func() { done <- r }()
+		r.reply, r.err = s.askAI(asyncCtx, msg)
+	})
+
+	timer := time.NewTimer(s.passiveTimeout)
+	defer timer.Stop()
+
+	var r result
+	select {
+	case r = <-done:
+	case <-timer.C:
+		s.logger.WarnContext(ctx, "AI reply missed passive reply window",
+			"msg_id", msg.MsgID,
+			"timeout", s.passiveTimeout,
+		)
+		s.goSafe(asyncCtx, "late_reply", msg, func() {
+			switch r := <-done; {
+			case r.err == nil:
+				s.deliver(asyncCtx, msg, r.reply)
+			case !errors.Is(r.err, errQueued):
+				s.deliver(asyncCtx, msg, s.errorReply(asyncCtx, msg))
+			}
+		})
+		return nil, nil
+	}
+	if errors.Is(r.err, errQueued) {
+		return nil, nil
+	}
+	if r.err != nil {
+		r.reply = s.errorReply(ctx, msg)
+	}
+	if r.reply == "" {
+		return nil, nil
+	}
+
+	out, err := s.passiveResponse(ctx, q, msg, r.reply)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to build passive reply", "msg_id", msg.MsgID, "error", err)
+		s.deliver(asyncCtx, msg, r.reply)
+		return nil, nil
+	}
+	return out, nil
+}
+
+// encryptReply 加密回复消息并计算签名，返回完整的加密 XML，JSON 回调返回加密 JSON
+func (s *serviceImpl) encryptReply(q CallbackQuery, reply ReplyMessage) ([]byte, error) {
+	if q.JSON {
+		return s.encryptJSONReply(q, reply)
+	}
+	plain, err := xml.Marshal(reply)
+	if err != nil {
+		return nil, fmt.Errorf("marshal reply: %w", err)
+	}
+	encrypted, err := s.crypto.Encrypt(plain)
+	if err != nil {
+		return nil, fmt.Errorf("encrypt reply: %w", err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	out, err := xml.Marshal(EncryptedReply{
+		Encrypt:      encrypted,
+		MsgSignature: s.crypto.Sign(timestamp, q.Nonce, encrypted),
+		TimeStamp:    timestamp,
+		Nonce:        q.Nonce,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal encrypted reply: %w", err)
+	}
+	return out, nil
+}
+
+// checkReplay 校验时间戳新鲜度并登记 nonce，须在签名验证通过后调用，避免伪造请求占用 nonce 缓存
+func (s *serviceImpl) checkReplay(ctx context.Context, q CallbackQuery) error {
+	if s.nonces == nil {
+		return nil
+	}
+	ts, err := strconv.ParseInt(q.Timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: invalid timestamp %q", ErrStaleTimestamp, q.Timestamp)
+	}
+	skew := time.Since(time.Unix(ts, 0))
+	if skew > s.replayWindow || skew < -s.replayWindow {
+		return fmt.Errorf("%w: skew %s", ErrStaleTimestamp, skew.Round(time.Second))
+	}
+
+	// 窗口外的时间戳已被拒绝，nonce 只需保留两倍窗口
+	first, err := s.nonces.SetNX(ctx, "nonce:"+q.Timestamp+":"+q.Nonce, "1", 2*s.replayWindow)
+	if err != nil {
+		s.logger.WarnContext(ctx, "nonce check failed", "error", err)
 		return nil
 	}
-	if !containsMention(msg.Content) {
+	if !first {
+		return ErrReplayedRequest
+	}
+	return nil
+}
+
+// isDuplicate 基于共享 Store 判断消息是否已处理过，首次出现时返回登记的键，存储故障时放行
+func (s *serviceImpl) isDuplicate(ctx context.Context, msg Message) (string, bool) {
+	if s.dedup == nil {
+		return "", false
+	}
+	// 事件消息没有 MsgId，使用发送者 + 时间；多应用 / 多租户共享存储时按名称隔离
+	key := msg.MsgID
+	if key == "" {
+		key = msg.FromUserName + ":" + strconv.FormatInt(msg.CreateTime, 10)
+	}
+	if s.agent != "" {
+		key = s.agent + ":" + key
+	}
+	key = "dedup:" + key
+	first, err := s.dedup.SetNX(ctx, key, "1", s.dedupTTL)
+	if err != nil {
+		s.logger.WarnContext(ctx, "dedup check failed", "msg_id", msg.MsgID, "error", err)
+		return "", false
+	}
+	if !first {
+		return "", true
+	}
+	return key, false
+}
+
+// attachmentOf 提取非文本消息的附加信息，文本消息返回 nil
+func attachmentOf(msg Message) *ai.Attachment {
+	switch msg.MsgType {
+	case MsgTypeImage:
+		return &ai.Attachment{Type: msg.MsgType, MediaID: msg.MediaID, URL: msg.PicURL}
+	case MsgTypeVoice:
+		return &ai.Attachment{Type: msg.MsgType, MediaID: msg.MediaID, Format: msg.Format}
+	case MsgTypeVideo:
+		return &ai.Attachment{Type: msg.MsgType, MediaID: msg.MediaID, ThumbMediaID: msg.ThumbMediaID}
+	case MsgTypeFile:
+		return &ai.Attachment{Type: msg.MsgType, MediaID: msg.MediaID}
+	case MsgTypeLocation:
+		return &ai.Attachment{Type: msg.MsgType, Latitude: msg.LocationX, Longitude: msg.LocationY, Label: msg.Label}
+	case MsgTypeLink:
+		return &ai.Attachment{Type: msg.MsgType, URL: msg.URL, Title: msg.Title, Description: msg.Description}
+	default:
 		return nil
 	}
+}
+
+// forwardToAI 将消息异步转发给 AI 助手，并主动发送回复
+func (s *serviceImpl) forwardToAI(ctx context.Context, msg Message) {
+	if s.stream != nil && s.canSend(msg) {
+		s.askAIStream(ctx, msg)
+		return
+	}
+	reply, err := s.askAI(ctx, msg)
+	if errors.Is(err, errQueued) {
+		return
+	}
+	if err != nil {
+		s.deliver(ctx, msg, s.errorReply(ctx, msg))
+		return
+	}
+	s.deliver(ctx, msg, reply)
+}
+
+// errQueued 转发失败的消息已写入 outbox，由重投送达回复，不再向用户发送错误提示
+var errQueued = errors.New("saved to outbox")
+
+// saveFailed 将转发失败的消息写入 outbox，返回是否写入成功；未配置 outbox 时忽略
+func (s *serviceImpl) saveFailed(ctx context.Context, msg Message, req ai.ChatRequest, cause error) bool {
+	if s.outbox == nil {
+		return false
+	}
+	// 图片内容不写入 outbox，只保留 MediaID，重投时重新下载
+	if a := req.Attachment; a != nil && len(a.Data) > 0 {
+		stripped := *a
+		stripped.Data, stripped.MimeType = nil, ""
+		req.Attachment = &stripped
+	}
+	now := time.Now()
+	e := &outbox.Entry{
+		MsgID:         msg.MsgID,
+		Agent:         s.agent,
+		UserID:        msg.FromUserName,
+		ChatID:        msg.ChatID,
+		ChatType:      msg.ChatType,
+		ResponseURL:   msg.ResponseURL,
+		Request:       req,
+		CreatedAt:     now,
+		Attempts:      1,
+		LastError:     cause.Error(),
+		LastAttemptAt: now,
+	}
+	if err := s.outbox.Add(ctx, e); err != nil {
+		s.logger.ErrorContext(ctx, "failed to save message to outbox", "msg_id", msg.MsgID, "error", err)
+		return false
+	}
+	s.logger.InfoContext(ctx, "message saved to outbox", "msg_id", msg.MsgID, "outbox_id", e.ID)
+	return true
+}
 
-	// 6. 异步转发给 AI（不阻塞响应）
-	go s.forwardToAI(context.WithoutCancel(ctx), msg)
+// Redeliver 实现 Service 接口，请求已在入库前完成入站变换
+func (s *serviceImpl) Redeliver(ctx context.Context, e outbox.Entry) error {
+	ctx = shared.WithLogAttrs(ctx, slog.String("msg_id", e.MsgID))
+	if s.agent != "" {
+		ctx = shared.WithLogAttrs(ctx, slog.String("agent", s.agent))
+	}
+	msg := Message{
+		MsgID:        e.MsgID,
+		FromUserName: e.UserID,
+		ChatID:       e.ChatID,
+		ChatType:     e.ChatType,
+		ResponseURL:  e.ResponseURL,
+	}
+	// 无法发送时返回错误，记录保留在 outbox 中，避免重投成功却丢弃回复
+	if !s.canSend(msg) {
+		return fmt.Errorf("redeliver %s: no sender or response_url available", e.MsgID)
+	}
+	// 工具不随请求持久化，按当前配置重新挂载
+	req := e.Request
+	req.Tools = s.tools
+	if a := req.Attachment; a != nil && a.Type == MsgTypeImage {
+		s.attachImage(ctx, a)
+	}
+	resp, err := s.aiSvc.SendMessage(ctx, req)
+	if err != nil {
+		return err
+	}
+	reply, _ := s.moderate(ctx, msg, DirectionOutbound, s.outbound.Transform(ctx, resp.Reply))
+	// 发送失败时返回错误，记录保留在 outbox 中等待下次重投
+	if err := s.deliver(ctx, msg, reply); err != nil {
+		return err
+	}
+	s.remember(ctx, req, reply)
+	s.postWebhook(ctx, msg, reply)
+	return nil
+}
 
+// deliver 通过 Sender（或消息的 response_url）将回复主动发送给消息发送者，都不可用时丢弃；返回分段发送的错误
+func (s *serviceImpl) deliver(ctx context.Context, msg Message, reply string) error {
+	if !s.canSend(msg) {
+		s.logger.DebugContext(ctx, "no sender configured, dropping AI reply", "msg_id", msg.MsgID)
+		return nil
+	}
+	if reply == "" {
+		return nil
+	}
+	msgType, parts := s.formatReply(reply, false)
+	if len(parts) == 0 {
+		return nil
+	}
+	// 发送失败时不归档，归档中只保留用户实际收到的回复
+	if err := s.sendParts(ctx, msg, msgType, parts); err != nil {
+		return err
+	}
+	s.sendReplyMedia(ctx, msg, reply)
+	s.archive.Outbound(ctx, s.agent, msg, reply)
 	return nil
 }
 
-// containsMention 检测消息内容是否包含 @提及
-func containsMention(content string) bool {
-	return strings.Contains(content, "@")
+// sendParts 依次主动发送分段回复，某一段失败时不再发送后续分段并返回错误
+func (s *serviceImpl) sendParts(ctx context.Context, msg Message, msgType string, parts []string) error {
+	for i, part := range parts {
+		err := s.sendPart(ctx, msg, msgType, part)
+		if err != nil {
+			s.logger.ErrorContext(ctx, "failed to send AI reply",
+				"msg_id", msg.MsgID,
+				"to_user", msg.FromUserName,
+				"part", i+1,
+				"parts", len(parts),
+				"error", err,
+			)
+			return fmt.Errorf("send reply part %d/%d: %w", i+1, len(parts), err)
+		}
+	}
+	s.logger.InfoContext(ctx, "AI reply sent", "msg_id", msg.MsgID, "to_user", msg.FromUserName, "parts", len(parts))
+	return nil
 }
 
-// forwardToAI 将 @提及消息异步转发给 AI 助手
-func (s *serviceImpl) forwardToAI(ctx context.Context, msg Message) {
+// canSend 消息能否主动回复：配置了 Sender，或 JSON 回调携带 response_url
+func (s *serviceImpl) canSend(msg Message) bool {
+	return s.sender != nil || (msg.ResponseURL != "" && s.responseURL != nil)
+}
+
+// sendPart 发送一段回复，消息携带 response_url 时优先使用，智能机器人没有应用消息接口
+func (s *serviceImpl) sendPart(ctx context.Context, msg Message, msgType, part string) error {
+	if msg.ResponseURL != "" && s.responseURL != nil {
+		return s.responseURL(msg.ResponseURL).Post(ctx, WebhookMessage{MsgType: msgType, Content: part})
+	}
+	return s.sender.Send(ctx, OutgoingMessage{
+		ToUser:  msg.FromUserName,
+		MsgType: msgType,
+		Content: part,
+	})
+}
+
+// passiveResponse 构造被动回复：第一段加密写回响应，其余分段和回复中的素材通过 Sender 异步发送
+func (s *serviceImpl) passiveResponse(ctx context.Context, q CallbackQuery, msg Message, reply string) ([]byte, error) {
+	_, parts := s.formatReply(reply, true)
+	if len(parts) == 0 {
+		return nil, nil
+	}
+	out, err := s.encryptReply(q, NewTextReply(msg, parts[0]))
+	if err != nil {
+		return nil, err
+	}
+	rest := parts[1:]
+	switch {
+	case !s.canSend(msg):
+		if len(rest) > 0 {
+			s.logger.WarnContext(ctx, "no sender configured, dropping remaining reply parts", "msg_id", msg.MsgID, "dropped", len(rest))
+		}
+	case len(rest) > 0 || s.uploader != nil:
+		asyncCtx := s.detach(ctx)
+		s.goSafe(asyncCtx, "reply_parts", msg, func() {
+			if len(rest) > 0 {
+				s.sendParts(asyncCtx, msg, MsgTypeText, rest)
+			}
+			s.sendReplyMedia(asyncCtx, msg, reply)
+		})
+	}
+	s.archive.Outbound(ctx, s.agent, msg, reply)
+	return out, nil
+}
+
+// bareText 经过入站变换并去掉对机器人的 @提及后的消息内容
+func (s *serviceImpl) bareText(ctx context.Context, msg Message) string {
+	text := strings.TrimSpace(s.inbound.Transform(ctx, msg.Content))
+	for _, name := range s.mention.Names {
+		text = strings.TrimSpace(strings.TrimPrefix(text, "@"+name))
+	}
+	return text
+}
+
+// respond 回复无需 AI 的消息：被动回复模式下同步生成并加密写回，否则异步生成并主动发送
+func (s *serviceImpl) respond(ctx context.Context, req *Request, task string, produce func(context.Context) string) ([]byte, error) {
+	msg := req.Message
+	if s.passiveTimeout > 0 {
+		reply := produce(ctx)
+		if reply == "" {
+			return nil, nil
+		}
+		out, err := s.passiveResponse(ctx, req.Query, msg, reply)
+		if err != nil {
+			s.logger.ErrorContext(ctx, "failed to build passive reply", "msg_id", msg.MsgID, "error", err)
+			return nil, nil
+		}
+		return out, nil
+	}
+	asyncCtx := s.detach(ctx)
+	s.goSafe(asyncCtx, task, msg, func() { s.deliver(asyncCtx, msg, produce(asyncCtx)) })
+	return nil, nil
+}
+
+// postWebhook 将 AI 回复异步推送到群机器人，未配置时忽略
+func (s *serviceImpl) postWebhook(ctx context.Context, msg Message, reply string) {
+	if s.webhook == nil || reply == "" {
+		return
+	}
+	asyncCtx := s.detach(ctx)
+	s.goSafe(asyncCtx, "reply_webhook", msg, func() {
+		err := s.webhook.Post(asyncCtx, WebhookMessage{
+			MsgType: MsgTypeMarkdown,
+			Content: fmt.Sprintf("**%s** 的提问已回复：\n%s", msg.FromUserName, reply),
+		})
+		if err != nil {
+			s.logger.WarnContext(ctx, "failed to post AI reply to webhook", "msg_id", msg.MsgID, "error", err)
+		}
+	})
+}
+
+// chatRequest 构造 AI 请求，内容经过入站变换并填写会话标识，启用会话历史时附带最近的对话，启用通讯录时附带发送者信息
+func (s *serviceImpl) chatRequest(ctx context.Context, msg Message) ai.ChatRequest {
 	req := ai.ChatRequest{
-		UserID:  msg.FromUserName,
-		Content: msg.Content,
-		Source:  "wework",
+		UserID:     msg.FromUserName,
+		Content:    s.redactor.Transform(ctx, s.inbound.Transform(ctx, msg.Content)),
+		Source:     "wework",
+		Attachment: attachmentOf(msg),
+		Profile:    s.profile(ctx, msg),
+		Route:      s.route(ctx, msg.FromUserName),
+		Persona:    s.personaOf(msg),
+		Tools:      s.tools,
+	}
+	if msg.MsgType == MsgTypeImage {
+		s.attachImage(ctx, req.Attachment)
 	}
+	if msg.IsGroup() {
+		req.GroupID = msg.ChatID
+	}
+	req.ConversationID = s.conversationID(ctx, msg)
+	if s.history != nil {
+		turns, err := s.history.Load(ctx, req.ConversationID)
+		if err != nil {
+			s.logger.WarnContext(ctx, "failed to load conversation history", "user_id", req.UserID, "error", err)
+		}
+		req.History = turns
+	}
+	return req
+}
+
+// prepare 构造 AI 请求并完成文件提取和入站审核，ok 为 false 时不转发 AI，reply 为直接回复给用户的提示
+func (s *serviceImpl) prepare(ctx context.Context, msg Message) (req ai.ChatRequest, reply string, ok bool) {
+	req = s.chatRequest(ctx, msg)
+	if msg.MsgType == MsgTypeFile {
+		if reply, ok := s.attachFileText(ctx, msg, req.Attachment); !ok {
+			return req, reply, false
+		}
+	}
+	content, blocked := s.moderate(ctx, msg, DirectionInbound, req.Content)
+	if blocked {
+		return req, content, false
+	}
+	req.Content = content
+	return req, "", true
+}
 
-	_, err := s.aiSvc.SendMessage(ctx, req)
+// remember 记录本轮对话，仅记录文本内容
+func (s *serviceImpl) remember(ctx context.Context, req ai.ChatRequest, reply string) {
+	if s.history == nil || req.Content == "" || reply == "" {
+		return
+	}
+	err := s.history.Append(ctx, req.ConversationID,
+		ai.Turn{Role: ai.RoleUser, Content: req.Content},
+		ai.Turn{Role: ai.RoleAssistant, Content: reply},
+	)
+	if err != nil {
+		s.logger.WarnContext(ctx, "failed to save conversation history", "user_id", req.UserID, "error", err)
+	}
+}
+
+// conversationKey 群聊按群组、单聊按用户区分会话，多应用 / 多租户按名称隔离
+func (s *serviceImpl) conversationKey(msg Message) string {
+	key := "user:" + msg.FromUserName
+	if msg.IsGroup() {
+		key = "group:" + msg.ChatID
+	}
+	if s.agent != "" {
+		key = s.agent + ":" + key
+	}
+	return key
+}
+
+// askAI 将消息发送给 AI 助手，返回经过出站变换和审核的回复；消息未转发 AI 时返回给用户的提示
+func (s *serviceImpl) askAI(ctx context.Context, msg Message) (string, error) {
+	ctx, span := tracer.Start(ctx, "wework.askAI",
+		trace.WithAttributes(attribute.String("wework.msg_id", msg.MsgID)),
+	)
+	defer span.End()
+
+	req, reply, ok := s.prepare(ctx, msg)
+	if !ok {
+		return reply, nil
+	}
+
+	start := time.Now()
+	resp, err := s.aiSvc.SendMessage(ctx, req)
+	s.observer.OnForwardDone(ctx, msg, time.Since(start), err)
 	if err != nil {
-		s.logger.Error("failed to forward message to AI",
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "ai request failed")
+		s.logger.ErrorContext(ctx, "failed to forward message to AI",
 			"user_id", msg.FromUserName,
 			"error", err,
 		)
-		return
+		if s.saveFailed(ctx, msg, req, err) {
+			return "", fmt.Errorf("%w: %w", errQueued, err)
+		}
+		return "", err
 	}
 
-	s.logger.Info("message forwarded to AI",
+	reply, _ = s.moderate(ctx, msg, DirectionOutbound, s.outbound.Transform(ctx, resp.Reply))
+	s.remember(ctx, req, reply)
+	s.postWebhook(ctx, msg, reply)
+
+	s.logger.InfoContext(ctx, "message forwarded to AI",
 		"msg_id", msg.MsgID,
 		"from_user", msg.FromUserName,
+		"backend", resp.Backend,
+		"provider", resp.Provider,
+		"reply_len", len(reply),
 	)
+	return reply, nil
 }
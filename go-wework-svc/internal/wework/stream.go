@@ -0,0 +1,186 @@
+package wework
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"go-wework-svc/internal/ai"
+)
+
+// StreamPolicy 流式回复的分段发送策略，两个条件满足其一即发送当前累积内容
+// 配置中未设置或设置为 0 时由启动流程填入默认值（3s、200 字）
+type StreamPolicy struct {
+	FlushInterval time.Duration // 距上次发送的最长间隔，AI 暂停输出时也按此间隔发送；小于等于 0 表示不按时间发送
+	MinChars      int           // 累积字符数阈值，小于等于 0 表示每段增量立即发送
+}
+
+// WithStreaming 启用流式回复，仅在异步模式且配置了 Sender（或消息携带 response_url）时生效
+func WithStreaming(p StreamPolicy) Option {
+	return func(s *serviceImpl) { s.stream = &p }
+}
+
+// streamFlusher 累积增量内容并按策略分段发送，增量回调和定时发送可能并发调用
+type streamFlusher struct {
+	policy StreamPolicy
+	send   func(string)
+
+	mu   sync.Mutex
+	buf  strings.Builder
+	last time.Time
+	sent int
+}
+
+func (f *streamFlusher) add(delta string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.buf.WriteString(delta)
+	if utf8.RuneCountInString(f.buf.String()) >= f.policy.MinChars || f.due() {
+		f.flushLocked()
+	}
+}
+
+// due 距上次发送已超过 FlushInterval，调用方需持有锁
+func (f *streamFlusher) due() bool {
+	return f.policy.FlushInterval > 0 && time.Since(f.last) >= f.policy.FlushInterval
+}
+
+// tick 按 FlushInterval 定时发送，AI 长时间没有新增量时已累积的内容不会一直停留在缓冲中
+// 返回的 stop 停止定时器并等待进行中的发送结束
+func (f *streamFlusher) tick() (stop func()) {
+	if f.policy.FlushInterval <= 0 {
+		return func() {}
+	}
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		t := time.NewTicker(f.policy.FlushInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+				f.mu.Lock()
+				if f.due() {
+					f.flushLocked()
+				}
+				f.mu.Unlock()
+			}
+		}
+	})
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+// flush 发送当前累积内容
+func (f *streamFlusher) flush() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.flushLocked()
+}
+
+// parts 已发送的分段数
+func (f *streamFlusher) parts() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.sent
+}
+
+// flushLocked 发送当前累积内容，调用方需持有锁
+func (f *streamFlusher) flushLocked() {
+	if strings.TrimSpace(f.buf.String()) == "" {
+		return
+	}
+	f.send(f.buf.String())
+	f.buf.Reset()
+	f.last = time.Now()
+	f.sent++
+}
+
+// askAIStream 流式请求 AI，回复按策略分段主动发送
+// 输出变换和审核按分段执行；尚未发送任何内容就失败时写入 outbox，已发送部分内容时发出剩余缓冲并不再重投以免重复
+func (s *serviceImpl) askAIStream(ctx context.Context, msg Message) {
+	ctx, span := tracer.Start(ctx, "wework.askAIStream",
+		trace.WithAttributes(attribute.String("wework.msg_id", msg.MsgID)),
+	)
+	defer span.End()
+
+	req, reply, ok := s.prepare(ctx, msg)
+	if !ok {
+		s.deliver(ctx, msg, reply)
+		return
+	}
+
+	// 某一分段被拦截后发送拦截提示，其余分段不再发送；任一分段发送失败时不归档
+	stopped, failed := false, false
+	f := &streamFlusher{
+		policy: *s.stream,
+		last:   time.Now(),
+		send: func(part string) {
+			if stopped {
+				return
+			}
+			part, stopped = s.moderate(ctx, msg, DirectionOutbound, s.outbound.Transform(ctx, part))
+			// 分段只发送文本，回复中的素材在完整回复生成后发送一次
+			if msgType, parts := s.formatReply(part, false); len(parts) > 0 {
+				if err := s.sendParts(ctx, msg, msgType, parts); err != nil {
+					failed = true
+				}
+			}
+		},
+	}
+
+	start := time.Now()
+	stopTick := f.tick()
+	resp, err := ai.SendStream(ctx, s.aiSvc, req, f.add)
+	stopTick()
+	s.observer.OnForwardDone(ctx, msg, time.Since(start), err)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "ai stream failed")
+		sent := f.parts()
+		s.logger.ErrorContext(ctx, "failed to stream message from AI",
+			"user_id", msg.FromUserName,
+			"parts_sent", sent,
+			"error", err,
+		)
+		if sent == 0 {
+			if !s.saveFailed(ctx, msg, req, err) {
+				s.deliver(ctx, msg, s.errorReply(ctx, msg))
+			}
+			return
+		}
+		// 用户已收到部分回复，剩余的缓冲内容也发出去，不再重投
+		f.flush()
+		return
+	}
+	f.flush()
+	span.SetAttributes(attribute.Int("wework.stream_parts", f.parts()))
+
+	// 完整回复再审核一次，覆盖跨分段的敏感词，结果用于会话历史和群推送
+	reply, blocked := s.moderate(ctx, msg, DirectionOutbound, s.outbound.Transform(ctx, resp.Reply))
+	s.remember(ctx, req, reply)
+	s.postWebhook(ctx, msg, reply)
+	if !blocked {
+		s.sendReplyMedia(ctx, msg, reply)
+	}
+	if !failed {
+		s.archive.Outbound(ctx, s.agent, msg, reply)
+	}
+	s.logger.InfoContext(ctx, "message streamed from AI",
+		"msg_id", msg.MsgID,
+		"from_user", msg.FromUserName,
+		"backend", resp.Backend,
+		"provider", resp.Provider,
+		"parts", f.parts(),
+	)
+}
@@ -0,0 +1,191 @@
+// Package suite 服务商第三方应用（suite）的指令回调与授权企业 access_token 管理
+//
+// 指令回调使用 suite 的 Token / EncodingAESKey 加解密，ReceiveId 为 suite_id。
+// 授权企业的数据回调（成员消息）以授权企业 corp_id 为 ReceiveId，不在本包处理范围内。
+package suite
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"go-wework-svc/internal/store"
+	"go-wework-svc/internal/wework"
+	"go-wework-svc/internal/wework/token"
+)
+
+// InfoType 指令回调类型常量
+const (
+	InfoSuiteTicket = "suite_ticket"
+	InfoCreateAuth  = "create_auth"
+	InfoChangeAuth  = "change_auth"
+	InfoCancelAuth  = "cancel_auth"
+)
+
+// Command 解密后的指令回调
+type Command struct {
+	XMLName     xml.Name `xml:"xml"`
+	SuiteID     string   `xml:"SuiteId"`
+	InfoType    string   `xml:"InfoType"`
+	TimeStamp   int64    `xml:"TimeStamp"`
+	SuiteTicket string   `xml:"SuiteTicket"` // suite_ticket
+	AuthCode    string   `xml:"AuthCode"`    // create_auth
+	AuthCorpID  string   `xml:"AuthCorpId"`  // change_auth / cancel_auth
+}
+
+// Suite 第三方应用，实现 wework.CallbackService 以复用回调 HTTP 处理器
+type Suite struct {
+	suiteID    string
+	secret     string
+	crypto     wework.Crypto
+	baseURL    string
+	kv         store.Store
+	httpClient *http.Client
+	logger     *slog.Logger
+
+	suiteToken *cache
+
+	mu    sync.Mutex
+	corps map[string]*corpToken
+}
+
+// New 创建第三方应用，crypto 需使用 suite 的 Token / EncodingAESKey 和 suite_id 创建
+// suite_ticket、永久授权码和 access_token 均保存在 kv 中，多副本部署时共享
+func New(suiteID, secret string, crypto wework.Crypto, baseURL string, kv store.Store, logger *slog.Logger) *Suite {
+	if baseURL == "" {
+		baseURL = token.DefaultAPIBaseURL
+	}
+	s := &Suite{
+		suiteID:    suiteID,
+		secret:     secret,
+		crypto:     crypto,
+		baseURL:    baseURL,
+		kv:         kv,
+		httpClient: &http.Client{Timeout: httpTimeout},
+		logger:     logger,
+		corps:      make(map[string]*corpToken),
+	}
+	s.suiteToken = &cache{kv: kv, key: "suite:" + suiteID + ":suite_token", logger: logger}
+	return s
+}
+
+// VerifyURL 实现 wework.CallbackService，处理指令回调 URL 验证
+func (s *Suite) VerifyURL(ctx context.Context, q wework.CallbackQuery) (string, error) {
+	if !s.crypto.VerifySignature(q.MsgSignature, q.Timestamp, q.Nonce, q.Echostr) {
+		return "", wework.ErrInvalidSignature
+	}
+	plaintext, err := s.crypto.Decrypt(ctx, q.Echostr)
+	if err != nil {
+		return "", fmt.Errorf("decrypt echostr: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// HandleCallback 实现 wework.CallbackService，处理指令回调，从不返回被动回复
+func (s *Suite) HandleCallback(ctx context.Context, q wework.CallbackQuery, body []byte) ([]byte, error) {
+	var encBody wework.EncryptedBody
+	if err := xml.Unmarshal(body, &encBody); err != nil {
+		return nil, fmt.Errorf("unmarshal encrypted body: %w: %w", wework.ErrMalformedBody, err)
+	}
+	if !s.crypto.VerifySignature(q.MsgSignature, q.Timestamp, q.Nonce, encBody.Encrypt) {
+		return nil, wework.ErrInvalidSignature
+	}
+	plaintext, err := s.crypto.Decrypt(ctx, encBody.Encrypt)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt command: %w", err)
+	}
+
+	var cmd Command
+	if err := xml.Unmarshal(plaintext, &cmd); err != nil {
+		return nil, fmt.Errorf("unmarshal command: %w: %w", wework.ErrMalformedBody, err)
+	}
+	return nil, s.handleCommand(ctx, cmd)
+}
+
+// handleCommand 按 InfoType 处理指令
+func (s *Suite) handleCommand(ctx context.Context, cmd Command) error {
+	switch cmd.InfoType {
+	case InfoSuiteTicket:
+		// suite_ticket 每 10 分钟推送一次，有效期 30 分钟
+		if err := s.kv.Set(ctx, s.ticketKey(), cmd.SuiteTicket, ticketTTL); err != nil {
+			return fmt.Errorf("save suite ticket: %w", err)
+		}
+		s.logger.Debug("suite ticket updated")
+		return nil
+	case InfoCreateAuth:
+		return s.createAuth(ctx, cmd.AuthCode)
+	case InfoChangeAuth:
+		s.logger.Info("suite authorization changed", "auth_corp_id", cmd.AuthCorpID)
+		return nil
+	case InfoCancelAuth:
+		return s.cancelAuth(ctx, cmd.AuthCorpID)
+	default:
+		s.logger.Debug("unhandled suite command", "info_type", cmd.InfoType)
+		return nil
+	}
+}
+
+// createAuth 用临时授权码换取永久授权码并保存
+func (s *Suite) createAuth(ctx context.Context, authCode string) error {
+	resp, err := s.getPermanentCode(ctx, authCode)
+	if err != nil {
+		return fmt.Errorf("get permanent code: %w", err)
+	}
+	corpID := resp.AuthCorpInfo.CorpID
+	if err := s.kv.Set(ctx, s.permanentCodeKey(corpID), resp.PermanentCode, 0); err != nil {
+		return fmt.Errorf("save permanent code: %w", err)
+	}
+	// 换取永久授权码时同时返回了企业 access_token，直接写入缓存
+	s.corp(corpID).cache.store(ctx, resp.AccessToken, resp.ExpiresIn)
+	s.logger.Info("suite authorized by corp",
+		"auth_corp_id", corpID,
+		"corp_name", resp.AuthCorpInfo.CorpName,
+	)
+	return nil
+}
+
+// cancelAuth 企业取消授权，清除永久授权码和 access_token 缓存
+func (s *Suite) cancelAuth(ctx context.Context, corpID string) error {
+	if err := s.kv.Delete(ctx, s.permanentCodeKey(corpID)); err != nil {
+		return fmt.Errorf("delete permanent code: %w", err)
+	}
+	s.corp(corpID).cache.clear(ctx)
+
+	s.mu.Lock()
+	delete(s.corps, corpID)
+	s.mu.Unlock()
+
+	s.logger.Info("suite authorization cancelled", "auth_corp_id", corpID)
+	return nil
+}
+
+// CorpTokens 返回授权企业的 access_token 提供者，可用于消息发送等服务端 API
+func (s *Suite) CorpTokens(corpID string) token.TokenProvider {
+	return s.corp(corpID)
+}
+
+func (s *Suite) corp(corpID string) *corpToken {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.corps[corpID]
+	if !ok {
+		c = &corpToken{
+			suite:  s,
+			corpID: corpID,
+			cache:  &cache{kv: s.kv, key: "token:" + corpID + ":suite:" + s.suiteID, logger: s.logger},
+		}
+		s.corps[corpID] = c
+	}
+	return c
+}
+
+func (s *Suite) ticketKey() string {
+	return "suite:" + s.suiteID + ":ticket"
+}
+
+func (s *Suite) permanentCodeKey(corpID string) string {
+	return "suite:" + s.suiteID + ":permanent_code:" + corpID
+}
@@ -0,0 +1,265 @@
+package suite
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go-wework-svc/internal/store"
+	"go-wework-svc/internal/wework"
+)
+
+const (
+	// ticketTTL suite_ticket 有效期
+	ticketTTL = 30 * time.Minute
+	// refreshAhead 在过期前提前刷新的时间
+	refreshAhead = 5 * time.Minute
+	httpTimeout  = 5 * time.Second
+
+	errCodeInvalidSuiteToken = 40082 // 不合法的 suite_access_token
+	errCodeSuiteTokenExpired = 42009 // suite_access_token 已过期
+)
+
+// errNoTicket 尚未收到 suite_ticket，通常在应用上线后 10 分钟内自动推送
+var errNoTicket = errors.New("suite ticket not received yet")
+
+// cache 进程内 + 共享 Store 两级 token 缓存，共享值格式 "<token>|<过期 unix 秒>"
+type cache struct {
+	kv     store.Store
+	key    string
+	logger *slog.Logger
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+// get 返回有效 token，缓存失效时调用 fetch 获取
+func (c *cache) get(ctx context.Context, fetch func(context.Context) (string, int64, error)) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.valid() {
+		return c.token, nil
+	}
+	if c.loadShared(ctx) && c.valid() {
+		return c.token, nil
+	}
+	tok, expiresIn, err := fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+	c.set(ctx, tok, expiresIn)
+	return tok, nil
+}
+
+// store 写入已获取的 token
+func (c *cache) store(ctx context.Context, tok string, expiresIn int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.set(ctx, tok, expiresIn)
+}
+
+// clear 丢弃缓存
+func (c *cache) clear(ctx context.Context) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token = ""
+	if err := c.kv.Delete(ctx, c.key); err != nil {
+		c.logger.Warn("failed to delete shared token", "key", c.key, "error", err)
+	}
+}
+
+func (c *cache) valid() bool {
+	return c.token != "" && time.Until(c.expiresAt) > refreshAhead
+}
+
+func (c *cache) loadShared(ctx context.Context) bool {
+	v, ok, err := c.kv.Get(ctx, c.key)
+	if err != nil {
+		c.logger.Warn("failed to read shared token", "key", c.key, "error", err)
+		return false
+	}
+	if !ok {
+		return false
+	}
+	tok, exp, found := strings.Cut(v, "|")
+	sec, err := strconv.ParseInt(exp, 10, 64)
+	if !found || err != nil {
+		return false
+	}
+	c.token = tok
+	c.expiresAt = time.Unix(sec, 0)
+	return true
+}
+
+func (c *cache) set(ctx context.Context, tok string, expiresIn int64) {
+	c.token = tok
+	c.expiresAt = time.Now().Add(time.Duration(expiresIn) * time.Second)
+	if ttl := time.Until(c.expiresAt) - refreshAhead; ttl > 0 {
+		shared := tok + "|" + strconv.FormatInt(c.expiresAt.Unix(), 10)
+		if err := c.kv.Set(ctx, c.key, shared, ttl); err != nil {
+			c.logger.Warn("failed to write shared token", "key", c.key, "error", err)
+		}
+	}
+}
+
+// corpToken 授权企业的 access_token，实现 token.TokenProvider
+type corpToken struct {
+	suite  *Suite
+	corpID string
+	cache  *cache
+}
+
+// Token 实现 token.TokenProvider
+func (t *corpToken) Token(ctx context.Context) (string, error) {
+	return t.cache.get(ctx, t.fetch)
+}
+
+// Refresh 实现 token.TokenProvider
+func (t *corpToken) Refresh(ctx context.Context) (string, error) {
+	t.cache.clear(ctx)
+	return t.Token(ctx)
+}
+
+// fetch 使用永久授权码获取企业 access_token
+func (t *corpToken) fetch(ctx context.Context) (string, int64, error) {
+	code, ok, err := t.suite.kv.Get(ctx, t.suite.permanentCodeKey(t.corpID))
+	if err != nil {
+		return "", 0, fmt.Errorf("read permanent code: %w", err)
+	}
+	if !ok {
+		return "", 0, fmt.Errorf("corp %s has not authorized the suite", t.corpID)
+	}
+
+	var resp struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	in := map[string]string{"auth_corpid": t.corpID, "permanent_code": code}
+	if err := t.suite.callWithSuiteToken(ctx, "/cgi-bin/service/get_corp_token", in, &resp); err != nil {
+		return "", 0, err
+	}
+	t.suite.logger.Info("corp access token refreshed", "auth_corp_id", t.corpID, "expires_in", resp.ExpiresIn)
+	return resp.AccessToken, resp.ExpiresIn, nil
+}
+
+// getSuiteToken 使用 suite_ticket 获取 suite_access_token
+func (s *Suite) getSuiteToken(ctx context.Context) (string, int64, error) {
+	ticket, ok, err := s.kv.Get(ctx, s.ticketKey())
+	if err != nil {
+		return "", 0, fmt.Errorf("read suite ticket: %w", err)
+	}
+	if !ok {
+		return "", 0, errNoTicket
+	}
+
+	var resp struct {
+		SuiteAccessToken string `json:"suite_access_token"`
+		ExpiresIn        int64  `json:"expires_in"`
+	}
+	in := map[string]string{"suite_id": s.suiteID, "suite_secret": s.secret, "suite_ticket": ticket}
+	if err := s.post(ctx, "/cgi-bin/service/get_suite_token", nil, in, &resp); err != nil {
+		return "", 0, err
+	}
+	s.logger.Info("suite access token refreshed", "expires_in", resp.ExpiresIn)
+	return resp.SuiteAccessToken, resp.ExpiresIn, nil
+}
+
+// permanentCodeResponse get_permanent_code 响应
+type permanentCodeResponse struct {
+	AccessToken   string `json:"access_token"`
+	ExpiresIn     int64  `json:"expires_in"`
+	PermanentCode string `json:"permanent_code"`
+	AuthCorpInfo  struct {
+		CorpID   string `json:"corpid"`
+		CorpName string `json:"corp_name"`
+	} `json:"auth_corp_info"`
+}
+
+func (s *Suite) getPermanentCode(ctx context.Context, authCode string) (*permanentCodeResponse, error) {
+	var resp permanentCodeResponse
+	in := map[string]string{"auth_code": authCode}
+	if err := s.callWithSuiteToken(ctx, "/cgi-bin/service/get_permanent_code", in, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// callWithSuiteToken 携带 suite_access_token 调用接口，token 失效时刷新并重试一次
+func (s *Suite) callWithSuiteToken(ctx context.Context, path string, in, out any) error {
+	tok, err := s.suiteToken.get(ctx, s.getSuiteToken)
+	if err != nil {
+		return fmt.Errorf("get suite access token: %w", err)
+	}
+	err = s.post(ctx, path, url.Values{"suite_access_token": {tok}}, in, out)
+	if !isSuiteTokenInvalid(err) {
+		return err
+	}
+
+	s.suiteToken.clear(ctx)
+	tok, err = s.suiteToken.get(ctx, s.getSuiteToken)
+	if err != nil {
+		return fmt.Errorf("refresh suite access token: %w", err)
+	}
+	return s.post(ctx, path, url.Values{"suite_access_token": {tok}}, in, out)
+}
+
+func isSuiteTokenInvalid(err error) bool {
+	var apiErr *wework.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.Code == errCodeInvalidSuiteToken || apiErr.Code == errCodeSuiteTokenExpired
+}
+
+// post 以 JSON 调用服务商接口并解析 errcode
+func (s *Suite) post(ctx context.Context, path string, query url.Values, in, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("marshal request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.baseURL+path+"?"+query.Encode(), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var raw json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	var result struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	if result.ErrCode != 0 {
+		return &wework.APIError{Code: result.ErrCode, Msg: result.ErrMsg}
+	}
+	if err := json.Unmarshal(raw, out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
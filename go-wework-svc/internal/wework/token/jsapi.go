@@ -0,0 +1,73 @@
+package token
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"log/slog"
+	"net/url"
+	"strconv"
+
+	"go-wework-svc/internal/store"
+	"go-wework-svc/internal/wework"
+)
+
+// TicketKind jsapi_ticket 类型
+type TicketKind string
+
+const (
+	// TicketCorp 企业的 jsapi_ticket，用于 wx.config
+	TicketCorp TicketKind = "corp"
+	// TicketAgent 应用的 jsapi_ticket，用于 wx.agentConfig
+	TicketAgent TicketKind = "agent"
+)
+
+// ticketResponse get_jsapi_ticket / ticket/get API 响应
+type ticketResponse struct {
+	ErrCode   int    `json:"errcode"`
+	ErrMsg    string `json:"errmsg"`
+	Ticket    string `json:"ticket"`
+	ExpiresIn int64  `json:"expires_in"`
+}
+
+// NewTicketManager 创建 jsapi_ticket 管理器，Token 返回 ticket，缓存和刷新方式与 access_token 相同
+// 企业 ticket 与应用 ticket 各自限频，按企业和应用分别缓存
+func NewTicketManager(baseURL, corpID string, agentID int64, kind TicketKind, tokens TokenProvider, kv store.Store, logger *slog.Logger) *Manager {
+	if baseURL == "" {
+		baseURL = DefaultAPIBaseURL
+	}
+	key := "jsapi:" + string(kind) + ":" + corpID + ":" + strconv.FormatInt(agentID, 10)
+	m := newManager(baseURL, key, string(kind)+" jsapi ticket", kv, logger)
+	path := "/cgi-bin/get_jsapi_ticket"
+	if kind == TicketAgent {
+		path = "/cgi-bin/ticket/get"
+	}
+	m.get = func(ctx context.Context) (string, int64, error) {
+		var body ticketResponse
+		err := Do(ctx, tokens, func(tok string) error {
+			q := url.Values{"access_token": {tok}}
+			if kind == TicketAgent {
+				q.Set("type", "agent_config")
+			}
+			if err := m.getJSON(ctx, path+"?"+q.Encode(), &body); err != nil {
+				return err
+			}
+			if body.ErrCode != 0 {
+				return &wework.APIError{Code: body.ErrCode, Msg: body.ErrMsg}
+			}
+			return nil
+		})
+		if err != nil {
+			return "", 0, err
+		}
+		return body.Ticket, body.ExpiresIn, nil
+	}
+	return m
+}
+
+// JSAPISignature 计算 JS-SDK 签名，url 不含 # 及其后部分
+func JSAPISignature(ticket, nonceStr string, timestamp int64, url string) string {
+	s := "jsapi_ticket=" + ticket + "&noncestr=" + nonceStr + "&timestamp=" + strconv.FormatInt(timestamp, 10) + "&url=" + url
+	sum := sha1.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,71 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal/wework/token/token.go
+//
+// Generated by this command:
+//
+//	mockgen -source=internal/wework/token/token.go -destination=internal/wework/token/mock_token.go -package=token
+//
+
+// Package token is a generated GoMock package.
+package token
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockTokenProvider is a mock of TokenProvider interface.
+type MockTokenProvider struct {
+	ctrl     *gomock.Controller
+	recorder *MockTokenProviderMockRecorder
+	isgomock struct{}
+}
+
+// MockTokenProviderMockRecorder is the mock recorder for MockTokenProvider.
+type MockTokenProviderMockRecorder struct {
+	mock *MockTokenProvider
+}
+
+// NewMockTokenProvider creates a new mock instance.
+func NewMockTokenProvider(ctrl *gomock.Controller) *MockTokenProvider {
+	mock := &MockTokenProvider{ctrl: ctrl}
+	mock.recorder = &MockTokenProviderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTokenProvider) EXPECT() *MockTokenProviderMockRecorder {
+	return m.recorder
+}
+
+// Refresh mocks base method.
+func (m *MockTokenProvider) Refresh(ctx context.Context) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Refresh", ctx)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Refresh indicates an expected call of Refresh.
+func (mr *MockTokenProviderMockRecorder) Refresh(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Refresh", reflect.TypeOf((*MockTokenProvider)(nil).Refresh), ctx)
+}
+
+// Token mocks base method.
+func (m *MockTokenProvider) Token(ctx context.Context) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Token", ctx)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Token indicates an expected call of Token.
+func (mr *MockTokenProviderMockRecorder) Token(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Token", reflect.TypeOf((*MockTokenProvider)(nil).Token), ctx)
+}
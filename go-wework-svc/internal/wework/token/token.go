@@ -0,0 +1,262 @@
+package token
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go-wework-svc/internal/store"
+	"go-wework-svc/internal/wework"
+)
+
+const (
+	// DefaultAPIBaseURL 企业微信服务端 API 默认地址
+	DefaultAPIBaseURL = "https://qyapi.weixin.qq.com"
+
+	// refreshAhead 在过期前提前刷新的时间
+	refreshAhead = 5 * time.Minute
+	httpTimeout  = 5 * time.Second
+
+	// lockTTL 刷新租约的有效期，覆盖一次 gettoken 请求
+	lockTTL = 2 * httpTimeout
+	// lockPoll 等待其他副本刷新时读取共享 Store 的间隔
+	lockPoll = 200 * time.Millisecond
+)
+
+// TokenProvider access_token 提供者接口，供消息发送、素材上传等组件复用
+type TokenProvider interface {
+	// Token 返回有效的 access_token，缓存即将过期时自动刷新
+	Token(ctx context.Context) (string, error)
+
+	// Refresh 丢弃缓存并强制重新获取，用于处理 40014/42001 错误
+	Refresh(ctx context.Context) (string, error)
+}
+
+// Manager 基于 gettoken API 的 TokenProvider 实现，也用于缓存 jsapi_ticket（见 NewTicketManager）
+// 进程内缓存之外，token 还写入共享 Store，多副本部署时各副本复用同一个 token
+// 刷新前先获取共享 Store 中的租约，同一时刻只有一个副本调用 gettoken，其余副本等待并读取其结果
+type Manager struct {
+	baseURL    string
+	corpID     string
+	secret     string
+	kv         store.Store
+	key        string
+	lock       *store.Lock
+	httpClient *http.Client
+	logger     *slog.Logger
+	// name 日志中的凭证名称，get 调用企业微信 API 获取新凭证及其有效秒数
+	name string
+	get  func(ctx context.Context) (string, int64, error)
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+// NewManager 创建 access_token 管理器，baseURL 为空时使用 DefaultAPIBaseURL
+func NewManager(baseURL, corpID, secret string, kv store.Store, logger *slog.Logger) *Manager {
+	if baseURL == "" {
+		baseURL = DefaultAPIBaseURL
+	}
+	// 同一企业下不同应用的 secret 不同，key 使用 secret 摘要区分且不泄露 secret
+	sum := sha256.Sum256([]byte(secret))
+	m := newManager(baseURL, "token:"+corpID+":"+hex.EncodeToString(sum[:4]), "access token", kv, logger)
+	m.corpID, m.secret = corpID, secret
+	m.get = m.gettoken
+	return m
+}
+
+func newManager(baseURL, key, name string, kv store.Store, logger *slog.Logger) *Manager {
+	return &Manager{
+		baseURL:    baseURL,
+		kv:         kv,
+		key:        key,
+		lock:       store.NewLock(kv, key+":lock", lockTTL),
+		httpClient: &http.Client{Timeout: httpTimeout},
+		logger:     logger,
+		name:       name,
+	}
+}
+
+// Token 实现 TokenProvider 接口：进程内缓存 → 共享 Store → gettoken
+func (m *Manager) Token(ctx context.Context) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.valid() {
+		return m.token, nil
+	}
+	if m.loadShared(ctx) && m.valid() {
+		return m.token, nil
+	}
+	return m.refresh(ctx, "")
+}
+
+// Refresh 实现 TokenProvider 接口
+// 其他副本已刷新出不同的 token 时直接复用，多个副本同时遇到 token 失效只调用一次 gettoken
+func (m *Manager) Refresh(ctx context.Context) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stale := m.token
+	m.token = ""
+	return m.refresh(ctx, stale)
+}
+
+// refresh 持有租约时调用 gettoken，租约被占用时轮询共享 Store 等待持有者的结果
+// stale 为已知失效的 token；等待超过 lockTTL 或 Store 不可用时直接调用 gettoken，调用方需持有锁
+func (m *Manager) refresh(ctx context.Context, stale string) (string, error) {
+	deadline := time.Now().Add(lockTTL)
+	for {
+		unlock, ok, err := m.lock.TryLock(ctx)
+		if err != nil {
+			m.logger.Warn("failed to acquire token refresh lock, fetching directly", "error", err)
+			return m.fetch(ctx)
+		}
+		if ok {
+			defer unlock()
+			// 其他副本可能在本副本等待期间刚完成刷新
+			if m.fresh(ctx, stale) {
+				return m.token, nil
+			}
+			return m.fetch(ctx)
+		}
+		if time.Now().After(deadline) {
+			m.logger.Warn("timed out waiting for token refresh lock, fetching directly")
+			return m.fetch(ctx)
+		}
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("wait for token refresh: %w", ctx.Err())
+		case <-time.After(lockPoll):
+		}
+		if m.fresh(ctx, stale) {
+			return m.token, nil
+		}
+	}
+}
+
+// fresh 从共享 Store 读取有效且不同于 stale 的 token，调用方需持有锁
+func (m *Manager) fresh(ctx context.Context, stale string) bool {
+	if m.loadShared(ctx) && m.token != stale && m.valid() {
+		return true
+	}
+	if m.token == stale {
+		m.token = ""
+	}
+	return false
+}
+
+// valid 进程内缓存的 token 是否仍在有效期内，调用方需持有锁
+func (m *Manager) valid() bool {
+	return m.token != "" && time.Until(m.expiresAt) > refreshAhead
+}
+
+// loadShared 从共享 Store 读取其他副本获取的 token，格式 "<token>|<过期 unix 秒>"
+func (m *Manager) loadShared(ctx context.Context) bool {
+	v, ok, err := m.kv.Get(ctx, m.key)
+	if err != nil {
+		m.logger.Warn("failed to read shared "+m.name, "error", err)
+		return false
+	}
+	if !ok {
+		return false
+	}
+	tok, exp, found := strings.Cut(v, "|")
+	sec, err := strconv.ParseInt(exp, 10, 64)
+	if !found || err != nil {
+		return false
+	}
+	m.token = tok
+	m.expiresAt = time.Unix(sec, 0)
+	return true
+}
+
+// gettokenResponse gettoken API 响应
+type gettokenResponse struct {
+	ErrCode     int    `json:"errcode"`
+	ErrMsg      string `json:"errmsg"`
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// fetch 获取新凭证并写入共享 Store，调用方需持有锁
+func (m *Manager) fetch(ctx context.Context) (string, error) {
+	tok, expiresIn, err := m.get(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	m.token = tok
+	m.expiresAt = time.Now().Add(time.Duration(expiresIn) * time.Second)
+	if ttl := time.Until(m.expiresAt) - refreshAhead; ttl > 0 {
+		shared := m.token + "|" + strconv.FormatInt(m.expiresAt.Unix(), 10)
+		if err := m.kv.Set(ctx, m.key, shared, ttl); err != nil {
+			m.logger.Warn("failed to write shared "+m.name, "error", err)
+		}
+	}
+	m.logger.Info(m.name+" refreshed", "expires_in", expiresIn)
+	return m.token, nil
+}
+
+// gettoken 调用 gettoken API
+func (m *Manager) gettoken(ctx context.Context) (string, int64, error) {
+	q := url.Values{"corpid": {m.corpID}, "corpsecret": {m.secret}}
+	var body gettokenResponse
+	if err := m.getJSON(ctx, "/cgi-bin/gettoken?"+q.Encode(), &body); err != nil {
+		return "", 0, err
+	}
+	if body.ErrCode != 0 {
+		return "", 0, &wework.APIError{Code: body.ErrCode, Msg: body.ErrMsg}
+	}
+	return body.AccessToken, body.ExpiresIn, nil
+}
+
+// getJSON 发送 GET 请求并解码 JSON 响应
+func (m *Manager) getJSON(ctx context.Context, path string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, m.baseURL+path, nil)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+// Do 使用 access_token 执行 fn，遇到 token 失效错误时强制刷新并重试一次
+func Do(ctx context.Context, p TokenProvider, fn func(token string) error) error {
+	tok, err := p.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("get access token: %w", err)
+	}
+	err = fn(tok)
+	if !wework.IsTokenInvalid(err) {
+		return err
+	}
+
+	tok, err = p.Refresh(ctx)
+	if err != nil {
+		return fmt.Errorf("refresh access token: %w", err)
+	}
+	return fn(tok)
+}
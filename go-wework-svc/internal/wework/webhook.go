@@ -0,0 +1,40 @@
+package wework
+
+import "context"
+
+// WebhookMessage 群机器人消息，按 MsgType 填写对应字段
+type WebhookMessage struct {
+	MsgType string // text | markdown | image | news
+
+	// text / markdown
+	Content string
+	// MentionedList 仅 text 有效，填写 userid，"@all" 提醒所有人
+	MentionedList       []string
+	MentionedMobileList []string
+
+	// image：原始图片数据（jpg/png，不超过 2M），由发送方计算 base64 与 md5
+	Image []byte
+
+	// news：1~8 条图文
+	Articles []Article
+}
+
+// Article 图文消息条目
+type Article struct {
+	Title       string
+	Description string
+	URL         string
+	PicURL      string
+}
+
+// WithResponseURL 设置 response_url 客户端的构造函数，智能机器人 JSON 回调的异步回复、
+// 被动回复之外的分段和超时后的补发回复通过消息中的 response_url 发送，无需应用 Sender
+func WithResponseURL(newWebhook func(url string) Webhook) Option {
+	return func(s *serviceImpl) { s.responseURL = newWebhook }
+}
+
+// Webhook 群机器人发送接口，不依赖应用 access_token
+type Webhook interface {
+	// Post 向群机器人 webhook 发送消息
+	Post(ctx context.Context, msg WebhookMessage) error
+}
@@ -0,0 +1,61 @@
+package wework
+
+import (
+	"context"
+	"text/template"
+	"time"
+
+	"go-wework-svc/internal/store"
+)
+
+// WelcomePolicy 关注应用（subscribe）和进入应用（enter_agent）时主动发送的欢迎语
+type WelcomePolicy struct {
+	// Template 欢迎语模板，可用 {{.User}}、{{.Event}} 和 {{.Commands}}（启用命令时为 /help 的内容）
+	Template *template.Template
+	// Interval 同一用户进入应用时的最短发送间隔，关注事件不受限制；0 表示每次进入都发送
+	Interval time.Duration
+	// Store Interval 大于 0 时记录上次发送时间
+	Store store.Store
+}
+
+// welcomeInput 欢迎语模板可用的字段
+type welcomeInput struct {
+	User     string
+	Event    string
+	Commands string
+}
+
+// WithWelcome 启用欢迎语，覆盖 subscribe 和 enter_agent 事件已注册的处理器，需配置 Sender
+func WithWelcome(p WelcomePolicy) Option {
+	return func(s *serviceImpl) {
+		h := s.eventHandler(EventHandlerFunc(func(ctx context.Context, ev Event) (string, error) {
+			return s.welcome(ctx, p, ev)
+		}))
+		s.registry.RegisterEvent(EventSubscribe, h)
+		s.registry.RegisterEvent(EventEnterAgent, h)
+	}
+}
+
+// welcome 渲染欢迎语，进入应用时间隔内已发送过则返回空回复
+func (s *serviceImpl) welcome(ctx context.Context, p WelcomePolicy, ev Event) (string, error) {
+	if ev.Event == EventEnterAgent && p.Interval > 0 && p.Store != nil {
+		key := "welcome:" + s.agent + ":" + ev.FromUserName
+		ok, err := p.Store.SetNX(ctx, key, "1", p.Interval)
+		if err != nil {
+			// 存储不可用时仍发送，宁可重复也不遗漏
+			s.logger.WarnContext(ctx, "failed to record welcome", "from_user", ev.FromUserName, "error", err)
+		} else if !ok {
+			return "", nil
+		}
+	}
+
+	in := welcomeInput{User: ev.FromUserName, Event: ev.Event}
+	if s.commandPolicy != nil {
+		in.Commands, _ = s.help(ctx, Message{}, "")
+	}
+	text, err := render(p.Template, in)
+	if err != nil {
+		return "", err
+	}
+	return s.localize(ctx, ev.FromUserName, ReplyWelcome, text, in), nil
+}